@@ -0,0 +1,265 @@
+// ACME DNS-01 CHALLENGES
+//
+// An ACMEResponder answers the DNS-01 challenges of an ACME certificate
+// authority (RFC 8555, section 8.4): the TXT records at
+// _acme-challenge.<domain> are kept in a Zone, they expire on their own after
+// a while. Queries for TXT records in the zone are answered by the
+// responder, all other queries are handed to Next. Basic use pattern:
+//
+//	a := dns.NewACMEResponder(dns.NewZone("example.org."))
+//	a.Next = handler
+//	dns.DefaultServeMux.HandleType("_acme-challenge.www.example.org.", dns.TypeTXT, a)
+//	stop := a.Run(time.Minute)       // remove expired challenges
+//	err := a.Add("www.example.org.", keyAuthorizationDigest, time.Hour)
+//
+// The responder can also be driven over HTTP with the API of acme-dns, which
+// most ACME clients support, see HTTPHandler.
+package dns
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for ACMEResponder.
+const (
+	ACMETtl      = 60        // TTL of the TXT records
+	acmeKeepTxts = 2         // the number of TXT records per name kept for acme-dns clients
+	acmeDNSLen   = 43        // length of a key authorization digest in base64url
+	acmeLifetime = time.Hour // how long records set over HTTP live
+)
+
+// ACMEResponder keeps ACME challenge TXT records in a zone and answers
+// queries for them. It's safe for concurrent use by multiple goroutines.
+type ACMEResponder struct {
+	Zone     *Zone
+	Ttl      uint32  // TTL of the TXT records, defaults to ACMETtl
+	Next     Handler // Handles all queries the responder does not answer, if nil they are REFUSED
+	expire   map[*TXT]time.Time
+	accounts map[string]*acmeAccount // acme-dns accounts, keyed on the user name
+	*sync.RWMutex
+}
+
+type acmeAccount struct {
+	password  []byte // SHA-256 of the password
+	subdomain string
+}
+
+// NewACMEResponder returns an ACMEResponder that keeps its records in z.
+func NewACMEResponder(z *Zone) *ACMEResponder {
+	return &ACMEResponder{Zone: z, expire: make(map[*TXT]time.Time),
+		accounts: make(map[string]*acmeAccount), RWMutex: new(sync.RWMutex)}
+}
+
+// Add adds the TXT record with the challenge text txt for domain, it is
+// removed after d. The record is placed at _acme-challenge.<domain>, unless
+// domain already starts with that label.
+func (a *ACMEResponder) Add(domain, txt string, d time.Duration) error {
+	return a.add(acmeName(domain), txt, d)
+}
+
+func (a *ACMEResponder) add(name, txt string, d time.Duration) error {
+	ttl := a.Ttl
+	if ttl == 0 {
+		ttl = ACMETtl
+	}
+	r := &TXT{Hdr: RR_Header{Name: name, Rrtype: TypeTXT, Class: ClassINET, Ttl: ttl}, Txt: []string{txt}}
+	if err := a.Zone.Insert(r); err != nil {
+		return err
+	}
+	a.Lock()
+	a.expire[r] = time.Now().Add(d)
+	a.Unlock()
+	return nil
+}
+
+// Remove removes the TXT record with the challenge text txt for domain, as
+// is done when the challenge has been validated.
+func (a *ACMEResponder) Remove(domain, txt string) {
+	a.removeFunc(func(r *TXT, _ time.Time) bool {
+		return strings.EqualFold(r.Hdr.Name, acmeName(domain)) && r.Txt[0] == txt
+	})
+}
+
+// Expire removes the records that have expired.
+func (a *ACMEResponder) Expire() {
+	now := time.Now()
+	a.removeFunc(func(_ *TXT, t time.Time) bool { return now.After(t) })
+}
+
+func (a *ACMEResponder) removeFunc(f func(*TXT, time.Time) bool) {
+	a.Lock()
+	defer a.Unlock()
+	for r, t := range a.expire {
+		if f(r, t) {
+			a.Zone.Remove(r)
+			delete(a.expire, r)
+		}
+	}
+}
+
+// Run calls Expire every d. Send true on the returned channel to stop.
+func (a *ACMEResponder) Run(d time.Duration) chan bool {
+	stop := make(chan bool)
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				a.Expire()
+			}
+		}
+	}()
+	return stop
+}
+
+// acmeName returns the name of the challenge records of domain.
+func acmeName(domain string) string {
+	domain = Fqdn(strings.ToLower(domain))
+	if strings.HasPrefix(domain, "_acme-challenge.") {
+		return domain
+	}
+	return "_acme-challenge." + domain
+}
+
+// ServeDNS implements the Handler interface. TXT queries for names in the
+// zone are answered, an empty answer is given when there are no challenges.
+func (a *ACMEResponder) ServeDNS(w ResponseWriter, req *Msg) {
+	if len(req.Question) != 1 || req.Question[0].Qtype != TypeTXT || !a.Zone.isSubDomain(req.Question[0].Name) {
+		if a.Next != nil {
+			a.Next.ServeDNS(w, req)
+			return
+		}
+		m := new(Msg)
+		m.SetRcode(req, RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+	m := new(Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+	if zd, exact := a.Zone.Find(req.Question[0].Name); exact {
+		zd.RLock()
+		m.Answer = append(m.Answer, zd.RR[TypeTXT]...)
+		zd.RUnlock()
+	}
+	if len(m.Answer) == 0 {
+		if apex := a.Zone.Apex(); apex != nil {
+			apex.RLock()
+			m.Ns = append(m.Ns, apex.RR[TypeSOA]...)
+			apex.RUnlock()
+		}
+	}
+	w.WriteMsg(m)
+}
+
+// HTTPHandler returns an http.Handler that implements the API of acme-dns:
+//
+//	POST /register    creates an account, the reply holds the user name,
+//	                  password and the name to CNAME _acme-challenge to
+//	POST /update      sets a TXT record, with the X-Api-User and X-Api-Key
+//	                  headers and a body of {"subdomain": "...", "txt": "..."}
+//	GET  /health      returns 200
+//
+// Each account has a name in the zone of its own, the two most recent TXT
+// records of an account are kept (for certificates with both the domain and
+// its wildcard). The accounts are kept in memory only.
+func (a *ACMEResponder) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, password, subdomain := randomHex(16), randomHex(20), randomHex(16)
+		h := sha256.Sum256([]byte(password))
+		a.Lock()
+		a.accounts[user] = &acmeAccount{h[:], subdomain}
+		a.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"username":   user,
+			"password":   password,
+			"fulldomain": strings.TrimSuffix(subdomain+"."+a.Zone.Origin, "."),
+			"subdomain":  subdomain,
+			"allowfrom":  []string{},
+		})
+	})
+	mux.HandleFunc("/update", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Subdomain string `json:"subdomain"`
+			Txt       string `json:"txt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error": "malformed_json_payload"}`, http.StatusBadRequest)
+			return
+		}
+		h := sha256.Sum256([]byte(r.Header.Get("X-Api-Key")))
+		a.RLock()
+		acc, ok := a.accounts[r.Header.Get("X-Api-User")]
+		a.RUnlock()
+		if !ok || subtle.ConstantTimeCompare(acc.password, h[:]) != 1 || acc.subdomain != body.Subdomain {
+			http.Error(w, `{"error": "forbidden"}`, http.StatusUnauthorized)
+			return
+		}
+		if len(body.Txt) != acmeDNSLen {
+			http.Error(w, `{"error": "bad_txt"}`, http.StatusBadRequest)
+			return
+		}
+		name := Fqdn(acc.subdomain + "." + a.Zone.Origin)
+		if err := a.add(name, body.Txt, acmeLifetime); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		a.keepNewest(name, acmeKeepTxts)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"txt": body.Txt})
+	})
+	return mux
+}
+
+// keepNewest removes all but the n newest records at name.
+func (a *ACMEResponder) keepNewest(name string, n int) {
+	a.Lock()
+	defer a.Unlock()
+	var rrs []*TXT
+	for r := range a.expire {
+		if r.Hdr.Name == name {
+			rrs = append(rrs, r)
+		}
+	}
+	for len(rrs) > n {
+		oldest := 0
+		for i, r := range rrs {
+			if a.expire[r].Before(a.expire[rrs[oldest]]) {
+				oldest = i
+			}
+		}
+		a.Zone.Remove(rrs[oldest])
+		delete(a.expire, rrs[oldest])
+		rrs = append(rrs[:oldest], rrs[oldest+1:]...)
+	}
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func acmeQuery(a *ACMEResponder, name string) *Msg {
+	q := new(Msg)
+	q.SetQuestion(name, TypeTXT)
+	w := new(vectorWriter)
+	a.ServeDNS(w, q)
+	return w.msg
+}
+
+func TestACMEResponder(t *testing.T) {
+	a := NewACMEResponder(NewZone("example.org."))
+	if err := a.Add("www.example.org.", "token1", time.Hour); err != nil {
+		t.Fatalf("failed to add challenge: %s", err.Error())
+	}
+	a.Add("www.example.org.", "token2", -time.Second)
+	if m := acmeQuery(a, "_acme-challenge.www.example.org."); len(m.Answer) != 2 || !m.Authoritative {
+		t.Fatalf("expected two challenges, got:\n%s", m.String())
+	}
+	a.Expire()
+	m := acmeQuery(a, "_acme-challenge.www.example.org.")
+	if len(m.Answer) != 1 || m.Answer[0].(*TXT).Txt[0] != "token1" {
+		t.Fatalf("expected only token1 after expiry, got:\n%s", m.String())
+	}
+	a.Remove("www.example.org.", "token1")
+	if m := acmeQuery(a, "_acme-challenge.www.example.org."); len(m.Answer) != 0 {
+		t.Errorf("expected no challenges after removal, got:\n%s", m.String())
+	}
+	if m := acmeQuery(a, "www.example.net."); m.Rcode != RcodeRefused {
+		t.Errorf("query outside of the zone should be refused")
+	}
+}
+
+func TestACMEResponderHTTP(t *testing.T) {
+	a := NewACMEResponder(NewZone("auth.example.org."))
+	s := httptest.NewServer(a.HTTPHandler())
+	defer s.Close()
+
+	resp, err := http.Post(s.URL+"/register", "application/json", nil)
+	if err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to register: %v", err)
+	}
+	var acc struct {
+		Username, Password, Fulldomain, Subdomain string
+	}
+	json.NewDecoder(resp.Body).Decode(&acc)
+	resp.Body.Close()
+
+	for i, txt := range []string{"a", "b", "c"} {
+		body := `{"subdomain": "` + acc.Subdomain + `", "txt": "` + strings.Repeat(txt, 43) + `"}`
+		req, _ := http.NewRequest("POST", s.URL+"/update", strings.NewReader(body))
+		req.Header.Set("X-Api-User", acc.Username)
+		req.Header.Set("X-Api-Key", acc.Password)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("update %d failed: %v %v", i, err, resp.Status)
+		}
+		resp.Body.Close()
+		time.Sleep(time.Millisecond)
+	}
+	m := acmeQuery(a, acc.Fulldomain+".")
+	if len(m.Answer) != 2 {
+		t.Fatalf("expected the two newest records, got:\n%s", m.String())
+	}
+	for _, r := range m.Answer {
+		if r.(*TXT).Txt[0][0] == 'a' {
+			t.Errorf("oldest record was not removed")
+		}
+	}
+
+	req, _ := http.NewRequest("POST", s.URL+"/update", strings.NewReader(`{"subdomain": "`+acc.Subdomain+`", "txt": "`+strings.Repeat("d", 43)+`"}`))
+	req.Header.Set("X-Api-User", acc.Username)
+	req.Header.Set("X-Api-Key", "wrong")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("update with a bad password should be refused")
+	}
+}
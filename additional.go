@@ -0,0 +1,96 @@
+package dns
+
+// Additional section processing for zones (RFC 1034, section 4.3.2, step 6).
+
+import (
+	"strings"
+)
+
+// Additional returns the address records in the zone of the names the RRs in
+// rrs point to: the targets of NS, MX and SRV records. Targets outside of the
+// zone are skipped, as is the root (as in a "null" SRV or MX). With do set the
+// RRSIGs of the address records are included too. The address records of a
+// target are returned once, even when more RRs point to it. Glue (see
+// IsGlue) is returned without RRSIGs.
+func (z *Zone) Additional(rrs []RR, do bool) []RR {
+	var extra []RR
+	seen := make(map[string]bool)
+	for _, r := range rrs {
+		var target string
+		switch r := r.(type) {
+		case *NS:
+			target = r.Ns
+		case *MX:
+			target = r.Mx
+		case *SRV:
+			target = r.Target
+		default:
+			continue
+		}
+		target = strings.ToLower(target)
+		if target == "." || seen[target] || !z.isSubDomain(target) {
+			continue
+		}
+		seen[target] = true
+		zd, exact := z.Find(target)
+		if !exact {
+			continue
+		}
+		sigs := do && !z.IsGlue(target)
+		zd.RLock()
+		for _, t := range []uint16{TypeA, TypeAAAA} {
+			for _, a := range zd.RR[t] {
+				extra = append(extra, a)
+			}
+			if sigs {
+				for _, s := range zd.Signatures[t] {
+					extra = append(extra, s)
+				}
+			}
+		}
+		zd.RUnlock()
+	}
+	return extra
+}
+
+// AddAdditional adds the additional records, see Additional, for the
+// answer and authority sections of the reply m to its additional section.
+// RRSIGs are added when m has an OPT RR with the DO bit set. Records that
+// are already in the additional section are not added again. Basic use
+// pattern in a handler:
+//
+//	m.SetReply(req)
+//	m.Answer = ... // from z
+//	if opt := req.IsEdns0(); opt != nil {
+//		m.SetEdns0(opt.UDPSize(), opt.Do())
+//	}
+//	z.AddAdditional(m)
+func (z *Zone) AddAdditional(m *Msg) {
+	do := false
+	if opt := m.IsEdns0(); opt != nil {
+		do = opt.Do()
+	}
+	have := make(map[RR]bool, len(m.Extra))
+	for _, r := range m.Extra {
+		have[r] = true
+	}
+	// Put the new records before the OPT and TSIG records, which must be last
+	tail := 0
+	for tail < len(m.Extra) {
+		if t := m.Extra[len(m.Extra)-1-tail].Header().Rrtype; t != TypeOPT && t != TypeTSIG {
+			break
+		}
+		tail++
+	}
+	var extra []RR
+	for _, r := range z.Additional(append(append([]RR(nil), m.Answer...), m.Ns...), do) {
+		if !have[r] {
+			extra = append(extra, r)
+		}
+	}
+	if len(extra) == 0 {
+		return
+	}
+	i := len(m.Extra) - tail
+	m.Extra = append(m.Extra[:i], append(extra, m.Extra[i:]...)...)
+}
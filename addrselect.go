@@ -0,0 +1,158 @@
+package dns
+
+// Sorting addresses, following the destination address selection rules
+// from RFC 6724, section 6.
+
+import (
+	"net"
+	"sort"
+)
+
+// sortAddrs sorts ips in the order they should be tried. The source
+// address for each destination is found by connecting a UDP socket,
+// no packets are sent.
+func sortAddrs(ips []net.IP) {
+	srcs := make([]net.IP, len(ips))
+	for i, ip := range ips {
+		c, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: 9})
+		if err != nil {
+			continue // unusable destination
+		}
+		srcs[i] = c.LocalAddr().(*net.UDPAddr).IP
+		c.Close()
+	}
+	sort.Stable(&byRFC6724{ips, srcs})
+}
+
+// byRFC6724 sorts addrs by the rules of RFC 6724, srcs holds the source
+// address for each destination, nil when the destination is unreachable.
+type byRFC6724 struct {
+	addrs []net.IP
+	srcs  []net.IP
+}
+
+func (s *byRFC6724) Len() int { return len(s.addrs) }
+func (s *byRFC6724) Swap(i, j int) {
+	s.addrs[i], s.addrs[j] = s.addrs[j], s.addrs[i]
+	s.srcs[i], s.srcs[j] = s.srcs[j], s.srcs[i]
+}
+
+// Less implements rules 1, 2, 5, 6, 8 and 9. Rules 3 and 4 (deprecated and
+// home addresses), 7 (native transport) can't be checked portably.
+func (s *byRFC6724) Less(i, j int) bool {
+	da, db := s.addrs[i], s.addrs[j]
+	sa, sb := s.srcs[i], s.srcs[j]
+
+	// Rule 1: avoid unusable destinations
+	if (sa == nil) != (sb == nil) {
+		return sa != nil
+	}
+	if sa == nil {
+		return false
+	}
+	// Rule 2: prefer matching scope
+	if ma, mb := scope(da) == scope(sa), scope(db) == scope(sb); ma != mb {
+		return ma
+	}
+	pa, pb := policy(da), policy(db)
+	// Rule 5: prefer matching label
+	if ma, mb := pa.label == policy(sa).label, pb.label == policy(sb).label; ma != mb {
+		return ma
+	}
+	// Rule 6: prefer higher precedence
+	if pa.precedence != pb.precedence {
+		return pa.precedence > pb.precedence
+	}
+	// Rule 8: prefer smaller scope
+	if a, b := scope(da), scope(db); a != b {
+		return a < b
+	}
+	// Rule 9: use longest matching prefix, only for IPv6 as for IPv4 this
+	// would undo the round robin of the name server.
+	if da.To4() == nil && db.To4() == nil {
+		return commonPrefixLen(sa, da) > commonPrefixLen(sb, db)
+	}
+	// Rule 10: otherwise, leave the order unchanged
+	return false
+}
+
+type policyEntry struct {
+	prefix     *net.IPNet
+	precedence uint8
+	label      uint8
+}
+
+// policyTable is the default policy table of RFC 6724, section 2.1,
+// longest prefixes first. IPv4 addresses are matched in their IPv4-mapped
+// IPv6 form.
+var policyTable = []policyEntry{
+	{mustCIDR("::1/128"), 50, 0},
+	{mustCIDR("::ffff:0:0/96"), 35, 4},
+	{mustCIDR("::/96"), 1, 3},
+	{mustCIDR("2001::/32"), 5, 5},
+	{mustCIDR("2002::/16"), 30, 2},
+	{mustCIDR("3ffe::/16"), 1, 12},
+	{mustCIDR("fec0::/10"), 1, 11},
+	{mustCIDR("fc00::/7"), 3, 13},
+	{mustCIDR("::/0"), 40, 1},
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic("dns: bad CIDR: " + s)
+	}
+	return n
+}
+
+func policy(ip net.IP) policyEntry {
+	ip = ip.To16()
+	for _, p := range policyTable {
+		if p.prefix.Contains(ip) {
+			return p
+		}
+	}
+	return policyTable[len(policyTable)-1]
+}
+
+// Address scopes, RFC 4291, section 2.7.
+const (
+	scopeLinkLocal = 0x2
+	scopeSiteLocal = 0x5
+	scopeGlobal    = 0xe
+)
+
+func scope(ip net.IP) uint8 {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return scopeLinkLocal
+	}
+	if ip4 := ip.To4(); ip4 == nil {
+		if ip.IsMulticast() {
+			return ip[1] & 0xf
+		}
+		if ip[0] == 0xfe && ip[1]&0xc0 == 0xc0 {
+			return scopeSiteLocal
+		}
+	}
+	return scopeGlobal
+}
+
+// commonPrefixLen returns the number of leading bits a and b have in
+// common, up to the 64 bits of the prefix of an IPv6 address.
+func commonPrefixLen(a, b net.IP) int {
+	a, b = a.To16(), b.To16()
+	n := 0
+	for i := 0; i < 8; i++ {
+		x := a[i] ^ b[i]
+		if x == 0 {
+			n += 8
+			continue
+		}
+		for x&0x80 == 0 {
+			n++
+			x <<= 1
+		}
+		break
+	}
+	return n
+}
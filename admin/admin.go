@@ -0,0 +1,305 @@
+// Package admin is a management API for the zones of a process over HTTP,
+// for embedders that want a management plane without writing one. All
+// requests and replies are JSON, the RRs are in the form of dns.ZoneRR. The
+// endpoints are:
+//
+//	GET    /zones                                the zones, with their serial
+//	GET    /zones/{origin}                       the zone, see dns.Zone.MarshalJSON
+//	GET    /zones/{origin}/rrsets/{name}/{type}  the RRset, name is relative to the origin or absolute
+//	PUT    /zones/{origin}/rrsets/{name}/{type}  replace the RRset with the RRs in the body
+//	DELETE /zones/{origin}/rrsets/{name}/{type}  remove the RRset
+//	POST   /zones/{origin}/sign                  call Sign
+//	POST   /zones/{origin}/reload                call Reload
+//	POST   /zones/{origin}/transfer              call Transfer
+//	GET    /stats                                the result of Stats
+//
+// Basic use pattern:
+//
+//	zones := dns.NewZoneSet()
+//	zones.Add(z)
+//	h := admin.NewHandler(zones)
+//	h.Authorize = func(r *http.Request) bool { return r.Header.Get("X-Api-Key") == key }
+//	go http.ListenAndServe("127.0.0.1:8053", h)
+package admin
+
+import (
+	"encoding/json"
+	"github.com/Meyermagic/dns"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBodySize is the largest request body the Handler reads.
+const maxBodySize = 1 << 20
+
+// Handler is the http.Handler of the management API for the zones in
+// Zones. The functions that trigger actions are optional, when one is nil
+// its endpoint returns 501 (Not Implemented). Authorize is not: when it is
+// nil all requests are refused, set it to a function that always returns
+// true to leave the API open.
+type Handler struct {
+	Zones     *dns.ZoneSet
+	Authorize func(r *http.Request) bool             // Requests it returns false for are refused
+	Sign      func(z *dns.Zone) error                // Sign the zone, e.g. with z.Sign
+	Reload    func(origin string) (*dns.Zone, error) // Read the zone again, the result replaces it in Zones
+	Transfer  func(z *dns.Zone) error                // Transfer the zone in again, or send NOTIFYs
+	Stats     func() interface{}                     // Statistics, encoded as JSON
+}
+
+// NewHandler returns a Handler for zones.
+func NewHandler(zones *dns.ZoneSet) *Handler {
+	return &Handler{Zones: zones}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Authorize == nil || !h.Authorize(r) {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "stats":
+		if method(w, r, "GET") {
+			h.stats(w, r)
+		}
+		return
+	case len(path) == 1 && path[0] == "zones":
+		if method(w, r, "GET") {
+			h.list(w, r)
+		}
+		return
+	case len(path) < 2 || path[0] != "zones":
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	z := h.Zones.Zone(path[1])
+	if z == nil {
+		writeError(w, http.StatusNotFound, "no such zone")
+		return
+	}
+	switch {
+	case len(path) == 2:
+		if method(w, r, "GET") {
+			writeJSON(w, http.StatusOK, z)
+		}
+	case len(path) == 5 && path[2] == "rrsets":
+		name, t, err := rrset(z, path[3], path[4])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		switch r.Method {
+		case "GET":
+			h.getRRset(w, z, name, t)
+		case "PUT":
+			h.putRRset(w, r, z, name, t)
+		case "DELETE":
+			if err := z.RemoveRRset(name, t); err != nil {
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	case len(path) == 3 && path[2] == "sign":
+		if method(w, r, "POST") {
+			h.sign(w, z)
+		}
+	case len(path) == 3 && path[2] == "reload":
+		if method(w, r, "POST") {
+			h.reload(w, z)
+		}
+	case len(path) == 3 && path[2] == "transfer":
+		if method(w, r, "POST") {
+			h.transfer(w, z)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// method returns true when r uses method m, otherwise it writes an error.
+func method(w http.ResponseWriter, r *http.Request, m string) bool {
+	if r.Method != m {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+// ZoneInfo is the description of a zone in the list of zones.
+type ZoneInfo struct {
+	Origin   string    `json:"origin"`
+	Serial   uint32    `json:"serial"`
+	Modified time.Time `json:"modified"`
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	zones := []ZoneInfo{}
+	for _, origin := range h.Zones.Origins() {
+		z := h.Zones.Zone(origin)
+		if z == nil {
+			continue // removed in the mean time
+		}
+		info := ZoneInfo{Origin: z.Origin}
+		if apex, exact := z.Find(z.Origin); exact {
+			apex.RLock()
+			if soa := apex.RR[dns.TypeSOA]; len(soa) > 0 {
+				info.Serial = soa[0].(*dns.SOA).Serial
+			}
+			apex.RUnlock()
+		}
+		z.RLock()
+		info.Modified = z.ModTime
+		z.RUnlock()
+		zones = append(zones, info)
+	}
+	writeJSON(w, http.StatusOK, zones)
+}
+
+// rrset returns the name and type of an RRset in the path, name is absolute
+// or relative to the origin of z.
+func rrset(z *dns.Zone, name, typ string) (string, uint16, error) {
+	if name == "@" {
+		name = z.Origin
+	}
+	if !dns.IsFqdn(name) {
+		name += "." + z.Origin
+	}
+	t, err := dns.ParseType(typ)
+	return name, t, err
+}
+
+func (h *Handler) getRRset(w http.ResponseWriter, z *dns.Zone, name string, t uint16) {
+	zd, exact := z.Find(name)
+	if !exact {
+		writeError(w, http.StatusNotFound, "no such rrset")
+		return
+	}
+	rrs := []*dns.ZoneRR{}
+	zd.RLock()
+	for _, rr := range zd.RR[t] {
+		if zr, err := dns.NewZoneRR(rr); err == nil {
+			rrs = append(rrs, zr)
+		}
+	}
+	zd.RUnlock()
+	if len(rrs) == 0 {
+		writeError(w, http.StatusNotFound, "no such rrset")
+		return
+	}
+	writeJSON(w, http.StatusOK, rrs)
+}
+
+func (h *Handler) putRRset(w http.ResponseWriter, r *http.Request, z *dns.Zone, name string, t uint16) {
+	var zrs []*dns.ZoneRR
+	if err := json.NewDecoder(r.Body).Decode(&zrs); err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "malformed json: "+err.Error())
+		return
+	}
+	rrs := make([]dns.RR, len(zrs))
+	for i, zr := range zrs {
+		if zr.Name == "" {
+			zr.Name = name
+		}
+		rr, err := zr.RR()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if rr.Header().Rrtype != t || !strings.EqualFold(rr.Header().Name, name) {
+			writeError(w, http.StatusBadRequest, "rr does not match the rrset: "+rr.String())
+			return
+		}
+		rrs[i] = rr
+	}
+	// Replace the RRset in one transaction, so it's left alone when one of
+	// the RRs can't be inserted
+	txn := z.Begin()
+	if err := txn.RemoveRRset(name, t); err != nil {
+		txn.Rollback()
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	for _, rr := range rrs {
+		if err := txn.Insert(rr); err != nil {
+			txn.Rollback()
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := txn.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) sign(w http.ResponseWriter, z *dns.Zone) {
+	if h.Sign == nil {
+		writeError(w, http.StatusNotImplemented, "signing not configured")
+		return
+	}
+	action(w, h.Sign(z))
+}
+
+func (h *Handler) reload(w http.ResponseWriter, z *dns.Zone) {
+	if h.Reload == nil {
+		writeError(w, http.StatusNotImplemented, "reloading not configured")
+		return
+	}
+	z1, err := h.Reload(z.Origin)
+	if err == nil {
+		h.Zones.Add(z1)
+	}
+	action(w, err)
+}
+
+func (h *Handler) transfer(w http.ResponseWriter, z *dns.Zone) {
+	if h.Transfer == nil {
+		writeError(w, http.StatusNotImplemented, "transfers not configured")
+		return
+	}
+	action(w, h.Transfer(z))
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	if h.Stats == nil {
+		writeError(w, http.StatusNotImplemented, "statistics not configured")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Stats())
+}
+
+// action writes the reply to a request that triggers an action.
+func action(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/Meyermagic/dns"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	z := dns.NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 42 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "www.miek.nl. A 127.0.0.1"} {
+		rr, _ := dns.NewRR(s)
+		z.Insert(rr)
+	}
+	zones := dns.NewZoneSet()
+	zones.Add(z)
+	h := NewHandler(zones)
+	h.Authorize = func(r *http.Request) bool { return r.Header.Get("X-Api-Key") == "secret" }
+	signed := false
+	h.Sign = func(z *dns.Zone) error { signed = true; return nil }
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("X-Api-Key", "secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	var list []ZoneInfo
+	if w := do("GET", "/zones", ""); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &list) != nil {
+		t.Fatalf("failed to list zones: %d %s", w.Code, w.Body)
+	}
+	if len(list) != 1 || list[0].Origin != "miek.nl." || list[0].Serial != 42 {
+		t.Errorf("unexpected zone list %+v", list)
+	}
+	if w := do("GET", "/zones/miek.nl./rrsets/www/A", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"127.0.0.1"`) {
+		t.Errorf("failed to get the rrset: %d %s", w.Code, w.Body)
+	}
+	body := `[{"type": "A", "ttl": 300, "rdata": {"A": "192.0.2.1"}}, {"type": "A", "ttl": 300, "rdata": {"A": "192.0.2.2"}}]`
+	if w := do("PUT", "/zones/miek.nl./rrsets/www/A", body); w.Code != http.StatusNoContent {
+		t.Errorf("failed to put the rrset: %d %s", w.Code, w.Body)
+	}
+	if www, _ := z.Find("www.miek.nl."); len(www.RR[dns.TypeA]) != 2 {
+		t.Errorf("expected 2 A records, got %v", www.RR[dns.TypeA])
+	}
+	if w := do("PUT", "/zones/miek.nl./rrsets/www/A", `[{"type": "MX", "ttl": 300, "rdata": {"Mx": "www.miek.nl."}}]`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a mismatching rr, got %d", w.Code)
+	}
+	// When one RR can't be inserted the RRset is left alone
+	z.NSEC3Policy = dns.StrictNSEC3Policy
+	param := &dns.NSEC3PARAM{Hdr: dns.RR_Header{Name: "miek.nl.", Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET}, Hash: 1}
+	if err := z.Insert(param); err != nil {
+		t.Fatalf("failed to insert NSEC3PARAM: %s", err.Error())
+	}
+	body = `[{"type": "NSEC3PARAM", "ttl": 0, "rdata": {"Hash": 1, "Flags": 0, "Iterations": 0, "SaltLength": 0, "Salt": ""}},
+		{"type": "NSEC3PARAM", "ttl": 0, "rdata": {"Hash": 1, "Flags": 0, "Iterations": 10, "SaltLength": 0, "Salt": ""}}]`
+	if w := do("PUT", "/zones/miek.nl./rrsets/miek.nl./NSEC3PARAM", body); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a refused rr, got %d", w.Code)
+	}
+	if apex, _ := z.Find("miek.nl."); len(apex.RR[dns.TypeNSEC3PARAM]) != 1 || apex.RR[dns.TypeNSEC3PARAM][0] != dns.RR(param) {
+		t.Errorf("rrset changed by a failed put: %v", apex.RR[dns.TypeNSEC3PARAM])
+	}
+	z.NSEC3Policy = nil
+	if w := do("DELETE", "/zones/miek.nl./rrsets/www.miek.nl./A", ""); w.Code != http.StatusNoContent {
+		t.Errorf("failed to delete the rrset: %d %s", w.Code, w.Body)
+	}
+	if w := do("GET", "/zones/miek.nl./rrsets/www/A", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted rrset, got %d", w.Code)
+	}
+	if w := do("GET", "/zones/example.org./rrsets/www/A", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown zone, got %d", w.Code)
+	}
+	if w := do("POST", "/zones/miek.nl./sign", ""); w.Code != http.StatusNoContent || !signed {
+		t.Errorf("failed to sign: %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/zones/miek.nl./reload", ""); w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without Reload, got %d", w.Code)
+	}
+	r := httptest.NewRequest("GET", "/zones", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the api key, got %d", w.Code)
+	}
+	big := "[" + strings.Repeat(" ", maxBodySize) + "]"
+	if w := do("PUT", "/zones/miek.nl./rrsets/www/A", big); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a large body, got %d", w.Code)
+	}
+	// Without Authorize everything is refused
+	h.Authorize = nil
+	if w := do("GET", "/zones", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without Authorize, got %d", w.Code)
+	}
+}
@@ -0,0 +1,175 @@
+package dns
+
+// Registry of DNSSEC signature and DS digest algorithms.
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"math/big"
+	"sync"
+)
+
+// VerifyFunc verifies the signature sig over data, with the public key
+// from k. It returns nil when the signature is valid.
+type VerifyFunc func(k *DNSKEY, data, sig []byte) error
+
+var (
+	verifiers   = make(map[uint8]VerifyFunc)
+	verifiersMu sync.RWMutex
+	digests     = map[uint8]func() hash.Hash{SHA1: sha1.New, SHA256: sha256.New, SHA384: sha512.New384}
+	digestsMu   sync.RWMutex
+)
+
+func init() {
+	for _, alg := range []uint8{RSAMD5, RSASHA1, RSASHA1NSEC3SHA1, RSASHA256, RSASHA512} {
+		RegisterAlgorithm(alg, verifyRSA)
+	}
+	RegisterAlgorithm(DSA, verifyDSA)
+	RegisterAlgorithm(DSANSEC3SHA1, verifyDSA)
+	RegisterAlgorithm(ECDSAP256SHA256, verifyECDSA)
+	RegisterAlgorithm(ECDSAP384SHA384, verifyECDSA)
+	RegisterAlgorithm(ED25519, verifyED25519)
+}
+
+// RegisterAlgorithm registers f as the verification function for the
+// DNSSEC algorithm alg, replacing the current one. This can be used to add
+// algorithms that are not supported by this package, like ECC-GOST. If f is
+// nil the algorithm is removed.
+func RegisterAlgorithm(alg uint8, f VerifyFunc) {
+	verifiersMu.Lock()
+	defer verifiersMu.Unlock()
+	if f == nil {
+		delete(verifiers, alg)
+		return
+	}
+	verifiers[alg] = f
+}
+
+// AlgorithmSupported returns true when signatures made with alg can be verified.
+// A validator should treat a zone that is only signed with unsupported
+// algorithms as insecure.
+func AlgorithmSupported(alg uint8) bool {
+	_, ok := verifier(alg)
+	return ok
+}
+
+func verifier(alg uint8) (VerifyFunc, bool) {
+	verifiersMu.RLock()
+	defer verifiersMu.RUnlock()
+	f, ok := verifiers[alg]
+	return f, ok
+}
+
+func verifyRSA(k *DNSKEY, data, sig []byte) error {
+	pubkey := k.publicKeyRSA()
+	if pubkey == nil {
+		return ErrKey
+	}
+	var (
+		h  hash.Hash
+		ch crypto.Hash
+	)
+	switch k.Algorithm {
+	case RSAMD5:
+		h, ch = md5.New(), crypto.MD5
+	case RSASHA1, RSASHA1NSEC3SHA1:
+		h, ch = sha1.New(), crypto.SHA1
+	case RSASHA256:
+		h, ch = sha256.New(), crypto.SHA256
+	case RSASHA512:
+		h, ch = sha512.New(), crypto.SHA512
+	default:
+		return ErrKeyAlg
+	}
+	h.Write(data)
+	return rsa.VerifyPKCS1v15(pubkey, ch, h.Sum(nil), sig)
+}
+
+func verifyDSA(k *DNSKEY, data, sig []byte) error {
+	pubkey := k.publicKeyDSA()
+	if pubkey == nil {
+		return ErrKey
+	}
+	// T, R and S (RFC 2536, section 3)
+	if len(sig) != 41 {
+		return ErrSig
+	}
+	h := sha1.New()
+	h.Write(data)
+	r := big.NewInt(0).SetBytes(sig[1:21])
+	s := big.NewInt(0).SetBytes(sig[21:])
+	if !dsa.Verify(pubkey, h.Sum(nil), r, s) {
+		return ErrSig
+	}
+	return nil
+}
+
+func verifyECDSA(k *DNSKEY, data, sig []byte) error {
+	pubkey := k.publicKeyCurve()
+	if pubkey == nil || pubkey.Curve == nil {
+		return ErrKey
+	}
+	var h hash.Hash
+	switch k.Algorithm {
+	case ECDSAP256SHA256:
+		h = sha256.New()
+	case ECDSAP384SHA384:
+		h = sha512.New384()
+	}
+	h.Write(data)
+	// Split sig into the r and s coordinates
+	r := big.NewInt(0).SetBytes(sig[:len(sig)/2])
+	s := big.NewInt(0).SetBytes(sig[len(sig)/2:])
+	if !ecdsa.Verify(pubkey, h.Sum(nil), r, s) {
+		return ErrSig
+	}
+	return nil
+}
+
+func verifyED25519(k *DNSKEY, data, sig []byte) error {
+	keybuf, err := packBase64([]byte(k.PublicKey))
+	if err != nil || len(keybuf) != ed25519.PublicKeySize {
+		return ErrKey
+	}
+	if !ed25519.Verify(ed25519.PublicKey(keybuf), data, sig) {
+		return ErrSig
+	}
+	return nil
+}
+
+// RegisterDigest registers f as the hash function for the DS digest
+// type t, replacing the current one. SHA1, SHA256 and SHA384 are registered
+// by default; GOST94 is not, as Go has no GOST R 34.11-94 implementation.
+// If f is nil the digest type is removed.
+func RegisterDigest(t uint8, f func() hash.Hash) {
+	digestsMu.Lock()
+	defer digestsMu.Unlock()
+	if f == nil {
+		delete(digests, t)
+		return
+	}
+	digests[t] = f
+}
+
+// DigestSupported returns true when DS records with digest type t can be
+// created and checked. A validator should treat a DS RRset with only
+// unsupported digest types as insecure.
+func DigestSupported(t uint8) bool {
+	_, ok := digestHash(t)
+	return ok
+}
+
+func digestHash(t uint8) (func() hash.Hash, bool) {
+	digestsMu.RLock()
+	defer digestsMu.RUnlock()
+	f, ok := digests[t]
+	return f, ok
+}
@@ -0,0 +1,108 @@
+package dns
+
+// Helpers for the CERT and OPENPGPKEY records.
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// CERT certificate types, RFC 4398, section 2.1.
+const (
+	CertPKIX    = 1
+	CertSPKI    = 2
+	CertPGP     = 3
+	CertIPKIX   = 4
+	CertISPKI   = 5
+	CertIPGP    = 6
+	CertACPKIX  = 7
+	CertIACPKIX = 8
+	CertURI     = 253
+	CertOID     = 254
+)
+
+// Map of the mnemonics of the CERT certificate types.
+var CertTypeToString = map[uint16]string{
+	CertPKIX:    "PKIX",
+	CertSPKI:    "SPKI",
+	CertPGP:     "PGP",
+	CertIPKIX:   "IPKIX",
+	CertISPKI:   "ISPKI",
+	CertIPGP:    "IPGP",
+	CertACPKIX:  "ACPKIX",
+	CertIACPKIX: "IACPKIX",
+	CertURI:     "URI",
+	CertOID:     "OID",
+}
+
+var StringToCertType = reverseInt16(CertTypeToString)
+
+// SetX509 puts the X.509 certificate cert in rr, with type PKIX. The key tag and
+// algorithm are set to zero, as cert is not a DNSSEC key.
+func (rr *CERT) SetX509(cert *x509.Certificate) {
+	rr.Hdr.Rrtype = TypeCERT
+	rr.Type = CertPKIX
+	rr.KeyTag = 0
+	rr.Algorithm = 0
+	rr.Certificate = base64.StdEncoding.EncodeToString(cert.Raw)
+}
+
+// X509 returns the X.509 certificate in rr, which must have type PKIX.
+func (rr *CERT) X509() (*x509.Certificate, error) {
+	if rr.Type != CertPKIX {
+		return nil, ErrKeyAlg
+	}
+	b, err := base64.StdEncoding.DecodeString(rr.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(b)
+}
+
+// SetPGP puts the OpenPGP key (a binary transferable public key, RFC 4880)
+// key in rr, with type PGP.
+func (rr *CERT) SetPGP(key []byte) {
+	rr.Hdr.Rrtype = TypeCERT
+	rr.Type = CertPGP
+	rr.KeyTag = 0
+	rr.Algorithm = 0
+	rr.Certificate = base64.StdEncoding.EncodeToString(key)
+}
+
+// PGP returns the OpenPGP key in rr, which must have type PGP.
+func (rr *CERT) PGP() ([]byte, error) {
+	if rr.Type != CertPGP {
+		return nil, ErrKeyAlg
+	}
+	return base64.StdEncoding.DecodeString(rr.Certificate)
+}
+
+// SetKey puts the OpenPGP key (a binary transferable public key, RFC 4880)
+// key in rr.
+func (rr *OPENPGPKEY) SetKey(key []byte) {
+	rr.Hdr.Rrtype = TypeOPENPGPKEY
+	rr.PublicKey = base64.StdEncoding.EncodeToString(key)
+}
+
+// Key returns the OpenPGP key in rr.
+func (rr *OPENPGPKEY) Key() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(rr.PublicKey)
+}
+
+// OPENPGPKEYName returns the ownername of the OPENPGPKEY record for the
+// e-mail address email, as per RFC 7929, Section 3: the hex encoded SHA-256
+// hash of the local part, truncated to 28 octets, in the _openpgpkey
+// subdomain of the domain of the address. The local part is used as is,
+// so the lookup is case sensitive. When an error occurs the empty string is
+// returned.
+func OPENPGPKEYName(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i <= 0 || i == len(email)-1 {
+		return ""
+	}
+	h := sha256.Sum256([]byte(email[:i]))
+	return hex.EncodeToString(h[:28]) + "._openpgpkey." + Fqdn(email[i+1:])
+}
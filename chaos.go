@@ -0,0 +1,99 @@
+package dns
+
+import (
+	"os"
+	"strings"
+)
+
+// ChaosHandler answers the well known TXT queries in the CHAOS class:
+// version.bind, version.server, hostname.bind, id.server, authors.bind
+// and authors.server. Basic use:
+//
+//	c := dns.NewChaosHandler()
+//	c.Version = "" // don't tell
+//	c.Handle(nil)  // register in dns.DefaultServeMux
+//
+// Queries for these names in another class are handed to Next, so that the
+// names can still exist in the IN class.
+type ChaosHandler struct {
+	Version  string   // Returned for version.bind and version.server
+	Hostname string   // Returned for hostname.bind
+	Id       string   // Returned for id.server
+	Authors  []string // Returned for authors.bind and authors.server
+	Next     Handler  // If not nil, called for queries not in the CHAOS class
+}
+
+// NewChaosHandler returns a ChaosHandler with Version set to Go DNS' version,
+// Hostname and Id set to the hostname and Authors set to the authors of Go
+// DNS. Setting a value to the empty string (or nil for Authors) causes
+// those queries to be refused.
+func NewChaosHandler() *ChaosHandler {
+	c := new(ChaosHandler)
+	c.Version = "Go DNS " + Version
+	c.Hostname, _ = os.Hostname()
+	c.Id = c.Hostname
+	c.Authors = Authors
+	return c
+}
+
+// Handle registers c for all the names it answers in mux. If mux is nil
+// DefaultServeMux is used.
+func (c *ChaosHandler) Handle(mux *ServeMux) {
+	if mux == nil {
+		mux = DefaultServeMux
+	}
+	for _, name := range []string{"version.bind.", "version.server.", "hostname.bind.", "id.server.", "authors.bind.", "authors.server."} {
+		mux.Handle(name, c)
+	}
+}
+
+// ServeDNS implements the Handler interface.
+func (c *ChaosHandler) ServeDNS(w ResponseWriter, r *Msg) {
+	if len(r.Question) != 1 {
+		HandleFailed(w, r)
+		return
+	}
+	q := r.Question[0]
+	if q.Qclass != ClassCHAOS {
+		if c.Next != nil {
+			c.Next.ServeDNS(w, r)
+			return
+		}
+		HandleFailed(w, r)
+		return
+	}
+	var txt []string
+	switch strings.ToLower(q.Name) {
+	case "version.bind.", "version.server.":
+		if c.Version != "" {
+			txt = []string{c.Version}
+		}
+	case "hostname.bind.":
+		if c.Hostname != "" {
+			txt = []string{c.Hostname}
+		}
+	case "id.server.":
+		if c.Id != "" {
+			txt = []string{c.Id}
+		}
+	case "authors.bind.", "authors.server.":
+		txt = c.Authors
+	}
+	m := new(Msg)
+	if len(txt) == 0 {
+		m.SetRcode(r, RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+	m.SetReply(r)
+	m.Authoritative = true
+	if q.Qtype != TypeTXT && q.Qtype != TypeANY {
+		w.WriteMsg(m)
+		return
+	}
+	for _, t := range txt {
+		h := RR_Header{q.Name, TypeTXT, ClassCHAOS, 0, 0}
+		m.Answer = append(m.Answer, &TXT{h, []string{t}})
+	}
+	w.WriteMsg(m)
+}
@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestChaosHandler(t *testing.T) {
+	c := NewChaosHandler()
+	c.Version = "test 1.0"
+	c.Hostname = "ns1.example.org"
+	c.Id = "ns1"
+	mux := NewServeMux()
+	c.Handle(mux)
+	query := func(name string, qtype, qclass uint16) *Msg {
+		req := new(Msg)
+		req.SetQuestion(name, qtype)
+		req.Question[0].Qclass = qclass
+		w := new(testWriter)
+		mux.ServeDNS(w, req)
+		if w.msg == nil {
+			t.Fatalf("no reply for %s", name)
+		}
+		return w.msg
+	}
+	for name, txt := range map[string]string{"version.bind.": "test 1.0", "VERSION.server.": "test 1.0",
+		"hostname.bind.": "ns1.example.org", "id.server.": "ns1"} {
+		m := query(name, TypeTXT, ClassCHAOS)
+		if m.Rcode != RcodeSuccess || !m.Authoritative || len(m.Answer) != 1 {
+			t.Errorf("%s: unexpected reply %v", name, m)
+			continue
+		}
+		if a := m.Answer[0].(*TXT); a.Hdr.Class != ClassCHAOS || a.Txt[0] != txt {
+			t.Errorf("%s: expected %q in the CH class, got %v", name, txt, a)
+		}
+	}
+	if m := query("version.bind.", TypeA, ClassCHAOS); m.Rcode != RcodeSuccess || len(m.Answer) != 0 {
+		t.Errorf("expected no answer for type A, got %v", m)
+	}
+
+	// Other names and empty values are refused
+	c.Hostname = ""
+	if m := query("hostname.bind.", TypeTXT, ClassCHAOS); m.Rcode != RcodeRefused {
+		t.Errorf("expected REFUSED for an empty hostname, got %s", RcodeToString[m.Rcode])
+	}
+	for _, name := range []string{"foo.bind.", "www.example.org."} {
+		req := new(Msg)
+		req.SetQuestion(name, TypeTXT)
+		req.Question[0].Qclass = ClassCHAOS
+		w := new(testWriter)
+		c.ServeDNS(w, req)
+		if w.msg == nil || w.msg.Rcode != RcodeRefused {
+			t.Errorf("%s: expected REFUSED, got %v", name, w.msg)
+		}
+	}
+
+	// Other classes are handed to Next
+	if m := query("version.bind.", TypeTXT, ClassINET); m.Rcode != RcodeServerFailure {
+		t.Errorf("expected SERVFAIL without Next, got %s", RcodeToString[m.Rcode])
+	}
+	c.Next = HandlerFunc(HandleRefused)
+	if m := query("version.bind.", TypeTXT, ClassINET); m.Rcode != RcodeRefused || len(m.Answer) != 0 {
+		t.Errorf("expected the IN query to be handed to Next, got %v", m)
+	}
+}
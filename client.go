@@ -3,8 +3,12 @@ package dns
 // A concurrent client implementation. 
 
 import (
+	"context"
+	"crypto/tls"
 	"io"
+	"math/rand"
 	"net"
+	"strings"
 	"time"
 )
 
@@ -21,38 +25,197 @@ type reply struct {
 	tsigStatus     error
 	rtt            time.Duration
 	t              time.Time
+	deadline       time.Time // if not zero, the read deadline instead of one per read
 }
 
 // A Client defines parameter for a DNS client. A nil
 // Client is usable for sending queries.
 type Client struct {
-	Net          string            // if "tcp" a TCP query will be initiated, otherwise an UDP one (default is "" for UDP)
-	Retry        bool              // retry with TCP
+	Net          string            // if "tcp" a TCP query will be initiated, "tcp-tls" uses DNS over TLS, "quic" DNS over QUIC, otherwise an UDP one (default is "" for UDP)
+	Retry        bool              // retry with TCP when the UDP reply is truncated
 	ReadTimeout  time.Duration     // the net.Conn.SetReadTimeout value for new connections (ns), defaults to 2 * 1e9
 	WriteTimeout time.Duration     // the net.Conn.SetWriteTimeout value for new connections (ns), defaults to 2 * 1e9
 	TsigSecret   map[string]string // secret(s) for Tsig map[<zonename>]<base64 secret>, zonename must be fully qualified
+	MaxUDPSize   uint16            // the largest EDNS0 buffer size advertised over UDP, defaults to FlagDayMsgSize
+	PMTUDisc     int               // path MTU discovery setting for UDP sockets: PMTUDiscDefault, PMTUDiscOmit or PMTUDiscDo
+	TLSConfig    *tls.Config       // TLS configuration for "tcp-tls" and "quic", if nil the default configuration is used
+	LocalAddr    net.IP            // if not nil, the source address of the queries, for UDP and TCP
+	LocalZone    string            // the IPv6 zone (interface) of LocalAddr, needed for a link-local address such as fe80::1
+	LocalPort    int               // if not zero, the source port of the queries, or the first port of the range
+	PortRange    int               // if larger than 1, a random source port from LocalPort up to LocalPort+PortRange-1 is used
+	Dialer       *net.Dialer       // if not nil, used to set up the connections; LocalAddr, LocalPort and PortRange are then ignored
+	DialContext  DialContextFunc   // if not nil, used to set up TCP connections (also for "tcp-tls"), e.g. SOCKS5Proxy or HTTPProxy
+	Conns        *ConnPool         // if not nil, UDP and DoQ queries reuse the sockets and connections in this pool
+	Validate     bool              // if true, Exchange validates the DNSSEC signatures of the reply, see ExchangeValidate
+	TrustAnchors []RR              // the DS or DNSKEY records the validation starts from, defaults to RootTrustAnchors
+	XfrFilter    func(rr RR) RR    // if not nil, applied to the RRs of incoming transfers, see TransferIn
+	Family       int               // FamilyIPv4 or FamilyIPv6 to only use that address family, default FamilyAny
+
+	ValidateResponse ResponseCheck // if not nil, checks the replies of Exchange, see Exchange
 }
 
+// The address families of Client.Family.
+const (
+	FamilyAny  = iota // IPv4 or IPv6, whatever the address is
+	FamilyIPv4        // IPv4 only
+	FamilyIPv6        // IPv6 only
+)
+
+// The number of source ports that are tried when a port from the range is in use.
+const portRetries = 5
+
 // Exchange performs an synchronous query. It sends the message m to the address
 // contained in a and waits for an reply. Basic use pattern with a *dns.Client:
 //
 //	c := new(dns.Client)
 //	in, rtt, err := c.Exchange(message, "127.0.0.1:53")
 // 
+//
+// A link-local IPv6 address needs its zone, the interface, in a as well:
+// "[fe80::1%eth0]:53".
+//
+// Over UDP, replies with another id or question than m are ignored and the
+// real reply is waited for, as they may be spoofed (RFC 5452, section 9.1).
+//
+// When the reply is truncated and c.Retry is set, the query is repeated
+// over TCP. If that fails the truncated reply is returned, with an error
+// that matches ErrTruncated.
+//
+// When c.Validate is set the reply is validated with ExchangeValidate, a
+// bogus reply is returned with ErrBogus.
+//
+// When c.ValidateResponse is set it's called with m and the reply before
+// Exchange returns. When it returns an error the reply is returned with an
+// error that matches ErrRejected and unwraps to that error. A truncated
+// reply over UDP is not checked, the reply of the retry over TCP is.
+//
+// When c.Family is FamilyIPv4 or FamilyIPv6, or c.Net is "udp4", "tcp6",
+// etc., only that address family is used: a host name in a is resolved to
+// addresses of that family only, and an address of the other family is an
+// error.
 func (c *Client) Exchange(m *Msg, a string) (r *Msg, rtt time.Duration, err error) {
+	if c.Validate {
+		var sec Security
+		r, sec, rtt, err = c.ExchangeValidate(m, a)
+		if err == nil && sec == Bogus {
+			err = ErrBogus
+		}
+	} else {
+		r, rtt, err = c.exchangeRetry(m, a)
+	}
+	if err != nil || c.ValidateResponse == nil {
+		return
+	}
+	switch c.Net {
+	case "", "udp", "udp4", "udp6":
+		if r.Truncated {
+			// To be retried over TCP, that reply is checked
+			return
+		}
+	}
+	if err = c.ValidateResponse(m, r); err != nil {
+		err = wrapError(ErrRejected, err)
+	}
+	return
+}
+
+// exchangeRetry exchanges m with a, and retries over TCP when the reply is
+// truncated and c.Retry is set.
+func (c *Client) exchangeRetry(m *Msg, a string) (r *Msg, rtt time.Duration, err error) {
+	r, rtt, err = c.exchange(m, a)
+	if err != nil || !r.Truncated || !c.Retry {
+		return
+	}
+	switch c.Net {
+	case "", "udp", "udp4", "udp6":
+		tcp := *c
+		tcp.Net = "tcp" + strings.TrimPrefix(c.Net, "udp")
+		r1, rtt1, err1 := tcp.exchange(m, a)
+		if err1 != nil {
+			return r, rtt, wrapError(ErrTruncated, err1)
+		}
+		return r1, rtt1, nil
+	}
+	return
+}
+
+// ExchangeFamily is like Exchange, but only uses the address family
+// family, FamilyIPv4 or FamilyIPv6, regardless of c.Family.
+func (c *Client) ExchangeFamily(m *Msg, a string, family int) (r *Msg, rtt time.Duration, err error) {
+	c1 := *c
+	c1.Family = family
+	return c1.Exchange(m, a)
+}
+
+func (c *Client) exchange(m *Msg, a string) (r *Msg, rtt time.Duration, err error) {
+	if c.Net == "quic" {
+		return c.exchangeQUIC(m, a)
+	}
+	if c.Conns != nil {
+		switch c.Net {
+		case "", "udp", "udp4", "udp6":
+			return c.exchangeConn(m, a)
+		}
+	}
 	w := new(reply)
 	w.client = c
 	w.addr = a
 	if err = w.dial(); err != nil {
-		return nil, 0, err
+		return nil, 0, netError(err)
 	}
 	if err = w.send(m); err != nil {
-		return nil, 0, err
+		return nil, 0, netError(err)
+	}
+	switch c.Net {
+	case "", "udp", "udp4", "udp6":
+		r, err = w.receiveReply(m)
+	default:
+		r, err = w.receive()
 	}
-	r, err = w.receive()
 	return r, w.rtt, err
 }
 
+// isReply returns true when r has the id and the question of the query q.
+// Only a FORMERR may come without the question, the server may not have
+// been able to parse it.
+func isReply(q, r *Msg) bool {
+	if r.Id != q.Id {
+		return false
+	}
+	if len(r.Question) == 0 {
+		return len(q.Question) == 0 || r.Rcode == RcodeFormatError
+	}
+	return len(q.Question) > 0 && len(r.Question) <= len(q.Question) && r.Question[0].Equal(&q.Question[0])
+}
+
+// exchangeConn sends m over the socket for a from c.Conns.
+func (c *Client) exchangeConn(m *Msg, a string) (r *Msg, rtt time.Duration, err error) {
+	conn, err := c.Conns.get(c, a)
+	if err != nil {
+		return nil, 0, netError(err)
+	}
+	defer c.Conns.put(conn)
+	max := c.MaxUDPSize
+	if max == 0 {
+		max = FlagDayMsgSize
+	}
+	if opt := m.IsEdns0(); opt != nil && opt.UDPSize() > max {
+		opt.SetUDPSize(max)
+	}
+	t := time.Now()
+	r, err = conn.exchange(m, c.ReadTimeout, c.TsigSecret)
+	return r, time.Since(t), err
+}
+
+// netError returns err as an *Error matching ErrTimeout when it's a
+// network time out, otherwise err is returned.
+func netError(err error) error {
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return &Error{Err: err.Error(), Timeout: true, cause: err}
+	}
+	return err
+}
+
 func (w *reply) RemoteAddr() net.Addr {
 	if w.conn != nil {
 		return w.conn.RemoteAddr()
@@ -63,23 +226,150 @@ func (w *reply) RemoteAddr() net.Addr {
 // dial connects to the address addr for the network set in c.Net
 func (w *reply) dial() (err error) {
 	var conn net.Conn
-	if w.client.Net == "" {
-		conn, err = net.DialTimeout("udp", w.addr, 5*1e9)
-	} else {
-		conn, err = net.DialTimeout(w.client.Net, w.addr, 5*1e9)
+	network := w.client.network()
+	if w.client.DialContext != nil && network != "udp" && network != "udp4" && network != "udp6" {
+		return w.dialContext(network)
+	}
+	for i := 0; i < portRetries; i++ {
+		d := w.client.dialer(network)
+		if w.client.Net == "tcp-tls" {
+			conn, err = tls.DialWithDialer(d, network, w.addr, w.client.TLSConfig)
+		} else {
+			conn, err = d.Dial(network, w.addr)
+		}
+		if err == nil || w.client.Dialer != nil || w.client.PortRange <= 1 {
+			break
+		}
 	}
 	if err != nil {
 		return err
 	}
+	if u, ok := conn.(*net.UDPConn); ok {
+		if err = setPMTUDisc(u, w.client.PMTUDisc); err != nil {
+			conn.Close()
+			return err
+		}
+	}
 	w.conn = conn
 	return
 }
 
+// dialContext connects with c.DialContext, and sets up TLS on top of the
+// connection for "tcp-tls".
+func (w *reply) dialContext(network string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*1e9)
+	defer cancel()
+	conn, err := w.client.DialContext(ctx, network, w.addr)
+	if err != nil {
+		return err
+	}
+	if w.client.Net == "tcp-tls" {
+		config := w.client.TLSConfig
+		if config == nil {
+			config = new(tls.Config)
+		}
+		if config.ServerName == "" {
+			config = config.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(w.addr)
+			if i := strings.LastIndex(config.ServerName, "%"); i >= 0 {
+				config.ServerName = config.ServerName[:i] // the zone of a link-local address
+			}
+		}
+		t := tls.Client(conn, config)
+		t.SetDeadline(time.Now().Add(5 * 1e9))
+		if err := t.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		t.SetDeadline(time.Time{})
+		conn = t
+	}
+	w.conn = conn
+	return nil
+}
+
+// network returns the network to dial for c.Net, with the address family
+// of c.Family: "udp", "udp4", "tcp6", etc.
+func (c *Client) network() string {
+	network := c.Net
+	switch network {
+	case "":
+		network = "udp"
+	case "tcp-tls":
+		network = "tcp"
+	}
+	switch {
+	case network != "udp" && network != "tcp":
+	case c.Family == FamilyIPv4:
+		network += "4"
+	case c.Family == FamilyIPv6:
+		network += "6"
+	}
+	return network
+}
+
+// dialer returns the net.Dialer for network, with the source address and
+// port set as configured in c.
+func (c *Client) dialer(network string) *net.Dialer {
+	if c.Dialer != nil {
+		return c.Dialer
+	}
+	d := &net.Dialer{Timeout: 5 * 1e9}
+	if c.LocalAddr == nil && c.LocalPort == 0 {
+		return d
+	}
+	port := c.LocalPort
+	if c.PortRange > 1 {
+		port += rand.Intn(c.PortRange)
+	}
+	switch network {
+	case "udp", "udp4", "udp6":
+		d.LocalAddr = &net.UDPAddr{IP: c.LocalAddr, Port: port, Zone: c.LocalZone}
+	default:
+		d.LocalAddr = &net.TCPAddr{IP: c.LocalAddr, Port: port, Zone: c.LocalZone}
+	}
+	return d
+}
+
 func (w *reply) receive() (*Msg, error) {
-	var p []byte
+	p, err := w.readMsg()
+	if err != nil {
+		return nil, err
+	}
 	m := new(Msg)
+	if err := m.Unpack(p); err != nil {
+		return nil, err
+	}
+	return w.verify(m, p)
+}
+
+// receiveReply receives the reply to q over UDP. Packets that can't be
+// unpacked or that are not the reply, see isReply, are skipped. They don't
+// extend the read timeout, which starts when receiveReply is called.
+func (w *reply) receiveReply(q *Msg) (*Msg, error) {
+	timeout := w.client.ReadTimeout
+	if timeout == 0 {
+		timeout = 2 * 1e9
+	}
+	w.deadline = time.Now().Add(timeout)
+	for {
+		p, err := w.readMsg()
+		if err != nil {
+			return nil, err
+		}
+		m := new(Msg)
+		if m.Unpack(p) != nil || !isReply(q, m) {
+			continue
+		}
+		return w.verify(m, p)
+	}
+}
+
+// readMsg reads a message from the connection.
+func (w *reply) readMsg() ([]byte, error) {
+	var p []byte
 	switch w.client.Net {
-	case "tcp", "tcp4", "tcp6":
+	case "tcp", "tcp4", "tcp6", "tcp-tls":
 		p = make([]byte, MaxMsgSize)
 	case "", "udp", "udp4", "udp6":
 		// OPT! TODO(mg)
@@ -87,18 +377,20 @@ func (w *reply) receive() (*Msg, error) {
 	}
 	n, err := w.read(p)
 	if err != nil && n == 0 {
-		return nil, err
-	}
-	p = p[:n]
-	if err := m.Unpack(p); err != nil {
-		return nil, err
+		return nil, netError(err)
 	}
+	return p[:n], nil
+}
+
+// verify sets the round trip time and checks the TSIG of m, which was
+// unpacked from p.
+func (w *reply) verify(m *Msg, p []byte) (*Msg, error) {
 	w.rtt = time.Since(w.t)
 	if t := m.IsTsig(); t != nil {
 		secret := t.Hdr.Name
 		if _, ok := w.client.TsigSecret[secret]; !ok {
-			w.tsigStatus = ErrSecret
-			return m, ErrSecret
+			w.tsigStatus = wrapError(ErrTsig, ErrSecret)
+			return m, w.tsigStatus
 		}
 		// Need to work on the original message p, as that was used to calculate the tsig.
 		w.tsigStatus = TsigVerify(p, w.client.TsigSecret[secret], w.tsigRequestMAC, w.tsigTimersOnly)
@@ -114,9 +406,9 @@ func (w *reply) read(p []byte) (n int, err error) {
 		return 0, io.ErrShortBuffer
 	}
 	switch w.client.Net {
-	case "tcp", "tcp4", "tcp6":
+	case "tcp", "tcp4", "tcp6", "tcp-tls":
 		setTimeouts(w)
-		n, err = w.conn.(*net.TCPConn).Read(p[0:2])
+		n, err = w.conn.Read(p[0:2])
 		if err != nil || n != 2 {
 			return n, err
 		}
@@ -127,13 +419,13 @@ func (w *reply) read(p []byte) (n int, err error) {
 		if int(l) > len(p) {
 			return int(l), io.ErrShortBuffer
 		}
-		n, err = w.conn.(*net.TCPConn).Read(p[:l])
+		n, err = w.conn.Read(p[:l])
 		if err != nil {
 			return n, err
 		}
 		i := n
 		for i < int(l) {
-			j, err := w.conn.(*net.TCPConn).Read(p[i:int(l)])
+			j, err := w.conn.Read(p[i:int(l)])
 			if err != nil {
 				return i, err
 			}
@@ -155,11 +447,21 @@ func (w *reply) read(p []byte) (n int, err error) {
 // signature is calculated.
 func (w *reply) send(m *Msg) (err error) {
 	var out []byte
+	switch w.client.Net {
+	case "", "udp", "udp4", "udp6":
+		max := w.client.MaxUDPSize
+		if max == 0 {
+			max = FlagDayMsgSize
+		}
+		if opt := m.IsEdns0(); opt != nil && opt.UDPSize() > max {
+			opt.SetUDPSize(max)
+		}
+	}
 	if t := m.IsTsig(); t != nil {
 		mac := ""
 		name := t.Hdr.Name
 		if _, ok := w.client.TsigSecret[name]; !ok {
-			return ErrSecret
+			return wrapError(ErrTsig, ErrSecret)
 		}
 		out, mac, err = TsigGenerate(m, w.client.TsigSecret[name], w.tsigRequestMAC, w.tsigTimersOnly)
 		w.tsigRequestMAC = mac
@@ -178,7 +480,7 @@ func (w *reply) send(m *Msg) (err error) {
 
 func (w *reply) write(p []byte) (n int, err error) {
 	switch w.client.Net {
-	case "tcp", "tcp4", "tcp6":
+	case "tcp", "tcp4", "tcp6", "tcp-tls":
 		if len(p) < 2 {
 			return 0, io.ErrShortBuffer
 		}
@@ -210,7 +512,9 @@ func (w *reply) write(p []byte) (n int, err error) {
 }
 
 func setTimeouts(w *reply) {
-	if w.client.ReadTimeout == 0 {
+	if !w.deadline.IsZero() {
+		w.conn.SetReadDeadline(w.deadline)
+	} else if w.client.ReadTimeout == 0 {
 		w.conn.SetReadDeadline(time.Now().Add(2 * 1e9))
 	} else {
 		w.conn.SetReadDeadline(time.Now().Add(w.client.ReadTimeout))
@@ -1,6 +1,12 @@
 package dns
 
 import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -121,3 +127,443 @@ func TestUpdateLeaseTSIG(t *testing.T) {
 	}
 
 }
+
+// socks5Server accepts one connection, does the SOCKS5 handshake, sends the
+// requested address on target and answers the DNS query itself.
+func socks5Server(l net.Listener, target chan string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+	buf = make([]byte, 10) // ipv4 address only
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	port, _ := unpackUint16(buf, 8)
+	target <- (&net.TCPAddr{IP: net.IP(buf[4:8]), Port: int(port)}).String()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	l2 := make([]byte, 2)
+	if _, err := io.ReadFull(conn, l2); err != nil {
+		return
+	}
+	length, _ := unpackUint16(l2, 0)
+	buf = make([]byte, length)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	req := new(Msg)
+	req.Unpack(buf)
+	m := new(Msg)
+	m.SetReply(req)
+	out, _ := m.Pack()
+	l2[0], l2[1] = packUint16(uint16(len(out)))
+	conn.Write(append(l2, out...))
+}
+
+func TestClientSOCKS5Proxy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	target := make(chan string, 1)
+	go socks5Server(l, target)
+
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeSOA)
+	c := &Client{Net: "tcp", DialContext: SOCKS5Proxy(l.Addr().String(), "", "")}
+	r, _, err := c.Exchange(m, "192.0.2.1:53")
+	if err != nil {
+		t.Fatalf("failed to exchange through the proxy: %s", err.Error())
+	}
+	if r.Id != m.Id {
+		t.Errorf("reply id %d does not match %d", r.Id, m.Id)
+	}
+	if a := <-target; a != "192.0.2.1:53" {
+		t.Errorf("proxy connected to %s, expected 192.0.2.1:53", a)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, a, err := l.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := new(Msg)
+			req.Unpack(buf[:n])
+			if req.Question[0].Name == "timeout.miek.nl." {
+				continue
+			}
+			m := new(Msg)
+			m.SetReply(req)
+			m.Truncated = true
+			out, _ := m.Pack()
+			l.WriteTo(out, a)
+		}
+	}()
+
+	m := new(Msg)
+	m.SetQuestion("timeout.miek.nl.", TypeA)
+	c := &Client{ReadTimeout: 50 * time.Millisecond}
+	_, _, err = c.Exchange(m, l.LocalAddr().String())
+	var ne net.Error
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &ne) || errors.Is(err, ErrTruncated) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	// Nothing listens on TCP, so the retry fails
+	m.SetQuestion("miek.nl.", TypeA)
+	c.Retry = true
+	r, _, err := c.Exchange(m, l.LocalAddr().String())
+	if !errors.Is(err, ErrTruncated) || r == nil || !r.Truncated {
+		t.Errorf("expected a truncated reply, got %v", err)
+	}
+
+	m.SetTsig("axfr.", HmacMD5, 300, time.Now().Unix())
+	buf, _, _ := TsigGenerate(m, "pRZgBrBvI4NAHZYhxmhs/Q==", "", false)
+	err = TsigVerify(buf, "so6ZGir4GPAqINNh9U5c3A==", "", false)
+	if !errors.Is(err, ErrTsig) || !errors.Is(err, ErrSig) || errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a bad TSIG signature, got %v", err)
+	}
+	if _, err := NewRR("miek.nl. IN A 192.0.2"); !errors.Is(err, ErrParse) {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+func TestClientSkip(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, a, err := l.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := new(Msg)
+			req.Unpack(buf[:n])
+			write := func(m *Msg) {
+				out, _ := m.Pack()
+				l.WriteTo(out, a)
+			}
+			m := new(Msg)
+			switch req.Question[0].Name {
+			case "flood.miek.nl.":
+				// Never the reply, but a packet with another id every 20ms
+				go func() {
+					m.SetReply(req)
+					m.Id++
+					for i := 0; i < 100; i++ {
+						write(m)
+						time.Sleep(20 * time.Millisecond)
+					}
+				}()
+			case "formerr.miek.nl.":
+				m.SetRcode(req, RcodeFormatError)
+				m.Question = nil
+				write(m)
+			default:
+				l.WriteTo([]byte{0xff, 0xff, 0xff}, a)
+				m.SetRcode(req, RcodeServerFailure)
+				m.Question = nil
+				write(m)
+				m.SetReply(req)
+				m.Question[0].Name = "www.miek.nl."
+				write(m)
+				m.SetReply(req)
+				m.Answer = []RR{&A{Hdr: RR_Header{req.Question[0].Name, TypeA, ClassINET, 3600, 0}, A: net.IPv4(192, 0, 2, 1)}}
+				write(m)
+			}
+		}
+	}()
+
+	c := &Client{ReadTimeout: 200 * time.Millisecond}
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeA)
+	r, _, err := c.Exchange(m, l.LocalAddr().String())
+	if err != nil || r.Rcode != RcodeSuccess || len(r.Answer) != 1 {
+		t.Errorf("expected the reply after the other packets, got %v, %v", r, err)
+	}
+	conn, err := DialConn("udp", l.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	defer conn.Close()
+	conn.ReadTimeout = 200 * time.Millisecond
+	if r, err := conn.Exchange(m); err != nil || r.Rcode != RcodeSuccess || len(r.Answer) != 1 {
+		t.Errorf("expected the reply on the connection after the other packets, got %v, %v", r, err)
+	}
+
+	m.SetQuestion("formerr.miek.nl.", TypeA)
+	if r, _, err := c.Exchange(m, l.LocalAddr().String()); err != nil || r.Rcode != RcodeFormatError {
+		t.Errorf("expected a FORMERR without question, got %v, %v", r, err)
+	}
+
+	m.SetQuestion("flood.miek.nl.", TypeA)
+	start := time.Now()
+	if _, _, err := c.Exchange(m, l.LocalAddr().String()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("other packets extended the timeout to %s", d)
+	}
+}
+
+func TestTransferZone(t *testing.T) {
+	server := func(h HandlerFunc) string {
+		l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		go (&Server{Handler: h}).serveTCP(l)
+		return l.Addr().String()
+	}
+	z := NewZone("example.org.")
+	for _, s := range []string{"example.org. 3600 SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300",
+		"example.org. 3600 NS ns.example.org.", "www.example.org. 300 A 192.0.2.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	// The first master loses the connection after the first message
+	broken := server(func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, z.Apex().RR[TypeSOA][0], z.Apex().RR[TypeNS][0])
+		w.WriteMsg(m)
+		w.Close()
+	})
+	good := server(func(w ResponseWriter, req *Msg) { z.TransferOut(w, req) })
+
+	q := new(Msg)
+	q.SetAxfr("example.org.")
+	c := &Client{Net: "tcp"}
+	tr, err := c.TransferIn(q, broken)
+	if err != nil {
+		t.Fatalf("failed to start transfer: %s", err.Error())
+	}
+	var last error
+	for e := range tr {
+		last = e.Error
+	}
+	if !errors.Is(last, ErrIncomplete) {
+		t.Errorf("expected ErrIncomplete, got %v", last)
+	}
+
+	rrs, used, err := c.TransferZone(q, broken, good)
+	if err != nil {
+		t.Fatalf("failed to transfer zone: %s", err.Error())
+	}
+	if used != good || len(rrs) != 3 || rrs[0].Header().Rrtype != TypeSOA {
+		t.Errorf("expected the 3 RRs of the zone from %s, got %d from %s", good, len(rrs), used)
+	}
+	if _, _, err := c.TransferZone(q, broken); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("expected ErrIncomplete, got %v", err)
+	}
+}
+
+func TestXfrFilter(t *testing.T) {
+	z := NewZone("example.org.")
+	for _, s := range []string{"example.org. 3600 SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300",
+		"example.org. 86400 NS ns.example.org.", "www.example.org. 300 A 192.0.2.1",
+		"example.org. 3600 DNSKEY 256 3 8 AwEAAcNEU67LJI5GEgF9QLNqLO1SMq1EdoQ6E9f85ha0k0ewQGCblyW2836GiVsm6k8Kr5ECIoMJ6fZWf3CQSQ9ycWfTyOHfmI3eQ/1Covhb2y4bAmL/07PhrL7ozWBW3wBfM335Ft9xjtXHPy7ztCbV9qZ4TVDTW/Iyg0PiwgoXVesz"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	go (&Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) { z.TransferOut(w, req) })}).serveTCP(l)
+
+	q := new(Msg)
+	q.SetAxfr("example.org.")
+	clamp := MaxTTL(600)
+	c := &Client{Net: "tcp", XfrFilter: func(rr RR) RR {
+		if rr = DropDNSSEC(rr); rr != nil {
+			rr = clamp(rr)
+		}
+		return rr
+	}}
+	rrs, _, err := c.TransferZone(q, l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to transfer zone: %s", err.Error())
+	}
+	if len(rrs) != 3 {
+		t.Fatalf("expected 3 RRs without the DNSKEY, got %v", rrs)
+	}
+	for _, rr := range rrs {
+		switch rr.Header().Rrtype {
+		case TypeSOA:
+			if rr.Header().Ttl != 3600 {
+				t.Errorf("SOA record is filtered: %s", rr)
+			}
+		case TypeNS:
+			if rr.Header().Ttl != 600 {
+				t.Errorf("expected the TTL to be clamped: %s", rr)
+			}
+		}
+	}
+	if z.Apex().RR[TypeNS][0].Header().Ttl != 86400 {
+		t.Error("the filter changed the RR in the zone")
+	}
+}
+
+func TestIPv6Zone(t *testing.T) {
+	if arpa, err := ReverseAddr("fe80::1%eth0"); err != nil || !strings.HasSuffix(arpa, ".0.8.e.f.ip6.arpa.") {
+		t.Errorf("bad reverse of a link-local address: %s %v", arpa, err)
+	}
+	for _, s := range []string{"fe80::1%", "127.0.0.1%eth0"} {
+		if ip, _ := parseIPZone(s); ip != nil {
+			t.Errorf("expected %s to be invalid", s)
+		}
+	}
+	e, err := NewEDNS0Subnet("[fe80::1234:5678%eth0]:53", 24, 56)
+	if err != nil || e.Family != 2 || e.SourceNetmask != 56 || !e.Address.Equal(net.ParseIP("fe80::")) {
+		t.Errorf("bad client subnet option %v: %v", e, err)
+	}
+
+	// Query a server on a link-local address, if this host has one
+	var addr *net.UDPAddr
+	ifs, _ := net.Interfaces()
+	for _, i := range ifs {
+		as, _ := i.Addrs()
+		for _, a := range as {
+			if n, ok := a.(*net.IPNet); ok && n.IP.To4() == nil && n.IP.IsLinkLocalUnicast() {
+				addr = &net.UDPAddr{IP: n.IP, Zone: i.Name}
+			}
+		}
+	}
+	if addr == nil {
+		t.Skip("no link-local IPv6 address")
+	}
+	l, err := net.ListenUDP("udp6", addr)
+	if err != nil {
+		t.Skipf("failed to listen on %s: %s", addr, err.Error())
+	}
+	defer l.Close()
+	go (&Server{Handler: HandlerFunc(HelloServer)}).serveUDP(l)
+	c := &Client{LocalAddr: addr.IP, LocalZone: addr.Zone}
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeTXT)
+	if _, _, err := c.Exchange(m, l.LocalAddr().String()); err != nil {
+		t.Errorf("failed to query %s: %s", l.LocalAddr(), err.Error())
+	}
+}
+
+func TestClientFamily(t *testing.T) {
+	l, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go (&Server{Handler: HandlerFunc(HelloServer)}).serveUDP(l)
+	_, port, _ := net.SplitHostPort(l.LocalAddr().String())
+
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeSOA)
+	c := &Client{Family: FamilyIPv4}
+	if _, _, err := c.Exchange(m, net.JoinHostPort("localhost", port)); err != nil {
+		t.Errorf("failed to exchange over IPv4: %s", err.Error())
+	}
+	if _, _, err := c.ExchangeFamily(m, l.LocalAddr().String(), FamilyIPv6); err == nil {
+		t.Error("expected an error sending to an IPv4 address over IPv6")
+	}
+	c.Family = FamilyIPv6
+	if _, _, err := c.Exchange(m, l.LocalAddr().String()); err == nil {
+		t.Error("expected an error sending to an IPv4 address over IPv6")
+	}
+	if n := (&Client{Net: "tcp-tls", Family: FamilyIPv6}).network(); n != "tcp6" {
+		t.Errorf("expected network tcp6, got %s", n)
+	}
+}
+
+func TestClientSource(t *testing.T) {
+	// The whole of 127.0.0.0/8 is on the loopback interface on Linux, not
+	// everywhere
+	if l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2)}); err != nil {
+		t.Skip("127.0.0.2 is not available")
+	} else {
+		l.Close()
+	}
+	from := make(chan net.Addr, 1)
+	h := HandlerFunc(func(w ResponseWriter, req *Msg) {
+		from <- w.RemoteAddr()
+		m := new(Msg)
+		m.SetReply(req)
+		w.WriteMsg(m)
+	})
+	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer u.Close()
+	go (&Server{Handler: h}).serveUDP(u)
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go (&Server{Handler: h}).serveTCP(l)
+
+	source := func(c *Client, addr string) (net.IP, int) {
+		m := new(Msg)
+		m.SetQuestion("miek.nl.", TypeSOA)
+		if _, _, err := c.Exchange(m, addr); err != nil {
+			t.Fatalf("failed to exchange over %s: %s", c.Net, err.Error())
+		}
+		host, port, _ := net.SplitHostPort((<-from).String())
+		p, _ := strconv.Atoi(port)
+		return net.ParseIP(host), p
+	}
+	base := 20000 + rand.Intn(20000)
+	for _, c := range []*Client{{Net: "udp"}, {Net: "tcp"}} {
+		addr := u.LocalAddr().String()
+		if c.Net == "tcp" {
+			addr = l.Addr().String()
+		}
+		c.LocalAddr = net.IPv4(127, 0, 0, 2)
+		c.LocalPort = base
+		c.PortRange = 100
+		ports := make(map[int]bool)
+		for i := 0; i < 10; i++ {
+			ip, port := source(c, addr)
+			if !ip.Equal(c.LocalAddr) {
+				t.Errorf("%s: expected source address %s, got %s", c.Net, c.LocalAddr, ip)
+			}
+			if port < base || port >= base+c.PortRange {
+				t.Errorf("%s: source port %d not in %d-%d", c.Net, port, base, base+c.PortRange-1)
+			}
+			ports[port] = true
+		}
+		if len(ports) < 2 {
+			t.Errorf("%s: expected random source ports, got %v", c.Net, ports)
+		}
+
+		// The Dialer wins
+		c.Dialer = &net.Dialer{LocalAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 3)}}
+		if c.Net == "tcp" {
+			c.Dialer.LocalAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 3)}
+		}
+		if ip, port := source(c, addr); !ip.Equal(net.IPv4(127, 0, 0, 3)) || port >= base && port < base+c.PortRange {
+			t.Errorf("%s: expected the source of the Dialer, got %s port %d", c.Net, ip, port)
+		}
+		base += c.PortRange
+	}
+}
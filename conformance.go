@@ -0,0 +1,284 @@
+// CONFORMANCE CHECKING
+//
+// A Checker verifies that the replies of an authoritative server follow the
+// RFCs, given the zone the replies are generated from. It checks the header
+// (QR and AA bits, id, question), positive answers, negative answers (RFC 2308:
+// the SOA and its TTL), referrals (RFC 1034: no AA bit, the NS RRset of the
+// delegation and the glue) and, when the query has the DO bit set and the zone
+// is signed with NSEC, the signatures and the denial of existence (RFC 4035).
+// Names that may be synthesized from a wildcard are only checked for the
+// header. The Checker can be used directly in tests, or as middleware in a
+// staging setup:
+//
+//	c := &dns.Checker{Zone: z, Report: func(q, m *dns.Msg, problems []error) {
+//		log.Printf("%s: %v", q.Question[0].Name, problems)
+//	}}
+//	dns.Handle("example.org.", c.Wrap(handler))
+package dns
+
+import (
+	"strings"
+)
+
+// Checker checks replies against the zone Zone.
+type Checker struct {
+	Zone   *Zone
+	Report func(q, m *Msg, problems []error) // Called by the wrapped handler when a reply has problems
+}
+
+// Check checks the reply m to the query q and returns the problems found, or
+// nil when there are none. Queries for names outside of the zone are only
+// checked for the header.
+func (c *Checker) Check(q, m *Msg) []error {
+	var problems []error
+	name := ""
+	if len(q.Question) > 0 {
+		name = q.Question[0].Name
+	}
+	bad := func(s string) { problems = append(problems, &Error{Err: s, Name: name}) }
+	if !m.Response {
+		bad("QR bit not set")
+	}
+	if m.Id != q.Id {
+		bad("id does not match the query")
+	}
+	if len(q.Question) != 1 {
+		return problems
+	}
+	if len(m.Question) != 1 || m.Question[0] != q.Question[0] {
+		bad("question not copied from the query")
+	}
+	if q.IsEdns0() != nil && m.IsEdns0() == nil && m.Rcode != RcodeFormatError {
+		bad("no OPT record in the reply to an EDNS query")
+	}
+	z := c.Zone
+	if !z.isSubDomain(name) || m.Rcode == RcodeServerFailure || m.Rcode == RcodeRefused {
+		return problems
+	}
+	qtype := q.Question[0].Qtype
+	apex, exact := z.lookup(z.Origin)
+	if !exact {
+		bad("zone has no apex")
+		return problems
+	}
+	apex.RLock()
+	soas := apex.RR[TypeSOA]
+	_, nsec := apex.RR[TypeNSEC]
+	apex.RUnlock()
+	if len(soas) == 0 {
+		bad("zone has no SOA record")
+		return problems
+	}
+	soa := soas[0].(*SOA)
+	dnssec := nsec && q.IsEdns0() != nil && q.IsEdns0().Do()
+
+	if cut := c.cut(name, qtype); cut != nil {
+		if m.Authoritative {
+			bad("AA bit set in a referral")
+		}
+		if m.Rcode != RcodeSuccess {
+			bad("rcode of a referral is not NOERROR")
+		}
+		if len(m.Answer) > 0 {
+			bad("answer section of a referral is not empty")
+		}
+		cut.RLock()
+		defer cut.RUnlock()
+		if !containsRRset(m.Ns, cut.RR[TypeNS]) {
+			bad("NS records of the delegation " + cut.Name + " missing")
+		}
+		for _, r := range cut.RR[TypeNS] {
+			ns := r.(*NS).Ns
+			if !IsSubDomain(cut.Name, ns) {
+				continue
+			}
+			if glue, exact := z.lookup(ns); exact {
+				glue.RLock()
+				if !containsRRset(m.Extra, glue.RR[TypeA]) || !containsRRset(m.Extra, glue.RR[TypeAAAA]) {
+					bad("glue for " + ns + " missing")
+				}
+				glue.RUnlock()
+			}
+		}
+		if dnssec {
+			if ds, ok := cut.RR[TypeDS]; ok {
+				if !containsRRset(m.Ns, ds) || !signed(m.Ns, cut.Name, TypeDS) {
+					bad("signed DS records of the delegation missing")
+				}
+			} else if !denies(m.Ns, cut.Name, TypeDS, true) {
+				bad("NSEC proving there is no DS record missing")
+			}
+		}
+		return problems
+	}
+
+	if !m.Authoritative {
+		bad("AA bit not set")
+	}
+	zd, exact := z.lookup(name)
+	if exact {
+		zd.RLock()
+		defer zd.RUnlock()
+		exact = len(zd.RR) > 0
+	}
+	negative := func(rcode int) {
+		if m.Rcode != rcode {
+			bad("rcode is " + RcodeToString[m.Rcode] + " instead of " + RcodeToString[rcode])
+		}
+		if len(m.Answer) > 0 {
+			bad("answer section of a negative answer is not empty")
+		}
+		ttl := soa.Hdr.Ttl
+		if soa.Minttl < ttl {
+			ttl = soa.Minttl
+		}
+		found := false
+		for _, r := range m.Ns {
+			if s, ok := r.(*SOA); ok && strings.EqualFold(s.Hdr.Name, z.Origin) {
+				found = true
+				if s.Hdr.Ttl != ttl {
+					bad("TTL of the SOA in a negative answer is not the minimum of its TTL and MINIMUM field")
+				}
+			}
+		}
+		if !found {
+			bad("SOA missing in a negative answer")
+		} else if dnssec && !signed(m.Ns, z.Origin, TypeSOA) {
+			bad("signature of the SOA missing")
+		}
+	}
+	switch {
+	case exact && zd.RR[TypeCNAME] != nil && qtype != TypeCNAME:
+		if !containsRRset(m.Answer, zd.RR[TypeCNAME]) {
+			bad("CNAME missing from the answer")
+		}
+		if dnssec && !signed(m.Answer, name, TypeCNAME) {
+			bad("signature of the CNAME missing")
+		}
+	case exact && (zd.RR[qtype] != nil || qtype == TypeANY):
+		if m.Rcode != RcodeSuccess {
+			bad("rcode of an answer is not NOERROR")
+		}
+		if !containsRRset(m.Answer, zd.RR[qtype]) {
+			bad("RRset missing from the answer")
+		}
+		if dnssec && qtype != TypeANY && !signed(m.Answer, name, qtype) {
+			bad("signature of the answer missing")
+		}
+	case exact:
+		negative(RcodeSuccess)
+		if dnssec && !denies(m.Ns, name, qtype, true) {
+			bad("NSEC proving the type does not exist missing")
+		}
+	case z.Wildcard > 0:
+		// The answer may be synthesized from a wildcard, this is not checked
+	case c.nonTerminal(name):
+		negative(RcodeSuccess)
+		if dnssec && !denies(m.Ns, name, qtype, false) {
+			bad("NSEC proving the empty non-terminal has no data missing")
+		}
+	default:
+		negative(RcodeNameError)
+		if dnssec && !denies(m.Ns, name, qtype, false) {
+			bad("NSEC proving the name does not exist missing")
+		}
+	}
+	return problems
+}
+
+// cut returns the highest delegation at or above name, or nil if name is not
+// delegated. DS queries for the delegation itself are answered by the parent.
+func (c *Checker) cut(name string, qtype uint16) *ZoneData {
+	name = Fqdn(name)
+	var cut *ZoneData
+	off := 0
+	for i := LenLabels(name) - len(c.Zone.olabels); i > 0; i-- {
+		if zd, exact := c.Zone.lookup(name[off:]); exact && zd.NonAuth {
+			cut = zd
+		}
+		off, _ = NextLabelOffset(name, off)
+	}
+	if cut != nil && qtype == TypeDS && strings.EqualFold(cut.Name, name) {
+		return nil
+	}
+	return cut
+}
+
+// nonTerminal returns true when name does not exist in the zone, but names
+// below it do.
+func (c *Checker) nonTerminal(name string) bool {
+	found := false
+	c.Zone.RLock()
+	defer c.Zone.RUnlock()
+	c.Zone.Radix.NextDo(func(i interface{}) {
+		if zd := i.(*ZoneData); !found && IsSubDomain(name, zd.Name) && !strings.EqualFold(name, zd.Name) {
+			found = true
+		}
+	})
+	return found
+}
+
+// Wrap returns a Handler that checks all the replies of h and calls
+// c.Report for the replies with problems.
+func (c *Checker) Wrap(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, req *Msg) {
+		h.ServeDNS(&checkWriter{w, c, req}, req)
+	})
+}
+
+type checkWriter struct {
+	ResponseWriter
+	c   *Checker
+	req *Msg
+}
+
+func (w *checkWriter) WriteMsg(m *Msg) error {
+	if problems := w.c.Check(w.req, m); problems != nil && w.c.Report != nil {
+		w.c.Report(w.req, m, problems)
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func (w *checkWriter) RequestInfo() *RequestInfo { return requestInfo(w.ResponseWriter) }
+
+// containsRRset returns true when all the RRs of rrset are in rrs.
+func containsRRset(rrs []RR, rrset []RR) bool {
+Set:
+	for _, r := range rrset {
+		for _, r1 := range rrs {
+			if r1.Header().Rrtype == r.Header().Rrtype && strings.EqualFold(r1.Header().Name, r.Header().Name) && sameRdata(r, r1) {
+				continue Set
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// signed returns true when rrs holds a signature for the RRset name/t.
+func signed(rrs []RR, name string, t uint16) bool {
+	for _, r := range rrs {
+		if s, ok := r.(*RRSIG); ok && s.TypeCovered == t && strings.EqualFold(s.Hdr.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// denies returns true when rrs holds a signed NSEC record that proves that
+// the type t does not exist at name (exact is true), or that covers name.
+func denies(rrs []RR, name string, t uint16, exact bool) bool {
+	for _, r := range rrs {
+		n, ok := r.(*NSEC)
+		if !ok || !signed(rrs, n.Hdr.Name, TypeNSEC) {
+			continue
+		}
+		if exact && n.Match(name) && !n.MatchType(t) {
+			return true
+		}
+		if !exact && n.Cover(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestChecker(t *testing.T) {
+	vectors, err := LoadTestVectors("testdata/vectors")
+	if err != nil {
+		t.Fatalf("failed to load the test vectors: %s", err.Error())
+	}
+	for _, v := range vectors {
+		if v.Zone == nil || v.Reply == nil {
+			continue
+		}
+		c := &Checker{Zone: v.Zone}
+		reply := v.Reply
+		if v.Query.IsEdns0() != nil {
+			reply.SetEdns0(4096, v.Query.IsEdns0().Do())
+		}
+		if problems := c.Check(v.Query, reply); problems != nil {
+			t.Errorf("%s: expected no problems, got %v", v.Name, problems)
+		}
+		// Flipping the AA bit must always be noticed
+		reply.Authoritative = !reply.Authoritative
+		if problems := c.Check(v.Query, reply); len(problems) != 1 {
+			t.Errorf("%s: expected one problem with the AA bit, got %v", v.Name, problems)
+		}
+		reply.Authoritative = !reply.Authoritative
+	}
+
+	v, err := ReadTestVector("testdata/vectors/signed-nxdomain.vec")
+	if err != nil {
+		t.Fatalf("failed to read the test vector: %s", err.Error())
+	}
+	reply := v.Reply
+	reply.SetEdns0(4096, true)
+	reply.Ns = reply.Ns[:2] // only the SOA and its signature
+	c := &Checker{Zone: v.Zone}
+	if problems := c.Check(v.Query, reply); len(problems) != 1 {
+		t.Errorf("expected the missing NSEC records to be noticed, got %v", problems)
+	}
+	reply.Rcode = RcodeSuccess
+	if problems := c.Check(v.Query, reply); len(problems) != 2 {
+		t.Errorf("expected the wrong rcode to be noticed, got %v", problems)
+	}
+}
+
+func TestCheckerNoSOA(t *testing.T) {
+	z := NewZone("example.org.")
+	ns, _ := NewRR("example.org. NS ns.example.org.")
+	z.Insert(ns)
+	q := new(Msg)
+	q.SetQuestion("www.example.org.", TypeA)
+	m := new(Msg)
+	m.SetRcode(q, RcodeNameError)
+	m.Authoritative = true
+	c := &Checker{Zone: z}
+	if problems := c.Check(q, m); len(problems) != 1 || problems[0].(*Error).Err != "zone has no SOA record" {
+		t.Errorf("expected the missing SOA to be reported, got %v", problems)
+	}
+	c.Zone = NewZone("example.org.")
+	if problems := c.Check(q, m); len(problems) != 1 {
+		t.Errorf("expected the missing apex to be reported, got %v", problems)
+	}
+}
@@ -0,0 +1,440 @@
+package dns
+
+// A connection that is shared by multiple queries and transfers.
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Conn is a TCP connection to a name server, which can be used by multiple
+// goroutines to send queries and do zone transfers at the same time,
+// as described in RFC 7766. Replies are matched on the message id, so they
+// may arrive in any order. A Conn can also be a connected UDP socket, that
+// is reused for many queries, see ConnPool. Basic use pattern:
+//
+//	c, err := dns.DialConn("tcp", "127.0.0.1:53")
+//	// in one goroutine
+//	t, err := c.TransferIn(axfr)
+//	for r := range t {
+//		// ... deal with r.RR or r.Error
+//	}
+//	// and in another
+//	in, err := c.Exchange(m)
+//
+// The message id of each request is changed when it collides with an
+// id that is still in use on the connection.
+type Conn struct {
+	ReadTimeout time.Duration     // Timeout for a reply to an Exchange, defaults to 2 * 1e9
+	TsigSecret  map[string]string // secret(s) for Tsig map[<zonename>]<base64 secret>, zonename must be fully qualified
+	conn        net.Conn
+	udp         bool                     // conn is a UDP socket, messages are not prefixed with their length
+	wmu         sync.Mutex               // serializes writes
+	mu          sync.Mutex               // protects pending and err
+	pending     map[uint16]*pendingReply // outstanding requests, keyed on the message id
+	err         error                    // set when reading from the connection failed
+}
+
+type pendingReply struct {
+	raw            chan rawReply     // replies are sent here by the reader
+	done           chan bool         // closed when the request is no longer interested in replies
+	secrets        map[string]string // the TSIG secrets
+	tsigRequestMAC string
+	tsigTimersOnly bool
+}
+
+// rawReply holds a reply and the wire format it was unpacked from, the latter
+// is needed for TSIG verification.
+type rawReply struct {
+	m   *Msg
+	buf []byte
+}
+
+// DialConn connects to the name server on addr, network must be "tcp",
+// "tcp4", "tcp6", "udp", "udp4" or "udp6". Zone transfers are not possible
+// over UDP.
+func DialConn(network, addr string) (*Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+	default:
+		return nil, &Error{Err: "bad network"}
+	}
+	conn, err := net.DialTimeout(network, addr, 5*1e9)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn), nil
+}
+
+// newConn returns a Conn using the connection conn.
+func newConn(conn net.Conn) *Conn {
+	_, udp := conn.(*net.UDPConn)
+	c := &Conn{conn: conn, udp: udp, pending: make(map[uint16]*pendingReply)}
+	go c.reader()
+	return c
+}
+
+// Close closes the connection, outstanding requests get an error.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// reader reads all replies from the connection and hands
+// them to the pending requests. On a UDP socket, an ICMP error (such as
+// port unreachable) also stops the reader.
+func (c *Conn) reader() {
+	l := make([]byte, 2)
+	var (
+		err  error
+		ubuf []byte
+	)
+	if c.udp {
+		ubuf = make([]byte, MaxMsgSize)
+	}
+	for {
+		var buf []byte
+		if c.udp {
+			n, err1 := c.conn.Read(ubuf)
+			if err = err1; err != nil {
+				break
+			}
+			buf = append([]byte(nil), ubuf[:n]...)
+		} else {
+			if _, err = io.ReadFull(c.conn, l); err != nil {
+				break
+			}
+			length, _ := unpackUint16(l, 0)
+			buf = make([]byte, int(length))
+			if _, err = io.ReadFull(c.conn, buf); err != nil {
+				break
+			}
+		}
+		m := new(Msg)
+		if m.Unpack(buf) != nil {
+			continue // ignore garbage, the request will time out
+		}
+		c.mu.Lock()
+		p, ok := c.pending[m.Id]
+		c.mu.Unlock()
+		if ok {
+			select {
+			case p.raw <- rawReply{m, buf}:
+			case <-p.done:
+			}
+		}
+	}
+	c.mu.Lock()
+	c.err = err
+	for id, p := range c.pending {
+		close(p.raw)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// register adds m as a pending request, changing its id when needed.
+func (c *Conn) register(m *Msg, size int) (*pendingReply, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	for _, ok := c.pending[m.Id]; ok; _, ok = c.pending[m.Id] {
+		m.Id = Id()
+		if t := m.IsTsig(); t != nil {
+			t.OrigId = m.Id
+		}
+	}
+	p := &pendingReply{raw: make(chan rawReply, size), done: make(chan bool)}
+	c.pending[m.Id] = p
+	return p, nil
+}
+
+func (c *Conn) unregister(id uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.pending[id]; ok {
+		delete(c.pending, id)
+		close(p.done)
+	}
+}
+
+// send writes m to the connection, if m has a TSIG record the
+// transaction signature is calculated.
+func (c *Conn) send(m *Msg, p *pendingReply) (err error) {
+	var out []byte
+	if t := m.IsTsig(); t != nil {
+		secret, ok := p.secrets[t.Hdr.Name]
+		if !ok {
+			return wrapError(ErrTsig, ErrSecret)
+		}
+		out, p.tsigRequestMAC, err = TsigGenerate(m, secret, "", false)
+	} else {
+		out, err = m.Pack()
+	}
+	if err != nil {
+		return err
+	}
+	if len(out) > MaxMsgSize {
+		return &Error{Err: "message too large"}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.udp {
+		_, err = c.conn.Write(out)
+		return err
+	}
+	l := make([]byte, 2)
+	l[0], l[1] = packUint16(uint16(len(out)))
+	_, err = c.conn.Write(append(l, out...))
+	return err
+}
+
+// verify checks the TSIG of the reply r.
+func (c *Conn) verify(r rawReply, p *pendingReply) error {
+	t := r.m.IsTsig()
+	if t == nil {
+		return nil
+	}
+	secret, ok := p.secrets[t.Hdr.Name]
+	if !ok {
+		return wrapError(ErrTsig, ErrSecret)
+	}
+	err := TsigVerify(r.buf, secret, p.tsigRequestMAC, p.tsigTimersOnly)
+	p.tsigRequestMAC = t.MAC
+	return err
+}
+
+// closed returns the error to use when the reply channel is closed.
+func (c *Conn) closed() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil && c.err != io.EOF {
+		return c.err
+	}
+	return &Error{Err: "connection closed"}
+}
+
+// Exchange sends m on the connection and waits for the reply. Replies with
+// the id of m but not its question are ignored, see Client.Exchange.
+func (c *Conn) Exchange(m *Msg) (*Msg, error) {
+	return c.exchange(m, c.ReadTimeout, c.TsigSecret)
+}
+
+func (c *Conn) exchange(m *Msg, timeout time.Duration, secrets map[string]string) (*Msg, error) {
+	p, err := c.register(m, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer c.unregister(m.Id)
+	p.secrets = secrets
+	if err := c.send(m, p); err != nil {
+		return nil, err
+	}
+	if timeout == 0 {
+		timeout = 2 * 1e9
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case r, ok := <-p.raw:
+			if !ok {
+				return nil, c.closed()
+			}
+			if !isReply(m, r.m) {
+				continue // spoofed, see Client.Exchange
+			}
+			return r.m, c.verify(r, p)
+		case <-deadline.C:
+			return nil, &Error{Err: "timeout", Timeout: true}
+		}
+	}
+}
+
+// TransferIn performs an AXFR or IXFR on the connection, see Client.TransferIn.
+// Unlike Client.TransferIn the connection is not closed when the transfer
+// is done.
+func (c *Conn) TransferIn(q *Msg) (chan *Envelope, error) {
+	if len(q.Question) != 1 || (q.Question[0].Qtype != TypeAXFR && q.Question[0].Qtype != TypeIXFR) {
+		return nil, &Error{Err: "not a transfer request"}
+	}
+	if c.udp {
+		return nil, &Error{Err: "no transfers over UDP"}
+	}
+	p, err := c.register(q, 16)
+	if err != nil {
+		return nil, err
+	}
+	p.secrets = c.TsigSecret
+	if err := c.send(q, p); err != nil {
+		c.unregister(q.Id)
+		return nil, err
+	}
+	e := make(chan *Envelope)
+	go c.xfrIn(q, p, e)
+	return e, nil
+}
+
+func (c *Conn) xfrIn(q *Msg, p *pendingReply, e chan *Envelope) {
+	defer close(e)
+	defer c.unregister(q.Id)
+	var serial uint32
+	first := true
+	for {
+		r, ok := <-p.raw
+		if !ok {
+			err := c.closed()
+			if !first {
+				err = incompleteXfr(q.Question[0].Name, err)
+			}
+			e <- &Envelope{nil, err}
+			return
+		}
+		in := r.m
+		if err := c.verify(r, p); err != nil {
+			e <- &Envelope{in.Answer, err}
+			return
+		}
+		p.tsigTimersOnly = true
+		if in.Rcode != RcodeSuccess {
+			e <- &Envelope{in.Answer, &Error{Err: "transfer failed: " + RcodeToString[in.Rcode], Name: q.Question[0].Name, Rcode: in.Rcode, kind: ErrRcode}}
+			return
+		}
+		if first {
+			if !checkXfrSOA(in, true) {
+				e <- &Envelope{in.Answer, ErrSoa}
+				return
+			}
+			serial = in.Answer[0].(*SOA).Serial
+			first = false
+			// A single SOA RR in an IXFR signals "no changes"
+			if q.Question[0].Qtype == TypeIXFR && len(in.Answer) == 1 {
+				e <- &Envelope{in.Answer, nil}
+				return
+			}
+			if len(in.Answer) == 1 {
+				e <- &Envelope{in.Answer, nil}
+				continue
+			}
+		}
+		e <- &Envelope{in.Answer, nil}
+		if closesXfr(in.Answer, serial) {
+			return
+		}
+	}
+}
+
+// dead returns true when reading from the connection failed.
+func (c *Conn) dead() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
+
+// ConnPool holds a connected UDP socket per name server, which Clients
+// that use the pool (see Client.Conns) reuse for their queries instead of
+// opening a new socket for each one. This saves the setup time and source
+// ports. Replies are matched on the message id. When a socket fails, for
+// instance because an ICMP port unreachable was received, a new one is
+// opened for the next query. It's safe for concurrent use by multiple
+// goroutines.
+//
+// For DoQ ("quic") the pool holds a QUIC connection per name server and TLS
+// configuration, the queries are sent on new streams of it; MaxQueries does
+// not apply, the connection is used until either side closes it.
+//
+// Reusing a socket is a trade-off: all queries on it share the source port,
+// so an attacker spoofing replies (see RFC 5452) only has to guess the
+// message id and not the port as well. That's why a socket is replaced by a
+// new one, with a new source port, after MaxQueries queries; set it lower
+// for more randomness, or don't use a pool at all for queries to servers
+// that are not trusted, such as those of a recursive resolver.
+//
+//	c := &dns.Client{Conns: dns.NewConnPool()}
+//	in, rtt, err := c.Exchange(m, "127.0.0.1:53")
+type ConnPool struct {
+	MaxQueries int                    // queries sent over a socket before it's replaced, defaults to 100
+	conns      map[string]*pooledConn // keyed on network and address
+	quic       map[string]*quicConn   // DoQ connections, keyed on network, address and TLS configuration
+	*sync.Mutex
+}
+
+// pooledConn is a socket in a ConnPool.
+type pooledConn struct {
+	*Conn
+	queries int  // the number of queries sent over the socket
+	active  int  // the number of queries waiting for a reply
+	retired bool // the socket is no longer in the pool, close it when active drops to 0
+}
+
+// NewConnPool returns an empty ConnPool.
+func NewConnPool() *ConnPool {
+	return &ConnPool{conns: make(map[string]*pooledConn), Mutex: new(sync.Mutex)}
+}
+
+// get returns the connection for c to the name server a, dialing one
+// when there is none yet, when the old one failed or when it was used for
+// MaxQueries queries. The connection must be given back with put.
+func (p *ConnPool) get(c *Client, a string) (*pooledConn, error) {
+	key := c.network() + " " + a
+	max := p.MaxQueries
+	if max == 0 {
+		max = 100
+	}
+	p.Lock()
+	defer p.Unlock()
+	if conn, ok := p.conns[key]; ok {
+		if !conn.dead() && conn.queries < max {
+			conn.queries++
+			conn.active++
+			return conn, nil
+		}
+		p.retire(conn)
+		delete(p.conns, key)
+	}
+	w := &reply{client: c, addr: a}
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	conn := &pooledConn{Conn: newConn(w.conn), queries: 1, active: 1}
+	p.conns[key] = conn
+	return conn, nil
+}
+
+// put gives back a connection returned by get, after the query is done.
+func (p *ConnPool) put(conn *pooledConn) {
+	p.Lock()
+	defer p.Unlock()
+	conn.active--
+	if conn.retired && conn.active == 0 {
+		conn.Close()
+	}
+}
+
+// retire removes conn from use, it's closed when no query is waiting for a
+// reply on it anymore.
+func (p *ConnPool) retire(conn *pooledConn) {
+	conn.retired = true
+	if conn.active == 0 {
+		conn.Close()
+	}
+}
+
+// Close closes all connections in the pool. Connections with queries that
+// still wait for a reply are closed when these are done.
+func (p *ConnPool) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	for key, conn := range p.conns {
+		p.retire(conn)
+		delete(p.conns, key)
+	}
+	for key, conn := range p.quic {
+		p.retireQUIC(conn)
+		delete(p.quic, key)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package dns
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// reverseServer reads two queries from each connection and answers them
+// in reverse order.
+func reverseServer(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		var replies [][]byte
+		for i := 0; i < 2; i++ {
+			b := make([]byte, 2)
+			if _, err := io.ReadFull(conn, b); err != nil {
+				break
+			}
+			length, _ := unpackUint16(b, 0)
+			buf := make([]byte, length)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				break
+			}
+			req := new(Msg)
+			req.Unpack(buf)
+			m := new(Msg)
+			m.SetReply(req)
+			txt, _ := NewRR(req.Question[0].Name + " TXT \"" + req.Question[0].Name + "\"")
+			m.Answer = append(m.Answer, txt)
+			out, _ := m.Pack()
+			b[0], b[1] = packUint16(uint16(len(out)))
+			replies = append(replies, append(b, out...))
+		}
+		for i := len(replies) - 1; i >= 0; i-- {
+			conn.Write(replies[i])
+		}
+	}
+}
+
+func TestConnOutOfOrder(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go reverseServer(l)
+
+	c, err := DialConn("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	defer c.Close()
+	done := make(chan bool)
+	for _, name := range []string{"one.example.", "two.example."} {
+		go func(name string) {
+			m := new(Msg)
+			m.SetQuestion(name, TypeTXT)
+			m.Id = 1 // force an id collision
+			r, err := c.Exchange(m)
+			if err != nil {
+				t.Errorf("exchange for %s failed: %s", name, err.Error())
+			} else if r.Answer[0].(*TXT).Txt[0] != name {
+				t.Errorf("reply for %s has the wrong answer: %s", name, r.Answer[0].String())
+			}
+			done <- true
+		}(name)
+	}
+	<-done
+	<-done
+}
+
+func TestConnPool(t *testing.T) {
+	serve := func(addr *net.UDPAddr, ports chan int) *net.UDPConn {
+		l, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		go (&Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) {
+			ports <- w.RemoteAddr().(*net.UDPAddr).Port
+			m := new(Msg)
+			m.SetReply(req)
+			w.WriteMsg(m)
+		})}).serveUDP(l)
+		return l
+	}
+	ports := make(chan int, 10)
+	l := serve(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, ports)
+	addr := l.LocalAddr().(*net.UDPAddr)
+
+	c := &Client{Conns: NewConnPool(), ReadTimeout: 500 * time.Millisecond}
+	defer c.Conns.Close()
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeSOA)
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.Exchange(m, addr.String()); err != nil {
+			t.Fatalf("failed to exchange: %s", err.Error())
+		}
+	}
+	port := <-ports
+	if p1, p2 := <-ports, <-ports; p1 != port || p2 != port {
+		t.Errorf("socket not reused: ports %d, %d and %d", port, p1, p2)
+	}
+
+	// The ICMP port unreachable kills the socket, a new one is used next
+	l.Close()
+	if _, _, err := c.Exchange(m, addr.String()); err == nil {
+		t.Fatal("expected an error without a server")
+	}
+	l = serve(addr, ports)
+	defer l.Close()
+	if _, _, err := c.Exchange(m, addr.String()); err != nil {
+		t.Fatalf("failed to exchange after the error: %s", err.Error())
+	}
+	if p := <-ports; p == port {
+		t.Error("expected a new socket after the error")
+	}
+
+	// After MaxQueries queries the socket is replaced
+	c.Conns.Close()
+	c.Conns.MaxQueries = 2
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.Exchange(m, addr.String()); err != nil {
+			t.Fatalf("failed to exchange: %s", err.Error())
+		}
+	}
+	if p1, p2, p3 := <-ports, <-ports, <-ports; p1 != p2 || p3 == p2 {
+		t.Errorf("expected the socket to be replaced after 2 queries: ports %d, %d and %d", p1, p2, p3)
+	}
+}
@@ -25,7 +25,34 @@ func (dns *Msg) SetReply(request *Msg) *Msg {
 	return dns
 }
 
-// SetQuestion creates a question packet.
+// CopyCase makes the reply dns echo the exact case of the query name of
+// request, as resolvers that use 0x20 randomization check it: the question
+// is copied from request and the answer RRs that have the query name as
+// owner name, in another case, get the case of the query name. Those RRs,
+// and the answer section, are copied first: the RRs and the slice are often
+// shared with a zone.
+func (dns *Msg) CopyCase(request *Msg) *Msg {
+	if len(request.Question) == 0 || len(dns.Question) == 0 {
+		return dns
+	}
+	name := request.Question[0].Name
+	dns.Question[0].Name = name
+	copied := false
+	for i, r := range dns.Answer {
+		if h := r.Header(); h.Name != name && strings.EqualFold(h.Name, name) {
+			if !copied {
+				dns.Answer = append([]RR(nil), dns.Answer...)
+				copied = true
+			}
+			dns.Answer[i] = r.Copy()
+			dns.Answer[i].Header().Name = name
+		}
+	}
+	return dns
+}
+
+// SetQuestion creates a question packet. The name z is not checked here, Pack
+// returns an error (see ValidateName) when it is not valid.
 func (dns *Msg) SetQuestion(z string, t uint16) *Msg {
 	dns.Id = Id()
 	dns.RecursionDesired = true
@@ -34,6 +61,16 @@ func (dns *Msg) SetQuestion(z string, t uint16) *Msg {
 	return dns
 }
 
+// SetQuestions creates a packet asking all the questions in qs. Most
+// servers only answer queries with a single question, but multicast DNS
+// and some update and notify uses carry more.
+func (dns *Msg) SetQuestions(qs ...Question) *Msg {
+	dns.Id = Id()
+	dns.RecursionDesired = true
+	dns.Question = append([]Question(nil), qs...)
+	return dns
+}
+
 // SetNotify creates a notify packet.
 func (dns *Msg) SetNotify(z string) *Msg {
 	dns.Opcode = OpcodeNotify
@@ -155,79 +192,111 @@ func (dns *Msg) IsEdns0() *OPT {
 // IsDomainName checks if s is a valid domainname, it returns
 // the number of labels, total length and true, when a domain name is valid. 
 // When false is returned the labelcount and length are not defined.
-func IsDomainName(s string) (uint8, uint8, bool) { // copied from net package.
-	// TODO(mg): check for \DDD
-	// See RFC 1035, RFC 3696.
-	l := len(s)
-	if l == 0 || l > 255 {
+// See ValidateName for the rules.
+func IsDomainName(s string) (uint8, uint8, bool) {
+	if len(s) == 0 || len(s) > 255 {
 		return 0, 0, false
 	}
-	longer := 0
-	// Simplify checking loop: make the name end in a dot.
-	// Don't call Fqdn() to save another len(s).
-	// Keep in mind that if we do this, otherwise we report a length+1
-	if s[l-1] != '.' {
-		s += "."
-		l++
-		longer = 1
+	labels, _, err := ValidateName(s)
+	if err != nil {
+		return 0, uint8(len(s)), false
+	}
+	return uint8(labels), uint8(len(s)), true
+}
+
+// ValidateName checks the domain name s, which does not need to be fully
+// qualified, and returns the number of labels and its length in wire format.
+// Labels may hold letters, digits, '_', '*', '/' and '-' (but not as the
+// first character), all other octets must be escaped as \X or \DDD (RFC 1035,
+// section 5.1). The error is one of ErrLabelLen (a label longer than 63
+// octets), ErrNameLen (a name longer than 255 octets), ErrLabelEmpty,
+// ErrEscape and ErrNameChar.
+func ValidateName(s string) (labels int, wirelen int, err error) {
+	if s == "" {
+		return 0, 0, ErrLabelEmpty
 	}
-	// Preloop check for root label
 	if s == "." {
-		return 0, 1, true
+		return 0, 1, nil
 	}
-
-	last := byte('.')
-	ok := false // ok once we've seen a letter or digit
+	// The number of backslashes before the end tells whether the last octet
+	// is escaped
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	if n%2 == 1 {
+		return 0, 0, ErrEscape
+	}
+	if !IsFqdn(s) {
+		s += "."
+	} else {
+		n = 0
+		for i := len(s) - 2; i >= 0 && s[i] == '\\'; i-- {
+			n++
+		}
+		if n%2 == 1 { // an escaped dot
+			s += "."
+		}
+	}
+	wirelen = 1 // the root label
 	partlen := 0
-	labels := uint8(0)
-	for i := 0; i < l; i++ {
+	for i := 0; i < len(s); i++ {
 		c := s[i]
 		switch {
-		default:
-			return 0, uint8(l - longer), false
-		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '*' || c == '/':
-			ok = true
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '*' || c == '/':
 			partlen++
-		case c == '\\':
-			// Ok
-		case c == '@':
-			if last != '\\' {
-				return 0, uint8(l - longer), false
+		case c == '-':
+			if partlen == 0 {
+				return 0, 0, ErrNameChar
 			}
 			partlen++
-		case '0' <= c && c <= '9':
-			ok = true
-			partlen++
-		case c == '-':
-			// byte before dash cannot be dot
-			if last == '.' {
-				return 0, uint8(l - longer), false
+		case c == '\\':
+			i++ // never past the final dot, see above
+			if isDigit(s[i]) {
+				if i+2 >= len(s) || !isDigit(s[i+1]) || !isDigit(s[i+2]) {
+					return 0, 0, ErrEscape
+				}
+				if int(s[i]-'0')*100+int(s[i+1]-'0')*10+int(s[i+2]-'0') > 255 {
+					return 0, 0, ErrEscape
+				}
+				i += 2
 			}
 			partlen++
 		case c == '.':
-			// byte before dot cannot be dot
-			if last == '.' {
-				return 0, uint8(l - longer), false
+			if partlen == 0 {
+				return 0, 0, ErrLabelEmpty
 			}
-			if last == '\\' { // Ok, escaped dot.
-				partlen++
-				break
-			}
-			if partlen > 63 || partlen == 0 {
-				return 0, uint8(l - longer), false
+			if partlen > 63 {
+				return 0, 0, ErrLabelLen
 			}
+			wirelen += partlen + 1
 			partlen = 0
 			labels++
+		default:
+			return 0, 0, ErrNameChar
 		}
-		last = c
 	}
-	return labels, uint8(l - longer), ok
+	if wirelen > 255 {
+		return 0, 0, ErrNameLen
+	}
+	return labels, wirelen, nil
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// checkName validates s like ValidateName, but allows all octets in labels:
+// names unpacked from the wire are not escaped.
+func checkName(s string) error {
+	if _, _, err := ValidateName(s); err != nil && err != ErrNameChar {
+		return err
+	}
+	return nil
 }
 
 // IsSubDomain checks if child is indeed a child of the parent.
 func IsSubDomain(parent, child string) bool {
 	// Entire child is contained in parent
-	return CompareLabels(strings.ToLower(parent), strings.ToLower(child)) == LenLabels(parent)
+	return compareLabels(parent, child, true) == LenLabels(parent)
 }
 
 // IsFqdn checks if a domain name is fully qualified.
@@ -248,13 +317,30 @@ func Fqdn(s string) string {
 	return s + "."
 }
 
+// parseIPZone parses s as an IP address. An IPv6 address can have a zone
+// (the interface of a link-local address), as in fe80::1%eth0, which is
+// returned separately. It returns nil when s is not valid.
+func parseIPZone(s string) (net.IP, string) {
+	zone := ""
+	i := strings.LastIndex(s, "%")
+	if i >= 0 {
+		s, zone = s[:i], s[i+1:]
+	}
+	ip := net.ParseIP(s)
+	if ip == nil || (i >= 0 && (zone == "" || ip.To4() != nil)) {
+		return nil, ""
+	}
+	return ip, zone
+}
+
 // Copied from the official Go code
 
 // ReverseAddr returns the in-addr.arpa. or ip6.arpa. hostname of the IP                                        
 // address addr suitable for rDNS (PTR) record lookup or an error if it fails                                   
 // to parse the IP address.                                                                                     
+// The zone of an IPv6 address, as in fe80::1%eth0, is ignored.
 func ReverseAddr(addr string) (arpa string, err error) {
-	ip := net.ParseIP(addr)
+	ip, _ := parseIPZone(addr)
 	if ip == nil {
 		return "", &Error{Err: "unrecognized address", Name: addr}
 	}
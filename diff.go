@@ -0,0 +1,94 @@
+package dns
+
+// Comparing messages.
+
+import (
+	"strings"
+)
+
+// MsgDiff returns a readable description of the differences between a and b,
+// or an empty string when they are equal. The message ids are ignored, as
+// is the order of the RRs within each section. The lines with the RRs (and
+// header fields) only found in a start with "- ", those only found in b
+// with "+ ", grouped per section. This is meant for tests:
+//
+//	if d := dns.MsgDiff(want, got); d != "" {
+//		t.Errorf("unexpected reply:\n%s", d)
+//	}
+func MsgDiff(a, b *Msg) string {
+	if a == nil || b == nil {
+		if a == b {
+			return ""
+		}
+		return "- " + a.String() + "\n+ " + b.String() + "\n"
+	}
+	s := diffSection(";; HEADER:", hdrFields(&a.MsgHdr), hdrFields(&b.MsgHdr))
+	qa := make([]string, len(a.Question))
+	for i, q := range a.Question {
+		qa[i] = q.String()
+	}
+	qb := make([]string, len(b.Question))
+	for i, q := range b.Question {
+		qb[i] = q.String()
+	}
+	s += diffSection(";; QUESTION SECTION:", qa, qb)
+	s += diffSection(";; ANSWER SECTION:", rrStrings(a.Answer), rrStrings(b.Answer))
+	s += diffSection(";; AUTHORITY SECTION:", rrStrings(a.Ns), rrStrings(b.Ns))
+	s += diffSection(";; ADDITIONAL SECTION:", rrStrings(a.Extra), rrStrings(b.Extra))
+	return s
+}
+
+// hdrFields returns the fields of h as strings, without the id.
+func hdrFields(h *MsgHdr) []string {
+	flags := "flags:"
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{{h.Response, " qr"}, {h.Authoritative, " aa"}, {h.Truncated, " tc"}, {h.RecursionDesired, " rd"},
+		{h.RecursionAvailable, " ra"}, {h.Zero, " z"}, {h.AuthenticatedData, " ad"}, {h.CheckingDisabled, " cd"}} {
+		if f.set {
+			flags += f.name
+		}
+	}
+	return []string{"opcode: " + OpcodeToString[h.Opcode], "status: " + RcodeToString[h.Rcode], flags}
+}
+
+func rrStrings(rrs []RR) []string {
+	s := make([]string, 0, len(rrs))
+	for _, r := range rrs {
+		if r != nil {
+			s = append(s, r.String())
+		}
+	}
+	return s
+}
+
+// diffSection returns the lines only in a (prefixed with "- ") and only in b
+// (prefixed with "+ "), after the title. When a and b hold the same lines,
+// the empty string is returned.
+func diffSection(title string, a, b []string) string {
+	count := make(map[string]int)
+	for _, l := range a {
+		count[l]++
+	}
+	for _, l := range b {
+		count[l]--
+	}
+	var minus, plus []string
+	seen := make(map[string]int)
+	for _, l := range a {
+		if seen[l]++; count[l] > 0 && seen[l] <= count[l] {
+			minus = append(minus, "- "+l)
+		}
+	}
+	seen = make(map[string]int)
+	for _, l := range b {
+		if seen[l]++; count[l] < 0 && seen[l] <= -count[l] {
+			plus = append(plus, "+ "+l)
+		}
+	}
+	if len(minus) == 0 && len(plus) == 0 {
+		return ""
+	}
+	return title + "\n" + strings.Join(append(minus, plus...), "\n") + "\n"
+}
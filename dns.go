@@ -84,17 +84,31 @@ import (
 const (
 	year68         = 1 << 31 // For RFC1982 (Serial Arithmetic) calculations in 32 bits.
 	DefaultMsgSize = 4096    // Standard default for larger than 512 packets.
+	FlagDayMsgSize = 1232    // EDNS0 buffer size that avoids fragmentation, see DNS Flag Day 2020.
 	udpMsgSize     = 512     // Default buffer size for servers receiving UDP packets.
 	MaxMsgSize     = 65536   // Largest possible DNS packet.
 	defaultTtl     = 3600    // Default TTL.
 )
 
-// Error represents a DNS error
+// Error represents a DNS error. Most failures can be told apart with
+// errors.Is, using one of the sentinel errors ErrTimeout, ErrTruncated,
+// ErrRcode, ErrTsig, ErrParse, ErrIncomplete and ErrRejected:
+//
+//	r, _, err := c.Exchange(m, "127.0.0.1:53")
+//	if errors.Is(err, dns.ErrTimeout) {
+//		// try the next server
+//	}
+//
+// The underlying error, such as the net.Error of a timeout, is returned
+// by Unwrap.
 type Error struct {
 	Err     string
 	Name    string
 	Server  net.Addr
 	Timeout bool
+	Rcode   int   // The rcode of the reply, for errors matching ErrRcode
+	kind    error // The sentinel error this error matches, see Is
+	cause   error // The underlying error, see Unwrap
 }
 
 func (e *Error) Error() string {
@@ -108,6 +122,24 @@ func (e *Error) Error() string {
 
 }
 
+// Is reports whether e matches the sentinel error target.
+func (e *Error) Is(target error) bool {
+	return target != nil && (target == e.kind || (e.Timeout && target == ErrTimeout))
+}
+
+// Unwrap returns the error that caused e, or nil.
+func (e *Error) Unwrap() error { return e.cause }
+
+// wrapError returns an error matching the sentinel error kind, caused
+// by cause. It has the same text as cause.
+func wrapError(kind, cause error) *Error {
+	e := &Error{Err: cause.Error(), kind: kind, cause: cause}
+	if c, ok := cause.(*Error); ok {
+		e.Err, e.Name, e.Server, e.Timeout = c.Err, c.Name, c.Server, c.Timeout
+	}
+	return e
+}
+
 // An RR represents a resource record.
 type RR interface {
 	// Header returns the header of an resource record. The header contains
@@ -178,6 +210,9 @@ func (h *RR_Header) String() string {
 
 func (h *RR_Header) Len() int {
 	l := len(h.Name) + 1
+	if h.Name == "." {
+		l = 1 // the root is a single null label
+	}
 	l += 10 // rrtype(2) + class(2) + ttl(4) + rdlength(2)
 	return l
 }
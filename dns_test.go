@@ -1,7 +1,10 @@
 package dns
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"strings"
 	"testing"
 )
 
@@ -152,3 +155,188 @@ func TestCompressLenght(t *testing.T) {
 		t.Fatalf("Should be equal")
 	}
 }
+
+func TestCompressLenPacked(t *testing.T) {
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeMX)
+	m.Compress = true
+	for i := 0; i < 60; i++ {
+		a, _ := NewRR("miek.nl. 3600 IN A 127.0.0.1")
+		mx, _ := NewRR(fmt.Sprintf("miek.nl. 3600 IN MX 10 mx%d.miek.nl.", i))
+		m.Answer = append(m.Answer, a, mx)
+	}
+	soa, _ := NewRR("miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400")
+	m.Ns = append(m.Ns, soa)
+	for _, compress := range []bool{true, false} {
+		m.Compress = compress
+		buf, err := m.Pack()
+		if err != nil {
+			t.Fatalf("failed to pack with compression %t: %s", compress, err.Error())
+		}
+		if m.Len() != len(buf) {
+			t.Errorf("expected length %d with compression %t, got %d", len(buf), compress, m.Len())
+		}
+	}
+
+	// Many names with compression pointers past the first 16K.
+	m.Answer = nil
+	m.Compress = true
+	for i := 0; i < 1000; i++ {
+		txt, _ := NewRR(fmt.Sprintf("txt%d.miek.nl. TXT %q", i, strings.Repeat("x", 20)))
+		m.Answer = append(m.Answer, txt)
+	}
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	if m.Len() < len(buf) {
+		t.Errorf("expected length of at least %d, got %d", len(buf), m.Len())
+	}
+}
+
+func TestMsgDiff(t *testing.T) {
+	a := new(Msg)
+	a.SetQuestion("miek.nl.", TypeA)
+	a1, _ := NewRR("miek.nl. 3600 IN A 127.0.0.1")
+	a2, _ := NewRR("miek.nl. 3600 IN A 127.0.0.2")
+	a.Answer = []RR{a1, a2}
+	b := new(Msg)
+	b.SetQuestion("miek.nl.", TypeA)
+	b.Answer = []RR{a2, a1}
+	if d := MsgDiff(a, b); d != "" {
+		t.Errorf("expected no difference, got:\n%s", d)
+	}
+	b.Rcode = RcodeNameError
+	b.Answer = []RR{a2}
+	want := ";; HEADER:\n- status: NOERROR\n+ status: NXDOMAIN\n;; ANSWER SECTION:\n- " + a1.String() + "\n"
+	if d := MsgDiff(a, b); d != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, d)
+	}
+}
+
+func TestEDNS0Options(t *testing.T) {
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeA)
+	m.SetEdns0(4096, true)
+	opt := m.IsEdns0()
+	opt.SetOption(&EDNS0_NSID{Code: EDNS0NSID})
+	opt.SetOption(&EDNS0_LOCAL{Code: 65001, Data: []byte{1, 2, 3}})
+	opt.SetOption(&EDNS0_TCP_KEEPALIVE{Code: EDNS0TCPKEEPALIVE, Length: 2, Timeout: 100})
+	opt.SetOption(&EDNS0_LOCAL{Code: 65001, Data: []byte{4, 5}})
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack message: %s", err.Error())
+	}
+	in := new(Msg)
+	if err := in.Unpack(buf); err != nil {
+		t.Fatalf("failed to unpack message: %s", err.Error())
+	}
+	opt = in.IsEdns0()
+	if len(opt.Option) != 3 {
+		t.Fatalf("expected 3 options, got %d", len(opt.Option))
+	}
+	if e, ok := opt.GetOption(65001).(*EDNS0_LOCAL); !ok || len(e.Data) != 2 || e.Data[1] != 5 {
+		t.Errorf("local option not unpacked: %v", opt.GetOption(65001))
+	}
+	if e, ok := opt.GetOption(EDNS0TCPKEEPALIVE).(*EDNS0_TCP_KEEPALIVE); !ok || e.Timeout != 100 {
+		t.Errorf("keepalive option not unpacked: %v", opt.GetOption(EDNS0TCPKEEPALIVE))
+	}
+	opt.DeleteOption(EDNS0NSID)
+	if opt.GetOption(EDNS0NSID) != nil || len(opt.Option) != 2 {
+		t.Errorf("NSID option not deleted")
+	}
+}
+
+func TestUpdateBuilder(t *testing.T) {
+	a, _ := NewRR("www.example.org. 3600 IN A 192.0.2.1")
+	u := NewUpdate("example.org")
+	u.RequireExists("www.example.org.", TypeA)
+	u.Delete(a)
+	u.Add(a)
+	if a.Header().Class != ClassINET || a.Header().Ttl != 3600 {
+		t.Errorf("the RR given to the builder was modified: %s", a.String())
+	}
+	if len(u.Answer) != 1 || u.Answer[0].Header().Class != ClassANY || u.Answer[0].Header().Rrtype != TypeA {
+		t.Errorf("bad prerequisite section: %v", u.Answer)
+	}
+	if len(u.Ns) != 2 || u.Ns[0].Header().Class != ClassNONE || u.Ns[0].Header().Ttl != 0 || u.Ns[1].Header().Ttl != 3600 {
+		t.Errorf("bad update section: %v", u.Ns)
+	}
+
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for i := 0; i < 2; i++ {
+			n, addr, err := l.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				continue // drop the first try
+			}
+			req := new(Msg)
+			req.Unpack(buf[:n])
+			m := new(Msg)
+			m.SetRcode(req, RcodeSuccess)
+			out, _ := m.Pack()
+			l.WriteTo(out, addr)
+		}
+	}()
+	if _, err := u.SendUpdate(l.LocalAddr().String(), nil); err != nil {
+		t.Errorf("update failed: %s", err.Error())
+	}
+}
+
+// testOption is an EDNS0 option as it would be defined outside the package.
+type testOption struct {
+	Cookie [8]byte
+}
+
+func (e *testOption) Option() uint16        { return 65002 }
+func (e *testOption) Pack() ([]byte, error) { return e.Cookie[:], nil }
+func (e *testOption) String() string        { return fmt.Sprintf("%x", e.Cookie) }
+func (e *testOption) Unpack(b []byte) error {
+	if len(b) != len(e.Cookie) {
+		return errors.New("bad cookie length")
+	}
+	copy(e.Cookie[:], b)
+	return nil
+}
+
+func TestRegisterEDNS0(t *testing.T) {
+	RegisterEDNS0(65002, func() EDNS0 { return new(testOption) })
+	defer RegisterEDNS0(65002, nil)
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeA)
+	m.SetEdns0(4096, false)
+	m.IsEdns0().SetOption(&testOption{Cookie: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack message: %s", err.Error())
+	}
+	in := new(Msg)
+	if err := in.Unpack(buf); err != nil {
+		t.Fatalf("failed to unpack message: %s", err.Error())
+	}
+	if e, ok := in.IsEdns0().GetOption(65002).(*testOption); !ok || e.Cookie[7] != 8 {
+		t.Errorf("custom option not unpacked: %v", in.IsEdns0().GetOption(65002))
+	}
+
+	m.IsEdns0().SetOption(&EDNS0_LOCAL{Code: 65002, Data: []byte{1, 2, 3}})
+	buf, _ = m.Pack()
+	if err := in.Unpack(buf); err == nil {
+		t.Error("expected the bad custom option to fail unpacking")
+	}
+
+	RegisterEDNS0(65002, nil)
+	if err := in.Unpack(buf); err != nil {
+		t.Fatalf("failed to unpack message: %s", err.Error())
+	}
+	if _, ok := in.IsEdns0().GetOption(65002).(*EDNS0_LOCAL); !ok {
+		t.Errorf("unregistered option not unpacked as EDNS0_LOCAL: %v", in.IsEdns0().GetOption(65002))
+	}
+}
@@ -0,0 +1,311 @@
+// DNSCRYPT
+//
+// DNSCrypt (version 2) encrypts and authenticates the traffic between a
+// client and a resolver. The resolver is known by its provider name (e.g.
+// "2.dnscrypt-cert.example.net") and the Ed25519 public key of the
+// provider. The provider signs short lived certificates, published as TXT
+// records for the provider name on the resolver itself, that hold the
+// X25519 public key of the resolver. Each query is encrypted with a fresh
+// client key pair, the reply with the same shared key. Basic use pattern:
+//
+//	c := &dns.DNSCryptClient{Server: "192.0.2.1:443",
+//		ProviderName: "2.dnscrypt-cert.example.net", ProviderKey: key}
+//	in, rtt, err := c.Exchange(m)
+//
+// Only the X25519-XSalsa20Poly1305 construction (es-version 1) is
+// supported, certificates for XChaCha20Poly1305 are skipped. The server
+// side is not implemented, (*DNSCryptCert).Pack can be used to create
+// certificates.
+package dns
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	DNSCryptXSalsa20Poly1305 = 0x0001 // es-version of X25519-XSalsa20Poly1305
+
+	dnscryptCertMagic   = "DNSC"
+	dnscryptServerMagic = "r6fnvWj8"
+	dnscryptCertLen     = 124 // without extensions
+	dnscryptMinQuery    = 256 // minimal size of a padded query over UDP
+)
+
+// DNSCryptCert is a DNSCrypt certificate, signed by the provider.
+type DNSCryptCert struct {
+	Version      uint16 // es-version, DNSCryptXSalsa20Poly1305
+	MinorVersion uint16
+	ResolverPK   [32]byte // the X25519 public key of the resolver
+	ClientMagic  [8]byte  // the first 8 bytes of each query
+	Serial       uint32
+	NotBefore    uint32 // start of the validity period, seconds since the epoch
+	NotAfter     uint32 // end of the validity period, seconds since the epoch
+	Extensions   []byte
+}
+
+// ParseDNSCryptCert parses the certificate in b and checks its signature
+// with the public key of the provider. The validity period is not checked.
+func ParseDNSCryptCert(b []byte, providerKey ed25519.PublicKey) (*DNSCryptCert, error) {
+	if len(b) < dnscryptCertLen || string(b[:4]) != dnscryptCertMagic {
+		return nil, &Error{Err: "bad dnscrypt certificate"}
+	}
+	if len(providerKey) != ed25519.PublicKeySize {
+		return nil, ErrKeySize
+	}
+	if !ed25519.Verify(providerKey, b[72:], b[8:72]) {
+		return nil, ErrSig
+	}
+	c := &DNSCryptCert{
+		Version:      binary.BigEndian.Uint16(b[4:]),
+		MinorVersion: binary.BigEndian.Uint16(b[6:]),
+		Serial:       binary.BigEndian.Uint32(b[112:]),
+		NotBefore:    binary.BigEndian.Uint32(b[116:]),
+		NotAfter:     binary.BigEndian.Uint32(b[120:]),
+		Extensions:   append([]byte(nil), b[124:]...),
+	}
+	copy(c.ResolverPK[:], b[72:104])
+	copy(c.ClientMagic[:], b[104:112])
+	return c, nil
+}
+
+// Pack returns c in wire format, signed with the private key of the
+// provider.
+func (c *DNSCryptCert) Pack(providerKey ed25519.PrivateKey) []byte {
+	b := make([]byte, dnscryptCertLen, dnscryptCertLen+len(c.Extensions))
+	copy(b, dnscryptCertMagic)
+	binary.BigEndian.PutUint16(b[4:], c.Version)
+	binary.BigEndian.PutUint16(b[6:], c.MinorVersion)
+	copy(b[72:], c.ResolverPK[:])
+	copy(b[104:], c.ClientMagic[:])
+	binary.BigEndian.PutUint32(b[112:], c.Serial)
+	binary.BigEndian.PutUint32(b[116:], c.NotBefore)
+	binary.BigEndian.PutUint32(b[120:], c.NotAfter)
+	b = append(b, c.Extensions...)
+	copy(b[8:72], ed25519.Sign(providerKey, b[72:]))
+	return b
+}
+
+// Valid returns true when t is in the validity period of c.
+func (c *DNSCryptCert) Valid(t time.Time) bool {
+	u := t.Unix()
+	return u >= int64(c.NotBefore) && u <= int64(c.NotAfter)
+}
+
+// FetchDNSCryptCert asks the resolver at server for the certificates of the
+// provider, using c. Of the valid, supported certificates signed by
+// providerKey the one with the highest serial is returned.
+func FetchDNSCryptCert(c *Client, server, provider string, providerKey ed25519.PublicKey) (*DNSCryptCert, error) {
+	m := new(Msg)
+	m.SetQuestion(Fqdn(provider), TypeTXT)
+	r, _, err := c.Exchange(m, server)
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode != RcodeSuccess {
+		return nil, &Error{Err: "dnscrypt certificate query failed: " + RcodeToString[r.Rcode], Name: provider, Rcode: r.Rcode, kind: ErrRcode}
+	}
+	var best *DNSCryptCert
+	now := time.Now()
+	for _, rr := range r.Answer {
+		txt, ok := rr.(*TXT)
+		if !ok {
+			continue
+		}
+		cert, err := ParseDNSCryptCert([]byte(JoinTxt(txt.Txt)), providerKey)
+		if err != nil || cert.Version != DNSCryptXSalsa20Poly1305 || !cert.Valid(now) {
+			continue
+		}
+		if best == nil || cert.Serial > best.Serial {
+			best = cert
+		}
+	}
+	if best == nil {
+		return nil, &Error{Err: "no valid dnscrypt certificate", Name: provider}
+	}
+	return best, nil
+}
+
+// A DNSCryptClient sends encrypted queries to a DNSCrypt resolver. The
+// certificate is fetched on first use, and again when it expires.
+type DNSCryptClient struct {
+	Server       string            // address of the resolver, host:port
+	ProviderName string            // e.g. "2.dnscrypt-cert.example.net"
+	ProviderKey  ed25519.PublicKey // public key of the provider
+	Net          string            // "udp" (default) or "tcp"
+	Timeout      time.Duration     // for each exchange, defaults to 2 seconds
+	Cert         *DNSCryptCert     // if set, this certificate is used
+	mu           sync.Mutex
+}
+
+func (c *DNSCryptClient) cert() (*DNSCryptCert, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Cert != nil && c.Cert.Valid(time.Now()) {
+		return c.Cert, nil
+	}
+	cert, err := FetchDNSCryptCert(&Client{ReadTimeout: c.timeout()}, c.Server, c.ProviderName, c.ProviderKey)
+	if err != nil {
+		return nil, err
+	}
+	c.Cert = cert
+	return cert, nil
+}
+
+func (c *DNSCryptClient) timeout() time.Duration {
+	if c.Timeout != 0 {
+		return c.Timeout
+	}
+	return 2 * 1e9
+}
+
+// Exchange encrypts the query m, sends it to the resolver and returns the
+// decrypted reply. When a reply over UDP is truncated, the query is sent
+// again over TCP.
+func (c *DNSCryptClient) Exchange(m *Msg) (r *Msg, rtt time.Duration, err error) {
+	cert, err := c.cert()
+	if err != nil {
+		return nil, 0, err
+	}
+	network := c.Net
+	if network == "" {
+		network = "udp"
+	}
+	r, rtt, err = c.exchange(m, cert, network)
+	if err == nil && r.MsgHdr.Truncated && network == "udp" {
+		return c.exchange(m, cert, "tcp")
+	}
+	return r, rtt, err
+}
+
+func (c *DNSCryptClient) exchange(m *Msg, cert *DNSCryptCert, network string) (r *Msg, rtt time.Duration, err error) {
+	q, err := m.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	query, key, nonce, err := cert.seal(q, network != "tcp")
+	if err != nil {
+		return nil, 0, err
+	}
+	t := time.Now()
+	conn, err := net.DialTimeout(network, c.Server, c.timeout())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(t.Add(c.timeout()))
+	var b []byte
+	if network == "tcp" {
+		l0, l1 := packUint16(uint16(len(query)))
+		if _, err := conn.Write(append([]byte{l0, l1}, query...)); err != nil {
+			return nil, 0, err
+		}
+		l := make([]byte, 2)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, 0, netError(err)
+		}
+		b = make([]byte, int(l[0])<<8|int(l[1]))
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return nil, 0, netError(err)
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, 0, err
+		}
+		b = make([]byte, MaxMsgSize)
+		n, err := conn.Read(b)
+		if err != nil {
+			return nil, 0, netError(err)
+		}
+		b = b[:n]
+	}
+	rtt = time.Since(t)
+	plain, err := dnscryptOpen(b, key, nonce)
+	if err != nil {
+		return nil, 0, err
+	}
+	r = new(Msg)
+	if err := r.Unpack(plain); err != nil {
+		return nil, 0, err
+	}
+	if r.Id != m.Id {
+		return r, rtt, ErrId
+	}
+	return r, rtt, nil
+}
+
+// seal encrypts the query q for the resolver of c. It returns the query
+// packet, the shared key and the client nonce.
+func (c *DNSCryptCert) seal(q []byte, udp bool) (query []byte, key *[32]byte, nonce []byte, err error) {
+	resolver, err := ecdh.X25519().NewPublicKey(c.ResolverPK[:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if key, err = boxKey(priv, resolver); err != nil {
+		return nil, nil, nil, err
+	}
+	nonce = make([]byte, 24) // the last 12 bytes stay zero
+	if _, err := rand.Read(nonce[:12]); err != nil {
+		return nil, nil, nil, err
+	}
+	min := 0
+	if udp {
+		min = dnscryptMinQuery
+	}
+	query = append(query, c.ClientMagic[:]...)
+	query = append(query, priv.PublicKey().Bytes()...)
+	query = append(query, nonce[:12]...)
+	query = append(query, secretboxSeal(dnscryptPad(q, min), key, nonce)...)
+	return query, key, nonce, nil
+}
+
+// dnscryptOpen decrypts the response b to the query sent with key and the
+// client nonce.
+func dnscryptOpen(b []byte, key *[32]byte, nonce []byte) ([]byte, error) {
+	if len(b) < 8+24+secretboxOverhead || string(b[:8]) != dnscryptServerMagic {
+		return nil, &Error{Err: "bad dnscrypt response"}
+	}
+	if !bytes.Equal(b[8:20], nonce[:12]) {
+		return nil, &Error{Err: "dnscrypt nonce mismatch"}
+	}
+	plain, err := secretboxOpen(b[32:], key, b[8:32])
+	if err != nil {
+		return nil, err
+	}
+	return dnscryptUnpad(plain)
+}
+
+// dnscryptPad pads msg with 0x80 and zeros to a multiple of 64 bytes, and
+// to at least min bytes.
+func dnscryptPad(msg []byte, min int) []byte {
+	n := (len(msg) + 1 + 63) / 64 * 64
+	if n < min {
+		n = min
+	}
+	b := make([]byte, n)
+	copy(b, msg)
+	b[len(msg)] = 0x80
+	return b
+}
+
+func dnscryptUnpad(b []byte) ([]byte, error) {
+	i := len(b) - 1
+	for i >= 0 && b[i] == 0 {
+		i--
+	}
+	if i < 0 || b[i] != 0x80 {
+		return nil, &Error{Err: "bad dnscrypt padding"}
+	}
+	return b[:i], nil
+}
@@ -0,0 +1,201 @@
+package dns
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretbox(t *testing.T) {
+	var key [32]byte
+	copy(key[:], "this is 32-byte key for xsalsa20")
+	out := make([]byte, 12)
+	xsalsa20XOR(out, []byte("Hello world!"), &key, []byte("24-byte nonce for xsalsa"))
+	if hex.EncodeToString(out) != "002d4513843fc240c401e541" {
+		t.Errorf("bad xsalsa20 output: %x", out)
+	}
+	k, _ := hex.DecodeString("85d6be7857556d337f4452fe42d506a80103808afb0db2fd4abff6af4149f51b")
+	copy(key[:], k)
+	if tag := poly1305([]byte("Cryptographic Forum Research Group"), &key); hex.EncodeToString(tag[:]) != "a8061dc1305136c6c22b8baf0c0127a9" {
+		t.Errorf("bad poly1305 tag: %x", tag)
+	}
+	// Test vectors from RFC 8439, appendix A.3, for the final reduction
+	for _, tt := range []struct{ key, msg, tag string }{
+		{"02" + strings.Repeat("00", 31), strings.Repeat("ff", 16), "03" + strings.Repeat("00", 15)},
+		{"02" + strings.Repeat("00", 15) + strings.Repeat("ff", 16), "02" + strings.Repeat("00", 15), "03" + strings.Repeat("00", 15)},
+		{"02" + strings.Repeat("00", 31), "fd" + strings.Repeat("ff", 15), "fa" + strings.Repeat("ff", 15)},
+		{"01" + strings.Repeat("00", 31), strings.Repeat("ff", 16) + "fb" + strings.Repeat("fe", 15) + strings.Repeat("01", 16), strings.Repeat("00", 16)},
+	} {
+		k, _ := hex.DecodeString(tt.key)
+		msg, _ := hex.DecodeString(tt.msg)
+		copy(key[:], k)
+		if tag := poly1305(msg, &key); hex.EncodeToString(tag[:]) != tt.tag {
+			t.Errorf("bad poly1305 tag for %s: %x, expected %s", tt.msg, tag, tt.tag)
+		}
+	}
+	copy(key[:], k)
+	nonce := make([]byte, 24)
+	box := secretboxSeal([]byte("miek.nl"), &key, nonce)
+	if plain, err := secretboxOpen(box, &key, nonce); err != nil || string(plain) != "miek.nl" {
+		t.Errorf("failed to open the box: %q %v", plain, err)
+	}
+	box[len(box)-1] ^= 1
+	if _, err := secretboxOpen(box, &key, nonce); err != ErrAuth {
+		t.Errorf("expected ErrAuth for a modified box, got %v", err)
+	}
+}
+
+// dnscryptServer is a minimal DNSCrypt resolver on UDP and TCP on the same
+// port. It answers A queries with 192.0.2.1, over UDP queries for names
+// starting with "big" get a truncated reply.
+func dnscryptServer(t *testing.T, providerKey ed25519.PrivateKey) (string, *DNSCryptCert) {
+	resolver, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	now := uint32(time.Now().Unix())
+	cert := &DNSCryptCert{Version: DNSCryptXSalsa20Poly1305, Serial: 2, NotBefore: now - 3600, NotAfter: now + 3600}
+	copy(cert.ResolverPK[:], resolver.PublicKey().Bytes())
+	copy(cert.ClientMagic[:], "magic123")
+	old := *cert
+	old.Serial = 1
+	expired := *cert
+	expired.Serial, expired.NotAfter = 3, now-1
+
+	answer := func(b []byte, udp bool) []byte {
+		if string(b[:8]) != "magic123" {
+			req := new(Msg)
+			if req.Unpack(b) != nil {
+				return nil
+			}
+			m := new(Msg)
+			m.SetReply(req)
+			for _, c := range []*DNSCryptCert{&old, cert, &expired} {
+				m.Answer = append(m.Answer, &TXT{Hdr: RR_Header{Name: req.Question[0].Name, Rrtype: TypeTXT, Class: ClassINET, Ttl: 3600}, Txt: []string{string(c.Pack(providerKey))}})
+			}
+			out, _ := m.Pack()
+			return out
+		}
+		pub, err := ecdh.X25519().NewPublicKey(b[8:40])
+		if err != nil {
+			return nil
+		}
+		key, _ := boxKey(resolver, pub)
+		nonce := make([]byte, 24)
+		copy(nonce, b[40:52])
+		plain, err := secretboxOpen(b[52:], key, nonce)
+		if err != nil || (udp && len(plain) < dnscryptMinQuery) {
+			return nil
+		}
+		q, _ := dnscryptUnpad(plain)
+		req := new(Msg)
+		if req.Unpack(q) != nil {
+			return nil
+		}
+		m := new(Msg)
+		m.SetReply(req)
+		if udp && strings.HasPrefix(req.Question[0].Name, "big") {
+			m.MsgHdr.Truncated = true
+		} else {
+			rr, _ := NewRR(req.Question[0].Name + " A 192.0.2.1")
+			m.Answer = []RR{rr}
+		}
+		out, _ := m.Pack()
+		rand.Read(nonce[12:])
+		return append(append([]byte(dnscryptServerMagic), nonce...), secretboxSeal(dnscryptPad(out, 0), key, nonce)...)
+	}
+
+	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: u.LocalAddr().(*net.UDPAddr).Port})
+	if err != nil {
+		u.Close()
+		t.Skipf("failed to listen on tcp: %s", err.Error())
+	}
+	t.Cleanup(func() { u.Close(); l.Close() })
+	go func() {
+		buf := make([]byte, MaxMsgSize)
+		for {
+			n, a, err := u.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if out := answer(buf[:n], true); out != nil {
+				u.WriteTo(out, a)
+			}
+		}
+	}()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			p := make([]byte, 2)
+			if _, err := io.ReadFull(c, p); err == nil {
+				b := make([]byte, int(p[0])<<8|int(p[1]))
+				if _, err := io.ReadFull(c, b); err == nil {
+					if out := answer(b, false); out != nil {
+						l0, l1 := packUint16(uint16(len(out)))
+						c.Write(append([]byte{l0, l1}, out...))
+					}
+				}
+			}
+			c.Close()
+		}
+	}()
+	return u.LocalAddr().String(), cert
+}
+
+func TestDNSCrypt(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	a, cert := dnscryptServer(t, priv)
+
+	got, err := FetchDNSCryptCert(new(Client), a, "2.dnscrypt-cert.example.net", pub)
+	if err != nil {
+		t.Fatalf("failed to fetch the certificate: %s", err.Error())
+	}
+	if got.Serial != 2 || got.ResolverPK != cert.ResolverPK || got.ClientMagic != cert.ClientMagic {
+		t.Errorf("expected the valid certificate with the highest serial, got %+v", got)
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := FetchDNSCryptCert(new(Client), a, "2.dnscrypt-cert.example.net", other); err == nil {
+		t.Error("expected an error for certificates signed by another key")
+	}
+
+	c := &DNSCryptClient{Server: a, ProviderName: "2.dnscrypt-cert.example.net", ProviderKey: pub}
+	for _, name := range []string{"www.miek.nl.", "big.miek.nl."} {
+		m := new(Msg)
+		m.SetQuestion(name, TypeA)
+		r, _, err := c.Exchange(m)
+		if err != nil {
+			t.Fatalf("failed to exchange %s: %s", name, err.Error())
+		}
+		if r.MsgHdr.Truncated || len(r.Answer) != 1 || r.Answer[0].(*A).A.String() != "192.0.2.1" {
+			t.Errorf("unexpected reply for %s:\n%s", name, r)
+		}
+	}
+	if c.Cert == nil || c.Cert.Serial != 2 {
+		t.Errorf("expected the certificate to be kept, got %+v", c.Cert)
+	}
+}
+
+func TestDNSCryptPad(t *testing.T) {
+	for _, n := range []int{0, 63, 64, 300} {
+		b := dnscryptPad(make([]byte, n), dnscryptMinQuery)
+		if len(b)%64 != 0 || len(b) < dnscryptMinQuery || len(b) <= n {
+			t.Errorf("bad padding of %d bytes: %d", n, len(b))
+		}
+		if u, err := dnscryptUnpad(b); err != nil || len(u) != n {
+			t.Errorf("bad unpadding of %d bytes: %d %v", n, len(u), err)
+		}
+	}
+	if _, err := dnscryptUnpad(make([]byte, 64)); err == nil {
+		t.Error("expected an error for missing padding")
+	}
+}
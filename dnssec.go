@@ -22,8 +22,8 @@ import (
 	"crypto"
 	"crypto/dsa"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
-	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
@@ -52,6 +52,8 @@ const (
 	ECCGOST          = 12
 	ECDSAP256SHA256  = 13
 	ECDSAP384SHA384  = 14
+	ED25519          = 15
+	ED448            = 16
 	INDIRECT         = 252
 	PRIVATEDNS       = 253 // Private (experimental keys)
 	PRIVATEOID       = 254
@@ -139,7 +141,10 @@ func (k *DNSKEY) KeyTag() uint16 {
 	return uint16(keytag)
 }
 
-// ToDS converts a DNSKEY record to a DS record.
+// ToDS converts a DNSKEY record to a DS record, h is the digest type: SHA1,
+// SHA256 or SHA384, or any type added with RegisterDigest. SHA1 should
+// no longer be used for new DS records (RFC 8624). ToDS returns nil when the
+// digest type is not supported.
 func (k *DNSKEY) ToDS(h int) *DS {
 	if k == nil {
 		return nil
@@ -166,7 +171,7 @@ func (k *DNSKEY) ToDS(h int) *DS {
 	wire = wire[:n]
 
 	owner := make([]byte, 255)
-	off, err1 := PackDomainName(k.Hdr.Name, owner, 0, nil, false)
+	off, err1 := PackDomainName(strings.ToLower(k.Hdr.Name), owner, 0, nil, false)
 	if err1 != nil {
 		return nil
 	}
@@ -179,27 +184,34 @@ func (k *DNSKEY) ToDS(h int) *DS {
 	// digest buffer
 	digest := append(owner, wire...) // another copy
 
-	switch h {
-	case SHA1:
-		s := sha1.New()
-		io.WriteString(s, string(digest))
-		ds.Digest = hex.EncodeToString(s.Sum(nil))
-	case SHA256:
-		s := sha256.New()
-		io.WriteString(s, string(digest))
-		ds.Digest = hex.EncodeToString(s.Sum(nil))
-	case SHA384:
-		s := sha512.New384()
-		io.WriteString(s, string(digest))
-		ds.Digest = hex.EncodeToString(s.Sum(nil))
-	case GOST94:
-		/* I have no clue */
-	default:
+	f, ok := digestHash(uint8(h))
+	if !ok {
 		return nil
 	}
+	s := f()
+	s.Write(digest)
+	ds.Digest = hex.EncodeToString(s.Sum(nil))
 	return ds
 }
 
+// Verify checks if the DS record rr is the digest of the DNSKEY k. It
+// returns ErrAlg when the digest type of rr isn't supported, a validator
+// should then treat the delegation as insecure.
+func (rr *DS) Verify(k *DNSKEY) error {
+	if !DigestSupported(rr.DigestType) {
+		return ErrAlg
+	}
+	ds := k.ToDS(int(rr.DigestType))
+	if ds == nil {
+		return ErrKey
+	}
+	if ds.KeyTag != rr.KeyTag || ds.Algorithm != rr.Algorithm || !strings.EqualFold(ds.Hdr.Name, rr.Hdr.Name) ||
+		!strings.EqualFold(ds.Digest, rr.Digest) {
+		return ErrKey
+	}
+	return nil
+}
+
 // Sign signs an RRSet. The signature needs to be filled in with
 // the values: Inception, Expiration, KeyTag, SignerName and Algorithm.
 // The rest is copied from the RRset. Sign returns true when the signing went OK,
@@ -250,6 +262,16 @@ func (rr *RRSIG) Sign(k PrivateKey, rrset []RR) error {
 	}
 	signdata = append(signdata, wire...)
 
+	if rr.Algorithm == ED25519 {
+		// Ed25519 signs the data itself, not a hash of it
+		p, ok := k.(ed25519.PrivateKey)
+		if !ok {
+			return ErrKeyAlg
+		}
+		rr.Signature = unpackBase64(ed25519.Sign(p, signdata))
+		return nil
+	}
+
 	var sighash []byte
 	var h hash.Hash
 	var ch crypto.Hash // Only need for RSA
@@ -297,8 +319,11 @@ func (rr *RRSIG) Sign(k PrivateKey, rrset []RR) error {
 		if err != nil {
 			return err
 		}
-		signature := r1.Bytes()
-		signature = append(signature, s1.Bytes()...)
+		// R and S are padded to the size of the curve (RFC 6605, section 4)
+		size := (p.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		copy(signature[size-len(r1.Bytes()):], r1.Bytes())
+		copy(signature[2*size-len(s1.Bytes()):], s1.Bytes())
 		rr.Signature = unpackBase64(signature)
 	default:
 		// Not given the correct key
@@ -309,7 +334,10 @@ func (rr *RRSIG) Sign(k PrivateKey, rrset []RR) error {
 
 // Verify validates an RRSet with the signature and key. This is only the
 // cryptographic test, the signature validity period must be checked separately.
-// This function copies the rdata of some RRs (to lowercase domain names) for the validation to work. 
+// This function copies the rdata of some RRs (to lowercase domain names) for the validation to work.
+// The algorithm is looked up in the registered algorithms, see RegisterAlgorithm.
+// If the algorithm is not registered ErrAlg is returned; a validator
+// should treat the RRset as insecure, not as bogus (RFC 4035, section 5.2).
 func (rr *RRSIG) Verify(k *DNSKEY, rrset []RR) error {
 	// First the easy checks
 	if len(rrset) == 0 {
@@ -362,65 +390,11 @@ func (rr *RRSIG) Verify(k *DNSKEY, rrset []RR) error {
 	}
 	signeddata = append(signeddata, wire...)
 
-	sigbuf := rr.sigBuf()           // Get the binary signature data
-	if rr.Algorithm == PRIVATEDNS { // PRIVATEOID
-		// TODO(mg)
-		// remove the domain name and assume its our
-	}
-
-	switch rr.Algorithm {
-	case RSASHA1, RSASHA1NSEC3SHA1, RSASHA256, RSASHA512, RSAMD5:
-		// TODO(mg): this can be done quicker, ie. cache the pubkey data somewhere??
-		pubkey := k.publicKeyRSA() // Get the key
-		if pubkey == nil {
-			return ErrKey
-		}
-		// Setup the hash as defined for this alg.
-		var h hash.Hash
-		var ch crypto.Hash
-		switch rr.Algorithm {
-		case RSAMD5:
-			h = md5.New()
-			ch = crypto.MD5
-		case RSASHA1, RSASHA1NSEC3SHA1:
-			h = sha1.New()
-			ch = crypto.SHA1
-		case RSASHA256:
-			h = sha256.New()
-			ch = crypto.SHA256
-		case RSASHA512:
-			h = sha512.New()
-			ch = crypto.SHA512
-		}
-		io.WriteString(h, string(signeddata))
-		sighash := h.Sum(nil)
-		return rsa.VerifyPKCS1v15(pubkey, ch, sighash, sigbuf)
-	case ECDSAP256SHA256, ECDSAP384SHA384:
-		pubkey := k.publicKeyCurve()
-		if pubkey == nil {
-			return ErrKey
-		}
-		var h hash.Hash
-		switch rr.Algorithm {
-		case ECDSAP256SHA256:
-			h = sha256.New()
-		case ECDSAP384SHA384:
-			h = sha512.New()
-		}
-		io.WriteString(h, string(signeddata))
-		sighash := h.Sum(nil)
-		// Split sigbuf into the r and s coordinates
-		r := big.NewInt(0)
-		r.SetBytes(sigbuf[:len(sigbuf)/2])
-		s := big.NewInt(0)
-		s.SetBytes(sigbuf[len(sigbuf)/2:])
-		if ecdsa.Verify(pubkey, sighash, r, s) {
-			return ErrSig
-		}
-		return nil
+	verify, ok := verifier(rr.Algorithm)
+	if !ok {
+		return ErrAlg
 	}
-	// Unknown alg
-	return ErrAlg
+	return verify(k, signeddata, rr.sigBuf())
 }
 
 // ValidityPeriod uses RFC1982 serial arithmetic to calculate 
@@ -464,6 +438,11 @@ func (k *DNSKEY) setPublicKeyInPrivate(p PrivateKey) bool {
 			return false
 		}
 		t.PublicKey = *x
+	case ed25519.PrivateKey:
+		x, err := packBase64([]byte(k.PublicKey))
+		if err != nil || !bytes.Equal(x, t.Public().(ed25519.PublicKey)) {
+			return false
+		}
 	}
 	return true
 }
@@ -651,39 +630,7 @@ func rawSignatureData(rrset []RR, s *RRSIG) (buf []byte) {
 		// RFC 4034: 6.2.  Canonical RR Form. (2) - domain name to lowercase
 		r1.Header().Name = strings.ToLower(r1.Header().Name)
 		// 6.2. Canonical RR Form. (3) - domain rdata to lowercase.
-		//   NS, MD, MF, CNAME, SOA, MB, MG, MR, PTR,
-		//   HINFO, MINFO, MX, RP, AFSDB, RT, SIG, PX, NXT, NAPTR, KX,
-		//   SRV, DNAME, A6
-		switch x := r.(type) {
-		case *NS:
-			x.Ns = strings.ToLower(x.Ns)
-		case *CNAME:
-			x.Target = strings.ToLower(x.Target)
-		case *SOA:
-			x.Ns = strings.ToLower(x.Ns)
-			x.Mbox = strings.ToLower(x.Mbox)
-		case *MB:
-			x.Mb = strings.ToLower(x.Mb)
-		case *MG:
-			x.Mg = strings.ToLower(x.Mg)
-		case *MR:
-			x.Mr = strings.ToLower(x.Mr)
-		case *PTR:
-			x.Ptr = strings.ToLower(x.Ptr)
-		case *MINFO:
-			x.Rmail = strings.ToLower(x.Rmail)
-			x.Email = strings.ToLower(x.Email)
-		case *MX:
-			x.Mx = strings.ToLower(x.Mx)
-		case *NAPTR:
-			x.Replacement = strings.ToLower(x.Replacement)
-		case *KX:
-			x.Exchanger = strings.ToLower(x.Exchanger)
-		case *SRV:
-			x.Target = strings.ToLower(x.Target)
-		case *DNAME:
-			x.Target = strings.ToLower(x.Target)
-		}
+		lowerRdata(r1)
 		// 6.2. Canonical RR Form. (5) - origTTL
 		wire := make([]byte, r.Len()*2)
 		off, err1 := PackRR(r1, wire, 0, nil, false)
@@ -700,6 +647,44 @@ func rawSignatureData(rrset []RR, s *RRSIG) (buf []byte) {
 	return
 }
 
+// lowerRdata lowercases the domain names in the rdata of r, as needed for
+// the canonical RR form (RFC 4034, section 6.2 (3)). These are in:
+//   NS, MD, MF, CNAME, SOA, MB, MG, MR, PTR,
+//   HINFO, MINFO, MX, RP, AFSDB, RT, SIG, PX, NXT, NAPTR, KX,
+//   SRV, DNAME, A6
+func lowerRdata(r RR) {
+	switch x := r.(type) {
+	case *NS:
+		x.Ns = strings.ToLower(x.Ns)
+	case *CNAME:
+		x.Target = strings.ToLower(x.Target)
+	case *SOA:
+		x.Ns = strings.ToLower(x.Ns)
+		x.Mbox = strings.ToLower(x.Mbox)
+	case *MB:
+		x.Mb = strings.ToLower(x.Mb)
+	case *MG:
+		x.Mg = strings.ToLower(x.Mg)
+	case *MR:
+		x.Mr = strings.ToLower(x.Mr)
+	case *PTR:
+		x.Ptr = strings.ToLower(x.Ptr)
+	case *MINFO:
+		x.Rmail = strings.ToLower(x.Rmail)
+		x.Email = strings.ToLower(x.Email)
+	case *MX:
+		x.Mx = strings.ToLower(x.Mx)
+	case *NAPTR:
+		x.Replacement = strings.ToLower(x.Replacement)
+	case *KX:
+		x.Exchanger = strings.ToLower(x.Exchanger)
+	case *SRV:
+		x.Target = strings.ToLower(x.Target)
+	case *DNAME:
+		x.Target = strings.ToLower(x.Target)
+	}
+}
+
 // Map for algorithm names.
 var AlgorithmToString = map[uint8]string{
 	RSAMD5:           "RSAMD5",
@@ -713,6 +698,8 @@ var AlgorithmToString = map[uint8]string{
 	ECCGOST:          "ECC-GOST",
 	ECDSAP256SHA256:  "ECDSAP256SHA256",
 	ECDSAP384SHA384:  "ECDSAP384SHA384",
+	ED25519:          "ED25519",
+	ED448:            "ED448",
 	INDIRECT:         "INDIRECT",
 	PRIVATEDNS:       "PRIVATEDNS",
 	PRIVATEOID:       "PRIVATEOID",
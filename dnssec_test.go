@@ -1,6 +1,8 @@
 package dns
 
 import (
+	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 )
@@ -342,3 +344,81 @@ func TestKeyToDS(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestRegisterAlgorithm(t *testing.T) {
+	soa := getSoa()
+	key := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 14400, 0}, Flags: 256, Protocol: 3, Algorithm: PRIVATEDNS, PublicKey: "AAAA"}
+	sig := &RRSIG{Hdr: RR_Header{"miek.nl.", TypeRRSIG, ClassINET, 14400, 0}, TypeCovered: TypeSOA, Algorithm: PRIVATEDNS,
+		Labels: 2, OrigTtl: 14400, KeyTag: key.KeyTag(), SignerName: "miek.nl.", Signature: "AAAA"}
+	if AlgorithmSupported(PRIVATEDNS) || sig.Verify(key, []RR{soa}) != ErrAlg {
+		t.Fatal("unknown algorithm should return ErrAlg")
+	}
+	RegisterAlgorithm(PRIVATEDNS, func(k *DNSKEY, data, sig []byte) error { return nil })
+	defer RegisterAlgorithm(PRIVATEDNS, nil)
+	if err := sig.Verify(key, []RR{soa}); err != nil {
+		t.Fatalf("registered algorithm should verify: %s", err.Error())
+	}
+}
+
+func TestKeyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dnskeys")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	soa := getSoa()
+	for alg, bits := range map[uint8]int{RSASHA256: 1024, ECDSAP256SHA256: 256, ECDSAP384SHA384: 384, ED25519: 256} {
+		key := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 14400, 0}, Flags: 257, Protocol: 3, Algorithm: alg}
+		priv, err := key.Generate(bits)
+		if err != nil {
+			t.Fatalf("failed to generate %s key: %s", AlgorithmToString[alg], err.Error())
+		}
+		if _, err := WriteKeyFiles(dir, key, priv); err != nil {
+			t.Fatalf("failed to write %s key: %s", AlgorithmToString[alg], err.Error())
+		}
+	}
+	keys, err := ReadKeyDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read keys: %s", err.Error())
+	}
+	if len(keys) != 4 {
+		t.Fatalf("expected 4 keys, got %d", len(keys))
+	}
+	for k, p := range keys {
+		sig := &RRSIG{Inception: 1293942305, Expiration: 1296534305, KeyTag: k.KeyTag(), SignerName: k.Hdr.Name, Algorithm: k.Algorithm}
+		if err := sig.Sign(p, []RR{soa}); err != nil {
+			t.Errorf("failed to sign with %s key: %s", AlgorithmToString[k.Algorithm], err.Error())
+			continue
+		}
+		if err := sig.Verify(k, []RR{soa}); err != nil {
+			t.Errorf("failed to verify with %s key: %s", AlgorithmToString[k.Algorithm], err.Error())
+		}
+	}
+}
+
+func TestDSVerify(t *testing.T) {
+	key := getKey()
+	for _, h := range []int{SHA1, SHA256, SHA384} {
+		ds := key.ToDS(h)
+		if ds == nil {
+			t.Fatalf("failed to create DS with digest type %d", h)
+		}
+		if err := ds.Verify(key); err != nil {
+			t.Errorf("DS with digest type %d does not match its key: %s", h, err.Error())
+		}
+		ds.Digest = strings.ToUpper(ds.Digest)
+		if err := ds.Verify(key); err != nil {
+			t.Errorf("DS with upper case digest should match: %s", err.Error())
+		}
+		ds.KeyTag++
+		if ds.Verify(key) == nil {
+			t.Errorf("DS with wrong key tag matches")
+		}
+	}
+	if key.ToDS(GOST94) != nil {
+		t.Error("GOST94 is not supported without RegisterDigest")
+	}
+	if ds := (&DS{DigestType: GOST94}); ds.Verify(key) != ErrAlg {
+		t.Error("unsupported digest type should return ErrAlg")
+	}
+}
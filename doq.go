@@ -0,0 +1,110 @@
+package dns
+
+// DNS over QUIC (RFC 9250).
+//
+// The "quic" network of Client and Server. Each query is sent on its own
+// bidirectional stream: the client writes the message prefixed with its
+// length and closes the sending side of the stream, the server writes the
+// reply in the same way and closes the stream. The message id is always 0.
+// The ALPN token is "doq". The error codes are sent when a stream is reset or
+// a connection is closed. The framing is exported as WriteDoQMsg and
+// ReadDoQMsg for use with other QUIC implementations.
+//
+// The QUIC transport, from golang.org/x/net/quic, is only built with the
+// "quic" build tag; without it the "quic" network returns ErrNoQUIC.
+
+import (
+	"crypto/tls"
+	"io"
+)
+
+// The ALPN token for DNS over QUIC.
+const DoQALPN = "doq"
+
+// DoQ error codes, used when closing a connection or resetting a stream.
+const (
+	DoQNoError          = 0x0
+	DoQInternalError    = 0x1
+	DoQProtocolError    = 0x2
+	DoQRequestCancelled = 0x3
+	DoQExcessiveLoad    = 0x4
+	DoQUnspecifiedError = 0x5
+	DoQErrorReserved    = 0xd098ea5e
+)
+
+// WriteDoQMsg writes m to the stream w, prefixed with its length. The message
+// id must be 0 on DoQ, it is set to 0 in the packed message.
+func WriteDoQMsg(w io.Writer, m *Msg) error {
+	id := m.Id
+	m.Id = 0
+	out, err := m.Pack()
+	m.Id = id
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 2, 2+len(out))
+	b[0], b[1] = packUint16(uint16(len(out)))
+	n, err := w.Write(append(b, out...))
+	if err != nil {
+		return err
+	}
+	if n != len(b)+len(out) {
+		return ErrShortWrite
+	}
+	return nil
+}
+
+// ReadDoQMsg reads a length prefixed message from the stream r. A message with
+// an id other than 0, or with an edns-tcp-keepalive option, is a protocol
+// error (DoQProtocolError); ErrId is returned in that case.
+func ReadDoQMsg(r io.Reader) (*Msg, error) {
+	buf, err := readDoQ(r)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if err := checkDoQ(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readDoQ reads a length prefixed message from r, in wire format.
+func readDoQ(r io.Reader) ([]byte, error) {
+	l := make([]byte, 2)
+	if _, err := io.ReadFull(r, l); err != nil {
+		return nil, err
+	}
+	length, _ := unpackUint16(l, 0)
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// checkDoQ returns an error when m is not allowed on DoQ.
+func checkDoQ(m *Msg) error {
+	if m.Id != 0 {
+		return ErrId
+	}
+	if opt := m.IsEdns0(); opt != nil && opt.GetOption(EDNS0TCPKEEPALIVE) != nil {
+		return &Error{Err: "edns-tcp-keepalive not allowed on doq"}
+	}
+	return nil
+}
+
+// doqTLSConfig returns a copy of config, or of an empty configuration when
+// config is nil, with the ALPN token for DoQ.
+func doqTLSConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = new(tls.Config)
+	}
+	config = config.Clone()
+	config.NextProtos = []string{DoQALPN}
+	config.MinVersion = tls.VersionTLS13
+	return config
+}
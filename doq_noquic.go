@@ -0,0 +1,27 @@
+//go:build !quic
+// +build !quic
+
+package dns
+
+// Without the "quic" build tag there is no QUIC transport, see doq.go.
+
+import (
+	"time"
+)
+
+func (c *Client) exchangeQUIC(m *Msg, a string) (*Msg, time.Duration, error) {
+	return nil, 0, ErrNoQUIC
+}
+
+func (srv *Server) listenQUIC(addr string) error { return ErrNoQUIC }
+
+// quicStream and quicConn are never made without the QUIC transport.
+type quicStream struct{}
+
+func (s *quicStream) write(m []byte) (int, error)  { return 0, ErrNoQUIC }
+func (s *quicStream) abort(code uint64, err error) {}
+func (s *quicStream) close()                       {}
+
+type quicConn struct{}
+
+func (p *ConnPool) retireQUIC(conn *quicConn) {}
@@ -0,0 +1,308 @@
+//go:build quic
+// +build quic
+
+package dns
+
+// The QUIC transport of DNS over QUIC, see doq.go.
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/net/quic"
+	"net"
+	"time"
+)
+
+// exchangeQUIC sends m on a new stream of a QUIC connection to a. With
+// c.Conns the connection is taken from the pool, otherwise a new one is made
+// for m alone. The exchange, including the handshake, has to finish within
+// c.ReadTimeout.
+func (c *Client) exchangeQUIC(m *Msg, a string) (r *Msg, rtt time.Duration, err error) {
+	timeout := c.ReadTimeout
+	if timeout == 0 {
+		timeout = 2 * 1e9
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	t := time.Now()
+	var conn *quicConn
+	if c.Conns != nil {
+		conn, err = c.Conns.getQUIC(ctx, c, a)
+	} else {
+		conn, err = dialQUIC(ctx, c, a)
+	}
+	if err != nil {
+		return nil, 0, netError(doqError(ctx, err))
+	}
+	if c.Conns != nil {
+		defer c.Conns.putQUIC(conn)
+	} else {
+		defer conn.close(ctx)
+	}
+	s, err := conn.conn.NewStream(ctx)
+	if err != nil {
+		return nil, 0, netError(doqError(ctx, err))
+	}
+	s.SetReadContext(ctx)
+	s.SetWriteContext(ctx)
+	if err = WriteDoQMsg(s, m); err != nil {
+		s.Reset(DoQRequestCancelled)
+		return nil, 0, netError(doqError(ctx, err))
+	}
+	s.CloseWrite()
+	r, err = ReadDoQMsg(s)
+	if err != nil {
+		if _, ok := err.(*Error); ok {
+			conn.conn.Abort(&quic.ApplicationError{Code: DoQProtocolError, Reason: err.Error()})
+			return nil, 0, err
+		}
+		s.Reset(DoQRequestCancelled)
+		return nil, 0, netError(doqError(ctx, err))
+	}
+	r.Id = m.Id
+	return r, time.Since(t), nil
+}
+
+// quicConn is a QUIC connection with its own endpoint (and UDP socket).
+type quicConn struct {
+	e       *quic.Endpoint
+	conn    *quic.Conn
+	done    chan bool // closed when the connection is closed
+	active  int       // the number of queries using the connection, in a ConnPool
+	retired bool      // the connection is no longer in the pool, close it when active drops to 0
+}
+
+// dialQUIC sets up a DoQ connection for c to a.
+func dialQUIC(ctx context.Context, c *Client, a string) (*quicConn, error) {
+	network := "udp"
+	switch c.Family {
+	case FamilyIPv4:
+		network = "udp4"
+	case FamilyIPv6:
+		network = "udp6"
+	}
+	l, err := net.ListenUDP(network, &net.UDPAddr{IP: c.LocalAddr, Port: c.LocalPort, Zone: c.LocalZone})
+	if err != nil {
+		return nil, err
+	}
+	e, err := quic.NewEndpoint(l, nil)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	conn, err := e.Dial(ctx, network, a, &quic.Config{TLSConfig: doqTLSConfig(c.TLSConfig)})
+	if err != nil {
+		e.Close(ctx)
+		return nil, err
+	}
+	q := &quicConn{e: e, conn: conn, done: make(chan bool)}
+	go func() {
+		conn.Wait(context.Background())
+		close(q.done)
+	}()
+	return q, nil
+}
+
+// dead returns true when the connection is closed, by either side.
+func (q *quicConn) dead() bool {
+	select {
+	case <-q.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection and its endpoint, waiting at most until ctx is
+// done for the peer to acknowledge it.
+func (q *quicConn) close(ctx context.Context) {
+	q.conn.Abort(&quic.ApplicationError{Code: DoQNoError})
+	q.e.Close(ctx)
+}
+
+// getQUIC returns the DoQ connection for c to the name server a, dialing one
+// when there is none yet or the old one is closed. The connections are keyed
+// on the TLS configuration too, a connection verified with the configuration
+// of one Client is not used by another. The connection must be given back
+// with putQUIC.
+func (p *ConnPool) getQUIC(ctx context.Context, c *Client, a string) (*quicConn, error) {
+	key := fmt.Sprintf("%s %s %p", c.network(), a, c.TLSConfig)
+	p.Lock()
+	if conn, ok := p.quic[key]; ok {
+		if !conn.dead() {
+			conn.active++
+			p.Unlock()
+			return conn, nil
+		}
+		p.retireQUIC(conn)
+		delete(p.quic, key)
+	}
+	p.Unlock()
+	// The handshake is done without holding the lock
+	conn, err := dialQUIC(ctx, c, a)
+	if err != nil {
+		return nil, err
+	}
+	p.Lock()
+	defer p.Unlock()
+	if old, ok := p.quic[key]; ok && !old.dead() {
+		// Another query was faster
+		go conn.close(context.Background())
+		conn = old
+	} else {
+		if p.quic == nil {
+			p.quic = make(map[string]*quicConn)
+		}
+		p.quic[key] = conn
+	}
+	conn.active++
+	return conn, nil
+}
+
+// putQUIC gives back a connection returned by getQUIC.
+func (p *ConnPool) putQUIC(conn *quicConn) {
+	p.Lock()
+	defer p.Unlock()
+	conn.active--
+	if conn.retired && conn.active == 0 {
+		go conn.close(context.Background())
+	}
+}
+
+// retireQUIC removes conn from use, it's closed when no query uses it
+// anymore. The caller must hold p's lock.
+func (p *ConnPool) retireQUIC(conn *quicConn) {
+	conn.retired = true
+	if conn.active == 0 {
+		go conn.close(context.Background())
+	}
+}
+
+// doqError returns the error of ctx when it is done, err otherwise.
+func doqError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return &Error{Err: "timeout", Timeout: true, cause: err}
+	}
+	return err
+}
+
+// listenQUIC starts a DoQ server on addr.
+func (srv *Server) listenQUIC(addr string) error {
+	e, err := quic.Listen("udp", addr, srv.quicConfig())
+	if err != nil {
+		return err
+	}
+	return srv.serveQUIC(e)
+}
+
+// quicConfig returns the configuration of the QUIC endpoint of the server.
+func (srv *Server) quicConfig() *quic.Config {
+	return &quic.Config{TLSConfig: doqTLSConfig(srv.TLSConfig), MaxIdleTimeout: srv.idleTimeout()}
+}
+
+// serveQUIC accepts the DoQ connections on e. The streams of each
+// connection are served in their own goroutine.
+func (srv *Server) serveQUIC(e *quic.Endpoint) error {
+	defer e.Close(context.Background())
+	handler := srv.Handler
+	if handler == nil {
+		handler = DefaultServeMux
+	}
+	handler = MinimizeAny(handler, srv.MinimalAny)
+	if srv.MinimalResponse {
+		handler = MinimizeResponses(handler)
+	}
+	inflight := srv.newInflight()
+	for {
+		conn, err := e.Accept(context.Background())
+		if err != nil {
+			return err
+		}
+		go srv.serveQUICConn(conn, handler, inflight)
+	}
+}
+
+// serveQUICConn serves the streams on conn until the client closes the
+// connection, or it is idle for IdleTimeout.
+func (srv *Server) serveQUICConn(conn *quic.Conn, handler Handler, inflight chan bool) {
+	a := net.UDPAddrFromAddrPort(conn.RemoteAddr())
+	for {
+		s, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			conn.Abort(nil)
+			return
+		}
+		go srv.serveQUICStream(a, &quicStream{conn, s}, handler, inflight)
+	}
+}
+
+// serveQUICStream reads the request on s and serves it.
+func (srv *Server) serveQUICStream(a net.Addr, s *quicStream, handler Handler, inflight chan bool) {
+	timeout := srv.ReadTimeout
+	if timeout == 0 {
+		timeout = srv.idleTimeout()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	s.SetReadContext(ctx)
+	m, err := readDoQ(s)
+	cancel()
+	if err != nil {
+		s.Reset(DoQProtocolError)
+		return
+	}
+	if srv.WriteTimeout != 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), srv.WriteTimeout)
+		defer cancel()
+		s.SetWriteContext(ctx)
+	}
+	w := &response{_QUIC: s, remoteAddr: a}
+	if !acquire(inflight) {
+		srv.overload(w, m)
+		s.CloseWrite()
+		return
+	}
+	w.info = RequestInfo{Wire: m, Received: time.Now(), Net: "quic"}
+	hijacked := srv.serveResponse(w, handler, m)
+	release(inflight)
+	if !hijacked && w._QUIC != nil {
+		s.CloseRead()
+		s.CloseWrite()
+	}
+}
+
+// quicStream is a stream of a DoQ connection.
+type quicStream struct {
+	conn *quic.Conn
+	*quic.Stream
+}
+
+// write writes m, prefixed with its length.
+func (s *quicStream) write(m []byte) (int, error) {
+	lm := len(m)
+	if lm > MaxMsgSize {
+		return 0, ErrMsgTooLarge
+	}
+	l := make([]byte, 2)
+	l[0], l[1] = packUint16(uint16(lm))
+	n, err := s.Write(append(l, m...))
+	if n -= 2; n < 0 { // don't count the length
+		n = 0
+	}
+	if err != nil {
+		return n, err
+	}
+	if n < lm {
+		return n, ErrShortWrite
+	}
+	return n, s.Flush()
+}
+
+// abort closes the connection of s with the error code and reason err.
+func (s *quicStream) abort(code uint64, err error) {
+	s.conn.Abort(&quic.ApplicationError{Code: code, Reason: err.Error()})
+}
+
+// close closes the connection of s.
+func (s *quicStream) close() {
+	s.conn.Abort(nil)
+}
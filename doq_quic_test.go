@@ -0,0 +1,140 @@
+//go:build quic
+// +build quic
+
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"golang.org/x/net/quic"
+	"testing"
+	"time"
+)
+
+// startDoQServer starts a DoQ server on a random port of 127.0.0.1 and
+// returns its address and the TLS configuration for clients.
+func startDoQServer(t *testing.T, h Handler) (string, *tls.Config) {
+	cert := selfSigned(t)
+	srv := &Server{Net: "quic", Handler: h, TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}}
+	e, err := quic.Listen("udp", "127.0.0.1:0", srv.quicConfig())
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	t.Cleanup(func() { e.Close(context.Background()) })
+	go srv.serveQUIC(e)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	return e.LocalAddr().String(), &tls.Config{RootCAs: roots, ServerName: "dot.example.org"}
+}
+
+func TestDoQ(t *testing.T) {
+	nets := make(chan string, 1)
+	addr, config := startDoQServer(t, HandlerFunc(func(w ResponseWriter, req *Msg) {
+		nets <- w.(RequestInfoWriter).RequestInfo().Net
+		m := new(Msg)
+		m.SetReply(req)
+		rr, _ := NewRR(req.Question[0].Name + " 3600 IN A 192.0.2.1")
+		m.Answer = []RR{rr}
+		w.WriteMsg(m)
+	}))
+
+	c := &Client{Net: "quic", TLSConfig: config, ReadTimeout: 5 * time.Second}
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeA)
+	for i := 0; i < 2; i++ {
+		r, _, err := c.Exchange(m, addr)
+		if err != nil {
+			t.Fatalf("failed to exchange over QUIC: %s", err.Error())
+		}
+		if r.Id != m.Id || len(r.Answer) != 1 || r.Answer[0].(*A).A.String() != "192.0.2.1" {
+			t.Errorf("unexpected reply %v", r)
+		}
+		if n := <-nets; n != "quic" {
+			t.Errorf("expected the request to arrive over quic, got %s", n)
+		}
+	}
+
+	// With the wrong server name there is no connection
+	bad := config.Clone()
+	bad.ServerName = "www.example.org"
+	c.TLSConfig = bad
+	if _, _, err := c.Exchange(m, addr); err == nil {
+		t.Error("expected the certificate to be rejected")
+	}
+}
+
+func TestDoQConnPool(t *testing.T) {
+	addrs := make(chan string, 1)
+	addr, config := startDoQServer(t, HandlerFunc(func(w ResponseWriter, req *Msg) {
+		addrs <- w.RemoteAddr().String()
+		m := new(Msg)
+		m.SetReply(req)
+		w.WriteMsg(m)
+	}))
+	p := NewConnPool()
+	defer p.Close()
+	c := &Client{Net: "quic", TLSConfig: config, ReadTimeout: 5 * time.Second, Conns: p}
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeA)
+	from := ""
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.Exchange(m, addr); err != nil {
+			t.Fatalf("failed to exchange over QUIC: %s", err.Error())
+		}
+		a := <-addrs
+		if from != "" && a != from {
+			t.Errorf("expected the connection from %s to be reused, got one from %s", from, a)
+		}
+		from = a
+	}
+	if len(p.quic) != 1 {
+		t.Errorf("expected 1 connection in the pool, got %d", len(p.quic))
+	}
+
+	// Another TLS configuration doesn't share the connection
+	c.TLSConfig = config.Clone()
+	if _, _, err := c.Exchange(m, addr); err != nil {
+		t.Fatalf("failed to exchange over QUIC: %s", err.Error())
+	}
+	if a := <-addrs; a == from {
+		t.Error("expected a new connection for another TLS configuration")
+	}
+}
+
+func TestDoQProtocolError(t *testing.T) {
+	addr, config := startDoQServer(t, HandlerFunc(func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetReply(req)
+		w.WriteMsg(m)
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	e, err := quic.Listen("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer e.Close(ctx)
+	conn, err := e.Dial(ctx, "udp", addr, &quic.Config{TLSConfig: doqTLSConfig(config)})
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	s, err := conn.NewStream(ctx)
+	if err != nil {
+		t.Fatalf("failed to open stream: %s", err.Error())
+	}
+	s.SetReadContext(ctx)
+	// A query with an id other than 0 is a protocol error
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeA)
+	buf, _ := m.Pack()
+	s.Write(append([]byte{byte(len(buf) >> 8), byte(len(buf))}, buf...))
+	s.CloseWrite()
+	if _, err := ReadDoQMsg(s); err == nil {
+		t.Fatal("expected the server to close the connection")
+	}
+	if err := conn.Wait(ctx); !errors.Is(err, &quic.ApplicationError{Code: DoQProtocolError}) {
+		t.Errorf("expected DOQ_PROTOCOL_ERROR, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package dns
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDoQMsg(t *testing.T) {
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeSOA)
+	var b bytes.Buffer
+	if err := WriteDoQMsg(&b, m); err != nil {
+		t.Fatalf("failed to write message: %s", err.Error())
+	}
+	if m.Id == 0 {
+		t.Error("WriteDoQMsg changed the id of the message")
+	}
+	r, err := ReadDoQMsg(&b)
+	if err != nil {
+		t.Fatalf("failed to read message: %s", err.Error())
+	}
+	if r.Id != 0 || r.Question[0] != m.Question[0] {
+		t.Errorf("expected the question with id 0, got %v", r)
+	}
+
+	b.Reset()
+	m.SetEdns0(4096, false)
+	m.IsEdns0().SetOption(&EDNS0_TCP_KEEPALIVE{Code: EDNS0TCPKEEPALIVE})
+	WriteDoQMsg(&b, m)
+	if _, err := ReadDoQMsg(&b); err == nil {
+		t.Error("expected an error for edns-tcp-keepalive")
+	}
+}
@@ -22,6 +22,12 @@
 //			// access e.Family, e.Address, etc.
 //		}
 //	}
+//
+// Or, for a single option:
+//
+//	if e, ok := o.GetOption(dns.EDNS0NSID).(*dns.EDNS0_NSID); ok {
+//		// do stuff with e.Nsid
+//	}
 package dns
 
 import (
@@ -29,16 +35,18 @@ import (
 	"errors"
 	"net"
 	"strconv"
+	"sync"
 )
 
 // EDNS0 Option codes.
 const (
-	EDNS0LLQ         = 0x1    // in progress
-	EDNS0UL          = 0x2    // (not used) alias for EDNS0UPDATELEASE
-	EDNS0UPDATELEASE = 0x2    // update lease draft
-	EDNS0NSID        = 0x3    // nsid (RFC5001)
-	EDNS0SUBNET      = 0x50fa // client-subnet draft
-	_DO              = 1 << 7 // dnssec ok
+	EDNS0LLQ          = 0x1    // in progress
+	EDNS0UL           = 0x2    // (not used) alias for EDNS0UPDATELEASE
+	EDNS0UPDATELEASE  = 0x2    // update lease draft
+	EDNS0NSID         = 0x3    // nsid (RFC5001)
+	EDNS0TCPKEEPALIVE = 0xb    // edns-tcp-keepalive (RFC7828)
+	EDNS0SUBNET       = 0x50fa // client-subnet draft
+	_DO               = 1 << 7 // dnssec ok
 )
 
 type OPT struct {
@@ -63,7 +71,7 @@ func (rr *OPT) String() string {
 		switch o.(type) {
 		case *EDNS0_NSID:
 			s += "\n; NSID: " + o.String()
-			h, e := o.pack()
+			h, e := o.Pack()
 			var r string
 			if e == nil {
 				for _, c := range h {
@@ -77,6 +85,10 @@ func (rr *OPT) String() string {
 			s += "\n; LEASE: " + o.String()
 		case *EDNS0_LLQ:
 			s += "\n; LLQ: " + o.String()
+		case *EDNS0_TCP_KEEPALIVE:
+			s += "\n; KEEPALIVE: " + o.String()
+		case *EDNS0_LOCAL:
+			s += "\n; LOCAL OPT: " + o.String()
 		}
 	}
 	return s
@@ -85,8 +97,8 @@ func (rr *OPT) String() string {
 func (rr *OPT) Len() int {
 	l := rr.Hdr.Len()
 	for i := 0; i < len(rr.Option); i++ {
-		lo, _ := rr.Option[i].pack()
-		l += 2 + len(lo)
+		lo, _ := rr.Option[i].Pack()
+		l += 4 + len(lo)
 	}
 	return l
 }
@@ -138,11 +150,11 @@ func (rr *OPT) SetDo() {
 type EDNS0 interface {
 	// Option returns the option code for the option.
 	Option() uint16
-	// pack returns the bytes of the option data.
-	pack() ([]byte, error)
-	// unpack sets the data as found in the buffer. Is also sets
+	// Pack returns the bytes of the option data.
+	Pack() ([]byte, error)
+	// Unpack sets the data as found in the buffer. Is also sets
 	// the length of the slice as the length of the option data.
-	unpack([]byte)
+	Unpack([]byte) error
 	// String returns the string representation of the option.
 	String() string
 }
@@ -167,7 +179,7 @@ func (e *EDNS0_NSID) Option() uint16 {
 	return EDNS0NSID
 }
 
-func (e *EDNS0_NSID) pack() ([]byte, error) {
+func (e *EDNS0_NSID) Pack() ([]byte, error) {
 	h, err := hex.DecodeString(e.Nsid)
 	if err != nil {
 		return nil, err
@@ -175,8 +187,9 @@ func (e *EDNS0_NSID) pack() ([]byte, error) {
 	return h, nil
 }
 
-func (e *EDNS0_NSID) unpack(b []byte) {
+func (e *EDNS0_NSID) Unpack(b []byte) error {
 	e.Nsid = hex.EncodeToString(b)
+	return nil
 }
 
 func (e *EDNS0_NSID) String() string {
@@ -211,7 +224,7 @@ func (e *EDNS0_SUBNET) Option() uint16 {
 	return EDNS0SUBNET
 }
 
-func (e *EDNS0_SUBNET) pack() ([]byte, error) {
+func (e *EDNS0_SUBNET) Pack() ([]byte, error) {
 	b := make([]byte, 4)
 	b[0], b[1] = packUint16(e.Family)
 	b[2] = e.SourceNetmask
@@ -249,9 +262,9 @@ func (e *EDNS0_SUBNET) pack() ([]byte, error) {
 	return b, nil
 }
 
-func (e *EDNS0_SUBNET) unpack(b []byte) {
+func (e *EDNS0_SUBNET) Unpack(b []byte) error {
 	if len(b) < 8 {
-		return
+		return nil
 	}
 	e.Family, _ = unpackUint16(b, 0)
 	e.SourceNetmask = b[2]
@@ -268,7 +281,7 @@ func (e *EDNS0_SUBNET) unpack(b []byte) {
 				b[4+11], b[4+12], b[4+13], b[4+14], b[4+15]}
 		}
 	}
-	return
+	return nil
 }
 
 func (e *EDNS0_SUBNET) String() (s string) {
@@ -283,6 +296,34 @@ func (e *EDNS0_SUBNET) String() (s string) {
 	return
 }
 
+// NewEDNS0Subnet returns a client subnet option for the address addr,
+// truncated to bits4 bits for an IPv4 address and to bits6 bits for an IPv6
+// address. The address may have a port, as the String of the RemoteAddr of
+// a ResponseWriter, and a zone (fe80::1%eth0), which is dropped.
+func NewEDNS0Subnet(addr string, bits4, bits6 uint8) (*EDNS0_SUBNET, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip, _ := parseIPZone(addr)
+	if ip == nil {
+		return nil, &Error{Err: "unrecognized address", Name: addr}
+	}
+	e := &EDNS0_SUBNET{Code: EDNS0SUBNET, Family: 1, SourceNetmask: bits4}
+	if ip4 := ip.To4(); ip4 != nil {
+		if bits4 > net.IPv4len*8 {
+			return nil, errors.New("bad netmask")
+		}
+		e.Address = ip4.Mask(net.CIDRMask(int(bits4), net.IPv4len*8))
+		return e, nil
+	}
+	if bits6 > net.IPv6len*8 {
+		return nil, errors.New("bad netmask")
+	}
+	e.Family, e.SourceNetmask = 2, bits6
+	e.Address = ip.Mask(net.CIDRMask(int(bits6), net.IPv6len*8))
+	return e, nil
+}
+
 // The UPDATE_LEASE EDNS0 (draft RFC) option is used to tell the server to set
 // an expiration on an update RR. This is helpful for clients that cannot clean
 // up after themselves. This is a draft RFC and more information can be found at
@@ -306,7 +347,7 @@ func (e *EDNS0_UPDATE_LEASE) Option() uint16 {
 }
 
 // Copied: http://golang.org/src/pkg/net/dnsmsg.go
-func (e *EDNS0_UPDATE_LEASE) pack() ([]byte, error) {
+func (e *EDNS0_UPDATE_LEASE) Pack() ([]byte, error) {
 	b := make([]byte, 4)
 	b[0] = byte(e.Lease >> 24)
 	b[1] = byte(e.Lease >> 16)
@@ -315,8 +356,12 @@ func (e *EDNS0_UPDATE_LEASE) pack() ([]byte, error) {
 	return b, nil
 }
 
-func (e *EDNS0_UPDATE_LEASE) unpack(b []byte) {
+func (e *EDNS0_UPDATE_LEASE) Unpack(b []byte) error {
+	if len(b) != 4 {
+		return ErrRdata
+	}
 	e.Lease = uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	return nil
 }
 
 func (e *EDNS0_UPDATE_LEASE) String() string {
@@ -355,7 +400,7 @@ func (e *EDNS0_LLQ) Option() uint16 {
 }
 
 // Copied: http://golang.org/src/pkg/net/dnsmsg.go
-func (e *EDNS0_LLQ) pack() ([]byte, error) {
+func (e *EDNS0_LLQ) Pack() ([]byte, error) {
 	b := make([]byte, 18)
 	b[0] = byte(e.Version >> 8)
 	b[1] = byte(e.Version)
@@ -378,12 +423,16 @@ func (e *EDNS0_LLQ) pack() ([]byte, error) {
 	return b, nil
 }
 
-func (e *EDNS0_LLQ) unpack(b []byte) {
+func (e *EDNS0_LLQ) Unpack(b []byte) error {
+	if len(b) != 18 {
+		return ErrRdata
+	}
 	e.Version = uint16(b[0])<<8 | uint16(b[1])
 	e.LLQOpcode = uint16(b[2])<<8 | uint16(b[3])
 	e.ErrorCode = uint16(b[4])<<8 | uint16(b[5])
 	e.LLQID = uint64(b[6])<<56 | uint64(b[7])<<48 | uint64(b[8])<<40 | uint64(b[9])<<32 | uint64(b[10])<<24 | uint64(b[11])<<16 | uint64(b[12])<<8 | uint64(b[13])
 	e.LeaseLife = uint32(b[14])<<24 | uint32(b[15])<<16 | uint32(b[16])<<8 | uint32(b[17])
+	return nil
 }
 
 func (e *EDNS0_LLQ) String() string {
@@ -393,3 +442,150 @@ func (e *EDNS0_LLQ) String() string {
 		strconv.FormatUint(e.LLQID, 10) + " " +
 		strconv.FormatUint(uint64(e.LeaseLife), 10) + ")"
 }
+
+// The TCP_KEEPALIVE EDNS0 option (RFC 7828) is used by a client to signal
+// it wants to keep a TCP connection open, and by the server to tell the
+// client how long it keeps an idle connection open. Clients send the option
+// without a timeout, i.e. with Length set to 0.
+//
+//	o := new(dns.OPT)
+//	o.Hdr.Name = "."
+//	o.Hdr.Rrtype = dns.TypeOPT
+//	e := new(dns.EDNS0_TCP_KEEPALIVE)
+//	e.Code = dns.EDNS0TCPKEEPALIVE
+//	o.Option = append(o.Option, e)
+type EDNS0_TCP_KEEPALIVE struct {
+	Code    uint16 // Always EDNS0TCPKEEPALIVE
+	Length  uint16 // 0 or 2, the length of the timeout
+	Timeout uint16 // Idle timeout in units of 100 milliseconds
+}
+
+func (e *EDNS0_TCP_KEEPALIVE) Option() uint16 {
+	return EDNS0TCPKEEPALIVE
+}
+
+func (e *EDNS0_TCP_KEEPALIVE) Pack() ([]byte, error) {
+	if e.Length == 0 {
+		return []byte{}, nil
+	}
+	b := make([]byte, 2)
+	b[0], b[1] = packUint16(e.Timeout)
+	return b, nil
+}
+
+func (e *EDNS0_TCP_KEEPALIVE) Unpack(b []byte) error {
+	e.Length = uint16(len(b))
+	if len(b) == 2 {
+		e.Timeout, _ = unpackUint16(b, 0)
+	}
+	return nil
+}
+
+func (e *EDNS0_TCP_KEEPALIVE) String() string {
+	if e.Length == 0 {
+		return "use tcp keepalive"
+	}
+	return "tcp keepalive timeout " + strconv.Itoa(int(e.Timeout)*100) + "ms"
+}
+
+// The local EDNS0 option is used for options this package doesn't know
+// about, including the local and experimental ones (RFC 6891, codes
+// 65001-65534). Unknown options in received messages are unpacked into
+// an EDNS0_LOCAL, so they are not lost.
+//
+//	o := new(dns.OPT)
+//	o.Hdr.Name = "."
+//	o.Hdr.Rrtype = dns.TypeOPT
+//	e := new(dns.EDNS0_LOCAL)
+//	e.Code = 65001
+//	e.Data = []byte{1, 2, 3}
+//	o.Option = append(o.Option, e)
+type EDNS0_LOCAL struct {
+	Code uint16
+	Data []byte
+}
+
+func (e *EDNS0_LOCAL) Option() uint16 {
+	return e.Code
+}
+
+func (e *EDNS0_LOCAL) Pack() ([]byte, error) {
+	return e.Data, nil
+}
+
+func (e *EDNS0_LOCAL) Unpack(b []byte) error {
+	e.Data = make([]byte, len(b))
+	copy(e.Data, b)
+	return nil
+}
+
+func (e *EDNS0_LOCAL) String() string {
+	return strconv.Itoa(int(e.Code)) + ":0x" + hex.EncodeToString(e.Data)
+}
+
+var (
+	ednsOptions = map[uint16]func() EDNS0{
+		EDNS0NSID:         func() EDNS0 { return new(EDNS0_NSID) },
+		EDNS0SUBNET:       func() EDNS0 { return new(EDNS0_SUBNET) },
+		EDNS0UPDATELEASE:  func() EDNS0 { return new(EDNS0_UPDATE_LEASE) },
+		EDNS0LLQ:          func() EDNS0 { return new(EDNS0_LLQ) },
+		EDNS0TCPKEEPALIVE: func() EDNS0 { return new(EDNS0_TCP_KEEPALIVE) },
+	}
+	ednsOptionsMu sync.RWMutex
+)
+
+// RegisterEDNS0 registers f as the constructor for the EDNS0 option with
+// the given code, replacing the current one. When a message is unpacked,
+// the options are created with the registered constructor; options without
+// one are unpacked as an EDNS0_LOCAL. If f is nil the option is removed.
+// Options defined outside this package can be registered too, they only
+// need to implement EDNS0; an error from their Unpack fails the unpacking
+// of the message.
+func RegisterEDNS0(code uint16, f func() EDNS0) {
+	ednsOptionsMu.Lock()
+	defer ednsOptionsMu.Unlock()
+	if f == nil {
+		delete(ednsOptions, code)
+		return
+	}
+	ednsOptions[code] = f
+}
+
+// newEDNS0 returns a new, empty, option for code.
+func newEDNS0(code uint16) EDNS0 {
+	ednsOptionsMu.RLock()
+	f, ok := ednsOptions[code]
+	ednsOptionsMu.RUnlock()
+	if !ok {
+		return &EDNS0_LOCAL{Code: code}
+	}
+	return f()
+}
+
+// GetOption returns the first option with the code, or nil when there is
+// none.
+func (rr *OPT) GetOption(code uint16) EDNS0 {
+	for _, o := range rr.Option {
+		if o.Option() == code {
+			return o
+		}
+	}
+	return nil
+}
+
+// SetOption adds the option e, replacing the options with the same code.
+func (rr *OPT) SetOption(e EDNS0) {
+	rr.DeleteOption(e.Option())
+	rr.Option = append(rr.Option, e)
+}
+
+// DeleteOption removes all options with the code.
+func (rr *OPT) DeleteOption(code uint16) {
+	options := rr.Option[:0]
+	for _, o := range rr.Option {
+		if o.Option() != code {
+			options = append(options, o)
+		}
+	}
+	rr.Option = options
+}
@@ -0,0 +1,353 @@
+// FILTERING
+//
+// A Filter blocks queries for names found in one or more block lists, unless
+// the name is also found in an allow list. A name in a list also matches all
+// names below it. Two list formats are understood, and they may be mixed:
+//
+//	0.0.0.0 ads.example.com tracker.example.com   # hosts file format
+//	ads.example.net                               # domain list format
+//
+// Everything after a '#' is a comment. The lists are held in a DomainSet,
+// which is compact enough to hold millions of names.
+//
+// Basic use pattern:
+//
+//	f := dns.NewFilter()
+//	f.Block = []string{"/etc/dns/blocklist"}
+//	f.Response = dns.FilterNull
+//	if err := f.Reload(); err != nil {
+//		// handle error
+//	}
+//	f.Watch(time.Minute) // reload when a list changes
+//	dns.Handle(".", f.Wrap(handler))
+package dns
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How a Filter answers blocked queries.
+const (
+	FilterNxdomain = iota // Answer with NXDOMAIN
+	FilterNull            // Answer with 0.0.0.0 or ::, other types get NODATA
+	FilterRefused         // Answer with REFUSED
+)
+
+// Filter is a Handler wrapper that blocks queries based on block and allow
+// lists read from files. It's safe for concurrent use by multiple goroutines.
+type Filter struct {
+	Block    []string                       // Files with names to block
+	Allow    []string                       // Files with names that are never blocked
+	Response int                            // How to answer blocked queries, defaults to FilterNxdomain
+	Ttl      uint32                         // TTL used in FilterNull answers
+	Log      func(qname string, a net.Addr) // If not nil, called for each blocked query
+	block    *DomainSet
+	allow    *DomainSet
+	mtime    time.Time // Most recent modification time of the lists
+	*sync.RWMutex
+}
+
+// NewFilter returns an empty Filter, which blocks nothing.
+func NewFilter() *Filter {
+	f := new(Filter)
+	f.Ttl = 3600
+	f.block = NewDomainSet(nil)
+	f.allow = NewDomainSet(nil)
+	f.RWMutex = new(sync.RWMutex)
+	return f
+}
+
+// Reload (re)reads the block and allow lists. When one of the files can not
+// be read or parsed, the current lists are kept and the error is returned.
+func (f *Filter) Reload() error {
+	block, t1, err := readDomainSets(f.Block)
+	if err != nil {
+		return err
+	}
+	allow, t2, err := readDomainSets(f.Allow)
+	if err != nil {
+		return err
+	}
+	if t2.After(t1) {
+		t1 = t2
+	}
+	f.Lock()
+	f.block, f.allow, f.mtime = block, allow, t1
+	f.Unlock()
+	return nil
+}
+
+// Watch checks the lists every d and reloads them when one of them has been
+// modified. Send true on the returned channel to stop watching. Errors
+// during reloading are ignored and the old lists stay in use.
+func (f *Filter) Watch(d time.Duration) chan bool {
+	stop := make(chan bool)
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				f.RLock()
+				mtime := f.mtime
+				f.RUnlock()
+				t1, t2 := latestModTime(f.Block), latestModTime(f.Allow)
+				if t1.After(mtime) || t2.After(mtime) {
+					f.Reload()
+				}
+			}
+		}
+	}()
+	return stop
+}
+
+// Blocked returns true when name is blocked by the filter.
+func (f *Filter) Blocked(name string) bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.block.Match(name) && !f.allow.Match(name)
+}
+
+// Wrap returns a Handler that answers blocked queries itself and hands all
+// other queries to h.
+func (f *Filter) Wrap(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, req *Msg) {
+		if len(req.Question) != 1 || !f.Blocked(req.Question[0].Name) {
+			h.ServeDNS(w, req)
+			return
+		}
+		if f.Log != nil {
+			f.Log(req.Question[0].Name, w.RemoteAddr())
+		}
+		w.WriteMsg(f.reply(req))
+	})
+}
+
+// reply creates the answer for a blocked query.
+func (f *Filter) reply(req *Msg) *Msg {
+	m := new(Msg)
+	switch f.Response {
+	case FilterRefused:
+		m.SetRcode(req, RcodeRefused)
+	case FilterNull:
+		m.SetReply(req)
+		q := req.Question[0]
+		hdr := RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: ClassINET, Ttl: f.Ttl}
+		switch q.Qtype {
+		case TypeA:
+			m.Answer = []RR{&A{Hdr: hdr, A: net.IPv4zero}}
+		case TypeAAAA:
+			m.Answer = []RR{&AAAA{Hdr: hdr, AAAA: net.IPv6zero}}
+		}
+	default:
+		m.SetRcode(req, RcodeNameError)
+	}
+	m.RecursionAvailable = true
+	return m
+}
+
+func readDomainSets(files []string) (*DomainSet, time.Time, error) {
+	var (
+		names []string
+		mtime time.Time
+	)
+	for _, file := range files {
+		fh, err := os.Open(file)
+		if err != nil {
+			return nil, mtime, err
+		}
+		if fi, err := fh.Stat(); err == nil && fi.ModTime().After(mtime) {
+			mtime = fi.ModTime()
+		}
+		n, err := readDomainList(fh, file)
+		fh.Close()
+		if err != nil {
+			return nil, mtime, err
+		}
+		names = append(names, n...)
+	}
+	return NewDomainSet(names), mtime, nil
+}
+
+func latestModTime(files []string) (mtime time.Time) {
+	for _, file := range files {
+		if fi, err := os.Stat(file); err == nil && fi.ModTime().After(mtime) {
+			mtime = fi.ModTime()
+		}
+	}
+	return
+}
+
+// Names found in hosts files that should never be blocked.
+var hostsNames = map[string]bool{
+	"localhost.":             true,
+	"localhost.localdomain.": true,
+	"local.":                 true,
+	"broadcasthost.":         true,
+	"ip6-localhost.":         true,
+	"ip6-loopback.":          true,
+	"ip6-localnet.":          true,
+	"ip6-mcastprefix.":       true,
+	"ip6-allnodes.":          true,
+	"ip6-allrouters.":        true,
+	"ip6-allhosts.":          true,
+}
+
+// ReadDomainSet reads a list of names in hosts file or domain list format
+// from r. The string file is only used in error reporting.
+func ReadDomainSet(r io.Reader, file string) (*DomainSet, error) {
+	names, err := readDomainList(r, file)
+	if err != nil {
+		return nil, err
+	}
+	return NewDomainSet(names), nil
+}
+
+func readDomainList(r io.Reader, file string) ([]string, error) {
+	names := make([]string, 0, 1024)
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		l := s.Text()
+		if i := strings.IndexByte(l, '#'); i >= 0 {
+			l = l[:i]
+		}
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+		for _, f := range fields {
+			f = strings.TrimPrefix(f, "*.")
+			if _, _, ok := IsDomainName(f); !ok {
+				return nil, &ParseError{file, "bad domain name", lex{token: f, line: line}, nil}
+			}
+			f = Fqdn(strings.ToLower(f))
+			if hostsNames[f] {
+				continue
+			}
+			names = append(names, f)
+		}
+	}
+	return names, s.Err()
+}
+
+// DomainSet is an immutable set of domain names. A name in the set also
+// matches all names below it. To keep the memory usage low all names are
+// stored in a single string and a bloom filter is used to quickly reject
+// names that are not in the set.
+type DomainSet struct {
+	data  string   // The sorted names, concatenated
+	index []uint32 // Offset of each name in data
+	bloom []uint64 // Bloom filter on the names
+}
+
+const bloomHashes = 7 // Number of hash functions in the bloom filter, 10 bits per name
+
+// NewDomainSet creates a DomainSet holding the names. Names are
+// compared case insensitive. Duplicates are removed.
+func NewDomainSet(names []string) *DomainSet {
+	for i, n := range names {
+		names[i] = Fqdn(strings.ToLower(n))
+	}
+	sort.Strings(names)
+	d := new(DomainSet)
+	size := 0
+	for _, n := range names {
+		size += len(n)
+	}
+	data := make([]byte, 0, size)
+	d.index = make([]uint32, 0, len(names)+1)
+	prev := ""
+	for i, n := range names {
+		if i > 0 && n == prev {
+			continue
+		}
+		d.index = append(d.index, uint32(len(data)))
+		data = append(data, n...)
+		prev = n
+	}
+	d.index = append(d.index, uint32(len(data)))
+	d.data = string(data)
+	d.bloom = make([]uint64, (d.Len()*10)/64+1)
+	for i := 0; i < d.Len(); i++ {
+		d.bloomAdd(d.name(i))
+	}
+	return d
+}
+
+// Len returns the number of names in the set.
+func (d *DomainSet) Len() int {
+	return len(d.index) - 1
+}
+
+// Match returns true when name or one of its parents is in the set.
+func (d *DomainSet) Match(name string) bool {
+	if d.Len() == 0 {
+		return false
+	}
+	name = Fqdn(strings.ToLower(name))
+	for off, end := 0, false; !end; off, end = NextLabelOffset(name, off) {
+		if d.contains(name[off:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DomainSet) name(i int) string {
+	return d.data[d.index[i]:d.index[i+1]]
+}
+
+func (d *DomainSet) contains(name string) bool {
+	if !d.bloomTest(name) {
+		return false
+	}
+	n := d.Len()
+	i := sort.Search(n, func(i int) bool { return d.name(i) >= name })
+	return i < n && d.name(i) == name
+}
+
+// The bloom filter uses double hashing on the two halves of a 64 bit
+// FNV-1a hash.
+func bloomHash(s string) (uint32, uint32) {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return uint32(h), uint32(h>>32) | 1
+}
+
+func (d *DomainSet) bloomAdd(s string) {
+	h1, h2 := bloomHash(s)
+	m := uint32(len(d.bloom) * 64)
+	for i := uint32(0); i < bloomHashes; i++ {
+		b := (h1 + i*h2) % m
+		d.bloom[b/64] |= 1 << (b % 64)
+	}
+}
+
+func (d *DomainSet) bloomTest(s string) bool {
+	h1, h2 := bloomHash(s)
+	m := uint32(len(d.bloom) * 64)
+	for i := uint32(0); i < bloomHashes; i++ {
+		b := (h1 + i*h2) % m
+		if d.bloom[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+const blockList = `# hosts file
+127.0.0.1	localhost
+0.0.0.0 ads.example.com tracker.example.com   # two names
+::1     ip6-localhost
+# domain list
+bad.example.net
+BAD.example.net
+*.wild.example.org
+`
+
+func TestDomainSet(t *testing.T) {
+	d, err := ReadDomainSet(strings.NewReader(blockList), "")
+	if err != nil {
+		t.Fatalf("failed to read list: %s", err.Error())
+	}
+	if d.Len() != 4 {
+		t.Errorf("set should have 4 names, has %d", d.Len())
+	}
+	yes := []string{"ads.example.com.", "www.ads.example.com", "bad.EXAMPLE.net.", "a.b.wild.example.org.", "wild.example.org."}
+	for _, n := range yes {
+		if !d.Match(n) {
+			t.Errorf("%s should match", n)
+		}
+	}
+	no := []string{"example.com.", "localhost.", "ip6-localhost.", "notads.example.com.", `not\.ads.example.com.`, "com.", "."}
+	for _, n := range no {
+		if d.Match(n) {
+			t.Errorf("%s should not match", n)
+		}
+	}
+	if _, err := ReadDomainSet(strings.NewReader("0.0.0.0 bad..name\n"), ""); err == nil {
+		t.Error("bad..name should fail to parse")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := NewFilter()
+	f.block, _ = ReadDomainSet(strings.NewReader(blockList), "")
+	f.allow = NewDomainSet([]string{"ok.ads.example.com"})
+	f.Response = FilterNull
+	h := f.Wrap(HandlerFunc(rpzAnswer))
+
+	w := new(testWriter)
+	m := new(Msg)
+	m.SetQuestion("www.ads.example.com.", TypeA)
+	h.ServeDNS(w, m)
+	if len(w.msg.Answer) != 1 || w.msg.Answer[0].(*A).A.String() != "0.0.0.0" {
+		t.Errorf("www.ads.example.com. should be blocked, got %v", w.msg)
+	}
+	m.SetQuestion("www.ads.example.com.", TypeMX)
+	h.ServeDNS(w, m)
+	if len(w.msg.Answer) != 0 || w.msg.Rcode != RcodeSuccess {
+		t.Errorf("www.ads.example.com. MX should be NODATA, got %v", w.msg)
+	}
+	m.SetQuestion("ok.ads.example.com.", TypeA)
+	h.ServeDNS(w, m)
+	if len(w.msg.Answer) != 1 || w.msg.Answer[0].(*A).A.String() != "192.0.2.53" {
+		t.Errorf("ok.ads.example.com. should be allowed, got %v", w.msg)
+	}
+	f.Response = FilterNxdomain
+	m.SetQuestion("tracker.example.com.", TypeA)
+	h.ServeDNS(w, m)
+	if w.msg.Rcode != RcodeNameError {
+		t.Errorf("tracker.example.com. should be NXDOMAIN, got %v", w.msg)
+	}
+}
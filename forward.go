@@ -0,0 +1,201 @@
+// FORWARDING
+//
+// A Forwarder is a Handler that sends the queries it gets to upstream
+// name servers. Which upstream servers are used depends on the query name:
+// routes map a domain to a set of upstream servers, the route with the longest
+// matching domain is used. Each route has its own transport settings.
+//
+// Basic use pattern, forward corp.example. to internal resolvers and everything
+// else over TLS to a public resolver:
+//
+//	f := dns.NewForwarder(&dns.Upstream{Servers: []string{"192.0.2.53:853"}, Net: "tcp-tls"})
+//	f.Route("corp.example.", &dns.Upstream{Servers: []string{"10.0.0.53:53", "10.0.1.53:53"}})
+//	dns.Handle(".", f)
+//
+// The EDNS Client Subnet option (ECS) of forwarded queries leaks (part of) the
+// address of the client to the upstream servers. Upstream.ECS sets the policy,
+// following the privacy guidance of RFC 7871: pass the option on, strip it,
+// truncate the address to a /24 (IPv4) or /56 (IPv6), or replace it with a
+// fixed subnet.
+package dns
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upstream is a set of upstream name servers, and the settings used to
+// reach them.
+type Upstream struct {
+	Servers   []string      // Addresses of the servers, host:port, they are tried in order
+	Net       string        // Transport, see Client.Net. With "" or "udp", truncated replies are retried over TCP
+	Timeout   time.Duration // Read and write timeout, defaults to 2 seconds
+	TLSConfig *tls.Config   // TLS configuration for "tcp-tls"
+	ECS       int           // What to do with the client subnet option of forwarded queries: ECSPass, ECSStrip, ECSTruncate or ECSForge
+	ECSSubnet *net.IPNet    // The subnet sent in its place with ECSForge
+
+	ValidateResponse ResponseCheck // If not nil, replies it rejects are treated as failures and the next server is tried
+}
+
+// Client subnet policies, see Upstream.ECS.
+const (
+	ECSPass     = iota // Send the option as is
+	ECSStrip           // Remove the option
+	ECSTruncate        // Truncate the address to ECSTruncate4 or ECSTruncate6 bits
+	ECSForge           // Replace the option with one for ECSSubnet, queries without the option also get it
+)
+
+// The source prefix lengths used with ECSTruncate, as recommended by RFC 7871.
+const (
+	ECSTruncate4 = 24
+	ECSTruncate6 = 56
+)
+
+// scrub returns m with the client subnet option changed according to u.ECS.
+// When the option needs to change a copy of m is returned, m itself is not
+// modified.
+func (u *Upstream) scrub(m *Msg) *Msg {
+	if u.ECS == ECSPass {
+		return m
+	}
+	opt := m.IsEdns0()
+	var ecs *EDNS0_SUBNET
+	if opt != nil {
+		ecs, _ = opt.GetOption(EDNS0SUBNET).(*EDNS0_SUBNET)
+	}
+	if ecs == nil && (u.ECS != ECSForge || u.ECSSubnet == nil) {
+		return m
+	}
+	m1 := *m
+	m1.Extra = make([]RR, 0, len(m.Extra)+1)
+	for _, r := range m.Extra {
+		if r.Header().Rrtype != TypeOPT {
+			m1.Extra = append(m1.Extra, r)
+		}
+	}
+	if opt == nil {
+		opt = &OPT{Hdr: RR_Header{Name: ".", Rrtype: TypeOPT}}
+		opt.SetUDPSize(DefaultMsgSize)
+	} else {
+		opt = &OPT{*opt.Hdr.CopyHeader(), append([]EDNS0(nil), opt.Option...)}
+	}
+	switch u.ECS {
+	case ECSStrip:
+		opt.DeleteOption(EDNS0SUBNET)
+	case ECSTruncate:
+		e := *ecs
+		bits := uint8(ECSTruncate4)
+		if e.Family == 2 {
+			bits = ECSTruncate6
+		}
+		if e.SourceNetmask > bits {
+			e.SourceNetmask = bits
+		}
+		if e.Family == 2 {
+			e.Address = e.Address.Mask(net.CIDRMask(int(e.SourceNetmask), net.IPv6len*8))
+		} else {
+			e.Address = e.Address.Mask(net.CIDRMask(int(e.SourceNetmask), net.IPv4len*8))
+		}
+		e.SourceScope = 0
+		opt.SetOption(&e)
+	case ECSForge:
+		if u.ECSSubnet == nil {
+			opt.DeleteOption(EDNS0SUBNET)
+			break
+		}
+		e := &EDNS0_SUBNET{Code: EDNS0SUBNET, Family: 1, Address: u.ECSSubnet.IP.To4()}
+		if e.Address == nil {
+			e.Family, e.Address = 2, u.ECSSubnet.IP.To16()
+		}
+		ones, _ := u.ECSSubnet.Mask.Size()
+		e.SourceNetmask = uint8(ones)
+		opt.SetOption(e)
+	}
+	m1.Extra = append(m1.Extra, opt)
+	return &m1
+}
+
+// Exchange sends m to the servers of u, until one of them replies with a
+// reply that passes u.ValidateResponse. The error of the last server is
+// returned when none does.
+func (u *Upstream) Exchange(m *Msg) (r *Msg, err error) {
+	// Truncated replies are retried over TCP, before they are checked
+	c := &Client{Net: u.Net, Retry: true, ReadTimeout: u.Timeout, WriteTimeout: u.Timeout, TLSConfig: u.TLSConfig, ValidateResponse: u.ValidateResponse}
+	if len(u.Servers) == 0 {
+		return nil, &Error{Err: "no upstream servers"}
+	}
+	for _, s := range u.Servers {
+		if r, _, err = c.Exchange(m, s); err == nil {
+			return r, nil
+		}
+	}
+	return nil, err
+}
+
+// Forwarder is a Handler that forwards queries to upstream name servers.
+// It's safe for concurrent use by multiple goroutines.
+type Forwarder struct {
+	Default *Upstream            // Used when no route matches, if nil such queries get REFUSED
+	routes  map[string]*Upstream // keyed on the lower cased domain
+	*sync.RWMutex
+}
+
+// NewForwarder returns a Forwarder that forwards all queries to def.
+func NewForwarder(def *Upstream) *Forwarder {
+	return &Forwarder{Default: def, routes: make(map[string]*Upstream), RWMutex: new(sync.RWMutex)}
+}
+
+// Route forwards queries for domain and all names below it to u. If u
+// is nil the route is removed.
+func (f *Forwarder) Route(domain string, u *Upstream) {
+	f.Lock()
+	defer f.Unlock()
+	domain = Fqdn(strings.ToLower(domain))
+	if u == nil {
+		delete(f.routes, domain)
+		return
+	}
+	f.routes[domain] = u
+}
+
+// Upstream returns the upstream servers for name: the route with the
+// longest domain name matching name, or Default.
+func (f *Forwarder) Upstream(name string) *Upstream {
+	f.RLock()
+	defer f.RUnlock()
+	name = Fqdn(strings.ToLower(name))
+	for off, end := 0, false; !end; off, end = NextLabelOffset(name, off) {
+		if u, ok := f.routes[name[off:]]; ok {
+			return u
+		}
+	}
+	if u, ok := f.routes["."]; ok {
+		return u
+	}
+	return f.Default
+}
+
+// ServeDNS implements the Handler interface.
+func (f *Forwarder) ServeDNS(w ResponseWriter, req *Msg) {
+	if len(req.Question) != 1 {
+		HandleFailed(w, req)
+		return
+	}
+	u := f.Upstream(req.Question[0].Name)
+	if u == nil {
+		m := new(Msg)
+		m.SetRcode(req, RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+	r, err := u.Exchange(u.scrub(req))
+	if err != nil {
+		HandleFailed(w, req)
+		return
+	}
+	r.Id = req.Id
+	w.WriteMsg(r)
+}
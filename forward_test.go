@@ -0,0 +1,160 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestForwarderRoute(t *testing.T) {
+	def := &Upstream{Servers: []string{"192.0.2.1:53"}}
+	corp := &Upstream{Servers: []string{"10.0.0.53:53"}}
+	lab := &Upstream{Servers: []string{"10.1.0.53:53"}, Net: "tcp"}
+	f := NewForwarder(def)
+	f.Route("corp.example.", corp)
+	f.Route("lab.corp.example", lab)
+
+	tests := map[string]*Upstream{
+		"www.example.org.":       def,
+		"corp.example.":          corp,
+		"WWW.Corp.Example.":      corp,
+		"host.lab.corp.example.": lab,
+		"notcorp.example.":       def,
+		"lab.corp.example.org.":  def,
+	}
+	for name, u := range tests {
+		if x := f.Upstream(name); x != u {
+			t.Errorf("%s should be routed to %v, not %v", name, u.Servers, x.Servers)
+		}
+	}
+	f.Route("lab.corp.example.", nil)
+	if f.Upstream("host.lab.corp.example.") != corp {
+		t.Error("removed route should not be used")
+	}
+}
+
+func TestUpstreamECS(t *testing.T) {
+	m := new(Msg)
+	m.SetQuestion("www.example.org.", TypeA)
+	m.SetEdns0(4096, false)
+	m.IsEdns0().SetOption(&EDNS0_SUBNET{Code: EDNS0SUBNET, Family: 1, SourceNetmask: 32, Address: net.IPv4(192, 0, 2, 1).To4()})
+	_, forged, _ := net.ParseCIDR("198.51.100.0/24")
+	for _, test := range []struct {
+		u    *Upstream
+		want string
+	}{
+		{&Upstream{ECS: ECSPass}, "192.0.2.1/32/0"},
+		{&Upstream{ECS: ECSStrip}, ""},
+		{&Upstream{ECS: ECSTruncate}, "192.0.2.0/24/0"},
+		{&Upstream{ECS: ECSForge, ECSSubnet: forged}, "198.51.100.0/24/0"},
+	} {
+		got := ""
+		if e := test.u.scrub(m).IsEdns0().GetOption(EDNS0SUBNET); e != nil {
+			got = e.String()
+		}
+		if got != test.want {
+			t.Errorf("policy %d: client subnet is %q, expected %q", test.u.ECS, got, test.want)
+		}
+	}
+	if e := m.IsEdns0().GetOption(EDNS0SUBNET); e == nil || e.String() != "192.0.2.1/32/0" {
+		t.Errorf("original query was modified: %v", e)
+	}
+}
+
+func TestUpstreamValidateResponse(t *testing.T) {
+	var servers []string
+	for _, rcode := range []int{RcodeServerFailure, RcodeSuccess} {
+		l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		defer l.Close()
+		rcode := rcode
+		go (&Server{Handler: HandlerFunc(func(w ResponseWriter, r *Msg) {
+			m := new(Msg)
+			m.SetRcode(r, rcode)
+			if rcode == RcodeSuccess {
+				m.Answer = []RR{&A{Hdr: RR_Header{r.Question[0].Name, TypeA, ClassINET, 3600, 0}, A: net.IPv4(192, 0, 2, 1)}}
+			}
+			w.WriteMsg(m)
+		})}).serveUDP(l)
+		servers = append(servers, l.LocalAddr().String())
+	}
+
+	m := new(Msg)
+	m.SetQuestion("www.example.org.", TypeA)
+	u := &Upstream{Servers: servers, ValidateResponse: ResponseChecks(RejectRcodes(RcodeServerFailure, RcodeRefused), CheckBailiwick, MinAnswers(1))}
+	r, err := u.Exchange(m)
+	if err != nil || len(r.Answer) != 1 {
+		t.Fatalf("expected the reply of the second server, got %v", err)
+	}
+
+	c := &Client{ValidateResponse: u.ValidateResponse}
+	r, _, err = c.Exchange(m, servers[0])
+	if !errors.Is(err, ErrRejected) || !errors.Is(err, ErrRcode) || r == nil {
+		t.Errorf("expected a rejected reply with an rcode error, got %v", err)
+	}
+
+	bad := new(Msg)
+	bad.SetReply(m)
+	bad.Answer = []RR{&A{Hdr: RR_Header{"www.example.net.", TypeA, ClassINET, 3600, 0}, A: net.IPv4(192, 0, 2, 2)}}
+	if err := CheckBailiwick(m, bad); err == nil {
+		t.Error("expected an error for an answer for another name")
+	}
+	bad.Answer = []RR{&CNAME{Hdr: RR_Header{"www.example.org.", TypeCNAME, ClassINET, 3600, 0}, Target: "www.example.net."}, bad.Answer[0]}
+	bad.Ns = []RR{&NS{Hdr: RR_Header{"example.net.", TypeNS, ClassINET, 3600, 0}, Ns: "ns.example.net."}}
+	if err := CheckBailiwick(m, bad); err != nil {
+		t.Errorf("expected no error for a CNAME chain, got %s", err.Error())
+	}
+	bad.Ns[0].Header().Name = "example.com."
+	if err := CheckBailiwick(m, bad); err == nil {
+		t.Error("expected an error for an unrelated NS record")
+	}
+	bad.Ns = []RR{&NS{Hdr: RR_Header{"example.org.", TypeNS, ClassINET, 3600, 0}, Ns: "ns.example.org."},
+		&NS{Hdr: RR_Header{".", TypeNS, ClassINET, 3600, 0}, Ns: "ns.example.org."}}
+	if err := CheckBailiwick(m, bad); err == nil {
+		t.Error("expected an error for NS records of two domains")
+	}
+}
+
+func TestUpstreamValidateTruncated(t *testing.T) {
+	// Over UDP the reply is truncated and has no answer, over TCP it has
+	h := HandlerFunc(func(w ResponseWriter, r *Msg) {
+		m := new(Msg)
+		m.SetReply(r)
+		if w.(RequestInfoWriter).RequestInfo().Net == "udp" {
+			m.Truncated = true
+		} else {
+			m.Answer = []RR{&A{Hdr: RR_Header{r.Question[0].Name, TypeA, ClassINET, 3600, 0}, A: net.IPv4(192, 0, 2, 1)}}
+		}
+		w.WriteMsg(m)
+	})
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go (&Server{Handler: h}).serveTCP(l)
+	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: l.Addr().(*net.TCPAddr).Port})
+	if err != nil {
+		t.Skipf("failed to listen on the UDP port: %s", err.Error())
+	}
+	defer u.Close()
+	go (&Server{Handler: h}).serveUDP(u)
+
+	m := new(Msg)
+	m.SetQuestion("www.example.org.", TypeA)
+	up := &Upstream{Servers: []string{u.LocalAddr().String()}, ValidateResponse: MinAnswers(1)}
+	if r, err := up.Exchange(m); err != nil || len(r.Answer) != 1 {
+		t.Errorf("expected the reply over TCP, got %v", err)
+	}
+
+	c := &Client{ValidateResponse: MinAnswers(1)}
+	if r, _, err := c.Exchange(m, u.LocalAddr().String()); err != nil || !r.Truncated {
+		t.Errorf("expected the truncated reply, got %v", err)
+	}
+	c.Retry = true
+	if r, _, err := c.Exchange(m, u.LocalAddr().String()); err != nil || len(r.Answer) != 1 {
+		t.Errorf("expected the reply over TCP, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package dns
+
+// Internationalized domain names, as far as needed to compare names.
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// foldName returns the domain name s in the form in which ServeMux and
+// QuestionMatcher compare names: fully qualified and lower cased, with each
+// escape (\X and \DDD) replaced by the byte it stands for and escaped again
+// in one way, so "\065.example." and "a.example." fold to the same name. A
+// label that is valid UTF-8 and not ASCII is lower cased and converted to its
+// ACE form (RFC 3492), "bücher" becomes "xn--bcher-kva"; no other IDNA
+// mapping is done. A literal asterisk stays "*".
+func foldName(s string) string {
+	s = Fqdn(s)
+	if s == "." {
+		return s
+	}
+	var f []byte
+	var label []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]):
+			n, _ := strconv.Atoi(s[i+1 : i+4])
+			label = append(label, byte(n))
+			i += 3
+		case c == '\\' && i+1 < len(s):
+			label = append(label, s[i+1])
+			i++
+		case c == '.':
+			f = appendLabel(f, label)
+			label = label[:0]
+		default:
+			label = append(label, c)
+		}
+	}
+	if len(label) > 0 {
+		f = appendLabel(f, label)
+	}
+	return string(f)
+}
+
+// appendLabel appends label, with a terminating dot, to f in the form of
+// foldName.
+func appendLabel(f, label []byte) []byte {
+	ascii := true
+	for _, c := range label {
+		if c >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if !ascii && utf8.Valid(label) {
+		label = []byte("xn--" + punycode(strings.ToLower(string(label))))
+	}
+	for _, c := range label {
+		switch {
+		case c == '.' || c == '\\':
+			f = append(f, '\\', c)
+		case 'A' <= c && c <= 'Z':
+			f = append(f, c+'a'-'A')
+		case c < '!' || c > '~':
+			f = append(f, '\\', '0'+c/100, '0'+c/10%10, '0'+c%10)
+		default:
+			f = append(f, c)
+		}
+	}
+	return append(f, '.')
+}
+
+// The parameters of Punycode, RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTmin        = 1
+	punyTmax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode returns the Punycode encoding of s, without the "xn--" prefix.
+func punycode(s string) string {
+	runes := []rune(s)
+	var out []byte
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	if b > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for h := b; h < len(runes); {
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTmin {
+					t = punyTmin
+				} else if t > punyTmax {
+					t = punyTmax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > (punyBase-punyTmin)*punyTmax/2 {
+		delta /= punyBase - punyTmin
+		k += punyBase
+	}
+	return k + (punyBase-punyTmin+1)*delta/(delta+punySkew)
+}
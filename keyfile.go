@@ -0,0 +1,95 @@
+package dns
+
+// Reading and writing BIND style key files.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// KeyFileName returns the base name BIND uses for the files of k:
+// K<name>+<algorithm>+<keytag>, for instance Kmiek.nl.+008+12051. The
+// public key is stored in the file with the extension .key, the private
+// key in the one with the extension .private.
+func (k *DNSKEY) KeyFileName() string {
+	alg := strconv.Itoa(int(k.Algorithm))
+	tag := strconv.Itoa(int(k.KeyTag()))
+	return "K" + strings.ToLower(Fqdn(k.Hdr.Name)) + "+" + strings.Repeat("0", 3-len(alg)) + alg +
+		"+" + strings.Repeat("0", 5-len(tag)) + tag
+}
+
+// WriteKeyFiles writes the .key and .private files for k and its private
+// key p in the directory dir. The private key is written in the v1.3 format
+// and is only readable by the owner. It returns the path of the files without
+// the extension.
+func WriteKeyFiles(dir string, k *DNSKEY, p PrivateKey) (string, error) {
+	private := k.PrivateKeyString(p)
+	if private == "" {
+		return "", ErrPrivKey
+	}
+	base := filepath.Join(dir, k.KeyFileName())
+	kind := "zone-signing"
+	if k.Flags&SEP == SEP {
+		kind = "key-signing"
+	}
+	public := "; This is a " + kind + " key, keyid " + strconv.Itoa(int(k.KeyTag())) + ", for " + k.Hdr.Name + "\n" + k.String() + "\n"
+	if err := ioutil.WriteFile(base+".key", []byte(public), 0644); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(base+".private", []byte(private), 0600); err != nil {
+		return "", err
+	}
+	return base, nil
+}
+
+// ReadKeyFiles reads the public key from base.key and the private
+// key from base.private. If base ends in .key or .private that extension
+// is removed first.
+func ReadKeyFiles(base string) (*DNSKEY, PrivateKey, error) {
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".key"), ".private")
+	f, err := os.Open(base + ".key")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	rr, err := ReadRR(f, base+".key")
+	if err != nil {
+		return nil, nil, err
+	}
+	k, ok := rr.(*DNSKEY)
+	if !ok {
+		return nil, nil, &Error{Err: "no DNSKEY in key file", Name: base + ".key"}
+	}
+	g, err := os.Open(base + ".private")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer g.Close()
+	p, err := k.ReadPrivateKey(g, base+".private")
+	if err != nil {
+		return nil, nil, err
+	}
+	return k, p, nil
+}
+
+// ReadKeyDir reads all key pairs (K*.private and K*.key) in the directory
+// dir, as written by dnssec-keygen or WriteKeyFiles. The returned map can be
+// given to Zone.Sign. It fails on the first key that can't be read.
+func ReadKeyDir(dir string) (map[*DNSKEY]PrivateKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "K*.private"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[*DNSKEY]PrivateKey)
+	for _, f := range files {
+		k, p, err := ReadKeyFiles(f)
+		if err != nil {
+			return nil, err
+		}
+		keys[k] = p
+	}
+	return keys, nil
+}
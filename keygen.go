@@ -3,6 +3,7 @@ package dns
 import (
 	"crypto/dsa"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -44,6 +45,10 @@ func (r *DNSKEY) Generate(bits int) (PrivateKey, error) {
 		if bits != 384 {
 			return nil, ErrKeySize
 		}
+	case ED25519:
+		if bits != 256 {
+			return nil, ErrKeySize
+		}
 	}
 
 	switch r.Algorithm {
@@ -81,6 +86,13 @@ func (r *DNSKEY) Generate(bits int) (PrivateKey, error) {
 		}
 		r.setPublicKeyCurve(priv.PublicKey.X, priv.PublicKey.Y)
 		return priv, nil
+	case ED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		r.PublicKey = unpackBase64(pub)
+		return priv, nil
 	default:
 		return nil, ErrAlg
 	}
@@ -116,7 +128,7 @@ func (r *DNSKEY) PrivateKeyString(p PrivateKey) (s string) {
 
 		s = _FORMAT +
 			"Algorithm: " + algorithm + "\n" +
-			"Modules: " + modulus + "\n" +
+			"Modulus: " + modulus + "\n" +
 			"PublicExponent: " + publicExponent + "\n" +
 			"PrivateExponent: " + privateExponent + "\n" +
 			"Prime1: " + prime1 + "\n" +
@@ -130,6 +142,12 @@ func (r *DNSKEY) PrivateKeyString(p PrivateKey) (s string) {
 		s = _FORMAT +
 			"Algorithm: " + algorithm + "\n" +
 			"PrivateKey: " + private + "\n"
+	case ed25519.PrivateKey:
+		algorithm := strconv.Itoa(int(r.Algorithm)) + " (" + AlgorithmToString[r.Algorithm] + ")"
+		private := unpackBase64(t.Seed())
+		s = _FORMAT +
+			"Algorithm: " + algorithm + "\n" +
+			"PrivateKey: " + private + "\n"
 	case *dsa.PrivateKey:
 		algorithm := strconv.Itoa(int(r.Algorithm)) + " (" + AlgorithmToString[r.Algorithm] + ")"
 		prime := unpackBase64(t.PublicKey.Parameters.P.Bytes())
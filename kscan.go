@@ -3,9 +3,11 @@ package dns
 import (
 	"crypto/dsa"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"io"
 	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -32,53 +34,37 @@ func (k *DNSKEY) ReadPrivateKey(q io.Reader, file string) (PrivateKey, error) {
 		return nil, ErrPrivKey
 	}
 	// TODO(mg): check if the pubkey matches the private key
-	switch m["algorithm"] {
-	case "3 (DSA)":
-		p, e := readPrivateKeyDSA(m)
-		if e != nil {
-			return nil, e
-		}
-		if !k.setPublicKeyInPrivate(p) {
-			return nil, ErrPrivKey
-		}
-		return p, e
-	case "1 (RSAMD5)":
-		fallthrough
-	case "5 (RSASHA1)":
-		fallthrough
-	case "7 (RSASHA1NSEC3SHA1)":
-		fallthrough
-	case "8 (RSASHA256)":
-		fallthrough
-	case "10 (RSASHA512)":
-		p, e := readPrivateKeyRSA(m)
-		if e != nil {
-			return nil, e
-		}
-		if !k.setPublicKeyInPrivate(p) {
-			return nil, ErrPrivKey
-		}
-		return p, e
-	case "12 (ECC-GOST)":
-		p, e := readPrivateKeyGOST(m)
-		if e != nil {
-			return nil, e
-		}
-		// setPublicKeyInPrivate(p)
-		return p, e
-	case "13 (ECDSAP256SHA256)":
-		fallthrough
-	case "14 (ECDSAP384SHA384)":
-		p, e := readPrivateKeyECDSA(m)
-		if e != nil {
-			return nil, e
-		}
-		if !k.setPublicKeyInPrivate(p) {
-			return nil, ErrPrivKey
-		}
-		return p, e
+	// The algorithm is given as "8 (RSASHA256)", only the number is used
+	alg := strings.Fields(m["algorithm"])
+	if len(alg) == 0 {
+		return nil, ErrPrivKey
+	}
+	a, err := strconv.Atoi(alg[0])
+	if err != nil || uint8(a) != k.Algorithm {
+		return nil, ErrPrivKey
+	}
+	var p PrivateKey
+	switch k.Algorithm {
+	case DSA, DSANSEC3SHA1:
+		p, e = readPrivateKeyDSA(m)
+	case RSAMD5, RSASHA1, RSASHA1NSEC3SHA1, RSASHA256, RSASHA512:
+		p, e = readPrivateKeyRSA(m)
+	case ECCGOST:
+		return readPrivateKeyGOST(m)
+	case ECDSAP256SHA256, ECDSAP384SHA384:
+		p, e = readPrivateKeyECDSA(m)
+	case ED25519:
+		p, e = readPrivateKeyED25519(m)
+	default:
+		return nil, ErrPrivKey
+	}
+	if e != nil {
+		return nil, e
 	}
-	return nil, ErrPrivKey
+	if !k.setPublicKeyInPrivate(p) {
+		return nil, ErrPrivKey
+	}
+	return p, nil
 }
 
 // Read a private key (file) string and create a public key. Return the private key.
@@ -124,13 +110,13 @@ func readPrivateKeyDSA(m map[string]string) (PrivateKey, error) {
 	p.X = big.NewInt(0)
 	for k, v := range m {
 		switch k {
-		case "private_value(x):":
+		case "private_value(x)":
 			v1, err := packBase64([]byte(v))
 			if err != nil {
 				return nil, err
 			}
 			p.X.SetBytes(v1)
-		case "created:", "publish:", "activate:":
+		case "created", "publish", "activate":
 			/* not used in Go (yet) */
 		}
 	}
@@ -143,33 +129,48 @@ func readPrivateKeyECDSA(m map[string]string) (PrivateKey, error) {
 	// Need to check if we have everything
 	for k, v := range m {
 		switch k {
-		case "privatekey:":
+		case "privatekey":
 			v1, err := packBase64([]byte(v))
 			if err != nil {
 				return nil, err
 			}
 			p.D.SetBytes(v1)
-		case "created:", "publish:", "activate:":
+		case "created", "publish", "activate":
 			/* not used in Go (yet) */
 		}
 	}
 	return p, nil
 }
 
+func readPrivateKeyED25519(m map[string]string) (PrivateKey, error) {
+	v, ok := m["privatekey"]
+	if !ok {
+		return nil, ErrPrivKey
+	}
+	seed, err := packBase64([]byte(v))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, ErrPrivKey
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
 func readPrivateKeyGOST(m map[string]string) (PrivateKey, error) {
 	//	p := new(ecdsa.PrivateKey)
 	//	p.D = big.NewInt(0)
 	// Need to check if we have everything
 	for k, v := range m {
 		switch k {
-		case "gostasn1:":
+		case "gostasn1":
 			v1, err := packBase64([]byte(v))
 			if err != nil {
 				return nil, err
 			}
 			v1 = v1
 			//p.D.SetBytes(v1)
-		case "created:", "publish:", "activate:":
+		case "created", "publish", "activate":
 			/* not used in Go (yet) */
 		}
 	}
@@ -192,7 +193,7 @@ func parseKey(r io.Reader, file string) (map[string]string, error) {
 			k = l.token
 		case _VALUE:
 			if k == "" {
-				return nil, &ParseError{file, "no private key seen", l}
+				return nil, &ParseError{file, "no private key seen", l, nil}
 			}
 			//println("Setting", strings.ToLower(k), "to", l.token, "b")
 			m[strings.ToLower(k)] = l.token
@@ -2,6 +2,12 @@ package dns
 
 // Holds a bunch of helper functions for dealing with labels.
 
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
 // SplitLabels splits a domainname string into its labels.
 // www.miek.nl. returns []string{"www", "miek", "nl"}
 // The root label (.) returns nil.
@@ -49,24 +55,57 @@ func JoinLabels(labels []string) string {
 // www.miek.nl. and miek.nl. have two labels in common: miek and nl
 // www.miek.nl. and www.bla.nl. have one label in common: nl
 func CompareLabels(s1, s2 string) (n int) {
-	l1 := SplitLabels(s1)
-	l2 := SplitLabels(s2)
+	return compareLabels(s1, s2, false)
+}
 
-	x1 := len(l1) - 1
-	x2 := len(l2) - 1
+// compareLabels is CompareLabels, when fold is true labels are compared
+// case-insensitively. The labels are walked in place, nothing is allocated.
+func compareLabels(s1, s2 string, fold bool) (n int) {
+	if s1 == "." || s2 == "." {
+		return 0
+	}
+	e1, e2 := labelsEnd(s1), labelsEnd(s2)
 	for {
-		if x1 < 0 || x2 < 0 {
-			break
+		b1, b2 := prevLabel(s1, e1), prevLabel(s2, e2)
+		l1, l2 := s1[b1:e1], s2[b2:e2]
+		if l1 != l2 && (!fold || !strings.EqualFold(l1, l2)) {
+			return
 		}
-		if l1[x1] == l2[x2] {
-			n++
-		} else {
-			break
+		n++
+		if b1 == 0 || b2 == 0 {
+			return
 		}
-		x1--
-		x2--
+		e1, e2 = b1-1, b2-1
 	}
-	return
+}
+
+// labelsEnd returns the end of the last label of s: the index of the
+// terminating dot, or len(s) when s isn't fully qualified.
+func labelsEnd(s string) int {
+	if IsFqdn(s) && !escapedAt(s, len(s)-1) {
+		return len(s) - 1
+	}
+	return len(s)
+}
+
+// prevLabel returns the start of the label in s that ends at end.
+func prevLabel(s string, end int) int {
+	for i := end - 1; i >= 0; i-- {
+		if s[i] == '.' && !escapedAt(s, i) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// escapedAt returns true when s[i] is escaped: preceded by an odd number of
+// backslashes.
+func escapedAt(s string, i int) bool {
+	n := 0
+	for i--; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
 }
 
 // LenLabels returns the number of labels in a domain name.
@@ -92,3 +131,71 @@ func LenLabels(s string) (labels int) {
 	}
 	return
 }
+
+// CanonicalCompare compares the domain names a and b in the canonical order
+// of RFC 4034, section 6.1: label by label from the right, each label as the
+// octets it stands for, with upper case ASCII letters lowercased; a name
+// sorts before the names below it. It returns -1, 0 or 1.
+func CanonicalCompare(a, b string) int {
+	na, nb := a != "" && a != ".", b != "" && b != "."
+	ea, eb := labelsEnd(a), labelsEnd(b)
+	var la, lb []byte
+	for na && nb {
+		ba, bb := prevLabel(a, ea), prevLabel(b, eb)
+		la, lb = appendLabelOctets(la[:0], a[ba:ea]), appendLabelOctets(lb[:0], b[bb:eb])
+		if c := bytes.Compare(la, lb); c != 0 {
+			return c
+		}
+		na, nb = ba > 0, bb > 0
+		ea, eb = ba-1, bb-1
+	}
+	switch {
+	case na == nb:
+		return 0
+	case nb:
+		return -1
+	}
+	return 1
+}
+
+// appendLabelOctets appends the octets of the label l, with the escapes
+// (\X and \DDD) undone and ASCII letters lowercased, to dst.
+func appendLabelOctets(dst []byte, l string) []byte {
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case c == '\\' && i+3 < len(l) && isDigit(l[i+1]) && isDigit(l[i+2]) && isDigit(l[i+3]):
+			n, _ := strconv.Atoi(l[i+1 : i+4])
+			c = byte(n)
+			i += 3
+		case c == '\\' && i+1 < len(l):
+			c = l[i+1]
+			i++
+		}
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// NextLabelOffset returns the index of the start of the next label in the
+// string s starting at offset. The bool end is true when the end of the
+// string has been reached. Walking a name with it doesn't allocate:
+//
+//	for off, end := 0, false; !end; off, end = NextLabelOffset(name, off) {
+//		// name[off:] is name or one of the domains above it
+//	}
+func NextLabelOffset(s string, offset int) (i int, end bool) {
+	for i = offset; i < len(s)-1; i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '.' {
+			return i + 1, false
+		}
+	}
+	return i + 1, true
+}
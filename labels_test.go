@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -31,6 +32,47 @@ func TestCompareLabels(t *testing.T) {
 		t.Logf("%s with %s should be %d", ".", ".", 0)
 		t.Fail()
 	}
+	if n := CompareLabels(`www\.miek.nl.`, `www.miek.nl.`); n != 1 {
+		t.Errorf("escaped dot: expected 1 label in common, got %d", n)
+	}
+	if n := CompareLabels(`www\\.miek.nl.`, `x.www\\.miek.nl.`); n != 3 {
+		t.Errorf("escaped backslash: expected 3 labels in common, got %d", n)
+	}
+	if !IsSubDomain("Miek.NL.", "www.miek.nl.") || IsSubDomain("miek.nl.", "www.bmiek.nl.") {
+		t.Error("IsSubDomain is wrong")
+	}
+}
+
+func TestNextLabelOffset(t *testing.T) {
+	name := `www.mi\.ek.nl.`
+	var domains []string
+	for off, end := 0, false; !end; off, end = NextLabelOffset(name, off) {
+		domains = append(domains, name[off:])
+	}
+	expected := []string{`www.mi\.ek.nl.`, `mi\.ek.nl.`, "nl."}
+	if strings.Join(domains, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, domains)
+	}
+	deep := strings.Repeat("a.", 60) + "miek.nl."
+	if n := testing.AllocsPerRun(100, func() { IsSubDomain("miek.nl.", deep) }); n != 0 {
+		t.Errorf("IsSubDomain allocates %v times", n)
+	}
+}
+
+func BenchmarkIsSubDomain(b *testing.B) {
+	deep := strings.Repeat("label.", 30) + "miek.nl."
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		IsSubDomain("miek.nl.", deep)
+	}
+}
+
+func BenchmarkIsSubDomainMismatch(b *testing.B) {
+	deep := strings.Repeat("label.", 30) + "miek.nl."
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		IsSubDomain("www.miek.nl.", deep)
+	}
 }
 
 func TestSplitLabels(t *testing.T) {
@@ -60,3 +102,43 @@ func TestSplitLabels(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestValidateName(t *testing.T) {
+	long := strings.Repeat("a", 63)
+	tests := []struct {
+		name    string
+		labels  int
+		wirelen int
+		err     error
+	}{
+		{".", 0, 1, nil},
+		{"miek.nl.", 2, 9, nil},
+		{"miek.nl", 2, 9, nil},
+		{`mi\.ek.nl.`, 2, 10, nil},
+		{`m\032ek.nl.`, 2, 9, nil},
+		{`miek\.`, 1, 7, nil},
+		{long + ".nl.", 2, 68, nil},
+		{long + "a.nl.", 0, 0, ErrLabelLen},
+		{strings.Repeat(long+".", 4), 0, 0, ErrNameLen},
+		{"miek..nl.", 0, 0, ErrLabelEmpty},
+		{`m\256ek.nl.`, 0, 0, ErrEscape},
+		{`m\03.nl.`, 0, 0, ErrEscape},
+		{`miek\`, 0, 0, ErrEscape},
+		{"mi ek.nl.", 0, 0, ErrNameChar},
+		{"-miek.nl.", 0, 0, ErrNameChar},
+	}
+	for _, tc := range tests {
+		labels, wirelen, err := ValidateName(tc.name)
+		if err != tc.err || labels != tc.labels || wirelen != tc.wirelen {
+			t.Errorf("ValidateName(%q) = %d, %d, %v, expected %d, %d, %v", tc.name, labels, wirelen, err, tc.labels, tc.wirelen, tc.err)
+		}
+	}
+	m := new(Msg)
+	m.SetQuestion(long+"a.nl.", TypeA)
+	if _, err := m.Pack(); err != ErrLabelLen {
+		t.Errorf("expected ErrLabelLen when packing, got %v", err)
+	}
+	if err := NewZone("nl.").Insert(&A{Hdr: RR_Header{Name: long + "a.nl.", Rrtype: TypeA, Class: ClassINET}}); err != ErrLabelLen {
+		t.Errorf("expected ErrLabelLen when inserting, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package loadgen sends streams of DNS queries to a name server at a
+// target rate and measures the latency and the rcodes of the replies, like
+// dnsperf does.
+//
+// Basic use pattern:
+//
+//	f, _ := os.Open("queries.txt") // lines with "name type"
+//	g, err := loadgen.ReadQueries(f)
+//	if err != nil {
+//		// ...
+//	}
+//	s := loadgen.Run(&loadgen.Config{Server: "127.0.0.1:53", QPS: 1000, Duration: time.Minute}, g)
+//	fmt.Printf("%d queries, %.0f qps, 99th percentile %s\n", s.Sent, s.QPS(), s.Latency.Percentile(99))
+package loadgen
+
+import (
+	"bufio"
+	"errors"
+	"github.com/Meyermagic/dns"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Generator generates the questions to send. Next is only called from a
+// single goroutine.
+type Generator interface {
+	Next() dns.Question
+}
+
+// GeneratorFunc is an adapter to use an ordinary function as a Generator.
+type GeneratorFunc func() dns.Question
+
+// Next calls f().
+func (f GeneratorFunc) Next() dns.Question { return f() }
+
+// List returns a Generator that cycles through the questions qs.
+func List(qs []dns.Question) Generator {
+	i := 0
+	return GeneratorFunc(func() dns.Question {
+		q := qs[i%len(qs)]
+		i++
+		return q
+	})
+}
+
+// ReadQueries reads the questions from r, one per line with the name and
+// the type, as in the query files of dnsperf. The type defaults to A, empty
+// lines and lines starting with ';' are skipped. The returned Generator
+// cycles through the questions.
+func ReadQueries(r io.Reader) (Generator, error) {
+	var qs []dns.Question
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], ";") {
+			continue
+		}
+		q := dns.Question{Name: dns.Fqdn(f[0]), Qtype: dns.TypeA, Qclass: dns.ClassINET}
+		if len(f) > 1 {
+			t, ok := dns.StringToType[strings.ToUpper(f[1])]
+			if !ok {
+				return nil, errors.New("loadgen: unknown type: " + f[1])
+			}
+			q.Qtype = t
+		}
+		qs = append(qs, q)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(qs) == 0 {
+		return nil, errors.New("loadgen: no queries")
+	}
+	return List(qs), nil
+}
+
+// Walk returns a Generator that cycles through all the RRsets in the
+// zone z, asking for the name and type of each of them. The zone is walked
+// once, when Walk is called.
+func Walk(z *dns.Zone) Generator {
+	var qs []dns.Question
+	z.Walk(func(rrs []dns.RR, _ []*dns.RRSIG, _ *dns.Meta) bool {
+		h := rrs[0].Header()
+		qs = append(qs, dns.Question{Name: h.Name, Qtype: h.Rrtype, Qclass: h.Class})
+		return true
+	})
+	if len(qs) == 0 {
+		qs = append(qs, dns.Question{Name: z.Origin, Qtype: dns.TypeSOA, Qclass: dns.ClassINET})
+	}
+	return List(qs)
+}
+
+// RandomSubdomains returns a Generator that asks for random names of n
+// lower case letters and digits directly below zone, with type t. As these
+// names are (most likely) not cached, this tests the worst case of a
+// resolver.
+func RandomSubdomains(zone string, t uint16, n int) Generator {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	zone = dns.Fqdn(zone)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	b := make([]byte, n)
+	return GeneratorFunc(func() dns.Question {
+		for i := range b {
+			b[i] = chars[r.Intn(len(chars))]
+		}
+		return dns.Question{Name: string(b) + "." + zone, Qtype: t, Qclass: dns.ClassINET}
+	})
+}
+
+// Config is the configuration of a load test.
+type Config struct {
+	Server      string        // The server to query, host:port
+	Net         string        // "udp" (the default), "tcp" or "tcp-tls" (DNS over TLS)
+	QPS         int           // The target rate in queries per second, if zero queries are sent as fast as possible
+	Duration    time.Duration // How long the test runs, defaults to 10 seconds when Queries is zero too
+	Queries     int           // The number of queries to send, if not zero
+	Outstanding int           // The maximum number of queries waiting for a reply, defaults to 100
+	Client      *dns.Client   // If not nil, the client to copy the settings from (timeouts, TLS, etc.)
+	Edns0       bool          // Add an OPT RR to the queries, with the DO bit set
+}
+
+// Run runs the load test described by c, with the questions from g, and
+// returns the results. Each query is sent over a new connection, as
+// dns.Client does. When the server can't keep up, the number of outstanding
+// queries limits the rate.
+func Run(c *Config, g Generator) *Stats {
+	cl := new(dns.Client)
+	if c.Client != nil {
+		*cl = *c.Client
+	}
+	if c.Net != "" {
+		cl.Net = c.Net
+	}
+	outstanding := c.Outstanding
+	if outstanding <= 0 {
+		outstanding = 100
+	}
+	duration := c.Duration
+	if duration == 0 && c.Queries == 0 {
+		duration = 10 * time.Second
+	}
+
+	s := newStats()
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan bool, outstanding)
+	)
+	start := time.Now()
+	for n := 0; c.Queries == 0 || n < c.Queries; n++ {
+		if c.QPS > 0 {
+			at := start.Add(time.Duration(n) * time.Second / time.Duration(c.QPS))
+			if d := at.Sub(time.Now()); d > 0 {
+				time.Sleep(d)
+			}
+		}
+		if duration > 0 && time.Since(start) >= duration {
+			break
+		}
+		m := new(dns.Msg)
+		m.Id = dns.Id()
+		m.RecursionDesired = true
+		m.Question = []dns.Question{g.Next()}
+		if c.Edns0 {
+			m.SetEdns0(4096, true)
+		}
+		sem <- true
+		s.Sent++
+		wg.Add(1)
+		go func(m *dns.Msg) {
+			defer wg.Done()
+			r, rtt, err := cl.Exchange(m, c.Server)
+			<-sem
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				s.Errors++
+				return
+			}
+			s.Received++
+			s.Rcodes[r.Rcode]++
+			s.Latency.Add(rtt)
+		}(m)
+	}
+	wg.Wait()
+	s.Elapsed = time.Since(start)
+	return s
+}
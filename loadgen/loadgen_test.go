@@ -0,0 +1,80 @@
+package loadgen
+
+import (
+	"github.com/Meyermagic/dns"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// server answers queries for names starting with "nx" with NXDOMAIN and
+// the others with NOERROR.
+func server(t *testing.T) string {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, a, err := l.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := new(dns.Msg)
+			if req.Unpack(buf[:n]) != nil {
+				continue
+			}
+			m := new(dns.Msg)
+			m.SetReply(req)
+			if strings.HasPrefix(req.Question[0].Name, "nx") {
+				m.Rcode = dns.RcodeNameError
+			}
+			out, _ := m.Pack()
+			l.WriteTo(out, a)
+		}
+	}()
+	return l.LocalAddr().String()
+}
+
+func TestRun(t *testing.T) {
+	g, err := ReadQueries(strings.NewReader("; comment\nwww.miek.nl A\n\nnx.miek.nl aaaa\n"))
+	if err != nil {
+		t.Fatalf("failed to read queries: %s", err.Error())
+	}
+	s := Run(&Config{Server: server(t), QPS: 200, Queries: 20}, g)
+	if s.Sent != 20 || s.Received != 20 || s.Rcodes[dns.RcodeSuccess] != 10 || s.Rcodes[dns.RcodeNameError] != 10 {
+		t.Errorf("unexpected results:\n%s", s)
+	}
+	if s.Elapsed < 90*time.Millisecond {
+		t.Errorf("target rate exceeded: 20 queries in %s", s.Elapsed)
+	}
+	if s.Latency.Count != 20 || s.Latency.Percentile(50) > s.Latency.Max {
+		t.Errorf("bad latency histogram:\n%s", s.Latency)
+	}
+	if _, err := ReadQueries(strings.NewReader("www.miek.nl BOGUS\n")); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	g := RandomSubdomains("miek.nl", dns.TypeA, 12)
+	q1, q2 := g.Next(), g.Next()
+	if q1.Name == q2.Name || len(q1.Name) != len("123456789012.miek.nl.") {
+		t.Errorf("bad random names: %s %s", q1.Name, q2.Name)
+	}
+	z := dns.NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. NS ns.miek.nl.", "www.miek.nl. A 192.0.2.1", "www.miek.nl. AAAA 2001:db8::1"} {
+		rr, _ := dns.NewRR(s)
+		z.Insert(rr)
+	}
+	g = Walk(z)
+	seen := make(map[dns.Question]bool)
+	for i := 0; i < 6; i++ {
+		seen[g.Next()] = true
+	}
+	if len(seen) != 3 || !seen[dns.Question{Name: "www.miek.nl.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}] {
+		t.Errorf("bad zone walk: %v", seen)
+	}
+}
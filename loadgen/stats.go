@@ -0,0 +1,122 @@
+package loadgen
+
+import (
+	"fmt"
+	"github.com/Meyermagic/dns"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats are the results of a load test.
+type Stats struct {
+	Sent     int           // Queries sent
+	Received int           // Replies received
+	Errors   int           // Queries that failed, mostly time outs
+	Rcodes   map[int]int   // Number of replies per rcode
+	Latency  *Histogram    // The latency of the replies
+	Elapsed  time.Duration // How long the test ran
+}
+
+func newStats() *Stats {
+	return &Stats{Rcodes: make(map[int]int), Latency: NewHistogram()}
+}
+
+// QPS returns the number of replies received per second.
+func (s *Stats) QPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Received) / s.Elapsed.Seconds()
+}
+
+func (s *Stats) String() string {
+	str := fmt.Sprintf("sent: %d, received: %d, errors: %d, elapsed: %s, qps: %.1f\n", s.Sent, s.Received, s.Errors, s.Elapsed, s.QPS())
+	rcodes := make([]int, 0, len(s.Rcodes))
+	for r := range s.Rcodes {
+		rcodes = append(rcodes, r)
+	}
+	sort.Ints(rcodes)
+	for _, r := range rcodes {
+		str += fmt.Sprintf("%-10s %d\n", dns.RcodeToString[r], s.Rcodes[r])
+	}
+	return str + s.Latency.String()
+}
+
+// Bounds are the upper bounds of the buckets of a Histogram, the last
+// bucket holds everything that is slower.
+var Bounds = []time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+}
+
+// Histogram is a latency histogram, with the buckets from Bounds. It's
+// not safe for concurrent use.
+type Histogram struct {
+	Counts []int         // Counts[i] is the number of latencies up to Bounds[i], the last one holds the rest
+	Count  int           // The number of latencies added
+	Min    time.Duration // The lowest latency
+	Max    time.Duration // The highest latency
+	Total  time.Duration // The sum of all latencies
+}
+
+// NewHistogram returns an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{Counts: make([]int, len(Bounds)+1)}
+}
+
+// Add adds the latency d to h.
+func (h *Histogram) Add(d time.Duration) {
+	i := sort.Search(len(Bounds), func(i int) bool { return d <= Bounds[i] })
+	h.Counts[i]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Count++
+	h.Total += d
+}
+
+// Mean returns the average latency.
+func (h *Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.Count)
+}
+
+// Percentile returns the upper bound of the bucket that holds the p-th
+// percentile (0 < p <= 100) of the latencies, or Max when that is lower.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	n := 0
+	for i, c := range h.Counts {
+		n += c
+		if float64(n) >= p/100*float64(h.Count) && n > 0 {
+			if i < len(Bounds) && Bounds[i] < h.Max {
+				return Bounds[i]
+			}
+			return h.Max
+		}
+	}
+	return h.Max
+}
+
+func (h *Histogram) String() string {
+	s := fmt.Sprintf("latency min/avg/max: %s/%s/%s\n", h.Min, h.Mean(), h.Max)
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		bound := "inf"
+		if i < len(Bounds) {
+			bound = Bounds[i].String()
+		}
+		s += fmt.Sprintf("<= %-8s %6d %s\n", bound, c, strings.Repeat("#", c*40/h.Count))
+	}
+	return s
+}
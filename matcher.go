@@ -0,0 +1,226 @@
+// QUESTION MATCHING
+//
+// A QuestionMatcher matches the question of a query on the name and the
+// type. Name patterns come in three forms:
+//
+//	www.example.org.      ; exactly this name
+//	*.example.org.        ; all names below example.org. (but not example.org. itself)
+//	\*.example.org.       ; the name with a literal asterisk as its first label
+//	~^ad[0-9]+\.          ; a regular expression, matched against the lower cased name
+//
+// Names are compared like ServeMux does: case insensitively, after undoing
+// escapes and with internationalized names in their ACE ("xn--") form. A
+// name matches when it matches one of the patterns; with no name patterns
+// all names match. Likewise, the type must be one of the types, if any are
+// given. Matchers are used by ServeMux.HandleMatch, RewriteRule.Matcher and
+// ACL, so routing, rewriting and access control can be expressed
+// declaratively. Basic use pattern, match A and AAAA queries for
+// internal.example. and everything below it:
+//
+//	m, err := dns.NewQuestionMatcher("internal.example.", "*.internal.example.")
+//	m.AddType(dns.TypeA, dns.TypeAAAA)
+//	dns.DefaultServeMux.HandleMatch(m, handler)
+package dns
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// QuestionMatcher matches questions on name patterns and a set of types. It's
+// safe for concurrent use by multiple goroutines.
+type QuestionMatcher struct {
+	exact map[string]bool // exact names
+	below map[string]bool // names below which all names match
+	res   []*regexp.Regexp
+	types map[uint16]bool
+	*sync.RWMutex
+}
+
+// NewQuestionMatcher returns a QuestionMatcher for the name patterns.
+func NewQuestionMatcher(patterns ...string) (*QuestionMatcher, error) {
+	qm := &QuestionMatcher{exact: make(map[string]bool), below: make(map[string]bool),
+		types: make(map[uint16]bool), RWMutex: new(sync.RWMutex)}
+	for _, p := range patterns {
+		if err := qm.AddName(p); err != nil {
+			return nil, err
+		}
+	}
+	return qm, nil
+}
+
+// AddName adds the name pattern p.
+func (qm *QuestionMatcher) AddName(p string) error {
+	if strings.HasPrefix(p, "~") {
+		re, err := regexp.Compile(p[1:])
+		if err != nil {
+			return err
+		}
+		qm.Lock()
+		qm.res = append(qm.res, re)
+		qm.Unlock()
+		return nil
+	}
+	wild := strings.HasPrefix(p, "*.") || p == "*"
+	if wild {
+		p = strings.TrimPrefix(p[1:], ".")
+		if p == "" {
+			p = "."
+		}
+	}
+	f := foldName(p)
+	if _, _, ok := IsDomainName(f); !ok {
+		return &Error{Err: "bad domain name", Name: p}
+	}
+	p = f
+	qm.Lock()
+	if wild {
+		qm.below[p] = true
+	} else {
+		qm.exact[p] = true
+	}
+	qm.Unlock()
+	return nil
+}
+
+// AddType adds the types to the types that match.
+func (qm *QuestionMatcher) AddType(t ...uint16) {
+	qm.Lock()
+	for _, t1 := range t {
+		qm.types[t1] = true
+	}
+	qm.Unlock()
+}
+
+// MatchName returns true when name matches one of the name patterns, or
+// when there are none.
+func (qm *QuestionMatcher) MatchName(name string) bool {
+	qm.RLock()
+	defer qm.RUnlock()
+	return qm.matchName(name)
+}
+
+func (qm *QuestionMatcher) matchName(name string) bool {
+	if len(qm.exact) == 0 && len(qm.below) == 0 && len(qm.res) == 0 {
+		return true
+	}
+	f := foldName(name)
+	if qm.exact[f] {
+		return true
+	}
+	if len(qm.below) > 0 && f != "." {
+		if qm.below["."] {
+			return true
+		}
+		for off, end := NextLabelOffset(f, 0); !end; off, end = NextLabelOffset(f, off) {
+			if qm.below[f[off:]] {
+				return true
+			}
+		}
+	}
+	for _, re := range qm.res {
+		if re.MatchString(Fqdn(strings.ToLower(name))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true when both the name and the type of q match.
+func (qm *QuestionMatcher) Match(q Question) bool {
+	qm.RLock()
+	defer qm.RUnlock()
+	if len(qm.types) > 0 && !qm.types[q.Qtype] {
+		return false
+	}
+	return qm.matchName(q.Name)
+}
+
+// ACL is a Handler wrapper that refuses or allows queries based on the
+// question and the address of the client. The rules are tried in order and
+// the first matching rule decides, when no rule matches the query is
+// allowed. It's safe for concurrent use by multiple goroutines.
+//
+//	acl := dns.NewACL()
+//	acl.Allow(internal, corpNet)
+//	acl.Deny(internal)           // from everywhere else
+//	dns.Handle(".", acl.Wrap(handler))
+type ACL struct {
+	rules []aclRule
+	*sync.RWMutex
+}
+
+type aclRule struct {
+	allow bool
+	qm    *QuestionMatcher // nil matches all questions
+	nets  []*net.IPNet     // empty matches all clients
+}
+
+// NewACL returns an ACL without any rules.
+func NewACL() *ACL {
+	return &ACL{RWMutex: new(sync.RWMutex)}
+}
+
+// Allow adds a rule that allows queries matching qm from clients in one of
+// the networks nets. With no networks the rule matches all clients.
+func (a *ACL) Allow(qm *QuestionMatcher, nets ...*net.IPNet) {
+	a.Lock()
+	a.rules = append(a.rules, aclRule{true, qm, nets})
+	a.Unlock()
+}
+
+// Deny adds a rule that refuses queries matching qm from clients in one of
+// the networks nets. With no networks the rule matches all clients.
+func (a *ACL) Deny(qm *QuestionMatcher, nets ...*net.IPNet) {
+	a.Lock()
+	a.rules = append(a.rules, aclRule{false, qm, nets})
+	a.Unlock()
+}
+
+// Allowed returns true when the query with question q from the client ip
+// is allowed.
+func (a *ACL) Allowed(q Question, ip net.IP) bool {
+	a.RLock()
+	defer a.RUnlock()
+	for _, r := range a.rules {
+		if r.qm != nil && !r.qm.Match(q) {
+			continue
+		}
+		if len(r.nets) == 0 {
+			return r.allow
+		}
+		for _, n := range r.nets {
+			if ip != nil && n.Contains(ip) {
+				return r.allow
+			}
+		}
+	}
+	return true
+}
+
+// Wrap returns a Handler that answers REFUSED to the queries the ACL does not
+// allow and hands all other queries to h.
+func (a *ACL) Wrap(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, req *Msg) {
+		if len(req.Question) == 1 && !a.Allowed(req.Question[0], addrIP(w.RemoteAddr())) {
+			m := new(Msg)
+			m.SetRcode(req, RcodeRefused)
+			w.WriteMsg(m)
+			return
+		}
+		h.ServeDNS(w, req)
+	})
+}
+
+// addrIP returns the IP address of a, or nil.
+func addrIP(a net.Addr) net.IP {
+	switch a := a.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+	return nil
+}
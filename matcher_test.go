@@ -0,0 +1,49 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+func TestQuestionMatcher(t *testing.T) {
+	qm, err := NewQuestionMatcher("internal.example.", "*.internal.example.", `~^ad[0-9]+\.`, `\*.literal.example.`, "*.bücher.example.")
+	if err != nil {
+		t.Fatalf("failed to create matcher: %s", err.Error())
+	}
+	qm.AddType(TypeA, TypeAAAA)
+	for _, test := range []struct {
+		q     Question
+		match bool
+	}{
+		{Question{"internal.example.", TypeA, ClassINET}, true},
+		{Question{"WWW.Internal.Example.", TypeAAAA, ClassINET}, true},
+		{Question{"a.b.internal.example.", TypeA, ClassINET}, true},
+		{Question{"a.b.internal.example.", TypeMX, ClassINET}, false},
+		{Question{"external.example.", TypeA, ClassINET}, false},
+		{Question{"xinternal.example.", TypeA, ClassINET}, false},
+		{Question{"ad12.tracker.example.", TypeA, ClassINET}, true},
+		{Question{"*.literal.example.", TypeA, ClassINET}, true},
+		{Question{"www.literal.example.", TypeA, ClassINET}, false},
+		{Question{"www.XN--BCHER-KVA.example.", TypeA, ClassINET}, true},
+		{Question{"www.b\\195\\188cher.example.", TypeA, ClassINET}, true},
+	} {
+		if qm.Match(test.q) != test.match {
+			t.Errorf("match of %s %s should be %t", test.q.Name, TypeToString[test.q.Qtype], test.match)
+		}
+	}
+
+	_, corp, _ := net.ParseCIDR("10.0.0.0/8")
+	acl := NewACL()
+	acl.Allow(qm, corp)
+	acl.Deny(qm)
+	q := Question{"www.internal.example.", TypeA, ClassINET}
+	if !acl.Allowed(q, net.ParseIP("10.1.2.3")) {
+		t.Error("query from the corporate network should be allowed")
+	}
+	if acl.Allowed(q, net.ParseIP("192.0.2.1")) {
+		t.Error("query from outside should be refused")
+	}
+	if !acl.Allowed(Question{"www.example.", TypeA, ClassINET}, net.ParseIP("192.0.2.1")) {
+		t.Error("query not matching any rule should be allowed")
+	}
+}
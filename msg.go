@@ -13,6 +13,7 @@
 package dns
 
 import (
+	crand "crypto/rand"
 	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
@@ -21,33 +22,53 @@ import (
 	"net"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 const maxCompressionOffset = 2 << 13 // We have 14 bits for the compression pointer
 
 var (
-	ErrFqdn      error = &Error{Err: "domain must be fully qualified"}
-	ErrId        error = &Error{Err: "id mismatch"}
-	ErrRdata     error = &Error{Err: "bad rdata"}
-	ErrBuf       error = &Error{Err: "buffer size too small"}
-	ErrShortRead error = &Error{Err: "short read"}
-	ErrConn      error = &Error{Err: "conn holds both UDP and TCP connection"}
-	ErrConnEmpty error = &Error{Err: "conn has no connection"}
-	ErrServ      error = &Error{Err: "no servers could be reached"}
-	ErrKey       error = &Error{Err: "bad key"}
-	ErrPrivKey   error = &Error{Err: "bad private key"}
-	ErrKeySize   error = &Error{Err: "bad key size"}
-	ErrKeyAlg    error = &Error{Err: "bad key algorithm"}
-	ErrAlg       error = &Error{Err: "bad algorithm"}
-	ErrTime      error = &Error{Err: "bad time"}
-	ErrNoSig     error = &Error{Err: "no signature found"}
-	ErrSig       error = &Error{Err: "bad signature"}
-	ErrSecret    error = &Error{Err: "no secrets defined"}
-	ErrSigGen    error = &Error{Err: "bad signature generation"}
-	ErrAuth      error = &Error{Err: "bad authentication"}
-	ErrSoa       error = &Error{Err: "no SOA"}
-	ErrRRset     error = &Error{Err: "bad rrset"}
+	ErrFqdn        error = &Error{Err: "domain must be fully qualified"}
+	ErrId          error = &Error{Err: "id mismatch"}
+	ErrRdata       error = &Error{Err: "bad rdata"}
+	ErrBuf         error = &Error{Err: "buffer size too small"}
+	ErrShortRead   error = &Error{Err: "short read"}
+	ErrConn        error = &Error{Err: "conn holds both UDP and TCP connection"}
+	ErrConnEmpty   error = &Error{Err: "conn has no connection"}
+	ErrServ        error = &Error{Err: "no servers could be reached"}
+	ErrKey         error = &Error{Err: "bad key"}
+	ErrPrivKey     error = &Error{Err: "bad private key"}
+	ErrKeySize     error = &Error{Err: "bad key size"}
+	ErrKeyAlg      error = &Error{Err: "bad key algorithm"}
+	ErrAlg         error = &Error{Err: "bad algorithm"}
+	ErrTime        error = &Error{Err: "bad time"}
+	ErrNoSig       error = &Error{Err: "no signature found"}
+	ErrSig         error = &Error{Err: "bad signature"}
+	ErrBogus       error = &Error{Err: "bogus reply"}
+	ErrSecret      error = &Error{Err: "no secrets defined"}
+	ErrSigGen      error = &Error{Err: "bad signature generation"}
+	ErrAuth        error = &Error{Err: "bad authentication"}
+	ErrSoa         error = &Error{Err: "no SOA"}
+	ErrRRset       error = &Error{Err: "bad rrset"}
+	ErrShortWrite  error = &Error{Err: "short write"}
+	ErrMsgTooLarge error = &Error{Err: "message too large"}
+	ErrTLSA        error = &Error{Err: "no matching TLSA record"}
+	ErrLabelLen    error = &Error{Err: "label too long"}
+	ErrNameLen     error = &Error{Err: "domain name too long"}
+	ErrLabelEmpty  error = &Error{Err: "empty label"}
+	ErrEscape      error = &Error{Err: "bad escape"}
+	ErrNameChar    error = &Error{Err: "bad character in domain name"}
+	ErrTimeout     error = &Error{Err: "timeout", Timeout: true}
+	ErrTruncated   error = &Error{Err: "truncated reply"}
+	ErrIncomplete  error = &Error{Err: "incomplete transfer"}
+	ErrTxnDone     error = &Error{Err: "transaction is already committed or rolled back"}
+	ErrRejected    error = &Error{Err: "reply rejected"}
+	ErrRcode       error = &Error{Err: "rcode error"}
+	ErrTsig        error = &Error{Err: "tsig error"}
+	ErrParse       error = &Error{Err: "parse error"}
+	ErrNoQUIC      error = &Error{Err: "quic not supported, build with the quic tag"}
 )
 
 // A manually-unpacked version of (id, bits).
@@ -80,7 +101,7 @@ type Msg struct {
 var TypeToString = map[uint16]string{
 	TypeCNAME:      "CNAME",
 	TypeHINFO:      "HINFO",
-	TypeTLSA:       "TSLA",
+	TypeTLSA:       "TLSA",
 	TypeMB:         "MB",
 	TypeMG:         "MG",
 	TypeRP:         "RP",
@@ -111,10 +132,13 @@ var TypeToString = map[uint16]string{
 	TypeOPT:        "OPT",
 	TypeDS:         "DS",
 	TypeDHCID:      "DHCID",
+	TypeOPENPGPKEY: "OPENPGPKEY",
 	TypeHIP:        "HIP",
 	TypeNINFO:      "NINFO",
 	TypeRKEY:       "RKEY",
 	TypeCDS:        "CDS",
+	TypeCDNSKEY:    "CDNSKEY",
+	TypeZONEMD:     "ZONEMD",
 	TypeCAA:        "CAA",
 	TypeIPSECKEY:   "IPSECKEY",
 	TypeSSHFP:      "SSHFP",
@@ -137,6 +161,11 @@ var TypeToString = map[uint16]string{
 	TypeURI:        "URI",
 	TypeTA:         "TA",
 	TypeDLV:        "DLV",
+	TypeSIG:        "SIG",
+	TypeKEY:        "KEY",
+	TypeNXT:        "NXT",
+	TypeMAILB:      "MAILB", // Meta RR
+	TypeMAILA:      "MAILA", // Meta RR
 }
 
 // Reverse, needed for string parsing.
@@ -149,6 +178,47 @@ var StringToOpcode = reverseInt(OpcodeToString)
 // Map of rcodes strings.
 var StringToRcode = reverseInt(RcodeToString)
 
+// ParseType returns the type named s (case insensitive), e.g. "AAAA".
+// Types can also be given in the generic form of RFC 3597, e.g. "TYPE65".
+func ParseType(s string) (uint16, error) {
+	t, err := parseCode(s, "type", "TYPE", func(s string) (int, bool) { t, ok := StringToType[s]; return int(t), ok }, 0xFFFF)
+	return uint16(t), err
+}
+
+// ParseClass returns the class named s (case insensitive), e.g. "CH".
+// Classes can also be given in the generic form of RFC 3597, e.g. "CLASS3".
+func ParseClass(s string) (uint16, error) {
+	c, err := parseCode(s, "class", "CLASS", func(s string) (int, bool) { c, ok := StringToClass[s]; return int(c), ok }, 0xFFFF)
+	return uint16(c), err
+}
+
+// ParseOpcode returns the opcode named s (case insensitive), e.g. "NOTIFY",
+// or given as a number.
+func ParseOpcode(s string) (int, error) {
+	return parseCode(s, "opcode", "", func(s string) (int, bool) { o, ok := StringToOpcode[s]; return o, ok }, 0xF)
+}
+
+// ParseRcode returns the rcode named s (case insensitive), e.g. "NXDOMAIN",
+// or given as a number. Extended rcodes (up to 4095) are allowed.
+func ParseRcode(s string) (int, error) {
+	return parseCode(s, "rcode", "", func(s string) (int, bool) { r, ok := StringToRcode[s]; return r, ok }, 0xFFF)
+}
+
+// parseCode looks up the what named s with lookup, or parses s as prefix
+// followed by a number not larger than max.
+func parseCode(s, what, prefix string, lookup func(string) (int, bool), max int) (int, error) {
+	u := strings.ToUpper(s)
+	if c, ok := lookup(u); ok {
+		return c, nil
+	}
+	if strings.HasPrefix(u, prefix) {
+		if c, err := strconv.Atoi(u[len(prefix):]); err == nil && c >= 0 && c <= max {
+			return c, nil
+		}
+	}
+	return 0, &Error{Err: "unknown " + what, Name: s}
+}
+
 // Map of strings for each CLASS wire type.
 var ClassToString = map[uint16]string{
 	ClassINET:   "IN",
@@ -300,6 +370,9 @@ func PackDomainName(s string, msg []byte, off int, compression map[string]int, c
 		off = nameoffset + 1
 		goto End
 	}
+	if off >= lenmsg {
+		return lenmsg, ErrBuf
+	}
 	msg[off] = 0
 End:
 	off++
@@ -425,7 +498,7 @@ func packStructValue(val reflect.Value, msg []byte, off int, compression map[str
 			case `dns:"opt"`: // edns
 				for j := 0; j < val.Field(i).Len(); j++ {
 					element := val.Field(i).Index(j).Interface()
-					b, e := element.(EDNS0).pack()
+					b, e := element.(EDNS0).Pack()
 					if e != nil {
 						return lenmsg, &Error{Err: "overflow packing opt"}
 					}
@@ -475,17 +548,18 @@ func packStructValue(val reflect.Value, msg []byte, off int, compression map[str
 				if val.Field(i).Len() == 0 {
 					break
 				}
-				var bitmapbyte uint16
+				bitmap := 0 // up to the octet of the highest port
 				for j := 0; j < val.Field(i).Len(); j++ {
-					serv := uint16((fv.Index(j).Uint()))
-					bitmapbyte = uint16(serv / 8)
-					if int(bitmapbyte) > lenmsg {
+					serv := int(fv.Index(j).Uint())
+					if off+serv/8 >= lenmsg {
 						return lenmsg, &Error{Err: "overflow packing wks"}
 					}
-					bit := uint16(serv) - bitmapbyte*8
-					msg[bitmapbyte] = byte(1 << (7 - bit))
+					msg[off+serv/8] |= byte(1 << (7 - uint(serv%8)))
+					if serv/8+1 > bitmap {
+						bitmap = serv/8 + 1
+					}
 				}
-				off += int(bitmapbyte)
+				off += bitmap
 			case `dns:"nsec"`: // NSEC/NSEC3
 				// This is the uint16 type bitmap
 				if val.Field(i).Len() == 0 {
@@ -718,41 +792,23 @@ func unpackStructValue(val reflect.Value, msg []byte, off int) (off1 int, err er
 					break
 				}
 				edns := make([]EDNS0, 0)
-			Option:
-				code := uint16(0)
-				if off+2 > lenmsg {
-					return lenmsg, &Error{Err: "overflow unpacking opt"}
-				}
-				code, off = unpackUint16(msg, off)
-				optlen, off1 := unpackUint16(msg, off)
-				if off1+int(optlen) > off+rdlength {
-					return lenmsg, &Error{Err: "overflow unpacking opt"}
-				}
-				switch code {
-				case EDNS0NSID:
-					e := new(EDNS0_NSID)
-					e.unpack(msg[off1 : off1+int(optlen)])
-					edns = append(edns, e)
-					off = off1 + int(optlen)
-				case EDNS0SUBNET:
-					e := new(EDNS0_SUBNET)
-					e.unpack(msg[off1 : off1+int(optlen)])
-					edns = append(edns, e)
-					off = off1 + int(optlen)
-				case EDNS0UPDATELEASE:
-					e := new(EDNS0_UPDATE_LEASE)
-					e.unpack(msg[off1 : off1+int(optlen)])
-					edns = append(edns, e)
-					off = off1 + int(optlen)
-				case EDNS0LLQ:
-					e := new(EDNS0_LLQ)
-					e.unpack(msg[off1 : off1+int(optlen)])
+				end := off + rdlength
+				for off < end {
+					if off+4 > end {
+						return lenmsg, &Error{Err: "overflow unpacking opt"}
+					}
+					code, off1 := unpackUint16(msg, off)
+					optlen, off1 := unpackUint16(msg, off1)
+					if off1+int(optlen) > end {
+						return lenmsg, &Error{Err: "overflow unpacking opt"}
+					}
+					e := newEDNS0(code)
+					if err := e.Unpack(msg[off1 : off1+int(optlen)]); err != nil {
+						return lenmsg, err
+					}
 					edns = append(edns, e)
 					off = off1 + int(optlen)
 				}
-				if off < rdlength {
-					goto Option
-				}
 				fv.Set(reflect.ValueOf(edns))
 			case `dns:"a"`:
 				if off+net.IPv4len > lenmsg {
@@ -1065,14 +1121,21 @@ func packBase32(s []byte) ([]byte, error) {
 	return buf, nil
 }
 
+//go:generate go run msg_generate.go
+
 // Resource record packer, pack rr into msg[off:]. See PackDomainName for documentation
-// about the compression.
+// about the compression. Most types are packed by the generated functions
+// in zmsg.go, the others with reflection.
 func PackRR(rr RR, msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
 	if rr == nil {
 		return len(msg), &Error{Err: "nil rr"}
 	}
 
-	off1, err = packStructCompress(rr, msg, off, compression, compress)
+	if p, ok := rr.(rrPacker); ok {
+		off1, err = p.pack(msg, off, compression, compress)
+	} else {
+		off1, err = packStructCompress(rr, msg, off, compression, compress)
+	}
 	if err != nil {
 		return len(msg), err
 	}
@@ -1085,10 +1148,13 @@ func UnpackRR(msg []byte, off int) (rr RR, off1 int, err error) {
 	// unpack just the header, to find the rr type and length
 	var h RR_Header
 	off0 := off
-	if off, err = UnpackStruct(&h, msg, off); err != nil {
+	if off, err = h.unpack(msg, off); err != nil {
 		return nil, len(msg), err
 	}
 	end := off + int(h.Rdlength)
+	if end > len(msg) {
+		return nil, len(msg), ErrRdata
+	}
 	// make an rr of that type and re-unpack.
 	mk, known := rr_mk[h.Rrtype]
 	if !known {
@@ -1096,7 +1162,12 @@ func UnpackRR(msg []byte, off int) (rr RR, off1 int, err error) {
 	} else {
 		rr = mk()
 	}
-	off, err = UnpackStruct(rr, msg, off0)
+	if u, ok := rr.(rrPacker); ok {
+		*rr.Header() = h
+		off, err = u.unpack(msg, off, end)
+	} else {
+		off, err = UnpackStruct(rr, msg, off0)
+	}
 	if off != end {
 		return &h, end, nil
 	}
@@ -1137,9 +1208,13 @@ func (h *MsgHdr) String() string {
 	if h == nil {
 		return "<nil> MsgHdr"
 	}
+	return h.string(h.Rcode)
+}
 
-	s := ";; opcode: " + OpcodeToString[h.Opcode]
-	s += ", status: " + RcodeToString[h.Rcode]
+// string returns the header like dig prints it, with rcode as the status.
+func (h *MsgHdr) string(rcode int) string {
+	s := ";; ->>HEADER<<- opcode: " + opcodeString(h.Opcode)
+	s += ", status: " + rcodeString(rcode)
 	s += ", id: " + strconv.Itoa(int(h.Id)) + "\n"
 
 	s += ";; flags:"
@@ -1172,9 +1247,32 @@ func (h *MsgHdr) String() string {
 	return s
 }
 
+// opcodeString returns the name of the opcode, dig style: unknown
+// opcodes are RESERVEDn.
+func opcodeString(opcode int) string {
+	if s, ok := OpcodeToString[opcode]; ok {
+		return s
+	}
+	return "RESERVED" + strconv.Itoa(opcode)
+}
+
+// rcodeString returns the name of the rcode, dig style: unknown rcodes
+// are RESERVEDn.
+func rcodeString(rcode int) string {
+	if s, ok := RcodeToString[rcode]; ok {
+		return s
+	}
+	return "RESERVED" + strconv.Itoa(rcode)
+}
+
 // Pack packs a Msg: it is converted to to wire format.
 // If the dns.Compress is true the message will be in compressed wire format.
 func (dns *Msg) Pack() (msg []byte, err error) {
+	for _, q := range dns.Question {
+		if err := checkName(q.Name); err != nil {
+			return nil, err
+		}
+	}
 	var dh Header
 	var compression map[string]int
 	if dns.Compress {
@@ -1317,11 +1415,25 @@ func (dns *Msg) String() string {
 	if dns == nil {
 		return "<nil> MsgHdr"
 	}
-	s := dns.MsgHdr.String() + " "
+	rcode := dns.Rcode
+	var opt *OPT
+	for _, r := range dns.Extra {
+		if o, ok := r.(*OPT); ok && opt == nil {
+			opt = o
+		}
+	}
+	if opt != nil {
+		// The upper 8 bits of the extended rcode are in the OPT RR
+		rcode |= int(opt.Hdr.Ttl>>24) << 4
+	}
+	s := dns.MsgHdr.string(rcode) + " "
 	s += "QUERY: " + strconv.Itoa(len(dns.Question)) + ", "
 	s += "ANSWER: " + strconv.Itoa(len(dns.Answer)) + ", "
 	s += "AUTHORITY: " + strconv.Itoa(len(dns.Ns)) + ", "
 	s += "ADDITIONAL: " + strconv.Itoa(len(dns.Extra)) + "\n"
+	if opt != nil {
+		s += opt.String() + "\n"
+	}
 	if len(dns.Question) > 0 {
 		s += "\n;; QUESTION SECTION:\n"
 		for i := 0; i < len(dns.Question); i++ {
@@ -1344,79 +1456,159 @@ func (dns *Msg) String() string {
 			}
 		}
 	}
-	if len(dns.Extra) > 0 {
-		s += "\n;; ADDITIONAL SECTION:\n"
-		for i := 0; i < len(dns.Extra); i++ {
-			if dns.Extra[i] != nil {
-				s += dns.Extra[i].String() + "\n"
-			}
+	extra := ""
+	for i := 0; i < len(dns.Extra); i++ {
+		// The OPT RR is shown in the pseudosection above
+		if dns.Extra[i] != nil && dns.Extra[i] != RR(opt) {
+			extra += dns.Extra[i].String() + "\n"
 		}
 	}
+	if extra != "" {
+		s += "\n;; ADDITIONAL SECTION:\n" + extra
+	}
 	return s
 }
 
-// Len return the message length when in (un)compressed wire format.
-// If dns.Compress is true compression is taken into account, currently
-// this only counts owner name compression. There is no check for 
-// nil valued sections (allocated, but contains no RRs).
+// Len returns the message length when in (un)compressed wire format.
+// If dns.Compress is true compression is taken into account: the owner
+// names and the compressible names in the rdata (of NS, CNAME, MX, SOA,
+// etc.) are counted with the compression pointers Pack emits for them.
+// The result is never smaller than the packed message, names with escapes
+// are counted uncompressed. There is no check for nil valued sections
+// (allocated, but contains no RRs).
 func (dns *Msg) Len() int {
-	// Message header is always 12 bytes       
+	// Message header is always 12 bytes
 	l := 12
-	var compression map[string]int
+	var compression map[string]struct{}
 	if dns.Compress {
-		compression = make(map[string]int)
+		compression = make(map[string]struct{})
 	}
-
 	for i := 0; i < len(dns.Question); i++ {
-		l += dns.Question[i].Len()
-		if dns.Compress {
-			compressionHelper(compression, dns.Question[i].Name)
-		}
+		q := &dns.Question[i]
+		l += q.Len() - (len(q.Name) + 1) + compressedNameLen(compression, q.Name, l)
 	}
-	for i := 0; i < len(dns.Answer); i++ {
-		if dns.Compress {
-			if v, ok := compression[dns.Answer[i].Header().Name]; ok {
-				l += dns.Answer[i].Len() - v
-				continue
-			}
-			compressionHelper(compression, dns.Answer[i].Header().Name)
+	for _, section := range [][]RR{dns.Answer, dns.Ns, dns.Extra} {
+		for _, r := range section {
+			l += compressedLen(compression, r, l)
 		}
-		l += dns.Answer[i].Len()
 	}
-	for i := 0; i < len(dns.Ns); i++ {
-		if dns.Compress {
-			if v, ok := compression[dns.Ns[i].Header().Name]; ok {
-				l += dns.Ns[i].Len() - v
-				continue
-			}
-			compressionHelper(compression, dns.Ns[i].Header().Name)
-		}
-		l += dns.Ns[i].Len()
+	return l
+}
+
+// compressedLen returns the length of r when packed at offset off, with the
+// names seen so far in compression. The names of r are added to it.
+func compressedLen(compression map[string]struct{}, r RR, off int) int {
+	l := r.Len()
+	if compression == nil {
+		return l
 	}
-	for i := 0; i < len(dns.Extra); i++ {
-		if dns.Compress {
-			if v, ok := compression[dns.Extra[i].Header().Name]; ok {
-				l += dns.Extra[i].Len() - v
-				continue
-			}
-			compressionHelper(compression, dns.Extra[i].Header().Name)
-		}
-		l += dns.Extra[i].Len()
+	name := r.Header().Name
+	l += compressedNameLen(compression, name, off) - (len(name) + 1)
+	// The rdata names are somewhere in the rdata, offset off+l is after them.
+	for _, s := range rdataNames(r) {
+		l += compressedNameLen(compression, s, off+l) - (len(s) + 1)
 	}
 	return l
 }
 
-func compressionHelper(c map[string]int, s string) {
-	pref := ""
-	lbs := SplitLabels(s)
-	for j := len(lbs) - 1; j >= 0; j-- {
-		c[lbs[j]+"."+pref] = 1 + len(pref) + len(lbs[j])
-		pref = lbs[j] + "." + pref
+// compressedNameLen returns the length of the domain name s when packed at
+// offset off, like PackDomainName does: up to the first suffix that is in
+// compression, which is replaced by a pointer. The suffixes of s are added
+// to compression, when their offset can be pointed to. Offsets are an upper
+// bound, so a suffix that is not added here may still get one in Pack.
+func compressedNameLen(compression map[string]struct{}, s string, off int) int {
+	if compression == nil || s == "." || !IsFqdn(s) || strings.IndexByte(s, '\\') >= 0 {
+		return len(s) + 1
+	}
+	l := 0
+	for i := 0; i < len(s); {
+		if _, ok := compression[s[i:]]; ok {
+			return l + 2
+		}
+		if off+l < maxCompressionOffset {
+			compression[s[i:]] = struct{}{}
+		}
+		j := strings.IndexByte(s[i:], '.') + 1
+		l += j
+		i += j
 	}
+	return l + 1
 }
 
-// Id return a 16 bits random number to be used as a
-// message id. The random provided should be good enough.
+// rdataNames returns the names in the rdata of r that Pack compresses, in
+// the order in which they are packed.
+func rdataNames(r RR) []string {
+	switch r := r.(type) {
+	case *NS:
+		return []string{r.Ns}
+	case *CNAME:
+		return []string{r.Target}
+	case *PTR:
+		return []string{r.Ptr}
+	case *MX:
+		return []string{r.Mx}
+	case *SOA:
+		return []string{r.Ns, r.Mbox}
+	case *MB:
+		return []string{r.Mb}
+	case *MG:
+		return []string{r.Mg}
+	case *MR:
+		return []string{r.Mr}
+	case *MF:
+		return []string{r.Mf}
+	case *MD:
+		return []string{r.Md}
+	case *MINFO:
+		return []string{r.Rmail, r.Email}
+	case *AFSDB:
+		return []string{r.Hostname}
+	case *RT:
+		return []string{r.Host}
+	}
+	return nil
+}
+
+// Id returns a 16 bits number to be used as a message id, taken
+// from DefaultIdSource.
 func Id() uint16 {
-	return uint16(rand.Int()) ^ uint16(time.Now().Nanosecond())
+	return DefaultIdSource.Id()
+}
+
+// An IdSource hands out message ids. Its Id method must be safe for
+// concurrent use.
+type IdSource interface {
+	Id() uint16
+}
+
+// DefaultIdSource is the IdSource used by Id. It returns unpredictable
+// ids from crypto/rand, which makes spoofing replies harder. Tests can
+// set it to a SequentialIds for reproducible messages.
+var DefaultIdSource IdSource = randomIds{}
+
+// randomIds is an IdSource using crypto/rand.
+type randomIds struct{}
+
+func (randomIds) Id() uint16 {
+	b := make([]byte, 2)
+	if _, err := crand.Read(b); err != nil {
+		// Should not happen, fall back to math/rand
+		return uint16(rand.Int()) ^ uint16(time.Now().Nanosecond())
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// SequentialIds is an IdSource that hands out the ids in order, starting
+// with the id it was created with. This is meant for tests.
+type SequentialIds struct {
+	next uint32
+}
+
+// NewSequentialIds returns a SequentialIds that starts at first.
+func NewSequentialIds(first uint16) *SequentialIds {
+	return &SequentialIds{next: uint32(first)}
+}
+
+func (s *SequentialIds) Id() uint16 {
+	return uint16(atomic.AddUint32(&s.next, 1) - 1)
 }
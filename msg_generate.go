@@ -0,0 +1,249 @@
+//go:build ignore
+// +build ignore
+
+// msg_generate.go generates zmsg.go: the pack, unpack, Len and Copy methods
+// of the RR types, so PackRR and UnpackRR don't need reflection for them.
+// Run it with go generate after adding or changing an RR type.
+//
+// A type gets generated methods when all its rdata fields are of a kind
+// listed in packField and unpackField. Types with other fields, such as
+// the type bitmaps of NSEC or the sized strings of NSEC3 and TSIG, are
+// still packed with reflection, and their Len and Copy are written by hand
+// in types.go.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// field is a field of an RR type: its name, Go type and dns tag.
+type field struct {
+	name, typ, tag string
+}
+
+func main() {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != "zmsg.go"
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	types := make(map[string][]field)
+	for _, f := range pkgs["dns"].Files {
+		for _, d := range f.Decls {
+			g, ok := d.(*ast.GenDecl)
+			if !ok || g.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range g.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok || len(st.Fields.List) == 0 || typeString(st.Fields.List[0].Type) != "RR_Header" {
+					continue
+				}
+				if fields, ok := rdataFields(st.Fields.List[1:]); ok {
+					types[ts.Name.Name] = fields
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := new(bytes.Buffer)
+	fmt.Fprint(b, "// Code generated by \"go run msg_generate.go\"; DO NOT EDIT.\n\npackage dns\n\nimport \"net\"\n\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "func (rr *%s) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {\n", name)
+		fmt.Fprint(b, "if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {\nreturn len(msg), err\n}\n")
+		for _, f := range types[name] {
+			fmt.Fprintf(b, "if off, err = %s; err != nil {\nreturn len(msg), err\n}\n", packField(f))
+		}
+		fmt.Fprint(b, "return off, nil\n}\n\n")
+
+		fmt.Fprintf(b, "func (rr *%s) unpack(msg []byte, off, end int) (off1 int, err error) {\n", name)
+		for _, f := range types[name] {
+			fmt.Fprintf(b, "if rr.%s, off, err = %s; err != nil {\nreturn len(msg), err\n}\n", f.name, unpackField(f))
+		}
+		fmt.Fprint(b, "return off, nil\n}\n\n")
+
+		fmt.Fprintf(b, "func (rr *%s) Len() int {\nl := rr.Hdr.Len()\n", name)
+		for _, f := range types[name] {
+			fmt.Fprintln(b, lenField(f))
+		}
+		fmt.Fprint(b, "return l\n}\n\n")
+
+		fmt.Fprintf(b, "func (rr *%s) Copy() RR {\nreturn &%s{*rr.Hdr.CopyHeader()", name, name)
+		for _, f := range types[name] {
+			fmt.Fprint(b, ", "+copyField(f))
+		}
+		fmt.Fprint(b, "}\n}\n\n")
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("bad generated code: %s\n%s", err, b.Bytes())
+	}
+	if err := os.WriteFile("zmsg.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// rdataFields returns the rdata fields of a type, ok is false when one of
+// them can't be generated.
+func rdataFields(list []*ast.Field) (fields []field, ok bool) {
+	for i, f := range list {
+		tag := ""
+		if f.Tag != nil {
+			s, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(s).Get("dns")
+		}
+		typ := typeString(f.Type)
+		for _, n := range f.Names {
+			fields = append(fields, field{n.Name, typ, tag})
+		}
+		if len(f.Names) == 0 || packField(fields[len(fields)-1]) == "" {
+			return nil, false
+		}
+		// The rest of the rdata is in these, they must be last.
+		if (tag == "base64" || tag == "hex") && i != len(list)-1 {
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+func typeString(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + typeString(t.Elt)
+	}
+	return ""
+}
+
+// packField returns the call that packs f, or "" when f can't be packed by
+// generated code.
+func packField(f field) string {
+	v := "rr." + f.name
+	switch f.typ + " " + f.tag {
+	case "uint8 ":
+		return "putUint8(" + v + ", msg, off)"
+	case "uint16 ":
+		return "putUint16(" + v + ", msg, off)"
+	case "uint32 ":
+		return "putUint32(" + v + ", msg, off)"
+	case "uint64 ":
+		return "putUint64(" + v + ", msg, off)"
+	case "uint64 uint48":
+		return "putUint48(" + v + ", msg, off)"
+	case "string ":
+		return "putString(" + v + ", msg, off)"
+	case "string domain-name":
+		return "PackDomainName(" + v + ", msg, off, compression, false)"
+	case "string cdomain-name":
+		return "PackDomainName(" + v + ", msg, off, compression, compress)"
+	case "string base64":
+		return "putBase64(" + v + ", msg, off)"
+	case "string hex":
+		return "putHex(" + v + ", msg, off)"
+	case "[]string txt":
+		return "putStrings(" + v + ", msg, off)"
+	case "net.IP a":
+		return "putA(" + v + ", msg, off)"
+	case "net.IP aaaa":
+		return "putAAAA(" + v + ", msg, off)"
+	}
+	return ""
+}
+
+// unpackField returns the call that unpacks f, see packField.
+func unpackField(f field) string {
+	switch f.typ + " " + f.tag {
+	case "uint8 ":
+		return "getUint8(msg[:end], off)"
+	case "uint16 ":
+		return "getUint16(msg[:end], off)"
+	case "uint32 ":
+		return "getUint32(msg[:end], off)"
+	case "uint64 ":
+		return "getUint64(msg[:end], off)"
+	case "uint64 uint48":
+		return "getUint48(msg[:end], off)"
+	case "string ":
+		return "getString(msg[:end], off)"
+	case "string domain-name", "string cdomain-name":
+		return "UnpackDomainName(msg, off)"
+	case "string base64":
+		return "getBase64(msg, off, end)"
+	case "string hex":
+		return "getHex(msg, off, end)"
+	case "[]string txt":
+		return "getStrings(msg, off, end)"
+	case "net.IP a":
+		return "getA(msg[:end], off)"
+	case "net.IP aaaa":
+		return "getAAAA(msg[:end], off)"
+	}
+	return ""
+}
+
+// lenField returns the statement that adds the length of f in wire format,
+// uncompressed, to l.
+func lenField(f field) string {
+	v := "rr." + f.name
+	switch f.typ + " " + f.tag {
+	case "uint8 ":
+		return "l++ // " + f.name
+	case "uint16 ":
+		return "l += 2 // " + f.name
+	case "uint32 ":
+		return "l += 4 // " + f.name
+	case "uint64 ":
+		return "l += 8 // " + f.name
+	case "uint64 uint48":
+		return "l += 6 // " + f.name
+	case "string ", "string domain-name", "string cdomain-name":
+		return "l += len(" + v + ") + 1"
+	case "string base64":
+		return "l += base64Len(" + v + ")"
+	case "string hex":
+		return "l += len(" + v + ") / 2"
+	case "[]string txt":
+		return "for _, s := range " + v + " {\nl += len(s) + 1\n}"
+	case "net.IP a":
+		return "l += net.IPv4len // " + f.name
+	case "net.IP aaaa":
+		return "l += net.IPv6len // " + f.name
+	}
+	return ""
+}
+
+// copyField returns the expression that copies f, slices are copied so the
+// copy doesn't share them with the original.
+func copyField(f field) string {
+	v := "rr." + f.name
+	switch f.typ {
+	case "[]string":
+		return "append([]string(nil), " + v + "...)"
+	case "net.IP":
+		return "append(net.IP(nil), " + v + "...)"
+	}
+	return v
+}
@@ -0,0 +1,258 @@
+package dns
+
+// Packing and unpacking of the fields of RRs, used by the generated
+// functions in zmsg.go.
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+)
+
+// rrPacker is implemented by the RR types that have generated pack and
+// unpack methods, see msg_generate.go. Other types are packed with
+// reflection.
+type rrPacker interface {
+	// pack packs the RR, header and rdata, into msg[off:]. The rdlength
+	// in the header is set by PackRR.
+	pack(msg []byte, off int, compression map[string]int, compress bool) (int, error)
+	// unpack unpacks the rdata in msg[off:end], the header is already set.
+	unpack(msg []byte, off, end int) (int, error)
+}
+
+func (h *RR_Header) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = PackDomainName(h.Name, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(h.Rrtype, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(h.Class, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(h.Ttl, msg, off); err != nil {
+		return len(msg), err
+	}
+	return putUint16(h.Rdlength, msg, off)
+}
+
+func (h *RR_Header) unpack(msg []byte, off int) (off1 int, err error) {
+	if h.Name, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if h.Rrtype, off, err = getUint16(msg, off); err != nil {
+		return len(msg), err
+	}
+	if h.Class, off, err = getUint16(msg, off); err != nil {
+		return len(msg), err
+	}
+	if h.Ttl, off, err = getUint32(msg, off); err != nil {
+		return len(msg), err
+	}
+	h.Rdlength, off, err = getUint16(msg, off)
+	return off, err
+}
+
+func putUint8(i uint8, msg []byte, off int) (int, error) {
+	if off+1 > len(msg) {
+		return len(msg), &Error{Err: "overflow packing uint8"}
+	}
+	msg[off] = i
+	return off + 1, nil
+}
+
+func putUint16(i uint16, msg []byte, off int) (int, error) {
+	if off+2 > len(msg) {
+		return len(msg), &Error{Err: "overflow packing uint16"}
+	}
+	msg[off], msg[off+1] = byte(i>>8), byte(i)
+	return off + 2, nil
+}
+
+func putUint32(i uint32, msg []byte, off int) (int, error) {
+	if off+4 > len(msg) {
+		return len(msg), &Error{Err: "overflow packing uint32"}
+	}
+	msg[off], msg[off+1], msg[off+2], msg[off+3] = byte(i>>24), byte(i>>16), byte(i>>8), byte(i)
+	return off + 4, nil
+}
+
+// putUint48 packs the lower 48 bits of i, as used in TSIG.
+func putUint48(i uint64, msg []byte, off int) (int, error) {
+	if off+6 > len(msg) {
+		return len(msg), &Error{Err: "overflow packing uint64 as uint48"}
+	}
+	for j := 0; j < 6; j++ {
+		msg[off+j] = byte(i >> uint(40-8*j))
+	}
+	return off + 6, nil
+}
+
+func putUint64(i uint64, msg []byte, off int) (int, error) {
+	if off+8 > len(msg) {
+		return len(msg), &Error{Err: "overflow packing uint64"}
+	}
+	for j := 0; j < 8; j++ {
+		msg[off+j] = byte(i >> uint(56-8*j))
+	}
+	return off + 8, nil
+}
+
+// putString packs s as a character-string, with a length byte.
+func putString(s string, msg []byte, off int) (int, error) {
+	if len(s) > 255 || off+1+len(s) > len(msg) {
+		return len(msg), &Error{Err: "overflow packing string"}
+	}
+	msg[off] = byte(len(s))
+	return off + 1 + copy(msg[off+1:], s), nil
+}
+
+func putStrings(txt []string, msg []byte, off int) (int, error) {
+	var err error
+	for _, s := range txt {
+		if off, err = putString(s, msg, off); err != nil {
+			return len(msg), &Error{Err: "overflow packing txt"}
+		}
+	}
+	return off, nil
+}
+
+// putA packs the IPv4 address a, nothing is packed when a is empty, as in
+// dynamic updates.
+func putA(a net.IP, msg []byte, off int) (int, error) {
+	switch len(a) {
+	case 0:
+		return off, nil
+	case net.IPv4len, net.IPv6len:
+		if off+net.IPv4len > len(msg) {
+			return len(msg), &Error{Err: "overflow packing a"}
+		}
+		return off + copy(msg[off:], a[len(a)-net.IPv4len:]), nil
+	}
+	return len(msg), &Error{Err: "overflow packing a"}
+}
+
+// putAAAA packs the IPv6 address aaaa, nothing is packed when aaaa is empty.
+func putAAAA(aaaa net.IP, msg []byte, off int) (int, error) {
+	if len(aaaa) == 0 {
+		return off, nil
+	}
+	if len(aaaa) != net.IPv6len || off+net.IPv6len > len(msg) {
+		return len(msg), &Error{Err: "overflow packing aaaa"}
+	}
+	copy(msg[off:off+net.IPv6len], aaaa)
+	return off + net.IPv6len, nil
+}
+
+func putBase64(s string, msg []byte, off int) (int, error) {
+	b, err := packBase64([]byte(s))
+	if err != nil || off+len(b) > len(msg) {
+		return len(msg), &Error{Err: "overflow packing base64"}
+	}
+	return off + copy(msg[off:], b), nil
+}
+
+func putHex(s string, msg []byte, off int) (int, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || off+len(b) > len(msg) {
+		return len(msg), &Error{Err: "overflow packing hex"}
+	}
+	return off + copy(msg[off:], b), nil
+}
+
+func getUint8(msg []byte, off int) (uint8, int, error) {
+	if off+1 > len(msg) {
+		return 0, len(msg), &Error{Err: "overflow unpacking uint8"}
+	}
+	return msg[off], off + 1, nil
+}
+
+func getUint16(msg []byte, off int) (uint16, int, error) {
+	if off+2 > len(msg) {
+		return 0, len(msg), &Error{Err: "overflow unpacking uint16"}
+	}
+	return uint16(msg[off])<<8 | uint16(msg[off+1]), off + 2, nil
+}
+
+func getUint32(msg []byte, off int) (uint32, int, error) {
+	if off+4 > len(msg) {
+		return 0, len(msg), &Error{Err: "overflow unpacking uint32"}
+	}
+	return uint32(msg[off])<<24 | uint32(msg[off+1])<<16 | uint32(msg[off+2])<<8 | uint32(msg[off+3]), off + 4, nil
+}
+
+func getUint48(msg []byte, off int) (uint64, int, error) {
+	if off+6 > len(msg) {
+		return 0, len(msg), &Error{Err: "overflow unpacking uint64 as uint48"}
+	}
+	var i uint64
+	for j := 0; j < 6; j++ {
+		i = i<<8 | uint64(msg[off+j])
+	}
+	return i, off + 6, nil
+}
+
+func getUint64(msg []byte, off int) (uint64, int, error) {
+	if off+8 > len(msg) {
+		return 0, len(msg), &Error{Err: "overflow unpacking uint64"}
+	}
+	var i uint64
+	for j := 0; j < 8; j++ {
+		i = i<<8 | uint64(msg[off+j])
+	}
+	return i, off + 8, nil
+}
+
+func getString(msg []byte, off int) (string, int, error) {
+	if off >= len(msg) || off+1+int(msg[off]) > len(msg) {
+		return "", len(msg), &Error{Err: "overflow unpacking string"}
+	}
+	n := int(msg[off])
+	return string(msg[off+1 : off+1+n]), off + 1 + n, nil
+}
+
+// getStrings unpacks the character-strings in msg[off:end].
+func getStrings(msg []byte, off, end int) ([]string, int, error) {
+	txt := make([]string, 0, 1)
+	for off < end {
+		s, off1, err := getString(msg[:end], off)
+		if err != nil {
+			return nil, len(msg), &Error{Err: "overflow unpacking txt"}
+		}
+		txt = append(txt, s)
+		off = off1
+	}
+	return txt, off, nil
+}
+
+func getA(msg []byte, off int) (net.IP, int, error) {
+	if off+net.IPv4len > len(msg) {
+		return nil, len(msg), &Error{Err: "overflow unpacking a"}
+	}
+	return net.IPv4(msg[off], msg[off+1], msg[off+2], msg[off+3]), off + net.IPv4len, nil
+}
+
+func getAAAA(msg []byte, off int) (net.IP, int, error) {
+	if off+net.IPv6len > len(msg) {
+		return nil, len(msg), &Error{Err: "overflow unpacking aaaa"}
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, msg[off:])
+	return ip, off + net.IPv6len, nil
+}
+
+// getBase64 returns the rest of the rdata, msg[off:end], base64 encoded.
+func getBase64(msg []byte, off, end int) (string, int, error) {
+	if off > end || end > len(msg) {
+		return "", len(msg), &Error{Err: "overflow unpacking base64"}
+	}
+	return base64.StdEncoding.EncodeToString(msg[off:end]), end, nil
+}
+
+// getHex returns the rest of the rdata, msg[off:end], hex encoded.
+func getHex(msg []byte, off, end int) (string, int, error) {
+	if off > end || end > len(msg) {
+		return "", len(msg), &Error{Err: "overflow unpacking hex"}
+	}
+	return hex.EncodeToString(msg[off:end]), end, nil
+}
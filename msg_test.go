@@ -0,0 +1,480 @@
+package dns
+
+// Benchmarks for packing and unpacking messages. Run them with:
+//
+//	go test -run Allocs -bench . -benchmem
+//
+// The Allocs tests fail when packing or unpacking starts to allocate
+// (much) more than it does now, so performance regressions show up in a
+// normal test run too.
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var benchRR = map[uint16]string{
+	TypeA:      "miek.nl. 3600 IN A 127.0.0.1",
+	TypeAAAA:   "miek.nl. 3600 IN AAAA ::1",
+	TypeMX:     "miek.nl. 3600 IN MX 10 mx.miek.nl.",
+	TypeSOA:    "miek.nl. 3600 IN SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+	TypeTXT:    `miek.nl. 3600 IN TXT "v=spf1 ip4:127.0.0.1 -all" "more text"`,
+	TypeSRV:    "_sip._tcp.miek.nl. 3600 IN SRV 10 20 5060 sip.miek.nl.",
+	TypeDNSKEY: "miek.nl. 3600 IN DNSKEY 257 3 8 AwEAAcWdjBl4W4wh/hPxMDcBytmNCvEngIgB9Ut3C2+QI0oVz78/WK9KPoQF7B74JQ/mjO4fvIncBmPp6mFNxs9/WQX0IXf7oKviEVOXLjctR4D1KQLX0wprvtUIsQFIGdXaO6suTT5eDbSd6tTwu5xIkGkDmQhhH8OQydoEuCwV245ZwF/8AIsqBYDNQtQ6zhd6jDC+uZJXg/9LuPOxFHbiMTjp6j3CCW0kHbfM/YHZErWWtjPj3U3Z7knQSIm5PO5FRKBEYDdr5UxWJ/1/20SrzI3iztvPwHDsA2rdHm/4YRzq7CvG4N0t9ac/T0a0Sxba/BUX2UVPWaIVBdTRBtgHi0s=",
+	TypeRRSIG:  "miek.nl. 3600 IN RRSIG SOA 8 2 3600 20130214123409 20130115123409 12051 miek.nl. Y4tzLvOeZyAxlnEHOKbsLRtmS4Qbq2YSwLntx2Z42CK12HlABRPuXDuaNuwvBTvSRgkFlvIeFjZPqf46CpOyCm2Tu3nPV8BmL9gmWNfiGv/G6EbdI3Jh0CmY5T6gmgJ7tLVzIG3jkPvpvBtQiWi8RbvG6ZHTaU6pPtY+f8Jz6Wg=",
+	TypeNSEC:   "miek.nl. 3600 IN NSEC a.miek.nl. NS SOA MX RRSIG NSEC DNSKEY",
+}
+
+func benchmarkPackRR(b *testing.B, t uint16) {
+	rr, err := NewRR(benchRR[t])
+	if err != nil {
+		b.Fatalf("failed to parse %s: %s", benchRR[t], err.Error())
+	}
+	buf := make([]byte, rr.Len()*2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		PackRR(rr, buf, 0, nil, false)
+	}
+}
+
+func benchmarkUnpackRR(b *testing.B, t uint16) {
+	rr, err := NewRR(benchRR[t])
+	if err != nil {
+		b.Fatalf("failed to parse %s: %s", benchRR[t], err.Error())
+	}
+	buf := make([]byte, rr.Len()*2)
+	off, err := PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		b.Fatalf("failed to pack %s: %s", benchRR[t], err.Error())
+	}
+	buf = buf[:off]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		UnpackRR(buf, 0)
+	}
+}
+
+func BenchmarkPackA(b *testing.B)      { benchmarkPackRR(b, TypeA) }
+func BenchmarkPackAAAA(b *testing.B)   { benchmarkPackRR(b, TypeAAAA) }
+func BenchmarkPackMX(b *testing.B)     { benchmarkPackRR(b, TypeMX) }
+func BenchmarkPackSOA(b *testing.B)    { benchmarkPackRR(b, TypeSOA) }
+func BenchmarkPackTXT(b *testing.B)    { benchmarkPackRR(b, TypeTXT) }
+func BenchmarkPackSRV(b *testing.B)    { benchmarkPackRR(b, TypeSRV) }
+func BenchmarkPackDNSKEY(b *testing.B) { benchmarkPackRR(b, TypeDNSKEY) }
+func BenchmarkPackRRSIG(b *testing.B)  { benchmarkPackRR(b, TypeRRSIG) }
+func BenchmarkPackNSEC(b *testing.B)   { benchmarkPackRR(b, TypeNSEC) }
+
+func BenchmarkUnpackA(b *testing.B)      { benchmarkUnpackRR(b, TypeA) }
+func BenchmarkUnpackAAAA(b *testing.B)   { benchmarkUnpackRR(b, TypeAAAA) }
+func BenchmarkUnpackMX(b *testing.B)     { benchmarkUnpackRR(b, TypeMX) }
+func BenchmarkUnpackSOA(b *testing.B)    { benchmarkUnpackRR(b, TypeSOA) }
+func BenchmarkUnpackTXT(b *testing.B)    { benchmarkUnpackRR(b, TypeTXT) }
+func BenchmarkUnpackSRV(b *testing.B)    { benchmarkUnpackRR(b, TypeSRV) }
+func BenchmarkUnpackDNSKEY(b *testing.B) { benchmarkUnpackRR(b, TypeDNSKEY) }
+func BenchmarkUnpackRRSIG(b *testing.B)  { benchmarkUnpackRR(b, TypeRRSIG) }
+func BenchmarkUnpackNSEC(b *testing.B)   { benchmarkUnpackRR(b, TypeNSEC) }
+
+// benchMsg returns a reply for miek.nl. MX with n MX records in the answer
+// section and their addresses in the additional section.
+func benchMsg(n int) *Msg {
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeMX)
+	m.Response = true
+	for i := 0; i < n; i++ {
+		host := "mx" + strconv.Itoa(i) + ".miek.nl."
+		mx, _ := NewRR("miek.nl. 3600 IN MX " + strconv.Itoa(i) + " " + host)
+		a, _ := NewRR(host + " 3600 IN A 127.0.0." + strconv.Itoa(i%256))
+		m.Answer = append(m.Answer, mx)
+		m.Extra = append(m.Extra, a)
+	}
+	return m
+}
+
+func benchmarkPackMsg(b *testing.B, n int, compress bool) {
+	m := benchMsg(n)
+	m.Compress = compress
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Pack()
+	}
+}
+
+func benchmarkUnpackMsg(b *testing.B, n int, compress bool) {
+	m := benchMsg(n)
+	m.Compress = compress
+	buf, err := m.Pack()
+	if err != nil {
+		b.Fatalf("failed to pack message: %s", err.Error())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		new(Msg).Unpack(buf)
+	}
+}
+
+func BenchmarkPackMsg(b *testing.B)                { benchmarkPackMsg(b, 4, false) }
+func BenchmarkPackMsgCompress(b *testing.B)        { benchmarkPackMsg(b, 4, true) }
+func BenchmarkUnpackMsg(b *testing.B)              { benchmarkUnpackMsg(b, 4, false) }
+func BenchmarkUnpackMsgCompress(b *testing.B)      { benchmarkUnpackMsg(b, 4, true) }
+func BenchmarkPackLargeMsg(b *testing.B)           { benchmarkPackMsg(b, 250, false) }
+func BenchmarkPackLargeMsgCompress(b *testing.B)   { benchmarkPackMsg(b, 250, true) }
+func BenchmarkUnpackLargeMsg(b *testing.B)         { benchmarkUnpackMsg(b, 250, false) }
+func BenchmarkUnpackLargeMsgCompress(b *testing.B) { benchmarkUnpackMsg(b, 250, true) }
+
+// BenchmarkPackAXFR packs a zone of 1000 names as an AXFR reply, in
+// envelopes of 100 RRs each, like TransferOut does.
+func BenchmarkPackAXFR(b *testing.B) {
+	soa, _ := NewRR(benchRR[TypeSOA])
+	rrs := []RR{soa}
+	for i := 0; i < 1000; i++ {
+		a, _ := NewRR("host" + strconv.Itoa(i) + ".miek.nl. 3600 IN A 127.0.0.1")
+		rrs = append(rrs, a)
+	}
+	rrs = append(rrs, soa)
+	var envelopes []*Envelope
+	for i := 0; i < len(rrs); i += 100 {
+		j := i + 100
+		if j > len(rrs) {
+			j = len(rrs)
+		}
+		envelopes = append(envelopes, &Envelope{RR: rrs[i:j]})
+	}
+	req := new(Msg)
+	req.SetAxfr("miek.nl.")
+	rep := new(Msg)
+	rep.SetReply(req)
+	rep.Authoritative = true
+	rep.Compress = true
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range envelopes {
+			rep.Answer = e.RR
+			if _, err := rep.Pack(); err != nil {
+				b.Fatalf("failed to pack envelope: %s", err.Error())
+			}
+		}
+	}
+}
+
+// The allocation limits are what packing and unpacking use now, with some
+// slack. Lower them when an optimization brings the numbers down.
+func TestPackAllocs(t *testing.T) {
+	for typ, max := range map[uint16]float64{TypeA: 0, TypeMX: 0, TypeSOA: 0, TypeRRSIG: 2} {
+		rr, _ := NewRR(benchRR[typ])
+		buf := make([]byte, rr.Len()*2)
+		if n := testing.AllocsPerRun(100, func() { PackRR(rr, buf, 0, nil, false) }); n > max {
+			t.Errorf("packing %s allocates %.0f times, expected at most %.0f", TypeToString[typ], n, max)
+		}
+	}
+	m := benchMsg(4)
+	if n := testing.AllocsPerRun(100, func() { m.Pack() }); n > 4 {
+		t.Errorf("packing a message allocates %.0f times, expected at most 4", n)
+	}
+	m.Compress = true
+	if n := testing.AllocsPerRun(100, func() { m.Pack() }); n > 64 {
+		t.Errorf("packing a compressed message allocates %.0f times, expected at most 64", n)
+	}
+}
+
+func TestUnpackAllocs(t *testing.T) {
+	m := benchMsg(4)
+	m.Compress = true
+	buf, _ := m.Pack()
+	if n := testing.AllocsPerRun(100, func() { new(Msg).Unpack(buf) }); n > 450 {
+		t.Errorf("unpacking a message allocates %.0f times, expected at most 450", n)
+	}
+}
+
+// TestPackGenerated checks that the generated pack and unpack methods give
+// the same results as the reflection based ones.
+func TestPackGenerated(t *testing.T) {
+	rrs := []string{
+		"miek.nl. 3600 IN NAPTR 100 50 \"s\" \"http+I2L+I2C+I2R\" \"\" _http._tcp.miek.nl.",
+		"miek.nl. 3600 IN DS 12051 8 2 E2D3C916F6DEEAC73294E8268FB5885044A833FC5459588F4A9184CFC41A5766",
+		"miek.nl. 3600 IN SSHFP 1 1 dc2bb93a7ea3af7ab3b4c1fdcbd6cf12a2f21c33",
+		"miek.nl. 3600 IN RP mbox.miek.nl. txt.miek.nl.",
+		"miek.nl. 3600 IN AFSDB 1 afs.miek.nl.",
+		"miek.nl. 3600 IN CNAME www.miek.nl.",
+	}
+	for _, s := range benchRR {
+		rrs = append(rrs, s)
+	}
+	for _, s := range rrs {
+		rr, err := NewRR(s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", s, err.Error())
+		}
+		if _, ok := rr.(rrPacker); !ok {
+			continue
+		}
+		buf := make([]byte, rr.Len()*2)
+		off, err := PackRR(rr, buf, 0, nil, false)
+		if err != nil {
+			t.Errorf("failed to pack %s: %s", s, err.Error())
+			continue
+		}
+		buf1 := make([]byte, rr.Len()*2)
+		off1, _ := packStructCompress(rr, buf1, 0, nil, false)
+		rawSetRdlength(buf1, 0, off1)
+		if string(buf[:off]) != string(buf1[:off1]) {
+			t.Errorf("generated pack of %s differs:\n%v\n%v", s, buf[:off], buf1[:off1])
+		}
+		rr1, _, err := UnpackRR(buf[:off], 0)
+		if err != nil || rr1.String() != rr.String() {
+			t.Errorf("generated unpack of %s gives %v (%v)", s, rr1, err)
+		}
+	}
+}
+
+// TestUnpackStringBytes checks that the generated unpack keeps the bytes of
+// a character-string, the reflection based one made a rune of each byte.
+func TestUnpackStringBytes(t *testing.T) {
+	rr := &HINFO{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeHINFO, Class: ClassINET}, Cpu: "caf\xe9", Os: "\xc3\xa9"}
+	buf := make([]byte, rr.Len())
+	off, err := PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	rr1, _, err := UnpackRR(buf[:off], 0)
+	if err != nil {
+		t.Fatalf("failed to unpack: %s", err.Error())
+	}
+	if h := rr1.(*HINFO); h.Cpu != rr.Cpu || h.Os != rr.Os {
+		t.Errorf("expected %q and %q, got %q and %q", rr.Cpu, rr.Os, h.Cpu, h.Os)
+	}
+}
+
+// TestCopyGenerated checks that the generated Copy doesn't share slices.
+func TestCopyGenerated(t *testing.T) {
+	a := &A{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeA, Class: ClassINET}, A: net.IPv4(192, 0, 2, 1)}
+	txt := &TXT{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeTXT, Class: ClassINET}, Txt: []string{"one"}}
+	a1, txt1 := a.Copy().(*A), txt.Copy().(*TXT)
+	a1.A[15], txt1.Txt[0] = 2, "two"
+	if a.A.String() != "192.0.2.1" || txt.Txt[0] != "one" {
+		t.Errorf("copy changed the original: %s, %s", a, txt)
+	}
+	if a1.String() != "miek.nl.\t0\tIN\tA\t192.0.2.2" {
+		t.Errorf("bad copy %s", a1)
+	}
+}
+
+func TestIdSource(t *testing.T) {
+	defer func(s IdSource) { DefaultIdSource = s }(DefaultIdSource)
+	DefaultIdSource = NewSequentialIds(65535)
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeA)
+	if m.Id != 65535 || Id() != 0 || Id() != 1 {
+		t.Errorf("ids not sequential")
+	}
+}
+
+func TestMsgString(t *testing.T) {
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeMX)
+	m.Response, m.RecursionAvailable, m.AuthenticatedData, m.CheckingDisabled = true, true, true, true
+	m.Id = 2157
+	m.SetEdns0(4096, true)
+	m.Extra[0].Header().Ttl |= 1 << 24 // extended rcode 16: BADSIG with NOERROR
+	s := m.String()
+	for _, want := range []string{
+		";; ->>HEADER<<- opcode: QUERY, status: BADSIG, id: 2157\n",
+		";; flags: qr rd ra ad cd; QUERY: 1, ANSWER: 0, AUTHORITY: 0, ADDITIONAL: 1\n",
+		";; OPT PSEUDOSECTION:\n; EDNS: version 0; flags: do; udp: 4096\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("expected %q in:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "ADDITIONAL SECTION") {
+		t.Errorf("OPT RR should only be shown in the pseudosection:\n%s", s)
+	}
+	m.Opcode = 3
+	if h := m.MsgHdr.String(); !strings.HasPrefix(h, ";; ->>HEADER<<- opcode: RESERVED3, status: NOERROR") {
+		t.Errorf("bad header: %s", h)
+	}
+}
+
+func TestParseType(t *testing.T) {
+	for typ := range rr_mk {
+		s, ok := TypeToString[typ]
+		if !ok {
+			t.Errorf("type %d has no name", typ)
+			continue
+		}
+		if p, err := ParseType(strings.ToLower(s)); err != nil || p != typ {
+			t.Errorf("ParseType(%q) = %d, %v; want %d", s, p, err, typ)
+		}
+	}
+	if p, err := ParseType("TYPE65"); err != nil || p != 65 {
+		t.Errorf("ParseType(TYPE65) = %d, %v", p, err)
+	}
+	for _, s := range []string{"BOGUS", "TYPE65536", "TYPE", "CLASS1"} {
+		if _, err := ParseType(s); err == nil {
+			t.Errorf("expected an error for type %q", s)
+		}
+	}
+	if c, err := ParseClass("ch"); err != nil || c != ClassCHAOS {
+		t.Errorf("ParseClass(ch) = %d, %v", c, err)
+	}
+	if c, err := ParseClass("CLASS42"); err != nil || c != 42 {
+		t.Errorf("ParseClass(CLASS42) = %d, %v", c, err)
+	}
+	if o, err := ParseOpcode("notify"); err != nil || o != OpcodeNotify {
+		t.Errorf("ParseOpcode(notify) = %d, %v", o, err)
+	}
+	if r, err := ParseRcode("NXDOMAIN"); err != nil || r != RcodeNameError {
+		t.Errorf("ParseRcode(NXDOMAIN) = %d, %v", r, err)
+	}
+	if r, err := ParseRcode("23"); err != nil || r != 23 {
+		t.Errorf("ParseRcode(23) = %d, %v", r, err)
+	}
+	if _, err := ParseRcode("NOSUCH"); err == nil || err.Error() != "dns: NOSUCH: unknown rcode" {
+		t.Errorf("expected an unknown rcode error, got %v", err)
+	}
+}
+
+func TestMultipleQuestions(t *testing.T) {
+	m := new(Msg)
+	m.SetQuestions(NewQuestion("a.local.", TypeA), NewQuestion("b.local.", TypeAAAA), Question{"b.local.", TypeTXT, 0x8001})
+	m.Compress = true
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	u := new(Msg)
+	if err := u.Unpack(buf); err != nil {
+		t.Fatalf("failed to unpack: %s", err.Error())
+	}
+	if len(u.Question) != 3 {
+		t.Fatalf("expected 3 questions, got %d", len(u.Question))
+	}
+	for i := range m.Question {
+		if !u.Question[i].Equal(&m.Question[i]) {
+			t.Errorf("question %d: expected %s, got %s", i, m.Question[i].String(), u.Question[i].String())
+		}
+	}
+	r := new(Msg)
+	r.SetReply(u)
+	if len(r.Question) != 1 || r.Question[0] != u.Question[0] {
+		t.Errorf("expected the reply to have the first question, got %v", r.Question)
+	}
+	q := NewQuestion("A.Local.", TypeA)
+	if !q.Equal(&m.Question[0]) || q.Equal(&m.Question[1]) {
+		t.Error("bad case insensitive question comparison")
+	}
+	// Only ASCII letters are folded: the Kelvin sign is not a "k"
+	q1, q2 := NewQuestion("\u212a.local.", TypeA), NewQuestion("K.local.", TypeA)
+	if q1.Equal(&q2) || !q2.Equal(&Question{"k.LOCAL.", TypeA, ClassINET}) {
+		t.Error("bad ASCII case folding in question comparison")
+	}
+	if s := u.Question[2].String(); s != ";b.local.\tCLASS32769\t TXT" {
+		t.Errorf("bad question string: %q", s)
+	}
+}
+
+// lenRR has an RR of each type, for TestRRLen.
+var lenRR = []string{
+	"miek.nl. 3600 IN CNAME www.miek.nl.",
+	"miek.nl. 3600 IN HINFO intel linux",
+	"miek.nl. 3600 IN MB mb.miek.nl.",
+	"miek.nl. 3600 IN MG mg.miek.nl.",
+	"miek.nl. 3600 IN MD md.miek.nl.",
+	"miek.nl. 3600 IN MF mf.miek.nl.",
+	"miek.nl. 3600 IN RP mbox.miek.nl. txt.miek.nl.",
+	"miek.nl. 3600 IN AFSDB 1 afsdb.miek.nl.",
+	"miek.nl. 3600 IN X25 311061700956",
+	"miek.nl. 3600 IN MR mr.miek.nl.",
+	"miek.nl. 3600 IN MX 10 mx.miek.nl.",
+	"miek.nl. 3600 IN RKEY 256 3 5 AwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+P0BBQkNERQ==",
+	"miek.nl. 3600 IN NINFO \"first\" \"second\"",
+	"miek.nl. 3600 IN NS ns.miek.nl.",
+	"1.0.0.127.in-addr.arpa. 3600 IN PTR localhost.",
+	"miek.nl. 3600 IN SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+	"miek.nl. 3600 IN RT 10 rt.miek.nl.",
+	"miek.nl. 3600 IN TXT \"one\" \"two\"",
+	"_sip._tcp.miek.nl. 3600 IN SRV 10 20 5060 sip.miek.nl.",
+	"miek.nl. 3600 IN NAPTR 100 50 \"s\" \"SIP+D2U\" \"!^.*$!sip:info@miek.nl!\" _sip._udp.miek.nl.",
+	"miek.nl. 3600 IN DNAME miek.net.",
+	"miek.nl. 3600 IN A 127.0.0.1",
+	"miek.nl. 3600 IN WKS 127.0.0.1 6 21 25",
+	"miek.nl. 3600 IN AAAA 2001:db8::1",
+	"miek.nl. 3600 IN LOC 52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m",
+	"miek.nl. 3600 IN DS 12051 8 2 1C1F1A4C3E4B5D6E7F808192A3B4C5D6E7F8091A2B3C4D5E6F708192A3B4C5D6",
+	"miek.nl. 3600 IN CDS 12051 8 2 1C1F1A4C3E4B5D6E7F808192A3B4C5D6E7F8091A2B3C4D5E6F708192A3B4C5D6",
+	"miek.nl. 3600 IN CDNSKEY 257 3 8 AwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+P0BBQkNERQ==",
+	"miek.nl. 3600 IN ZONEMD 1 1 1 1C1F1A4C3E4B5D6E7F808192A3B4C5D6E7F8091A2B3C4D5E6F708192A3B4C5D61C1F1A4C3E4B5D6E7F808192A3B4C5D6",
+	"miek.nl. 3600 IN CERT PGP 0 0 AwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+P0BBQkNERQ==",
+	"miek.nl. 3600 IN KX 10 kx.miek.nl.",
+	"miek.nl. 3600 IN SPF \"v=spf1 -all\"",
+	"miek.nl. 3600 IN TALINK prev.miek.nl. next.miek.nl.",
+	"miek.nl. 3600 IN SSHFP 1 1 123456789ABCDEF67890123456789ABCDEF67890",
+	"miek.nl. 3600 IN RRSIG SOA 8 2 3600 20130214123409 20130115123409 12051 miek.nl. Y4tzLvOeZyAxlnEHOKbsLRtmS4Qbq2YSwLntx2Z42CK12HlABRPuXDuaNuwvBTvSRgkFlvIeFjZPqf46CpOyCm2Tu3nPV8BmL9gmWNfiGv/G6EbdI3Jh0CmY5T6gmgJ7tLVzIG3jkPvpvBtQiWi8RbvG6ZHTaU6pPtY+f8Jz6Wg=",
+	"miek.nl. 3600 IN NSEC a.miek.nl. NS SOA MX RRSIG NSEC DNSKEY",
+	"miek.nl. 3600 IN DNSKEY 257 3 8 AwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+P0BBQkNERQ==",
+	"0p9mhaveqvm6t7vbl5lop2u3t2rp3tom.miek.nl. 3600 IN NSEC3 1 1 12 aabbccdd 2T7B4G4VSA5SMI47K61MV5BV1A22BOJR A RRSIG",
+	"miek.nl. 3600 IN DHCID AAIBY2/AuCccgoJbsaxcQc9TUapptP69lOjxfNuVAA2kjEA=",
+	"miek.nl. 3600 IN OPENPGPKEY AwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+P0BBQkNERQ==",
+	"miek.nl. 3600 IN NSEC3PARAM 1 0 12 aabbccdd",
+	"_443._tcp.miek.nl. 3600 IN TLSA 3 1 1 1C1F1A4C3E4B5D6E7F808192A3B4C5D6E7F8091A2B3C4D5E6F708192A3B4C5D6",
+	"miek.nl. 3600 IN TA 12051 8 2 1C1F1A4C3E4B5D6E7F808192A3B4C5D6E7F8091A2B3C4D5E6F708192A3B4C5D6",
+	"miek.nl. 3600 IN DLV 12051 8 2 1C1F1A4C3E4B5D6E7F808192A3B4C5D6E7F8091A2B3C4D5E6F708192A3B4C5D6",
+	"miek.nl. 3600 IN HIP 2 200100107B1A74DF365639CC39F1D578 AwEAAbdxyhNuSutc5EMzxTs9LBPCIkOFH8cIvM4p9+LrV4e19WzK00+CI6zBCQTdtWsuxKbWIy87UOoJTwkUs7lBu+Upr1gsNrut79ryra+bSRGQb1slImA8YVJyuIDsj7kwzG7jnERNqnWxZ48AWkskmdHaVDP4BcelrTI3rMXdXF5D rvs.example.com.",
+	"miek.nl. 3600 IN NID 10 0014:4fff:ff20:ee64",
+	"miek.nl. 3600 IN L32 10 10.1.2.0",
+	"miek.nl. 3600 IN L64 10 2001:0db8:1140:1000",
+	"miek.nl. 3600 IN LP 10 l64-subnet1.miek.nl.",
+	"miek.nl. 3600 IN TYPE65534 \\# 4 0A000001",
+}
+
+func TestRRLen(t *testing.T) {
+	seen := make(map[uint16]bool)
+	check := func(rr RR) {
+		seen[rr.Header().Rrtype] = true
+		buf := make([]byte, rr.Len()*2+512)
+		off, err := PackRR(rr, buf, 0, nil, false)
+		if err != nil {
+			t.Errorf("failed to pack %s: %s", rr.String(), err.Error())
+			return
+		}
+		if off != rr.Len() {
+			t.Errorf("%s: Len returns %d, packed length is %d", TypeToString[rr.Header().Rrtype], rr.Len(), off)
+		}
+	}
+	for _, s := range lenRR {
+		rr, err := NewRR(s)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", s, err.Error())
+			continue
+		}
+		check(rr)
+	}
+	// Not in presentation format, or not known to the parser
+	check(&MINFO{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeMINFO, Class: ClassINET}, Rmail: "rmail.miek.nl.", Email: "email.miek.nl."})
+	check(&URI{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeURI, Class: ClassINET}, Priority: 10, Weight: 1, Target: "https://miek.nl/"})
+	check(&OPT{Hdr: RR_Header{Name: ".", Rrtype: TypeOPT, Class: 4096}, Option: []EDNS0{&EDNS0_NSID{Code: EDNS0NSID, Nsid: "6e736964"}}})
+	check(&TKEY{Hdr: RR_Header{Name: "tkey.miek.nl.", Rrtype: TypeTKEY, Class: ClassANY}, Algorithm: "gss-tsig.", Mode: 3, KeySize: 2, Key: "abcd", OtherLen: 2, OtherData: "abcd"})
+	check(&TSIG{Hdr: RR_Header{Name: "key.miek.nl.", Rrtype: TypeTSIG, Class: ClassANY}, Algorithm: HmacMD5, TimeSigned: 1, Fudge: 300, MACSize: 16, MAC: "0123456789abcdef0123456789abcdef", OrigId: 1, OtherLen: 0})
+	for typ := range rr_mk {
+		if !seen[typ] {
+			t.Errorf("no RR of type %s tested", TypeToString[typ])
+		}
+	}
+}
+
+func TestPackDomainNameShort(t *testing.T) {
+	for i := 0; i < len("miek.nl.")+1; i++ {
+		if _, err := PackDomainName("miek.nl.", make([]byte, i), 0, nil, false); err == nil {
+			t.Errorf("expected an error packing into %d octets", i)
+		}
+	}
+	if off, err := PackDomainName("miek.nl.", make([]byte, 9), 0, nil, false); err != nil || off != 9 {
+		t.Errorf("expected 9 octets, got %d, %v", off, err)
+	}
+}
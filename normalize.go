@@ -0,0 +1,108 @@
+package dns
+
+// Normalizing zone files.
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+)
+
+// NormalizeOptions are the options for NormalizeZone.
+type NormalizeOptions struct {
+	Origin string // The initial origin, as if the zone starts with $ORIGIN, defaults to "."
+	File   string // The file name, only used in error reporting
+}
+
+// NormalizeZone reads the zone file from in and writes it in normalized
+// form to out: one RR per line, with fully qualified and lower cased owner
+// names, the SOA record first and the other records in canonical order
+// (RFC 4034, section 6). Duplicate RRs are removed. Before anything is
+// written the zone is checked: it must have exactly one SOA record, all
+// RRs must be at or below the owner of the SOA and a name with a CNAME can
+// not have other data. The first problem found is returned. Opts may be nil.
+func NormalizeZone(in io.Reader, out io.Writer, opts *NormalizeOptions) error {
+	if opts == nil {
+		opts = new(NormalizeOptions)
+	}
+	var (
+		rrs canonicalRRs
+		soa RR
+	)
+	for x := range ParseZone(in, opts.Origin, opts.File) {
+		if x.Error != nil {
+			return x.Error
+		}
+		x.RR.Header().Name = strings.ToLower(x.RR.Header().Name)
+		if x.RR.Header().Rrtype == TypeSOA {
+			if soa != nil {
+				return &Error{Err: "more than one SOA", Name: x.RR.Header().Name}
+			}
+			soa = x.RR
+			continue
+		}
+		wire := make([]byte, x.RR.Len()*2)
+		off, err := PackRR(x.RR, wire, 0, nil, false)
+		if err != nil {
+			return err
+		}
+		_, hoff, _ := UnpackDomainName(wire, 0)
+		rrs = append(rrs, canonicalRR{x.RR, wire[hoff+10 : off]})
+	}
+	if soa == nil {
+		return ErrSoa
+	}
+	sort.Sort(rrs)
+	origin := soa.Header().Name
+	types := make(map[uint16]bool) // types seen at the current name
+	w := bufio.NewWriter(out)
+	w.WriteString(soa.String() + "\n")
+	for i, r := range rrs {
+		h := r.rr.Header()
+		if !IsSubDomain(origin, h.Name) {
+			return &Error{Err: "out of zone data", Name: h.Name}
+		}
+		if i > 0 && h.Name != rrs[i-1].rr.Header().Name {
+			types = make(map[uint16]bool)
+		}
+		types[h.Rrtype] = true
+		if types[TypeCNAME] {
+			for t := range types {
+				if t != TypeCNAME && t != TypeRRSIG && t != TypeNSEC {
+					return &Error{Err: "CNAME and other data", Name: h.Name}
+				}
+			}
+		}
+		if i > 0 && !rrs.Less(i-1, i) {
+			continue // duplicate
+		}
+		w.WriteString(r.rr.String() + "\n")
+	}
+	return w.Flush()
+}
+
+// canonicalRR is an RR with the parts needed to sort it in canonical order.
+type canonicalRR struct {
+	rr    RR
+	rdata []byte // the rdata in wire format
+}
+
+type canonicalRRs []canonicalRR
+
+func (p canonicalRRs) Len() int      { return len(p) }
+func (p canonicalRRs) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p canonicalRRs) Less(i, j int) bool {
+	hi, hj := p[i].rr.Header(), p[j].rr.Header()
+	if c := CanonicalCompare(hi.Name, hj.Name); c != 0 {
+		return c < 0
+	}
+	if hi.Rrtype != hj.Rrtype {
+		return hi.Rrtype < hj.Rrtype
+	}
+	if hi.Class != hj.Class {
+		return hi.Class < hj.Class
+	}
+	return bytes.Compare(p[i].rdata, p[j].rdata) < 0
+}
@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeZone(t *testing.T) {
+	const soa = "example.org.\t3600\tIN\tSOA\tns.example.org. hostmaster.example.org. 1 3600 600 86400 300\n"
+	tests := []struct {
+		name, zone, out string
+	}{
+		{"order",
+			`www.example.org. 300 IN A 192.0.2.2
+a.b.example.org. 300 IN A 192.0.2.3
+example.org. 3600 IN NS ns.example.org.
+www.example.org. 300 IN A 192.0.2.1
+example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300
+b.example.org. 300 IN A 192.0.2.4
+www.example.org. 300 IN AAAA 2001:db8::1
+`,
+			soa + `example.org.	3600	IN	NS	ns.example.org.
+b.example.org.	300	IN	A	192.0.2.4
+a.b.example.org.	300	IN	A	192.0.2.3
+www.example.org.	300	IN	A	192.0.2.1
+www.example.org.	300	IN	A	192.0.2.2
+www.example.org.	300	IN	AAAA	2001:db8::1
+`},
+		{"duplicates",
+			`example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300
+www.example.org. 300 IN A 192.0.2.1
+www.example.org. 300 IN A 192.0.2.1
+www.example.org. 600 IN A 192.0.2.1
+`,
+			soa + "www.example.org.\t300\tIN\tA\t192.0.2.1\n"},
+		{"case",
+			`$ORIGIN Example.ORG.
+@ 3600 IN SOA ns hostmaster 1 3600 600 86400 300
+WWW 300 IN A 192.0.2.1
+www 300 IN A 192.0.2.1
+Mail 300 IN MX 10 Mail.Example.ORG.
+`,
+			"example.org.\t3600\tIN\tSOA\tns.Example.ORG. hostmaster.Example.ORG. 1 3600 600 86400 300\n" +
+				"mail.example.org.\t300\tIN\tMX\t10 Mail.Example.ORG.\n" +
+				"www.example.org.\t300\tIN\tA\t192.0.2.1\n"},
+		{"escapes",
+			`example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300
+\200.example.org. 300 IN A 192.0.2.1
+z.example.org. 300 IN A 192.0.2.2
+`,
+			soa + "z.example.org.\t300\tIN\tA\t192.0.2.2\n\\200.example.org.\t300\tIN\tA\t192.0.2.1\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := NormalizeZone(strings.NewReader(tt.zone), &out, nil); err != nil {
+			t.Errorf("%s: failed to normalize: %s", tt.name, err.Error())
+			continue
+		}
+		if out.String() != tt.out {
+			t.Errorf("%s: expected\n%sgot\n%s", tt.name, tt.out, out.String())
+		}
+	}
+}
+
+func TestNormalizeZoneErrors(t *testing.T) {
+	const soa = "example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300\n"
+	tests := []struct {
+		zone, err string
+	}{
+		{"www.example.org. 300 IN A 192.0.2.1\n", ErrSoa.Error()},
+		{soa + soa, "more than one SOA"},
+		{soa + "www.example.net. 300 IN A 192.0.2.1\n", "out of zone data"},
+		{soa + "www.example.org. 300 IN CNAME example.org.\nwww.example.org. 300 IN A 192.0.2.1\n", "CNAME and other data"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := NormalizeZone(strings.NewReader(tt.zone), &out, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("expected %q, got %v for\n%s", tt.err, err, tt.zone)
+		}
+		if out.Len() > 0 {
+			t.Errorf("expected no output on error, got\n%s", out.String())
+		}
+	}
+}
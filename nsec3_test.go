@@ -1,6 +1,9 @@
 package dns
 
 import (
+	"net"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -17,3 +20,132 @@ func TestPackNsec3(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestNSEC3Policy(t *testing.T) {
+	tests := map[string]bool{"miek.nl. NSEC3PARAM 1 0 0 -": true,
+		"miek.nl. NSEC3PARAM 1 0 10 AABBCCDD":  true,
+		"miek.nl. NSEC3PARAM 1 0 120 AABBCCDD": false,
+		"miek.nl. NSEC3PARAM 1 0 500 AABBCCDD": false}
+	for s, ok := range tests {
+		rr, err := NewRR(s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", s, err.Error())
+		}
+		if err := DefaultNSEC3Policy.Check(rr); (err == nil) != ok {
+			t.Errorf("%s: expected ok to be %t, got %v", s, ok, err)
+		}
+	}
+	rr, _ := NewRR("miek.nl. NSEC3PARAM 1 0 120 AABBCCDD")
+	if e, ok := DefaultNSEC3Policy.Check(rr).(*NSEC3Error); !ok || !e.Insecure {
+		t.Error("120 iterations should be insecure")
+	}
+	rr, _ = NewRR("miek.nl. NSEC3PARAM 1 0 10 AABBCCDD")
+	if e, ok := StrictNSEC3Policy.Check(rr).(*NSEC3Error); !ok || !e.Insecure {
+		t.Error("iterations should be insecure with the strict policy")
+	}
+	z := NewZone("miek.nl.")
+	z.NSEC3Policy = DefaultNSEC3Policy
+	rr, _ = NewRR("miek.nl. NSEC3PARAM 1 0 500 AABBCCDD")
+	if err := z.Insert(rr); err == nil {
+		t.Error("zone should refuse NSEC3PARAM with 500 iterations")
+	}
+}
+
+// walkServer starts a server that answers with the records from h and
+// returns its address.
+func walkServer(t *testing.T, h HandlerFunc) string {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	go (&Server{Handler: h}).serveUDP(l)
+	return l.LocalAddr().String()
+}
+
+func TestWalkNSEC(t *testing.T) {
+	chain := map[string]string{"example.org.": "a.example.org.", "a.example.org.": "mail.example.org.", "mail.example.org.": "example.org."}
+	nsec := func(owner string) RR {
+		return &NSEC{Hdr: RR_Header{owner, TypeNSEC, ClassINET, 3600, 0}, NextDomain: chain[owner], TypeBitMap: []uint16{TypeA, TypeRRSIG, TypeNSEC}}
+	}
+	a := walkServer(t, func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetReply(req)
+		name := req.Question[0].Name
+		switch {
+		case req.Question[0].Qtype == TypeNSEC && name != "a.example.org.":
+			m.Answer = []RR{nsec(name)}
+		case strings.HasPrefix(name, `\000.`):
+			m.Rcode = RcodeNameError
+			m.Ns = []RR{nsec(name[5:])}
+		}
+		w.WriteMsg(m)
+	})
+	nsecs, err := WalkNSEC(new(Client), a, "Example.org")
+	if err != nil {
+		t.Fatalf("failed to walk the zone: %s", err.Error())
+	}
+	if len(nsecs) != 3 || nsecs[1].Hdr.Name != "a.example.org." || nsecs[2].NextDomain != "example.org." {
+		t.Errorf("bad NSEC chain: %v", nsecs)
+	}
+}
+
+func TestMapNSEC3(t *testing.T) {
+	var hashes []string
+	for _, n := range []string{"example.org.", "www.example.org.", "mail.example.org."} {
+		hashes = append(hashes, HashName(n, SHA1, 1, "AABB"))
+	}
+	sort.Strings(hashes)
+	var chain []*NSEC3
+	for i, h := range hashes {
+		chain = append(chain, &NSEC3{Hdr: RR_Header{strings.ToLower(h) + ".example.org.", TypeNSEC3, ClassINET, 3600, 0},
+			Hash: SHA1, Iterations: 1, SaltLength: 2, Salt: "AABB", HashLength: 20, NextDomain: hashes[(i+1)%len(hashes)], TypeBitMap: []uint16{TypeA, TypeRRSIG}})
+	}
+	queries := 0
+	a := walkServer(t, func(w ResponseWriter, req *Msg) {
+		queries++
+		m := new(Msg)
+		m.SetReply(req)
+		m.Rcode = RcodeNameError
+		h := HashName(req.Question[0].Name, SHA1, 1, "AABB")
+		// The last record covers the hashes before the first one
+		m.Ns = []RR{chain[len(chain)-1]}
+		for i := len(hashes) - 1; i >= 0; i-- {
+			if h > hashes[i] {
+				m.Ns = []RR{chain[i]}
+				break
+			}
+		}
+		w.WriteMsg(m)
+	})
+	nsec3s, complete, err := MapNSEC3(new(Client), a, "example.org.", 500)
+	if err != nil {
+		t.Fatalf("failed to map the zone: %s", err.Error())
+	}
+	if !complete || len(nsec3s) != 3 || nsec3s[0].Hdr.Name != chain[0].Hdr.Name {
+		t.Errorf("expected the complete chain, got %v (complete: %t)", nsec3s, complete)
+	}
+	if queries > 100 {
+		t.Errorf("too many queries: %d", queries)
+	}
+}
+
+func TestNSECCover(t *testing.T) {
+	n := &NSEC{Hdr: RR_Header{Name: "a.miek.nl.", Rrtype: TypeNSEC, Class: ClassINET}, NextDomain: "z.miek.nl."}
+	for name, cover := range map[string]bool{
+		"b.miek.nl.":     true,
+		"B.Miek.NL.":     true,
+		"x.b.miek.nl.":   true,
+		"a.miek.nl.":     false,
+		"z.miek.nl.":     false,
+		"\\200.miek.nl.": false, // octet 200 sorts after z
+		"\\099.miek.nl.": true,  // c
+	} {
+		if n.Cover(name) != cover {
+			t.Errorf("expected Cover(%s) to be %t", name, cover)
+		}
+	}
+	last := &NSEC{Hdr: RR_Header{Name: "z.miek.nl.", Rrtype: TypeNSEC, Class: ClassINET}, NextDomain: "miek.nl."}
+	if !last.Cover("\\200.miek.nl.") || last.Cover("b.miek.nl.") {
+		t.Error("the last NSEC should only cover the names after its owner")
+	}
+}
@@ -0,0 +1,161 @@
+package dns
+
+// Enumerating the names in a signed zone by walking the NSEC chain, or
+// mapping the hashed intervals of the NSEC3 chain.
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WalkNSEC enumerates the names in the zone on the name server a by
+// following the NSEC chain, starting at the apex, using c. For each name
+// the NSEC record is asked for, when the server doesn't return it the
+// NSEC record is taken from the denial of the name directly following it
+// (\000.name). The NSEC records are returned in the order of the chain.
+// When the chain is broken, the records found so far are returned together
+// with the error.
+func WalkNSEC(c *Client, a, zone string) ([]*NSEC, error) {
+	zone = Fqdn(strings.ToLower(zone))
+	var chain []*NSEC
+	seen := make(map[string]bool)
+	for name := zone; ; {
+		nsec, err := nsecOf(c, a, name)
+		if err != nil {
+			return chain, err
+		}
+		chain = append(chain, nsec)
+		seen[name] = true
+		next := strings.ToLower(nsec.NextDomain)
+		if next == zone {
+			return chain, nil
+		}
+		if seen[next] || !IsSubDomain(zone, next) {
+			return chain, &Error{Err: "broken NSEC chain", Name: next}
+		}
+		name = next
+	}
+}
+
+// nsecOf returns the NSEC record owned by name.
+func nsecOf(c *Client, a, name string) (*NSEC, error) {
+	for _, q := range []Question{{name, TypeNSEC, ClassINET}, {`\000.` + name, TypeA, ClassINET}} {
+		m := new(Msg)
+		m.SetQuestion(q.Name, q.Qtype)
+		m.SetEdns0(4096, true)
+		r, _, err := c.Exchange(m, a)
+		if err != nil {
+			return nil, err
+		}
+		if r.Rcode != RcodeSuccess && r.Rcode != RcodeNameError {
+			return nil, &Error{Err: "NSEC query failed: " + RcodeToString[r.Rcode], Name: q.Name, Rcode: r.Rcode, kind: ErrRcode}
+		}
+		for _, rr := range append(r.Answer, r.Ns...) {
+			if nsec, ok := rr.(*NSEC); ok && strings.ToLower(nsec.Hdr.Name) == name {
+				return nsec, nil
+			}
+		}
+	}
+	return nil, &Error{Err: "no NSEC record", Name: name}
+}
+
+// MapNSEC3 maps the NSEC3 chain of the zone on the name server a, using
+// c. Random names in the zone are asked for, each denial gives an interval
+// of hashes without names. Names that hash into an interval that is
+// already known are not asked for. This stops when the chain is complete
+// or after maxQueries queries. The hashes are not cracked, but the
+// number of names in the zone is known when the chain is complete. The
+// NSEC3 records are returned sorted on their hash, complete is true when
+// they form the complete chain.
+func MapNSEC3(c *Client, a, zone string, maxQueries int) (chain []*NSEC3, complete bool, err error) {
+	zone = Fqdn(strings.ToLower(zone))
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	label := make([]byte, 12)
+	known := make(map[string]*NSEC3) // keyed on the hash of the owner name
+	var params *NSEC3
+	for queries, tries := 0, 0; queries < maxQueries && tries < maxQueries*1000; tries++ {
+		for i := range label {
+			label[i] = chars[r.Intn(len(chars))]
+		}
+		name := string(label) + "." + zone
+		if params != nil && nsec3Covered(known, HashName(name, params.Hash, params.Iterations, params.Salt)) {
+			continue
+		}
+		queries++
+		m := new(Msg)
+		m.SetQuestion(name, TypeA)
+		m.SetEdns0(4096, true)
+		in, _, err := c.Exchange(m, a)
+		if err != nil {
+			return nsec3Chain(known), false, err
+		}
+		for _, rr := range in.Ns {
+			if n, ok := rr.(*NSEC3); ok {
+				known[nsec3Hash(n)] = n
+				if params == nil {
+					params = n
+				}
+			}
+		}
+		if params == nil {
+			return nil, false, &Error{Err: "no NSEC3 records", Name: zone}
+		}
+		if nsec3Complete(known) {
+			return nsec3Chain(known), true, nil
+		}
+	}
+	return nsec3Chain(known), false, nil
+}
+
+// nsec3Hash returns the hash in the owner name of n, in upper case.
+func nsec3Hash(n *NSEC3) string {
+	return strings.ToUpper(SplitLabels(n.Hdr.Name)[0])
+}
+
+// nsec3Covered returns true when hash is the owner of, or covered by,
+// one of the known NSEC3 records.
+func nsec3Covered(known map[string]*NSEC3, hash string) bool {
+	if hash == "" {
+		return false
+	}
+	for owner, n := range known {
+		next := strings.ToUpper(n.NextDomain)
+		if hash == owner || (owner < next && hash > owner && hash < next) || (owner >= next && (hash > owner || hash < next)) {
+			return true
+		}
+	}
+	return false
+}
+
+// nsec3Complete returns true when the known NSEC3 records form a
+// closed chain.
+func nsec3Complete(known map[string]*NSEC3) bool {
+	for start := range known {
+		owner := start
+		for i := 0; i < len(known); i++ {
+			n, ok := known[owner]
+			if !ok {
+				return false
+			}
+			owner = strings.ToUpper(n.NextDomain)
+		}
+		return owner == start
+	}
+	return false
+}
+
+func nsec3Chain(known map[string]*NSEC3) []*NSEC3 {
+	hashes := make([]string, 0, len(known))
+	for h := range known {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	chain := make([]*NSEC3, len(hashes))
+	for i, h := range hashes {
+		chain[i] = known[h]
+	}
+	return chain
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha1"
 	"hash"
 	"io"
+	"strconv"
 	"strings"
 )
 
@@ -115,7 +116,77 @@ func (rr *NSEC3) Cover(domain string) bool {
 	return false
 }
 
-// Cover checks if domain is covered by the NSEC record. Domain must be given in plain text.
+// Cover checks if domain is covered by the NSEC record, i.e. if it sorts between
+// the owner name and the next domain name in the canonical order. The last NSEC
+// of a zone, pointing back to the apex, covers all names after its owner.
+// Domain must be given in plain text.
 func (rr *NSEC) Cover(domain string) bool {
-	return false
+	owner, next := rr.Hdr.Name, rr.NextDomain
+	if CanonicalCompare(owner, domain) >= 0 {
+		return false
+	}
+	return CanonicalCompare(domain, next) < 0 || CanonicalCompare(next, owner) <= 0
+}
+
+// NSEC3Policy limits the NSEC3 parameters that are accepted. Computing NSEC3
+// hashes with many iterations is expensive, so validators should not do that
+// for any zone that asks (RFC 9276). Records that exceed InsecureIterations
+// should be treated as insecure, records that exceed BogusIterations as bogus.
+type NSEC3Policy struct {
+	InsecureIterations uint16 // Above this number of iterations the answer is insecure
+	BogusIterations    uint16 // Above this number of iterations the answer is bogus
+	MaxSaltLength      uint8  // Longer salts are bogus
+}
+
+// DefaultNSEC3Policy follows RFC 9276: more than 100 iterations is insecure
+// and more than 150 is bogus.
+var DefaultNSEC3Policy = &NSEC3Policy{InsecureIterations: 100, BogusIterations: 150, MaxSaltLength: 255}
+
+// StrictNSEC3Policy treats any NSEC3 record with additional iterations or
+// a salt as insecure, as recommended for new zones by RFC 9276.
+var StrictNSEC3Policy = &NSEC3Policy{InsecureIterations: 0, BogusIterations: 150, MaxSaltLength: 0}
+
+// NSEC3Error is returned when NSEC3 parameters are not acceptable
+// under an NSEC3Policy. When Insecure is true a resolver should treat the
+// answer as insecure, instead of failing it.
+type NSEC3Error struct {
+	Name       string // Owner name of the NSEC3 or NSEC3PARAM record
+	Iterations uint16
+	SaltLength uint8
+	Insecure   bool // The answer is insecure rather than bogus
+}
+
+func (e *NSEC3Error) Error() string {
+	s := "dns: " + e.Name + ": NSEC3 with " + strconv.Itoa(int(e.Iterations)) + " iterations and salt length " + strconv.Itoa(int(e.SaltLength))
+	if e.Insecure {
+		return s + " is insecure"
+	}
+	return s + " is bogus"
+}
+
+// Check checks the parameters of rr, which must be an NSEC3 or NSEC3PARAM
+// record, against p. It returns nil when they are acceptable and
+// an *NSEC3Error otherwise. Other records are always acceptable.
+func (p *NSEC3Policy) Check(rr RR) error {
+	var (
+		iter uint16
+		salt uint8
+	)
+	switch x := rr.(type) {
+	case *NSEC3:
+		iter, salt = x.Iterations, x.SaltLength
+	case *NSEC3PARAM:
+		iter, salt = x.Iterations, x.SaltLength
+	default:
+		return nil
+	}
+	e := &NSEC3Error{Name: rr.Header().Name, Iterations: iter, SaltLength: salt}
+	if iter > p.BogusIterations {
+		return e
+	}
+	if iter > p.InsecureIterations || salt > p.MaxSaltLength {
+		e.Insecure = true
+		return e
+	}
+	return nil
 }
@@ -0,0 +1,442 @@
+// OBLIVIOUS DNS OVER HTTPS
+//
+// Oblivious DoH (RFC 9230) hides the client's address from the resolver: the
+// query is encrypted (with HPKE, RFC 9180) to the public key of the target
+// resolver and sent over HTTPS to a proxy (the relay), which forwards it to
+// the target. The proxy sees the client, but not the query; the target sees
+// the query, but not the client. The reply is encrypted with a key derived
+// from the query, so only the client can read it.
+//
+// The public key of the target is published in its ODoH configuration, which
+// is fetched from https://<target>/.well-known/odohconfigs. Basic use
+// pattern:
+//
+//	configs, err := dns.FetchODoHConfigs(nil, "odoh.example.net")
+//	c := &dns.ODoHClient{Proxy: "https://relay.example.org/proxy",
+//		Target: "odoh.example.net", Config: configs[0]}
+//	in, rtt, err := c.Exchange(m)
+//
+// Only the AES-GCM AEADs are supported, configurations using
+// ChaCha20Poly1305 are skipped by ParseODoHConfigs.
+package dns
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hpke"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	ODoHVersion     = 0x0001
+	ODoHContentType = "application/oblivious-dns-message"
+
+	odohQuery    = 0x01
+	odohResponse = 0x02
+)
+
+// ODoHConfig is the configuration of an ODoH target, see RFC 9230,
+// section 6.
+type ODoHConfig struct {
+	KemID     uint16
+	KdfID     uint16
+	AeadID    uint16
+	PublicKey []byte
+}
+
+// ParseODoHConfigs parses the ObliviousDoHConfigs structure in b, the
+// format served by the target. Configurations with an unknown version
+// or with algorithms that are not supported are skipped.
+func ParseODoHConfigs(b []byte) ([]*ODoHConfig, error) {
+	if len(b) < 2 {
+		return nil, ErrBuf
+	}
+	l, off := unpackUint16(b, 0)
+	if int(l) != len(b)-2 {
+		return nil, ErrBuf
+	}
+	var configs []*ODoHConfig
+	for off < len(b) {
+		if off+4 > len(b) {
+			return nil, ErrBuf
+		}
+		version, off1 := unpackUint16(b, off)
+		length, off1 := unpackUint16(b, off1)
+		if off1+int(length) > len(b) {
+			return nil, ErrBuf
+		}
+		off = off1 + int(length)
+		if version != ODoHVersion {
+			continue
+		}
+		c, err := parseODoHConfigContents(b[off1:off])
+		if err != nil {
+			return nil, err
+		}
+		if c.suite() == nil {
+			continue
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+func parseODoHConfigContents(b []byte) (*ODoHConfig, error) {
+	if len(b) < 8 {
+		return nil, ErrBuf
+	}
+	c := new(ODoHConfig)
+	c.KemID, _ = unpackUint16(b, 0)
+	c.KdfID, _ = unpackUint16(b, 2)
+	c.AeadID, _ = unpackUint16(b, 4)
+	l, _ := unpackUint16(b, 6)
+	if int(l) != len(b)-8 || l == 0 {
+		return nil, ErrBuf
+	}
+	c.PublicKey = append([]byte(nil), b[8:]...)
+	return c, nil
+}
+
+// contents returns the ObliviousDoHConfigContents of c in wire format.
+func (c *ODoHConfig) contents() []byte {
+	b := make([]byte, 8, 8+len(c.PublicKey))
+	b[0], b[1] = packUint16(c.KemID)
+	b[2], b[3] = packUint16(c.KdfID)
+	b[4], b[5] = packUint16(c.AeadID)
+	b[6], b[7] = packUint16(uint16(len(c.PublicKey)))
+	return append(b, c.PublicKey...)
+}
+
+// Pack returns c as an ObliviousDoHConfigs structure holding only c.
+func (c *ODoHConfig) Pack() []byte {
+	contents := c.contents()
+	b := make([]byte, 6, 6+len(contents))
+	b[0], b[1] = packUint16(uint16(len(contents) + 4))
+	b[2], b[3] = packUint16(ODoHVersion)
+	b[4], b[5] = packUint16(uint16(len(contents)))
+	return append(b, contents...)
+}
+
+// odohSuite holds the algorithms of a configuration.
+type odohSuite struct {
+	kem  hpke.KEM
+	kdf  hpke.KDF
+	aead hpke.AEAD
+	hash func() hash.Hash
+	nk   int // key size of the AEAD
+}
+
+// suite returns the algorithms of c, or nil if they are not supported.
+func (c *ODoHConfig) suite() *odohSuite {
+	s := new(odohSuite)
+	var err error
+	if s.kem, err = hpke.NewKEM(c.KemID); err != nil {
+		return nil
+	}
+	if s.kdf, err = hpke.NewKDF(c.KdfID); err != nil {
+		return nil
+	}
+	if s.aead, err = hpke.NewAEAD(c.AeadID); err != nil {
+		return nil
+	}
+	switch c.KdfID {
+	case 0x0001:
+		s.hash = sha256.New
+	case 0x0002:
+		s.hash = sha512.New384
+	case 0x0003:
+		s.hash = sha512.New
+	default:
+		return nil
+	}
+	switch c.AeadID {
+	case 0x0001:
+		s.nk = 16
+	case 0x0002:
+		s.nk = 32
+	default:
+		return nil
+	}
+	return s
+}
+
+// KeyID returns the key identifier of c, which is sent with each query.
+func (c *ODoHConfig) KeyID() ([]byte, error) {
+	s := c.suite()
+	if s == nil {
+		return nil, ErrAlg
+	}
+	prk, err := hkdf.Extract(s.hash, c.contents(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Expand(s.hash, prk, "odoh key id", s.hash().Size())
+}
+
+// FetchODoHConfigs fetches the configurations of target (a host name, with
+// an optional port) from its well-known location. If client is nil,
+// http.DefaultClient is used.
+func FetchODoHConfigs(client *http.Client, target string) ([]*ODoHConfig, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get("https://" + target + "/.well-known/odohconfigs")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Err: "fetching odoh configs: " + resp.Status, Name: target}
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	return ParseODoHConfigs(b)
+}
+
+// An ODoHClient sends queries to the target Target via the proxy Proxy.
+type ODoHClient struct {
+	Proxy      string       // URL of the proxy, e.g. "https://relay.example.org/proxy"
+	Target     string       // host name of the target
+	TargetPath string       // path of the target's DoH endpoint, defaults to "/dns-query"
+	Config     *ODoHConfig  // configuration of the target
+	HTTPClient *http.Client // if nil, http.DefaultClient is used
+	Padding    int          // if not zero, the query is padded to a multiple of Padding bytes
+}
+
+// Exchange sends the query m through the proxy to the target and returns
+// the decrypted reply.
+func (c *ODoHClient) Exchange(m *Msg) (r *Msg, rtt time.Duration, err error) {
+	q, err := m.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	body, ctx, err := c.Config.seal(q, c.Padding)
+	if err != nil {
+		return nil, 0, err
+	}
+	u, err := url.Parse(c.Proxy)
+	if err != nil {
+		return nil, 0, err
+	}
+	path := c.TargetPath
+	if path == "" {
+		path = "/dns-query"
+	}
+	v := u.Query()
+	v.Set("targethost", c.Target)
+	v.Set("targetpath", path)
+	u.RawQuery = v.Encode()
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", ODoHContentType)
+	req.Header.Set("Accept", ODoHContentType)
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	t := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, &Error{Err: "odoh: " + resp.Status, Name: c.Proxy}
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16+1024))
+	if err != nil {
+		return nil, 0, err
+	}
+	rtt = time.Since(t)
+	plain, err := ctx.open(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	r = new(Msg)
+	if err := r.Unpack(plain); err != nil {
+		return nil, 0, err
+	}
+	if r.Id != m.Id {
+		return r, rtt, ErrId
+	}
+	return r, rtt, nil
+}
+
+// odohContext holds what is needed to decrypt the response to a query.
+type odohContext struct {
+	suite  *odohSuite
+	secret []byte // exported from the HPKE context of the query
+	query  []byte // plaintext of the query
+}
+
+// odohPlaintext returns the ObliviousDoHMessagePlaintext for msg, padded to a
+// multiple of padding bytes.
+func odohPlaintext(msg []byte, padding int) []byte {
+	pad := 0
+	if padding > 0 && (len(msg)+4)%padding != 0 {
+		pad = padding - (len(msg)+4)%padding
+	}
+	b := make([]byte, 2, len(msg)+4+pad)
+	b[0], b[1] = packUint16(uint16(len(msg)))
+	b = append(b, msg...)
+	l0, l1 := packUint16(uint16(pad))
+	b = append(b, l0, l1)
+	return append(b, make([]byte, pad)...)
+}
+
+// odohMessage returns an ObliviousDoHMessage in wire format.
+func odohMessage(t byte, keyID, msg []byte) []byte {
+	b := make([]byte, 3, 5+len(keyID)+len(msg))
+	b[0] = t
+	b[1], b[2] = packUint16(uint16(len(keyID)))
+	b = append(b, keyID...)
+	l0, l1 := packUint16(uint16(len(msg)))
+	b = append(b, l0, l1)
+	return append(b, msg...)
+}
+
+// parseODoHMessage returns the type, the key id and the encrypted message of
+// the ObliviousDoHMessage in b.
+func parseODoHMessage(b []byte) (t byte, keyID, msg []byte, err error) {
+	if len(b) < 3 {
+		return 0, nil, nil, ErrBuf
+	}
+	l, _ := unpackUint16(b, 1)
+	off := 3 + int(l)
+	if off+2 > len(b) {
+		return 0, nil, nil, ErrBuf
+	}
+	keyID = b[3:off]
+	l, _ = unpackUint16(b, off)
+	if off+2+int(l) != len(b) {
+		return 0, nil, nil, ErrBuf
+	}
+	return b[0], keyID, b[off+2:], nil
+}
+
+// odohAAD returns the additional authenticated data for a message of type t.
+func odohAAD(t byte, keyID []byte) []byte {
+	b := []byte{t, 0, 0}
+	b[1], b[2] = packUint16(uint16(len(keyID)))
+	return append(b, keyID...)
+}
+
+// seal encrypts the query q to c, and returns the ObliviousDoHMessage and
+// the context needed to decrypt the response.
+func (c *ODoHConfig) seal(q []byte, padding int) ([]byte, *odohContext, error) {
+	s := c.suite()
+	if s == nil {
+		return nil, nil, ErrAlg
+	}
+	keyID, err := c.KeyID()
+	if err != nil {
+		return nil, nil, err
+	}
+	pk, err := s.kem.NewPublicKey(c.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, sender, err := hpke.NewSender(pk, s.kdf, s.aead, []byte("odoh query"))
+	if err != nil {
+		return nil, nil, err
+	}
+	plain := odohPlaintext(q, padding)
+	ct, err := sender.Seal(odohAAD(odohQuery, keyID), plain)
+	if err != nil {
+		return nil, nil, err
+	}
+	secret, err := sender.Export("odoh response", s.nk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return odohMessage(odohQuery, keyID, append(enc, ct...)), &odohContext{s, secret, plain}, nil
+}
+
+// aead returns the AEAD and nonce for the response with nonce responseNonce,
+// see RFC 9230, section 6.4.
+func (ctx *odohContext) aead(responseNonce []byte) (cipher.AEAD, []byte, error) {
+	salt := append([]byte(nil), ctx.query...)
+	l0, l1 := packUint16(uint16(len(responseNonce)))
+	salt = append(salt, l0, l1)
+	salt = append(salt, responseNonce...)
+	prk, err := hkdf.Extract(ctx.suite.hash, ctx.secret, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := hkdf.Expand(ctx.suite.hash, prk, "odoh key", ctx.suite.nk)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := hkdf.Expand(ctx.suite.hash, prk, "odoh nonce", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := cipher.NewGCM(block)
+	return a, nonce, err
+}
+
+// open decrypts the ObliviousDoHMessage response b and returns the DNS
+// message in it.
+func (ctx *odohContext) open(b []byte) ([]byte, error) {
+	t, responseNonce, ct, err := parseODoHMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	if t != odohResponse {
+		return nil, &Error{Err: "odoh: not a response"}
+	}
+	a, nonce, err := ctx.aead(responseNonce)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := a.Open(nil, nonce, ct, odohAAD(odohResponse, responseNonce))
+	if err != nil {
+		return nil, err
+	}
+	return unpackODoHPlaintext(plain)
+}
+
+// seal encrypts the response r to the query of ctx, this is done by the
+// target.
+func (ctx *odohContext) seal(r []byte) ([]byte, error) {
+	responseNonce := make([]byte, ctx.suite.nk)
+	if _, err := rand.Read(responseNonce); err != nil {
+		return nil, err
+	}
+	a, nonce, err := ctx.aead(responseNonce)
+	if err != nil {
+		return nil, err
+	}
+	ct := a.Seal(nil, nonce, odohPlaintext(r, 0), odohAAD(odohResponse, responseNonce))
+	return odohMessage(odohResponse, responseNonce, ct), nil
+}
+
+// unpackODoHPlaintext returns the DNS message in the
+// ObliviousDoHMessagePlaintext b.
+func unpackODoHPlaintext(b []byte) ([]byte, error) {
+	if len(b) < 2 {
+		return nil, ErrBuf
+	}
+	l, off := unpackUint16(b, 0)
+	if off+int(l)+2 > len(b) {
+		return nil, ErrBuf
+	}
+	return b[off : off+int(l)], nil
+}
@@ -0,0 +1,73 @@
+package dns
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestODoHExchange(t *testing.T) {
+	kem := hpke.DHKEM(ecdh.X25519())
+	priv, err := kem.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	config := &ODoHConfig{KemID: kem.ID(), KdfID: 0x0001, AeadID: 0x0001, PublicKey: priv.PublicKey().Bytes()}
+	configs, err := ParseODoHConfigs(config.Pack())
+	if err != nil || len(configs) != 1 {
+		t.Fatalf("failed to parse the packed config: %v", err)
+	}
+	keyID, _ := config.KeyID()
+
+	// The test server is both the proxy and the target
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("targethost") != "odoh.example.net" || r.Header.Get("Content-Type") != ODoHContentType {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		typ, id, msg, err := parseODoHMessage(b)
+		if err != nil || typ != odohQuery || string(id) != string(keyID) || len(msg) < 32 {
+			http.Error(w, "bad message", http.StatusBadRequest)
+			return
+		}
+		suite := configs[0].suite()
+		rcpt, err := hpke.NewRecipient(msg[:32], priv, suite.kdf, suite.aead, []byte("odoh query"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		plain, err := rcpt.Open(odohAAD(odohQuery, id), msg[32:])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		secret, _ := rcpt.Export("odoh response", suite.nk)
+		q, _ := unpackODoHPlaintext(plain)
+		req := new(Msg)
+		req.Unpack(q)
+		m := new(Msg)
+		m.SetReply(req)
+		a, _ := NewRR(req.Question[0].Name + " 3600 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, a)
+		out, _ := m.Pack()
+		resp, _ := (&odohContext{suite, secret, plain}).seal(out)
+		w.Header().Set("Content-Type", ODoHContentType)
+		w.Write(resp)
+	}))
+	defer s.Close()
+
+	c := &ODoHClient{Proxy: s.URL + "/proxy", Target: "odoh.example.net", Config: configs[0], Padding: 128}
+	m := new(Msg)
+	m.SetQuestion("www.example.org.", TypeA)
+	r, _, err := c.Exchange(m)
+	if err != nil {
+		t.Fatalf("failed to exchange: %s", err.Error())
+	}
+	if len(r.Answer) != 1 || r.Answer[0].(*A).A.String() != "192.0.2.1" {
+		t.Errorf("unexpected reply:\n%s", r.String())
+	}
+}
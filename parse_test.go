@@ -300,7 +300,7 @@ func TestQuotes(t *testing.T) {
 	tests := map[string]string{
 		`t.example.com. IN TXT "a bc"`: "t.example.com.\t3600\tIN\tTXT\t\"a bc\"",
 		`t.example.com. IN TXT "a
- bc"`: "t.example.com.\t3600\tIN\tTXT\t\"a\\n bc\"",
+ bc"`: "t.example.com.\t3600\tIN\tTXT\t\"a\\010 bc\"",
 		`t.example.com. IN TXT "a"`:                                                          "t.example.com.\t3600\tIN\tTXT\t\"a\"",
 		`t.example.com. IN TXT "aa"`:                                                         "t.example.com.\t3600\tIN\tTXT\t\"aa\"",
 		`t.example.com. IN TXT "aaa" ;`:                                                      "t.example.com.\t3600\tIN\tTXT\t\"aaa\"",
@@ -705,3 +705,59 @@ func TestILNP(t *testing.T) {
 		}
 	}
 }
+
+func TestParseZoneAll(t *testing.T) {
+	zone := `$ORIGIN example.org.
+@	IN	SOA	ns hostmaster 1 3600 600 86400 300
+www	IN	A	192.0.2.1
+bad	IN	A	192.0.2.256
+mail	IN	MX	10 www
+too..many.dots	IN	A	192.0.2.2
+ftp	IN	A	192.0.2.3
+`
+	rrs, err := ParseZoneAll(strings.NewReader(zone), "", "db.example.org")
+	if len(rrs) != 4 {
+		t.Errorf("expected 4 RRs, got %d", len(rrs))
+	}
+	errs, ok := err.(ParseErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	if errs[0].File() != "db.example.org" || errs[0].Line() != 4 || errs[0].Token() != "192.0.2.256" {
+		t.Errorf("bad position of the first error: %s", errs[0].Error())
+	}
+	if errs[1].Line() != 6 || errs[1].Unwrap() != ErrLabelEmpty {
+		t.Errorf("expected an empty label error on line 6, got %s", errs[1].Error())
+	}
+	// ParseZone still stops at the first error
+	n := 0
+	for x := range ParseZone(strings.NewReader(zone), "", "") {
+		if n++; x.Error != nil && n != 3 {
+			t.Errorf("expected the error as the third token, got %d", n)
+		}
+	}
+	if n != 3 {
+		t.Errorf("ParseZone did not stop after the error, got %d tokens", n)
+	}
+}
+
+func TestNewRRWithOrigin(t *testing.T) {
+	tests := map[string]string{
+		"www CNAME @":                    "www.example.org.\t300\tIN\tCNAME\texample.org.",
+		"@ 60 MX 10 mail":                "example.org.\t60\tIN\tMX\t10 mail.example.org.",
+		"ns.example.net. IN A 192.0.2.1": "ns.example.net.\t300\tIN\tA\t192.0.2.1",
+	}
+	for s, want := range tests {
+		rr, err := NewRRWithOrigin(s, "example.org", 300)
+		if err != nil {
+			t.Errorf("failed to parse %q: %s", s, err.Error())
+			continue
+		}
+		if rr.String() != want {
+			t.Errorf("expected %q, got %q", want, rr.String())
+		}
+	}
+	if _, err := NewRRWithOrigin("www A 192.0.2.1", "bad..origin", 300); err == nil {
+		t.Error("expected an error for a bad origin")
+	}
+}
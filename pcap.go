@@ -0,0 +1,325 @@
+package dns
+
+// Reading DNS messages from packet captures and replaying them.
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Link types of pcap files, only these are supported.
+const (
+	LinkTypeNull     = 0   // BSD loopback
+	LinkTypeEthernet = 1   // Ethernet
+	LinkTypeRaw      = 101 // Raw IPv4 or IPv6
+	LinkTypeLinuxSLL = 113 // Linux "cooked" capture
+)
+
+// maxPcapRecord is the largest pcap record that is read, larger records,
+// or records larger than the snapshot length of the file, are an error.
+const maxPcapRecord = 256 << 10
+
+// Capture is a DNS message read from a packet capture.
+type Capture struct {
+	Msg   *Msg      // The message
+	Time  time.Time // The time the packet was captured
+	Src   net.Addr  // The source address, a *net.UDPAddr or *net.TCPAddr
+	Dst   net.Addr  // The destination address
+	Error error     // If something went wrong, this contains the error
+}
+
+// ReadPcap reads the pcap file (the libpcap format, not pcapng) from r. It
+// returns a channel of *Capture on which the DNS messages in the capture are
+// sent, the channel is closed at the end of the file or after a Capture
+// with an error. Packets that are not IP over one of the supported link
+// types, that are not UDP or TCP or that do not hold a valid DNS message are
+// skipped, as are IP fragments. TCP streams are not reassembled: only the
+// messages that are completely contained in a segment are found.
+//
+// Basic use pattern:
+//
+//	f, _ := os.Open("dns.pcap")
+//	for c := range dns.ReadPcap(f) {
+//		if c.Error != nil {
+//			// ...
+//		}
+//		fmt.Printf("%s %s > %s\n%s\n", c.Time, c.Src, c.Dst, c.Msg)
+//	}
+func ReadPcap(r io.Reader) chan *Capture {
+	c := make(chan *Capture)
+	go readPcap(r, c)
+	return c
+}
+
+func readPcap(r io.Reader, c chan *Capture) {
+	defer close(c)
+	hdr := make([]byte, 24)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		c <- &Capture{Error: &Error{Err: "short pcap header"}}
+		return
+	}
+	var (
+		order binary.ByteOrder
+		nano  bool
+	)
+	switch binary.LittleEndian.Uint32(hdr) {
+	case 0xa1b2c3d4:
+		order = binary.LittleEndian
+	case 0xa1b23c4d:
+		order, nano = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		order = binary.BigEndian
+	case 0x4d3cb2a1:
+		order, nano = binary.BigEndian, true
+	default:
+		c <- &Capture{Error: &Error{Err: "not a pcap file"}}
+		return
+	}
+	linktype := int(order.Uint32(hdr[20:]))
+	switch linktype {
+	case LinkTypeNull, LinkTypeEthernet, LinkTypeRaw, LinkTypeLinuxSLL:
+	default:
+		c <- &Capture{Error: &Error{Err: "unsupported pcap link type"}}
+		return
+	}
+	max := uint32(maxPcapRecord)
+	if snaplen := order.Uint32(hdr[16:]); snaplen != 0 && snaplen < max {
+		max = snaplen
+	}
+	rec := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, rec); err != nil {
+			if err != io.EOF {
+				c <- &Capture{Error: &Error{Err: "short pcap record"}}
+			}
+			return
+		}
+		l := order.Uint32(rec[8:])
+		if l > max {
+			c <- &Capture{Error: &Error{Err: "pcap record too large"}}
+			return
+		}
+		data := make([]byte, l)
+		if _, err := io.ReadFull(r, data); err != nil {
+			c <- &Capture{Error: &Error{Err: "short pcap record"}}
+			return
+		}
+		frac := int64(order.Uint32(rec[4:]))
+		if !nano {
+			frac *= 1000
+		}
+		t := time.Unix(int64(order.Uint32(rec)), frac)
+		caps, err := DecodePacket(data, linktype)
+		if err != nil {
+			continue
+		}
+		for _, x := range caps {
+			x.Time = t
+			c <- x
+		}
+	}
+}
+
+// DecodePacket decodes the DNS messages in the packet data, captured on a
+// link of type linktype (one of the LinkType constants). Time is not set in
+// the returned captures. For packets that are not UDP or TCP over IP
+// nothing is returned.
+func DecodePacket(data []byte, linktype int) ([]*Capture, error) {
+	var ip []byte
+	switch linktype {
+	case LinkTypeNull:
+		if len(data) < 4 {
+			return nil, ErrShortRead
+		}
+		ip = data[4:]
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return nil, ErrShortRead
+		}
+		ethertype, off := binary.BigEndian.Uint16(data[12:]), 14
+		if ethertype == 0x8100 { // 802.1Q VLAN tag
+			if len(data) < 18 {
+				return nil, ErrShortRead
+			}
+			ethertype, off = binary.BigEndian.Uint16(data[16:]), 18
+		}
+		if ethertype != 0x0800 && ethertype != 0x86dd {
+			return nil, nil
+		}
+		ip = data[off:]
+	case LinkTypeRaw:
+		ip = data
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, ErrShortRead
+		}
+		ip = data[16:]
+	default:
+		return nil, &Error{Err: "unsupported link type"}
+	}
+	if len(ip) == 0 {
+		return nil, ErrShortRead
+	}
+	var (
+		src, dst net.IP
+		proto    byte
+		payload  []byte
+	)
+	switch ip[0] >> 4 {
+	case 4:
+		if len(ip) < 20 {
+			return nil, ErrShortRead
+		}
+		hl, tl := int(ip[0]&0x0f)*4, int(binary.BigEndian.Uint16(ip[2:]))
+		if hl < 20 || tl < hl || len(ip) < tl {
+			return nil, ErrShortRead
+		}
+		if binary.BigEndian.Uint16(ip[6:])&0x3fff != 0 {
+			return nil, nil // fragment
+		}
+		src, dst, proto, payload = net.IP(ip[12:16]), net.IP(ip[16:20]), ip[9], ip[hl:tl]
+	case 6:
+		if len(ip) < 40 {
+			return nil, ErrShortRead
+		}
+		pl := int(binary.BigEndian.Uint16(ip[4:]))
+		if len(ip) < 40+pl {
+			return nil, ErrShortRead
+		}
+		// Extension headers are not supported
+		src, dst, proto, payload = net.IP(ip[8:24]), net.IP(ip[24:40]), ip[6], ip[40:40+pl]
+	default:
+		return nil, nil
+	}
+	var (
+		tcp  bool
+		s, d int
+	)
+	switch proto {
+	case 17:
+		if len(payload) < 8 {
+			return nil, ErrShortRead
+		}
+		s, d = int(binary.BigEndian.Uint16(payload)), int(binary.BigEndian.Uint16(payload[2:]))
+		payload = payload[8:]
+	case 6:
+		if len(payload) < 20 {
+			return nil, ErrShortRead
+		}
+		off := int(payload[12]>>4) * 4
+		if off < 20 || len(payload) < off {
+			return nil, ErrShortRead
+		}
+		tcp = true
+		s, d = int(binary.BigEndian.Uint16(payload)), int(binary.BigEndian.Uint16(payload[2:]))
+		payload = payload[off:]
+		if len(payload) == 0 {
+			return nil, nil // SYN, ACK, FIN, etc.
+		}
+	default:
+		return nil, nil
+	}
+	msgs, err := DecodePayload(payload, tcp)
+	if err != nil {
+		return nil, err
+	}
+	caps := make([]*Capture, len(msgs))
+	for i, m := range msgs {
+		caps[i] = &Capture{Msg: m}
+		if tcp {
+			caps[i].Src, caps[i].Dst = &net.TCPAddr{IP: src, Port: s}, &net.TCPAddr{IP: dst, Port: d}
+		} else {
+			caps[i].Src, caps[i].Dst = &net.UDPAddr{IP: src, Port: s}, &net.UDPAddr{IP: dst, Port: d}
+		}
+	}
+	return caps, nil
+}
+
+// DecodePayload decodes the DNS messages in the payload of a UDP datagram
+// or, when tcp is true, a TCP segment. A UDP datagram holds one message, in
+// TCP each message is preceded by its length. A message that doesn't
+// completely fit in a TCP segment is ignored.
+func DecodePayload(payload []byte, tcp bool) ([]*Msg, error) {
+	if !tcp {
+		m := new(Msg)
+		if err := m.Unpack(payload); err != nil {
+			return nil, err
+		}
+		return []*Msg{m}, nil
+	}
+	var msgs []*Msg
+	for len(payload) >= 2 {
+		l := int(binary.BigEndian.Uint16(payload))
+		if len(payload) < 2+l {
+			break
+		}
+		m := new(Msg)
+		if err := m.Unpack(payload[2 : 2+l]); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+		payload = payload[2+l:]
+	}
+	return msgs, nil
+}
+
+// ReplayResult is the result of replaying a captured query.
+type ReplayResult struct {
+	Query *Capture      // The captured query
+	Reply *Msg          // The reply from the server
+	Rtt   time.Duration // The round trip time
+	Error error         // If something went wrong, this contains the error
+}
+
+// Replay sends the queries captured in in (as read with ReadPcap) to
+// the server a, using c. Captured replies are skipped. The queries are sent at
+// the pace they were captured, divided by speed: a speed of 2 replays
+// twice as fast. If speed is zero or negative, the queries are sent as
+// fast as possible. Each query is sent in its own goroutine, so a slow
+// server doesn't hold up the rest. The results are sent on the returned
+// channel, which is closed when all queries are answered (or timed out).
+//
+//	f, _ := os.Open("dns.pcap")
+//	for r := range dns.Replay(new(dns.Client), "127.0.0.1:53", dns.ReadPcap(f), 1) {
+//		// ...
+//	}
+func Replay(c *Client, a string, in chan *Capture, speed float64) chan *ReplayResult {
+	out := make(chan *ReplayResult)
+	go func() {
+		var (
+			wg    sync.WaitGroup
+			first time.Time
+			start time.Time
+		)
+		for x := range in {
+			if x.Error != nil {
+				out <- &ReplayResult{Query: x, Error: x.Error}
+				continue
+			}
+			if x.Msg == nil || x.Msg.Response {
+				continue
+			}
+			if first.IsZero() {
+				first, start = x.Time, time.Now()
+			}
+			if speed > 0 {
+				at := start.Add(time.Duration(float64(x.Time.Sub(first)) / speed))
+				if d := at.Sub(time.Now()); d > 0 {
+					time.Sleep(d)
+				}
+			}
+			wg.Add(1)
+			go func(x *Capture) {
+				defer wg.Done()
+				r, rtt, err := c.Exchange(x.Msg, a)
+				out <- &ReplayResult{Query: x, Reply: r, Rtt: rtt, Error: err}
+			}(x)
+		}
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,140 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// pcapFile returns a pcap file with the packets on an Ethernet link,
+// captured at t, t+1s, etc.
+func pcapFile(t time.Time, packets ...[]byte) []byte {
+	b := new(bytes.Buffer)
+	hdr := []uint32{0xa1b2c3d4, 2 | 4<<16, 0, 0, 65535, LinkTypeEthernet}
+	for _, h := range hdr {
+		binary.Write(b, binary.LittleEndian, h)
+	}
+	for i, p := range packets {
+		ts := t.Add(time.Duration(i) * time.Second)
+		for _, h := range []uint32{uint32(ts.Unix()), uint32(ts.Nanosecond() / 1000), uint32(len(p)), uint32(len(p))} {
+			binary.Write(b, binary.LittleEndian, h)
+		}
+		b.Write(p)
+	}
+	return b.Bytes()
+}
+
+// ipv4Packet returns an Ethernet frame with an IPv4 packet with payload,
+// which is UDP when proto is 17 and TCP when it is 6.
+func ipv4Packet(proto byte, src, dst string, sport, dport int, payload []byte) []byte {
+	var l4 []byte
+	if proto == 17 {
+		l4 = make([]byte, 8)
+		binary.BigEndian.PutUint16(l4[4:], uint16(8+len(payload)))
+	} else {
+		l4 = make([]byte, 20)
+		l4[12] = 5 << 4
+		m := make([]byte, 2)
+		binary.BigEndian.PutUint16(m, uint16(len(payload)))
+		payload = append(m, payload...)
+	}
+	binary.BigEndian.PutUint16(l4, uint16(sport))
+	binary.BigEndian.PutUint16(l4[2:], uint16(dport))
+	l4 = append(l4, payload...)
+	ip := make([]byte, 20)
+	ip[0], ip[8], ip[9] = 0x45, 64, proto
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(l4)))
+	copy(ip[12:], net.ParseIP(src).To4())
+	copy(ip[16:], net.ParseIP(dst).To4())
+	eth := make([]byte, 14)
+	binary.BigEndian.PutUint16(eth[12:], 0x0800)
+	return append(append(eth, ip...), l4...)
+}
+
+func TestReadPcap(t *testing.T) {
+	q := new(Msg)
+	q.SetQuestion("miek.nl.", TypeMX)
+	wq, _ := q.Pack()
+	r := new(Msg)
+	r.SetReply(q)
+	wr, _ := r.Pack()
+	start := time.Unix(1371542400, 250000000)
+	f := pcapFile(start,
+		ipv4Packet(17, "192.0.2.1", "192.0.2.53", 4242, 53, wq),
+		ipv4Packet(17, "192.0.2.1", "192.0.2.53", 4243, 53, []byte("no dns here")),
+		ipv4Packet(6, "192.0.2.53", "192.0.2.1", 53, 4244, wr))
+	var caps []*Capture
+	for c := range ReadPcap(bytes.NewReader(f)) {
+		if c.Error != nil {
+			t.Fatalf("failed to read the capture: %s", c.Error.Error())
+		}
+		caps = append(caps, c)
+	}
+	if len(caps) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(caps))
+	}
+	if !caps[0].Time.Equal(start) || caps[0].Src.String() != "192.0.2.1:4242" || caps[0].Msg.Question[0].Name != "miek.nl." {
+		t.Errorf("bad UDP capture: %s %s %v", caps[0].Time, caps[0].Src, caps[0].Msg)
+	}
+	if _, ok := caps[1].Dst.(*net.TCPAddr); !ok || !caps[1].Msg.Response || !caps[1].Time.Equal(start.Add(2*time.Second)) {
+		t.Errorf("bad TCP capture: %s %s %v", caps[1].Time, caps[1].Dst, caps[1].Msg)
+	}
+	for c := range ReadPcap(bytes.NewReader([]byte("not a pcap file, not at all"))) {
+		if c.Error == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	// A record larger than the snapshot length is not read
+	for _, l := range []uint32{65536, 1 << 31} {
+		f := pcapFile(start, ipv4Packet(17, "192.0.2.1", "192.0.2.53", 4242, 53, wq))
+		binary.LittleEndian.PutUint32(f[24+8:], l)
+		var err error
+		for c := range ReadPcap(bytes.NewReader(f)) {
+			err = c.Error
+		}
+		if err == nil || err.Error() != "dns: pcap record too large" {
+			t.Errorf("expected an error for a record of %d bytes, got %v", l, err)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	go (&Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetReply(req)
+		w.WriteMsg(m)
+	})}).serveUDP(l)
+
+	in := make(chan *Capture, 3)
+	start := time.Now()
+	for i, name := range []string{"a.miek.nl.", "b.miek.nl."} {
+		q := new(Msg)
+		q.SetQuestion(name, TypeA)
+		in <- &Capture{Msg: q, Time: start.Add(time.Duration(i) * 200 * time.Millisecond)}
+	}
+	r := new(Msg)
+	r.Response = true
+	in <- &Capture{Msg: r, Time: start}
+	close(in)
+
+	n := 0
+	for res := range Replay(new(Client), l.LocalAddr().String(), in, 2) {
+		if res.Error != nil || res.Reply.Question[0].Name != res.Query.Msg.Question[0].Name {
+			t.Errorf("bad replay result: %v %v", res.Reply, res.Error)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 replayed queries, got %d", n)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("queries replayed too fast: %s", d)
+	}
+}
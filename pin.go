@@ -0,0 +1,134 @@
+package dns
+
+// Pinning the public keys of DNS over TLS (and DNS over HTTPS) upstreams.
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"sync"
+)
+
+// SPKIHash returns the pin of cert: the base64 encoded SHA-256 hash of its
+// SubjectPublicKeyInfo, as in RFC 7469 and RFC 7858, section 4.2.
+func SPKIHash(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// SPKIPins holds the public key pins of servers. When TOFU (trust on first
+// use) is set, the key of the certificate of a server without pins is
+// pinned the first time a connection is made. It's safe for concurrent use
+// by multiple goroutines.
+type SPKIPins struct {
+	TOFU bool
+	pins map[string][]string // keyed on the lower cased server name
+	*sync.RWMutex
+}
+
+// NewSPKIPins returns an empty SPKIPins.
+func NewSPKIPins(tofu bool) *SPKIPins {
+	return &SPKIPins{TOFU: tofu, pins: make(map[string][]string), RWMutex: new(sync.RWMutex)}
+}
+
+// Add adds the pin (see SPKIHash) for server. A server can have more than
+// one pin, for instance for a backup key.
+func (p *SPKIPins) Add(server, pin string) {
+	p.Lock()
+	defer p.Unlock()
+	server = strings.ToLower(server)
+	p.pins[server] = append(p.pins[server], pin)
+}
+
+// Pins returns the pins of server.
+func (p *SPKIPins) Pins(server string) []string {
+	p.RLock()
+	defer p.RUnlock()
+	return append([]string(nil), p.pins[strings.ToLower(server)]...)
+}
+
+// Verify checks that one of the certificates in the chain certs matches
+// a pin of server. When the server has no pins, Verify succeeds. If TOFU is
+// set, the key of the first certificate is then pinned.
+func (p *SPKIPins) Verify(server string, certs []*x509.Certificate) error {
+	server = strings.ToLower(server)
+	got := make([]string, len(certs))
+	for i, c := range certs {
+		got[i] = SPKIHash(c)
+	}
+	p.Lock()
+	defer p.Unlock()
+	pins, ok := p.pins[server]
+	if !ok {
+		if p.TOFU && len(got) > 0 {
+			p.pins[server] = []string{got[0]}
+		}
+		return nil
+	}
+	for _, pin := range pins {
+		for _, g := range got {
+			if pin == g {
+				return nil
+			}
+		}
+	}
+	return &PinError{Server: server, Pins: append([]string(nil), pins...), Got: got}
+}
+
+// PinError is returned when the certificates of a server don't match its
+// pins.
+type PinError struct {
+	Server string   // The name of the server
+	Pins   []string // The pins of the server
+	Got    []string // The pins of the certificates the server presented
+}
+
+func (e *PinError) Error() string {
+	return "dns: " + e.Server + ": no certificate matches the pinned public keys"
+}
+
+// PinnedConfig returns a copy of config (which may be nil) that checks the
+// server's certificates against the pins of server (the name used in pins,
+// this defaults to config.ServerName) after the normal validation. Only
+// the certificates of the verified chains are checked, other certificates
+// the server sends prove nothing. When there is no match, the handshake
+// fails with a *PinError. For a server with a self-signed certificate, set
+// InsecureSkipVerify in config: only the pins of the server's own
+// certificate are checked then. Use it as the TLSConfig of a Client for DNS
+// over TLS, or in the http.Transport used for DNS over HTTPS:
+//
+//	pins := dns.NewSPKIPins(true)
+//	c := &dns.Client{Net: "tcp-tls", TLSConfig: dns.PinnedConfig(nil, "dns.example.net", pins)}
+func PinnedConfig(config *tls.Config, server string, pins *SPKIPins) *tls.Config {
+	if config == nil {
+		config = new(tls.Config)
+	}
+	config = config.Clone()
+	if server == "" {
+		server = config.ServerName
+	}
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		name := server
+		if name == "" {
+			name = cs.ServerName
+		}
+		var certs []*x509.Certificate
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+		if len(cs.VerifiedChains) == 0 && len(cs.PeerCertificates) > 0 {
+			// Not verified, the handshake only proves the key of the leaf
+			certs = cs.PeerCertificates[:1]
+		}
+		return pins.Verify(name, certs)
+	}
+	return config
+}
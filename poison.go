@@ -0,0 +1,311 @@
+// CACHE POISONING TESTS
+//
+// A PoisonSuite checks that a forwarder or recursor resists the classic cache
+// poisoning attacks. The suite plays the upstream name server of a test
+// domain, the resolver under test must send its queries for that domain to
+// the address of the suite. For each PoisonTest the upstream misbehaves in
+// one way when it gets the first query for a name, as an attacker would:
+// spoofed replies with the wrong id, question or source address racing the
+// real reply, a duplicate reply with other data, records for names the query
+// didn't ask for, or NS records that take over a domain above the queried
+// name. The resolver is then asked for the name again, and for a name the
+// attack tried to plant data for; its replies must have the real answer,
+// and none of the records of the attacker in any section. Use it in a
+// test, basic use pattern:
+//
+//	s, err := dns.NewPoisonSuite("poison.test.")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer s.Close()
+//	f := dns.NewForwarder(&dns.Upstream{Servers: []string{s.Addr()}, ValidateResponse: dns.CheckBailiwick})
+//	for _, r := range s.Run(f) {
+//		if r.Err != nil {
+//			t.Errorf("%s: %s", r.Test.Name, r.Err)
+//		}
+//	}
+//
+// A resolver may also answer SERVFAIL when it detects an attack, only answers
+// with poisoned data, or without the real data, are failures.
+package dns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The addresses in the A records of the upstream of a PoisonSuite: RealAddr
+// in the real replies, PoisonAddr in those of the attacker.
+var (
+	RealAddr   = net.IPv4(192, 0, 2, 1)
+	PoisonAddr = net.IPv4(198, 51, 100, 66)
+)
+
+// poisonNS is the name server of the attacker in NS records.
+const poisonNS = "ns.attacker.invalid."
+
+// A PoisonTest is an attack on a resolver, see PoisonSuite.
+type PoisonTest struct {
+	Name   string              // Used as a label in the names queried, so letters, digits and hyphens only
+	Attack func(*PoisonAttack) // Sends the replies to the first query for a name
+}
+
+// PoisonTests are the attacks done by PoisonSuite.Run.
+var PoisonTests = []*PoisonTest{
+	{"spoofed-id", func(a *PoisonAttack) {
+		m := a.Poison(a.Name())
+		m.Id++
+		a.Send(m)
+		a.Send(a.Reply())
+	}},
+	{"spoofed-question", func(a *PoisonAttack) {
+		m := a.Poison(a.Name())
+		m.Question[0].Name = a.Victim
+		a.Send(m)
+		a.Send(a.Reply())
+	}},
+	{"spoofed-source", func(a *PoisonAttack) {
+		a.Spoof(a.Poison(a.Name()))
+		a.Send(a.Reply())
+	}},
+	{"duplicate-reply", func(a *PoisonAttack) {
+		a.Send(a.Reply())
+		a.Send(a.Poison(a.Name()))
+	}},
+	{"out-of-bailiwick", func(a *PoisonAttack) {
+		m := a.Reply()
+		m.Answer = append(m.Answer, a.A(a.Victim, PoisonAddr))
+		m.Extra = append(m.Extra, a.A(a.Victim, PoisonAddr))
+		a.Send(m)
+	}},
+	{"ns-override", func(a *PoisonAttack) {
+		m := a.Reply()
+		for _, zone := range []string{a.Domain, "."} {
+			m.Ns = append(m.Ns, &NS{Hdr: RR_Header{Name: zone, Rrtype: TypeNS, Class: ClassINET, Ttl: 86400}, Ns: poisonNS})
+		}
+		m.Extra = append(m.Extra, a.A(poisonNS, PoisonAddr))
+		a.Send(m)
+	}},
+}
+
+// PoisonAttack is an attack in progress: the upstream of a PoisonSuite got
+// Query from the resolver.
+type PoisonAttack struct {
+	Query  *Msg
+	Domain string // The test domain
+	Victim string // A name the attack may plant data for, the resolver is asked for it afterwards
+	conn   *net.UDPConn
+	addr   *net.UDPAddr
+}
+
+// Name returns the name in the question of the query.
+func (a *PoisonAttack) Name() string { return queryName(a.Query) }
+
+// A returns an A record for name with the address ip.
+func (a *PoisonAttack) A(name string, ip net.IP) RR {
+	return &A{Hdr: RR_Header{Name: name, Rrtype: TypeA, Class: ClassINET, Ttl: 3600}, A: ip}
+}
+
+// Reply returns the real reply to the query.
+func (a *PoisonAttack) Reply() *Msg {
+	m := new(Msg)
+	m.SetReply(a.Query)
+	m.Authoritative = true
+	if len(m.Question) == 1 && m.Question[0].Qtype == TypeA {
+		m.Answer = append(m.Answer, a.A(a.Name(), RealAddr))
+	}
+	return m
+}
+
+// Poison returns the real reply to the query, with PoisonAddr in the A
+// record for name.
+func (a *PoisonAttack) Poison(name string) *Msg {
+	m := a.Reply()
+	m.Answer = []RR{a.A(name, PoisonAddr)}
+	return m
+}
+
+// Send sends m to the resolver, from the address of the upstream.
+func (a *PoisonAttack) Send(m *Msg) error {
+	return a.send(a.conn, m)
+}
+
+// Spoof sends m to the resolver, from another address.
+func (a *PoisonAttack) Spoof(m *Msg) error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: a.conn.LocalAddr().(*net.UDPAddr).IP})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return a.send(conn, m)
+}
+
+func (a *PoisonAttack) send(conn *net.UDPConn, m *Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteToUDP(buf, a.addr)
+	return err
+}
+
+// PoisonResult is the result of a PoisonTest.
+type PoisonResult struct {
+	Test *PoisonTest
+	Err  error // Nil when the resolver wasn't poisoned
+}
+
+// PoisonSuite is the upstream name server for the test domain that attacks
+// the resolver, see PoisonTests.
+type PoisonSuite struct {
+	Domain  string        // The test domain
+	Timeout time.Duration // How long to wait for a reply of the resolver, defaults to 2 seconds
+	conn    *net.UDPConn
+	attacks map[string]*PoisonTest // keyed on the lower cased names to attack
+	seq     int
+	mu      sync.Mutex
+}
+
+// NewPoisonSuite starts the upstream for domain on a random port of
+// 127.0.0.1.
+func NewPoisonSuite(domain string) (*PoisonSuite, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	s := &PoisonSuite{Domain: Fqdn(strings.ToLower(domain)), conn: conn, attacks: make(map[string]*PoisonTest)}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address of the upstream, host:port.
+func (s *PoisonSuite) Addr() string { return s.conn.LocalAddr().String() }
+
+// Close stops the upstream.
+func (s *PoisonSuite) Close() error { return s.conn.Close() }
+
+// Run does the tests, or PoisonTests when none are given, against the
+// resolver h and returns the results in the same order.
+func (s *PoisonSuite) Run(h Handler, tests ...*PoisonTest) []PoisonResult {
+	if len(tests) == 0 {
+		tests = PoisonTests
+	}
+	results := make([]PoisonResult, 0, len(tests))
+	for _, t := range tests {
+		results = append(results, PoisonResult{t, s.run(h, t)})
+	}
+	return results
+}
+
+// run does the test t: the name is asked for twice, then the victim.
+func (s *PoisonSuite) run(h Handler, t *PoisonTest) error {
+	s.mu.Lock()
+	s.seq++
+	name := "q" + strconv.Itoa(s.seq) + "." + strings.ToLower(t.Name) + "." + s.Domain
+	victim := "v" + name[1:]
+	s.attacks[name] = t
+	s.mu.Unlock()
+	for _, n := range []string{name, name, victim} {
+		q := new(Msg)
+		q.SetQuestion(n, TypeA)
+		if err := s.check(q, s.ask(h, q)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ask returns the reply of h to q, or nil.
+func (s *PoisonSuite) ask(h Handler, q *Msg) *Msg {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	done := make(chan *Msg, 1)
+	go func() {
+		w := &vectorWriter{info: RequestInfo{Received: time.Now(), Net: "udp"}}
+		h.ServeDNS(w, q)
+		done <- w.msg
+	}()
+	select {
+	case m := <-done:
+		return m
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+// check checks the reply r of the resolver to q: no section may have an A
+// record with PoisonAddr, or an NS record of the test domain or a domain
+// above it, the upstream never sends those.
+func (s *PoisonSuite) check(q, r *Msg) error {
+	name := queryName(q)
+	switch {
+	case r == nil:
+		return &Error{Err: "no reply", Name: name}
+	case r.Rcode == RcodeServerFailure:
+		return nil
+	case r.Rcode != RcodeSuccess:
+		return &Error{Err: "unexpected rcode " + RcodeToString[r.Rcode], Name: name, Rcode: r.Rcode}
+	}
+	for _, section := range [][]RR{r.Answer, r.Ns, r.Extra} {
+		for _, rr := range section {
+			switch x := rr.(type) {
+			case *A:
+				if x.A.Equal(PoisonAddr) {
+					return &Error{Err: "poisoned record " + x.String(), Name: name}
+				}
+			case *NS:
+				if strings.EqualFold(x.Ns, poisonNS) || IsSubDomain(x.Hdr.Name, s.Domain) {
+					return &Error{Err: "poisoned record " + x.String(), Name: name}
+				}
+			}
+		}
+	}
+	found := false
+	for _, rr := range r.Answer {
+		if a, ok := rr.(*A); ok && a.A.Equal(RealAddr) && strings.EqualFold(a.Hdr.Name, name) {
+			found = true
+		}
+	}
+	if !found {
+		return &Error{Err: "real answer missing", Name: name}
+	}
+	return nil
+}
+
+// serve answers the queries of the resolver, the first query for a name to
+// attack gets the attack.
+func (s *PoisonSuite) serve() {
+	buf := make([]byte, MaxMsgSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		q := new(Msg)
+		if q.Unpack(buf[:n]) != nil || len(q.Question) != 1 {
+			continue
+		}
+		name := strings.ToLower(q.Question[0].Name)
+		a := &PoisonAttack{Query: q, Domain: s.Domain, conn: s.conn, addr: addr}
+		s.mu.Lock()
+		t := s.attacks[name]
+		delete(s.attacks, name)
+		s.mu.Unlock()
+		switch {
+		case t != nil:
+			a.Victim = "v" + name[1:]
+			t.Attack(a)
+		case IsSubDomain(s.Domain, name):
+			a.Send(a.Reply())
+		default:
+			m := new(Msg)
+			m.SetRcode(q, RcodeRefused)
+			a.Send(m)
+		}
+	}
+}
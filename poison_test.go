@@ -0,0 +1,72 @@
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPoisonSuite(t *testing.T) {
+	s, err := NewPoisonSuite("poison.test.")
+	if err != nil {
+		t.Fatalf("failed to start the upstream: %s", err.Error())
+	}
+	defer s.Close()
+
+	f := NewForwarder(&Upstream{Servers: []string{s.Addr()}, ValidateResponse: CheckBailiwick})
+	for _, r := range s.Run(f) {
+		if r.Err != nil {
+			t.Errorf("%s: %s", r.Test.Name, r.Err)
+		}
+	}
+
+	// Without the bailiwick check the records for the victim and the NS
+	// records of the attacker are passed on
+	f = NewForwarder(&Upstream{Servers: []string{s.Addr()}})
+	for _, r := range s.Run(f) {
+		if (r.Err != nil) != (r.Test.Name == "out-of-bailiwick" || r.Test.Name == "ns-override") {
+			t.Errorf("%s: unexpected result %v", r.Test.Name, r.Err)
+		}
+	}
+
+	// Records of the attacker are found in all sections
+	q := new(Msg)
+	q.SetQuestion("www."+s.Domain, TypeA)
+	for _, rr := range []RR{&A{Hdr: RR_Header{"ns.example.org.", TypeA, ClassINET, 3600, 0}, A: PoisonAddr},
+		&NS{Hdr: RR_Header{"test.", TypeNS, ClassINET, 3600, 0}, Ns: "ns.example.org."},
+		&NS{Hdr: RR_Header{"www." + s.Domain, TypeNS, ClassINET, 3600, 0}, Ns: poisonNS}} {
+		r := new(Msg)
+		r.SetReply(q)
+		r.Answer = []RR{&A{Hdr: RR_Header{"www." + s.Domain, TypeA, ClassINET, 3600, 0}, A: RealAddr}}
+		r.Ns, r.Extra = []RR{rr}, []RR{rr}
+		if err := s.check(q, r); err == nil {
+			t.Errorf("expected an error for %s", rr)
+		}
+	}
+
+	// A resolver that takes the first packet it gets falls for spoofs
+	naive := HandlerFunc(func(w ResponseWriter, req *Msg) {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf, _ := req.Pack()
+		addr, _ := net.ResolveUDPAddr("udp", s.Addr())
+		conn.WriteToUDP(buf, addr)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFromUDP(buf[:cap(buf)])
+		if err != nil {
+			return
+		}
+		m := new(Msg)
+		if m.Unpack(buf[:n]) == nil {
+			w.WriteMsg(m)
+		}
+	})
+	for _, r := range s.Run(naive, PoisonTests[:3]...) {
+		if r.Err == nil {
+			t.Errorf("%s: expected the naive resolver to be poisoned", r.Test.Name)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package dns
+
+// Dialing through a SOCKS5 or HTTP CONNECT proxy.
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// A DialContextFunc sets up a connection to addr, like net.Dialer.DialContext.
+// It is used by the Client for TCP connections, see Client.DialContext.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SOCKS5Proxy returns a DialContextFunc that connects through the SOCKS5
+// proxy (RFC 1928) on proxy. If user is not empty, username/password
+// authentication (RFC 1929) is used. Only TCP can be proxied; the name in
+// addr is resolved by the proxy. Basic use pattern:
+//
+//	c := &dns.Client{Net: "tcp", DialContext: dns.SOCKS5Proxy("127.0.0.1:1080", "", "")}
+//	in, rtt, err := c.Exchange(m, "192.0.2.1:53")
+func SOCKS5Proxy(proxy, user, password string) DialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialProxy(ctx, network, proxy)
+		if err != nil {
+			return nil, err
+		}
+		if err := socks5Connect(conn, addr, user, password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return conn, nil
+	}
+}
+
+// HTTPProxy returns a DialContextFunc that connects through the HTTP proxy on
+// proxy with the CONNECT method. If user is not empty, basic authentication
+// is used.
+func HTTPProxy(proxy, user, password string) DialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialProxy(ctx, network, proxy)
+		if err != nil {
+			return nil, err
+		}
+		if err := httpConnect(conn, addr, user, password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return conn, nil
+	}
+}
+
+// dialProxy connects to the proxy, the deadline of ctx is set on the
+// connection, so that it also applies to the handshake with the proxy.
+func dialProxy(ctx context.Context, network, proxy string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, &Error{Err: "proxy only supports tcp", Name: network}
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, proxy)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(t)
+	}
+	return conn, nil
+}
+
+func socks5Connect(conn net.Conn, addr, user, password string) error {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || port < 0 || port > 0xFFFF {
+		return &Error{Err: "bad port", Name: addr}
+	}
+	method := byte(0x00) // no authentication
+	if user != "" {
+		method = 0x02 // username/password
+	}
+	if _, err := conn.Write([]byte{5, 1, method}); err != nil {
+		return err
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != 5 || buf[1] != method {
+		return &Error{Err: "socks5 authentication method not accepted"}
+	}
+	if method == 0x02 {
+		if len(user) > 255 || len(password) > 255 {
+			return &Error{Err: "socks5 username or password too long"}
+		}
+		req := append([]byte{1, byte(len(user))}, user...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return err
+		}
+		if buf[1] != 0 {
+			return &Error{Err: "socks5 authentication failed"}
+		}
+	}
+
+	req := []byte{5, 1, 0} // CONNECT
+	if ip, _ := parseIPZone(host); ip == nil {
+		if len(host) > 255 {
+			return &Error{Err: "socks5 host name too long", Name: host}
+		}
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 1)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 4)
+		req = append(req, ip...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	// Version, reply, reserved and the type of the bound address
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != 5 {
+		return &Error{Err: "socks5 bad version in reply"}
+	}
+	if buf[1] != 0 {
+		return &Error{Err: "socks5 connect failed with code " + strconv.Itoa(int(buf[1])), Name: addr}
+	}
+	var n int
+	switch buf[3] {
+	case 1:
+		n = net.IPv4len
+	case 4:
+		n = net.IPv6len
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		n = int(l[0])
+	default:
+		return &Error{Err: "socks5 bad address type in reply"}
+	}
+	// Skip the bound address and port
+	_, err = io.ReadFull(conn, make([]byte, n+2))
+	return err
+}
+
+func httpConnect(conn net.Conn, addr, user, password string) error {
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if user != "" {
+		req += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password)) + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return err
+	}
+	// Read the response byte by byte, so nothing after it is consumed
+	br := bufio.NewReaderSize(oneByteReader{conn}, 16)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &Error{Err: "http proxy: " + resp.Status, Name: addr}
+	}
+	return nil
+}
+
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return o.r.Read(p)
+}
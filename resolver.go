@@ -0,0 +1,457 @@
+// STUB RESOLVER
+//
+// A LocalResolver is a stub resolver that can replace the resolver from
+// the C library. It has the same Lookup methods as package net. A lookup
+// first consults the hosts file, which is re-read when it changes, then
+// the cache and then the upstream name servers.
+//
+// When NAT64Prefix is set, AAAA records are synthesized from A records
+// for names that have no AAAA records (DNS64, RFC 6147).
+//
+// Basic use pattern:
+//
+//	r, err := dns.NewLocalResolver()
+//	if err != nil {
+//		// no /etc/resolv.conf
+//	}
+//	ips, err := r.LookupIP("www.example.org")
+package dns
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the hosts file is checked for changes.
+const hostsCheckInterval = 5 * time.Second
+
+// The maximum TTL for negative answers in the cache.
+const maxNegativeTtl = 300
+
+// The default maximum number of answers in the cache.
+const defaultCacheSize = 10000
+
+// LocalResolver is a stub resolver. It's safe for concurrent use by multiple
+// goroutines.
+type LocalResolver struct {
+	HostsFile   string     // The hosts file, defaults to /etc/hosts, set to "-" to not use a hosts file
+	Upstream    *Upstream  // The upstream name servers
+	Search      []string   // Suffixes to append to names with less than Ndots dots
+	Ndots       int        // Number of dots in a name to trigger an absolute lookup first
+	NAT64Prefix *net.IPNet // If not nil, the /96 prefix used to synthesize AAAA records
+	CacheSize   int        // Maximum number of answers in the cache, defaults to 10000
+	hosts       map[string][]net.IP
+	addrs       map[string][]string // reverse of hosts, keyed on the address
+	hostsMod    time.Time           // modification time of the hosts file
+	hostsCheck  time.Time           // when the hosts file was last checked
+	cache       map[cacheKey]*cacheEntry
+	*sync.RWMutex
+}
+
+type cacheKey struct {
+	name  string
+	qtype uint16
+}
+
+type cacheEntry struct {
+	rrs    []RR
+	rcode  int
+	expire time.Time
+}
+
+// NewLocalResolver returns a LocalResolver that uses the name servers
+// and search list from /etc/resolv.conf.
+func NewLocalResolver() (*LocalResolver, error) {
+	conf, err := ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	u := &Upstream{Timeout: time.Duration(conf.Timeout) * time.Second}
+	for _, s := range conf.Servers {
+		u.Servers = append(u.Servers, net.JoinHostPort(s, conf.Port))
+	}
+	r := NewLocalResolverUpstream(u)
+	r.Search = conf.Search
+	r.Ndots = conf.Ndots
+	return r, nil
+}
+
+// NewLocalResolverUpstream returns a LocalResolver that uses the name
+// servers in u.
+func NewLocalResolverUpstream(u *Upstream) *LocalResolver {
+	r := new(LocalResolver)
+	r.HostsFile = "/etc/hosts"
+	r.Upstream = u
+	r.Ndots = 1
+	r.cache = make(map[cacheKey]*cacheEntry)
+	r.RWMutex = new(sync.RWMutex)
+	return r
+}
+
+// readHosts (re)reads the hosts file when it has been modified.
+func (r *LocalResolver) readHosts() {
+	if r.HostsFile == "-" {
+		return
+	}
+	r.RLock()
+	check := time.Since(r.hostsCheck) > hostsCheckInterval
+	r.RUnlock()
+	if !check {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.hostsCheck = time.Now()
+	fi, err := os.Stat(r.HostsFile)
+	if err != nil || !fi.ModTime().After(r.hostsMod) {
+		return
+	}
+	f, err := os.Open(r.HostsFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	r.hostsMod = fi.ModTime()
+	r.hosts = make(map[string][]net.IP)
+	r.addrs = make(map[string][]string)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := s.Text()
+		if i := strings.Index(l, "#"); i >= 0 {
+			l = l[:i]
+		}
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			continue
+		}
+		ip, _ := parseIPZone(fields[0]) // the zone of a link-local address is dropped
+		if ip == nil {
+			continue
+		}
+		for _, h := range fields[1:] {
+			h = Fqdn(strings.ToLower(h))
+			r.hosts[h] = append(r.hosts[h], ip)
+			r.addrs[ip.String()] = append(r.addrs[ip.String()], h)
+		}
+	}
+}
+
+// names returns the names to try for name, using the search list.
+func (r *LocalResolver) names(name string) []string {
+	if IsFqdn(name) {
+		return []string{name}
+	}
+	var names []string
+	absolute := strings.Count(name, ".") >= r.Ndots
+	if absolute {
+		names = append(names, Fqdn(name))
+	}
+	for _, s := range r.Search {
+		names = append(names, Fqdn(name+"."+strings.TrimSuffix(s, ".")))
+	}
+	if !absolute {
+		names = append(names, Fqdn(name))
+	}
+	return names
+}
+
+// Lookup returns the records of type qtype for name, from the cache or the
+// upstream name servers. The hosts file is not consulted. When the name
+// does not exist an *Error with Err set to "no such host" is returned.
+func (r *LocalResolver) Lookup(name string, qtype uint16) ([]RR, error) {
+	var (
+		rrs []RR
+		err error
+	)
+	for _, n := range r.names(name) {
+		rrs, err = r.lookup(n, qtype)
+		if err == nil {
+			return rrs, nil
+		}
+		if e, ok := err.(*Error); ok && e.Err == "no records" {
+			// The name exists, the rest of the search list isn't tried
+			break
+		}
+	}
+	return nil, err
+}
+
+func (r *LocalResolver) lookup(name string, qtype uint16) ([]RR, error) {
+	key := cacheKey{strings.ToLower(name), qtype}
+	r.RLock()
+	e, ok := r.cache[key]
+	r.RUnlock()
+	if !ok || time.Now().After(e.expire) {
+		m := new(Msg)
+		m.SetQuestion(name, qtype)
+		m.RecursionDesired = true
+		in, err := r.Upstream.Exchange(m)
+		if err != nil {
+			return nil, err
+		}
+		e = newCacheEntry(in, name, qtype)
+		r.Lock()
+		r.evict()
+		r.cache[key] = e
+		r.Unlock()
+	}
+	switch e.rcode {
+	case RcodeSuccess:
+		if len(e.rrs) == 0 {
+			return nil, &Error{Err: "no records", Name: name}
+		}
+		return e.rrs, nil
+	case RcodeNameError:
+		return nil, &Error{Err: "no such host", Name: name, Rcode: RcodeNameError, kind: ErrRcode}
+	}
+	return nil, &Error{Err: "server failure: " + RcodeToString[e.rcode], Name: name, Rcode: e.rcode, kind: ErrRcode}
+}
+
+// evict makes room for a new answer when the cache is full: the expired
+// answers are dropped, and if that's not enough a random one. The caller
+// must hold the write lock.
+func (r *LocalResolver) evict() {
+	size := r.CacheSize
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	if len(r.cache) < size {
+		return
+	}
+	now := time.Now()
+	for k, e := range r.cache {
+		if now.After(e.expire) {
+			delete(r.cache, k)
+		}
+	}
+	for k := range r.cache {
+		if len(r.cache) < size {
+			return
+		}
+		delete(r.cache, k)
+	}
+}
+
+// newCacheEntry creates a cache entry from the reply in. The records
+// of type qtype are followed through any CNAMEs, the entry expires with the
+// record with the lowest TTL.
+func newCacheEntry(in *Msg, name string, qtype uint16) *cacheEntry {
+	e := &cacheEntry{rcode: in.Rcode}
+	ttl := ^uint32(0)
+	target := name
+	for i := 0; i <= len(in.Answer); i++ { // CNAME chains can't be longer
+		found := false
+		for _, rr := range in.Answer {
+			h := rr.Header()
+			if !strings.EqualFold(h.Name, target) {
+				continue
+			}
+			if h.Rrtype == qtype {
+				e.rrs = append(e.rrs, rr)
+			} else if c, ok := rr.(*CNAME); ok && qtype != TypeCNAME {
+				target = c.Target
+				found = true
+			} else {
+				continue
+			}
+			if h.Ttl < ttl {
+				ttl = h.Ttl
+			}
+		}
+		if !found || len(e.rrs) > 0 {
+			break
+		}
+	}
+	if len(e.rrs) == 0 {
+		// Negative answer, use the SOA minimum
+		if ttl > maxNegativeTtl {
+			ttl = maxNegativeTtl
+		}
+		for _, rr := range in.Ns {
+			if soa, ok := rr.(*SOA); ok && soa.Minttl < ttl {
+				ttl = soa.Minttl
+			}
+		}
+	}
+	if opt := in.IsEdns0(); opt != nil {
+		// The answer is only valid for the client subnet it was
+		// given for, the cache is not keyed on the subnet
+		if ecs, ok := opt.GetOption(EDNS0SUBNET).(*EDNS0_SUBNET); ok && ecs.SourceScope > 0 {
+			ttl = 0
+		}
+	}
+	e.expire = time.Now().Add(time.Duration(ttl) * time.Second)
+	return e
+}
+
+// LookupIP looks up host in the hosts file and using the upstream name
+// servers. It returns an array of that host's IPv4 and IPv6 addresses.
+// The A and AAAA records are looked up at the same time, when one of
+// the lookups fails (e.g. with SERVFAIL) the addresses of the other are
+// returned. The addresses from the name servers are sorted in the order
+// they should be tried, as described in RFC 6724.
+func (r *LocalResolver) LookupIP(host string) ([]net.IP, error) {
+	r.readHosts()
+	r.RLock()
+	for _, n := range r.names(host) {
+		if ips, ok := r.hosts[strings.ToLower(n)]; ok {
+			r.RUnlock()
+			return ips, nil
+		}
+	}
+	r.RUnlock()
+	var (
+		ips  []net.IP
+		aaaa []RR
+		err6 error
+		done = make(chan bool)
+	)
+	go func() {
+		aaaa, err6 = r.Lookup(host, TypeAAAA)
+		done <- true
+	}()
+	a, err4 := r.Lookup(host, TypeA)
+	<-done
+	for _, rr := range a {
+		ips = append(ips, rr.(*A).A)
+	}
+	for _, rr := range aaaa {
+		ips = append(ips, rr.(*AAAA).AAAA)
+	}
+	if len(aaaa) == 0 && r.NAT64Prefix != nil {
+		for _, rr := range a {
+			ips = append(ips, nat64(r.NAT64Prefix, rr.(*A).A))
+		}
+	}
+	if len(ips) == 0 {
+		if err4 != nil {
+			return nil, err4
+		}
+		return nil, err6
+	}
+	sortAddrs(ips)
+	return ips, nil
+}
+
+// nat64 embeds the IPv4 address ip in the /96 prefix.
+func nat64(prefix *net.IPNet, ip net.IP) net.IP {
+	x := make(net.IP, net.IPv6len)
+	copy(x, prefix.IP.To16())
+	copy(x[12:], ip.To4())
+	return x
+}
+
+// LookupHost looks up the given host. It returns an array of that
+// host's addresses.
+func (r *LocalResolver) LookupHost(host string) ([]string, error) {
+	ips, err := r.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+	return addrs, nil
+}
+
+// LookupAddr performs a reverse lookup for the given address, returning a
+// list of names mapping to that address.
+func (r *LocalResolver) LookupAddr(addr string) ([]string, error) {
+	r.readHosts()
+	if ip, _ := parseIPZone(addr); ip != nil {
+		r.RLock()
+		names, ok := r.addrs[ip.String()]
+		r.RUnlock()
+		if ok {
+			return names, nil
+		}
+	}
+	arpa, err := ReverseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	rrs, err := r.Lookup(arpa, TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(rrs))
+	for i, rr := range rrs {
+		names[i] = rr.(*PTR).Ptr
+	}
+	return names, nil
+}
+
+// LookupCNAME returns the canonical name for the given host.
+func (r *LocalResolver) LookupCNAME(host string) (string, error) {
+	rrs, err := r.Lookup(host, TypeCNAME)
+	if err != nil {
+		if e, ok := err.(*Error); ok && e.Err == "no records" {
+			return Fqdn(host), nil
+		}
+		return "", err
+	}
+	return rrs[0].(*CNAME).Target, nil
+}
+
+// LookupMX returns the DNS MX records for the given domain name.
+func (r *LocalResolver) LookupMX(name string) ([]*net.MX, error) {
+	rrs, err := r.Lookup(name, TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	mx := make([]*net.MX, len(rrs))
+	for i, rr := range rrs {
+		mx[i] = &net.MX{Host: rr.(*MX).Mx, Pref: rr.(*MX).Preference}
+	}
+	return mx, nil
+}
+
+// LookupNS returns the DNS NS records for the given domain name.
+func (r *LocalResolver) LookupNS(name string) ([]*net.NS, error) {
+	rrs, err := r.Lookup(name, TypeNS)
+	if err != nil {
+		return nil, err
+	}
+	ns := make([]*net.NS, len(rrs))
+	for i, rr := range rrs {
+		ns[i] = &net.NS{Host: rr.(*NS).Ns}
+	}
+	return ns, nil
+}
+
+// LookupTXT returns the DNS TXT records for the given domain name.
+func (r *LocalResolver) LookupTXT(name string) ([]string, error) {
+	rrs, err := r.Lookup(name, TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txt []string
+	for _, rr := range rrs {
+		txt = append(txt, strings.Join(rr.(*TXT).Txt, ""))
+	}
+	return txt, nil
+}
+
+// LookupSRV tries to resolve an SRV query of the given service, protocol,
+// and domain name, as in package net.
+func (r *LocalResolver) LookupSRV(service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+	rrs, err := r.Lookup(target, TypeSRV)
+	if err != nil {
+		return "", nil, err
+	}
+	srv := make([]*net.SRV, len(rrs))
+	for i, rr := range rrs {
+		s := rr.(*SRV)
+		srv[i] = &net.SRV{Target: s.Target, Port: s.Port, Priority: s.Priority, Weight: s.Weight}
+	}
+	return rrs[0].Header().Name, srv, nil
+}
@@ -0,0 +1,149 @@
+package dns
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLookupIPServfail(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go (&Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetReply(req)
+		if req.Question[0].Qtype == TypeA {
+			m.Rcode = RcodeServerFailure
+		} else {
+			rr, _ := NewRR(req.Question[0].Name + " AAAA 2001:db8::1")
+			m.Answer = []RR{rr}
+		}
+		w.WriteMsg(m)
+	})}).serveUDP(l)
+
+	r := NewLocalResolverUpstream(&Upstream{Servers: []string{l.LocalAddr().String()}})
+	r.HostsFile = "-"
+	ips, err := r.LookupIP("www.example.org.")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("expected the IPv6 address, got %v, %v", ips, err)
+	}
+}
+
+func TestSortAddrs(t *testing.T) {
+	ip := func(s string) net.IP { return net.ParseIP(s) }
+	s := &byRFC6724{
+		addrs: []net.IP{ip("2001:db8::1"), ip("198.51.100.1"), ip("fe80::1"), ip("2001:db8:1::1"), ip("::1")},
+		srcs:  []net.IP{nil, ip("192.0.2.10"), ip("2001:db8::10"), ip("2001:db8:1::10"), ip("::1")},
+	}
+	sort.Stable(s)
+	want := []string{"::1", "2001:db8:1::1", "198.51.100.1", "fe80::1", "2001:db8::1"}
+	for i, w := range want {
+		if !s.addrs[i].Equal(ip(w)) {
+			t.Errorf("expected %s at position %d, got %s", w, i, s.addrs[i])
+		}
+	}
+}
+
+func TestLookupSearch(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	asked := make(chan string, 10)
+	go (&Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) {
+		asked <- req.Question[0].Name
+		m := new(Msg)
+		m.SetReply(req)
+		switch req.Question[0].Name {
+		case "www.example.org.":
+			m.Rcode = RcodeNameError
+		case "www.example.net.":
+			// NODATA
+		default:
+			rr, _ := NewRR(req.Question[0].Name + " A 192.0.2.1")
+			m.Answer = []RR{rr}
+		}
+		w.WriteMsg(m)
+	})}).serveUDP(l)
+
+	r := NewLocalResolverUpstream(&Upstream{Servers: []string{l.LocalAddr().String()}})
+	r.HostsFile = "-"
+	r.Search = []string{"example.org", "example.net", "example.com"}
+	r.Ndots = 2
+	_, err = r.Lookup("www", TypeA)
+	if e, ok := err.(*Error); !ok || e.Err != "no records" {
+		t.Errorf("expected no records, got %v", err)
+	}
+	close(asked)
+	var names []string
+	for n := range asked {
+		names = append(names, n)
+	}
+	if len(names) != 2 || names[0] != "www.example.org." || names[1] != "www.example.net." {
+		t.Errorf("expected the search list to stop at the NODATA answer, asked %v", names)
+	}
+}
+
+func TestNewCacheEntry(t *testing.T) {
+	tests := []struct {
+		answer, ns []string
+		rcode      int
+		ttl        time.Duration
+	}{
+		{[]string{"www.example.org. 3600 A 192.0.2.1"}, nil, RcodeSuccess, 3600 * time.Second},
+		{[]string{"www.example.org. 3600 A 192.0.2.1", "www.example.org. 60 A 192.0.2.2"}, nil, RcodeSuccess, 60 * time.Second},
+		{[]string{"www.example.org. 60 CNAME web.example.org.", "web.example.org. 3600 A 192.0.2.1"}, nil, RcodeSuccess, 60 * time.Second},
+		{nil, []string{"example.org. 3600 SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 3600"}, RcodeNameError, maxNegativeTtl * time.Second},
+		{nil, []string{"example.org. 3600 SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 30"}, RcodeNameError, 30 * time.Second},
+		{[]string{"www.example.org. 10 CNAME web.example.org."}, []string{"example.org. 3600 SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 30"}, RcodeNameError, 10 * time.Second},
+	}
+	for i, tt := range tests {
+		in := new(Msg)
+		in.SetQuestion("www.example.org.", TypeA)
+		in.Rcode = tt.rcode
+		for _, s := range tt.answer {
+			rr, _ := NewRR(s)
+			in.Answer = append(in.Answer, rr)
+		}
+		for _, s := range tt.ns {
+			rr, _ := NewRR(s)
+			in.Ns = append(in.Ns, rr)
+		}
+		e := newCacheEntry(in, "www.example.org.", TypeA)
+		if ttl := time.Until(e.expire); ttl > tt.ttl || ttl < tt.ttl-time.Second {
+			t.Errorf("%d: expected the entry to expire in %s, got %s", i, tt.ttl, ttl)
+		}
+	}
+}
+
+func TestLookupCacheSize(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	go (&Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetReply(req)
+		rr, _ := NewRR(req.Question[0].Name + " A 192.0.2.1")
+		m.Answer = []RR{rr}
+		w.WriteMsg(m)
+	})}).serveUDP(l)
+
+	r := NewLocalResolverUpstream(&Upstream{Servers: []string{l.LocalAddr().String()}})
+	r.HostsFile = "-"
+	r.CacheSize = 2
+	for _, n := range []string{"a.example.org.", "b.example.org.", "c.example.org."} {
+		if _, err := r.Lookup(n, TypeA); err != nil {
+			t.Fatalf("failed to look up %s: %s", n, err.Error())
+		}
+	}
+	if len(r.cache) != 2 {
+		t.Errorf("expected 2 answers in the cache, got %d", len(r.cache))
+	}
+}
@@ -0,0 +1,126 @@
+package dns
+
+// Checks of the replies a Client gets, see Client.ValidateResponse.
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A ResponseCheck checks the reply r to the query q. It returns an error
+// when r is not acceptable.
+type ResponseCheck func(q, r *Msg) error
+
+// ResponseChecks returns a ResponseCheck that does the checks in order,
+// the first error is returned.
+func ResponseChecks(checks ...ResponseCheck) ResponseCheck {
+	return func(q, r *Msg) error {
+		for _, check := range checks {
+			if err := check(q, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// RejectRcodes returns a ResponseCheck that rejects replies with one of the
+// rcodes, with an error matching ErrRcode. Rejecting RcodeServerFailure and
+// RcodeRefused makes Upstream try the next server for such replies.
+func RejectRcodes(rcodes ...int) ResponseCheck {
+	return func(q, r *Msg) error {
+		for _, rcode := range rcodes {
+			if r.Rcode == rcode {
+				return &Error{Err: "rcode " + RcodeToString[r.Rcode], Name: queryName(q), Rcode: r.Rcode, kind: ErrRcode}
+			}
+		}
+		return nil
+	}
+}
+
+// MinAnswers returns a ResponseCheck that rejects successful replies with
+// less than n RRs of the query type in the answer section.
+func MinAnswers(n int) ResponseCheck {
+	return func(q, r *Msg) error {
+		if r.Rcode != RcodeSuccess || len(q.Question) != 1 {
+			return nil
+		}
+		i := 0
+		for _, rr := range r.Answer {
+			if rr.Header().Rrtype == q.Question[0].Qtype {
+				i++
+			}
+		}
+		if i < n {
+			return &Error{Err: "expected at least " + strconv.Itoa(n) + " answers, got " + strconv.Itoa(i), Name: queryName(q)}
+		}
+		return nil
+	}
+}
+
+// CheckBailiwick is a ResponseCheck that rejects replies with records that
+// have nothing to do with the query, as a cache poisoning attempt would
+// have: the question must be the same, the answer section may only hold
+// records for the query name and the names its CNAME records lead to and
+// DNAME records above them, and the NS and SOA records in the authority
+// section must be for one of those names or a domain above it. All NS
+// records must be for the same domain, a reply has the NS RRset of one zone
+// at most.
+func CheckBailiwick(q, r *Msg) error {
+	if len(q.Question) != 1 {
+		return nil
+	}
+	qname := q.Question[0].Name
+	if len(r.Question) != 1 || !strings.EqualFold(r.Question[0].Name, qname) || r.Question[0].Qtype != q.Question[0].Qtype {
+		return &Error{Err: "question of the reply differs", Name: qname}
+	}
+	names := map[string]bool{strings.ToLower(qname): true}
+	for _, rr := range r.Answer {
+		h := rr.Header()
+		if h.Rrtype == TypeDNAME {
+			if !below(names, h.Name) {
+				return &Error{Err: "out of bailiwick DNAME in the answer", Name: h.Name}
+			}
+			continue
+		}
+		if !names[strings.ToLower(h.Name)] {
+			return &Error{Err: "out of bailiwick record in the answer", Name: h.Name}
+		}
+		if c, ok := rr.(*CNAME); ok {
+			names[strings.ToLower(c.Target)] = true
+		}
+	}
+	zone := ""
+	for _, rr := range r.Ns {
+		h := rr.Header()
+		if (h.Rrtype == TypeNS || h.Rrtype == TypeSOA) && !below(names, h.Name) {
+			return &Error{Err: "out of bailiwick record in the authority section", Name: h.Name}
+		}
+		if h.Rrtype != TypeNS {
+			continue
+		}
+		if zone != "" && !strings.EqualFold(zone, h.Name) {
+			return &Error{Err: "NS records of more than one domain in the authority section", Name: h.Name}
+		}
+		zone = h.Name
+	}
+	return nil
+}
+
+// below returns true when one of the names is below or at domain.
+func below(names map[string]bool, domain string) bool {
+	for name := range names {
+		if IsSubDomain(domain, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryName returns the name in the question of m, or "".
+func queryName(m *Msg) string {
+	if len(m.Question) == 0 {
+		return ""
+	}
+	return m.Question[0].Name
+}
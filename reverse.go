@@ -0,0 +1,96 @@
+package dns
+
+// Generating reverse zones from forward zones.
+
+import (
+	"strings"
+)
+
+// What UpdateReverse does when a reverse name already has PTR records.
+const (
+	PTRKeep    = iota // Keep the existing PTR records, don't add one
+	PTRReplace        // Replace the existing PTR records
+	PTRAdd            // Add the PTR record next to the existing ones
+)
+
+// UpdateReverse adds the PTR records for the A and AAAA records in the
+// forward zone to the reverse zones, each address goes to the reverse
+// (in-addr.arpa. or ip6.arpa.) zone it falls in. Addresses for which there is
+// no reverse zone and wildcard names are skipped. The PTR records get the TTL
+// of the address record. When a reverse name already has PTR records, also
+// when more names in forward share an address, policy decides what happens:
+// PTRKeep, PTRReplace or PTRAdd. A PTR record that already exists is never
+// added twice. The added PTR records are returned. Basic use pattern, to
+// create the reverse zone of 192.0.2.0/24:
+//
+//	rev := dns.NewZone("2.0.192.in-addr.arpa.")
+//	rev.Insert(soa)
+//	rev.Insert(ns)
+//	ptrs, err := dns.UpdateReverse(z, []*dns.Zone{rev}, dns.PTRKeep)
+func UpdateReverse(forward *Zone, reverse []*Zone, policy int) ([]RR, error) {
+	var addrs []RR
+	forward.Walk(func(rrs []RR, _ []*RRSIG, _ *Meta) bool {
+		switch rrs[0].Header().Rrtype {
+		case TypeA, TypeAAAA:
+			if !strings.HasPrefix(rrs[0].Header().Name, "*.") {
+				addrs = append(addrs, rrs...)
+			}
+		}
+		return true
+	})
+	var added []RR
+	set := make(map[string]bool) // reverse names that got a PTR in this run
+	for _, a := range addrs {
+		var addr string
+		switch a := a.(type) {
+		case *A:
+			addr = a.A.String()
+		case *AAAA:
+			addr = a.AAAA.String()
+		}
+		name, err := ReverseAddr(addr)
+		if err != nil {
+			return added, err
+		}
+		var rz *Zone
+		for _, z := range reverse {
+			if z.isSubDomain(name) {
+				rz = z
+				break
+			}
+		}
+		if rz == nil {
+			continue
+		}
+		ptr := &PTR{Hdr: RR_Header{Name: name, Rrtype: TypePTR, Class: ClassINET, Ttl: a.Header().Ttl}, Ptr: a.Header().Name}
+		if rz.find(ptr) != nil {
+			set[name] = true
+			continue
+		}
+		if zd, exact := rz.lookup(name); exact {
+			zd.RLock()
+			n := len(zd.RR[TypePTR])
+			zd.RUnlock()
+			if n > 0 {
+				switch {
+				case policy == PTRKeep:
+					continue
+				case policy == PTRReplace && set[name]:
+					// Another name in forward has the same address, the
+					// first one wins
+					continue
+				case policy == PTRReplace:
+					if err := rz.RemoveRRset(name, TypePTR); err != nil {
+						return added, err
+					}
+				}
+			}
+		}
+		if err := rz.Insert(ptr); err != nil {
+			return added, err
+		}
+		set[name] = true
+		added = append(added, ptr)
+	}
+	return added, nil
+}
@@ -0,0 +1,295 @@
+// REWRITING
+//
+// A Rewriter sits in front of a Handler and changes queries before they are
+// handled and replies before they are sent back. Each RewriteRule matches on
+// the query name, either exactly, on a suffix or with a regular expression,
+// and optionally on the query type. The first matching rule is used. A rule
+// can:
+//
+//   - rewrite the query name (domain aliasing), the reply is renamed back;
+//   - replace an NXDOMAIN reply with a CNAME (NXDOMAIN redirection);
+//   - flatten a CNAME chain in the answer to records owned by the query name;
+//   - clamp the TTLs in the reply.
+//
+// Basic use pattern:
+//
+//	rw := dns.NewRewriter()
+//	rw.Add(&dns.RewriteRule{Match: dns.RewriteSuffix, Name: "corp.example.", Replace: "example.net."})
+//	rw.Add(&dns.RewriteRule{Match: dns.RewriteRegexp, Name: `^www\.(.*)$`, Flatten: true, MaxTtl: 300})
+//	rw.Log = func(a *dns.RewriteAudit) { log.Printf("%s", a) }
+//	dns.Handle(".", rw.Wrap(handler))
+package dns
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// How a RewriteRule matches the query name.
+const (
+	RewriteExact  = iota // Name must be equal to the query name
+	RewriteSuffix        // Name must be a suffix of the query name
+	RewriteRegexp        // Name is a regular expression that must match the query name
+)
+
+// RewriteRule is a single rewrite rule.
+type RewriteRule struct {
+	Match    int              // How to match the query name: RewriteExact, RewriteSuffix or RewriteRegexp
+	Name     string           // Name, suffix or regular expression to match
+	Qtype    uint16           // If not zero, only queries for this type match
+	Matcher  *QuestionMatcher // If not nil, the query must also match Matcher
+	Replace  string           // If not empty, the new query name, the new suffix, or the regexp replacement (may use $1, etc.)
+	Nxdomain string           // If not empty, NXDOMAIN replies are replaced with a CNAME to this name
+	Flatten  bool             // If true, CNAME chains in the answer are flattened
+	MinTtl   uint32           // If not zero, TTLs in the reply are raised to at least MinTtl
+	MaxTtl   uint32           // If not zero, TTLs in the reply are lowered to at most MaxTtl
+	re       *regexp.Regexp
+}
+
+// RewriteAudit describes what a RewriteRule did. It is handed to Rewriter.Log.
+type RewriteAudit struct {
+	Rule      *RewriteRule // The matching rule
+	Qname     string       // Query name as received
+	Qtype     uint16       // Query type as received
+	Rewritten string       // Query name as handed to the handler
+	Client    net.Addr     // Address of the client, may be nil
+	Changes   []string     // Description of the changes made to the reply
+}
+
+func (a *RewriteAudit) String() string {
+	s := "rewrite: qname: " + a.Qname
+	if t, ok := TypeToString[a.Qtype]; ok {
+		s += " " + t
+	} else {
+		s += " TYPE" + strconv.Itoa(int(a.Qtype))
+	}
+	if a.Rewritten != a.Qname {
+		s += " rewritten: " + a.Rewritten
+	}
+	if a.Client != nil {
+		s += " client: " + a.Client.String()
+	}
+	if len(a.Changes) > 0 {
+		s += ": " + strings.Join(a.Changes, ", ")
+	}
+	return s
+}
+
+// Rewriter holds the rewrite rules. It's safe for concurrent use by
+// multiple goroutines.
+type Rewriter struct {
+	Log   func(*RewriteAudit) // If not nil, called for each query a rule matched
+	rules []*RewriteRule
+	*sync.RWMutex
+}
+
+// NewRewriter returns a Rewriter without any rules.
+func NewRewriter() *Rewriter {
+	return &Rewriter{RWMutex: new(sync.RWMutex)}
+}
+
+// Add appends rule to the rules of the rewriter.
+func (rw *Rewriter) Add(rule *RewriteRule) error {
+	switch rule.Match {
+	case RewriteExact, RewriteSuffix:
+		if _, _, ok := IsDomainName(rule.Name); !ok {
+			return &Error{Err: "bad domain name", Name: rule.Name}
+		}
+		rule.Name = Fqdn(strings.ToLower(rule.Name))
+		if rule.Replace != "" {
+			rule.Replace = Fqdn(rule.Replace)
+		}
+	case RewriteRegexp:
+		re, err := regexp.Compile(rule.Name)
+		if err != nil {
+			return err
+		}
+		rule.re = re
+	default:
+		return &Error{Err: "unknown rewrite match type " + strconv.Itoa(rule.Match)}
+	}
+	rw.Lock()
+	rw.rules = append(rw.rules, rule)
+	rw.Unlock()
+	return nil
+}
+
+// match returns the first rule matching q and the rewritten query name.
+func (rw *Rewriter) match(q Question) (*RewriteRule, string) {
+	rw.RLock()
+	defer rw.RUnlock()
+	name := strings.ToLower(Fqdn(q.Name))
+	for _, r := range rw.rules {
+		if r.Qtype != 0 && r.Qtype != q.Qtype {
+			continue
+		}
+		if r.Matcher != nil && !r.Matcher.Match(q) {
+			continue
+		}
+		switch r.Match {
+		case RewriteExact:
+			if name != r.Name {
+				continue
+			}
+			if r.Replace != "" {
+				return r, r.Replace
+			}
+		case RewriteSuffix:
+			if !IsSubDomain(r.Name, name) {
+				continue
+			}
+			if r.Replace != "" {
+				return r, name[:len(name)-len(r.Name)] + r.Replace
+			}
+		case RewriteRegexp:
+			if !r.re.MatchString(name) {
+				continue
+			}
+			if r.Replace != "" {
+				return r, Fqdn(r.re.ReplaceAllString(name, r.Replace))
+			}
+		}
+		return r, q.Name
+	}
+	return nil, ""
+}
+
+// Wrap returns a Handler that applies the rewrite rules to the queries
+// handled by h.
+func (rw *Rewriter) Wrap(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, req *Msg) {
+		if len(req.Question) != 1 {
+			h.ServeDNS(w, req)
+			return
+		}
+		q := req.Question[0]
+		rule, name := rw.match(q)
+		if rule == nil {
+			h.ServeDNS(w, req)
+			return
+		}
+		audit := &RewriteAudit{Rule: rule, Qname: q.Name, Qtype: q.Qtype, Rewritten: name, Client: w.RemoteAddr()}
+		if name != q.Name {
+			// Don't modify the request of the caller
+			r := *req
+			r.Question = []Question{{name, q.Qtype, q.Qclass}}
+			req = &r
+		}
+		h.ServeDNS(&rewriteWriter{w, rw, audit, q}, req)
+	})
+}
+
+// rewriteWriter rewrites the reply written by the wrapped handler.
+type rewriteWriter struct {
+	ResponseWriter
+	rw    *Rewriter
+	audit *RewriteAudit
+	q     Question // The original question
+}
+
+func (w *rewriteWriter) WriteMsg(m *Msg) error {
+	a, rule := w.audit, w.audit.Rule
+	// The records may be those of the wrapped handler, change copies
+	m1 := *m
+	m = &m1
+	m.Question = append([]Question(nil), m.Question...)
+	for _, s := range []*[]RR{&m.Answer, &m.Ns, &m.Extra} {
+		rrs := make([]RR, len(*s))
+		for i, r := range *s {
+			rrs[i] = r.Copy()
+		}
+		*s = rrs
+	}
+	if len(m.Question) == 1 {
+		m.Question[0] = w.q
+	}
+	if a.Rewritten != a.Qname {
+		n := 0
+		for _, s := range [][]RR{m.Answer, m.Ns, m.Extra} {
+			for _, r := range s {
+				if strings.EqualFold(r.Header().Name, a.Rewritten) {
+					r.Header().Name = a.Qname
+					n++
+				}
+			}
+		}
+		a.Changes = append(a.Changes, "renamed "+strconv.Itoa(n)+" records")
+	}
+	if rule.Nxdomain != "" && m.Rcode == RcodeNameError {
+		m.Rcode = RcodeSuccess
+		m.Ns = nil
+		m.Answer = []RR{&CNAME{Hdr: RR_Header{Name: a.Qname, Rrtype: TypeCNAME, Class: ClassINET, Ttl: rule.MinTtl}, Target: Fqdn(rule.Nxdomain)}}
+		a.Changes = append(a.Changes, "redirected NXDOMAIN to "+Fqdn(rule.Nxdomain))
+	}
+	if rule.Flatten && w.q.Qtype != TypeCNAME {
+		if answer, ok := flattenCNAME(m.Answer, w.q); ok {
+			m.Answer = answer
+			a.Changes = append(a.Changes, "flattened CNAME")
+		}
+	}
+	if rule.MinTtl != 0 || rule.MaxTtl != 0 {
+		for _, s := range [][]RR{m.Answer, m.Ns, m.Extra} {
+			for _, r := range s {
+				h := r.Header()
+				if h.Rrtype == TypeOPT {
+					continue
+				}
+				if h.Ttl < rule.MinTtl {
+					h.Ttl = rule.MinTtl
+				}
+				if rule.MaxTtl != 0 && h.Ttl > rule.MaxTtl {
+					h.Ttl = rule.MaxTtl
+				}
+			}
+		}
+		a.Changes = append(a.Changes, "clamped TTLs")
+	}
+	if w.rw.Log != nil {
+		w.rw.Log(a)
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func (w *rewriteWriter) RequestInfo() *RequestInfo { return requestInfo(w.ResponseWriter) }
+
+// flattenCNAME replaces the CNAME chain in answer, starting at q.Name, with
+// the records the chain ends in, renamed to q.Name. The TTL is lowered
+// to the lowest TTL in the chain.
+func flattenCNAME(answer []RR, q Question) ([]RR, bool) {
+	name, ttl, chain := q.Name, uint32(0), false
+	for i := 0; i <= len(answer); i++ { // there can't be a longer chain than this
+		found := false
+		for _, r := range answer {
+			if c, ok := r.(*CNAME); ok && strings.EqualFold(c.Hdr.Name, name) {
+				if !chain || c.Hdr.Ttl < ttl {
+					ttl = c.Hdr.Ttl
+				}
+				name, chain, found = c.Target, true, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	if !chain {
+		return answer, false
+	}
+	flat := make([]RR, 0, len(answer))
+	for _, r := range answer {
+		h := r.Header()
+		if !strings.EqualFold(h.Name, name) || (h.Rrtype != q.Qtype && q.Qtype != TypeANY) {
+			continue
+		}
+		r1 := r.Copy()
+		r1.Header().Name = q.Name
+		if r1.Header().Ttl > ttl {
+			r1.Header().Ttl = ttl
+		}
+		flat = append(flat, r1)
+	}
+	return flat, true
+}
@@ -0,0 +1,66 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	rw := NewRewriter()
+	rw.Add(&RewriteRule{Match: RewriteSuffix, Name: "corp.example.", Replace: "example.net.", MaxTtl: 60})
+	rw.Add(&RewriteRule{Match: RewriteExact, Name: "missing.example.", Nxdomain: "search.example.org."})
+	if err := rw.Add(&RewriteRule{Match: RewriteRegexp, Name: "(bad"}); err == nil {
+		t.Error("bad regexp should fail")
+	}
+	var seen string
+	a, _ := NewRR("www.example.net. 3600 IN A 192.0.2.53")
+	h := rw.Wrap(HandlerFunc(func(w ResponseWriter, req *Msg) {
+		seen = req.Question[0].Name
+		m := new(Msg)
+		if seen == "missing.example." {
+			m.SetRcode(req, RcodeNameError)
+			w.WriteMsg(m)
+			return
+		}
+		m.SetReply(req)
+		m.Answer = append(m.Answer, a) // the handler's own record
+		w.WriteMsg(m)
+	}))
+
+	w := new(testWriter)
+	m := new(Msg)
+	m.SetQuestion("www.corp.example.", TypeA)
+	h.ServeDNS(w, m)
+	if seen != "www.example.net." {
+		t.Errorf("query should be rewritten to www.example.net., got %s", seen)
+	}
+	if m.Question[0].Name != "www.corp.example." {
+		t.Error("request of the caller should not be modified")
+	}
+	if len(w.msg.Answer) != 1 || w.msg.Answer[0].Header().Name != "www.corp.example." || w.msg.Answer[0].Header().Ttl != 60 {
+		t.Errorf("answer should be renamed and clamped, got %v", w.msg)
+	}
+	if a.Header().Name != "www.example.net." || a.Header().Ttl != 3600 {
+		t.Errorf("record of the handler should not be modified, got %s", a)
+	}
+
+	m.SetQuestion("missing.example.", TypeA)
+	h.ServeDNS(w, m)
+	if w.msg.Rcode != RcodeSuccess || len(w.msg.Answer) != 1 || w.msg.Answer[0].(*CNAME).Target != "search.example.org." {
+		t.Errorf("NXDOMAIN should be redirected, got %v", w.msg)
+	}
+}
+
+func TestFlattenCNAME(t *testing.T) {
+	var answer []RR
+	for _, s := range []string{"www.example. 300 IN CNAME a.example.", "a.example. 60 IN CNAME b.example.", "b.example. 3600 IN A 192.0.2.1"} {
+		r, _ := NewRR(s)
+		answer = append(answer, r)
+	}
+	flat, ok := flattenCNAME(answer, Question{"www.example.", TypeA, ClassINET})
+	if !ok || len(flat) != 1 {
+		t.Fatalf("chain should be flattened to one record, got %v", flat)
+	}
+	if flat[0].Header().Name != "www.example." || flat[0].Header().Ttl != 60 {
+		t.Errorf("flattened record has wrong name or ttl: %s", flat[0].String())
+	}
+}
@@ -0,0 +1,219 @@
+// ROOT HINTS AND TRUST ANCHORS
+//
+// The package ships a copy of the root hints file of IANA (named.root) and of
+// the root trust anchors (root-anchors.xml), so resolvers and validators work
+// without any configuration. When RootHintsFile or RootAnchorsFile is set,
+// that file is read instead; keep it up to date with RFC 5011 or by
+// downloading it from https://www.iana.org/domains/root/files and
+// https://data.iana.org/root-anchors/root-anchors.xml.
+//
+// Basic use pattern:
+//
+//	anchors, err := dns.RootTrustAnchors()
+//	c := &dns.Client{Validate: true, TrustAnchors: anchors}
+package dns
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The files that override the shipped root hints and trust anchors.
+var (
+	RootHintsFile   string // in the format of named.root
+	RootAnchorsFile string // in the format of root-anchors.xml (RFC 9718)
+)
+
+// RootHints returns the NS records of the root and the A and AAAA records of
+// the root servers, from RootHintsFile or from the shipped copy.
+func RootHints() ([]RR, error) {
+	if RootHintsFile == "" {
+		return ReadRootHints(strings.NewReader(rootHints))
+	}
+	f, err := os.Open(RootHintsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRootHints(f)
+}
+
+// ReadRootHints reads a root hints file, in the format of named.root, from r.
+// Only NS records of the root and address records of their targets are
+// accepted.
+func ReadRootHints(r io.Reader) ([]RR, error) {
+	rrs, err := ParseZoneAll(r, ".", "named.root")
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[string]bool)
+	for _, rr := range rrs {
+		if ns, ok := rr.(*NS); ok && ns.Hdr.Name == "." {
+			targets[strings.ToLower(ns.Ns)] = true
+		}
+	}
+	if len(targets) == 0 {
+		return nil, &Error{Err: "no root NS records in root hints"}
+	}
+	for _, rr := range rrs {
+		h := rr.Header()
+		switch h.Rrtype {
+		case TypeNS:
+			if h.Name != "." {
+				return nil, &Error{Err: "NS record in root hints is not for the root", Name: h.Name}
+			}
+		case TypeA, TypeAAAA:
+			if !targets[strings.ToLower(h.Name)] {
+				return nil, &Error{Err: "address in root hints is not of a root server", Name: h.Name}
+			}
+		default:
+			return nil, &Error{Err: "unexpected " + TypeToString[h.Rrtype] + " record in root hints", Name: h.Name}
+		}
+	}
+	return rrs, nil
+}
+
+// RootTrustAnchors returns the DS records of the root keys that are valid
+// now, from RootAnchorsFile or from the shipped copy.
+func RootTrustAnchors() ([]RR, error) {
+	if RootAnchorsFile == "" {
+		return ReadTrustAnchors(strings.NewReader(rootAnchors), time.Now())
+	}
+	f, err := os.Open(RootAnchorsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTrustAnchors(f, time.Now())
+}
+
+// trustAnchorXML is the format of root-anchors.xml.
+type trustAnchorXML struct {
+	Zone       string `xml:"Zone"`
+	KeyDigests []struct {
+		ID         string `xml:"id,attr"`
+		ValidFrom  string `xml:"validFrom,attr"`
+		ValidUntil string `xml:"validUntil,attr"`
+		KeyTag     uint16 `xml:"KeyTag"`
+		Algorithm  uint8  `xml:"Algorithm"`
+		DigestType uint8  `xml:"DigestType"`
+		Digest     string `xml:"Digest"`
+	} `xml:"KeyDigest"`
+}
+
+// ReadTrustAnchors reads trust anchors in the XML format of IANA's
+// root-anchors.xml (RFC 9718) from r. It returns the DS records of the key
+// digests that are valid at time t.
+func ReadTrustAnchors(r io.Reader, t time.Time) ([]RR, error) {
+	var x trustAnchorXML
+	if err := xml.NewDecoder(r).Decode(&x); err != nil {
+		return nil, &Error{Err: "bad trust anchor xml: " + err.Error()}
+	}
+	zone := Fqdn(x.Zone)
+	var anchors []RR
+	for _, k := range x.KeyDigests {
+		from, err := time.Parse(time.RFC3339, k.ValidFrom)
+		if err != nil {
+			return nil, &Error{Err: "bad validFrom in key digest " + k.ID, Name: zone}
+		}
+		if t.Before(from) {
+			continue
+		}
+		if k.ValidUntil != "" {
+			until, err := time.Parse(time.RFC3339, k.ValidUntil)
+			if err != nil {
+				return nil, &Error{Err: "bad validUntil in key digest " + k.ID, Name: zone}
+			}
+			if !t.Before(until) {
+				continue
+			}
+		}
+		ds := &DS{Hdr: RR_Header{Name: zone, Rrtype: TypeDS, Class: ClassINET}, KeyTag: k.KeyTag,
+			Algorithm: k.Algorithm, DigestType: k.DigestType, Digest: strings.ToUpper(k.Digest)}
+		if ds.KeyTag == 0 || ds.Digest == "" {
+			return nil, &Error{Err: "incomplete key digest " + strconv.Quote(k.ID), Name: zone}
+		}
+		anchors = append(anchors, ds)
+	}
+	if len(anchors) == 0 {
+		return nil, &Error{Err: "no valid trust anchors", Name: zone}
+	}
+	return anchors, nil
+}
+
+// The root hints, from https://www.internic.net/domain/named.root.
+const rootHints = `;       This file holds the information on root name servers needed to
+;       initialize cache of Internet domain name servers
+;
+;       last update:     July 19, 2024
+;       related version of root zone:     2024071901
+;
+.                        3600000      NS    A.ROOT-SERVERS.NET.
+A.ROOT-SERVERS.NET.      3600000      A     198.41.0.4
+A.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:ba3e::2:30
+.                        3600000      NS    B.ROOT-SERVERS.NET.
+B.ROOT-SERVERS.NET.      3600000      A     170.247.170.2
+B.ROOT-SERVERS.NET.      3600000      AAAA  2801:1b8:10::b
+.                        3600000      NS    C.ROOT-SERVERS.NET.
+C.ROOT-SERVERS.NET.      3600000      A     192.33.4.12
+C.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2::c
+.                        3600000      NS    D.ROOT-SERVERS.NET.
+D.ROOT-SERVERS.NET.      3600000      A     199.7.91.13
+D.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2d::d
+.                        3600000      NS    E.ROOT-SERVERS.NET.
+E.ROOT-SERVERS.NET.      3600000      A     192.203.230.10
+E.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:a8::e
+.                        3600000      NS    F.ROOT-SERVERS.NET.
+F.ROOT-SERVERS.NET.      3600000      A     192.5.5.241
+F.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2f::f
+.                        3600000      NS    G.ROOT-SERVERS.NET.
+G.ROOT-SERVERS.NET.      3600000      A     192.112.36.4
+G.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:12::d0d
+.                        3600000      NS    H.ROOT-SERVERS.NET.
+H.ROOT-SERVERS.NET.      3600000      A     198.97.190.53
+H.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:1::53
+.                        3600000      NS    I.ROOT-SERVERS.NET.
+I.ROOT-SERVERS.NET.      3600000      A     192.36.148.17
+I.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fe::53
+.                        3600000      NS    J.ROOT-SERVERS.NET.
+J.ROOT-SERVERS.NET.      3600000      A     192.58.128.30
+J.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:c27::2:30
+.                        3600000      NS    K.ROOT-SERVERS.NET.
+K.ROOT-SERVERS.NET.      3600000      A     193.0.14.129
+K.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fd::1
+.                        3600000      NS    L.ROOT-SERVERS.NET.
+L.ROOT-SERVERS.NET.      3600000      A     199.7.83.42
+L.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:9f::42
+.                        3600000      NS    M.ROOT-SERVERS.NET.
+M.ROOT-SERVERS.NET.      3600000      A     202.12.27.33
+M.ROOT-SERVERS.NET.      3600000      AAAA  2001:dc3::35
+`
+
+// The root trust anchors, from https://data.iana.org/root-anchors/root-anchors.xml.
+const rootAnchors = `<?xml version="1.0" encoding="UTF-8"?>
+<TrustAnchor id="E9724F53-1851-4F86-85E5-F1392102940B" source="http://data.iana.org/root-anchors/root-anchors.xml">
+<Zone>.</Zone>
+<KeyDigest id="Kjqmt7v" validFrom="2010-07-15T00:00:00+00:00" validUntil="2019-01-11T00:00:00+00:00">
+<KeyTag>19036</KeyTag>
+<Algorithm>8</Algorithm>
+<DigestType>2</DigestType>
+<Digest>49AAC11D7B6F6446702E54A1607371607A1A41855200FD2CE1CDDE32F24E8FB5</Digest>
+</KeyDigest>
+<KeyDigest id="Klajeyz" validFrom="2017-02-02T00:00:00+00:00">
+<KeyTag>20326</KeyTag>
+<Algorithm>8</Algorithm>
+<DigestType>2</DigestType>
+<Digest>E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D</Digest>
+</KeyDigest>
+<KeyDigest id="Kmyv6jo" validFrom="2024-07-18T00:00:00+00:00">
+<KeyTag>38696</KeyTag>
+<Algorithm>8</Algorithm>
+<DigestType>2</DigestType>
+<Digest>683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16</Digest>
+</KeyDigest>
+</TrustAnchor>
+`
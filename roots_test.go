@@ -0,0 +1,55 @@
+package dns
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRootHints(t *testing.T) {
+	rrs, err := RootHints()
+	if err != nil {
+		t.Fatalf("failed to read the root hints: %s", err.Error())
+	}
+	count := make(map[uint16]int)
+	for _, rr := range rrs {
+		count[rr.Header().Rrtype]++
+	}
+	if count[TypeNS] != 13 || count[TypeA] != 13 || count[TypeAAAA] != 13 {
+		t.Errorf("expected 13 NS, A and AAAA records, got %v", count)
+	}
+
+	if _, err := ReadRootHints(strings.NewReader("example.org. NS a.root-servers.net.\n")); err == nil {
+		t.Error("expected an error for an NS record that is not for the root")
+	}
+
+	RootHintsFile = filepath.Join(t.TempDir(), "named.root")
+	defer func() { RootHintsFile = "" }()
+	os.WriteFile(RootHintsFile, []byte(". 3600000 NS a.root-servers.net.\na.root-servers.net. 3600000 A 192.0.2.1\n"), 0644)
+	if rrs, err := RootHints(); err != nil || len(rrs) != 2 {
+		t.Errorf("expected the 2 records of the override file, got %d (%v)", len(rrs), err)
+	}
+}
+
+func TestRootTrustAnchors(t *testing.T) {
+	anchors, err := ReadTrustAnchors(strings.NewReader(rootAnchors), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("failed to read the trust anchors: %s", err.Error())
+	}
+	if len(anchors) != 1 || anchors[0].(*DS).KeyTag != 20326 {
+		t.Errorf("expected only KSK-2017 in 2020, got %v", anchors)
+	}
+	if anchors, _ = ReadTrustAnchors(strings.NewReader(rootAnchors), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)); len(anchors) != 2 {
+		t.Errorf("expected KSK-2017 and KSK-2024 in 2025, got %v", anchors)
+	}
+	ds := anchors[0].(*DS)
+	if ds.Hdr.Name != "." || ds.Algorithm != RSASHA256 || ds.DigestType != SHA256 {
+		t.Errorf("bad trust anchor %s", ds)
+	}
+
+	if _, err := ReadTrustAnchors(strings.NewReader("<TrustAnchor>"), time.Now()); err == nil {
+		t.Error("expected an error for bad xml")
+	}
+}
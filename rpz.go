@@ -0,0 +1,438 @@
+// RESPONSE POLICY ZONES
+//
+// A Response Policy Zone (RPZ) is a normal DNS zone which holds rules that
+// rewrite or block answers. The owner name of each record is the trigger,
+// the rdata is the action. Supported triggers are:
+//
+//	example.com.<rpz-origin>                   QNAME trigger (wildcards allowed)
+//	32.1.0.0.10.rpz-ip.<rpz-origin>            IP trigger, matches addresses in the answer
+//	ns.example.net.rpz-nsdname.<rpz-origin>    NSDNAME trigger, matches names of the name servers
+//	32.1.0.0.10.rpz-nsip.<rpz-origin>          NSIP trigger, matches addresses of the name servers
+//
+// The action is encoded in the rdata:
+//
+//	CNAME .               NXDOMAIN
+//	CNAME *.              NODATA
+//	CNAME rpz-drop.       drop the query, nothing is sent back
+//	CNAME rpz-passthru.   give the original answer (allowlist)
+//	CNAME garden.example. rewrite to a CNAME (walled garden)
+//	<any other RR>        local data, this data is returned instead
+//
+// Basic use pattern for protecting a handler with a policy zone:
+//
+//	p := dns.NewRPZ("rpz.example.org.")
+//	if err := p.ReadZone(f, "rpz.db"); err != nil {
+//		// handle error
+//	}
+//	p.Log = func(h *dns.PolicyHit) { log.Printf("%s", h) }
+//	dns.Handle(".", p.Wrap(handler))
+package dns
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RPZ policy actions.
+const (
+	PolicyPassthru  = iota // Give the original answer
+	PolicyNxdomain         // Answer with NXDOMAIN
+	PolicyNodata           // Answer with NOERROR and an empty answer section
+	PolicyDrop             // Do not answer at all
+	PolicyLocalData        // Answer with the data from the policy zone
+)
+
+// RPZ policy triggers.
+const (
+	TriggerQname   = iota // The query name matched
+	TriggerIP             // An address in the answer matched
+	TriggerNsdname        // The name of an authoritative name server matched
+	TriggerNsip           // The address of an authoritative name server matched
+)
+
+// Map of strings for the RPZ actions.
+var PolicyToString = map[int]string{
+	PolicyPassthru:  "PASSTHRU",
+	PolicyNxdomain:  "NXDOMAIN",
+	PolicyNodata:    "NODATA",
+	PolicyDrop:      "DROP",
+	PolicyLocalData: "LOCAL-DATA",
+}
+
+// Map of strings for the RPZ triggers.
+var TriggerToString = map[int]string{
+	TriggerQname:   "QNAME",
+	TriggerIP:      "IP",
+	TriggerNsdname: "NSDNAME",
+	TriggerNsip:    "NSIP",
+}
+
+// PolicyHit describes a match of a policy rule. It is handed to RPZ.Log.
+type PolicyHit struct {
+	Trigger int      // Kind of trigger that matched
+	Action  int      // Action taken
+	Rule    string   // Owner name of the matching rule in the policy zone
+	Qname   string   // Query name of the request
+	Qtype   uint16   // Query type of the request
+	Client  net.Addr // Address of the client, may be nil
+}
+
+func (h *PolicyHit) String() string {
+	s := "rpz: " + TriggerToString[h.Trigger] + " " + PolicyToString[h.Action] + " " + h.Rule + " qname: " + h.Qname
+	if t, ok := TypeToString[h.Qtype]; ok {
+		s += " " + t
+	} else {
+		s += " TYPE" + strconv.Itoa(int(h.Qtype))
+	}
+	if h.Client != nil {
+		s += " client: " + h.Client.String()
+	}
+	return s
+}
+
+type policyRule struct {
+	owner  string // owner name in the policy zone
+	action int
+	data   []RR // local data, only for PolicyLocalData
+}
+
+type policyNet struct {
+	net  *net.IPNet
+	rule *policyRule
+}
+
+// RPZ holds the rules of a response policy zone. It's safe for concurrent
+// use by multiple goroutines.
+type RPZ struct {
+	Origin  string                 // Origin of the policy zone
+	Log     func(*PolicyHit)       // If not nil, called for each policy hit
+	qname   map[string]*policyRule // QNAME triggers
+	nsdname map[string]*policyRule // NSDNAME triggers
+	ip      []*policyNet           // IP triggers
+	nsip    []*policyNet           // NSIP triggers
+	*sync.RWMutex
+}
+
+// NewRPZ creates an empty response policy zone with Origin set to origin.
+func NewRPZ(origin string) *RPZ {
+	if _, _, ok := IsDomainName(origin); !ok {
+		return nil
+	}
+	p := new(RPZ)
+	p.Origin = Fqdn(strings.ToLower(origin))
+	p.qname = make(map[string]*policyRule)
+	p.nsdname = make(map[string]*policyRule)
+	p.RWMutex = new(sync.RWMutex)
+	return p
+}
+
+// ReadZone reads a policy zone in the standard zone file format from r and
+// inserts all the rules. The string file is only used in error reporting.
+func (p *RPZ) ReadZone(r io.Reader, file string) error {
+	for x := range ParseZone(r, p.Origin, file) {
+		if x.Error != nil {
+			return x.Error
+		}
+		if err := p.Insert(x.RR); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert adds the policy rule encoded in r. Records at the apex of the
+// policy zone (SOA, NS) are ignored.
+func (p *RPZ) Insert(r RR) error {
+	name := strings.ToLower(r.Header().Name)
+	if name == p.Origin {
+		return nil
+	}
+	if !strings.HasSuffix(name, "."+p.Origin) {
+		return &Error{Err: "out of zone data", Name: r.Header().Name}
+	}
+	labels := SplitLabels(name[:len(name)-len(p.Origin)])
+	if len(labels) == 0 {
+		return &Error{Err: "bad rpz trigger", Name: r.Header().Name}
+	}
+	p.Lock()
+	defer p.Unlock()
+	switch labels[len(labels)-1] {
+	case "rpz-ip", "rpz-nsip":
+		n := parseRPZIP(labels[:len(labels)-1])
+		if n == nil {
+			return &Error{Err: "bad rpz ip trigger", Name: r.Header().Name}
+		}
+		if labels[len(labels)-1] == "rpz-ip" {
+			p.ip = insertPolicyNet(p.ip, n, name, r)
+		} else {
+			p.nsip = insertPolicyNet(p.nsip, n, name, r)
+		}
+	case "rpz-nsdname":
+		key := JoinLabels(labels[:len(labels)-1])
+		p.nsdname[key] = addPolicyRule(p.nsdname[key], name, r)
+	case "rpz-client-ip", "rpz-ip6":
+		// Not supported, silently ignored
+	default:
+		key := JoinLabels(labels)
+		p.qname[key] = addPolicyRule(p.qname[key], name, r)
+	}
+	return nil
+}
+
+// addPolicyRule adds the action in r to the rule pr, pr may be nil.
+func addPolicyRule(pr *policyRule, owner string, r RR) *policyRule {
+	if pr == nil {
+		pr = &policyRule{owner: owner, action: PolicyLocalData}
+	}
+	if c, ok := r.(*CNAME); ok {
+		switch strings.ToLower(c.Target) {
+		case ".":
+			pr.action = PolicyNxdomain
+			return pr
+		case "*.":
+			pr.action = PolicyNodata
+			return pr
+		case "rpz-drop.":
+			pr.action = PolicyDrop
+			return pr
+		case "rpz-passthru.":
+			pr.action = PolicyPassthru
+			return pr
+		}
+	}
+	pr.action = PolicyLocalData
+	pr.data = append(pr.data, r)
+	return pr
+}
+
+func insertPolicyNet(nets []*policyNet, n *net.IPNet, owner string, r RR) []*policyNet {
+	for _, pn := range nets {
+		if pn.net.String() == n.String() {
+			pn.rule = addPolicyRule(pn.rule, owner, r)
+			return nets
+		}
+	}
+	return append(nets, &policyNet{n, addPolicyRule(nil, owner, r)})
+}
+
+// parseRPZIP parses the labels of an rpz-ip or rpz-nsip trigger, i.e.
+// 24.0.2.0.192 or 128.1.zz.db8.2001, into an IP network.
+func parseRPZIP(labels []string) *net.IPNet {
+	if len(labels) < 2 {
+		return nil
+	}
+	prefix, err := strconv.Atoi(labels[0])
+	if err != nil {
+		return nil
+	}
+	labels = labels[1:]
+	var s string
+	if len(labels) == 4 && strings.Index(JoinLabels(labels), "zz") == -1 && prefix <= 32 {
+		for i := len(labels) - 1; i >= 0; i-- {
+			s += labels[i]
+			if i > 0 {
+				s += "."
+			}
+		}
+		_, n, err := net.ParseCIDR(s + "/" + strconv.Itoa(prefix))
+		if err != nil {
+			return nil
+		}
+		return n
+	}
+	for i := len(labels) - 1; i >= 0; i-- {
+		if labels[i] == "zz" {
+			s += ":"
+		} else {
+			s += labels[i]
+		}
+		if i > 0 {
+			s += ":"
+		}
+	}
+	s = strings.Replace(s, ":::", "::", 1)
+	_, n, err := net.ParseCIDR(s + "/" + strconv.Itoa(prefix))
+	if err != nil {
+		return nil
+	}
+	return n
+}
+
+// matchQname returns the rule matching qname, exact matches are
+// preferred over wildcards, the closest wildcard wins.
+func (p *RPZ) matchQname(m map[string]*policyRule, qname string) *policyRule {
+	qname = strings.ToLower(Fqdn(qname))
+	if r, ok := m[qname]; ok {
+		return r
+	}
+	labels := SplitLabels(qname)
+	for i := 1; i < len(labels); i++ {
+		if r, ok := m["*."+JoinLabels(labels[i:])]; ok {
+			return r
+		}
+	}
+	if r, ok := m["*."]; ok {
+		return r
+	}
+	return nil
+}
+
+// matchIP returns the rule with the longest prefix that contains ip.
+func matchIP(nets []*policyNet, ip net.IP) *policyRule {
+	var (
+		best *policyNet
+		bits int
+	)
+	for _, pn := range nets {
+		if pn.net.Contains(ip) {
+			if ones, _ := pn.net.Mask.Size(); best == nil || ones > bits {
+				best, bits = pn, ones
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.rule
+}
+
+// Wrap returns a Handler that applies the policy zone to the queries
+// handled by h. QNAME triggers are checked before h is called, the other
+// triggers are checked on the reply h writes with WriteMsg.
+func (p *RPZ) Wrap(h Handler) Handler {
+	return &rpzHandler{p, h}
+}
+
+type rpzHandler struct {
+	rpz *RPZ
+	h   Handler
+}
+
+func (r *rpzHandler) ServeDNS(w ResponseWriter, req *Msg) {
+	if len(req.Question) != 1 {
+		r.h.ServeDNS(w, req)
+		return
+	}
+	r.rpz.RLock()
+	rule := r.rpz.matchQname(r.rpz.qname, req.Question[0].Name)
+	r.rpz.RUnlock()
+	if rule != nil {
+		if rule.action == PolicyPassthru {
+			// Allowlisted, the other triggers are not checked
+			r.rpz.log(w, req, rule, TriggerQname)
+			r.h.ServeDNS(w, req)
+			return
+		}
+		r.rpz.apply(w, req, nil, rule, TriggerQname)
+		return
+	}
+	r.h.ServeDNS(&rpzWriter{w, r.rpz, req}, req)
+}
+
+// rpzWriter checks the reply written by the wrapped handler
+// for IP, NSDNAME and NSIP triggers.
+type rpzWriter struct {
+	ResponseWriter
+	rpz *RPZ
+	req *Msg
+}
+
+func (w *rpzWriter) WriteMsg(m *Msg) error {
+	p := w.rpz
+	p.RLock()
+	var (
+		rule    *policyRule
+		trigger int
+	)
+	for _, a := range m.Answer {
+		switch x := a.(type) {
+		case *A:
+			rule = matchIP(p.ip, x.A)
+		case *AAAA:
+			rule = matchIP(p.ip, x.AAAA)
+		}
+		if rule != nil {
+			trigger = TriggerIP
+			break
+		}
+	}
+	if rule == nil {
+		nsnames := make(map[string]bool)
+	NS:
+		for _, n := range m.Ns {
+			if ns, ok := n.(*NS); ok {
+				nsnames[strings.ToLower(ns.Ns)] = true
+				if rule = p.matchQname(p.nsdname, ns.Ns); rule != nil {
+					trigger = TriggerNsdname
+					break NS
+				}
+			}
+		}
+		for _, e := range m.Extra {
+			if rule != nil {
+				break
+			}
+			if !nsnames[strings.ToLower(e.Header().Name)] {
+				continue
+			}
+			switch x := e.(type) {
+			case *A:
+				rule = matchIP(p.nsip, x.A)
+			case *AAAA:
+				rule = matchIP(p.nsip, x.AAAA)
+			}
+			trigger = TriggerNsip
+		}
+	}
+	p.RUnlock()
+	if rule == nil {
+		return w.ResponseWriter.WriteMsg(m)
+	}
+	return p.apply(w.ResponseWriter, w.req, m, rule, trigger)
+}
+
+func (w *rpzWriter) RequestInfo() *RequestInfo { return requestInfo(w.ResponseWriter) }
+
+func (p *RPZ) log(w ResponseWriter, req *Msg, rule *policyRule, trigger int) {
+	if p.Log == nil {
+		return
+	}
+	q := req.Question[0]
+	p.Log(&PolicyHit{Trigger: trigger, Action: rule.action, Rule: rule.owner, Qname: q.Name, Qtype: q.Qtype, Client: w.RemoteAddr()})
+}
+
+// apply executes the action of rule, orig is the original reply, which
+// may be nil when it has not been generated yet.
+func (p *RPZ) apply(w ResponseWriter, req *Msg, orig *Msg, rule *policyRule, trigger int) error {
+	q := req.Question[0]
+	p.log(w, req, rule, trigger)
+	m := new(Msg)
+	switch rule.action {
+	case PolicyDrop:
+		return nil
+	case PolicyPassthru:
+		if orig != nil {
+			return w.WriteMsg(orig)
+		}
+		return nil
+	case PolicyNxdomain:
+		m.SetRcode(req, RcodeNameError)
+	case PolicyNodata:
+		m.SetReply(req)
+	case PolicyLocalData:
+		m.SetReply(req)
+		for _, r := range rule.data {
+			t := r.Header().Rrtype
+			if t == q.Qtype || t == TypeCNAME || q.Qtype == TypeANY {
+				r1 := r.Copy()
+				r1.Header().Name = q.Name
+				m.Answer = append(m.Answer, r1)
+			}
+		}
+	}
+	m.RecursionAvailable = true
+	return w.WriteMsg(m)
+}
@@ -0,0 +1,113 @@
+package dns
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+// testWriter is a ResponseWriter that records the message written.
+type testWriter struct {
+	msg *Msg
+}
+
+func (w *testWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53000}
+}
+func (w *testWriter) WriteMsg(m *Msg) error       { w.msg = m; return nil }
+func (w *testWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *testWriter) Close() error                { return nil }
+func (w *testWriter) TsigStatus() error           { return nil }
+func (w *testWriter) TsigTimersOnly(bool)         {}
+func (w *testWriter) Hijack()                     {}
+
+const rpzZone = `$ORIGIN rpz.example.org.
+@                   SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 60
+                    NS  ns.example.org.
+bad.example.com     CNAME .
+*.bad.example.com   CNAME .
+nodata.example.com  CNAME *.
+drop.example.com    CNAME rpz-drop.
+ok.bad.example.com  CNAME rpz-passthru.
+garden.example.com  CNAME walled.example.org.
+local.example.com   A   10.0.0.1
+24.0.2.0.192.rpz-ip CNAME .
+`
+
+func rpzAnswer(w ResponseWriter, req *Msg) {
+	m := new(Msg)
+	m.SetReply(req)
+	a, _ := NewRR(req.Question[0].Name + " IN A 192.0.2.53")
+	m.Answer = append(m.Answer, a)
+	w.WriteMsg(m)
+}
+
+func TestRPZ(t *testing.T) {
+	p := NewRPZ("rpz.example.org.")
+	if err := p.ReadZone(strings.NewReader(rpzZone), ""); err != nil {
+		t.Fatalf("failed to read policy zone: %s", err.Error())
+	}
+	hits := 0
+	p.Log = func(h *PolicyHit) { hits++ }
+	h := p.Wrap(HandlerFunc(rpzAnswer))
+
+	tests := map[string]int{
+		"bad.example.com.":      RcodeNameError,
+		"www.bad.example.com.":  RcodeNameError,
+		"nodata.example.com.":   RcodeSuccess,
+		"ok.bad.example.com.":   RcodeSuccess, // passthru exempts from IP triggers
+		"local.example.com.":    RcodeSuccess,
+		"garden.example.com.":   RcodeSuccess,
+		"www.good.example.com.": RcodeNameError, // answer is in 192.0.2.0/24
+	}
+	for name, rcode := range tests {
+		w := new(testWriter)
+		m := new(Msg)
+		m.SetQuestion(name, TypeA)
+		h.ServeDNS(w, m)
+		if w.msg == nil {
+			t.Errorf("%s: no reply", name)
+			continue
+		}
+		if w.msg.Rcode != rcode {
+			t.Errorf("%s: rcode should be %d, not %d", name, rcode, w.msg.Rcode)
+		}
+	}
+	if hits != len(tests) {
+		t.Errorf("expected %d policy hits, got %d", len(tests), hits)
+	}
+
+	w := new(testWriter)
+	m := new(Msg)
+	m.SetQuestion("drop.example.com.", TypeA)
+	h.ServeDNS(w, m)
+	if w.msg != nil {
+		t.Error("drop.example.com. should not be answered")
+	}
+
+	m.SetQuestion("local.example.com.", TypeA)
+	h.ServeDNS(w, m)
+	if len(w.msg.Answer) != 1 || w.msg.Answer[0].(*A).A.String() != "10.0.0.1" {
+		t.Errorf("local.example.com. should have local data, got %v", w.msg)
+	}
+	m.SetQuestion("garden.example.com.", TypeA)
+	h.ServeDNS(w, m)
+	if len(w.msg.Answer) != 1 || w.msg.Answer[0].(*CNAME).Target != "walled.example.org." {
+		t.Errorf("garden.example.com. should be a CNAME, got %v", w.msg)
+	}
+}
+
+func TestRPZIP(t *testing.T) {
+	tests := map[string]string{
+		"32.1.0.0.10":       "10.0.0.1/32",
+		"24.0.2.0.192":      "192.0.2.0/24",
+		"128.1.zz.db8.2001": "2001:db8::1/128",
+		"48.zz.db8.2001":    "2001:db8::/48",
+	}
+	for in, out := range tests {
+		n := parseRPZIP(SplitLabels(in))
+		if n == nil || n.String() != out {
+			t.Errorf("%s should parse to %s, got %v", in, out, n)
+		}
+	}
+}
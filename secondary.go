@@ -0,0 +1,211 @@
+// SECONDARY ZONES
+//
+// A Secondary keeps a copy of a zone that is transferred from one or more
+// masters. On each Refresh all masters are asked for their SOA serial; the
+// zone is transferred from the master with the highest serial, falling over
+// to the next one if the transfer fails. Serials are compared using the
+// serial number arithmetic from RFC 1982. Each master can have its own TSIG key.
+//
+// Basic use pattern:
+//
+//	s := dns.NewSecondary("example.org.",
+//		&dns.Master{Addr: "192.0.2.1:53", TsigName: "axfr.", TsigSecret: "so6ZGir4GPAqINNh9U5c3A=="},
+//		&dns.Master{Addr: "192.0.2.2:53"})
+//	if _, err := s.Refresh(); err != nil {
+//		// all masters failed
+//	}
+//	z := s.Zone()
+package dns
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SerialLess returns true when serial s1 is less than s2, using serial
+// number arithmetic (RFC 1982). For serials that are 2^31 apart, the result
+// is undefined; false is returned.
+func SerialLess(s1, s2 uint32) bool {
+	return (s1 < s2 && s2-s1 < 1<<31) || (s1 > s2 && s1-s2 > 1<<31)
+}
+
+// Master is a master name server for a Secondary.
+type Master struct {
+	Addr       string // Address of the master, host:port
+	TsigName   string // If not empty, the name of the TSIG key, must be fully qualified
+	TsigSecret string // The base64 TSIG secret
+	TsigAlgo   string // TSIG algorithm, defaults to HmacMD5
+}
+
+// client returns a Client configured for querying m.
+func (m *Master) client(net string) *Client {
+	c := &Client{Net: net}
+	if m.TsigName != "" {
+		c.TsigSecret = map[string]string{m.TsigName: m.TsigSecret}
+	}
+	return c
+}
+
+// sign adds a TSIG RR to q if m has a TSIG key.
+func (m *Master) sign(q *Msg) *Msg {
+	if m.TsigName != "" {
+		algo := m.TsigAlgo
+		if algo == "" {
+			algo = HmacMD5
+		}
+		q.SetTsig(m.TsigName, algo, 300, time.Now().Unix())
+	}
+	return q
+}
+
+// Secondary is a secondary (slave) zone. It's safe for concurrent use by
+// multiple goroutines.
+type Secondary struct {
+	Origin    string         // Origin of the zone
+	Masters   []*Master      // The masters to transfer the zone from
+	XfrFilter func(rr RR) RR // If not nil, applied to the transferred RRs before they are inserted, see Client.XfrFilter
+	zone      *Zone
+	serial    uint32
+	*sync.RWMutex
+}
+
+// NewSecondary returns a Secondary for the zone origin, which is
+// transferred from masters. The zone is empty until Refresh is called.
+func NewSecondary(origin string, masters ...*Master) *Secondary {
+	s := new(Secondary)
+	s.Origin = Fqdn(origin)
+	s.Masters = masters
+	s.RWMutex = new(sync.RWMutex)
+	return s
+}
+
+// Zone returns the most recently transferred zone, or nil if the zone has
+// not been transferred yet.
+func (s *Secondary) Zone() *Zone {
+	s.RLock()
+	defer s.RUnlock()
+	return s.zone
+}
+
+// Serial returns the serial of the most recently transferred zone.
+func (s *Secondary) Serial() uint32 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.serial
+}
+
+type masterSerial struct {
+	master *Master
+	serial uint32
+	index  int // position in Masters, to prefer the first master on equal serials
+}
+
+type masterSerials []masterSerial
+
+func (p masterSerials) Len() int { return len(p) }
+func (p masterSerials) Less(i, j int) bool {
+	if p[i].serial == p[j].serial {
+		return p[i].index < p[j].index
+	}
+	return SerialLess(p[j].serial, p[i].serial)
+}
+func (p masterSerials) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// Refresh checks the serial of the zone on all masters and transfers
+// the zone when one of them has a newer serial than the current zone. The
+// zone is transferred from the master with the highest serial, if that fails
+// the master with the next highest serial is used, etc. It returns true when
+// the zone was transferred. The error of the last failed master is
+// returned when no master could be used.
+func (s *Secondary) Refresh() (bool, error) {
+	var (
+		serials masterSerials
+		err     error
+	)
+	for i, m := range s.Masters {
+		serial, e := m.soaSerial(s.Origin)
+		if e != nil {
+			err = e
+			continue
+		}
+		serials = append(serials, masterSerial{m, serial, i})
+	}
+	if len(serials) == 0 {
+		if err == nil {
+			err = &Error{Err: "no masters", Name: s.Origin}
+		}
+		return false, err
+	}
+	sort.Sort(serials)
+	s.RLock()
+	current, zone := s.serial, s.zone
+	s.RUnlock()
+	for _, ms := range serials {
+		if zone != nil && !SerialLess(current, ms.serial) {
+			// Sorted, so the next ones won't be newer either
+			return false, nil
+		}
+		z, serial, e := ms.master.transfer(s.Origin, s.XfrFilter)
+		if e != nil {
+			err = e
+			continue
+		}
+		s.Lock()
+		s.zone, s.serial = z, serial
+		s.Unlock()
+		return true, nil
+	}
+	return false, err
+}
+
+// soaSerial returns the serial of the SOA record of origin on master m.
+func (m *Master) soaSerial(origin string) (uint32, error) {
+	q := new(Msg)
+	q.SetQuestion(origin, TypeSOA)
+	r, _, err := m.client("udp").Exchange(m.sign(q), m.Addr)
+	if err != nil {
+		return 0, err
+	}
+	if r.Rcode != RcodeSuccess {
+		return 0, &Error{Err: "failed to get SOA: " + RcodeToString[r.Rcode], Name: origin, Rcode: r.Rcode, kind: ErrRcode}
+	}
+	for _, rr := range r.Answer {
+		if soa, ok := rr.(*SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+	return 0, ErrSoa
+}
+
+// transfer transfers the zone origin from m using AXFR, the RRs are passed
+// through filter if it's not nil.
+func (m *Master) transfer(origin string, filter func(rr RR) RR) (*Zone, uint32, error) {
+	rrs, serial, err := m.transferRRs(origin, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	z := NewZone(origin)
+	for _, rr := range rrs {
+		if err := z.Insert(rr); err != nil {
+			return nil, 0, err
+		}
+	}
+	return z, serial, nil
+}
+
+// transferRRs transfers the zone origin from m using AXFR and returns
+// the RRs, without the closing SOA record. A transfer that ends before the
+// closing SOA record fails with ErrIncomplete, so Refresh starts again with
+// the next master.
+func (m *Master) transferRRs(origin string, filter func(rr RR) RR) ([]RR, uint32, error) {
+	q := new(Msg)
+	q.SetAxfr(origin)
+	c := m.client("tcp")
+	c.XfrFilter = filter
+	t, err := c.TransferIn(m.sign(q), m.Addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	return readAxfr(t, origin)
+}
@@ -0,0 +1,100 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSerialLess(t *testing.T) {
+	tests := []struct {
+		s1, s2 uint32
+		less   bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{1, 1, false},
+		{0xFFFFFFFF, 0, true}, // wraps around
+		{0, 0xFFFFFFFF, false},
+		{0xFFFFFF00, 0x100, true},
+		{0, 1<<31 - 1, true},
+		{0, 1 << 31, false}, // undefined
+		{1 << 31, 0, false},
+	}
+	for _, tt := range tests {
+		if SerialLess(tt.s1, tt.s2) != tt.less {
+			t.Errorf("SerialLess(%d, %d) should be %t", tt.s1, tt.s2, tt.less)
+		}
+	}
+}
+
+// startMaster starts a master for z on UDP and TCP on the same port of
+// 127.0.0.1 and returns its address. Its answer to a SOA query has serial;
+// when broken is true it cuts off the transfers after the first message.
+func startMaster(t *testing.T, z *Zone, serial uint32, broken bool) string {
+	soa := z.Apex().RR[TypeSOA][0].Copy().(*SOA)
+	soa.Serial = serial
+	h := HandlerFunc(func(w ResponseWriter, req *Msg) {
+		if req.Question[0].Qtype == TypeAXFR && !broken {
+			z.TransferOut(w, req)
+			return
+		}
+		m := new(Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, soa)
+		w.WriteMsg(m)
+		if broken {
+			w.Close()
+		}
+	})
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: l.Addr().(*net.TCPAddr).Port})
+	if err != nil {
+		l.Close()
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	go (&Server{Handler: h}).serveTCP(l)
+	go (&Server{Handler: h}).serveUDP(u)
+	return l.Addr().String()
+}
+
+func TestSecondaryFailover(t *testing.T) {
+	z := NewZone("example.org.")
+	for _, s := range []string{"example.org. 3600 SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300",
+		"example.org. 3600 NS ns.example.org.", "www.example.org. 300 A 192.0.2.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	// The master with the highest serial is tried first, its transfer fails
+	broken := startMaster(t, z, 2, true)
+	good := startMaster(t, z, 1, false)
+
+	s := NewSecondary("example.org", &Master{Addr: good}, &Master{Addr: broken})
+	ok, err := s.Refresh()
+	if err != nil || !ok {
+		t.Fatalf("expected the zone to be transferred from %s, got %t, %v", good, ok, err)
+	}
+	if s.Serial() != 1 {
+		t.Errorf("expected serial 1, got %d", s.Serial())
+	}
+	if s.Zone() == nil {
+		t.Fatal("no zone after the transfer")
+	}
+	if _, exact := s.Zone().Find("www.example.org."); !exact {
+		t.Error("transferred zone misses www.example.org.")
+	}
+
+	// Serial 1 isn't newer, so nothing is transferred
+	s.Masters = s.Masters[:1]
+	if ok, err := s.Refresh(); ok || err != nil {
+		t.Errorf("expected no transfer, got %t, %v", ok, err)
+	}
+
+	// All transfers fail
+	s = NewSecondary("example.org.", &Master{Addr: broken})
+	if ok, err := s.Refresh(); ok || err == nil || s.Zone() != nil {
+		t.Errorf("expected the transfer to fail, got %t, %v", ok, err)
+	}
+}
@@ -0,0 +1,218 @@
+package dns
+
+// The XSalsa20Poly1305 secretbox and the crypto_box_curve25519xsalsa20poly1305
+// key agreement of NaCl, as used by DNSCrypt. These are not in the standard
+// library.
+
+import (
+	"crypto/ecdh"
+	"crypto/subtle"
+	"encoding/binary"
+	"math/bits"
+)
+
+const secretboxOverhead = 16 // the Poly1305 tag
+
+// The "expand 32-byte k" constant of Salsa20.
+var salsaSigma = [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+
+// salsaRounds applies the 20 rounds of Salsa20 to x.
+func salsaRounds(x *[16]uint32) {
+	qr := func(a, b, c, d int) {
+		x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+		x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+		x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+		x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+	}
+	for i := 0; i < 10; i++ {
+		// Columns
+		qr(0, 4, 8, 12)
+		qr(5, 9, 13, 1)
+		qr(10, 14, 2, 6)
+		qr(15, 3, 7, 11)
+		// Rows
+		qr(0, 1, 2, 3)
+		qr(5, 6, 7, 4)
+		qr(10, 11, 8, 9)
+		qr(15, 12, 13, 14)
+	}
+}
+
+// salsaState returns the initial Salsa20 state for key and the 16 bytes in
+// input (nonce and block counter).
+func salsaState(key *[32]byte, input []byte) (x [16]uint32) {
+	x[0], x[5], x[10], x[15] = salsaSigma[0], salsaSigma[1], salsaSigma[2], salsaSigma[3]
+	for i := 0; i < 4; i++ {
+		x[1+i] = binary.LittleEndian.Uint32(key[4*i:])
+		x[11+i] = binary.LittleEndian.Uint32(key[16+4*i:])
+		x[6+i] = binary.LittleEndian.Uint32(input[4*i:])
+	}
+	return x
+}
+
+// hsalsa20 derives a subkey from key and the 16 byte nonce.
+func hsalsa20(key *[32]byte, nonce []byte) (out [32]byte) {
+	x := salsaState(key, nonce)
+	salsaRounds(&x)
+	for i, w := range []uint32{x[0], x[5], x[10], x[15], x[6], x[7], x[8], x[9]} {
+		binary.LittleEndian.PutUint32(out[4*i:], w)
+	}
+	return out
+}
+
+// xsalsa20XOR sets out to in XOR the XSalsa20 key stream for key and the 24
+// byte nonce. Out and in may be the same slice.
+func xsalsa20XOR(out, in []byte, key *[32]byte, nonce []byte) {
+	subkey := hsalsa20(key, nonce[:16])
+	input := make([]byte, 16)
+	copy(input, nonce[16:24])
+	var block [64]byte
+	for counter := uint64(0); len(in) > 0; counter++ {
+		binary.LittleEndian.PutUint64(input[8:], counter)
+		x := salsaState(&subkey, input)
+		y := x
+		salsaRounds(&y)
+		for i := range y {
+			binary.LittleEndian.PutUint32(block[4*i:], y[i]+x[i])
+		}
+		n := subtle.XORBytes(out, in, block[:])
+		out, in = out[n:], in[n:]
+	}
+}
+
+// poly1305 returns the Poly1305 tag of msg with the one-time key. The
+// arithmetic is done on 26 bit limbs (as in poly1305-donna), without
+// branches or table lookups that depend on the key or the message, so it
+// runs in constant time.
+func poly1305(msg []byte, key *[32]byte) (tag [16]byte) {
+	const mask = 1<<26 - 1
+	le := binary.LittleEndian.Uint32
+	r0 := le(key[0:]) & 0x3ffffff
+	r1 := (le(key[3:]) >> 2) & 0x3ffff03
+	r2 := (le(key[6:]) >> 4) & 0x3ffc0ff
+	r3 := (le(key[9:]) >> 6) & 0x3f03fff
+	r4 := (le(key[12:]) >> 8) & 0x00fffff
+	s1, s2, s3, s4 := r1*5, r2*5, r3*5, r4*5
+
+	var h0, h1, h2, h3, h4 uint32
+	var block [16]byte
+	for len(msg) > 0 {
+		hibit := uint32(1 << 24) // the 1 bit after the 16 bytes
+		m := msg
+		if len(msg) < 16 {
+			// A short final block is padded with a 1 byte and zeros
+			block = [16]byte{}
+			copy(block[:], msg)
+			block[len(msg)] = 1
+			m, hibit = block[:], 0
+		}
+		h0 += le(m[0:]) & mask
+		h1 += (le(m[3:]) >> 2) & mask
+		h2 += (le(m[6:]) >> 4) & mask
+		h3 += (le(m[9:]) >> 6) & mask
+		h4 += (le(m[12:]) >> 8) | hibit
+
+		// h *= r, modulo 2^130 - 5
+		d0 := uint64(h0)*uint64(r0) + uint64(h1)*uint64(s4) + uint64(h2)*uint64(s3) + uint64(h3)*uint64(s2) + uint64(h4)*uint64(s1)
+		d1 := uint64(h0)*uint64(r1) + uint64(h1)*uint64(r0) + uint64(h2)*uint64(s4) + uint64(h3)*uint64(s3) + uint64(h4)*uint64(s2)
+		d2 := uint64(h0)*uint64(r2) + uint64(h1)*uint64(r1) + uint64(h2)*uint64(r0) + uint64(h3)*uint64(s4) + uint64(h4)*uint64(s3)
+		d3 := uint64(h0)*uint64(r3) + uint64(h1)*uint64(r2) + uint64(h2)*uint64(r1) + uint64(h3)*uint64(r0) + uint64(h4)*uint64(s4)
+		d4 := uint64(h0)*uint64(r4) + uint64(h1)*uint64(r3) + uint64(h2)*uint64(r2) + uint64(h3)*uint64(r1) + uint64(h4)*uint64(r0)
+		d1 += d0 >> 26
+		d2 += d1 >> 26
+		d3 += d2 >> 26
+		d4 += d3 >> 26
+		h0, h1, h2, h3, h4 = uint32(d0)&mask, uint32(d1)&mask, uint32(d2)&mask, uint32(d3)&mask, uint32(d4)&mask
+		h0 += uint32(d4>>26) * 5
+		h1 += h0 >> 26
+		h0 &= mask
+
+		if len(msg) < 16 {
+			break
+		}
+		msg = msg[16:]
+	}
+
+	// Fully carry h
+	h2 += h1 >> 26
+	h1 &= mask
+	h3 += h2 >> 26
+	h2 &= mask
+	h4 += h3 >> 26
+	h3 &= mask
+	h0 += (h4 >> 26) * 5
+	h4 &= mask
+	h1 += h0 >> 26
+	h0 &= mask
+
+	// g = h - p = h + 5 - 2^130, use it when it's not negative
+	g0 := h0 + 5
+	g1 := h1 + g0>>26
+	g0 &= mask
+	g2 := h2 + g1>>26
+	g1 &= mask
+	g3 := h3 + g2>>26
+	g2 &= mask
+	g4 := h4 + g3>>26 - 1<<26
+	g3 &= mask
+	sel := (g4 >> 31) - 1 // all ones when g4 is not negative
+	h0 = h0&^sel | g0&sel
+	h1 = h1&^sel | g1&sel
+	h2 = h2&^sel | g2&sel
+	h3 = h3&^sel | g3&sel
+	h4 = h4&^sel | g4&sel
+
+	// h += s, modulo 2^128
+	f := uint64(h0|h1<<26) + uint64(le(key[16:]))
+	binary.LittleEndian.PutUint32(tag[0:], uint32(f))
+	f = uint64(h1>>6|h2<<20) + uint64(le(key[20:])) + f>>32
+	binary.LittleEndian.PutUint32(tag[4:], uint32(f))
+	f = uint64(h2>>12|h3<<14) + uint64(le(key[24:])) + f>>32
+	binary.LittleEndian.PutUint32(tag[8:], uint32(f))
+	f = uint64(h3>>18|h4<<8) + uint64(le(key[28:])) + f>>32
+	binary.LittleEndian.PutUint32(tag[12:], uint32(f))
+	return tag
+}
+
+// secretboxSeal encrypts and authenticates msg with key and the 24 byte
+// nonce, the result is the tag followed by the cipher text.
+func secretboxSeal(msg []byte, key *[32]byte, nonce []byte) []byte {
+	buf := make([]byte, 32+len(msg))
+	copy(buf[32:], msg)
+	xsalsa20XOR(buf, buf, key, nonce)
+	var polyKey [32]byte
+	copy(polyKey[:], buf[:32])
+	tag := poly1305(buf[32:], &polyKey)
+	copy(buf[16:32], tag[:])
+	return buf[16:]
+}
+
+// secretboxOpen authenticates and decrypts box, sealed by secretboxSeal.
+func secretboxOpen(box []byte, key *[32]byte, nonce []byte) ([]byte, error) {
+	if len(box) < secretboxOverhead {
+		return nil, ErrAuth
+	}
+	buf := make([]byte, 32+len(box)-secretboxOverhead)
+	copy(buf[32:], box[secretboxOverhead:])
+	xsalsa20XOR(buf, buf, key, nonce)
+	var polyKey [32]byte
+	copy(polyKey[:], buf[:32])
+	tag := poly1305(box[secretboxOverhead:], &polyKey)
+	if subtle.ConstantTimeCompare(tag[:], box[:secretboxOverhead]) != 1 {
+		return nil, ErrAuth
+	}
+	return buf[32:], nil
+}
+
+// boxKey returns the shared key of crypto_box for the private key priv and
+// the peer's public key pub.
+func boxKey(priv *ecdh.PrivateKey, pub *ecdh.PublicKey) (*[32]byte, error) {
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	var k [32]byte
+	copy(k[:], shared)
+	k = hsalsa20(&k, make([]byte, 16))
+	return &k, nil
+}
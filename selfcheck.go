@@ -0,0 +1,176 @@
+// SELF CHECKS
+//
+// A SelfCheck checks the health of an authoritative server from the inside:
+// the server's own listeners are asked for the SOA of each zone, the answers
+// must be authoritative and all listeners must agree on the serial. When
+// masters are given for a zone, the serial must not be behind the highest
+// serial of the masters. This is meant for health checking the members of an
+// anycast fleet, a member that fails its checks should stop announcing the
+// anycast prefix. Basic use pattern:
+//
+//	s := dns.NewSelfCheck("127.0.0.1:53", "[::1]:53")
+//	s.AddZone("example.org.", &dns.Master{Addr: "192.0.2.1:53"})
+//	stop := s.Run(time.Minute)
+//	if !s.Healthy() {
+//		// withdraw the route
+//	}
+package dns
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// ZoneStatus is the result of checking a zone.
+type ZoneStatus struct {
+	Origin       string
+	Checked      time.Time         // When the zone was checked
+	Serials      map[string]uint32 // Serial per listener that answered
+	MasterSerial uint32            // Highest serial of the masters, if any answered
+	Errors       map[string]error  // Errors per listener or master address
+	Healthy      bool              // The listeners answered, agree on the serial and are not behind the masters
+}
+
+// SelfCheck checks the zones served by the server on its listeners. It's
+// safe for concurrent use by multiple goroutines.
+type SelfCheck struct {
+	Listeners []string // Addresses of the listeners, host:port
+	Net       string   // Network used for the queries, defaults to "udp"
+	zones     map[string][]*Master
+	status    map[string]*ZoneStatus
+	*sync.RWMutex
+}
+
+// NewSelfCheck returns a SelfCheck for the listeners.
+func NewSelfCheck(listeners ...string) *SelfCheck {
+	return &SelfCheck{Listeners: listeners, zones: make(map[string][]*Master),
+		status: make(map[string]*ZoneStatus), RWMutex: new(sync.RWMutex)}
+}
+
+// AddZone adds the zone origin to the zones that are checked, its serial is
+// compared with the serials of masters.
+func (s *SelfCheck) AddZone(origin string, masters ...*Master) {
+	s.Lock()
+	s.zones[Fqdn(strings.ToLower(origin))] = masters
+	s.Unlock()
+}
+
+// RemoveZone removes the zone origin from the zones that are checked.
+func (s *SelfCheck) RemoveZone(origin string) {
+	origin = Fqdn(strings.ToLower(origin))
+	s.Lock()
+	delete(s.zones, origin)
+	delete(s.status, origin)
+	s.Unlock()
+}
+
+// Check checks all zones now.
+func (s *SelfCheck) Check() {
+	s.RLock()
+	zones := make(map[string][]*Master, len(s.zones))
+	for origin, masters := range s.zones {
+		zones[origin] = masters
+	}
+	s.RUnlock()
+	for origin, masters := range zones {
+		st := s.check(origin, masters)
+		s.Lock()
+		if _, ok := s.zones[origin]; ok {
+			s.status[origin] = st
+		}
+		s.Unlock()
+	}
+}
+
+func (s *SelfCheck) check(origin string, masters []*Master) *ZoneStatus {
+	st := &ZoneStatus{Origin: origin, Checked: time.Now(), Serials: make(map[string]uint32),
+		Errors: make(map[string]error)}
+	c := &Client{Net: s.Net}
+	q := new(Msg)
+	q.SetQuestion(origin, TypeSOA)
+	for _, l := range s.Listeners {
+		r, _, err := c.Exchange(q, l)
+		switch {
+		case err != nil:
+			st.Errors[l] = err
+		case r.Rcode != RcodeSuccess:
+			st.Errors[l] = &Error{Err: "failed to get SOA: " + RcodeToString[r.Rcode], Name: origin, Rcode: r.Rcode, kind: ErrRcode}
+		case !r.Authoritative:
+			st.Errors[l] = &Error{Err: "not authoritative", Name: origin}
+		default:
+			st.Errors[l] = ErrSoa
+			for _, rr := range r.Answer {
+				if soa, ok := rr.(*SOA); ok {
+					st.Serials[l] = soa.Serial
+					delete(st.Errors, l)
+					break
+				}
+			}
+		}
+	}
+	answered := false
+	for _, m := range masters {
+		serial, err := m.soaSerial(origin)
+		if err != nil {
+			st.Errors[m.Addr] = err
+			continue
+		}
+		if !answered || SerialLess(st.MasterSerial, serial) {
+			st.MasterSerial = serial
+		}
+		answered = true
+	}
+	st.Healthy = len(st.Serials) == len(s.Listeners) && len(st.Serials) > 0
+	for _, serial := range st.Serials {
+		for _, serial1 := range st.Serials {
+			st.Healthy = st.Healthy && serial == serial1
+		}
+		st.Healthy = st.Healthy && !(answered && SerialLess(serial, st.MasterSerial))
+	}
+	return st
+}
+
+// Status returns the results of the last check of each zone, keyed on the
+// origin. Zones that have not been checked yet are not included.
+func (s *SelfCheck) Status() map[string]*ZoneStatus {
+	s.RLock()
+	defer s.RUnlock()
+	status := make(map[string]*ZoneStatus, len(s.status))
+	for origin, st := range s.status {
+		status[origin] = st
+	}
+	return status
+}
+
+// Healthy returns true when all zones have been checked and are healthy.
+func (s *SelfCheck) Healthy() bool {
+	s.RLock()
+	defer s.RUnlock()
+	for origin, _ := range s.zones {
+		if st, ok := s.status[origin]; !ok || !st.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Run calls Check every d, the first time right away. Send true on the
+// returned channel to stop.
+func (s *SelfCheck) Run(d time.Duration) chan bool {
+	stop := make(chan bool)
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		s.Check()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				s.Check()
+			}
+		}
+	}()
+	return stop
+}
@@ -7,9 +7,16 @@
 package dns
 
 import (
+	"crypto/tls"
+	"errors"
 	"github.com/miekg/radix"
+	"io"
+	"log"
 	"net"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,9 +29,10 @@ type Handler interface {
 type ResponseWriter interface {
 	// RemoteAddr returns the net.Addr of the client that sent the current request.
 	RemoteAddr() net.Addr
-	// WriteMsg writes a reply back to the client.
+	// WriteMsg writes a reply back to the client. When the reply can't be
+	// packed or sent, a *WriteError is returned.
 	WriteMsg(*Msg) error
-	// Write writes a raw buffer back to the client.
+	// Write writes a raw buffer back to the client, errors are of type *WriteError.
 	Write([]byte) (int, error)
 	// Close closes the connection.
 	Close() error
@@ -37,15 +45,75 @@ type ResponseWriter interface {
 	Hijack()
 }
 
+// A RequestInfoWriter is a ResponseWriter that knows how the request was
+// received. The ResponseWriters of the Server, and those of the handler
+// wrappers in this package, implement it; handlers type-assert for it:
+//
+//	if w, ok := w.(dns.RequestInfoWriter); ok {
+//		info := w.RequestInfo()
+//		...
+//	}
+type RequestInfoWriter interface {
+	ResponseWriter
+	// RequestInfo returns the request as it was received, or nil when that
+	// isn't known.
+	RequestInfo() *RequestInfo
+}
+
+// requestInfo returns the RequestInfo of w, or nil when w has none.
+func requestInfo(w ResponseWriter) *RequestInfo {
+	if w, ok := w.(RequestInfoWriter); ok {
+		return w.RequestInfo()
+	}
+	return nil
+}
+
+// RequestInfo holds what the server knows about a request besides the
+// message itself: the original wire format (to verify TSIG or SIG(0)
+// signatures, or for dnstap), when it arrived and over what transport.
+// The handler must not modify Wire.
+type RequestInfo struct {
+	Wire     []byte    // the request as received
+	Received time.Time // when the request was read
+	Net      string    // "udp", "tcp" or "quic"
+}
+
 type response struct {
 	hijacked       bool // connection has been hijacked by handler
+	written        bool // a reply has been written
 	tsigStatus     error
 	tsigTimersOnly bool
 	tsigRequestMAC string
 	tsigSecret     map[string]string // the tsig secrets
+	tcpKeepalive   uint16            // if not zero, the edns-tcp-keepalive timeout to send back
+	udpSize        int               // if not zero, the maximum size of a UDP reply, larger replies are truncated
+	maxUDPSize     int               // the largest EDNS0 buffer size to advertise
 	_UDP           *net.UDPConn      // i/o connection if UDP was used
 	_TCP           *net.TCPConn      // i/o connection if TCP was used
+	_QUIC          *quicStream       // i/o stream if DNS over QUIC was used
 	remoteAddr     net.Addr          // address of the client
+	onError        func(net.Addr, error)
+	info           RequestInfo
+}
+
+// A WriteError is returned by the ResponseWriter when a reply could not be
+// (completely) sent to the client. Op is "pack" when the message could not
+// be converted to wire format, "tsig" when it could not be signed and "write"
+// when sending failed. In the last case Err is ErrShortWrite, ErrMsgTooLarge
+// or the error from the connection.
+type WriteError struct {
+	Op   string   // "pack", "tsig" or "write"
+	Addr net.Addr // address of the client
+	N    int      // bytes of the reply that were written
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	s := "dns: " + e.Op
+	if e.Addr != nil {
+		s += " reply to " + e.Addr.String()
+	}
+	return s + ": " + e.Err.Error()
 }
 
 // ServeMux is an DNS request multiplexer. It matches the
@@ -54,10 +122,52 @@ type response struct {
 // that most closely matches the zone name. ServeMux is DNSSEC aware, meaning
 // that queries for the DS record are redirected to the parent zone (if that
 // is also registered), otherwise the child gets the query.
-// ServeMux is also safe for concurrent access from multiple goroutines.
+// Handlers registered for a pattern and a type with HandleType take precedence
+// over the handler of the pattern. Handlers registered with HandleMatch and
+// HandleMatchPriority are tried before any pattern. ServeMux is also safe for concurrent access from multiple goroutines.
+//
+// A pattern "*.example.org." is a wildcard: it matches the names below
+// example.org., but not example.org. itself, and takes precedence over the
+// handler of example.org. for them. To register a name that has a literal
+// asterisk as its first label, escape it: "\*.example.org.". Names are
+// compared case insensitively and after undoing escapes, internationalized
+// names match in both their Unicode and their ACE ("xn--") form.
+//
+// A parent zone can post-process the replies of the handlers below it with
+// Intercept, for instance to enforce policies or to log.
 type ServeMux struct {
-	r *radix.Radix
-	m *sync.RWMutex
+	r        *radix.Radix
+	m        *sync.RWMutex
+	matches  []muxMatch
+	notFound Handler // if nil, SERVFAIL is returned
+}
+
+type muxMatch struct {
+	qm       *QuestionMatcher
+	h        Handler
+	priority int
+}
+
+// muxEntry holds the handlers registered for a pattern.
+type muxEntry struct {
+	h     Handler            // may be nil
+	types map[uint16]Handler // type specific handlers
+	wild  *muxEntry          // handlers of the wildcard below the pattern, may be nil
+
+	intercept []func(Handler) Handler
+}
+
+// empty returns true when e has no handlers and no interceptors.
+func (e *muxEntry) empty() bool {
+	return e.h == nil && len(e.types) == 0 && len(e.intercept) == 0
+}
+
+// handler returns the handler for type t, or nil.
+func (e *muxEntry) handler(t uint16) Handler {
+	if h, ok := e.types[t]; ok {
+		return h
+	}
+	return e.h
 }
 
 // NewServeMux allocates and returns a new ServeMux.
@@ -92,6 +202,15 @@ func HandleFailed(w ResponseWriter, r *Msg) {
 	w.WriteMsg(m)
 }
 
+// HandleRefused returns REFUSED for every request it gets. An
+// authoritative server uses it for zones it doesn't serve, see
+// HandleNotFound.
+func HandleRefused(w ResponseWriter, r *Msg) {
+	m := new(Msg)
+	m.SetRcode(r, RcodeRefused)
+	w.WriteMsg(m)
+}
+
 // AuthorHandler returns a HandlerFunc that returns the authors
 // of Go DNS for 'authors.bind' or 'authors.server' queries in the
 // CHAOS Class. Note with:
@@ -154,6 +273,147 @@ func HandleVersion(w ResponseWriter, r *Msg) {
 	w.WriteMsg(m)
 }
 
+// How queries for QTYPE=ANY are answered, see RFC 8482.
+const (
+	AnyFull   = iota // Give the full answer
+	AnyHinfo         // Answer with a synthesized HINFO record
+	AnyRRset         // Answer with only one RRset (and its signatures) from the full answer
+)
+
+// MinimizeAny returns a Handler that minimizes the answers to QTYPE=ANY
+// queries handled by h, as described in RFC 8482. The mode is one of
+// AnyFull, AnyHinfo or AnyRRset. With AnyHinfo h is not called for
+// ANY queries. To use this for a single zone, register the
+// handler for the zone with:
+//
+//	dns.Handle("example.org.", dns.MinimizeAny(handler, dns.AnyRRset))
+func MinimizeAny(h Handler, mode int) Handler {
+	if mode == AnyFull {
+		return h
+	}
+	return HandlerFunc(func(w ResponseWriter, r *Msg) {
+		if len(r.Question) != 1 || r.Question[0].Qtype != TypeANY {
+			h.ServeDNS(w, r)
+			return
+		}
+		if mode == AnyHinfo {
+			m := new(Msg)
+			m.SetReply(r)
+			hdr := RR_Header{r.Question[0].Name, TypeHINFO, ClassINET, 3600, 0}
+			m.Answer = append(m.Answer, &HINFO{hdr, "RFC8482", ""})
+			w.WriteMsg(m)
+			return
+		}
+		h.ServeDNS(&anyWriter{w}, r)
+	})
+}
+
+// anyWriter trims the answer of an ANY query to a single RRset.
+type anyWriter struct {
+	ResponseWriter
+}
+
+func (w *anyWriter) WriteMsg(m *Msg) error {
+	var keep RR
+	for _, r := range m.Answer {
+		if r.Header().Rrtype != TypeRRSIG {
+			keep = r
+			break
+		}
+	}
+	if keep == nil {
+		return w.ResponseWriter.WriteMsg(m)
+	}
+	k := keep.Header()
+	answer := make([]RR, 0, len(m.Answer))
+	for _, r := range m.Answer {
+		h := r.Header()
+		if h.Class != k.Class || !strings.EqualFold(h.Name, k.Name) {
+			continue
+		}
+		if h.Rrtype == k.Rrtype || (h.Rrtype == TypeRRSIG && r.(*RRSIG).TypeCovered == k.Rrtype) {
+			answer = append(answer, r)
+		}
+	}
+	m.Answer = answer
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func (w *anyWriter) RequestInfo() *RequestInfo { return requestInfo(w.ResponseWriter) }
+
+// MinimizeResponses returns a Handler that trims the replies of h to what
+// is required, like minimal-responses in BIND: the NS records in the
+// authority section and the additional section are omitted from answers. The
+// authority section is kept for negative answers, and for referrals all
+// sections are kept, as the glue is needed. The NSEC and NSEC3 records that
+// prove a wildcard expansion, the OPT and TSIG records are always kept. This
+// makes replies smaller and cheaper to pack.
+func MinimizeResponses(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Msg) {
+		h.ServeDNS(&minimalWriter{w}, r)
+	})
+}
+
+// minimalWriter trims the authority and additional sections of a reply.
+type minimalWriter struct {
+	ResponseWriter
+}
+
+func (w *minimalWriter) WriteMsg(m *Msg) error {
+	if len(m.Answer) == 0 {
+		if m.Rcode == RcodeSuccess && !m.Authoritative {
+			return w.ResponseWriter.WriteMsg(m) // referral
+		}
+	} else {
+		ns := make([]RR, 0, len(m.Ns))
+		for _, r := range m.Ns {
+			switch t := r.Header().Rrtype; t {
+			case TypeRRSIG:
+				t = r.(*RRSIG).TypeCovered
+				if t != TypeNSEC && t != TypeNSEC3 {
+					continue
+				}
+			case TypeNSEC, TypeNSEC3:
+			default:
+				continue
+			}
+			ns = append(ns, r)
+		}
+		m.Ns = ns
+	}
+	extra := make([]RR, 0, 2)
+	for _, r := range m.Extra {
+		if t := r.Header().Rrtype; t == TypeOPT || t == TypeTSIG {
+			extra = append(extra, r)
+		}
+	}
+	m.Extra = extra
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func (w *minimalWriter) RequestInfo() *RequestInfo { return requestInfo(w.ResponseWriter) }
+
+// PreserveCase returns a Handler that makes the replies of h echo the exact
+// case of the query name, see Msg.CopyCase. Use it for handlers that build
+// replies from lower cased names, such as those stored in a zone.
+func PreserveCase(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Msg) {
+		h.ServeDNS(&caseWriter{w, r}, r)
+	})
+}
+
+// caseWriter copies the case of the query name into the reply.
+type caseWriter struct {
+	ResponseWriter
+	req *Msg
+}
+
+func (w *caseWriter) WriteMsg(m *Msg) error {
+	return w.ResponseWriter.WriteMsg(m.CopyCase(w.req))
+}
+
+func (w *caseWriter) RequestInfo() *RequestInfo { return requestInfo(w.ResponseWriter) }
+
 func authorHandler() Handler  { return HandlerFunc(HandleAuthors) }
 func failedHandler() Handler  { return HandlerFunc(HandleFailed) }
 func versionHandler() Handler { return HandlerFunc(HandleVersion) }
@@ -168,48 +428,203 @@ func ListenAndServe(addr string, network string, handler Handler) error {
 func (mux *ServeMux) match(zone string, t uint16) Handler {
 	mux.m.RLock()
 	defer mux.m.RUnlock()
-	if h, e := mux.r.Find(toRadixName(zone)); e {
+	h, e := mux.r.Find(toRadixName(foldName(zone)))
+	if h == nil {
+		return nil
+	}
+	if e && t == TypeDS {
 		// If we got queried for a DS record, we must see if we
-		// if we also serve the parent. We then redirect the query to it.
-		if t != TypeDS {
-			return h.Value.(Handler)
+		// if we also serve the parent. We then redirect the query to it,
+		// unless a handler is registered for the DS type itself.
+		if hd, ok := h.Value.(*muxEntry).types[TypeDS]; ok {
+			return hd
 		}
 		if d := h.Up(); d != nil {
-			return d.Value.(Handler)
+			h = d
+			e = false
 		}
 		// No parent zone found, let the original handler take care of it
-		return h.Value.(Handler)
-	} else {
-		if h == nil {
-			return nil
+	}
+	// Zones that only have type specific handlers defer the other
+	// types to the parent zone. For names below a zone its wildcard
+	// handlers come first.
+	for ; h != nil; h, e = h.Up(), false {
+		en := h.Value.(*muxEntry)
+		if !e && en.wild != nil {
+			if hd := en.wild.handler(t); hd != nil {
+				return hd
+			}
+		}
+		if hd := en.handler(t); hd != nil {
+			return hd
 		}
-		return h.Value.(Handler)
 	}
-	panic("dns: not reached")
+	return nil
 }
 
-// Handle adds a handler to the ServeMux for pattern.
-func (mux *ServeMux) Handle(pattern string, handler Handler) {
+// matchQuestion returns the handler of the first matcher matching q.
+func (mux *ServeMux) matchQuestion(q Question) Handler {
+	mux.m.RLock()
+	defer mux.m.RUnlock()
+	for _, m := range mux.matches {
+		if m.qm.Match(q) {
+			return m.h
+		}
+	}
+	return nil
+}
+
+// entry returns the muxEntry for pattern, it is created when needed. The
+// caller must hold the write lock.
+func (mux *ServeMux) entry(pattern string) *muxEntry {
 	if pattern == "" {
 		panic("dns: invalid pattern " + pattern)
 	}
+	pattern, wild := muxPattern(pattern)
+	var en *muxEntry
+	if r, e := mux.r.Find(pattern); e {
+		en = r.Value.(*muxEntry)
+	} else {
+		en = &muxEntry{types: make(map[uint16]Handler)}
+		mux.r.Insert(pattern, en)
+	}
+	if !wild {
+		return en
+	}
+	if en.wild == nil {
+		en.wild = &muxEntry{types: make(map[uint16]Handler)}
+	}
+	return en.wild
+}
+
+// muxPattern returns the radix name of pattern and whether it is a wildcard.
+func muxPattern(pattern string) (string, bool) {
+	wild := pattern == "*" || strings.HasPrefix(pattern, "*.")
+	if wild {
+		pattern = strings.TrimPrefix(pattern[1:], ".")
+	}
+	return toRadixName(foldName(pattern)), wild
+}
+
+// Handle adds a handler to the ServeMux for pattern.
+func (mux *ServeMux) Handle(pattern string, handler Handler) {
 	mux.m.Lock()
-	mux.r.Insert(toRadixName(Fqdn(pattern)), handler)
+	mux.entry(pattern).h = handler
 	mux.m.Unlock()
 }
 
+// HandleType adds a handler to the ServeMux for queries of type t for
+// pattern. It takes precedence over the handler registered with Handle for
+// pattern; when pattern only has type specific handlers, queries for other
+// types go to the handler of the closest enclosing pattern.
+func (mux *ServeMux) HandleType(pattern string, t uint16, handler Handler) {
+	mux.m.Lock()
+	mux.entry(pattern).types[t] = handler
+	mux.m.Unlock()
+}
+
+// HandleMatch adds a handler to the ServeMux for the queries matching qm,
+// with priority 0.
+func (mux *ServeMux) HandleMatch(qm *QuestionMatcher, handler Handler) {
+	mux.HandleMatchPriority(qm, 0, handler)
+}
+
+// HandleMatchPriority adds a handler to the ServeMux for the queries matching
+// qm. Matchers are tried from the highest priority to the lowest, matchers with
+// the same priority in the order they were added.
+func (mux *ServeMux) HandleMatchPriority(qm *QuestionMatcher, priority int, handler Handler) {
+	mux.m.Lock()
+	defer mux.m.Unlock()
+	i := len(mux.matches)
+	for i > 0 && mux.matches[i-1].priority < priority {
+		i--
+	}
+	mux.matches = append(mux.matches, muxMatch{})
+	copy(mux.matches[i+1:], mux.matches[i:])
+	mux.matches[i] = muxMatch{qm, handler, priority}
+}
+
 // Handle adds a handler to the ServeMux for pattern.
 func (mux *ServeMux) HandleFunc(pattern string, handler func(ResponseWriter, *Msg)) {
 	mux.Handle(pattern, HandlerFunc(handler))
 }
 
-// HandleRemove deregistrars the handler specific for pattern from the ServeMux.
+// HandleRemove deregistrars the handler specific for pattern from the ServeMux,
+// together with the type specific handlers of pattern.
 func (mux *ServeMux) HandleRemove(pattern string) {
 	if pattern == "" {
 		panic("dns: invalid pattern " + pattern)
 	}
+	pattern, wild := muxPattern(pattern)
+	mux.m.Lock()
+	defer mux.m.Unlock()
+	r, e := mux.r.Find(pattern)
+	if !e {
+		return
+	}
+	en := r.Value.(*muxEntry)
+	if w := en.wild; wild && w != nil {
+		w.h, w.types = nil, make(map[uint16]Handler)
+		if w.empty() {
+			en.wild = nil
+		}
+	} else if !wild {
+		en.h, en.types = nil, make(map[uint16]Handler)
+	}
+	if en.empty() && en.wild == nil {
+		mux.r.Remove(pattern)
+	}
+}
+
+// Intercept adds an interceptor for pattern to the ServeMux. The handler
+// that is chosen for a query for a name at or below pattern, even when
+// it's registered for a more specific pattern or with HandleMatch, is
+// wrapped by it, so the interceptor sees the query first and the reply
+// last. Use it to apply policies of a parent zone to all child zones, to
+// log, rewrite or sign replies. When more patterns match, the interceptor
+// of the least specific pattern is the outermost; the interceptors of one
+// pattern are applied in the order in which they are added, the first one
+// outermost. The Wrap methods of Rewriter, RPZ, ACL, Filter and Checker,
+// and MinimizeResponses and PreserveCase are interceptors:
+//
+//	mux.Intercept("example.org.", dns.MinimizeResponses)
+//
+// The wrap function is called for each query, it should be cheap.
+func (mux *ServeMux) Intercept(pattern string, wrap func(Handler) Handler) {
 	mux.m.Lock()
-	mux.r.Remove(toRadixName(Fqdn(pattern)))
+	en := mux.entry(pattern)
+	en.intercept = append(en.intercept, wrap)
+	mux.m.Unlock()
+}
+
+// interceptors returns the interceptors for name, from the innermost to
+// the outermost. The caller must hold the read lock.
+func (mux *ServeMux) interceptors(name string) (wraps []func(Handler) Handler) {
+	r, e := mux.r.Find(toRadixName(foldName(name)))
+	for ; r != nil; r, e = r.Up(), false {
+		en := r.Value.(*muxEntry)
+		if !e && en.wild != nil {
+			for i := len(en.wild.intercept) - 1; i >= 0; i-- {
+				wraps = append(wraps, en.wild.intercept[i])
+			}
+		}
+		for i := len(en.intercept) - 1; i >= 0; i-- {
+			wraps = append(wraps, en.intercept[i])
+		}
+	}
+	return wraps
+}
+
+// HandleNotFound sets the handler for requests that match no pattern. By
+// default these get SERVFAIL, an authoritative server should answer them
+// with REFUSED:
+//
+//	mux.HandleNotFound(dns.HandlerFunc(dns.HandleRefused))
+//
+// A nil handler restores the default.
+func (mux *ServeMux) HandleNotFound(handler Handler) {
+	mux.m.Lock()
+	mux.notFound = handler
 	mux.m.Unlock()
 }
 
@@ -217,19 +632,40 @@ func (mux *ServeMux) HandleRemove(pattern string) {
 // pattern most closely matches the request message. If DefaultServeMux
 // is used the correct thing for DS queries is done: a possible parent
 // is sought.
-// If no handler is found a standard SERVFAIL message is returned
+// If no handler is found the handler set with HandleNotFound is called, or a
+// standard SERVFAIL message is returned.
+// The handler is wrapped by the interceptors added with Intercept.
 // If the request message does not have a single question in the
 // question section a SERVFAIL is returned.
 func (mux *ServeMux) ServeDNS(w ResponseWriter, request *Msg) {
-	var h Handler
 	if len(request.Question) != 1 {
+		failedHandler().ServeDNS(w, request)
+		return
+	}
+	mux.Handler(request.Question[0]).ServeDNS(w, request)
+}
+
+// Handler returns the handler ServeDNS calls for a query with question q:
+// the handler of the first matcher or of the most specific pattern, or the
+// one set with HandleNotFound, wrapped by the interceptors of q's name.
+func (mux *ServeMux) Handler(q Question) Handler {
+	h := mux.matchQuestion(q)
+	if h == nil {
+		h = mux.match(q.Name, q.Qtype)
+	}
+	mux.m.RLock()
+	if h == nil {
+		h = mux.notFound
+	}
+	wraps := mux.interceptors(q.Name)
+	mux.m.RUnlock()
+	if h == nil {
 		h = failedHandler()
-	} else {
-		if h = mux.match(request.Question[0].Name, request.Question[0].Qtype); h == nil {
-			h = failedHandler()
-		}
 	}
-	h.ServeDNS(w, request)
+	for _, wrap := range wraps {
+		h = wrap(h)
+	}
+	return h
 }
 
 // Handle registers the handler with the given pattern
@@ -241,6 +677,13 @@ func Handle(pattern string, handler Handler) { DefaultServeMux.Handle(pattern, h
 // in the DefaultServeMux.
 func HandleRemove(pattern string) { DefaultServeMux.HandleRemove(pattern) }
 
+// Intercept adds an interceptor for pattern to the DefaultServeMux.
+func Intercept(pattern string, wrap func(Handler) Handler) { DefaultServeMux.Intercept(pattern, wrap) }
+
+// HandleNotFound sets the handler for requests that match no pattern in
+// the DefaultServeMux.
+func HandleNotFound(handler Handler) { DefaultServeMux.HandleNotFound(handler) }
+
 // HandleFunc registers the handler function with the given pattern
 // in the DefaultServeMux.
 func HandleFunc(pattern string, handler func(ResponseWriter, *Msg)) {
@@ -249,13 +692,139 @@ func HandleFunc(pattern string, handler func(ResponseWriter, *Msg)) {
 
 // A Server defines parameters for running an DNS server.
 type Server struct {
-	Addr         string            // address to listen on, ":dns" if empty
-	Net          string            // if "tcp" it will invoke a TCP listener, otherwise an UDP one
-	Handler      Handler           // handler to invoke, dns.DefaultServeMux if nil
-	UDPSize      int               // default buffer size to use to read incoming UDP messages
-	ReadTimeout  time.Duration     // the net.Conn.SetReadTimeout value for new connections
-	WriteTimeout time.Duration     // the net.Conn.SetWriteTimeout value for new connections
-	TsigSecret   map[string]string // secret(s) for Tsig map[<zonename>]<base64 secret>
+	Addr            string            // address to listen on, ":dns" if empty; a link-local IPv6 address needs its zone: "[fe80::1%eth0]:53"
+	Net             string            // if "tcp" it will invoke a TCP listener, "quic" a DNS over QUIC listener, otherwise an UDP one
+	TLSConfig       *tls.Config       // TLS configuration for "quic", it must have a certificate
+	Handler         Handler           // handler to invoke, dns.DefaultServeMux if nil
+	UDPSize         int               // default buffer size to use to read incoming UDP messages
+	ReadTimeout     time.Duration     // the net.Conn.SetReadTimeout value for new connections
+	WriteTimeout    time.Duration     // the net.Conn.SetWriteTimeout value for new connections
+	TsigSecret      map[string]string // secret(s) for Tsig map[<zonename>]<base64 secret>
+	MinimalAny      int               // how to answer ANY queries: AnyFull (default), AnyHinfo or AnyRRset, see MinimizeAny
+	MinimalResponse bool              // if true, the authority and additional sections are left out when not required, see MinimizeResponses
+	IdleTimeout     time.Duration     // TCP connections are closed when idle this long, defaults to 8 seconds
+	MaxTCPConns     int               // if not zero, the maximum number of open TCP connections
+	MaxTCPPerClient int               // if not zero, the maximum number of open TCP connections per client address
+	MaxQueries      int               // if not zero, the maximum number of queries handled at the same time
+	Workers         int               // if not zero, UDP queries are handled by this many goroutines, instead of a goroutine per query
+	MaxUDPSize      uint16            // the largest EDNS0 buffer size accepted and advertised, defaults to FlagDayMsgSize
+	PMTUDisc        int               // path MTU discovery setting for UDP sockets: PMTUDiscDefault, PMTUDiscOmit or PMTUDiscDo
+	ReadBuffer      int               // if not zero, the size of the receive buffer of the UDP socket (SO_RCVBUF)
+	WriteBuffer     int               // if not zero, the size of the send buffer of the UDP socket (SO_SNDBUF)
+	TOS             int               // if not zero, the IP TOS (IPv4) or traffic class (IPv6) of replies, e.g. a DSCP value << 2
+	Overload        int               // what to do with queries when MaxQueries is reached: OverloadDrop (default), OverloadServfail or OverloadRefused
+	Questions       int               // requests without exactly one question: QuestionsFormErr (default) or QuestionsPass
+	// OnError, if not nil, is called with a *WriteError whenever a reply can't
+	// be sent, also when the handler ignores the error from WriteMsg.
+	OnError func(a net.Addr, err error)
+	// Recover, if true, recovers panics in the handler: the client gets
+	// SERVFAIL (if nothing was written yet) and the connection is kept.
+	// Without it, a panicking handler takes down the process.
+	Recover bool
+	// OnPanic, if not nil, is called with the recovered value and the stack
+	// of a panicking handler. If nil, these are logged with the log package.
+	OnPanic func(a net.Addr, v interface{}, stack []byte)
+	// OnMalformed, if not nil, is called for requests that are malformed,
+	// unsupported or look spoofed, see MalformedPacket. The calls are rate
+	// limited to MalformedRate per second.
+	OnMalformed func(p *MalformedPacket)
+	// MalformedRate is the maximum number of OnMalformed calls per second,
+	// defaults to 10. If negative, there is no limit.
+	MalformedRate  int
+	panics         uint64                   // the number of recovered panics
+	drops          uint64                   // the number of queries dropped by the kernel, see Drops
+	malformed      [malformedReasons]uint64 // the number of malformed requests per reason, see Malformed
+	malformedLimit rateLimit
+}
+
+// What a Server does with queries when it is overloaded.
+const (
+	OverloadDrop     = iota // Don't answer
+	OverloadServfail        // Answer with SERVFAIL
+	OverloadRefused         // Answer with REFUSED
+)
+
+// What a Server does with requests that don't have exactly one question.
+// Most implementations answer them with FORMERR. With QuestionsPass the
+// request is given to the handler; note that ServeMux answers such requests
+// with SERVFAIL.
+const (
+	QuestionsFormErr = iota // Answer with FORMERR
+	QuestionsPass           // Let the handler decide
+)
+
+// The reasons a request is reported to Server.OnMalformed. How the request
+// is handled does not change: the request that can't be unpacked or has more
+// questions (with QuestionsFormErr) gets FORMERR, the others are given to the
+// handler.
+const (
+	MalformedParse     = iota // The request can't be unpacked
+	MalformedQuestions        // The request does not have exactly one question
+	MalformedOpcode           // The opcode is unknown
+	MalformedResponse         // The QR bit is set, the request is a reply
+	MalformedSource           // Over UDP, the source port is 0 or that of a service used in reflection attacks (echo, chargen, NTP, ...)
+	malformedReasons
+)
+
+// MalformedPacket describes a request reported to Server.OnMalformed.
+type MalformedPacket struct {
+	Addr       net.Addr // The address of the client
+	Reason     int      // MalformedParse, MalformedQuestions, ...
+	Err        error    // For MalformedParse, the error from Unpack
+	Wire       []byte   // The request in wire format
+	Suppressed uint64   // The number of reports dropped by the rate limit since the last one
+}
+
+// The default rate of OnMalformed calls per second.
+const malformedRate = 10
+
+// reflectorPorts are the UDP source ports of services that are abused to
+// reflect spoofed traffic. Queries from these ports are not real queries.
+var reflectorPorts = map[int]bool{0: true, 7: true, 13: true, 17: true, 19: true, 37: true, 123: true, 161: true, 389: true, 1900: true, 11211: true}
+
+// rateLimit allows a number of events per second and counts the events that
+// are dropped.
+type rateLimit struct {
+	second     int64
+	n          int
+	suppressed uint64
+	sync.Mutex
+}
+
+// allow returns true when an event is allowed in the current second, with
+// the number of events dropped since the last allowed one.
+func (l *rateLimit) allow(rate int) (bool, uint64) {
+	now := time.Now().Unix()
+	l.Lock()
+	defer l.Unlock()
+	if now != l.second {
+		l.second, l.n = now, 0
+	}
+	l.n++
+	if rate >= 0 && l.n > rate {
+		l.suppressed++
+		return false, 0
+	}
+	suppressed := l.suppressed
+	l.suppressed = 0
+	return true, suppressed
+}
+
+// The default TCP idle timeout, see RFC 7766, section 6.2.3.
+const tcpIdleTimeout = 8 * time.Second
+
+func (srv *Server) idleTimeout() time.Duration {
+	if srv.IdleTimeout == 0 {
+		return tcpIdleTimeout
+	}
+	return srv.IdleTimeout
+}
+
+func (srv *Server) maxUDPSize() int {
+	if srv.MaxUDPSize == 0 {
+		return FlagDayMsgSize
+	}
+	return int(srv.MaxUDPSize)
 }
 
 // ListenAndServe starts a nameserver on the configured address in *Server.
@@ -263,8 +832,13 @@ func (srv *Server) ListenAndServe() error {
 	addr := srv.Addr
 	if addr == "" {
 		addr = ":domain"
+		if srv.Net == "quic" {
+			addr = ":853"
+		}
 	}
 	switch srv.Net {
+	case "quic":
+		return srv.listenQUIC(addr)
 	case "tcp", "tcp4", "tcp6":
 		a, e := net.ResolveTCPAddr(srv.Net, addr)
 		if e != nil {
@@ -274,6 +848,13 @@ func (srv *Server) ListenAndServe() error {
 		if e != nil {
 			return e
 		}
+		if srv.TOS != 0 {
+			// Accepted connections inherit the setting
+			if e := setTOS(l, srv.TOS); e != nil {
+				l.Close()
+				return e
+			}
+		}
 		return srv.serveTCP(l)
 	case "udp", "udp4", "udp6":
 		a, e := net.ResolveUDPAddr(srv.Net, addr)
@@ -284,71 +865,200 @@ func (srv *Server) ListenAndServe() error {
 		if e != nil {
 			return e
 		}
+		if e := srv.setupUDP(l); e != nil {
+			l.Close()
+			return e
+		}
 		return srv.serveUDP(l)
 	}
 	return &Error{Err: "bad network"}
 }
 
+// setupUDP sets the socket options of the UDP socket of the server.
+func (srv *Server) setupUDP(l *net.UDPConn) error {
+	if e := setPMTUDisc(l, srv.PMTUDisc); e != nil {
+		return e
+	}
+	if srv.ReadBuffer != 0 {
+		if e := l.SetReadBuffer(srv.ReadBuffer); e != nil {
+			return e
+		}
+	}
+	if srv.WriteBuffer != 0 {
+		if e := l.SetWriteBuffer(srv.WriteBuffer); e != nil {
+			return e
+		}
+	}
+	if srv.TOS != 0 {
+		if e := setTOS(l, srv.TOS); e != nil {
+			return e
+		}
+	}
+	// Failing to count drops is not fatal
+	setDropCount(l)
+	return nil
+}
+
+// Drops returns the number of queries the kernel dropped because the
+// receive buffer of the UDP socket was full, see ReadBuffer. It is only
+// counted on Linux, elsewhere it is zero.
+func (srv *Server) Drops() uint64 { return atomic.LoadUint64(&srv.drops) }
+
 // serveTCP starts a TCP listener for the server.
-// Each request is handled in a seperate goroutine.
+// Each connection is handled in a seperate goroutine. When MaxTCPConns
+// connections are open, no new connections are accepted until one is closed.
+// It returns when l is closed.
 func (srv *Server) serveTCP(l *net.TCPListener) error {
 	defer l.Close()
 	handler := srv.Handler
 	if handler == nil {
 		handler = DefaultServeMux
 	}
-forever:
+	handler = MinimizeAny(handler, srv.MinimalAny)
+	if srv.MinimalResponse {
+		handler = MinimizeResponses(handler)
+	}
+	var conns chan bool
+	if srv.MaxTCPConns > 0 {
+		conns = make(chan bool, srv.MaxTCPConns)
+	}
+	clients := &tcpClients{m: make(map[string]int), max: srv.MaxTCPPerClient}
+	inflight := srv.newInflight()
 	for {
+		if conns != nil {
+			conns <- true
+		}
 		rw, e := l.AcceptTCP()
 		if e != nil {
-			// don't bail out, but wait for a new request  
+			if conns != nil {
+				<-conns
+			}
+			if errors.Is(e, net.ErrClosed) {
+				return e
+			}
+			// don't bail out, but wait for a new request
 			continue
 		}
-		if srv.ReadTimeout != 0 {
-			rw.SetReadDeadline(time.Now().Add(srv.ReadTimeout))
-		}
-		if srv.WriteTimeout != 0 {
-			rw.SetWriteDeadline(time.Now().Add(srv.WriteTimeout))
-		}
-		l := make([]byte, 2)
-		n, err := rw.Read(l)
-		if err != nil || n != 2 {
+		ip, _, _ := net.SplitHostPort(rw.RemoteAddr().String())
+		if !clients.add(ip) {
+			rw.Close()
+			if conns != nil {
+				<-conns
+			}
 			continue
 		}
+		go func() {
+			srv.serveTCPConn(rw, handler, inflight)
+			clients.remove(ip)
+			if conns != nil {
+				<-conns
+			}
+		}()
+	}
+	panic("dns: not reached")
+}
+
+// serveTCPConn reads and handles the requests on a TCP connection, until
+// the client closes the connection, the connection is idle for IdleTimeout
+// or the handler hijacks the connection.
+func (srv *Server) serveTCPConn(rw *net.TCPConn, handler Handler, inflight chan bool) {
+	idle := srv.idleTimeout()
+	timeout := srv.ReadTimeout // for the first request
+	if timeout == 0 {
+		timeout = idle
+	}
+	l := make([]byte, 2)
+	for {
+		rw.SetReadDeadline(time.Now().Add(timeout))
+		timeout = idle
+		if _, err := io.ReadFull(rw, l); err != nil {
+			break
+		}
 		length, _ := unpackUint16(l, 0)
 		if length == 0 {
-			continue
+			break
 		}
 		m := make([]byte, int(length))
-		n, err = rw.Read(m[:int(length)])
-		if err != nil || n == 0 {
+		if _, err := io.ReadFull(rw, m); err != nil {
+			break
+		}
+		if srv.WriteTimeout != 0 {
+			rw.SetWriteDeadline(time.Now().Add(srv.WriteTimeout))
+		}
+		if !acquire(inflight) {
+			srv.overload(&response{_TCP: rw, remoteAddr: rw.RemoteAddr()}, m)
 			continue
 		}
-		i := n
-		for i < int(length) {
-			j, err := rw.Read(m[i:int(length)])
-			if err != nil {
-				continue forever
-			}
-			i += j
+		hijacked := srv.serve(rw.RemoteAddr(), handler, m, nil, rw, time.Now())
+		release(inflight)
+		if hijacked {
+			// client takes care of the connection
+			return
 		}
-		n = i
-		go serve(rw.RemoteAddr(), handler, m, nil, rw, srv.TsigSecret)
 	}
-	panic("dns: not reached")
+	rw.Close()
+}
+
+// tcpClients counts the open TCP connections per client address.
+type tcpClients struct {
+	m   map[string]int
+	max int // if zero, there is no maximum
+	sync.Mutex
+}
+
+func (c *tcpClients) add(ip string) bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.max > 0 && c.m[ip] >= c.max {
+		return false
+	}
+	c.m[ip]++
+	return true
+}
+
+func (c *tcpClients) remove(ip string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.m[ip]--; c.m[ip] <= 0 {
+		delete(c.m, ip)
+	}
 }
 
 // serveUDP starts a UDP listener for the server.
-// Each request is handled in a seperate goroutine.
+// Each request is handled in a seperate goroutine. It returns when l is
+// closed.
 func (srv *Server) serveUDP(l *net.UDPConn) error {
 	defer l.Close()
 	handler := srv.Handler
 	if handler == nil {
 		handler = DefaultServeMux
 	}
+	handler = MinimizeAny(handler, srv.MinimalAny)
+	if srv.MinimalResponse {
+		handler = MinimizeResponses(handler)
+	}
 	if srv.UDPSize == 0 {
 		srv.UDPSize = udpMsgSize
 	}
+	inflight := srv.newInflight()
+	oob := make([]byte, 64) // for the drop counter
+	var work chan *udpRequest
+	if srv.Workers > 0 {
+		queue := srv.MaxQueries
+		if queue == 0 {
+			queue = srv.Workers
+		}
+		work = make(chan *udpRequest, queue)
+		defer close(work)
+		for i := 0; i < srv.Workers; i++ {
+			go func() {
+				for r := range work {
+					srv.serve(r.a, handler, r.m, l, nil, r.t)
+					release(inflight)
+				}
+			}()
+		}
+	}
 	for {
 		if srv.ReadTimeout != 0 {
 			l.SetReadDeadline(time.Now().Add(srv.ReadTimeout))
@@ -357,69 +1067,267 @@ func (srv *Server) serveUDP(l *net.UDPConn) error {
 			l.SetWriteDeadline(time.Now().Add(srv.WriteTimeout))
 		}
 		m := make([]byte, srv.UDPSize)
-		n, a, e := l.ReadFromUDP(m)
+		n, oobn, _, a, e := l.ReadMsgUDP(m, oob)
+		if oobn > 0 {
+			if drops, ok := parseDropCount(oob[:oobn]); ok {
+				atomic.StoreUint64(&srv.drops, drops)
+			}
+		}
+		if errors.Is(e, net.ErrClosed) {
+			return e
+		}
 		if e != nil || n == 0 {
 			// don't bail out, but wait for a new request
 			continue
 		}
 		m = m[:n]
-		go serve(a, handler, m, l, nil, srv.TsigSecret)
+		received := time.Now()
+		if !acquire(inflight) {
+			srv.overload(&response{_UDP: l, remoteAddr: a}, m)
+			continue
+		}
+		if work != nil {
+			work <- &udpRequest{a, m, received}
+			continue
+		}
+		go func() {
+			srv.serve(a, handler, m, l, nil, received)
+			release(inflight)
+		}()
 	}
 	panic("dns: not reached")
 }
 
-// Serve a new connection.
-func serve(a net.Addr, h Handler, m []byte, u *net.UDPConn, t *net.TCPConn, tsigSecret map[string]string) {
-	// for block to make it easy to break out to close the tcp connection
-	for {
-		// Request has been read in serveUDP or serveTCP
-		w := new(response)
-		w.tsigSecret = tsigSecret
-		w._UDP = u
-		w._TCP = t
-		w.remoteAddr = a
-		req := new(Msg)
-		if req.Unpack(m) != nil {
-			// Send a format error back
-			x := new(Msg)
-			x.SetRcodeFormatError(req)
-			w.WriteMsg(x)
-			break
+type udpRequest struct {
+	a *net.UDPAddr
+	m []byte
+	t time.Time // when the request was read
+}
+
+// newInflight returns the channel used to limit the number of queries
+// in flight, or nil if there is no limit.
+func (srv *Server) newInflight() chan bool {
+	if srv.MaxQueries > 0 {
+		return make(chan bool, srv.MaxQueries)
+	}
+	return nil
+}
+
+// acquire returns true if a query may be handled, it never blocks.
+func acquire(inflight chan bool) bool {
+	if inflight == nil {
+		return true
+	}
+	select {
+	case inflight <- true:
+		return true
+	default:
+		return false
+	}
+}
+
+func release(inflight chan bool) {
+	if inflight != nil {
+		<-inflight
+	}
+}
+
+// overload handles a request that can't be served because too many
+// queries are in flight, as specified in srv.Overload. The reply, if any, is
+// written to w.
+func (srv *Server) overload(w *response, m []byte) {
+	rcode := RcodeServerFailure
+	switch srv.Overload {
+	case OverloadDrop:
+		return
+	case OverloadRefused:
+		rcode = RcodeRefused
+	}
+	req := new(Msg)
+	if req.Unpack(m) != nil {
+		return
+	}
+	w.onError = srv.OnError
+	x := new(Msg)
+	x.SetRcode(req, rcode)
+	w.WriteMsg(x)
+}
+
+// Serve a request, received at the time received. Serve returns true if
+// the handler hijacked the connection.
+func (srv *Server) serve(a net.Addr, h Handler, m []byte, u *net.UDPConn, t *net.TCPConn, received time.Time) bool {
+	// Request has been read in serveUDP or serveTCPConn
+	w := new(response)
+	w.info = RequestInfo{Wire: m, Received: received, Net: "udp"}
+	if t != nil {
+		w.info.Net = "tcp"
+	}
+	w._UDP = u
+	w._TCP = t
+	w.remoteAddr = a
+	return srv.serveResponse(w, h, m)
+}
+
+// serveResponse serves the request m, received as set in w.info, the
+// replies are written to w. It returns true if the handler hijacked the
+// connection.
+func (srv *Server) serveResponse(w *response, h Handler, m []byte) bool {
+	a, u, t := w.remoteAddr, w._UDP, w._TCP
+	w.tsigSecret = srv.TsigSecret
+	w.maxUDPSize = srv.maxUDPSize()
+	w.onError = srv.OnError
+	if u != nil {
+		if ua, ok := a.(*net.UDPAddr); ok && reflectorPorts[ua.Port] {
+			srv.reportMalformed(a, MalformedSource, nil, m)
 		}
+	}
+	req := new(Msg)
+	if err := req.Unpack(m); err != nil {
+		srv.reportMalformed(a, MalformedParse, err, m)
+		// Send a format error back
+		x := new(Msg)
+		x.SetRcodeFormatError(req)
+		w.WriteMsg(x)
+		return false
+	}
+	if w._QUIC != nil {
+		if err := checkDoQ(req); err != nil {
+			srv.reportMalformed(a, MalformedParse, err, m)
+			w._QUIC.abort(DoQProtocolError, err)
+			return false
+		}
+	}
 
-		w.tsigStatus = nil
-		if w.tsigSecret != nil {
-			if t := req.IsTsig(); t != nil {
-				secret := t.Hdr.Name
-				if _, ok := tsigSecret[secret]; !ok {
-					w.tsigStatus = ErrKeyAlg
-				}
-				w.tsigStatus = TsigVerify(m, tsigSecret[secret], "", false)
-				w.tsigTimersOnly = false
-				w.tsigRequestMAC = req.Extra[len(req.Extra)-1].(*TSIG).MAC
+	w.tsigStatus = nil
+	if w.tsigSecret != nil {
+		if t := req.IsTsig(); t != nil {
+			secret := t.Hdr.Name
+			if _, ok := w.tsigSecret[secret]; !ok {
+				w.tsigStatus = ErrKeyAlg
 			}
+			w.tsigStatus = TsigVerify(m, w.tsigSecret[secret], "", false)
+			w.tsigTimersOnly = false
+			w.tsigRequestMAC = req.Extra[len(req.Extra)-1].(*TSIG).MAC
 		}
-		h.ServeDNS(w, req) // this does the writing back to the client
-		if w.hijacked {
-			// client takes care of the connection, i.e. calls Close()
-			break
+	}
+	opt := req.IsEdns0()
+	if u != nil {
+		// The reply must fit in the buffer of the client
+		w.udpSize = 512
+		if opt != nil && int(opt.UDPSize()) > w.udpSize {
+			w.udpSize = int(opt.UDPSize())
+			if w.udpSize > w.maxUDPSize {
+				w.udpSize = w.maxUDPSize
+			}
 		}
-		if t != nil {
-			w.Close()
+	}
+	if t != nil && opt != nil {
+		for _, o := range opt.Option {
+			if _, ok := o.(*EDNS0_TCP_KEEPALIVE); ok {
+				w.tcpKeepalive = uint16(srv.idleTimeout() / (100 * time.Millisecond))
+			}
 		}
-		break
 	}
-	return
+	if req.Response {
+		srv.reportMalformed(a, MalformedResponse, nil, m)
+	}
+	if _, ok := OpcodeToString[req.Opcode]; !ok {
+		srv.reportMalformed(a, MalformedOpcode, nil, m)
+	}
+	if len(req.Question) != 1 {
+		srv.reportMalformed(a, MalformedQuestions, nil, m)
+	}
+	if len(req.Question) != 1 && srv.Questions == QuestionsFormErr {
+		x := new(Msg)
+		x.SetRcodeFormatError(req)
+		x.Opcode = req.Opcode
+		w.WriteMsg(x)
+		return false
+	}
+	if srv.Recover {
+		defer srv.recoverHandler(w, req)
+	}
+	h.ServeDNS(w, req) // this does the writing back to the client
+	return w.hijacked
+}
+
+// recoverHandler recovers a panic in the handler serving req and answers
+// with SERVFAIL, unless the handler already wrote a reply.
+func (srv *Server) recoverHandler(w *response, req *Msg) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	atomic.AddUint64(&srv.panics, 1)
+	stack := debug.Stack()
+	if srv.OnPanic != nil {
+		srv.OnPanic(w.remoteAddr, v, stack)
+	} else {
+		log.Printf("dns: panic serving %s: %v\n%s", w.remoteAddr, v, stack)
+	}
+	if !w.written {
+		x := new(Msg)
+		x.SetRcode(req, RcodeServerFailure)
+		w.WriteMsg(x)
+	}
 }
 
-// WriteMsg implements the ResponseWriter.WriteMsg method.
+// Panics returns the number of handler panics recovered by srv, see
+// Recover.
+func (srv *Server) Panics() uint64 { return atomic.LoadUint64(&srv.panics) }
+
+// reportMalformed counts the malformed request m and calls OnMalformed,
+// unless the rate limit is reached.
+func (srv *Server) reportMalformed(a net.Addr, reason int, err error, m []byte) {
+	atomic.AddUint64(&srv.malformed[reason], 1)
+	if srv.OnMalformed == nil {
+		return
+	}
+	rate := srv.MalformedRate
+	if rate == 0 {
+		rate = malformedRate
+	}
+	ok, suppressed := srv.malformedLimit.allow(rate)
+	if !ok {
+		return
+	}
+	srv.OnMalformed(&MalformedPacket{Addr: a, Reason: reason, Err: err, Wire: m, Suppressed: suppressed})
+}
+
+// Malformed returns the number of requests srv received for reason, e.g.
+// MalformedParse. The requests are counted also when OnMalformed is nil.
+func (srv *Server) Malformed(reason int) uint64 {
+	if reason < 0 || reason >= malformedReasons {
+		return 0
+	}
+	return atomic.LoadUint64(&srv.malformed[reason])
+}
+
+// WriteMsg implements the ResponseWriter.WriteMsg method. All errors
+// are of type *WriteError.
 func (w *response) WriteMsg(m *Msg) (err error) {
 	var data []byte
+	if opt := m.IsEdns0(); opt != nil && w.maxUDPSize != 0 && int(opt.UDPSize()) > w.maxUDPSize {
+		opt.SetUDPSize(uint16(w.maxUDPSize))
+	}
+	if w.tcpKeepalive != 0 {
+		if opt := m.IsEdns0(); opt != nil {
+			keepalive := false
+			for _, o := range opt.Option {
+				if _, ok := o.(*EDNS0_TCP_KEEPALIVE); ok {
+					keepalive = true
+				}
+			}
+			if !keepalive {
+				opt.Option = append(opt.Option, &EDNS0_TCP_KEEPALIVE{Code: EDNS0TCPKEEPALIVE, Length: 2, Timeout: w.tcpKeepalive})
+			}
+		}
+	}
 	if w.tsigSecret != nil { // if no secrets, dont check for the tsig (which is a longer check)
 		if t := m.IsTsig(); t != nil {
 			data, w.tsigRequestMAC, err = TsigGenerate(m, w.tsigSecret[t.Hdr.Name], w.tsigRequestMAC, w.tsigTimersOnly)
 			if err != nil {
-				return err
+				return w.fail("tsig", 0, err)
 			}
 			_, err = w.Write(data)
 			return err
@@ -427,42 +1335,90 @@ func (w *response) WriteMsg(m *Msg) (err error) {
 	}
 	data, err = m.Pack()
 	if err != nil {
-		return err
+		return w.fail("pack", 0, err)
+	}
+	if w._UDP != nil && w.udpSize != 0 && len(data) > w.udpSize {
+		data, err = truncate(m).Pack()
+		if err != nil {
+			return w.fail("pack", 0, err)
+		}
 	}
 	_, err = w.Write(data)
 	return err
 }
 
-// Write implements the ResponseWriter.Write method.
+// fail returns a *WriteError for err and reports it to the server's
+// OnError callback.
+func (w *response) fail(op string, n int, err error) error {
+	e := &WriteError{Op: op, Addr: w.remoteAddr, N: n, Err: err}
+	if w.onError != nil {
+		w.onError(w.remoteAddr, e)
+	}
+	return e
+}
+
+// truncate returns a copy of m with only the question section, the OPT RR
+// and the TC bit set.
+func truncate(m *Msg) *Msg {
+	t := new(Msg)
+	t.MsgHdr = m.MsgHdr
+	t.Truncated = true
+	t.Compress = m.Compress
+	t.Question = m.Question
+	if opt := m.IsEdns0(); opt != nil {
+		t.Extra = []RR{opt}
+	}
+	return t
+}
+
+// Write implements the ResponseWriter.Write method. All errors are of
+// type *WriteError.
 func (w *response) Write(m []byte) (int, error) {
+	n, err := w.write(m)
+	if err != nil {
+		return n, w.fail("write", n, err)
+	}
+	w.written = true
+	return n, nil
+}
+
+func (w *response) write(m []byte) (int, error) {
 	switch {
 	case w._UDP != nil:
+		if len(m) > MaxMsgSize || (w.udpSize != 0 && len(m) > w.udpSize) {
+			return 0, ErrMsgTooLarge
+		}
 		n, err := w._UDP.WriteTo(m, w.remoteAddr)
-		return n, err
+		if err != nil {
+			return n, err
+		}
+		if n < len(m) {
+			return n, ErrShortWrite
+		}
+		return n, nil
 	case w._TCP != nil:
 		lm := len(m)
-		if len(m) > MaxMsgSize {
-			return 0, &Error{Err: "message too large"}
+		if lm > MaxMsgSize {
+			return 0, ErrMsgTooLarge
 		}
 		l := make([]byte, 2)
 		l[0], l[1] = packUint16(uint16(lm))
 		m = append(l, m...)
 		n, err := w._TCP.Write(m)
+		if n -= 2; n < 0 { // don't count the length
+			n = 0
+		}
 		if err != nil {
 			return n, err
 		}
-		i := n
-		if i < lm {
-			j, err := w._TCP.Write(m[i:lm])
-			if err != nil {
-				return i, err
-			}
-			i += j
+		if n < lm {
+			return n, ErrShortWrite
 		}
-		n = i
-		return i, nil
+		return n, nil
+	case w._QUIC != nil:
+		return w._QUIC.write(m)
 	}
-	panic("not reached")
+	return 0, ErrConnEmpty
 }
 
 // RemoteAddr implements the ResponseWriter.RemoteAddr method.
@@ -477,6 +1433,9 @@ func (w *response) TsigTimersOnly(b bool) { w.tsigTimersOnly = b }
 // Hijack implements the ResponseWriter.Hijack method.
 func (w *response) Hijack() { w.hijacked = true }
 
+// RequestInfo implements the RequestInfoWriter.RequestInfo method.
+func (w *response) RequestInfo() *RequestInfo { return &w.info }
+
 // Close implements the ResponseWriter.Close method
 func (w *response) Close() error {
 	if w._UDP != nil {
@@ -489,6 +1448,11 @@ func (w *response) Close() error {
 		w._TCP = nil
 		return e
 	}
+	if w._QUIC != nil {
+		w._QUIC.close()
+		w._QUIC = nil
+		return nil
+	}
 	// no-op
 	return nil
 }
@@ -1,6 +1,10 @@
 package dns
 
 import (
+	"io"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -96,3 +100,858 @@ func TestDotAsCatchAllWildcard(t *testing.T) {
 		t.Error("boe. match failed")
 	}
 }
+
+// namedHandler is a Handler that can be compared in tests.
+type namedHandler string
+
+func (h namedHandler) ServeDNS(w ResponseWriter, r *Msg) {}
+
+func TestServeMuxTypeAndPriority(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("example.org.", namedHandler("zone"))
+	mux.HandleType("_acme-challenge.example.org.", TypeTXT, namedHandler("acme"))
+	mux.HandleType("example.org.", TypeDS, namedHandler("ds"))
+	for _, test := range []struct {
+		name string
+		t    uint16
+		want Handler
+	}{
+		{"_acme-challenge.example.org.", TypeTXT, namedHandler("acme")},
+		{"_acme-challenge.example.org.", TypeA, namedHandler("zone")},
+		{"www.example.org.", TypeTXT, namedHandler("zone")},
+		{"example.org.", TypeDS, namedHandler("ds")},
+	} {
+		if h := mux.match(test.name, test.t); h != test.want {
+			t.Errorf("%s %s: got handler %v, expected %v", test.name, TypeToString[test.t], h, test.want)
+		}
+	}
+
+	low, _ := NewQuestionMatcher("*.example.org.")
+	high, _ := NewQuestionMatcher("www.example.org.")
+	mux.HandleMatch(low, namedHandler("low"))
+	mux.HandleMatchPriority(high, 10, namedHandler("high"))
+	if h := mux.matchQuestion(Question{"www.example.org.", TypeA, ClassINET}); h != namedHandler("high") {
+		t.Errorf("got handler %v, expected the one with the highest priority", h)
+	}
+	if h := mux.matchQuestion(Question{"ftp.example.org.", TypeA, ClassINET}); h != namedHandler("low") {
+		t.Errorf("got handler %v, expected low", h)
+	}
+}
+
+func TestServeMuxWildcard(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("example.org.", namedHandler("zone"))
+	mux.Handle("*.example.org.", namedHandler("wild"))
+	mux.HandleType("*.example.org.", TypeMX, namedHandler("wild mx"))
+	mux.Handle(`\*.example.org.`, namedHandler("literal"))
+	mux.Handle("Bücher.example.", namedHandler("idn"))
+	for _, test := range []struct {
+		name string
+		t    uint16
+		want Handler
+	}{
+		{"example.org.", TypeA, namedHandler("zone")},
+		{"www.example.org.", TypeA, namedHandler("wild")},
+		{"WWW.Example.ORG.", TypeA, namedHandler("wild")},
+		{"a.b.example.org.", TypeA, namedHandler("wild")},
+		{"www.example.org.", TypeMX, namedHandler("wild mx")},
+		{"*.example.org.", TypeA, namedHandler("literal")},
+		{"\\042.example.org.", TypeA, namedHandler("literal")},
+		{"xn--bcher-kva.example.", TypeA, namedHandler("idn")},
+		{"www.B\\195\\188CHER.example.", TypeA, namedHandler("idn")},
+		{"example.net.", TypeA, nil},
+	} {
+		if h := mux.match(test.name, test.t); h != test.want {
+			t.Errorf("%s %s: got handler %v, expected %v", test.name, TypeToString[test.t], h, test.want)
+		}
+	}
+
+	mux.HandleRemove("*.example.org.")
+	if h := mux.match("www.example.org.", TypeA); h != namedHandler("zone") {
+		t.Errorf("got handler %v after removing the wildcard, expected zone", h)
+	}
+	if h := mux.match("*.example.org.", TypeA); h != namedHandler("literal") {
+		t.Errorf("got handler %v after removing the wildcard, expected literal", h)
+	}
+	if s := foldName("München.\\065."); s != "xn--mnchen-3ya.a." {
+		t.Errorf("bad folded name %s", s)
+	}
+}
+
+// tagWriter adds a TXT record with its tag to the answer of a reply.
+type tagWriter struct {
+	ResponseWriter
+	tag string
+}
+
+func (w *tagWriter) WriteMsg(m *Msg) error {
+	m.Answer = append(m.Answer, &TXT{Hdr: RR_Header{"tag.", TypeTXT, ClassINET, 0, 0}, Txt: []string{w.tag}})
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func tagger(tag string) func(Handler) Handler {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Msg) {
+			h.ServeDNS(&tagWriter{w, tag}, r)
+		})
+	}
+}
+
+func TestServeMuxIntercept(t *testing.T) {
+	mux := NewServeMux()
+	answer := HandlerFunc(func(w ResponseWriter, r *Msg) {
+		m := new(Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+	mux.Handle("example.org.", answer)
+	mux.Handle("sub.example.org.", answer)
+	mux.Intercept("example.org.", tagger("parent"))
+	mux.Intercept("example.org.", tagger("parent2"))
+	mux.Intercept("sub.example.org.", tagger("child"))
+	mux.Intercept("*.sub.example.org.", tagger("wild"))
+	mux.Intercept(".", tagger("root"))
+	for _, test := range []struct {
+		name  string
+		rcode int
+		tags  string
+	}{
+		{"www.sub.example.org.", RcodeSuccess, "wild child parent2 parent root"},
+		{"sub.example.org.", RcodeSuccess, "child parent2 parent root"},
+		{"www.example.org.", RcodeSuccess, "parent2 parent root"},
+		{"example.net.", RcodeServerFailure, "root"},
+	} {
+		w := new(testWriter)
+		req := new(Msg)
+		req.SetQuestion(test.name, TypeA)
+		mux.ServeDNS(w, req)
+		var tags []string
+		for _, rr := range w.msg.Answer {
+			tags = append(tags, rr.(*TXT).Txt[0])
+		}
+		if w.msg.Rcode != test.rcode || strings.Join(tags, " ") != test.tags {
+			t.Errorf("%s: expected rcode %d and tags %q, got %d and %q", test.name, test.rcode, test.tags, w.msg.Rcode, tags)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	var reported error
+	srv := &Server{OnError: func(a net.Addr, err error) { reported = err }}
+	var returned error
+	h := HandlerFunc(func(w ResponseWriter, r *Msg) {
+		m := new(Msg)
+		m.SetReply(r)
+		m.Answer = []RR{&NS{Hdr: RR_Header{"miek.nl.", TypeNS, ClassINET, 3600, 0}, Ns: "not.fqdn"}}
+		returned = w.WriteMsg(m)
+	})
+	q := new(Msg)
+	q.SetQuestion("miek.nl.", TypeNS)
+	buf, _ := q.Pack()
+	srv.serve(l.LocalAddr(), h, buf, l, nil, time.Now())
+	e, ok := returned.(*WriteError)
+	if !ok || e.Op != "pack" {
+		t.Fatalf("expected a pack *WriteError, got %v", returned)
+	}
+	if reported != returned {
+		t.Errorf("OnError got %v, WriteMsg returned %v", reported, returned)
+	}
+
+	// A raw reply larger than the client's buffer must not be sent
+	reported = nil
+	h = HandlerFunc(func(w ResponseWriter, r *Msg) { _, returned = w.Write(make([]byte, 1024)) })
+	srv.serve(l.LocalAddr(), h, buf, l, nil, time.Now())
+	if e, ok := returned.(*WriteError); !ok || e.Err != ErrMsgTooLarge || reported != returned {
+		t.Errorf("expected ErrMsgTooLarge, got %v", returned)
+	}
+}
+
+func TestSelfCheck(t *testing.T) {
+	serial := func(s uint32) string {
+		l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		h := HandlerFunc(func(w ResponseWriter, req *Msg) {
+			m := new(Msg)
+			m.SetReply(req)
+			m.Authoritative = true
+			m.Answer = []RR{&SOA{Hdr: RR_Header{"example.org.", TypeSOA, ClassINET, 3600, 0}, Ns: "ns.example.org.",
+				Mbox: "hostmaster.example.org.", Serial: s, Refresh: 3600, Retry: 600, Expire: 86400, Minttl: 300}}
+			w.WriteMsg(m)
+		})
+		go (&Server{Handler: h}).serveUDP(l)
+		return l.LocalAddr().String()
+	}
+	s := NewSelfCheck(serial(5))
+	s.AddZone("example.org.", &Master{Addr: serial(6)})
+	if s.Healthy() {
+		t.Error("healthy before the first check")
+	}
+	s.Check()
+	st := s.Status()["example.org."]
+	if st == nil || st.Healthy || st.MasterSerial != 6 {
+		t.Fatalf("expected an unhealthy zone behind serial 6, got %+v", st)
+	}
+	s.AddZone("example.org.", &Master{Addr: serial(5)})
+	s.Check()
+	if !s.Healthy() {
+		t.Errorf("expected healthy zones, got %+v", s.Status()["example.org."])
+	}
+}
+
+func TestMinimizeResponses(t *testing.T) {
+	rr := func(s string) RR { r, _ := NewRR(s); return r }
+	var reply *Msg
+	h := MinimizeResponses(HandlerFunc(func(w ResponseWriter, req *Msg) { w.WriteMsg(reply) }))
+	req := new(Msg)
+	req.SetQuestion("www.example.org.", TypeA)
+
+	reply = new(Msg)
+	reply.SetReply(req)
+	reply.Authoritative = true
+	reply.Answer = []RR{rr("www.example.org. IN A 192.0.2.1")}
+	reply.Ns = []RR{rr("example.org. IN NS ns.example.org."), rr("*.example.org. IN NSEC z.example.org. A RRSIG NSEC")}
+	reply.Extra = []RR{rr("ns.example.org. IN A 192.0.2.53")}
+	reply.SetEdns0(4096, true)
+	w := new(vectorWriter)
+	h.ServeDNS(w, req)
+	if len(w.msg.Ns) != 1 || w.msg.Ns[0].Header().Rrtype != TypeNSEC {
+		t.Errorf("expected only the NSEC in the authority section, got %v", w.msg.Ns)
+	}
+	if len(w.msg.Extra) != 1 || w.msg.IsEdns0() == nil {
+		t.Errorf("expected only the OPT in the additional section, got %v", w.msg.Extra)
+	}
+
+	// Referrals are left alone
+	reply = new(Msg)
+	reply.SetReply(req)
+	reply.Ns = []RR{rr("example.org. IN NS ns.example.org.")}
+	reply.Extra = []RR{rr("ns.example.org. IN A 192.0.2.53")}
+	h.ServeDNS(w, req)
+	if len(w.msg.Ns) != 1 || len(w.msg.Extra) != 1 {
+		t.Errorf("referral was trimmed: %v %v", w.msg.Ns, w.msg.Extra)
+	}
+}
+
+// anyHandler answers with A, AAAA and MX records and the RRSIGs of the A and
+// AAAA records, for any type. If called is not nil, it's set to true.
+func anyHandler(called *bool) Handler {
+	return HandlerFunc(func(w ResponseWriter, req *Msg) {
+		if called != nil {
+			*called = true
+		}
+		m := new(Msg)
+		m.SetReply(req)
+		for _, s := range []string{"A 192.0.2.1", "RRSIG A 8 2 3600 20300101000000 20200101000000 12345 miek.nl. AAAA",
+			"AAAA 2001:db8::1", "RRSIG AAAA 8 2 3600 20300101000000 20200101000000 12345 miek.nl. AAAA", "MX 10 mx.miek.nl."} {
+			rr, _ := NewRR(req.Question[0].Name + " 3600 IN " + s)
+			m.Answer = append(m.Answer, rr)
+		}
+		w.WriteMsg(m)
+	})
+}
+
+func TestMinimizeAny(t *testing.T) {
+	var called bool
+	h := anyHandler(&called)
+	query := func(h Handler, qtype uint16) *Msg {
+		called = false
+		req := new(Msg)
+		req.SetQuestion("miek.nl.", qtype)
+		w := new(testWriter)
+		h.ServeDNS(w, req)
+		return w.msg
+	}
+	if m := query(MinimizeAny(h, AnyFull), TypeANY); len(m.Answer) != 5 {
+		t.Errorf("expected the full answer with AnyFull, got %v", m)
+	}
+	m := query(MinimizeAny(h, AnyHinfo), TypeANY)
+	if called || len(m.Answer) != 1 || m.Answer[0].(*HINFO).Cpu != "RFC8482" {
+		t.Errorf("expected a synthesized HINFO with AnyHinfo, got %v", m)
+	}
+	m = query(MinimizeAny(h, AnyRRset), TypeANY)
+	if len(m.Answer) != 2 || m.Answer[0].Header().Rrtype != TypeA || m.Answer[1].(*RRSIG).TypeCovered != TypeA {
+		t.Errorf("expected the A RRset and its signature with AnyRRset, got %v", m)
+	}
+	// Other types are not touched
+	for _, mode := range []int{AnyHinfo, AnyRRset} {
+		if m := query(MinimizeAny(h, mode), TypeA); !called || len(m.Answer) != 5 {
+			t.Errorf("expected the full answer for type A in mode %d, got %v", mode, m)
+		}
+	}
+
+	// Over UDP and TCP, with Server.MinimalAny
+	for _, mode := range []int{AnyHinfo, AnyRRset} {
+		srv := &Server{Handler: anyHandler(nil), MinimalAny: mode}
+		u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		go srv.serveUDP(u)
+		addr := startTCPServer(t, srv)
+		for _, c := range []struct{ net, addr string }{{"udp", u.LocalAddr().String()}, {"tcp", addr}} {
+			req := new(Msg)
+			req.SetQuestion("miek.nl.", TypeANY)
+			m, _, err := (&Client{Net: c.net}).Exchange(req, c.addr)
+			if err != nil {
+				t.Fatalf("failed to exchange over %s: %s", c.net, err.Error())
+			}
+			expected := TypeA
+			if mode == AnyHinfo {
+				expected = TypeHINFO
+			}
+			if len(m.Answer) == 0 || m.Answer[0].Header().Rrtype != expected || len(m.Answer) > 2 {
+				t.Errorf("expected a minimal answer with %s over %s, got %v", TypeToString[expected], c.net, m)
+			}
+		}
+		u.Close()
+	}
+}
+
+func TestPreserveCase(t *testing.T) {
+	a, _ := NewRR("www.example.org. IN A 192.0.2.1")
+	answer := []RR{a}
+	h := PreserveCase(HandlerFunc(func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetQuestion("www.example.org.", TypeA)
+		m.Response = true
+		m.Answer = answer
+		w.WriteMsg(m)
+	}))
+	req := new(Msg)
+	req.SetQuestion("wWw.ExamPLE.org.", TypeA)
+	w := new(vectorWriter)
+	h.ServeDNS(w, req)
+	if w.msg.Question[0].Name != "wWw.ExamPLE.org." || w.msg.Answer[0].Header().Name != "wWw.ExamPLE.org." {
+		t.Errorf("case not preserved: %s", w.msg.String())
+	}
+	if a.Header().Name != "www.example.org." || answer[0] != a {
+		t.Error("the original answer was modified")
+	}
+	buf, _ := w.msg.Pack()
+	m := new(Msg)
+	if m.Unpack(buf); m.Answer[0].Header().Name != "wWw.ExamPLE.org." {
+		t.Errorf("case lost when packing: %s", m.Answer[0].Header().Name)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	var stack []byte
+	srv := &Server{Recover: true, OnPanic: func(a net.Addr, v interface{}, s []byte) { stack = s }}
+	go srv.serveUDP(l)
+
+	HandleFunc("panic.example.", func(w ResponseWriter, r *Msg) { panic("bad handler") })
+	defer HandleRemove("panic.example.")
+	c := new(Client)
+	m := new(Msg)
+	m.SetQuestion("panic.example.", TypeA)
+	r, _, err := c.Exchange(m, l.LocalAddr().String())
+	if err != nil || r.Rcode != RcodeServerFailure {
+		t.Fatalf("expected SERVFAIL, got %v, %v", r, err)
+	}
+	if srv.Panics() != 1 || len(stack) == 0 {
+		t.Errorf("expected one recovered panic with its stack, got %d", srv.Panics())
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	var mu sync.Mutex
+	var reports []*MalformedPacket
+	srv := &Server{MalformedRate: -1, OnMalformed: func(p *MalformedPacket) {
+		mu.Lock()
+		reports = append(reports, p)
+		mu.Unlock()
+	}, Handler: HandlerFunc(func(w ResponseWriter, r *Msg) {
+		m := new(Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})}
+	go srv.serveUDP(l)
+
+	c, err := net.Dial("udp", l.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	defer c.Close()
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeSOA)
+	m.Response = true
+	m.Opcode = 3
+	q, _ := m.Pack()
+	buf := make([]byte, 512)
+	for _, p := range [][]byte{{1, 2, 3}, q} {
+		c.Write(p)
+		c.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := c.Read(buf); err != nil {
+			t.Fatalf("no reply: %s", err.Error())
+		}
+	}
+	if srv.Malformed(MalformedParse) != 1 || srv.Malformed(MalformedResponse) != 1 || srv.Malformed(MalformedOpcode) != 1 || srv.Malformed(MalformedQuestions) != 0 {
+		t.Errorf("bad counters: %d %d %d", srv.Malformed(MalformedParse), srv.Malformed(MalformedResponse), srv.Malformed(MalformedOpcode))
+	}
+	mu.Lock()
+	if len(reports) != 3 || reports[0].Reason != MalformedParse || reports[0].Err == nil {
+		t.Errorf("unexpected reports %v", reports)
+	}
+	mu.Unlock()
+
+	// With a rate of 0 all reports are dropped, and counted
+	var rl rateLimit
+	for i := 0; i < 2; i++ {
+		if ok, _ := rl.allow(0); ok {
+			t.Error("expected the report to be dropped")
+		}
+	}
+	if ok, suppressed := rl.allow(-1); !ok || suppressed != 2 {
+		t.Errorf("expected 2 suppressed reports, got %d", suppressed)
+	}
+}
+
+func TestQuestionsFormErr(t *testing.T) {
+	for _, mode := range []int{QuestionsFormErr, QuestionsPass} {
+		l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		go (&Server{Questions: mode, Handler: HandlerFunc(func(w ResponseWriter, r *Msg) {
+			m := new(Msg)
+			m.SetReply(r)
+			w.WriteMsg(m)
+		})}).serveUDP(l)
+		c, err := net.Dial("udp", l.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %s", err.Error())
+		}
+		buf := make([]byte, 512)
+		for _, qdcount := range []int{0, 1, 2} {
+			m := new(Msg)
+			m.Id = uint16(1000 + qdcount)
+			for i := 0; i < qdcount; i++ {
+				m.Question = append(m.Question, Question{"miek.nl.", TypeSOA, ClassINET})
+			}
+			q, _ := m.Pack()
+			c.Write(q)
+			c.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, err := c.Read(buf)
+			if err != nil {
+				t.Fatalf("no reply: %s", err.Error())
+			}
+			r := new(Msg)
+			if err := r.Unpack(buf[:n]); err != nil {
+				t.Fatalf("failed to unpack reply: %s", err.Error())
+			}
+			rcode := RcodeSuccess
+			if qdcount != 1 && mode == QuestionsFormErr {
+				rcode = RcodeFormatError
+			}
+			if r.Id != m.Id || r.Rcode != rcode {
+				t.Errorf("mode %d, QDCOUNT %d: expected id %d and %s, got id %d and %s", mode, qdcount, m.Id, RcodeToString[rcode], r.Id, RcodeToString[r.Rcode])
+			}
+		}
+		c.Close()
+		l.Close()
+	}
+}
+
+func TestRequestInfo(t *testing.T) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	var info RequestInfo
+	// The wrappers of this package pass it on
+	go (&Server{Handler: MinimizeResponses(HandlerFunc(func(w ResponseWriter, r *Msg) {
+		info = *w.(RequestInfoWriter).RequestInfo()
+		m := new(Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	}))}).serveTCP(l)
+
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeSOA)
+	wire, _ := m.Pack()
+	start := time.Now()
+	c := &Client{Net: "tcp"}
+	if _, _, err := c.Exchange(m, l.Addr().String()); err != nil {
+		t.Fatalf("failed to exchange: %s", err.Error())
+	}
+	if info.Net != "tcp" || string(info.Wire) != string(wire) {
+		t.Errorf("unexpected request info: %s %x", info.Net, info.Wire)
+	}
+	if info.Received.Before(start) || time.Since(info.Received) > time.Second {
+		t.Errorf("bad receive time: %s", info.Received)
+	}
+}
+
+// startTCPServer serves srv on a random TCP port of 127.0.0.1 and returns
+// its address.
+func startTCPServer(t *testing.T, srv *Server) string {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	go srv.serveTCP(l)
+	return l.Addr().String()
+}
+
+// tcpQuery sends m on c and reads the reply, which must arrive within
+// timeout.
+func tcpQuery(c net.Conn, m *Msg, timeout time.Duration) (*Msg, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Write(append([]byte{byte(len(buf) >> 8), byte(len(buf))}, buf...)); err != nil {
+		return nil, err
+	}
+	c.SetReadDeadline(time.Now().Add(timeout))
+	l := make([]byte, 2)
+	if _, err := io.ReadFull(c, l); err != nil {
+		return nil, err
+	}
+	buf = make([]byte, int(l[0])<<8|int(l[1]))
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return nil, err
+	}
+	r := new(Msg)
+	return r, r.Unpack(buf)
+}
+
+func TestMaxTCPConns(t *testing.T) {
+	addr := startTCPServer(t, &Server{Handler: HandlerFunc(HelloServer), MaxTCPConns: 1})
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeTXT)
+	c1, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	if _, err := tcpQuery(c1, m, 2*time.Second); err != nil {
+		t.Fatalf("failed to exchange: %s", err.Error())
+	}
+	// The second connection waits until the first one is closed
+	c2, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	defer c2.Close()
+	if _, err := tcpQuery(c2, m, 200*time.Millisecond); err == nil {
+		t.Fatal("expected no reply while the first connection is open")
+	}
+	c1.Close()
+	c2.SetReadDeadline(time.Now().Add(2 * time.Second))
+	l := make([]byte, 2)
+	if _, err := io.ReadFull(c2, l); err != nil {
+		t.Errorf("expected a reply after the first connection is closed: %s", err.Error())
+	}
+}
+
+func TestMaxTCPPerClient(t *testing.T) {
+	addr := startTCPServer(t, &Server{Handler: HandlerFunc(HelloServer), MaxTCPPerClient: 1})
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeTXT)
+	c1, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	if _, err := tcpQuery(c1, m, 2*time.Second); err != nil {
+		t.Fatalf("failed to exchange: %s", err.Error())
+	}
+	// A second connection from the same address is closed
+	c2, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	defer c2.Close()
+	if _, err := tcpQuery(c2, m, 2*time.Second); err == nil || isTimeout(err) {
+		t.Errorf("expected the second connection to be closed, got %v", err)
+	}
+	// After closing the first one, there is room again
+	c1.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c3, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("failed to dial: %s", err.Error())
+		}
+		_, err = tcpQuery(c3, m, 2*time.Second)
+		c3.Close()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no new connection accepted after closing the first one: %s", err.Error())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// isTimeout returns true when err is a network time out.
+func isTimeout(err error) bool {
+	e, ok := err.(net.Error)
+	return ok && e.Timeout()
+}
+
+func TestTCPIdleTimeout(t *testing.T) {
+	addr := startTCPServer(t, &Server{Handler: HandlerFunc(HelloServer), IdleTimeout: 500 * time.Millisecond})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	defer c.Close()
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeTXT)
+	for i := 0; i < 3; i++ {
+		if _, err := tcpQuery(c, m, 2*time.Second); err != nil {
+			t.Fatalf("failed to exchange: %s", err.Error())
+		}
+	}
+	start := time.Now()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the server to close the idle connection, got %v", err)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("connection closed after %s, before it was idle", d)
+	}
+}
+
+func TestTCPKeepalive(t *testing.T) {
+	// The option is added to replies with an OPT record
+	addr := startTCPServer(t, &Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) {
+		m := new(Msg)
+		m.SetReply(req)
+		m.SetEdns0(4096, false)
+		w.WriteMsg(m)
+	}), IdleTimeout: 30 * time.Second})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err.Error())
+	}
+	defer c.Close()
+	keepalive := func(r *Msg) *EDNS0_TCP_KEEPALIVE {
+		if opt := r.IsEdns0(); opt != nil {
+			if o, ok := opt.GetOption(EDNS0TCPKEEPALIVE).(*EDNS0_TCP_KEEPALIVE); ok {
+				return o
+			}
+		}
+		return nil
+	}
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeTXT)
+	m.SetEdns0(4096, false)
+	r, err := tcpQuery(c, m, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to exchange: %s", err.Error())
+	}
+	if keepalive(r) != nil {
+		t.Error("unexpected edns-tcp-keepalive in the reply to a query without it")
+	}
+	m.IsEdns0().SetOption(&EDNS0_TCP_KEEPALIVE{Code: EDNS0TCPKEEPALIVE})
+	if r, err = tcpQuery(c, m, 2*time.Second); err != nil {
+		t.Fatalf("failed to exchange: %s", err.Error())
+	}
+	// The timeout is in units of 100 milliseconds
+	if o := keepalive(r); o == nil || o.Timeout != 300 {
+		t.Errorf("expected an edns-tcp-keepalive with a timeout of 300, got %v", o)
+	}
+}
+
+func TestOverload(t *testing.T) {
+	for _, workers := range []int{0, 1} {
+		for _, mode := range []int{OverloadDrop, OverloadServfail, OverloadRefused} {
+			entered, unblock := make(chan bool), make(chan bool)
+			h := HandlerFunc(func(w ResponseWriter, req *Msg) {
+				if req.Question[0].Name == "block." {
+					entered <- true
+					<-unblock
+				}
+				m := new(Msg)
+				m.SetReply(req)
+				w.WriteMsg(m)
+			})
+			l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatalf("failed to listen: %s", err.Error())
+			}
+			go (&Server{Handler: h, MaxQueries: 1, Workers: workers, Overload: mode}).serveUDP(l)
+			addr := l.LocalAddr().String()
+			c := &Client{ReadTimeout: 300 * time.Millisecond}
+
+			block := new(Msg)
+			block.SetQuestion("block.", TypeA)
+			done := make(chan error)
+			go func() {
+				_, _, err := (&Client{ReadTimeout: 5 * time.Second}).Exchange(block, addr)
+				done <- err
+			}()
+			<-entered
+			// MaxQueries is reached
+			m := new(Msg)
+			m.SetQuestion("miek.nl.", TypeA)
+			r, _, err := c.Exchange(m, addr)
+			switch mode {
+			case OverloadDrop:
+				if err == nil {
+					t.Errorf("workers %d: expected no reply with OverloadDrop, got %v", workers, r)
+				}
+			case OverloadServfail, OverloadRefused:
+				rcode := RcodeServerFailure
+				if mode == OverloadRefused {
+					rcode = RcodeRefused
+				}
+				if err != nil || r.Rcode != rcode {
+					t.Errorf("workers %d: expected %s, got %v, %v", workers, RcodeToString[rcode], r, err)
+				}
+			}
+			close(unblock)
+			if err := <-done; err != nil {
+				t.Errorf("workers %d: failed to exchange the blocked query: %s", workers, err.Error())
+			}
+			// There is room again
+			if r, _, err := c.Exchange(m, addr); err != nil || r.Rcode != RcodeSuccess {
+				t.Errorf("workers %d: expected an answer after the query is done, got %v, %v", workers, r, err)
+			}
+			l.Close()
+		}
+	}
+}
+
+func TestMaxUDPSize(t *testing.T) {
+	sizes := make(chan uint16, 1)
+	h := HandlerFunc(func(w ResponseWriter, req *Msg) {
+		sizes <- req.IsEdns0().UDPSize()
+		m := new(Msg)
+		m.SetReply(req)
+		m.SetEdns0(4096, false)
+		for i := 0; i < 10; i++ {
+			txt := &TXT{Hdr: RR_Header{req.Question[0].Name, TypeTXT, ClassINET, 3600, 0}, Txt: []string{strings.Repeat("x", 150)}}
+			txt.Txt[0] += string(rune('a' + i))
+			m.Answer = append(m.Answer, txt)
+		}
+		w.WriteMsg(m)
+	})
+	for _, max := range []uint16{0, 4096} {
+		l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		go (&Server{Handler: h, MaxUDPSize: max}).serveUDP(l)
+		c, err := net.Dial("udp", l.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %s", err.Error())
+		}
+		// The query advertises 4096 bytes, the reply is about 1600
+		m := new(Msg)
+		m.SetQuestion("miek.nl.", TypeTXT)
+		m.SetEdns0(4096, false)
+		buf, _ := m.Pack()
+		c.Write(buf)
+		c.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf = make([]byte, 4096)
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatalf("failed to read the reply: %s", err.Error())
+		}
+		<-sizes
+		r := new(Msg)
+		if err := r.Unpack(buf[:n]); err != nil {
+			t.Fatalf("failed to unpack the reply: %s", err.Error())
+		}
+		switch max {
+		case 0:
+			// FlagDayMsgSize
+			if !r.Truncated || len(r.Answer) != 0 || n > FlagDayMsgSize {
+				t.Errorf("expected a truncated reply of at most %d bytes, got %d bytes: %v", FlagDayMsgSize, n, r)
+			}
+			if opt := r.IsEdns0(); opt == nil || opt.UDPSize() != FlagDayMsgSize {
+				t.Errorf("expected %d as the advertised size, got %v", FlagDayMsgSize, opt)
+			}
+		default:
+			if r.Truncated || len(r.Answer) != 10 {
+				t.Errorf("expected the whole reply with MaxUDPSize %d, got %v", max, r)
+			}
+			if opt := r.IsEdns0(); opt == nil || opt.UDPSize() != max {
+				t.Errorf("expected %d as the advertised size, got %v", max, opt)
+			}
+		}
+		c.Close()
+
+		// The client caps the size it advertises too
+		for _, cmax := range []uint16{0, 2048} {
+			m := new(Msg)
+			m.SetQuestion("miek.nl.", TypeTXT)
+			m.SetEdns0(4096, false)
+			(&Client{MaxUDPSize: cmax}).Exchange(m, l.LocalAddr().String())
+			expected := cmax
+			if expected == 0 {
+				expected = FlagDayMsgSize
+			}
+			if size := <-sizes; size != expected {
+				t.Errorf("expected the client to advertise %d, got %d", expected, size)
+			}
+		}
+		l.Close()
+	}
+}
+
+func TestServerSocketOptions(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	srv := &Server{Handler: HandlerFunc(HelloServer), ReadBuffer: 1 << 18, WriteBuffer: 1 << 18, TOS: 0x28 << 2, PMTUDisc: PMTUDiscOmit}
+	if err := srv.setupUDP(l); err != nil {
+		t.Fatalf("failed to set the socket options: %s", err.Error())
+	}
+	go srv.serveUDP(l)
+
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeTXT)
+	r, _, err := new(Client).Exchange(m, l.LocalAddr().String())
+	if err != nil || len(r.Extra) != 1 {
+		t.Fatalf("failed to exchange: %v, %v", r, err)
+	}
+	if srv.Drops() != 0 {
+		t.Errorf("expected no drops, got %d", srv.Drops())
+	}
+}
+
+func TestHandleNotFound(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("miek.nl.", HandlerFunc(HelloServer))
+	q := new(Msg)
+	q.SetQuestion("www.example.org.", TypeA)
+	w := new(testWriter)
+	mux.ServeDNS(w, q)
+	if w.msg == nil || w.msg.Rcode != RcodeServerFailure {
+		t.Errorf("expected SERVFAIL by default, got %v", w.msg)
+	}
+	mux.HandleNotFound(HandlerFunc(HandleRefused))
+	mux.ServeDNS(w, q)
+	if w.msg == nil || w.msg.Rcode != RcodeRefused || len(w.msg.Question) != 1 {
+		t.Errorf("expected REFUSED, got %v", w.msg)
+	}
+	q.SetQuestion("www.miek.nl.", TypeTXT)
+	mux.ServeDNS(w, q)
+	if w.msg == nil || w.msg.Rcode != RcodeSuccess {
+		t.Errorf("expected the registered handler to answer, got %v", w.msg)
+	}
+}
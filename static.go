@@ -0,0 +1,201 @@
+// STATIC RECORDS
+//
+// A StaticHandler serves a small set of locally configured records, without
+// the need to set up a full Zone. It is modelled after the address= and
+// host-record= options of dnsmasq:
+//
+//	s := dns.NewStaticHandler()
+//	s.Host("router.lan.", "192.168.1.1", "fd00::1")  // Also adds the PTR records
+//	s.Address("ads.example.com.", "0.0.0.0")          // Matches ads.example.com. and all names below it
+//	s.Insert(rr)                                     // Any other record
+//	s.Next = handler                                 // Optional, handles all other names
+//	dns.Handle(".", s)
+package dns
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// StaticHandler is a Handler that answers from a set of local records. Names
+// that are not known are handed to Next, or when Next is nil, answered with
+// NXDOMAIN. It's safe for concurrent use by multiple goroutines.
+type StaticHandler struct {
+	Ttl     uint32          // TTL for the records created by Host and Address, defaults to 0
+	Next    Handler         // If not nil, called for names not found
+	names   map[string][]RR // Records, keyed on the lower cased owner name
+	address map[string][]RR // Records that also match all names below the owner
+	*sync.RWMutex
+}
+
+// NewStaticHandler returns an empty StaticHandler.
+func NewStaticHandler() *StaticHandler {
+	s := new(StaticHandler)
+	s.names = make(map[string][]RR)
+	s.address = make(map[string][]RR)
+	s.RWMutex = new(sync.RWMutex)
+	return s
+}
+
+// Insert adds the record r. Records with the same owner, type and rdata are
+// only added once.
+func (s *StaticHandler) Insert(r RR) {
+	s.Lock()
+	defer s.Unlock()
+	s.names = insertStatic(s.names, r)
+}
+
+// Remove removes the record r, the TTL is not used in the comparison.
+func (s *StaticHandler) Remove(r RR) {
+	s.Lock()
+	defer s.Unlock()
+	key := strings.ToLower(r.Header().Name)
+	rrs := s.names[key]
+	for i, r1 := range rrs {
+		if sameRdata(r, r1) {
+			rrs = append(rrs[:i], rrs[i+1:]...)
+			break
+		}
+	}
+	if len(rrs) == 0 {
+		delete(s.names, key)
+		return
+	}
+	s.names[key] = rrs
+}
+
+// Host adds A and AAAA records for name and a PTR record pointing back to name
+// for each address.
+func (s *StaticHandler) Host(name string, addr ...string) error {
+	rrs, err := s.addressRRs(name, addr)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	for i, r := range rrs {
+		s.names = insertStatic(s.names, r)
+		arpa, _ := ReverseAddr(addr[i])
+		ptr := &PTR{Hdr: RR_Header{Name: arpa, Rrtype: TypePTR, Class: ClassINET, Ttl: s.Ttl}, Ptr: r.Header().Name}
+		s.names = insertStatic(s.names, ptr)
+	}
+	return nil
+}
+
+// Address adds A and AAAA records for name, which are also used for all names
+// below name.
+func (s *StaticHandler) Address(name string, addr ...string) error {
+	rrs, err := s.addressRRs(name, addr)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, r := range rrs {
+		s.address = insertStatic(s.address, r)
+	}
+	return nil
+}
+
+func (s *StaticHandler) addressRRs(name string, addr []string) ([]RR, error) {
+	if _, _, ok := IsDomainName(name); !ok {
+		return nil, &Error{Err: "bad domain name", Name: name}
+	}
+	name = Fqdn(name)
+	rrs := make([]RR, len(addr))
+	for i, a := range addr {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return nil, &Error{Err: "unrecognized address", Name: a}
+		}
+		hdr := RR_Header{Name: name, Class: ClassINET, Ttl: s.Ttl}
+		if ip4 := ip.To4(); ip4 != nil {
+			hdr.Rrtype = TypeA
+			rrs[i] = &A{Hdr: hdr, A: ip4}
+			continue
+		}
+		hdr.Rrtype = TypeAAAA
+		rrs[i] = &AAAA{Hdr: hdr, AAAA: ip}
+	}
+	return rrs, nil
+}
+
+func insertStatic(m map[string][]RR, r RR) map[string][]RR {
+	key := strings.ToLower(r.Header().Name)
+	for _, r1 := range m[key] {
+		if sameRdata(r, r1) {
+			return m
+		}
+	}
+	m[key] = append(m[key], r)
+	return m
+}
+
+// sameRdata returns true when r1 and r2 have the same owner, type, class
+// and rdata.
+func sameRdata(r1, r2 RR) bool {
+	h1, h2 := r1.Header(), r2.Header()
+	if h1.Rrtype != h2.Rrtype || h1.Class != h2.Class || !strings.EqualFold(h1.Name, h2.Name) {
+		return false
+	}
+	c1, c2 := r1.Copy(), r2.Copy()
+	c1.Header().Ttl, c2.Header().Ttl = 0, 0
+	return c1.String() == c2.String()
+}
+
+// lookup returns the records for name, records added with Address have their
+// owner name set to name.
+func (s *StaticHandler) lookup(name string) []RR {
+	key := strings.ToLower(name)
+	if rrs, ok := s.names[key]; ok {
+		return rrs
+	}
+	for off, end := 0, false; !end; off, end = NextLabelOffset(key, off) {
+		if rrs, ok := s.address[key[off:]]; ok {
+			ret := make([]RR, len(rrs))
+			for i, r := range rrs {
+				ret[i] = r.Copy()
+				ret[i].Header().Name = name
+			}
+			return ret
+		}
+	}
+	return nil
+}
+
+// ServeDNS implements the Handler interface.
+func (s *StaticHandler) ServeDNS(w ResponseWriter, req *Msg) {
+	if len(req.Question) != 1 {
+		s.next(w, req)
+		return
+	}
+	q := req.Question[0]
+	s.RLock()
+	rrs := s.lookup(Fqdn(q.Name))
+	s.RUnlock()
+	if rrs == nil {
+		s.next(w, req)
+		return
+	}
+	m := new(Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+	for _, r := range rrs {
+		t := r.Header().Rrtype
+		if t == q.Qtype || q.Qtype == TypeANY || t == TypeCNAME {
+			m.Answer = append(m.Answer, r)
+		}
+	}
+	w.WriteMsg(m)
+}
+
+func (s *StaticHandler) next(w ResponseWriter, req *Msg) {
+	if s.Next != nil {
+		s.Next.ServeDNS(w, req)
+		return
+	}
+	m := new(Msg)
+	m.SetRcode(req, RcodeNameError)
+	w.WriteMsg(m)
+}
@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestStaticHandler(t *testing.T) {
+	s := NewStaticHandler()
+	s.Ttl = 60
+	if err := s.Host("router.lan.", "192.168.1.1", "fd00::1"); err != nil {
+		t.Fatalf("failed to add host: %s", err.Error())
+	}
+	if err := s.Address("ads.example.com", "0.0.0.0"); err != nil {
+		t.Fatalf("failed to add address: %s", err.Error())
+	}
+	if err := s.Host("bad..name.", "192.168.1.2"); err == nil {
+		t.Error("expected an error for a bad name")
+	}
+	if err := s.Address("x.lan.", "not an address"); err == nil {
+		t.Error("expected an error for a bad address")
+	}
+	txt, _ := NewRR(`router.lan. 60 IN TXT "the router"`)
+	s.Insert(txt)
+	s.Insert(txt) // only added once
+
+	query := func(name string, qtype uint16) *Msg {
+		req := new(Msg)
+		req.SetQuestion(name, qtype)
+		w := new(testWriter)
+		s.ServeDNS(w, req)
+		if w.msg == nil {
+			t.Fatalf("no reply for %s", name)
+		}
+		return w.msg
+	}
+	tests := []struct {
+		name   string
+		qtype  uint16
+		answer []string
+	}{
+		{"router.lan.", TypeA, []string{"router.lan.\t60\tIN\tA\t192.168.1.1"}},
+		{"ROUTER.lan.", TypeAAAA, []string{"router.lan.\t60\tIN\tAAAA\tfd00::1"}},
+		{"router.lan.", TypeTXT, []string{"router.lan.\t60\tIN\tTXT\t\"the router\""}},
+		{"router.lan.", TypeMX, nil},
+		{"1.1.168.192.in-addr.arpa.", TypePTR, []string{"1.1.168.192.in-addr.arpa.\t60\tIN\tPTR\trouter.lan."}},
+		{"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.", TypePTR, []string{"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa.\t60\tIN\tPTR\trouter.lan."}},
+		{"ads.example.com.", TypeA, []string{"ads.example.com.\t60\tIN\tA\t0.0.0.0"}},
+		{"www.Ads.example.com.", TypeA, []string{"www.Ads.example.com.\t60\tIN\tA\t0.0.0.0"}},
+	}
+	for _, tc := range tests {
+		m := query(tc.name, tc.qtype)
+		if m.Rcode != RcodeSuccess || !m.Authoritative || len(m.Answer) != len(tc.answer) {
+			t.Errorf("%s %s: unexpected reply %v", tc.name, TypeToString[tc.qtype], m)
+			continue
+		}
+		for i, a := range tc.answer {
+			if m.Answer[i].String() != a {
+				t.Errorf("%s %s: expected %q, got %q", tc.name, TypeToString[tc.qtype], a, m.Answer[i].String())
+			}
+		}
+	}
+	if m := query("notads.example.com.", TypeA); m.Rcode != RcodeNameError {
+		t.Errorf("expected NXDOMAIN for notads.example.com., got %s", RcodeToString[m.Rcode])
+	}
+
+	s.Remove(txt)
+	if m := query("router.lan.", TypeTXT); m.Rcode != RcodeSuccess || len(m.Answer) != 0 {
+		t.Errorf("expected no TXT record after Remove, got %v", m)
+	}
+
+	// Names that are not found go to Next
+	var next string
+	s.Next = HandlerFunc(func(w ResponseWriter, req *Msg) {
+		next = req.Question[0].Name
+		m := new(Msg)
+		m.SetRcode(req, RcodeRefused)
+		w.WriteMsg(m)
+	})
+	if m := query("www.example.org.", TypeA); m.Rcode != RcodeRefused || next != "www.example.org." {
+		t.Errorf("expected www.example.org. to be handed to Next, got %v", m)
+	}
+	next = ""
+	if m := query("router.lan.", TypeA); len(m.Answer) != 1 || next != "" {
+		t.Error("router.lan. must not be handed to Next")
+	}
+}
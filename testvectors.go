@@ -0,0 +1,291 @@
+// TEST VECTORS
+//
+// A test vector describes a query (or a raw packet) and the reply a handler
+// should give to it. Test vectors are kept in files with the extension .vec,
+// this package ships a corpus in testdata/vectors, with signed and unsigned
+// zones, malformed packets and examples from the RFCs. A vector file holds
+// "key: value" lines, the RRs of the sections of the reply are given on the
+// (indented) lines that follow the section key, in the zone file format:
+//
+//	; Comments start with a semicolon
+//	description: positive answer from a signed zone
+//	zone: example.signed example.          ; zone file (relative to the .vec file) and origin
+//	query: www.example. A do               ; qname, qtype and optionally "do"
+//	rcode: NOERROR
+//	flags: aa                              ; flags of the reply, besides qr (and rd when it is set in the query)
+//	answer:
+//		www.example. 3600 IN A 192.0.2.1
+//	authority:
+//	additional:
+//
+// A vector can also hold a raw message in hex ("packet: 0001 0100 ...", the
+// key may be repeated), with "malformed: yes" when the message must not be
+// accepted. Basic use pattern for running the corpus against a handler:
+//
+//	vectors, err := dns.LoadTestVectors("testdata/vectors")
+//	for _, v := range vectors {
+//		if d := v.Check(handler); d != "" {
+//			t.Errorf("%s: %s", v.Name, d)
+//		}
+//	}
+package dns
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestVector is a single test vector, read with LoadTestVectors.
+type TestVector struct {
+	Name        string // Name of the file, without the .vec extension
+	Description string
+	Zone        *Zone  // The zone the reply is generated from, may be nil
+	Query       *Msg   // The query, may be nil for packet only vectors
+	Packet      []byte // Raw message, may be nil
+	Malformed   bool   // If true, Packet must not unpack
+	Reply       *Msg   // The expected reply, nil if the vector has none
+}
+
+// LoadTestVectors reads all the test vectors (*.vec) in the directory dir,
+// sorted on their name.
+func LoadTestVectors(dir string) ([]*TestVector, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.vec"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	vectors := make([]*TestVector, 0, len(files))
+	for _, f := range files {
+		v, err := ReadTestVector(f)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+// ReadTestVector reads the test vector in file.
+func ReadTestVector(file string) (*TestVector, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	v := &TestVector{Name: strings.TrimSuffix(filepath.Base(file), ".vec")}
+	var (
+		section *[]RR
+		reply   *Msg
+	)
+	bad := func(msg string) error { return &Error{Err: msg, Name: file} }
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, ";"); i >= 0 && !strings.Contains(line[:i], `"`) {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if section == nil {
+				return nil, bad("RR outside of a section")
+			}
+			rr, err := NewRR(strings.TrimSpace(line))
+			if err != nil {
+				return nil, err
+			}
+			*section = append(*section, rr)
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, bad("no key in line: " + line)
+		}
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		fields := strings.Fields(value)
+		section = nil
+		if reply == nil && (key == "rcode" || key == "flags" || key == "answer" || key == "authority" || key == "additional") {
+			reply = new(Msg)
+		}
+		switch key {
+		case "description":
+			v.Description = value
+		case "zone":
+			if len(fields) != 2 {
+				return nil, bad("zone needs a file and an origin")
+			}
+			v.Zone, err = readTestZone(filepath.Join(filepath.Dir(file), fields[0]), fields[1])
+			if err != nil {
+				return nil, err
+			}
+		case "query":
+			if len(fields) < 2 {
+				return nil, bad("query needs a name and a type")
+			}
+			t, ok := StringToType[strings.ToUpper(fields[1])]
+			if !ok {
+				return nil, bad("unknown type " + fields[1])
+			}
+			v.Query = new(Msg)
+			v.Query.SetQuestion(Fqdn(fields[0]), t)
+			v.Query.Id = 0
+			if len(fields) > 2 && strings.ToLower(fields[2]) == "do" {
+				v.Query.SetEdns0(4096, true)
+			}
+		case "packet":
+			b, err := hex.DecodeString(strings.Join(fields, ""))
+			if err != nil {
+				return nil, err
+			}
+			v.Packet = append(v.Packet, b...)
+		case "malformed":
+			v.Malformed = value == "yes" || value == "true"
+		case "rcode":
+			rcode, ok := StringToRcode[strings.ToUpper(value)]
+			if !ok {
+				return nil, bad("unknown rcode " + value)
+			}
+			reply.Rcode = rcode
+		case "flags":
+			for _, flag := range fields {
+				switch strings.ToLower(flag) {
+				case "aa":
+					reply.Authoritative = true
+				case "tc":
+					reply.Truncated = true
+				case "ra":
+					reply.RecursionAvailable = true
+				case "ad":
+					reply.AuthenticatedData = true
+				case "cd":
+					reply.CheckingDisabled = true
+				default:
+					return nil, bad("unknown flag " + flag)
+				}
+			}
+		case "answer":
+			section = &reply.Answer
+		case "authority":
+			section = &reply.Ns
+		case "additional":
+			section = &reply.Extra
+		default:
+			return nil, bad("unknown key " + key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if reply != nil {
+		q := v.Query
+		if q == nil && v.Packet != nil {
+			q = new(Msg)
+			if q.Unpack(v.Packet) != nil {
+				q = nil
+			}
+		}
+		if q == nil {
+			return nil, bad("reply without a query")
+		}
+		rcode, hdr := reply.Rcode, reply.MsgHdr
+		reply.SetReply(q)
+		reply.Rcode = rcode
+		reply.Authoritative = hdr.Authoritative
+		reply.Truncated = hdr.Truncated
+		reply.RecursionAvailable = hdr.RecursionAvailable
+		reply.AuthenticatedData = hdr.AuthenticatedData
+		reply.CheckingDisabled = hdr.CheckingDisabled
+		v.Reply = reply
+	}
+	return v, nil
+}
+
+// readTestZone reads the zone in file into a Zone.
+func readTestZone(file, origin string) (*Zone, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	z := NewZone(origin)
+	if z == nil {
+		return nil, &Error{Err: "bad origin", Name: origin}
+	}
+	for x := range ParseZone(f, z.Origin, file) {
+		if x.Error != nil {
+			return nil, x.Error
+		}
+		if err := z.Insert(x.RR); err != nil {
+			return nil, err
+		}
+	}
+	return z, nil
+}
+
+// Check runs the test vector against h and returns a description of what is
+// wrong, or an empty string if the vector passes. The packet, if any, must
+// unpack unless the vector is malformed; when there is no query, the packet
+// is given to h. The reply of h is compared with MsgDiff, the OPT record in
+// the additional section is not compared. If h is nil, only the packet is
+// checked.
+func (v *TestVector) Check(h Handler) string {
+	q := v.Query
+	if v.Packet != nil {
+		m := new(Msg)
+		err := m.Unpack(v.Packet)
+		switch {
+		case v.Malformed && err == nil:
+			return "malformed packet unpacks"
+		case v.Malformed:
+			return ""
+		case err != nil:
+			return "packet does not unpack: " + err.Error()
+		}
+		if q == nil {
+			q = m
+		}
+	}
+	if h == nil || q == nil || v.Reply == nil {
+		return ""
+	}
+	w := &vectorWriter{info: RequestInfo{Wire: v.Packet, Received: time.Now(), Net: "udp"}}
+	if v.Query != nil {
+		w.info.Wire, _ = v.Query.Pack()
+	}
+	h.ServeDNS(w, q)
+	if w.msg == nil {
+		return "no reply"
+	}
+	got := *w.msg
+	got.Extra = nil
+	for _, r := range w.msg.Extra {
+		if r.Header().Rrtype != TypeOPT {
+			got.Extra = append(got.Extra, r)
+		}
+	}
+	return MsgDiff(v.Reply, &got)
+}
+
+// vectorWriter is the ResponseWriter used by Check, it records the reply.
+type vectorWriter struct {
+	msg  *Msg
+	info RequestInfo
+}
+
+func (w *vectorWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53000}
+}
+func (w *vectorWriter) WriteMsg(m *Msg) error       { w.msg = m; return nil }
+func (w *vectorWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *vectorWriter) Close() error                { return nil }
+func (w *vectorWriter) TsigStatus() error           { return nil }
+func (w *vectorWriter) TsigTimersOnly(bool)         {}
+func (w *vectorWriter) Hijack()                     {}
+func (w *vectorWriter) RequestInfo() *RequestInfo   { return &w.info }
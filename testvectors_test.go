@@ -0,0 +1,50 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestLoadTestVectors(t *testing.T) {
+	vectors, err := LoadTestVectors("testdata/vectors")
+	if err != nil {
+		t.Fatalf("failed to load the test vectors: %s", err.Error())
+	}
+	if len(vectors) == 0 {
+		t.Fatalf("no test vectors found")
+	}
+	k, _, err := ReadKeyFiles("testdata/vectors/Kexample.+015+34258")
+	if err != nil {
+		t.Fatalf("failed to read the key of the signed zone: %s", err.Error())
+	}
+	for _, v := range vectors {
+		if d := v.Check(nil); d != "" {
+			t.Errorf("%s: %s", v.Name, d)
+		}
+		if v.Reply == nil {
+			continue
+		}
+		// A handler that gives the expected reply must pass
+		h := HandlerFunc(func(w ResponseWriter, r *Msg) { w.WriteMsg(v.Reply) })
+		if d := v.Check(h); d != "" {
+			t.Errorf("%s: %s", v.Name, d)
+		}
+		// The signatures in the signed vectors must be valid
+		for _, section := range [][]RR{v.Reply.Answer, v.Reply.Ns} {
+			for _, r := range section {
+				sig, ok := r.(*RRSIG)
+				if !ok {
+					continue
+				}
+				var rrset []RR
+				for _, r1 := range section {
+					if r1.Header().Rrtype == sig.TypeCovered && r1.Header().Name == sig.Hdr.Name {
+						rrset = append(rrset, r1)
+					}
+				}
+				if err := sig.Verify(k, rrset); err != nil {
+					t.Errorf("%s: signature for %s/%s does not verify: %s", v.Name, sig.Hdr.Name, TypeToString[sig.TypeCovered], err.Error())
+				}
+			}
+		}
+	}
+}
@@ -3,41 +3,35 @@ package dns
 import (
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
-	"io"
 	"net"
 	"strconv"
+	"strings"
 )
 
 // CertificateToDANE converts a certificate to a hex string as used in the TLSA record.
+// The empty string is returned for an unknown selector or matching type.
 func CertificateToDANE(selector, matchingType uint8, cert *x509.Certificate) string {
+	var data []byte
+	switch selector {
+	case 0:
+		data = cert.Raw
+	case 1:
+		data = cert.RawSubjectPublicKeyInfo
+	default:
+		return ""
+	}
 	switch matchingType {
 	case 0:
-		switch selector {
-		case 0:
-			return hex.EncodeToString(cert.Raw)
-		case 1:
-			return hex.EncodeToString(cert.RawSubjectPublicKeyInfo)
-		}
+		return hex.EncodeToString(data)
 	case 1:
-		h := sha256.New()
-		switch selector {
-		case 0:
-			return hex.EncodeToString(cert.Raw)
-		case 1:
-			io.WriteString(h, string(cert.RawSubjectPublicKeyInfo))
-			return hex.EncodeToString(h.Sum(nil))
-		}
+		h := sha256.Sum256(data)
+		return hex.EncodeToString(h[:])
 	case 2:
-		h := sha512.New()
-		switch selector {
-		case 0:
-			return hex.EncodeToString(cert.Raw)
-		case 1:
-			io.WriteString(h, string(cert.RawSubjectPublicKeyInfo))
-			return hex.EncodeToString(h.Sum(nil))
-		}
+		h := sha512.Sum512(data)
+		return hex.EncodeToString(h[:])
 	}
 	return ""
 }
@@ -50,13 +44,16 @@ func (r *TLSA) Sign(usage, selector, matchingType int, cert *x509.Certificate) e
 	r.MatchingType = uint8(matchingType)
 
 	r.Certificate = CertificateToDANE(r.Selector, r.MatchingType, cert)
+	if r.Certificate == "" {
+		return ErrAlg
+	}
 	return nil
 }
 
 // Verify verifies a TLSA record against an SSL certificate. If it is OK
 // a nil error is returned.
 func (r *TLSA) Verify(cert *x509.Certificate) error {
-	if r.Certificate == CertificateToDANE(r.Selector, r.MatchingType, cert) {
+	if d := CertificateToDANE(r.Selector, r.MatchingType, cert); d != "" && strings.EqualFold(r.Certificate, d) {
 		return nil
 	}
 	return ErrSig // ErrSig, really?
@@ -73,5 +70,98 @@ func TLSAName(name, service, network string) string {
 	if e != nil {
 		return ""
 	}
-	return "_" + strconv.Itoa(p) + "_" + network + "." + name
+	return "_" + strconv.Itoa(p) + "._" + network + "." + name
+}
+
+// DANE certificate usages, RFC 7218.
+const (
+	DANEPKIXTA = 0 // CA constraint, the chain must also validate with PKIX
+	DANEPKIXEE = 1 // Service certificate constraint, the chain must also validate with PKIX
+	DANETA     = 2 // Trust anchor assertion
+	DANEEE     = 3 // Domain-issued certificate, the certificate is not checked any further
+)
+
+// VerifyDANE verifies the certificates of the TLS connection cs against the
+// TLSA records in rrs (other RRs are ignored), as described in RFC 6698 and
+// RFC 7671. It returns nil when one of the records matches. The usages
+// DANEPKIXTA and DANEPKIXEE also require the certificate chain to validate
+// against roots (if nil, the system roots) for cs.ServerName. The TLSA
+// records should be validated with DNSSEC, otherwise DANE is worthless.
+func VerifyDANE(rrs []RR, cs tls.ConnectionState, roots *x509.CertPool) error {
+	certs := cs.PeerCertificates
+	if len(certs) == 0 {
+		return ErrTLSA
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	var chains [][]*x509.Certificate
+	pkix := func() bool {
+		if chains == nil {
+			chains, _ = certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: cs.ServerName})
+			if chains == nil {
+				chains = [][]*x509.Certificate{}
+			}
+		}
+		return len(chains) > 0
+	}
+	for _, r := range rrs {
+		t, ok := r.(*TLSA)
+		if !ok {
+			continue
+		}
+		switch t.Usage {
+		case DANEEE:
+			if t.Verify(certs[0]) == nil {
+				return nil
+			}
+		case DANEPKIXEE:
+			if t.Verify(certs[0]) == nil && pkix() {
+				return nil
+			}
+		case DANEPKIXTA:
+			if !pkix() {
+				continue
+			}
+			for _, chain := range chains {
+				for _, c := range chain[1:] {
+					if t.Verify(c) == nil {
+						return nil
+					}
+				}
+			}
+		case DANETA:
+			for _, c := range certs[1:] {
+				if t.Verify(c) != nil {
+					continue
+				}
+				anchor := x509.NewCertPool()
+				anchor.AddCert(c)
+				if _, err := certs[0].Verify(x509.VerifyOptions{Roots: anchor, Intermediates: intermediates, DNSName: cs.ServerName}); err == nil {
+					return nil
+				}
+			}
+		}
+	}
+	return ErrTLSA
+}
+
+// DANEConfig returns a copy of config (which may be nil) that verifies the
+// server's certificates with VerifyDANE against the TLSA records in rrs,
+// instead of with the normal PKIX validation. Use it as the TLSConfig of a
+// Client for a DANE authenticated DNS over TLS upstream:
+//
+//	c := &dns.Client{Net: "tcp-tls", TLSConfig: dns.DANEConfig(nil, tlsa)}
+func DANEConfig(config *tls.Config, rrs []RR) *tls.Config {
+	if config == nil {
+		config = new(tls.Config)
+	}
+	config = config.Clone()
+	roots := config.RootCAs
+	config.InsecureSkipVerify = true
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		return VerifyDANE(rrs, cs, roots)
+	}
+	return config
 }
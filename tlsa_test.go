@@ -0,0 +1,176 @@
+package dns
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err.Error())
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dot.example.org"},
+		DNSNames:     []string{"dot.example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err.Error())
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func TestDANE(t *testing.T) {
+	cert := selfSigned(t)
+	for selector := 0; selector < 2; selector++ {
+		for matchingType := 0; matchingType < 3; matchingType++ {
+			r := &TLSA{Hdr: RR_Header{Name: "_853._tcp.dot.example.org.", Class: ClassINET}}
+			if err := r.Sign(DANEEE, selector, matchingType, cert.Leaf); err != nil {
+				t.Fatalf("failed to create TLSA %d %d: %s", selector, matchingType, err.Error())
+			}
+			if err := r.Verify(cert.Leaf); err != nil {
+				t.Errorf("TLSA %d %d does not verify", selector, matchingType)
+			}
+		}
+	}
+	if n := TLSAName("dot.example.org.", "domain-s", "tcp"); n != "_853._tcp.dot.example.org." {
+		t.Errorf("TLSAName returned %s", n)
+	}
+
+	good, _ := NewRR("_853._tcp.dot.example.org. IN TLSA 3 1 1 " + CertificateToDANE(1, 1, cert.Leaf))
+	bad, _ := NewRR("_853._tcp.dot.example.org. IN TLSA 1 1 1 " + CertificateToDANE(1, 1, cert.Leaf))
+	handshake := func(rrs []RR) error {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		defer l.Close()
+		go func() {
+			s, err := l.Accept()
+			if err != nil {
+				return
+			}
+			tls.Server(s, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+			s.Close()
+		}()
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %s", err.Error())
+		}
+		defer c.Close()
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		return tls.Client(c, DANEConfig(&tls.Config{ServerName: "dot.example.org"}, rrs)).Handshake()
+	}
+	if err := handshake([]RR{good}); err != nil {
+		t.Errorf("DANE-EE handshake failed: %s", err.Error())
+	}
+	// PKIX-EE must fail, the certificate is self-signed
+	if err := handshake([]RR{bad}); err == nil {
+		t.Error("PKIX-EE handshake with a self-signed certificate succeeded")
+	}
+}
+
+func TestSPKIPins(t *testing.T) {
+	handshake := func(cert tls.Certificate, config *tls.Config) error {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		defer l.Close()
+		go func() {
+			s, err := l.Accept()
+			if err != nil {
+				return
+			}
+			tls.Server(s, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+			s.Close()
+		}()
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %s", err.Error())
+		}
+		defer c.Close()
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		return tls.Client(c, config).Handshake()
+	}
+	cert, other := selfSigned(t), selfSigned(t)
+	pins := NewSPKIPins(true)
+	config := PinnedConfig(&tls.Config{ServerName: "dot.example.org", InsecureSkipVerify: true}, "", pins)
+	if err := handshake(cert, config); err != nil {
+		t.Fatalf("first handshake failed: %s", err.Error())
+	}
+	if p := pins.Pins("DOT.example.org"); len(p) != 1 || p[0] != SPKIHash(cert.Leaf) {
+		t.Fatalf("key not pinned on first use: %v", p)
+	}
+	if err := handshake(cert, config); err != nil {
+		t.Errorf("handshake with the pinned key failed: %s", err.Error())
+	}
+	var perr *PinError
+	if err := handshake(other, config); !errors.As(err, &perr) || perr.Got[0] != SPKIHash(other.Leaf) {
+		t.Errorf("expected a pin error for another key, got %v", err)
+	}
+
+	pins = NewSPKIPins(false)
+	pins.Add("dot.example.org", SPKIHash(other.Leaf))
+	config = PinnedConfig(&tls.Config{InsecureSkipVerify: true}, "dot.example.org", pins)
+	if err := handshake(other, config); err != nil {
+		t.Errorf("handshake with a configured pin failed: %s", err.Error())
+	}
+	if err := handshake(cert, config); !errors.As(err, &perr) {
+		t.Errorf("expected a pin error, got %v", err)
+	}
+	// The pinned certificate in the chain doesn't help the wrong leaf
+	chain := tls.Certificate{Certificate: [][]byte{cert.Certificate[0], other.Certificate[0]}, PrivateKey: cert.PrivateKey}
+	if err := handshake(chain, config); !errors.As(err, &perr) {
+		t.Errorf("expected a pin error for an extra pinned certificate, got %v", err)
+	}
+}
+
+func TestCERTAndOPENPGPKEY(t *testing.T) {
+	cert := selfSigned(t)
+	c := &CERT{Hdr: RR_Header{Name: "dot.example.org.", Class: ClassINET}}
+	c.SetX509(cert.Leaf)
+	r, err := NewRR(c.String())
+	if err != nil {
+		t.Fatalf("failed to parse %s: %s", c.String(), err.Error())
+	}
+	x, err := r.(*CERT).X509()
+	if err != nil || !x.Equal(cert.Leaf) {
+		t.Errorf("certificate does not survive a round trip: %v", err)
+	}
+	if r, err := NewRR("example.org. IN CERT PGP 0 0 AQID"); err != nil || r.(*CERT).Type != CertPGP {
+		t.Errorf("failed to parse CERT with mnemonic type: %v", err)
+	}
+
+	if n := OPENPGPKEYName("hugh@example.com"); n != "c93f1e400f26708f98cb19d936620da35eec8f72e57f9eec01c1afd6._openpgpkey.example.com." {
+		t.Errorf("OPENPGPKEYName returned %s", n)
+	}
+	k := &OPENPGPKEY{Hdr: RR_Header{Name: OPENPGPKEYName("hugh@example.com"), Class: ClassINET, Ttl: 3600}}
+	k.SetKey([]byte{1, 2, 3, 4, 5})
+	buf := make([]byte, k.Len())
+	off, err := PackRR(k, buf, 0, nil, false)
+	if err != nil {
+		t.Fatalf("failed to pack OPENPGPKEY: %s", err.Error())
+	}
+	r, _, err = UnpackRR(buf[:off], 0)
+	if err != nil {
+		t.Fatalf("failed to unpack OPENPGPKEY: %s", err.Error())
+	}
+	if key, _ := r.(*OPENPGPKEY).Key(); string(key) != "\x01\x02\x03\x04\x05" {
+		t.Errorf("OPENPGPKEY key does not survive a round trip: %v", key)
+	}
+}
@@ -109,7 +109,7 @@ func (rr *TSIG) String() string {
 
 func (rr *TSIG) Len() int {
 	return rr.Hdr.Len() + len(rr.Algorithm) + 1 + 6 +
-		4 + len(rr.MAC)/2 + 1 + 6 + len(rr.OtherData)/2 + 1
+		4 + len(rr.MAC)/2 + 6 + len(rr.OtherData)/2
 }
 
 func (rr *TSIG) Copy() RR {
@@ -182,7 +182,7 @@ func TsigGenerate(m *Msg, secret, requestMAC string, timersOnly bool) ([]byte, s
 	case HmacSHA256:
 		h = hmac.New(sha256.New, []byte(rawsecret))
 	default:
-		return nil, "", ErrKeyAlg
+		return nil, "", wrapError(ErrTsig, ErrKeyAlg)
 	}
 	io.WriteString(h, string(buf))
 	t.MAC = hex.EncodeToString(h.Sum(nil))
@@ -207,22 +207,23 @@ func TsigGenerate(m *Msg, secret, requestMAC string, timersOnly bool) ([]byte, s
 
 // TsigVerify verifies the TSIG on a message. 
 // If the signature does not validate err contains the
-// error, otherwise it is nil.
+// error, otherwise it is nil. The error matches ErrTsig, use errors.Is
+// to look for the specific error, such as ErrSig or ErrTime.
 func TsigVerify(msg []byte, secret, requestMAC string, timersOnly bool) error {
 	rawsecret, err := packBase64([]byte(secret))
 	if err != nil {
-		return err
+		return wrapError(ErrTsig, err)
 	}
 	// Srtip the TSIG from the incoming msg
 	stripped, tsig, err := stripTsig(msg)
 	if err != nil {
-		return err
+		return wrapError(ErrTsig, err)
 	}
 
 	buf := tsigBuffer(stripped, tsig, requestMAC, timersOnly)
 	ti := uint64(time.Now().Unix()) - tsig.TimeSigned
 	if uint64(tsig.Fudge) < ti {
-		return ErrTime
+		return wrapError(ErrTsig, ErrTime)
 	}
 
 	var h hash.Hash
@@ -234,11 +235,11 @@ func TsigVerify(msg []byte, secret, requestMAC string, timersOnly bool) error {
 	case HmacSHA256:
 		h = hmac.New(sha256.New, []byte(rawsecret))
 	default:
-		return ErrKeyAlg
+		return wrapError(ErrTsig, ErrKeyAlg)
 	}
 	io.WriteString(h, string(buf))
 	if strings.ToUpper(hex.EncodeToString(h.Sum(nil))) != strings.ToUpper(tsig.MAC) {
-		return ErrSig
+		return wrapError(ErrTsig, ErrSig)
 	}
 	return nil
 }
@@ -0,0 +1,83 @@
+package dns
+
+// Long strings in TXT and SPF records.
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The maximum length of a character-string in a TXT record.
+const maxTxtString = 255
+
+// SplitTxt splits s in the character-strings of a TXT or SPF record: chunks
+// of at most 255 bytes. Use it for SPF policies and DKIM keys that are too
+// long for one string; JoinTxt gives s back. SplitTxt("") returns one empty
+// string.
+func SplitTxt(s string) []string {
+	txt := make([]string, 0, len(s)/maxTxtString+1)
+	for len(s) > maxTxtString {
+		txt = append(txt, s[:maxTxtString])
+		s = s[maxTxtString:]
+	}
+	return append(txt, s)
+}
+
+// JoinTxt concatenates the character-strings in txt, without spaces, the
+// way SPF (RFC 7208, section 3.3) and DKIM (RFC 6376, section 3.6.2.2)
+// read a TXT record.
+func JoinTxt(txt []string) string {
+	return strings.Join(txt, "")
+}
+
+// NewTXT returns a TXT record for name with the text s, split by SplitTxt.
+func NewTXT(name string, ttl uint32, s string) *TXT {
+	return &TXT{Hdr: RR_Header{Name: name, Rrtype: TypeTXT, Class: ClassINET, Ttl: ttl}, Txt: SplitTxt(s)}
+}
+
+// QuoteTxt returns s as a quoted character-string for a zone file (RFC 1035,
+// section 5.1): quotes and backslashes are escaped with a backslash, bytes
+// that are not printable ASCII are written as \DDD.
+func QuoteTxt(s string) string {
+	b := make([]byte, 0, len(s)+2)
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < ' ' || c > '~':
+			b = append(b, '\\')
+			if c < 100 {
+				b = append(b, '0')
+			}
+			if c < 10 {
+				b = append(b, '0')
+			}
+			b = strconv.AppendInt(b, int64(c), 10)
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(append(b, '"'))
+}
+
+// FormatTxt returns the rdata of a TXT or SPF record with the strings in
+// txt, for a zone file. A single string is returned quoted, more strings
+// are put between parentheses, one per line, as DKIM keys are usually
+// published:
+//
+//	( "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA..."
+//	  "...IDAQAB" )
+func FormatTxt(txt []string) string {
+	if len(txt) == 1 {
+		return QuoteTxt(txt[0])
+	}
+	s := "("
+	for i, t := range txt {
+		if i > 0 {
+			s += "\n "
+		}
+		s += " " + QuoteTxt(t)
+	}
+	return s + " )"
+}
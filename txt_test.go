@@ -0,0 +1,48 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitTxt(t *testing.T) {
+	key := "v=DKIM1; k=rsa; p=" + strings.Repeat("MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8A", 16)
+	txt := SplitTxt(key)
+	if len(txt) != 3 || len(txt[0]) != 255 || len(txt[1]) != 255 {
+		t.Fatalf("expected strings of 255, 255 and 20 bytes, got %d strings", len(txt))
+	}
+	if JoinTxt(txt) != key {
+		t.Error("joined strings differ from the key")
+	}
+	if txt := SplitTxt(""); len(txt) != 1 || txt[0] != "" {
+		t.Errorf("expected one empty string, got %q", txt)
+	}
+
+	rr := NewTXT("mail._domainkey.miek.nl.", 3600, key)
+	buf := make([]byte, rr.Len())
+	if _, err := PackRR(rr, buf, 0, nil, false); err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	rr1, err := NewRR("mail._domainkey.miek.nl. 3600 IN TXT " + FormatTxt(rr.Txt))
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err.Error())
+	}
+	if JoinTxt(rr1.(*TXT).Txt) != key {
+		t.Errorf("expected the key after parsing, got %s", rr1)
+	}
+
+	if s := QuoteTxt("a\"b\\c\x01é"); s != `"a\"b\\c\001\195\169"` {
+		t.Errorf("bad quoted string %s", s)
+	}
+}
+
+func TestTxtString(t *testing.T) {
+	for _, rr := range []RR{
+		&TXT{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeTXT, Class: ClassINET}, Txt: []string{"a\"b", "é"}},
+		&SPF{Hdr: RR_Header{Name: "miek.nl.", Rrtype: TypeSPF, Class: ClassINET}, Txt: []string{"a\"b", "é"}},
+	} {
+		if s := rr.String(); !strings.HasSuffix(s, `"a\"b" "\195\169"`) {
+			t.Errorf("bad presentation format %s", s)
+		}
+	}
+}
@@ -6,6 +6,7 @@
 package dns
 
 import (
+	"encoding/base32"
 	"encoding/base64"
 	"fmt"
 	"net"
@@ -67,6 +68,9 @@ const (
 	TypeRKEY       uint16 = 57
 	TypeTALINK     uint16 = 58
 	TypeCDS        uint16 = 59
+	TypeCDNSKEY    uint16 = 60
+	TypeOPENPGPKEY uint16 = 61
+	TypeZONEMD     uint16 = 63
 	TypeSPF        uint16 = 99
 	TypeNID        uint16 = 104
 	TypeL32        uint16 = 105
@@ -152,6 +156,39 @@ type Question struct {
 	Qclass uint16
 }
 
+// NewQuestion returns a question for name and type t in class IN.
+func NewQuestion(name string, t uint16) Question {
+	return Question{name, t, ClassINET}
+}
+
+// Equal returns true when q and o ask the same question, the names are
+// compared case insensitively for the ASCII letters only (RFC 4343).
+func (q *Question) Equal(o *Question) bool {
+	return q.Qtype == o.Qtype && q.Qclass == o.Qclass && equalFoldASCII(q.Name, o.Name)
+}
+
+// equalFoldASCII returns true when a and b are equal with upper case ASCII
+// letters lowercased. Other bytes, including those of UTF-8 sequences, must
+// be equal.
+func equalFoldASCII(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
+
 func (q *Question) String() (s string) {
 	// prefix with ; (as in dig)
 	if len(q.Name) == 0 {
@@ -162,7 +199,7 @@ func (q *Question) String() (s string) {
 	if _, ok := ClassToString[q.Qclass]; ok {
 		s += ClassToString[q.Qclass] + "\t"
 	} else {
-		s += "CLASS" + strconv.Itoa(int(q.Qtype))
+		s += "CLASS" + strconv.Itoa(int(q.Qclass)) + "\t"
 	}
 
 	if _, ok := TypeToString[q.Qtype]; ok {
@@ -184,33 +221,22 @@ type ANY struct {
 }
 
 func (rr *ANY) Header() *RR_Header { return &rr.Hdr }
-func (rr *ANY) Copy() RR           { return &ANY{*rr.Hdr.CopyHeader()} }
 
 func (rr *ANY) String() string {
 	return rr.Hdr.String()
 }
 
-func (rr *ANY) Len() int {
-	return rr.Hdr.Len()
-}
-
 type CNAME struct {
 	Hdr    RR_Header
 	Target string `dns:"cdomain-name"`
 }
 
 func (rr *CNAME) Header() *RR_Header { return &rr.Hdr }
-func (rr *CNAME) Copy() RR           { return &CNAME{*rr.Hdr.CopyHeader(), rr.Target} }
 
 func (rr *CNAME) String() string {
 	return rr.Hdr.String() + rr.Target
 }
 
-func (rr *CNAME) Len() int {
-	l := len(rr.Target) + 1
-	return rr.Hdr.Len() + l
-}
-
 type HINFO struct {
 	Hdr RR_Header
 	Cpu string
@@ -218,50 +244,33 @@ type HINFO struct {
 }
 
 func (rr *HINFO) Header() *RR_Header { return &rr.Hdr }
-func (rr *HINFO) Copy() RR           { return &HINFO{*rr.Hdr.CopyHeader(), rr.Cpu, rr.Os} }
 
 func (rr *HINFO) String() string {
 	return rr.Hdr.String() + rr.Cpu + " " + rr.Os
 }
 
-func (rr *HINFO) Len() int {
-	return rr.Hdr.Len() + len(rr.Cpu) + len(rr.Os)
-}
-
 type MB struct {
 	Hdr RR_Header
 	Mb  string `dns:"cdomain-name"`
 }
 
 func (rr *MB) Header() *RR_Header { return &rr.Hdr }
-func (rr *MB) Copy() RR           { return &MB{*rr.Hdr.CopyHeader(), rr.Mb} }
 
 func (rr *MB) String() string {
 	return rr.Hdr.String() + rr.Mb
 }
 
-func (rr *MB) Len() int {
-	l := len(rr.Mb) + 1
-	return rr.Hdr.Len() + l
-}
-
 type MG struct {
 	Hdr RR_Header
 	Mg  string `dns:"cdomain-name"`
 }
 
 func (rr *MG) Header() *RR_Header { return &rr.Hdr }
-func (rr *MG) Copy() RR           { return &MG{*rr.Hdr.CopyHeader(), rr.Mg} }
 
 func (rr *MG) String() string {
 	return rr.Hdr.String() + rr.Mg
 }
 
-func (rr *MG) Len() int {
-	l := len(rr.Mg) + 1
-	return rr.Hdr.Len() + l
-}
-
 type MINFO struct {
 	Hdr   RR_Header
 	Rmail string `dns:"cdomain-name"`
@@ -269,67 +278,44 @@ type MINFO struct {
 }
 
 func (rr *MINFO) Header() *RR_Header { return &rr.Hdr }
-func (rr *MINFO) Copy() RR           { return &MINFO{*rr.Hdr.CopyHeader(), rr.Rmail, rr.Email} }
 
 func (rr *MINFO) String() string {
 	return rr.Hdr.String() + rr.Rmail + " " + rr.Email
 }
 
-func (rr *MINFO) Len() int {
-	l := len(rr.Rmail) + 1
-	n := len(rr.Email) + 1
-	return rr.Hdr.Len() + l + n
-}
-
 type MR struct {
 	Hdr RR_Header
 	Mr  string `dns:"cdomain-name"`
 }
 
 func (rr *MR) Header() *RR_Header { return &rr.Hdr }
-func (rr *MR) Copy() RR           { return &MR{*rr.Hdr.CopyHeader(), rr.Mr} }
 
 func (rr *MR) String() string {
 	return rr.Hdr.String() + rr.Mr
 }
 
-func (rr *MR) Len() int {
-	l := len(rr.Mr) + 1
-	return rr.Hdr.Len() + l
-}
-
 type MF struct {
 	Hdr RR_Header
 	Mf  string `dns:"cdomain-name"`
 }
 
 func (rr *MF) Header() *RR_Header { return &rr.Hdr }
-func (rr *MF) Copy() RR           { return &MF{*rr.Hdr.CopyHeader(), rr.Mf} }
 
 func (rr *MF) String() string {
 	return rr.Hdr.String() + " " + rr.Mf
 }
 
-func (rr *MF) Len() int {
-	return rr.Hdr.Len() + len(rr.Mf) + 1
-}
-
 type MD struct {
 	Hdr RR_Header
 	Md  string `dns:"cdomain-name"`
 }
 
 func (rr *MD) Header() *RR_Header { return &rr.Hdr }
-func (rr *MD) Copy() RR           { return &MD{*rr.Hdr.CopyHeader(), rr.Md} }
 
 func (rr *MD) String() string {
 	return rr.Hdr.String() + " " + rr.Md
 }
 
-func (rr *MD) Len() int {
-	return rr.Hdr.Len() + len(rr.Md) + 1
-}
-
 type MX struct {
 	Hdr        RR_Header
 	Preference uint16
@@ -337,17 +323,11 @@ type MX struct {
 }
 
 func (rr *MX) Header() *RR_Header { return &rr.Hdr }
-func (rr *MX) Copy() RR           { return &MX{*rr.Hdr.CopyHeader(), rr.Preference, rr.Mx} }
 
 func (rr *MX) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Preference)) + " " + rr.Mx
 }
 
-func (rr *MX) Len() int {
-	l := len(rr.Mx) + 1
-	return rr.Hdr.Len() + l + 2
-}
-
 type AFSDB struct {
 	Hdr      RR_Header
 	Subtype  uint16
@@ -355,33 +335,22 @@ type AFSDB struct {
 }
 
 func (rr *AFSDB) Header() *RR_Header { return &rr.Hdr }
-func (rr *AFSDB) Copy() RR           { return &AFSDB{*rr.Hdr.CopyHeader(), rr.Subtype, rr.Hostname} }
 
 func (rr *AFSDB) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Subtype)) + " " + rr.Hostname
 }
 
-func (rr *AFSDB) Len() int {
-	l := len(rr.Hostname) + 1
-	return rr.Hdr.Len() + l + 2
-}
-
 type X25 struct {
 	Hdr         RR_Header
 	PSDNAddress string
 }
 
 func (rr *X25) Header() *RR_Header { return &rr.Hdr }
-func (rr *X25) Copy() RR           { return &X25{*rr.Hdr.CopyHeader(), rr.PSDNAddress} }
 
 func (rr *X25) String() string {
 	return rr.Hdr.String() + rr.PSDNAddress
 }
 
-func (rr *X25) Len() int {
-	return rr.Hdr.Len() + len(rr.PSDNAddress)
-}
-
 type RT struct {
 	Hdr        RR_Header
 	Preference uint16
@@ -389,51 +358,33 @@ type RT struct {
 }
 
 func (rr *RT) Header() *RR_Header { return &rr.Hdr }
-func (rr *RT) Copy() RR           { return &RT{*rr.Hdr.CopyHeader(), rr.Preference, rr.Host} }
 
 func (rr *RT) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Preference)) + " " + rr.Host
 }
 
-func (rr *RT) Len() int {
-	l := len(rr.Host) + 1
-	return rr.Hdr.Len() + l + 2
-}
-
 type NS struct {
 	Hdr RR_Header
 	Ns  string `dns:"cdomain-name"`
 }
 
 func (rr *NS) Header() *RR_Header { return &rr.Hdr }
-func (rr *NS) Copy() RR           { return &NS{*rr.Hdr.CopyHeader(), rr.Ns} }
 
 func (rr *NS) String() string {
 	return rr.Hdr.String() + rr.Ns
 }
 
-func (rr *NS) Len() int {
-	l := len(rr.Ns) + 1
-	return rr.Hdr.Len() + l
-}
-
 type PTR struct {
 	Hdr RR_Header
 	Ptr string `dns:"cdomain-name"`
 }
 
 func (rr *PTR) Header() *RR_Header { return &rr.Hdr }
-func (rr *PTR) Copy() RR           { return &PTR{*rr.Hdr.CopyHeader(), rr.Ptr} }
 
 func (rr *PTR) String() string {
 	return rr.Hdr.String() + rr.Ptr
 }
 
-func (rr *PTR) Len() int {
-	l := len(rr.Ptr) + 1
-	return rr.Hdr.Len() + l
-}
-
 type RP struct {
 	Hdr  RR_Header
 	Mbox string `dns:"domain-name"`
@@ -441,16 +392,11 @@ type RP struct {
 }
 
 func (rr *RP) Header() *RR_Header { return &rr.Hdr }
-func (rr *RP) Copy() RR           { return &RP{*rr.Hdr.CopyHeader(), rr.Mbox, rr.Txt} }
 
 func (rr *RP) String() string {
 	return rr.Hdr.String() + rr.Mbox + " " + rr.Txt
 }
 
-func (rr *RP) Len() int {
-	return rr.Hdr.Len() + len(rr.Mbox) + 1 + len(rr.Txt) + 1
-}
-
 type SOA struct {
 	Hdr     RR_Header
 	Ns      string `dns:"cdomain-name"`
@@ -463,9 +409,6 @@ type SOA struct {
 }
 
 func (rr *SOA) Header() *RR_Header { return &rr.Hdr }
-func (rr *SOA) Copy() RR {
-	return &SOA{*rr.Hdr.CopyHeader(), rr.Ns, rr.Mbox, rr.Serial, rr.Refresh, rr.Retry, rr.Expire, rr.Minttl}
-}
 
 func (rr *SOA) String() string {
 	return rr.Hdr.String() + rr.Ns + " " + rr.Mbox +
@@ -476,68 +419,44 @@ func (rr *SOA) String() string {
 		" " + strconv.FormatInt(int64(rr.Minttl), 10)
 }
 
-func (rr *SOA) Len() int {
-	l := len(rr.Ns) + 1
-	n := len(rr.Mbox) + 1
-	return rr.Hdr.Len() + l + n + 20
-}
-
 type TXT struct {
 	Hdr RR_Header
 	Txt []string `dns:"txt"`
 }
 
 func (rr *TXT) Header() *RR_Header { return &rr.Hdr }
-func (rr *TXT) Copy() RR           { return &TXT{*rr.Hdr.CopyHeader(), rr.Txt} }
 
 func (rr *TXT) String() string {
 	s := rr.Hdr.String()
 	for i, s1 := range rr.Txt {
 		if i > 0 {
-			s += " " + strconv.QuoteToASCII(s1)
+			s += " " + QuoteTxt(s1)
 		} else {
-			s += strconv.QuoteToASCII(s1)
+			s += QuoteTxt(s1)
 		}
 	}
 	return s
 }
 
-func (rr *TXT) Len() int {
-	l := rr.Hdr.Len()
-	for _, t := range rr.Txt {
-		l += len(t)
-	}
-	return l
-}
-
 type SPF struct {
 	Hdr RR_Header
 	Txt []string `dns:"txt"`
 }
 
 func (rr *SPF) Header() *RR_Header { return &rr.Hdr }
-func (rr *SPF) Copy() RR           { return &SPF{*rr.Hdr.CopyHeader(), rr.Txt} }
 
 func (rr *SPF) String() string {
 	s := rr.Hdr.String()
 	for i, s1 := range rr.Txt {
 		if i > 0 {
-			s += " " + "\"" + s1 + "\""
+			s += " " + QuoteTxt(s1)
 		} else {
-			s += "\"" + s1 + "\""
+			s += QuoteTxt(s1)
 		}
 	}
 	return s
 }
 
-func (rr *SPF) Len() int {
-	l := rr.Hdr.Len()
-	for _, t := range rr.Txt {
-		l += len(t)
-	}
-	return l
-}
-
 type SRV struct {
 	Hdr      RR_Header
 	Priority uint16
@@ -547,9 +466,6 @@ type SRV struct {
 }
 
 func (rr *SRV) Header() *RR_Header { return &rr.Hdr }
-func (rr *SRV) Copy() RR {
-	return &SRV{*rr.Hdr.CopyHeader(), rr.Priority, rr.Weight, rr.Port, rr.Target}
-}
 
 func (rr *SRV) String() string {
 	return rr.Hdr.String() +
@@ -558,11 +474,6 @@ func (rr *SRV) String() string {
 		strconv.Itoa(int(rr.Port)) + " " + rr.Target
 }
 
-func (rr *SRV) Len() int {
-	l := len(rr.Target) + 1
-	return rr.Hdr.Len() + l + 6
-}
-
 type NAPTR struct {
 	Hdr         RR_Header
 	Order       uint16
@@ -574,9 +485,6 @@ type NAPTR struct {
 }
 
 func (rr *NAPTR) Header() *RR_Header { return &rr.Hdr }
-func (rr *NAPTR) Copy() RR {
-	return &NAPTR{*rr.Hdr.CopyHeader(), rr.Order, rr.Preference, rr.Flags, rr.Service, rr.Regexp, rr.Replacement}
-}
 
 func (rr *NAPTR) String() string {
 	return rr.Hdr.String() +
@@ -588,11 +496,6 @@ func (rr *NAPTR) String() string {
 		rr.Replacement
 }
 
-func (rr *NAPTR) Len() int {
-	return rr.Hdr.Len() + 4 + len(rr.Flags) + len(rr.Service) +
-		len(rr.Regexp) + len(rr.Replacement) + 1
-}
-
 // See RFC 4398.
 type CERT struct {
 	Hdr         RR_Header
@@ -603,9 +506,6 @@ type CERT struct {
 }
 
 func (rr *CERT) Header() *RR_Header { return &rr.Hdr }
-func (rr *CERT) Copy() RR {
-	return &CERT{*rr.Hdr.CopyHeader(), rr.Type, rr.KeyTag, rr.Algorithm, rr.Certificate}
-}
 
 func (rr *CERT) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Type)) +
@@ -614,11 +514,6 @@ func (rr *CERT) String() string {
 		" " + rr.Certificate
 }
 
-func (rr *CERT) Len() int {
-	return rr.Hdr.Len() + 5 +
-		base64.StdEncoding.DecodedLen(len(rr.Certificate))
-}
-
 // See RFC 2672.
 type DNAME struct {
 	Hdr    RR_Header
@@ -626,49 +521,33 @@ type DNAME struct {
 }
 
 func (rr *DNAME) Header() *RR_Header { return &rr.Hdr }
-func (rr *DNAME) Copy() RR           { return &DNAME{*rr.Hdr.CopyHeader(), rr.Target} }
 
 func (rr *DNAME) String() string {
 	return rr.Hdr.String() + rr.Target
 }
 
-func (rr *DNAME) Len() int {
-	l := len(rr.Target) + 1
-	return rr.Hdr.Len() + l
-}
-
 type A struct {
 	Hdr RR_Header
 	A   net.IP `dns:"a"`
 }
 
 func (rr *A) Header() *RR_Header { return &rr.Hdr }
-func (rr *A) Copy() RR           { return &A{*rr.Hdr.CopyHeader(), rr.A} }
 
 func (rr *A) String() string {
 	return rr.Hdr.String() + rr.A.String()
 }
 
-func (rr *A) Len() int {
-	return rr.Hdr.Len() + net.IPv4len
-}
-
 type AAAA struct {
 	Hdr  RR_Header
 	AAAA net.IP `dns:"aaaa"`
 }
 
 func (rr *AAAA) Header() *RR_Header { return &rr.Hdr }
-func (rr *AAAA) Copy() RR           { return &AAAA{*rr.Hdr.CopyHeader(), rr.AAAA} }
 
 func (rr *AAAA) String() string {
 	return rr.Hdr.String() + rr.AAAA.String()
 }
 
-func (rr *AAAA) Len() int {
-	return rr.Hdr.Len() + net.IPv6len
-}
-
 type LOC struct {
 	Hdr       RR_Header
 	Version   uint8
@@ -681,9 +560,6 @@ type LOC struct {
 }
 
 func (rr *LOC) Header() *RR_Header { return &rr.Hdr }
-func (rr *LOC) Copy() RR {
-	return &LOC{*rr.Hdr.CopyHeader(), rr.Version, rr.Size, rr.HorizPre, rr.VertPre, rr.Latitude, rr.Longitude, rr.Altitude}
-}
 
 func (rr *LOC) String() string {
 	s := rr.Hdr.String()
@@ -730,10 +606,6 @@ func (rr *LOC) String() string {
 	return s
 }
 
-func (rr *LOC) Len() int {
-	return rr.Hdr.Len() + 4 + 12
-}
-
 type RRSIG struct {
 	Hdr         RR_Header
 	TypeCovered uint16
@@ -748,9 +620,6 @@ type RRSIG struct {
 }
 
 func (rr *RRSIG) Header() *RR_Header { return &rr.Hdr }
-func (rr *RRSIG) Copy() RR {
-	return &RRSIG{*rr.Hdr.CopyHeader(), rr.TypeCovered, rr.Algorithm, rr.Labels, rr.OrigTtl, rr.Expiration, rr.Inception, rr.KeyTag, rr.SignerName, rr.Signature}
-}
 
 func (rr *RRSIG) String() string {
 	return rr.Hdr.String() + TypeToString[rr.TypeCovered] +
@@ -764,11 +633,6 @@ func (rr *RRSIG) String() string {
 		" " + rr.Signature
 }
 
-func (rr *RRSIG) Len() int {
-	return rr.Hdr.Len() + len(rr.SignerName) + 1 +
-		base64.StdEncoding.DecodedLen(len(rr.Signature)) + 18
-}
-
 type NSEC struct {
 	Hdr        RR_Header
 	NextDomain string   `dns:"domain-name"`
@@ -791,9 +655,33 @@ func (rr *NSEC) String() string {
 }
 
 func (rr *NSEC) Len() int {
-	l := len(rr.NextDomain) + 1
-	return rr.Hdr.Len() + l + 32 + 1
-	// TODO: +32 is max type bitmap
+	return rr.Hdr.Len() + len(rr.NextDomain) + 1 + typeBitMapLen(rr.TypeBitMap)
+}
+
+// typeBitMapLen returns the length of the type bitmap of NSEC and NSEC3
+// records in wire format, the types in bitmap must be sorted.
+func typeBitMapLen(bitmap []uint16) int {
+	l := 0
+	lastwindow, lastlength := -1, 0
+	for _, t := range bitmap {
+		window, length := int(t/256), int(t%256)/8+1
+		if window != lastwindow {
+			l += 2 + length
+		} else {
+			l += length - lastlength
+		}
+		lastwindow, lastlength = window, length
+	}
+	return l
+}
+
+// base64Len returns the length of the base64 encoded s when decoded.
+func base64Len(s string) int {
+	l := base64.StdEncoding.DecodedLen(len(s))
+	for i := len(s) - 1; i >= 0 && s[i] == '='; i-- {
+		l--
+	}
+	return l
 }
 
 type DS struct {
@@ -805,9 +693,6 @@ type DS struct {
 }
 
 func (rr *DS) Header() *RR_Header { return &rr.Hdr }
-func (rr *DS) Copy() RR {
-	return &DS{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
-}
 
 func (rr *DS) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.KeyTag)) +
@@ -816,10 +701,6 @@ func (rr *DS) String() string {
 		" " + strings.ToUpper(rr.Digest)
 }
 
-func (rr *DS) Len() int {
-	return rr.Hdr.Len() + 4 + len(rr.Digest)/2
-}
-
 type CDS struct {
 	Hdr        RR_Header
 	KeyTag     uint16
@@ -829,9 +710,6 @@ type CDS struct {
 }
 
 func (rr *CDS) Header() *RR_Header { return &rr.Hdr }
-func (rr *CDS) Copy() RR {
-	return &CDS{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
-}
 
 func (rr *CDS) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.KeyTag)) +
@@ -840,10 +718,6 @@ func (rr *CDS) String() string {
 		" " + strings.ToUpper(rr.Digest)
 }
 
-func (rr *CDS) Len() int {
-	return rr.Hdr.Len() + 4 + len(rr.Digest)/2
-}
-
 type DLV struct {
 	Hdr        RR_Header
 	KeyTag     uint16
@@ -853,9 +727,6 @@ type DLV struct {
 }
 
 func (rr *DLV) Header() *RR_Header { return &rr.Hdr }
-func (rr *DLV) Copy() RR {
-	return &DLV{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
-}
 
 func (rr *DLV) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.KeyTag)) +
@@ -864,10 +735,6 @@ func (rr *DLV) String() string {
 		" " + strings.ToUpper(rr.Digest)
 }
 
-func (rr *DLV) Len() int {
-	return rr.Hdr.Len() + 4 + len(rr.Digest)/2
-}
-
 type KX struct {
 	Hdr        RR_Header
 	Preference uint16
@@ -875,17 +742,12 @@ type KX struct {
 }
 
 func (rr *KX) Header() *RR_Header { return &rr.Hdr }
-func (rr *KX) Copy() RR           { return &KX{*rr.Hdr.CopyHeader(), rr.Preference, rr.Exchanger} }
 
 func (rr *KX) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Preference)) +
 		" " + rr.Exchanger
 }
 
-func (rr *KX) Len() int {
-	return 0
-}
-
 type TA struct {
 	Hdr        RR_Header
 	KeyTag     uint16
@@ -895,9 +757,6 @@ type TA struct {
 }
 
 func (rr *TA) Header() *RR_Header { return &rr.Hdr }
-func (rr *TA) Copy() RR {
-	return &TA{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
-}
 
 func (rr *TA) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.KeyTag)) +
@@ -906,28 +765,19 @@ func (rr *TA) String() string {
 		" " + strings.ToUpper(rr.Digest)
 }
 
-func (rr *TA) Len() int {
-	return rr.Hdr.Len() + 4 + len(rr.Digest)/2
-}
-
 type TALINK struct {
 	Hdr          RR_Header
-	PreviousName string `dns:"domain"`
-	NextName     string `dns:"domain"`
+	PreviousName string `dns:"domain-name"`
+	NextName     string `dns:"domain-name"`
 }
 
 func (rr *TALINK) Header() *RR_Header { return &rr.Hdr }
-func (rr *TALINK) Copy() RR           { return &TALINK{*rr.Hdr.CopyHeader(), rr.PreviousName, rr.NextName} }
 
 func (rr *TALINK) String() string {
 	return rr.Hdr.String() +
 		" " + rr.PreviousName + " " + rr.NextName
 }
 
-func (rr *TALINK) Len() int {
-	return rr.Hdr.Len() + len(rr.PreviousName) + len(rr.NextName) + 2
-}
-
 type SSHFP struct {
 	Hdr         RR_Header
 	Algorithm   uint8
@@ -936,9 +786,6 @@ type SSHFP struct {
 }
 
 func (rr *SSHFP) Header() *RR_Header { return &rr.Hdr }
-func (rr *SSHFP) Copy() RR {
-	return &SSHFP{*rr.Hdr.CopyHeader(), rr.Algorithm, rr.Type, rr.FingerPrint}
-}
 
 func (rr *SSHFP) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Algorithm)) +
@@ -946,10 +793,6 @@ func (rr *SSHFP) String() string {
 		" " + strings.ToUpper(rr.FingerPrint)
 }
 
-func (rr *SSHFP) Len() int {
-	return rr.Hdr.Len() + 2 + len(rr.FingerPrint)/2
-}
-
 type IPSECKEY struct {
 	Hdr         RR_Header
 	Precedence  uint8
@@ -986,9 +829,6 @@ type DNSKEY struct {
 }
 
 func (rr *DNSKEY) Header() *RR_Header { return &rr.Hdr }
-func (rr *DNSKEY) Copy() RR {
-	return &DNSKEY{*rr.Hdr.CopyHeader(), rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey}
-}
 
 func (rr *DNSKEY) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Flags)) +
@@ -997,9 +837,21 @@ func (rr *DNSKEY) String() string {
 		" " + rr.PublicKey
 }
 
-func (rr *DNSKEY) Len() int {
-	return rr.Hdr.Len() + 4 +
-		base64.StdEncoding.DecodedLen(len(rr.PublicKey))
+type CDNSKEY struct {
+	Hdr       RR_Header
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey string `dns:"base64"`
+}
+
+func (rr *CDNSKEY) Header() *RR_Header { return &rr.Hdr }
+
+func (rr *CDNSKEY) String() string {
+	return rr.Hdr.String() + strconv.Itoa(int(rr.Flags)) +
+		" " + strconv.Itoa(int(rr.Protocol)) +
+		" " + strconv.Itoa(int(rr.Algorithm)) +
+		" " + rr.PublicKey
 }
 
 type RKEY struct {
@@ -1011,9 +863,6 @@ type RKEY struct {
 }
 
 func (rr *RKEY) Header() *RR_Header { return &rr.Hdr }
-func (rr *RKEY) Copy() RR {
-	return &RKEY{*rr.Hdr.CopyHeader(), rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey}
-}
 
 func (rr *RKEY) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Flags)) +
@@ -1022,11 +871,6 @@ func (rr *RKEY) String() string {
 		" " + rr.PublicKey
 }
 
-func (rr *RKEY) Len() int {
-	return rr.Hdr.Len() + 4 +
-		base64.StdEncoding.DecodedLen(len(rr.PublicKey))
-}
-
 type NSEC3 struct {
 	Hdr        RR_Header
 	Hash       uint8
@@ -1062,8 +906,8 @@ func (rr *NSEC3) String() string {
 }
 
 func (rr *NSEC3) Len() int {
-	return rr.Hdr.Len() + 6 + len(rr.Salt)/2 + 1 + len(rr.NextDomain) + 1 + 32
-	// TODO: +32 is MAX type bit map
+	return rr.Hdr.Len() + 6 + len(rr.Salt)/2 + base32.HexEncoding.DecodedLen(len(rr.NextDomain)) +
+		typeBitMapLen(rr.TypeBitMap)
 }
 
 type NSEC3PARAM struct {
@@ -1076,9 +920,6 @@ type NSEC3PARAM struct {
 }
 
 func (rr *NSEC3PARAM) Header() *RR_Header { return &rr.Hdr }
-func (rr *NSEC3PARAM) Copy() RR {
-	return &NSEC3PARAM{*rr.Hdr.CopyHeader(), rr.Hash, rr.Flags, rr.Iterations, rr.SaltLength, rr.Salt}
-}
 
 func (rr *NSEC3PARAM) String() string {
 	s := rr.Hdr.String()
@@ -1089,10 +930,6 @@ func (rr *NSEC3PARAM) String() string {
 	return s
 }
 
-func (rr *NSEC3PARAM) Len() int {
-	return rr.Hdr.Len() + 2 + 4 + 1 + len(rr.Salt)/2
-}
-
 type TKEY struct {
 	Hdr        RR_Header
 	Algorithm  string `dns:"domain-name"`
@@ -1107,20 +944,12 @@ type TKEY struct {
 }
 
 func (rr *TKEY) Header() *RR_Header { return &rr.Hdr }
-func (rr *TKEY) Copy() RR {
-	return &TKEY{*rr.Hdr.CopyHeader(), rr.Algorithm, rr.Inception, rr.Expiration, rr.Mode, rr.Error, rr.KeySize, rr.Key, rr.OtherLen, rr.OtherData}
-}
 
 func (rr *TKEY) String() string {
 	// It has no presentation format
 	return ""
 }
 
-func (rr *TKEY) Len() int {
-	return rr.Hdr.Len() + len(rr.Algorithm) + 1 + 4 + 4 + 6 +
-		len(rr.Key) + 2 + len(rr.OtherData)
-}
-
 // RFC3597 representes an unknown RR.
 type RFC3597 struct {
 	Hdr   RR_Header
@@ -1128,7 +957,6 @@ type RFC3597 struct {
 }
 
 func (rr *RFC3597) Header() *RR_Header { return &rr.Hdr }
-func (rr *RFC3597) Copy() RR           { return &RFC3597{*rr.Hdr.CopyHeader(), rr.Rdata} }
 
 func (rr *RFC3597) String() string {
 	s := rr.Hdr.String()
@@ -1136,10 +964,6 @@ func (rr *RFC3597) String() string {
 	return s
 }
 
-func (rr *RFC3597) Len() int {
-	return rr.Hdr.Len() + len(rr.Rdata)/2
-}
-
 type URI struct {
 	Hdr      RR_Header
 	Priority uint16
@@ -1166,15 +990,21 @@ type DHCID struct {
 }
 
 func (rr *DHCID) Header() *RR_Header { return &rr.Hdr }
-func (rr *DHCID) Copy() RR           { return &DHCID{*rr.Hdr.CopyHeader(), rr.Digest} }
 
 func (rr *DHCID) String() string {
 	return rr.Hdr.String() + rr.Digest
 }
 
-func (rr *DHCID) Len() int {
-	return rr.Hdr.Len() +
-		base64.StdEncoding.DecodedLen(len(rr.Digest))
+// See RFC 7929.
+type OPENPGPKEY struct {
+	Hdr       RR_Header
+	PublicKey string `dns:"base64"`
+}
+
+func (rr *OPENPGPKEY) Header() *RR_Header { return &rr.Hdr }
+
+func (rr *OPENPGPKEY) String() string {
+	return rr.Hdr.String() + rr.PublicKey
 }
 
 type TLSA struct {
@@ -1186,9 +1016,6 @@ type TLSA struct {
 }
 
 func (rr *TLSA) Header() *RR_Header { return &rr.Hdr }
-func (rr *TLSA) Copy() RR {
-	return &TLSA{*rr.Hdr.CopyHeader(), rr.Usage, rr.Selector, rr.MatchingType, rr.Certificate}
-}
 
 func (rr *TLSA) String() string {
 	return rr.Hdr.String() +
@@ -1198,8 +1025,21 @@ func (rr *TLSA) String() string {
 		" " + rr.Certificate
 }
 
-func (rr *TLSA) Len() int {
-	return rr.Hdr.Len() + 3 + len(rr.Certificate)/2
+type ZONEMD struct {
+	Hdr    RR_Header
+	Serial uint32
+	Scheme uint8
+	Hash   uint8
+	Digest string `dns:"hex"`
+}
+
+func (rr *ZONEMD) Header() *RR_Header { return &rr.Hdr }
+
+func (rr *ZONEMD) String() string {
+	return rr.Hdr.String() + strconv.FormatInt(int64(rr.Serial), 10) +
+		" " + strconv.Itoa(int(rr.Scheme)) +
+		" " + strconv.Itoa(int(rr.Hash)) +
+		" " + strings.ToUpper(rr.Digest)
 }
 
 type HIP struct {
@@ -1231,7 +1071,7 @@ func (rr *HIP) String() string {
 func (rr *HIP) Len() int {
 	l := rr.Hdr.Len() + 4 +
 		len(rr.Hit)/2 +
-		base64.StdEncoding.DecodedLen(len(rr.PublicKey))
+		base64Len(rr.PublicKey)
 	for _, d := range rr.RendezvousServers {
 		l += len(d) + 1
 	}
@@ -1244,7 +1084,6 @@ type NINFO struct {
 }
 
 func (rr *NINFO) Header() *RR_Header { return &rr.Hdr }
-func (rr *NINFO) Copy() RR           { return &NINFO{*rr.Hdr.CopyHeader(), rr.ZSData} }
 
 func (rr *NINFO) String() string {
 	s := rr.Hdr.String()
@@ -1258,14 +1097,6 @@ func (rr *NINFO) String() string {
 	return s
 }
 
-func (rr *NINFO) Len() int {
-	l := rr.Hdr.Len()
-	for _, t := range rr.ZSData {
-		l += len(t)
-	}
-	return l
-}
-
 type WKS struct {
 	Hdr      RR_Header
 	Address  net.IP `dns:"a"`
@@ -1286,7 +1117,13 @@ func (rr *WKS) String() string {
 }
 
 func (rr *WKS) Len() int {
-	return rr.Hdr.Len() + net.IPv4len + 1
+	bitmap := 0 // up to the octet of the highest port
+	for _, serv := range rr.BitMap {
+		if int(serv/8)+1 > bitmap {
+			bitmap = int(serv/8) + 1
+		}
+	}
+	return rr.Hdr.Len() + net.IPv4len + 1 + bitmap
 }
 
 type NID struct {
@@ -1296,7 +1133,6 @@ type NID struct {
 }
 
 func (rr *NID) Header() *RR_Header { return &rr.Hdr }
-func (rr *NID) Copy() RR           { return &NID{*rr.Hdr.CopyHeader(), rr.Preference, rr.NodeID} }
 
 func (rr *NID) String() string {
 	s := rr.Hdr.String() + strconv.Itoa(int(rr.Preference))
@@ -1305,10 +1141,6 @@ func (rr *NID) String() string {
 	return s
 }
 
-func (rr *NID) Len() int {
-	return rr.Hdr.Len() + 2 + 8
-}
-
 type L32 struct {
 	Hdr        RR_Header
 	Preference uint16
@@ -1316,17 +1148,12 @@ type L32 struct {
 }
 
 func (rr *L32) Header() *RR_Header { return &rr.Hdr }
-func (rr *L32) Copy() RR           { return &L32{*rr.Hdr.CopyHeader(), rr.Preference, rr.Locator32} }
 
 func (rr *L32) String() string {
 	return rr.Hdr.String() + strconv.Itoa(int(rr.Preference)) +
 		" " + rr.Locator32.String()
 }
 
-func (rr *L32) Len() int {
-	return rr.Hdr.Len() + net.IPv4len
-}
-
 type L64 struct {
 	Hdr        RR_Header
 	Preference uint16
@@ -1334,7 +1161,6 @@ type L64 struct {
 }
 
 func (rr *L64) Header() *RR_Header { return &rr.Hdr }
-func (rr *L64) Copy() RR           { return &L64{*rr.Hdr.CopyHeader(), rr.Preference, rr.Locator64} }
 
 func (rr *L64) String() string {
 	s := rr.Hdr.String() + strconv.Itoa(int(rr.Preference))
@@ -1343,10 +1169,6 @@ func (rr *L64) String() string {
 	return s
 }
 
-func (rr *L64) Len() int {
-	return rr.Hdr.Len() + 2 + 8
-}
-
 type LP struct {
 	Hdr        RR_Header
 	Preference uint16
@@ -1354,7 +1176,6 @@ type LP struct {
 }
 
 func (rr *LP) Header() *RR_Header { return &rr.Hdr }
-func (rr *LP) Copy() RR           { return &LP{*rr.Hdr.CopyHeader(), rr.Preference, rr.Fqdn} }
 
 func (rr *LP) String() string {
 	s := rr.Hdr.String() + strconv.Itoa(int(rr.Preference)) +
@@ -1362,10 +1183,6 @@ func (rr *LP) String() string {
 	return s
 }
 
-func (rr *LP) Len() int {
-	return rr.Hdr.Len() + 2 + len(rr.Fqdn) + 1
-}
-
 // TimeToString translates the RRSIG's incep. and expir. times to the
 // string representation used when printing the record.
 // It takes serial arithmetic (RFC 1982) into account.
@@ -1450,6 +1267,8 @@ var rr_mk = map[uint16]func() RR{
 	TypeOPT:        func() RR { return new(OPT) },
 	TypeDS:         func() RR { return new(DS) },
 	TypeCDS:        func() RR { return new(CDS) },
+	TypeCDNSKEY:    func() RR { return new(CDNSKEY) },
+	TypeZONEMD:     func() RR { return new(ZONEMD) },
 	TypeCERT:       func() RR { return new(CERT) },
 	TypeKX:         func() RR { return new(KX) },
 	TypeSPF:        func() RR { return new(SPF) },
@@ -1460,6 +1279,7 @@ var rr_mk = map[uint16]func() RR{
 	TypeDNSKEY:     func() RR { return new(DNSKEY) },
 	TypeNSEC3:      func() RR { return new(NSEC3) },
 	TypeDHCID:      func() RR { return new(DHCID) },
+	TypeOPENPGPKEY: func() RR { return new(OPENPGPKEY) },
 	TypeNSEC3PARAM: func() RR { return new(NSEC3PARAM) },
 	TypeTKEY:       func() RR { return new(TKEY) },
 	TypeTSIG:       func() RR { return new(TSIG) },
@@ -0,0 +1,10 @@
+package dns
+
+// Path MTU discovery settings for UDP sockets. These, like the TOS and the
+// drop counter of a Server, are only implemented on Linux, on other systems
+// they are ignored.
+const (
+	PMTUDiscDefault = iota // Use the system default
+	PMTUDiscOmit           // Don't set DF and ignore path MTU updates (IP_PMTUDISC_OMIT), protects against spoofed ICMP
+	PMTUDiscDo             // Always set DF, so packets are never fragmented (IP_PMTUDISC_DO)
+)
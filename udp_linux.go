@@ -0,0 +1,87 @@
+// +build linux
+
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+// Values for the IP_MTU_DISCOVER and IPV6_MTU_DISCOVER socket options,
+// not all of them are defined in package syscall.
+const (
+	ip_PMTUDISC_DO   = 2
+	ip_PMTUDISC_OMIT = 5
+)
+
+// setPMTUDisc sets the path MTU discovery behavior of the UDP socket.
+func setPMTUDisc(c *net.UDPConn, pmtu int) error {
+	var v int
+	switch pmtu {
+	case PMTUDiscOmit:
+		v = ip_PMTUDISC_OMIT
+	case PMTUDiscDo:
+		v = ip_PMTUDISC_DO
+	default:
+		return nil
+	}
+	return setsockopt(c, func(fd int) error {
+		// Set both, one of them fails depending on the address family
+		e4 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, v)
+		e6 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, v)
+		if e4 != nil && e6 != nil {
+			return e4
+		}
+		return nil
+	})
+}
+
+// setTOS sets the IP TOS and IPv6 traffic class of the socket c.
+func setTOS(c syscall.Conn, tos int) error {
+	return setsockopt(c, func(fd int) error {
+		// Set both, one of them fails depending on the address family
+		e4 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+		e6 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+		if e4 != nil && e6 != nil {
+			return e4
+		}
+		return nil
+	})
+}
+
+// setDropCount makes the kernel send the number of dropped packets along
+// with each packet read (SO_RXQ_OVFL), see parseDropCount.
+func setDropCount(c *net.UDPConn) error {
+	return setsockopt(c, func(fd int) error {
+		return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RXQ_OVFL, 1)
+	})
+}
+
+// parseDropCount returns the drop counter from the out-of-band data of a
+// packet.
+func parseDropCount(oob []byte) (uint64, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, m := range msgs {
+		if m.Header.Level == syscall.SOL_SOCKET && m.Header.Type == syscall.SO_RXQ_OVFL && len(m.Data) >= 4 {
+			return uint64(binary.NativeEndian.Uint32(m.Data)), true
+		}
+	}
+	return 0, false
+}
+
+// setsockopt calls set with the file descriptor of c.
+func setsockopt(c syscall.Conn, set func(fd int) error) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) { serr = set(int(fd)) }); err != nil {
+		return err
+	}
+	return serr
+}
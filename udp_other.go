@@ -0,0 +1,28 @@
+// +build !linux
+
+package dns
+
+import (
+	"net"
+	"syscall"
+)
+
+// setPMTUDisc is a no-op on systems other than Linux.
+func setPMTUDisc(c *net.UDPConn, pmtu int) error {
+	return nil
+}
+
+// setTOS is a no-op on systems other than Linux.
+func setTOS(c syscall.Conn, tos int) error {
+	return nil
+}
+
+// setDropCount is a no-op on systems other than Linux.
+func setDropCount(c *net.UDPConn) error {
+	return nil
+}
+
+// parseDropCount never finds a drop counter on systems other than Linux.
+func parseDropCount(oob []byte) (uint64, bool) {
+	return 0, false
+}
@@ -35,6 +35,10 @@
 // 
 package dns
 
+import (
+	"time"
+)
+
 // NameUsed sets the RRs in the prereq section to
 // "Name is in use" RRs. RFC 2136 section 2.4.4.
 func (u *Msg) NameUsed(rr []RR) {
@@ -130,3 +134,153 @@ func (u *Msg) Remove(rr []RR) {
 		u.Ns[i].Header().Ttl = 0
 	}
 }
+
+// UpdateBuilder builds a dynamic update message one RR at a time, unlike the
+// methods above it appends to the sections and it does not modify the RRs
+// it is given. Basic use pattern:
+//
+//	u := dns.NewUpdate("example.org.")
+//	u.RequireExists("www.example.org.", dns.TypeA)
+//	u.Delete(oldA)
+//	u.Add(newA)
+//	r, err := u.SendUpdate("192.0.2.53:53", &dns.TsigKey{Name: "update.", Secret: secret})
+type UpdateBuilder struct {
+	*Msg
+}
+
+// TsigKey is a TSIG key used to sign messages.
+type TsigKey struct {
+	Name      string // Name of the key, must be fully qualified
+	Secret    string // The base64 secret
+	Algorithm string // Defaults to HmacMD5
+}
+
+// The number of times an update is sent over UDP before giving up.
+const updateRetries = 3
+
+// NewUpdate returns an UpdateBuilder for an update of zone.
+func NewUpdate(zone string) *UpdateBuilder {
+	u := &UpdateBuilder{new(Msg)}
+	u.SetUpdate(Fqdn(zone))
+	return u
+}
+
+// updateRR returns a copy of r with the class, and when zero is true the TTL
+// and rdata length, reset.
+func updateRR(r RR, class uint16, zero bool) RR {
+	r = r.Copy()
+	r.Header().Class = class
+	if zero {
+		r.Header().Ttl = 0
+		r.Header().Rdlength = 0
+	}
+	return r
+}
+
+// Add adds the RRs to their RRsets, RFC 2136 section 2.5.1.
+func (u *UpdateBuilder) Add(rr ...RR) {
+	for _, r := range rr {
+		u.Ns = append(u.Ns, updateRR(r, u.Question[0].Qclass, false))
+	}
+}
+
+// Delete deletes the RRs from their RRsets, RFC 2136 section 2.5.4.
+func (u *UpdateBuilder) Delete(rr ...RR) {
+	for _, r := range rr {
+		r = updateRR(r, ClassNONE, false)
+		r.Header().Ttl = 0
+		u.Ns = append(u.Ns, r)
+	}
+}
+
+// DeleteRRset deletes the RRset of type t at name, RFC 2136 section 2.5.2.
+func (u *UpdateBuilder) DeleteRRset(name string, t uint16) {
+	u.Ns = append(u.Ns, &ANY{Hdr: RR_Header{Name: name, Rrtype: t, Class: ClassANY}})
+}
+
+// DeleteName deletes all RRsets at name, RFC 2136 section 2.5.3.
+func (u *UpdateBuilder) DeleteName(name string) {
+	u.Ns = append(u.Ns, &ANY{Hdr: RR_Header{Name: name, Rrtype: TypeANY, Class: ClassANY}})
+}
+
+// RequireExists requires that an RRset of type t exists at name, RFC 2136
+// section 2.4.1.
+func (u *UpdateBuilder) RequireExists(name string, t uint16) {
+	u.Answer = append(u.Answer, &ANY{Hdr: RR_Header{Name: name, Rrtype: t, Class: ClassANY}})
+}
+
+// RequireRRset requires that the RRset rr exists exactly as given, RFC 2136
+// section 2.4.2.
+func (u *UpdateBuilder) RequireRRset(rr ...RR) {
+	for _, r := range rr {
+		r = updateRR(r, u.Question[0].Qclass, false)
+		r.Header().Ttl = 0
+		u.Answer = append(u.Answer, r)
+	}
+}
+
+// RequireNotExists requires that no RRset of type t exists at name, RFC 2136
+// section 2.4.3.
+func (u *UpdateBuilder) RequireNotExists(name string, t uint16) {
+	u.Answer = append(u.Answer, &ANY{Hdr: RR_Header{Name: name, Rrtype: t, Class: ClassNONE}})
+}
+
+// RequireName requires that name is in use, RFC 2136 section 2.4.4.
+func (u *UpdateBuilder) RequireName(name string) {
+	u.Answer = append(u.Answer, &ANY{Hdr: RR_Header{Name: name, Rrtype: TypeANY, Class: ClassANY}})
+}
+
+// RequireNoName requires that name is not in use, RFC 2136 section 2.4.5.
+func (u *UpdateBuilder) RequireNoName(name string) {
+	u.Answer = append(u.Answer, &ANY{Hdr: RR_Header{Name: name, Rrtype: TypeANY, Class: ClassNONE}})
+}
+
+// SendUpdate sends the update to the server at addr, signed with tsig if it
+// is not nil. The update is sent over UDP (and over TCP when the reply is
+// truncated), it is retried when no reply is received. An error is returned
+// when the server does not reply or the reply does not have rcode NOERROR;
+// in the latter case the reply is returned too.
+func (u *UpdateBuilder) SendUpdate(addr string, tsig *TsigKey) (r *Msg, err error) {
+	c := new(Client)
+	if tsig != nil {
+		c.TsigSecret = map[string]string{tsig.Name: tsig.Secret}
+	}
+	for i := 0; i < updateRetries; i++ {
+		r, _, err = c.Exchange(u.sign(tsig), addr)
+		if err == nil && r.Truncated {
+			tcp := &Client{Net: "tcp", TsigSecret: c.TsigSecret}
+			r, _, err = tcp.Exchange(u.sign(tsig), addr)
+		}
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode != RcodeSuccess {
+		return r, &Error{Err: "update failed: " + RcodeToString[r.Rcode], Name: u.Question[0].Name, Rcode: r.Rcode, kind: ErrRcode}
+	}
+	return r, nil
+}
+
+// sign returns a copy of the update with a fresh TSIG RR when tsig is not
+// nil, with a new id.
+func (u *UpdateBuilder) sign(tsig *TsigKey) *Msg {
+	m := *u.Msg
+	m.Id = Id()
+	m.Extra = nil
+	for _, r := range u.Extra {
+		if r.Header().Rrtype != TypeTSIG {
+			m.Extra = append(m.Extra, r)
+		}
+	}
+	if tsig != nil {
+		algo := tsig.Algorithm
+		if algo == "" {
+			algo = HmacMD5
+		}
+		m.SetTsig(tsig.Name, algo, 300, time.Now().Unix())
+	}
+	return &m
+}
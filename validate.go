@@ -0,0 +1,576 @@
+// DNSSEC VALIDATION
+//
+// With Validate set a Client checks the DNSSEC signatures of the replies it
+// gets (RFC 4035, section 5). The query is sent with the DO and CD bits set
+// and the chain of trust, from the trust anchors down to the zone that signed
+// the answer, is built with DNSKEY and DS queries to the same server. That
+// server must thus be a recursive resolver. The keys are cached for the
+// duration of a single exchange only.
+//
+// Unsigned answers are Insecure when a signed DS denial proves there is an
+// insecure delegation above them, and Bogus otherwise. Denials of existence
+// are checked with NSEC and NSEC3 records, signed by a zone at or above the
+// name; a denial by an opt-out NSEC3 is Insecure. The proofs that no closer
+// name matches a wildcard are not checked.
+//
+// Basic use pattern:
+//
+//	c := new(dns.Client)
+//	c.Validate = true
+//	c.TrustAnchors = []dns.RR{ds} // a DS or DNSKEY of a zone, defaults to the root
+//	r, sec, _, err := c.ExchangeValidate(m, "127.0.0.1:53")
+//	if sec == dns.Secure {
+//		// use r
+//	}
+package dns
+
+import (
+	"strings"
+	"time"
+)
+
+// Security is the DNSSEC security status of a reply (RFC 4035, section 4.3).
+type Security int
+
+const (
+	Indeterminate Security = iota // No trust anchor covers the name
+	Insecure                      // The name is below an insecure delegation
+	Secure                        // The reply is validated
+	Bogus                         // The validation failed
+)
+
+func (s Security) String() string {
+	switch s {
+	case Insecure:
+		return "insecure"
+	case Secure:
+		return "secure"
+	case Bogus:
+		return "bogus"
+	}
+	return "indeterminate"
+}
+
+var securityRank = [...]int{Secure: 0, Insecure: 1, Indeterminate: 2, Bogus: 3}
+
+// worse returns the worst of s and s1: Bogus, then Indeterminate, then
+// Insecure and then Secure.
+func (s Security) worse(s1 Security) Security {
+	if securityRank[s1] > securityRank[s] {
+		return s1
+	}
+	return s
+}
+
+// ExchangeValidate performs a synchronous query like Exchange and validates
+// the reply with the keys in c.TrustAnchors, or with RootTrustAnchors when
+// that is empty. The AuthenticatedData bit of r
+// is set only when sec is Secure. A Bogus reply is returned with a nil
+// error, Exchange returns ErrBogus for it.
+func (c *Client) ExchangeValidate(m *Msg, a string) (r *Msg, sec Security, rtt time.Duration, err error) {
+	if len(m.Question) != 1 {
+		return nil, Indeterminate, 0, &Error{Err: "validation needs a single question"}
+	}
+	anchors := c.TrustAnchors
+	if len(anchors) == 0 {
+		if anchors, err = RootTrustAnchors(); err != nil {
+			return nil, Indeterminate, 0, err
+		}
+	}
+	c1 := *c
+	c1.Validate = false
+	c1.Retry = true
+	q, err := dnssecQuery(m)
+	if err != nil {
+		return nil, Indeterminate, 0, err
+	}
+	if r, rtt, err = c1.Exchange(q, a); err != nil {
+		return nil, Indeterminate, rtt, err
+	}
+	v := &validator{c: &c1, server: a, anchors: anchors,
+		keys: make(map[string]*validKeys), ds: make(map[string]*validDS)}
+	sec = v.validate(r, m.Question[0].Name, m.Question[0].Qtype)
+	r.AuthenticatedData = sec == Secure
+	r.CheckingDisabled = m.CheckingDisabled
+	return r, sec, rtt, nil
+}
+
+// dnssecQuery returns a copy of m with the DO and CD bits set.
+func dnssecQuery(m *Msg) (*Msg, error) {
+	b, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	q := new(Msg)
+	if err := q.Unpack(b); err != nil {
+		return nil, err
+	}
+	q.CheckingDisabled = true
+	if opt := q.IsEdns0(); opt != nil {
+		opt.SetDo()
+	} else {
+		q.SetEdns0(FlagDayMsgSize, true)
+	}
+	return q, nil
+}
+
+// validator builds the chain of trust for a single exchange.
+type validator struct {
+	c       *Client
+	server  string
+	anchors []RR
+	keys    map[string]*validKeys // keyed on the lower cased zone
+	ds      map[string]*validDS   // keyed on the lower cased name
+}
+
+// validKeys are the DNSKEYs of a zone.
+type validKeys struct {
+	keys []*DNSKEY
+	sec  Security
+}
+
+// validDS is the result of a DS query.
+type validDS struct {
+	ds  []RR
+	sec Security
+	cut bool // name is a zone cut
+}
+
+// sigRRset is an RRset with its signatures.
+type sigRRset struct {
+	rrs  []RR
+	sigs []*RRSIG
+}
+
+// sigRRsets groups rrs into RRsets and adds the signatures that cover them.
+func sigRRsets(rrs []RR) []*sigRRset {
+	var sets []*sigRRset
+	index := make(map[string]*sigRRset)
+	key := func(name string, t uint16) string {
+		return strings.ToLower(name) + "/" + TypeToString[t]
+	}
+	for _, r := range rrs {
+		h := r.Header()
+		if h.Rrtype == TypeRRSIG || h.Rrtype == TypeOPT {
+			continue
+		}
+		k := key(h.Name, h.Rrtype)
+		if s, ok := index[k]; ok {
+			s.rrs = append(s.rrs, r)
+			continue
+		}
+		s := &sigRRset{rrs: []RR{r}}
+		index[k] = s
+		sets = append(sets, s)
+	}
+	for _, r := range rrs {
+		if sig, ok := r.(*RRSIG); ok {
+			if s, ok := index[key(sig.Hdr.Name, sig.TypeCovered)]; ok {
+				s.sigs = append(s.sigs, sig)
+			}
+		}
+	}
+	return sets
+}
+
+// query sends a DNSSEC query for name/t to the server.
+func (v *validator) query(name string, t uint16) (*Msg, error) {
+	m := new(Msg)
+	m.SetQuestion(name, t)
+	m.CheckingDisabled = true
+	m.SetEdns0(FlagDayMsgSize, true)
+	r, _, err := v.c.Exchange(m, v.server)
+	if err != nil {
+		return nil, err
+	}
+	if r.Rcode != RcodeSuccess && r.Rcode != RcodeNameError {
+		return nil, ErrRcode
+	}
+	return r, nil
+}
+
+// anchor returns the closest trust anchor at or above name, or the empty
+// string.
+func (v *validator) anchor(name string) string {
+	closest := ""
+	for _, a := range v.anchors {
+		n := a.Header().Name
+		if IsSubDomain(n, name) && (closest == "" || LenLabels(n) > LenLabels(closest)) {
+			closest = n
+		}
+	}
+	return closest
+}
+
+// validate returns the security status of the reply r to name/qtype.
+func (v *validator) validate(r *Msg, name string, qtype uint16) Security {
+	if v.anchor(name) == "" {
+		return Indeterminate
+	}
+	sec := Secure
+	answered := false
+	sets, target, synth := answerChain(sigRRsets(r.Answer), name)
+	for _, s := range sets {
+		h := s.rrs[0].Header()
+		if strings.EqualFold(h.Name, target) && (h.Rrtype == qtype || qtype == TypeANY) {
+			answered = true
+		}
+		if synth[s] && len(s.sigs) == 0 {
+			continue // a CNAME synthesized from a DNAME, which is checked
+		}
+		sec = sec.worse(v.verify(s))
+	}
+	if answered && r.Rcode == RcodeSuccess {
+		return sec
+	}
+	s, proof := v.denial(r.Ns, target)
+	if s == Secure {
+		s = denied(proof, target, qtype, r.Rcode == RcodeNameError)
+	}
+	return sec.worse(s)
+}
+
+// answerChain returns the RRsets in sets that answer name: those owned by
+// name or a name on the CNAME and DNAME chain from it, and the DNAMEs used.
+// Target is the last name on the chain. The CNAMEs that match the DNAME they
+// are synthesized from are returned in synth.
+func answerChain(sets []*sigRRset, name string) (chain []*sigRRset, target string, synth map[*sigRRset]bool) {
+	synth = make(map[*sigRRset]bool)
+	seen := make(map[*sigRRset]bool)
+	add := func(s *sigRRset) {
+		if !seen[s] {
+			seen[s] = true
+			chain = append(chain, s)
+		}
+	}
+	target = name
+	names := map[string]bool{strings.ToLower(name): true}
+	for {
+		next := ""
+		var cname *sigRRset
+		for _, s := range sets {
+			h := s.rrs[0].Header()
+			switch {
+			case strings.EqualFold(h.Name, target):
+				add(s)
+				if h.Rrtype == TypeCNAME {
+					cname = s
+					next = s.rrs[0].(*CNAME).Target
+				}
+			case h.Rrtype == TypeDNAME && IsSubDomain(h.Name, target):
+				add(s)
+				if next == "" {
+					next = target[:len(target)-len(h.Name)] + s.rrs[0].(*DNAME).Target
+				}
+			}
+		}
+		if cname != nil {
+			for _, s := range sets {
+				h := s.rrs[0].Header()
+				if h.Rrtype == TypeDNAME && IsSubDomain(h.Name, target) && !strings.EqualFold(h.Name, target) &&
+					strings.EqualFold(target[:len(target)-len(h.Name)]+s.rrs[0].(*DNAME).Target, next) {
+					synth[cname] = true
+				}
+			}
+		}
+		if next == "" || names[strings.ToLower(next)] || len(names) > 8 {
+			return chain, target, synth
+		}
+		names[strings.ToLower(next)] = true
+		target = next
+	}
+}
+
+// denied returns Secure when the NSEC or NSEC3 records in proof prove that
+// qtype does not exist at name, or that name does not exist (nx is true).
+// When the proof is an opt-out NSEC3 that covers name, name may be an
+// insecure delegation and Insecure is returned. Otherwise it's Bogus.
+//
+// An NSEC or NSEC3 of a delegation, with NS but without SOA, is from the
+// parent side of the zone cut: it only denies the DS records at the cut,
+// not the names at or below it (RFC 6840, section 4.1).
+func denied(proof []RR, name string, qtype uint16, nx bool) Security {
+	sec := Bogus
+	for _, r := range proof {
+		switch n := r.(type) {
+		case *NSEC:
+			if n.MatchType(TypeNS) && !n.MatchType(TypeSOA) && IsSubDomain(n.Hdr.Name, name) && (qtype != TypeDS || !n.Match(name)) {
+				continue
+			}
+			if !nx && n.Match(name) && !n.MatchType(qtype) && !n.MatchType(TypeCNAME) {
+				return Secure
+			}
+			// An empty non-terminal has no NSEC, the NSEC before it covers it
+			if !nx && n.Cover(name) && IsSubDomain(name, n.NextDomain) {
+				return Secure
+			}
+			if nx && n.Cover(name) {
+				return Secure
+			}
+		case *NSEC3:
+			if n.MatchType(TypeNS) && !n.MatchType(TypeSOA) && n.Match(name) && qtype != TypeDS {
+				continue
+			}
+			if !nx && n.Match(name) && !n.MatchType(qtype) && !n.MatchType(TypeCNAME) {
+				return Secure
+			}
+			if n.Cover(name) {
+				if n.Flags&1 == 1 {
+					sec = Insecure // an opt-out span may hide an insecure delegation
+				} else if nx {
+					return Secure
+				}
+			}
+		}
+	}
+	return sec
+}
+
+// denial verifies the NSEC and NSEC3 RRsets in ns that deny name and
+// returns them. Only the signatures of the zones at and above name are
+// used, a zone can't deny the names of its parent.
+func (v *validator) denial(ns []RR, name string) (Security, []RR) {
+	sec := Secure
+	var proof []RR
+	for _, s := range sigRRsets(ns) {
+		t := s.rrs[0].Header().Rrtype
+		if t != TypeNSEC && t != TypeNSEC3 {
+			continue
+		}
+		var sigs []*RRSIG
+		for _, sig := range s.sigs {
+			if IsSubDomain(sig.SignerName, name) {
+				sigs = append(sigs, sig)
+			}
+		}
+		if len(sigs) == 0 && len(s.sigs) > 0 {
+			continue
+		}
+		sec = sec.worse(v.verify(&sigRRset{s.rrs, sigs}))
+		for _, r := range s.rrs {
+			if err := DefaultNSEC3Policy.Check(r); err != nil {
+				if !err.(*NSEC3Error).Insecure {
+					return Bogus, nil
+				}
+				sec = sec.worse(Insecure)
+			}
+		}
+		proof = append(proof, s.rrs...)
+	}
+	if len(proof) == 0 {
+		// Unsigned denials are fine below an insecure delegation
+		if len(ns) > 0 {
+			return v.insecure(ns[0].Header().Name), nil
+		}
+		return Bogus, nil
+	}
+	return sec, proof
+}
+
+// verify returns the security status of the RRset s.
+func (v *validator) verify(s *sigRRset) Security {
+	owner := s.rrs[0].Header().Name
+	if len(s.sigs) == 0 {
+		return v.insecure(owner)
+	}
+	sec := Bogus
+	for _, sig := range s.sigs {
+		if !IsSubDomain(sig.SignerName, owner) {
+			continue
+		}
+		// The DS RRset is signed by the parent
+		if s.rrs[0].Header().Rrtype == TypeDS && strings.EqualFold(sig.SignerName, owner) {
+			continue
+		}
+		keys, ks := v.zoneKeys(sig.SignerName)
+		if ks != Secure {
+			sec = ks
+			continue
+		}
+		if verifySig(sig, keys, s.rrs) {
+			return Secure
+		}
+	}
+	return sec
+}
+
+// verifySig returns true when one of keys verifies sig over rrs.
+func verifySig(sig *RRSIG, keys []*DNSKEY, rrs []RR) bool {
+	if !sig.ValidityPeriod() {
+		return false
+	}
+	for _, k := range keys {
+		if k.Algorithm == sig.Algorithm && k.KeyTag() == sig.KeyTag && sig.Verify(k, rrs) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneKeys returns the validated DNSKEYs of zone.
+func (v *validator) zoneKeys(zone string) ([]*DNSKEY, Security) {
+	zone = strings.ToLower(Fqdn(zone))
+	if k, ok := v.keys[zone]; ok {
+		return k.keys, k.sec
+	}
+	v.keys[zone] = &validKeys{sec: Bogus} // breaks loops
+	keys, sec := v.fetchKeys(zone)
+	v.keys[zone] = &validKeys{keys, sec}
+	return keys, sec
+}
+
+// fetchKeys queries and validates the DNSKEYs of zone: the DNSKEY RRset
+// must be signed by a key that is a trust anchor, or that matches the
+// validated DS RRset of zone.
+func (v *validator) fetchKeys(zone string) ([]*DNSKEY, Security) {
+	var trusted []RR
+	for _, a := range v.anchors {
+		if strings.EqualFold(a.Header().Name, zone) {
+			trusted = append(trusted, a)
+		}
+	}
+	if len(trusted) == 0 {
+		if v.anchor(zone) == "" {
+			return nil, Indeterminate
+		}
+		d := v.dsSet(zone)
+		if d.sec != Secure {
+			return nil, d.sec
+		}
+		if !d.cut {
+			return nil, Bogus // not a zone
+		}
+		trusted = d.ds
+	}
+	supported := false
+	for _, t := range trusted {
+		if ds, ok := t.(*DS); !ok || DigestSupported(ds.DigestType) {
+			supported = true
+		}
+	}
+	if !supported {
+		return nil, Insecure
+	}
+	r, err := v.query(zone, TypeDNSKEY)
+	if err != nil {
+		return nil, Bogus
+	}
+	for _, s := range sigRRsets(r.Answer) {
+		if s.rrs[0].Header().Rrtype != TypeDNSKEY || !strings.EqualFold(s.rrs[0].Header().Name, zone) {
+			continue
+		}
+		var keys, sep []*DNSKEY
+		for _, k := range s.rrs {
+			k := k.(*DNSKEY)
+			keys = append(keys, k)
+			for _, t := range trusted {
+				switch t := t.(type) {
+				case *DS:
+					if t.Verify(k) == nil {
+						sep = append(sep, k)
+					}
+				case *DNSKEY:
+					if t.Algorithm == k.Algorithm && t.Flags == k.Flags && t.PublicKey == k.PublicKey {
+						sep = append(sep, k)
+					}
+				}
+			}
+		}
+		for _, sig := range s.sigs {
+			if verifySig(sig, sep, s.rrs) {
+				return keys, Secure
+			}
+		}
+	}
+	return nil, Bogus
+}
+
+// dsSet queries and validates the DS RRset of name. When there is no DS
+// RRset the signed denial tells if name is an insecure delegation or not a
+// zone cut at all.
+func (v *validator) dsSet(name string) *validDS {
+	name = strings.ToLower(Fqdn(name))
+	if d, ok := v.ds[name]; ok {
+		return d
+	}
+	v.ds[name] = &validDS{sec: Bogus} // breaks loops
+	d := v.fetchDS(name)
+	v.ds[name] = d
+	return d
+}
+
+func (v *validator) fetchDS(name string) *validDS {
+	r, err := v.query(name, TypeDS)
+	if err != nil {
+		return &validDS{sec: Bogus}
+	}
+	for _, s := range sigRRsets(r.Answer) {
+		if s.rrs[0].Header().Rrtype != TypeDS || !strings.EqualFold(s.rrs[0].Header().Name, name) {
+			continue
+		}
+		if len(s.sigs) == 0 {
+			return &validDS{sec: Bogus, cut: true}
+		}
+		sec := v.verify(s)
+		if sec != Secure {
+			return &validDS{sec: sec, cut: true}
+		}
+		return &validDS{ds: s.rrs, sec: Secure, cut: true}
+	}
+	sec, proof := v.denial(r.Ns, name)
+	if sec != Secure {
+		return &validDS{sec: sec}
+	}
+	for _, p := range proof {
+		switch n := p.(type) {
+		case *NSEC:
+			if n.Match(name) {
+				if n.MatchType(TypeNS) && !n.MatchType(TypeSOA) && !n.MatchType(TypeDS) {
+					return &validDS{sec: Insecure, cut: true}
+				}
+				if !n.MatchType(TypeDS) {
+					return &validDS{sec: Secure}
+				}
+			}
+			if n.Cover(name) {
+				return &validDS{sec: Secure}
+			}
+		case *NSEC3:
+			if n.Match(name) {
+				if n.MatchType(TypeNS) && !n.MatchType(TypeSOA) && !n.MatchType(TypeDS) {
+					return &validDS{sec: Insecure, cut: true}
+				}
+				if !n.MatchType(TypeDS) {
+					return &validDS{sec: Secure}
+				}
+			}
+			// An opt-out span may hide insecure delegations
+			if n.Cover(name) && n.Flags&1 == 1 {
+				return &validDS{sec: Insecure, cut: true}
+			}
+		}
+	}
+	return &validDS{sec: Bogus}
+}
+
+// insecure returns Insecure when there is a provably insecure delegation
+// between the closest trust anchor and name, otherwise Bogus.
+func (v *validator) insecure(name string) Security {
+	anchor := v.anchor(name)
+	if anchor == "" {
+		return Indeterminate
+	}
+	labels := SplitLabels(name)
+	for i := len(labels) - LenLabels(anchor) - 1; i >= 0; i-- {
+		d := v.dsSet(strings.Join(labels[i:], ".") + ".")
+		switch d.sec {
+		case Secure:
+			continue // a secure delegation, or not a cut
+		case Insecure:
+			return Insecure
+		default:
+			return d.sec
+		}
+	}
+	return Bogus
+}
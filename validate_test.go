@@ -0,0 +1,227 @@
+package dns
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// signedZone returns the zone origin with the RRs in rrs, signed with a new
+// key when sign is true.
+func signedZone(t *testing.T, origin string, sign bool, rrs ...string) (*Zone, *DNSKEY) {
+	z := NewZone(origin)
+	rrs = append(rrs, origin+" SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400", origin+" NS open.nlnetlabs.nl.")
+	for _, s := range rrs {
+		rr, err := NewRR(s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", s, err.Error())
+		}
+		z.Insert(rr)
+	}
+	if !sign {
+		return z, nil
+	}
+	k := &DNSKEY{Hdr: RR_Header{origin, TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	p, _ := k.Generate(256)
+	z.Insert(k)
+	if err := z.Sign(map[*DNSKEY]PrivateKey{k: p}, newSignatureConfig()); err != nil {
+		t.Fatalf("failed to sign %s: %s", origin, err.Error())
+	}
+	return z, k
+}
+
+// authoritative answers from the zones in zones, like a recursive resolver
+// would. DS queries are answered from the parent zone. With tamper set to 1
+// the A records are changed, with 2 the signed A RRset of www.miek.nl. is
+// the answer to all A queries, with 3 they are denied with the NSEC
+// records of sub.miek.nl., and with 4 with the NSEC of the delegation to
+// sub.miek.nl. in miek.nl.
+func authoritative(zones *ZoneSet, tamper *int32) HandlerFunc {
+	return func(w ResponseWriter, r *Msg) {
+		q := r.Question[0]
+		m := new(Msg)
+		m.SetReply(r)
+		switch atomic.LoadInt32(tamper) {
+		case 2:
+			if q.Qtype == TypeA {
+				zd, _ := zones.Match("www.miek.nl.").Find("www.miek.nl.")
+				m.Answer = append(m.Answer, zd.RR[TypeA]...)
+				for _, s := range zd.Signatures[TypeA] {
+					m.Answer = append(m.Answer, s)
+				}
+				w.WriteMsg(m)
+				return
+			}
+		case 3:
+			if q.Qtype == TypeA {
+				sub := zones.Match("sub.miek.nl.")
+				m.Rcode = RcodeNameError
+				sub.Radix.NextDo(func(v interface{}) {
+					zd := v.(*ZoneData)
+					m.Ns = append(m.Ns, zd.RR[TypeNSEC]...)
+					for _, s := range zd.Signatures[TypeNSEC] {
+						m.Ns = append(m.Ns, s)
+					}
+				})
+				w.WriteMsg(m)
+				return
+			}
+		case 4:
+			if q.Qtype == TypeA {
+				zd, _ := zones.Match("miek.nl.").Find("sub.miek.nl.")
+				m.Ns = append(m.Ns, zd.RR[TypeNSEC]...)
+				for _, s := range zd.Signatures[TypeNSEC] {
+					m.Ns = append(m.Ns, s)
+				}
+				if !strings.EqualFold(q.Name, "sub.miek.nl.") {
+					m.Rcode = RcodeNameError
+				}
+				w.WriteMsg(m)
+				return
+			}
+		}
+		z := zones.Match(q.Name)
+		if q.Qtype == TypeDS && strings.EqualFold(z.Origin, q.Name) {
+			z = zones.Match(strings.SplitN(q.Name, ".", 2)[1])
+		}
+		if z == nil {
+			m.Rcode = RcodeRefused
+			w.WriteMsg(m)
+			return
+		}
+		zd, exact := z.Find(q.Name)
+		if exact && len(zd.RR[q.Qtype]) > 0 {
+			for _, rr := range zd.RR[q.Qtype] {
+				if atomic.LoadInt32(tamper) == 1 && q.Qtype == TypeA {
+					rr = rr.Copy()
+					rr.(*A).A = net.IPv4(10, 0, 0, 1)
+				}
+				m.Answer = append(m.Answer, rr)
+			}
+			for _, s := range zd.Signatures[q.Qtype] {
+				m.Answer = append(m.Answer, s)
+			}
+			w.WriteMsg(m)
+			return
+		}
+		apex := z.Apex()
+		m.Ns = append(m.Ns, apex.RR[TypeSOA]...)
+		for _, s := range apex.Signatures[TypeSOA] {
+			m.Ns = append(m.Ns, s)
+		}
+		z.Radix.NextDo(func(v interface{}) {
+			zd := v.(*ZoneData)
+			for _, rr := range zd.RR[TypeNSEC] {
+				n := rr.(*NSEC)
+				if (exact && n.Match(q.Name)) || (!exact && n.Cover(q.Name)) {
+					m.Ns = append(m.Ns, n)
+					for _, s := range zd.Signatures[TypeNSEC] {
+						m.Ns = append(m.Ns, s)
+					}
+				}
+			}
+		})
+		if !exact {
+			m.Rcode = RcodeNameError
+		}
+		w.WriteMsg(m)
+	}
+}
+
+func TestClientValidate(t *testing.T) {
+	sub, subKey := signedZone(t, "sub.miek.nl.", true, "www.sub.miek.nl. A 127.0.0.3")
+	parent, key := signedZone(t, "miek.nl.", true, "www.miek.nl. A 127.0.0.1",
+		"sub.miek.nl. NS ns.example.org.", subKey.ToDS(SHA256).String(),
+		"insecure.miek.nl. NS ns.insecure.miek.nl.", "ns.insecure.miek.nl. A 127.0.0.2")
+	insecure, _ := signedZone(t, "insecure.miek.nl.", false, "www.insecure.miek.nl. A 127.0.0.4")
+	zones := NewZoneSet()
+	zones.Add(parent)
+	zones.Add(sub)
+	zones.Add(insecure)
+
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err.Error())
+	}
+	defer l.Close()
+	var tamper int32
+	srv := &Server{Handler: authoritative(zones, &tamper)}
+	go srv.serveUDP(l)
+
+	c := &Client{Validate: true, TrustAnchors: []RR{key.ToDS(SHA256)}}
+	tests := []struct {
+		name  string
+		qtype uint16
+		sec   Security
+		rcode int
+	}{
+		{"www.miek.nl.", TypeA, Secure, RcodeSuccess},
+		{"www.miek.nl.", TypeMX, Secure, RcodeSuccess},
+		{"nx.miek.nl.", TypeA, Secure, RcodeNameError},
+		{"www.sub.miek.nl.", TypeA, Secure, RcodeSuccess},
+		{"www.insecure.miek.nl.", TypeA, Insecure, RcodeSuccess},
+		{"www.example.org.", TypeA, Indeterminate, RcodeRefused},
+	}
+	for _, tc := range tests {
+		m := new(Msg)
+		m.SetQuestion(tc.name, tc.qtype)
+		r, sec, _, err := c.ExchangeValidate(m, l.LocalAddr().String())
+		if err != nil {
+			t.Errorf("failed to exchange %s: %s", tc.name, err.Error())
+			continue
+		}
+		if sec != tc.sec || r.Rcode != tc.rcode {
+			t.Errorf("expected %s and rcode %d for %s/%s, got %s and %d", tc.sec, tc.rcode, tc.name, TypeToString[tc.qtype], sec, r.Rcode)
+		}
+		if r.AuthenticatedData != (sec == Secure) {
+			t.Errorf("bad AD bit for %s", tc.name)
+		}
+	}
+
+	atomic.StoreInt32(&tamper, 1)
+	m := new(Msg)
+	m.SetQuestion("www.miek.nl.", TypeA)
+	if _, _, err := c.Exchange(m, l.LocalAddr().String()); err != ErrBogus {
+		t.Errorf("expected ErrBogus for a tampered answer, got %v", err)
+	}
+
+	// A signed RRset of another name is no answer
+	atomic.StoreInt32(&tamper, 2)
+	m.SetQuestion("other.miek.nl.", TypeA)
+	if _, sec, _, err := c.ExchangeValidate(m, l.LocalAddr().String()); err != nil || sec != Bogus {
+		t.Errorf("expected bogus for the answer of another name, got %s, %v", sec, err)
+	}
+	// A child zone can't deny the names of its parent
+	atomic.StoreInt32(&tamper, 3)
+	m.SetQuestion("zzz.miek.nl.", TypeA)
+	if _, sec, _, err := c.ExchangeValidate(m, l.LocalAddr().String()); err != nil || sec != Bogus {
+		t.Errorf("expected bogus for a denial signed by sub.miek.nl., got %s, %v", sec, err)
+	}
+	// The NSEC of a delegation doesn't deny the names at or below it
+	atomic.StoreInt32(&tamper, 4)
+	for _, name := range []string{"www.sub.miek.nl.", "sub.miek.nl."} {
+		m.SetQuestion(name, TypeA)
+		if _, sec, _, err := c.ExchangeValidate(m, l.LocalAddr().String()); err != nil || sec != Bogus {
+			t.Errorf("expected bogus for %s denied by the NSEC of the delegation, got %s, %v", name, sec, err)
+		}
+	}
+}
+
+func TestDeniedOptOut(t *testing.T) {
+	n := &NSEC3{Hdr: RR_Header{Name: strings.Repeat("0", 32) + ".miek.nl.", Rrtype: TypeNSEC3, Class: ClassINET},
+		Hash: SHA1, NextDomain: strings.Repeat("V", 32), TypeBitMap: []uint16{TypeNS}}
+	if !n.Cover("www.miek.nl.") {
+		t.Fatal("NSEC3 doesn't cover www.miek.nl.")
+	}
+	if sec := denied([]RR{n}, "www.miek.nl.", TypeA, true); sec != Secure {
+		t.Errorf("expected a secure denial, got %s", sec)
+	}
+	n.Flags = 1
+	if sec := denied([]RR{n}, "www.miek.nl.", TypeA, true); sec != Insecure {
+		t.Errorf("expected an opt-out denial to be insecure, got %s", sec)
+	}
+	if sec := denied([]RR{n}, "www.miek.nl.", TypeDS, false); sec != Insecure {
+		t.Errorf("expected an opt-out DS denial to be insecure, got %s", sec)
+	}
+}
@@ -46,12 +46,16 @@ func (c *Client) TransferIn(q *Msg, a string) (chan *Envelope, error) {
 }
 
 func (w *reply) axfrIn(q *Msg, c chan *Envelope) {
+	var serial uint32 // The serial of the opening SOA, the closing SOA must have it too
 	first := true
 	defer w.conn.Close()
 	defer close(c)
 	for {
 		in, err := w.receive()
 		if err != nil {
+			if !first {
+				err = incompleteXfr(q.Question[0].Name, err)
+			}
 			c <- &Envelope{nil, err}
 			return
 		}
@@ -59,21 +63,21 @@ func (w *reply) axfrIn(q *Msg, c chan *Envelope) {
 			c <- &Envelope{in.Answer, ErrId}
 			return
 		}
+		rrs := in.Answer
 		if first {
 			if !checkXfrSOA(in, true) {
 				c <- &Envelope{in.Answer, ErrSoa}
 				return
 			}
+			serial = in.Answer[0].(*SOA).Serial
 			first = !first
+			rrs = rrs[1:] // The opening SOA doesn't close the transfer
 		}
-
-		if !first {
-			w.tsigTimersOnly = true // Subsequent envelopes use this.
-			if checkXfrSOA(in, false) {
-				c <- &Envelope{in.Answer, nil}
-				return
-			}
-			c <- &Envelope{in.Answer, nil}
+		w.tsigTimersOnly = true // Subsequent envelopes use this.
+		closed := closesXfr(rrs, serial)
+		c <- &Envelope{w.client.filterXfr(in.Answer), nil}
+		if closed {
+			return
 		}
 	}
 	panic("dns: not reached")
@@ -87,13 +91,17 @@ func (w *reply) ixfrIn(q *Msg, c chan *Envelope) {
 	for {
 		in, err := w.receive()
 		if err != nil {
-			c <- &Envelope{in.Answer, err}
+			if !first {
+				err = incompleteXfr(q.Question[0].Name, err)
+			}
+			c <- &Envelope{nil, err}
 			return
 		}
 		if q.Id != in.Id {
 			c <- &Envelope{in.Answer, ErrId}
 			return
 		}
+		rrs := in.Answer
 		if first {
 			// A single SOA RR signals "no changes"
 			if len(in.Answer) == 1 && checkXfrSOA(in, true) {
@@ -109,22 +117,141 @@ func (w *reply) ixfrIn(q *Msg, c chan *Envelope) {
 			// This serial is important
 			serial = in.Answer[0].(*SOA).Serial
 			first = !first
+			rrs = rrs[1:]
 		}
 
-		// Now we need to check each message for SOA records, to see what we need to do
-		if !first {
-			w.tsigTimersOnly = true
-			// If the last record in the IXFR contains the servers' SOA,  we should quit
-			if v, ok := in.Answer[len(in.Answer)-1].(*SOA); ok {
-				if v.Serial == serial {
-					c <- &Envelope{in.Answer, nil}
-					return
+		// If the last record in the IXFR contains the servers' SOA, we should quit
+		w.tsigTimersOnly = true
+		closed := closesXfr(rrs, serial)
+		c <- &Envelope{w.client.filterXfr(in.Answer), nil}
+		if closed {
+			return
+		}
+	}
+	panic("dns: not reached")
+}
+
+// filterXfr applies c.XfrFilter to the RRs of a transfer, except the SOA
+// records. It changes rrs.
+func (c *Client) filterXfr(rrs []RR) []RR {
+	if c.XfrFilter == nil {
+		return rrs
+	}
+	out := rrs[:0]
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != TypeSOA {
+			if rr = c.XfrFilter(rr); rr == nil {
+				continue
+			}
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+// DropDNSSEC is an XfrFilter that drops the DNSSEC records (RRSIG, NSEC,
+// NSEC3, NSEC3PARAM, DNSKEY, DS, CDS and CDNSKEY), for secondaries
+// that serve an unsigned copy of a zone or sign it themselves.
+func DropDNSSEC(rr RR) RR {
+	switch rr.Header().Rrtype {
+	case TypeRRSIG, TypeNSEC, TypeNSEC3, TypeNSEC3PARAM, TypeDNSKEY, TypeDS, TypeCDS, TypeCDNSKEY:
+		return nil
+	}
+	return rr
+}
+
+// MaxTTL returns an XfrFilter that lowers the TTLs above ttl to ttl.
+func MaxTTL(ttl uint32) func(rr RR) RR {
+	return func(rr RR) RR {
+		if rr.Header().Ttl > ttl {
+			rr = rr.Copy()
+			rr.Header().Ttl = ttl
+		}
+		return rr
+	}
+}
+
+// closesXfr returns true when the last RR in rrs is the SOA record with
+// serial, that closes a transfer.
+func closesXfr(rrs []RR, serial uint32) bool {
+	if len(rrs) == 0 {
+		return false
+	}
+	soa, ok := rrs[len(rrs)-1].(*SOA)
+	return ok && soa.Serial == serial
+}
+
+// incompleteXfr returns the error for a transfer of zone that ended, because
+// of err, before the closing SOA record was received.
+func incompleteXfr(zone string, err error) error {
+	return &Error{Err: "incomplete transfer: " + err.Error(), Name: zone, kind: ErrIncomplete, cause: err}
+}
+
+// TransferZone transfers a zone with the AXFR request q from the first of
+// servers that succeeds. It returns the RRs of the zone, starting with the
+// SOA record but without the closing SOA record, and the server that was
+// used. A partial zone is never returned: when a transfer fails, for
+// instance because the connection is lost before the closing SOA record
+// (the error matches ErrIncomplete), the transfer is started again from
+// scratch with the next server. The error of the last server is returned
+// when all of them fail.
+func (c *Client) TransferZone(q *Msg, servers ...string) ([]RR, string, error) {
+	if len(q.Question) != 1 || q.Question[0].Qtype != TypeAXFR {
+		return nil, "", &Error{Err: "not an AXFR request"}
+	}
+	err := ErrServ
+	for _, a := range servers {
+		t, e := c.TransferIn(q, a)
+		if e != nil {
+			err = e
+			continue
+		}
+		rrs, _, e := readAxfr(t, q.Question[0].Name)
+		if e == nil {
+			return rrs, a, nil
+		}
+		err = e
+	}
+	return nil, "", err
+}
+
+// readAxfr reads an AXFR of the zone origin from t and returns the RRs,
+// without the closing SOA record, and the serial. A transfer without the
+// closing SOA record returns an error that matches ErrIncomplete.
+func readAxfr(t chan *Envelope, origin string) ([]RR, uint32, error) {
+	var (
+		rrs    []RR
+		serial uint32
+	)
+	soas := 0
+	for e := range t {
+		if e.Error != nil {
+			drain(t)
+			return nil, 0, e.Error
+		}
+		for _, rr := range e.RR {
+			if soa, ok := rr.(*SOA); ok {
+				soas++
+				serial = soa.Serial
+				if soas > 1 {
+					continue // closing SOA
 				}
 			}
-			c <- &Envelope{in.Answer, nil}
+			rrs = append(rrs, rr)
 		}
 	}
-	panic("dns: not reached")
+	if soas < 2 {
+		return nil, 0, &Error{Err: "incomplete transfer", Name: origin, kind: ErrIncomplete}
+	}
+	return rrs, serial, nil
+}
+
+// drain reads the remaining envelopes from c, so the sender isn't blocked.
+func drain(c chan *Envelope) {
+	go func() {
+		for _ = range c {
+		}
+	}()
 }
 
 // Check if he SOA record exists in the Answer section of 
@@ -164,9 +291,24 @@ func checkXfrSOA(in *Msg, first bool) bool {
 //	}
 //	// w.Close() // Don't! Let the client close the connection
 func TransferOut(w ResponseWriter, q *Msg, c chan *Envelope, e *error) error {
+	return TransferOutSize(w, q, c, e, 0)
+}
+
+// The default target size of the messages of an outgoing transfer.
+const xfrMsgSize = 16384
+
+// TransferOutSize is like TransferOut, but the RRs of each envelope are
+// spread over as many (compressed) messages as needed to keep every message
+// below size bytes. An RR that is larger than size on its own is sent in a
+// message of its own. If size is zero or larger than the maximum TCP message
+// size, messages of about 16K are sent.
+func TransferOutSize(w ResponseWriter, q *Msg, c chan *Envelope, e *error, size int) error {
+	if size <= 0 || size > MaxMsgSize-1 {
+		size = xfrMsgSize
+	}
 	switch q.Question[0].Qtype {
 	case TypeAXFR, TypeIXFR:
-		go xfrOut(w, q, c, e)
+		go xfrOut(w, q, c, e, size)
 		return nil
 	default:
 		return nil
@@ -174,20 +316,43 @@ func TransferOut(w ResponseWriter, q *Msg, c chan *Envelope, e *error) error {
 	panic("dns: not reached")
 }
 
-// TODO(mg): count the RRs and the resulting size.
-func xfrOut(w ResponseWriter, req *Msg, c chan *Envelope, e *error) {
+func xfrOut(w ResponseWriter, req *Msg, c chan *Envelope, e *error, size int) {
 	rep := new(Msg)
 	rep.SetReply(req)
 	rep.Authoritative = true
+	rep.Compress = true
+	empty := rep.Len()
+	if t := req.IsTsig(); t != nil {
+		empty += t.Len() // room for the TSIG of the reply
+	}
 
-	for x := range c {
-		// assume it fits
-		rep.Answer = append(rep.Answer, x.RR...)
-		if err := w.WriteMsg(rep); e != nil {
-			*e = err
-			return
+	send := func() bool {
+		if err := w.WriteMsg(rep); err != nil {
+			if e != nil {
+				*e = err
+			}
+			return false
 		}
 		w.TsigTimersOnly(true)
 		rep.Answer = nil
+		return true
+	}
+	for x := range c {
+		// The uncompressed length is an upper bound
+		l := empty
+		for _, r := range x.RR {
+			rl := r.Len()
+			if l+rl > size && len(rep.Answer) > 0 {
+				if !send() {
+					return
+				}
+				l = empty
+			}
+			rep.Answer = append(rep.Answer, r)
+			l += rl
+		}
+		if len(rep.Answer) > 0 && !send() {
+			return
+		}
 	}
 }
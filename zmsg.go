@@ -0,0 +1,1915 @@
+// Code generated by "go run msg_generate.go"; DO NOT EDIT.
+
+package dns
+
+import "net"
+
+func (rr *A) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putA(rr.A, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *A) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.A, off, err = getA(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *A) Len() int {
+	l := rr.Hdr.Len()
+	l += net.IPv4len // A
+	return l
+}
+
+func (rr *A) Copy() RR {
+	return &A{*rr.Hdr.CopyHeader(), append(net.IP(nil), rr.A...)}
+}
+
+func (rr *AAAA) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putAAAA(rr.AAAA, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *AAAA) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.AAAA, off, err = getAAAA(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *AAAA) Len() int {
+	l := rr.Hdr.Len()
+	l += net.IPv6len // AAAA
+	return l
+}
+
+func (rr *AAAA) Copy() RR {
+	return &AAAA{*rr.Hdr.CopyHeader(), append(net.IP(nil), rr.AAAA...)}
+}
+
+func (rr *AFSDB) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Subtype, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Hostname, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *AFSDB) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Subtype, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Hostname, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *AFSDB) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Subtype
+	l += len(rr.Hostname) + 1
+	return l
+}
+
+func (rr *AFSDB) Copy() RR {
+	return &AFSDB{*rr.Hdr.CopyHeader(), rr.Subtype, rr.Hostname}
+}
+
+func (rr *ANY) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *ANY) unpack(msg []byte, off, end int) (off1 int, err error) {
+	return off, nil
+}
+
+func (rr *ANY) Len() int {
+	l := rr.Hdr.Len()
+	return l
+}
+
+func (rr *ANY) Copy() RR {
+	return &ANY{*rr.Hdr.CopyHeader()}
+}
+
+func (rr *CDNSKEY) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Flags, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Protocol, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putBase64(rr.PublicKey, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CDNSKEY) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Flags, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Protocol, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.PublicKey, off, err = getBase64(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CDNSKEY) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Flags
+	l++    // Protocol
+	l++    // Algorithm
+	l += base64Len(rr.PublicKey)
+	return l
+}
+
+func (rr *CDNSKEY) Copy() RR {
+	return &CDNSKEY{*rr.Hdr.CopyHeader(), rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey}
+}
+
+func (rr *CDS) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.KeyTag, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.DigestType, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Digest, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CDS) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.KeyTag, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.DigestType, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Digest, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CDS) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // KeyTag
+	l++    // Algorithm
+	l++    // DigestType
+	l += len(rr.Digest) / 2
+	return l
+}
+
+func (rr *CDS) Copy() RR {
+	return &CDS{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
+}
+
+func (rr *CERT) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Type, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.KeyTag, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putBase64(rr.Certificate, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CERT) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Type, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.KeyTag, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Certificate, off, err = getBase64(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CERT) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Type
+	l += 2 // KeyTag
+	l++    // Algorithm
+	l += base64Len(rr.Certificate)
+	return l
+}
+
+func (rr *CERT) Copy() RR {
+	return &CERT{*rr.Hdr.CopyHeader(), rr.Type, rr.KeyTag, rr.Algorithm, rr.Certificate}
+}
+
+func (rr *CNAME) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Target, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CNAME) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Target, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *CNAME) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Target) + 1
+	return l
+}
+
+func (rr *CNAME) Copy() RR {
+	return &CNAME{*rr.Hdr.CopyHeader(), rr.Target}
+}
+
+func (rr *DHCID) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putBase64(rr.Digest, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DHCID) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Digest, off, err = getBase64(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DHCID) Len() int {
+	l := rr.Hdr.Len()
+	l += base64Len(rr.Digest)
+	return l
+}
+
+func (rr *DHCID) Copy() RR {
+	return &DHCID{*rr.Hdr.CopyHeader(), rr.Digest}
+}
+
+func (rr *DLV) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.KeyTag, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.DigestType, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Digest, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DLV) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.KeyTag, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.DigestType, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Digest, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DLV) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // KeyTag
+	l++    // Algorithm
+	l++    // DigestType
+	l += len(rr.Digest) / 2
+	return l
+}
+
+func (rr *DLV) Copy() RR {
+	return &DLV{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
+}
+
+func (rr *DNAME) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Target, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DNAME) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Target, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DNAME) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Target) + 1
+	return l
+}
+
+func (rr *DNAME) Copy() RR {
+	return &DNAME{*rr.Hdr.CopyHeader(), rr.Target}
+}
+
+func (rr *DNSKEY) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Flags, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Protocol, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putBase64(rr.PublicKey, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DNSKEY) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Flags, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Protocol, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.PublicKey, off, err = getBase64(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DNSKEY) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Flags
+	l++    // Protocol
+	l++    // Algorithm
+	l += base64Len(rr.PublicKey)
+	return l
+}
+
+func (rr *DNSKEY) Copy() RR {
+	return &DNSKEY{*rr.Hdr.CopyHeader(), rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey}
+}
+
+func (rr *DS) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.KeyTag, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.DigestType, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Digest, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DS) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.KeyTag, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.DigestType, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Digest, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *DS) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // KeyTag
+	l++    // Algorithm
+	l++    // DigestType
+	l += len(rr.Digest) / 2
+	return l
+}
+
+func (rr *DS) Copy() RR {
+	return &DS{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
+}
+
+func (rr *HINFO) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.Cpu, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.Os, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *HINFO) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Cpu, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Os, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *HINFO) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Cpu) + 1
+	l += len(rr.Os) + 1
+	return l
+}
+
+func (rr *HINFO) Copy() RR {
+	return &HINFO{*rr.Hdr.CopyHeader(), rr.Cpu, rr.Os}
+}
+
+func (rr *KX) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Exchanger, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *KX) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Exchanger, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *KX) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Preference
+	l += len(rr.Exchanger) + 1
+	return l
+}
+
+func (rr *KX) Copy() RR {
+	return &KX{*rr.Hdr.CopyHeader(), rr.Preference, rr.Exchanger}
+}
+
+func (rr *L32) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putA(rr.Locator32, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *L32) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Locator32, off, err = getA(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *L32) Len() int {
+	l := rr.Hdr.Len()
+	l += 2           // Preference
+	l += net.IPv4len // Locator32
+	return l
+}
+
+func (rr *L32) Copy() RR {
+	return &L32{*rr.Hdr.CopyHeader(), rr.Preference, append(net.IP(nil), rr.Locator32...)}
+}
+
+func (rr *L64) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint64(rr.Locator64, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *L64) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Locator64, off, err = getUint64(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *L64) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Preference
+	l += 8 // Locator64
+	return l
+}
+
+func (rr *L64) Copy() RR {
+	return &L64{*rr.Hdr.CopyHeader(), rr.Preference, rr.Locator64}
+}
+
+func (rr *LOC) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Version, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Size, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.HorizPre, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.VertPre, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Latitude, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Longitude, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Altitude, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *LOC) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Version, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Size, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.HorizPre, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.VertPre, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Latitude, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Longitude, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Altitude, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *LOC) Len() int {
+	l := rr.Hdr.Len()
+	l++    // Version
+	l++    // Size
+	l++    // HorizPre
+	l++    // VertPre
+	l += 4 // Latitude
+	l += 4 // Longitude
+	l += 4 // Altitude
+	return l
+}
+
+func (rr *LOC) Copy() RR {
+	return &LOC{*rr.Hdr.CopyHeader(), rr.Version, rr.Size, rr.HorizPre, rr.VertPre, rr.Latitude, rr.Longitude, rr.Altitude}
+}
+
+func (rr *LP) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Fqdn, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *LP) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Fqdn, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *LP) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Preference
+	l += len(rr.Fqdn) + 1
+	return l
+}
+
+func (rr *LP) Copy() RR {
+	return &LP{*rr.Hdr.CopyHeader(), rr.Preference, rr.Fqdn}
+}
+
+func (rr *MB) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Mb, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MB) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Mb, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MB) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Mb) + 1
+	return l
+}
+
+func (rr *MB) Copy() RR {
+	return &MB{*rr.Hdr.CopyHeader(), rr.Mb}
+}
+
+func (rr *MD) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Md, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MD) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Md, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MD) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Md) + 1
+	return l
+}
+
+func (rr *MD) Copy() RR {
+	return &MD{*rr.Hdr.CopyHeader(), rr.Md}
+}
+
+func (rr *MF) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Mf, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MF) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Mf, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MF) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Mf) + 1
+	return l
+}
+
+func (rr *MF) Copy() RR {
+	return &MF{*rr.Hdr.CopyHeader(), rr.Mf}
+}
+
+func (rr *MG) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Mg, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MG) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Mg, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MG) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Mg) + 1
+	return l
+}
+
+func (rr *MG) Copy() RR {
+	return &MG{*rr.Hdr.CopyHeader(), rr.Mg}
+}
+
+func (rr *MINFO) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Rmail, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Email, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MINFO) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Rmail, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if rr.Email, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MINFO) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Rmail) + 1
+	l += len(rr.Email) + 1
+	return l
+}
+
+func (rr *MINFO) Copy() RR {
+	return &MINFO{*rr.Hdr.CopyHeader(), rr.Rmail, rr.Email}
+}
+
+func (rr *MR) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Mr, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MR) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Mr, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MR) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Mr) + 1
+	return l
+}
+
+func (rr *MR) Copy() RR {
+	return &MR{*rr.Hdr.CopyHeader(), rr.Mr}
+}
+
+func (rr *MX) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Mx, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MX) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Mx, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *MX) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Preference
+	l += len(rr.Mx) + 1
+	return l
+}
+
+func (rr *MX) Copy() RR {
+	return &MX{*rr.Hdr.CopyHeader(), rr.Preference, rr.Mx}
+}
+
+func (rr *NAPTR) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Order, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.Flags, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.Service, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.Regexp, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Replacement, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NAPTR) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Order, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Flags, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Service, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Regexp, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Replacement, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NAPTR) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Order
+	l += 2 // Preference
+	l += len(rr.Flags) + 1
+	l += len(rr.Service) + 1
+	l += len(rr.Regexp) + 1
+	l += len(rr.Replacement) + 1
+	return l
+}
+
+func (rr *NAPTR) Copy() RR {
+	return &NAPTR{*rr.Hdr.CopyHeader(), rr.Order, rr.Preference, rr.Flags, rr.Service, rr.Regexp, rr.Replacement}
+}
+
+func (rr *NID) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint64(rr.NodeID, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NID) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.NodeID, off, err = getUint64(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NID) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Preference
+	l += 8 // NodeID
+	return l
+}
+
+func (rr *NID) Copy() RR {
+	return &NID{*rr.Hdr.CopyHeader(), rr.Preference, rr.NodeID}
+}
+
+func (rr *NINFO) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putStrings(rr.ZSData, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NINFO) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.ZSData, off, err = getStrings(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NINFO) Len() int {
+	l := rr.Hdr.Len()
+	for _, s := range rr.ZSData {
+		l += len(s) + 1
+	}
+	return l
+}
+
+func (rr *NINFO) Copy() RR {
+	return &NINFO{*rr.Hdr.CopyHeader(), append([]string(nil), rr.ZSData...)}
+}
+
+func (rr *NS) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Ns, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NS) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Ns, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NS) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Ns) + 1
+	return l
+}
+
+func (rr *NS) Copy() RR {
+	return &NS{*rr.Hdr.CopyHeader(), rr.Ns}
+}
+
+func (rr *NSEC3PARAM) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Hash, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Flags, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Iterations, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.SaltLength, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Salt, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NSEC3PARAM) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Hash, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Flags, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Iterations, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.SaltLength, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Salt, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *NSEC3PARAM) Len() int {
+	l := rr.Hdr.Len()
+	l++    // Hash
+	l++    // Flags
+	l += 2 // Iterations
+	l++    // SaltLength
+	l += len(rr.Salt) / 2
+	return l
+}
+
+func (rr *NSEC3PARAM) Copy() RR {
+	return &NSEC3PARAM{*rr.Hdr.CopyHeader(), rr.Hash, rr.Flags, rr.Iterations, rr.SaltLength, rr.Salt}
+}
+
+func (rr *OPENPGPKEY) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putBase64(rr.PublicKey, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *OPENPGPKEY) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.PublicKey, off, err = getBase64(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *OPENPGPKEY) Len() int {
+	l := rr.Hdr.Len()
+	l += base64Len(rr.PublicKey)
+	return l
+}
+
+func (rr *OPENPGPKEY) Copy() RR {
+	return &OPENPGPKEY{*rr.Hdr.CopyHeader(), rr.PublicKey}
+}
+
+func (rr *PTR) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Ptr, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *PTR) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Ptr, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *PTR) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Ptr) + 1
+	return l
+}
+
+func (rr *PTR) Copy() RR {
+	return &PTR{*rr.Hdr.CopyHeader(), rr.Ptr}
+}
+
+func (rr *RFC3597) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Rdata, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RFC3597) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Rdata, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RFC3597) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Rdata) / 2
+	return l
+}
+
+func (rr *RFC3597) Copy() RR {
+	return &RFC3597{*rr.Hdr.CopyHeader(), rr.Rdata}
+}
+
+func (rr *RKEY) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Flags, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Protocol, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putBase64(rr.PublicKey, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RKEY) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Flags, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Protocol, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.PublicKey, off, err = getBase64(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RKEY) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Flags
+	l++    // Protocol
+	l++    // Algorithm
+	l += base64Len(rr.PublicKey)
+	return l
+}
+
+func (rr *RKEY) Copy() RR {
+	return &RKEY{*rr.Hdr.CopyHeader(), rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey}
+}
+
+func (rr *RP) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Mbox, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Txt, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RP) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Mbox, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if rr.Txt, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RP) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Mbox) + 1
+	l += len(rr.Txt) + 1
+	return l
+}
+
+func (rr *RP) Copy() RR {
+	return &RP{*rr.Hdr.CopyHeader(), rr.Mbox, rr.Txt}
+}
+
+func (rr *RRSIG) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.TypeCovered, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Labels, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.OrigTtl, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Expiration, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Inception, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.KeyTag, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.SignerName, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	if off, err = putBase64(rr.Signature, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RRSIG) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.TypeCovered, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Labels, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.OrigTtl, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Expiration, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Inception, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.KeyTag, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.SignerName, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if rr.Signature, off, err = getBase64(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RRSIG) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // TypeCovered
+	l++    // Algorithm
+	l++    // Labels
+	l += 4 // OrigTtl
+	l += 4 // Expiration
+	l += 4 // Inception
+	l += 2 // KeyTag
+	l += len(rr.SignerName) + 1
+	l += base64Len(rr.Signature)
+	return l
+}
+
+func (rr *RRSIG) Copy() RR {
+	return &RRSIG{*rr.Hdr.CopyHeader(), rr.TypeCovered, rr.Algorithm, rr.Labels, rr.OrigTtl, rr.Expiration, rr.Inception, rr.KeyTag, rr.SignerName, rr.Signature}
+}
+
+func (rr *RT) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Preference, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Host, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RT) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Preference, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Host, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *RT) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Preference
+	l += len(rr.Host) + 1
+	return l
+}
+
+func (rr *RT) Copy() RR {
+	return &RT{*rr.Hdr.CopyHeader(), rr.Preference, rr.Host}
+}
+
+func (rr *SOA) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Ns, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Mbox, msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Serial, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Refresh, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Retry, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Expire, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Minttl, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SOA) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Ns, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if rr.Mbox, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if rr.Serial, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Refresh, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Retry, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Expire, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Minttl, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SOA) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Ns) + 1
+	l += len(rr.Mbox) + 1
+	l += 4 // Serial
+	l += 4 // Refresh
+	l += 4 // Retry
+	l += 4 // Expire
+	l += 4 // Minttl
+	return l
+}
+
+func (rr *SOA) Copy() RR {
+	return &SOA{*rr.Hdr.CopyHeader(), rr.Ns, rr.Mbox, rr.Serial, rr.Refresh, rr.Retry, rr.Expire, rr.Minttl}
+}
+
+func (rr *SPF) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putStrings(rr.Txt, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SPF) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Txt, off, err = getStrings(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SPF) Len() int {
+	l := rr.Hdr.Len()
+	for _, s := range rr.Txt {
+		l += len(s) + 1
+	}
+	return l
+}
+
+func (rr *SPF) Copy() RR {
+	return &SPF{*rr.Hdr.CopyHeader(), append([]string(nil), rr.Txt...)}
+}
+
+func (rr *SRV) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Priority, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Weight, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Port, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Target, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SRV) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Priority, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Weight, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Port, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Target, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SRV) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // Priority
+	l += 2 // Weight
+	l += 2 // Port
+	l += len(rr.Target) + 1
+	return l
+}
+
+func (rr *SRV) Copy() RR {
+	return &SRV{*rr.Hdr.CopyHeader(), rr.Priority, rr.Weight, rr.Port, rr.Target}
+}
+
+func (rr *SSHFP) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Type, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.FingerPrint, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SSHFP) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Type, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.FingerPrint, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *SSHFP) Len() int {
+	l := rr.Hdr.Len()
+	l++ // Algorithm
+	l++ // Type
+	l += len(rr.FingerPrint) / 2
+	return l
+}
+
+func (rr *SSHFP) Copy() RR {
+	return &SSHFP{*rr.Hdr.CopyHeader(), rr.Algorithm, rr.Type, rr.FingerPrint}
+}
+
+func (rr *TA) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.KeyTag, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Algorithm, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.DigestType, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Digest, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TA) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.KeyTag, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Algorithm, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.DigestType, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Digest, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TA) Len() int {
+	l := rr.Hdr.Len()
+	l += 2 // KeyTag
+	l++    // Algorithm
+	l++    // DigestType
+	l += len(rr.Digest) / 2
+	return l
+}
+
+func (rr *TA) Copy() RR {
+	return &TA{*rr.Hdr.CopyHeader(), rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest}
+}
+
+func (rr *TALINK) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.PreviousName, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.NextName, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TALINK) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.PreviousName, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if rr.NextName, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TALINK) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.PreviousName) + 1
+	l += len(rr.NextName) + 1
+	return l
+}
+
+func (rr *TALINK) Copy() RR {
+	return &TALINK{*rr.Hdr.CopyHeader(), rr.PreviousName, rr.NextName}
+}
+
+func (rr *TKEY) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = PackDomainName(rr.Algorithm, msg, off, compression, false); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Inception, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Expiration, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Mode, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.Error, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.KeySize, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.Key, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint16(rr.OtherLen, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.OtherData, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TKEY) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Algorithm, off, err = UnpackDomainName(msg, off); err != nil {
+		return len(msg), err
+	}
+	if rr.Inception, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Expiration, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Mode, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Error, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.KeySize, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Key, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.OtherLen, off, err = getUint16(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.OtherData, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TKEY) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.Algorithm) + 1
+	l += 4 // Inception
+	l += 4 // Expiration
+	l += 2 // Mode
+	l += 2 // Error
+	l += 2 // KeySize
+	l += len(rr.Key) + 1
+	l += 2 // OtherLen
+	l += len(rr.OtherData) + 1
+	return l
+}
+
+func (rr *TKEY) Copy() RR {
+	return &TKEY{*rr.Hdr.CopyHeader(), rr.Algorithm, rr.Inception, rr.Expiration, rr.Mode, rr.Error, rr.KeySize, rr.Key, rr.OtherLen, rr.OtherData}
+}
+
+func (rr *TLSA) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Usage, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Selector, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.MatchingType, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Certificate, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TLSA) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Usage, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Selector, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.MatchingType, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Certificate, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TLSA) Len() int {
+	l := rr.Hdr.Len()
+	l++ // Usage
+	l++ // Selector
+	l++ // MatchingType
+	l += len(rr.Certificate) / 2
+	return l
+}
+
+func (rr *TLSA) Copy() RR {
+	return &TLSA{*rr.Hdr.CopyHeader(), rr.Usage, rr.Selector, rr.MatchingType, rr.Certificate}
+}
+
+func (rr *TXT) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putStrings(rr.Txt, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TXT) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Txt, off, err = getStrings(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *TXT) Len() int {
+	l := rr.Hdr.Len()
+	for _, s := range rr.Txt {
+		l += len(s) + 1
+	}
+	return l
+}
+
+func (rr *TXT) Copy() RR {
+	return &TXT{*rr.Hdr.CopyHeader(), append([]string(nil), rr.Txt...)}
+}
+
+func (rr *X25) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putString(rr.PSDNAddress, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *X25) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.PSDNAddress, off, err = getString(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *X25) Len() int {
+	l := rr.Hdr.Len()
+	l += len(rr.PSDNAddress) + 1
+	return l
+}
+
+func (rr *X25) Copy() RR {
+	return &X25{*rr.Hdr.CopyHeader(), rr.PSDNAddress}
+}
+
+func (rr *ZONEMD) pack(msg []byte, off int, compression map[string]int, compress bool) (off1 int, err error) {
+	if off, err = rr.Hdr.pack(msg, off, compression, compress); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint32(rr.Serial, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Scheme, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putUint8(rr.Hash, msg, off); err != nil {
+		return len(msg), err
+	}
+	if off, err = putHex(rr.Digest, msg, off); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *ZONEMD) unpack(msg []byte, off, end int) (off1 int, err error) {
+	if rr.Serial, off, err = getUint32(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Scheme, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Hash, off, err = getUint8(msg[:end], off); err != nil {
+		return len(msg), err
+	}
+	if rr.Digest, off, err = getHex(msg, off, end); err != nil {
+		return len(msg), err
+	}
+	return off, nil
+}
+
+func (rr *ZONEMD) Len() int {
+	l := rr.Hdr.Len()
+	l += 4 // Serial
+	l++    // Scheme
+	l++    // Hash
+	l += len(rr.Digest) / 2
+	return l
+}
+
+func (rr *ZONEMD) Copy() RR {
+	return &ZONEMD{*rr.Hdr.CopyHeader(), rr.Serial, rr.Scheme, rr.Hash, rr.Digest}
+}
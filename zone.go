@@ -3,6 +3,7 @@ package dns
 // A structure for handling zone data
 
 import (
+	"context"
 	"fmt"
 	"github.com/miekg/radix"
 	"math/rand"
@@ -16,12 +17,21 @@ import (
 // Zone represents a DNS zone. It's safe for concurrent use by 
 // multilpe goroutines.
 type Zone struct {
-	Origin       string    // Origin of the zone
-	olabels      []string  // origin cut up in labels, just to speed up the isSubDomain method
-	Wildcard     int       // Whenever we see a wildcard name, this is incremented
-	expired      bool      // Slave zone is expired
-	ModTime      time.Time // When is the zone last modified
-	*radix.Radix           // Zone data
+	Origin       string           // Origin of the zone
+	olabels      []string         // origin cut up in labels, just to speed up the isSubDomain method
+	Wildcard     int              // Whenever we see a wildcard name, this is incremented
+	expired      bool             // Slave zone is expired
+	ModTime      time.Time        // When is the zone last modified
+	NSEC3Policy  *NSEC3Policy     // If not nil, NSEC3 and NSEC3PARAM records that violate the policy are not inserted
+	MinLease     uint32           // Minimum lease in seconds granted to dynamic updates, see Lease
+	MaxLease     uint32           // If not zero, the maximum lease in seconds granted to dynamic updates
+	OnExpire     func(RR)         // If not nil, called for every RR removed by Expire
+	Authorize    AuthorizeFunc    // If not nil, called to authorize changes to the zone
+	Case         CasePolicy       // How the case of owner names is stored, see SetCase
+	HitSampling  int              // If not zero, a random 1 in HitSampling lookups by Find and FindFunc is counted, see HotNames
+	expire       map[RR]time.Time // Absolute expiry times of RRs, e.g. from update leases
+	events       *zoneEvents      // The subscribers, see Subscribe
+	*radix.Radix                  // Zone data
 	*sync.RWMutex
 }
 
@@ -54,20 +64,46 @@ type SignatureConfig struct {
 	// set all records are signed with all keys. If this flag is true and
 	// a single KSK is used for signing, only the keyset is signed.
 	HonorSepFlag bool
+	// ClockSkew is the clock skew that is tolerated between the signer
+	// and validators: a signature is refreshed when it expires within Refresh
+	// plus ClockSkew, or when its inception is more than ClockSkew in the
+	// future. Typical value is 0 to a few minutes.
+	ClockSkew time.Duration
 	// SignerRoutines specifies the number of signing goroutines, if not
 	// set runtime.NumCPU() + 1 is used as the value.
 	SignerRoutines int
 	// SOA Minttl value must be used as the ttl on NSEC/NSEC3 records.
 	Minttl uint32
+	// Progress, if not nil, is called after each signed node (name) with the
+	// number of signed nodes and the total number of nodes in the zone.
+	// The calls are serialized.
+	Progress func(signed, total int)
+	// MultiSigner enables the multi-signer mode (RFC 8901, model 2), where
+	// other providers sign the zone with their own keys. Their signatures are
+	// kept until they expire, ForeignKeys are added to the DNSKEY RRset and
+	// CDS and CDNSKEY records are added for the SEP keys, without removing the
+	// ones from the other providers.
+	MultiSigner bool
+	// ForeignKeys are the ZSKs of the other providers. In multi-signer mode
+	// they are added to the DNSKEY RRset at the apex, they are never used for
+	// signing.
+	ForeignKeys []*DNSKEY
+	// NSEC3, if not nil, holds the parameters (hash algorithm, iterations
+	// and salt) of an NSEC3 chain (RFC 5155), which replaces the NSEC chain.
+	// The NSEC3PARAM record is added at the apex.
+	NSEC3 *NSEC3PARAM
+	// OptOut sets the opt-out flag in the NSEC3 records and leaves the
+	// unsigned delegations (without a DS) out of the NSEC3 chain.
+	OptOut bool
 }
 
 func newSignatureConfig() *SignatureConfig {
-	return &SignatureConfig{time.Duration(4*7*24) * time.Hour, time.Duration(3*24) * time.Hour, time.Duration(12) * time.Hour, time.Duration(300) * time.Second, true, runtime.NumCPU() + 1, 0}
+	return &SignatureConfig{time.Duration(4*7*24) * time.Hour, time.Duration(3*24) * time.Hour, time.Duration(12) * time.Hour, time.Duration(300) * time.Second, true, 0, runtime.NumCPU() + 1, 0, nil, false, nil, nil, false}
 }
 
 // DefaultSignaturePolicy has the following values. Validity is 4 weeks, 
 // Refresh is set to 3 days, Jitter to 12 hours and InceptionOffset to 300 seconds.
-// HonorSepFlag is set to true, SignerRoutines is set to runtime.NumCPU() + 1. The
+// HonorSepFlag is set to true, ClockSkew to zero, SignerRoutines is set to runtime.NumCPU() + 1. The
 // Minttl value is zero.
 var DefaultSignatureConfig = newSignatureConfig()
 
@@ -85,14 +121,18 @@ func NewZone(origin string) *Zone {
 	z.Radix = radix.New()
 	z.RWMutex = new(sync.RWMutex)
 	z.ModTime = time.Now().UTC()
+	z.events = new(zoneEvents)
 	return z
 }
 
 // ZoneData holds all the RRs having their owner name equal to Name.
 type ZoneData struct {
+	hits       uint64              // Sampled lookups of this name, see HotNames; first for 64-bit alignment
+	misses     uint64              // Sampled lookups of names that don't exist, with this node as closest encloser
 	Name       string              // Domain name for this node
 	RR         map[uint16][]RR     // Map of the RR type to the RR
 	Signatures map[uint16][]*RRSIG // DNSSEC signatures for the RRs, stored under type covered
+	Meta       map[uint16]*Meta    // Metadata of the RRsets, see Zone.SetMeta
 	NonAuth    bool                // Always false, except for NSsets that differ from z.Origin
 	*sync.RWMutex
 }
@@ -103,41 +143,44 @@ func NewZoneData(s string) *ZoneData {
 	zd.Name = s
 	zd.RR = make(map[uint16][]RR)
 	zd.Signatures = make(map[uint16][]*RRSIG)
+	zd.Meta = make(map[uint16]*Meta)
 	zd.RWMutex = new(sync.RWMutex)
 	return zd
 }
 
-// toRadixName reverses a domain name so that when we store it in the radix tree
-// we preserve the nsec ordering of the zone (this idea was stolen from NSD).
-// Each label is also lowercased.
+// toRadixName returns the key of the domain name d in the radix tree. The
+// labels are put in from the root down, so the key of a name is a prefix of
+// the keys of the names below it, and the keys sort in the canonical order
+// of RFC 4034, section 6.1, which is the nsec ordering of the zone (this idea
+// was stolen from NSD). Each label is unescaped and lowercased, and ended by
+// two zero octets; a zero octet in a label is put in as 0x00 0x01, so it
+// sorts after the end of the label and before other octets.
 func toRadixName(d string) string {
 	if d == "" || d == "." {
 		return "."
 	}
-	s := ""
-	ld := len(d)
-	if d[ld-1] != '.' {
-		d = d + "."
-		ld++
-	}
-	var lastdot int
-	var lastbyte byte
-	var lastlastbyte byte
-	for i := 0; i < len(d); i++ {
-		if d[i] == '.' {
-			switch {
-			case lastbyte != '\\':
-				fallthrough
-			case lastbyte == '\\' && lastlastbyte == '\\':
-				s = d[lastdot:i] + "." + s
-				lastdot = i + 1
+	key := make([]byte, 1, 2*len(d)+3)
+	key[0] = '.'
+	var buf [63]byte
+	label := buf[:0]
+	e := labelsEnd(d)
+	for {
+		b := prevLabel(d, e)
+		label = appendLabelOctets(label[:0], d[b:e])
+		for _, c := range label {
+			if c == 0 {
+				key = append(key, 0, 1)
 				continue
 			}
+			key = append(key, c)
+		}
+		key = append(key, 0, 0)
+		if b == 0 {
+			break
 		}
-		lastlastbyte = lastbyte
-		lastbyte = d[i]
+		e = b - 1
 	}
-	return "." + strings.ToLower(s[:len(s)-1])
+	return string(key)
 }
 
 // String returns a string representation of a ZoneData. There is no
@@ -194,106 +237,141 @@ Types:
 }
 
 // Insert inserts the RR r into the zone. There is no check for duplicate data, although
-// Remove will remove all duplicates.
+// Remove will remove all duplicates. The zone keeps r, with z.Case set to CaseLower
+// the owner name of r is lowercased.
 func (z *Zone) Insert(r RR) error {
+	if err := z.authorize("", AuthInsert, r.Header().Name, r.Header().Rrtype); err != nil {
+		return err
+	}
+	return z.insert(r)
+}
+
+func (z *Zone) insert(r RR) error {
+	if err := z.checkInsert(r); err != nil {
+		return err
+	}
+	z.Lock()
+	defer z.Unlock()
+	z.ModTime = time.Now().UTC()
+	z.add(r)
+	return nil
+}
+
+// checkInsert returns an error when r can't be inserted in z.
+func (z *Zone) checkInsert(r RR) error {
+	if err := checkName(r.Header().Name); err != nil {
+		return err
+	}
 	if !z.isSubDomain(r.Header().Name) {
 		return &Error{Err: "out of zone data", Name: r.Header().Name}
 	}
+	if z.NSEC3Policy != nil {
+		if err := z.NSEC3Policy.Check(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// add adds r to z. The caller must hold z's lock.
+func (z *Zone) add(r RR) {
+	if z.Case == CaseLower {
+		lowerOwner(r)
+	}
 	key := toRadixName(r.Header().Name)
-	z.Lock()
-	z.ModTime = time.Now().UTC()
-	zd, exact := z.Radix.Find(key)
-	if !exact {
+	var zd *ZoneData
+	if n, exact := z.Radix.Find(key); exact {
+		zd = n.Value.(*ZoneData)
+		zd.Lock()
+		defer zd.Unlock()
+	} else {
 		// Not an exact match, so insert new value
-		defer z.Unlock()
 		// Check if it's a wildcard name
 		if len(r.Header().Name) > 1 && r.Header().Name[0] == '*' && r.Header().Name[1] == '.' {
 			z.Wildcard++
 		}
-		zd := NewZoneData(r.Header().Name)
-		switch t := r.Header().Rrtype; t {
-		case TypeRRSIG:
-			sigtype := r.(*RRSIG).TypeCovered
-			zd.Signatures[sigtype] = append(zd.Signatures[sigtype], r.(*RRSIG))
-		case TypeNS:
-			// NS records with other names than z.Origin are non-auth
-			if r.Header().Name != z.Origin {
-				zd.NonAuth = true
-			}
-			fallthrough
-		default:
-			zd.RR[t] = append(zd.RR[t], r)
-		}
+		zd = NewZoneData(r.Header().Name)
 		z.Radix.Insert(key, zd)
-		return nil
 	}
-	z.Unlock()
-	zd.Value.(*ZoneData).Lock()
-	defer zd.Value.(*ZoneData).Unlock()
-	// Name already there
 	switch t := r.Header().Rrtype; t {
 	case TypeRRSIG:
 		sigtype := r.(*RRSIG).TypeCovered
-		zd.Value.(*ZoneData).Signatures[sigtype] = append(zd.Value.(*ZoneData).Signatures[sigtype], r.(*RRSIG))
+		zd.Signatures[sigtype] = append(zd.Signatures[sigtype], r.(*RRSIG))
 	case TypeNS:
-		if r.Header().Name != z.Origin {
-			zd.Value.(*ZoneData).NonAuth = true
+		// NS records with other names than z.Origin are non-auth
+		if !strings.EqualFold(r.Header().Name, z.Origin) {
+			zd.NonAuth = true
 		}
 		fallthrough
 	default:
-		zd.Value.(*ZoneData).RR[t] = append(zd.Value.(*ZoneData).RR[t], r)
+		zd.RR[t] = append(zd.RR[t], r)
+		z.emit(ZoneEvent{Type: RRsetAdded, Name: r.Header().Name, Rrtype: t})
 	}
-	return nil
 }
 
 // Remove removes the RR r from the zone. If the RR can not be found,
 // this is a no-op.
 func (z *Zone) Remove(r RR) error {
-	key := toRadixName(r.Header().Name)
+	if err := z.authorize("", AuthRemove, r.Header().Name, r.Header().Rrtype); err != nil {
+		return err
+	}
+	return z.remove(r)
+}
+
+func (z *Zone) remove(r RR) error {
 	z.Lock()
+	defer z.Unlock()
 	z.ModTime = time.Now().UTC()
-	zd, exact := z.Radix.Find(key)
+	z.del(r)
+	return nil
+}
+
+// del removes r from z. The caller must hold z's lock.
+func (z *Zone) del(r RR) {
+	key := toRadixName(r.Header().Name)
+	delete(z.expire, r)
+	n, exact := z.Radix.Find(key)
 	if !exact {
-		defer z.Unlock()
-		return nil
+		return
 	}
-	z.Unlock()
-	zd.Value.(*ZoneData).Lock()
-	defer zd.Value.(*ZoneData).Unlock()
+	zd := n.Value.(*ZoneData)
+	zd.Lock()
+	defer zd.Unlock()
 	remove := false
 	switch t := r.Header().Rrtype; t {
 	case TypeRRSIG:
 		sigtype := r.(*RRSIG).TypeCovered
-		for i, zr := range zd.Value.(*ZoneData).Signatures[sigtype] {
+		for i, zr := range zd.Signatures[sigtype] {
 			if r == zr {
-				zd.Value.(*ZoneData).Signatures[sigtype] = append(zd.Value.(*ZoneData).Signatures[sigtype][:i], zd.Value.(*ZoneData).Signatures[sigtype][i+1:]...)
+				zd.Signatures[sigtype] = append(zd.Signatures[sigtype][:i], zd.Signatures[sigtype][i+1:]...)
 				remove = true
 			}
 		}
 		if remove {
 			// If every Signature of the covering type is removed, removed the type from the map
-			if len(zd.Value.(*ZoneData).Signatures[sigtype]) == 0 {
-				delete(zd.Value.(*ZoneData).Signatures, sigtype)
+			if len(zd.Signatures[sigtype]) == 0 {
+				delete(zd.Signatures, sigtype)
 			}
 		}
 	default:
-		for i, zr := range zd.Value.(*ZoneData).RR[t] {
+		for i, zr := range zd.RR[t] {
 			// Matching RR
 			if r == zr {
-				zd.Value.(*ZoneData).RR[t] = append(zd.Value.(*ZoneData).RR[t][:i], zd.Value.(*ZoneData).RR[t][i+1:]...)
+				zd.RR[t] = append(zd.RR[t][:i], zd.RR[t][i+1:]...)
 				remove = true
 			}
 		}
 		if remove {
 			// If every RR of this type is removed, removed the type from the map
-			if len(zd.Value.(*ZoneData).RR[t]) == 0 {
-				delete(zd.Value.(*ZoneData).RR, t)
+			if len(zd.RR[t]) == 0 {
+				delete(zd.RR, t)
+				delete(zd.Meta, t)
 			}
+			z.emit(ZoneEvent{Type: RRsetRemoved, Name: r.Header().Name, Rrtype: t})
 		}
 	}
 	if !remove {
-		return nil
+		return
 	}
 
 	if len(r.Header().Name) > 1 && r.Header().Name[0] == '*' && r.Header().Name[1] == '.' {
@@ -302,20 +380,29 @@ func (z *Zone) Remove(r RR) error {
 			z.Wildcard = 0
 		}
 	}
-	if len(zd.Value.(*ZoneData).RR) == 0 && len(zd.Value.(*ZoneData).Signatures) == 0 {
+	if len(zd.RR) == 0 && len(zd.Signatures) == 0 {
 		// Entire node is empty, remove it from the Radix tree
 		z.Radix.Remove(key)
 	}
-	return nil
 }
 
 // RemoveName removes all the RRs with ownername matching s from the zone. Typical use of this
 // method is when processing a RemoveName dynamic update packet.
 func (z *Zone) RemoveName(s string) error {
+	if err := z.authorize("", AuthRemove, s, TypeANY); err != nil {
+		return err
+	}
+	return z.removeName(s)
+}
+
+func (z *Zone) removeName(s string) error {
 	key := toRadixName(s)
 	z.Lock()
 	z.ModTime = time.Now().UTC()
 	defer z.Unlock()
+	if _, exact := z.Radix.Find(key); exact {
+		z.emit(ZoneEvent{Type: RRsetRemoved, Name: s, Rrtype: TypeANY})
+	}
 	z.Radix.Remove(key)
 	if len(s) > 1 && s[0] == '*' && s[1] == '.' {
 		z.Wildcard--
@@ -329,29 +416,43 @@ func (z *Zone) RemoveName(s string) error {
 // RemoveRRset removes all the RRs with the ownername matching s and the type matching t from the zone.
 // Typical use of this method is when processing a RemoveRRset dynamic update packet.
 func (z *Zone) RemoveRRset(s string, t uint16) error {
+	if err := z.authorize("", AuthRemove, s, t); err != nil {
+		return err
+	}
+	return z.removeRRset(s, t)
+}
+
+func (z *Zone) removeRRset(s string, t uint16) error {
 	z.Lock()
+	defer z.Unlock()
 	z.ModTime = time.Now().UTC()
-	zd, exact := z.Radix.Find(toRadixName(s))
+	z.delRRset(s, t)
+	return nil
+}
+
+// delRRset removes the RRset of s and type t from z. The caller must hold z's
+// lock.
+func (z *Zone) delRRset(s string, t uint16) {
+	n, exact := z.Radix.Find(toRadixName(s))
 	if !exact {
-		defer z.Unlock()
-		return nil
+		return
 	}
-	z.Unlock()
-	zd.Value.(*ZoneData).Lock()
-	defer zd.Value.(*ZoneData).Unlock()
+	zd := n.Value.(*ZoneData)
+	zd.Lock()
+	defer zd.Unlock()
 	switch t {
 	case TypeRRSIG:
 		// empty all signature maps
-		for covert, _ := range zd.Value.(*ZoneData).Signatures {
-			delete(zd.Value.(*ZoneData).Signatures, covert)
+		for covert, _ := range zd.Signatures {
+			delete(zd.Signatures, covert)
 		}
 	default:
-		// empty all rr maps
-		for t, _ := range zd.Value.(*ZoneData).RR {
-			delete(zd.Value.(*ZoneData).RR, t)
+		if _, ok := zd.RR[t]; ok {
+			z.emit(ZoneEvent{Type: RRsetRemoved, Name: s, Rrtype: t})
 		}
+		delete(zd.RR, t)
+		delete(zd.Meta, t)
 	}
-	return nil
 }
 
 // Apex returns the zone's apex records (SOA, NS and possibly other). If the
@@ -363,7 +464,7 @@ func (z *Zone) RemoveRRset(s string, t uint16) error {
 // Note the a) this increment is not protected by locks and b) if you use DNSSEC
 // you MUST resign the SOA record.
 func (z *Zone) Apex() *ZoneData {
-	apex, e := z.Find(z.Origin)
+	apex, e := z.lookup(z.Origin)
 	if !e {
 		fmt.Printf("%#v\n", apex)
 		return nil
@@ -371,11 +472,86 @@ func (z *Zone) Apex() *ZoneData {
 	return apex
 }
 
+// Snapshot returns a copy of all the RRs in the zone, taken while
+// the zone is locked, so the copy is consistent even when the zone is
+// modified afterwards. The apex SOA record is the first and the last RR,
+// as in an AXFR. If the zone has no SOA record ErrSoa is returned. Glue is
+// included without signatures.
+func (z *Zone) Snapshot() ([]RR, error) {
+	z.Lock()
+	defer z.Unlock()
+	apex, e := z.Radix.Find(toRadixName(z.Origin))
+	if !e {
+		return nil, ErrSoa
+	}
+	apex.Value.(*ZoneData).RLock()
+	soas, ok := apex.Value.(*ZoneData).RR[TypeSOA]
+	if !ok || len(soas) == 0 {
+		apex.Value.(*ZoneData).RUnlock()
+		return nil, ErrSoa
+	}
+	soa := soas[0].Copy()
+	apex.Value.(*ZoneData).RUnlock()
+
+	rrs := []RR{soa}
+	z.Radix.NextDo(func(i interface{}) {
+		zd := i.(*ZoneData)
+		zd.RLock()
+		defer zd.RUnlock()
+		for t, rrset := range zd.RR {
+			for _, r := range rrset {
+				if t == TypeSOA && strings.EqualFold(zd.Name, z.Origin) {
+					continue
+				}
+				rrs = append(rrs, r.Copy())
+			}
+		}
+		if z.glue(zd.Name) {
+			return // glue is sent without signatures
+		}
+		for _, sigs := range zd.Signatures {
+			for _, r := range sigs {
+				rrs = append(rrs, r.Copy())
+			}
+		}
+	})
+	return append(rrs, soa), nil
+}
+
+// TransferOut answers the AXFR request q from a Snapshot of the zone, this
+// guarantees the receiver a consistent zone, pinned at the serial
+// of the snapshot. The RRs are sent in messages of at most 16 kB, see
+// TransferOutSize. Unlike the function TransferOut, this method returns
+// when the transfer is done, so there is no need to Hijack the connection.
+func (z *Zone) TransferOut(w ResponseWriter, q *Msg) error {
+	if len(q.Question) != 1 || q.Question[0].Qtype != TypeAXFR {
+		return &Error{Err: "not an AXFR request"}
+	}
+	rrs, err := z.Snapshot()
+	if err != nil {
+		return err
+	}
+	c := make(chan *Envelope, 1)
+	c <- &Envelope{RR: rrs}
+	close(c)
+	xfrOut(w, q, c, &err, xfrMsgSize)
+	return err
+}
+
 // Find looks up the ownername s in the zone and returns the
 // data and true when an exact match is found. If an exact find isn't
 // possible the first parent node with a non-nil Value is returned and
 // the boolean is false.
 func (z *Zone) Find(s string) (node *ZoneData, exact bool) {
+	node, exact = z.lookup(s)
+	if node != nil && z.HitSampling > 0 {
+		z.count(node, exact)
+	}
+	return
+}
+
+// lookup is Find without counting the lookup, for the zone's own use.
+func (z *Zone) lookup(s string) (node *ZoneData, exact bool) {
 	z.RLock()
 	defer z.RUnlock()
 	n, e := z.Radix.Find(toRadixName(s))
@@ -397,16 +573,21 @@ func (z *Zone) FindFunc(s string, f func(interface{}) bool) (*ZoneData, bool, bo
 	if zd == nil {
 		return nil, false, false
 	}
+	if z.HitSampling > 0 {
+		z.count(zd.Value.(*ZoneData), e)
+	}
 	return zd.Value.(*ZoneData), e, b
 }
 
 func (z *Zone) isSubDomain(child string) bool {
-	return compareLabelsSlice(z.olabels, strings.ToLower(child)) == len(z.olabels)
+	return compareLabels(z.Origin, child, true) == len(z.olabels)
 }
 
 // Sign (re)signs the zone z with the given keys. 
 // NSECs and RRSIGs are added as needed. 
 // The public keys themselves are not added to the zone. 
+// When config.NSEC3 is set, an NSEC3 chain is made instead of an NSEC chain,
+// with config.OptOut unsigned delegations are left out of it.
 // If config is nil DefaultSignatureConfig is used. The signatureConfig
 // describes how the zone must be signed and if the SEP flag (for KSK)
 // should be honored. If signatures approach their expriration time, they
@@ -421,6 +602,13 @@ func (z *Zone) isSubDomain(child string) bool {
 //	}
 //	// Admire your signed zone...
 func (z *Zone) Sign(keys map[*DNSKEY]PrivateKey, config *SignatureConfig) error {
+	return z.SignContext(context.Background(), keys, config)
+}
+
+// SignContext is like Sign, but signing stops when ctx is canceled, in which
+// case ctx.Err() is returned. The zone is then partially signed, calling
+// Sign again finishes the job.
+func (z *Zone) SignContext(ctx context.Context, keys map[*DNSKEY]PrivateKey, config *SignatureConfig) error {
 	z.Lock()
 	z.ModTime = time.Now().UTC()
 	defer z.Unlock()
@@ -433,60 +621,183 @@ func (z *Zone) Sign(keys map[*DNSKEY]PrivateKey, config *SignatureConfig) error
 		keytags[k] = k.KeyTag()
 	}
 
-	errChan := make(chan error)
-	radChan := make(chan *radix.Radix, config.SignerRoutines*2)
-
-	// Start the signer goroutines
-	wg := new(sync.WaitGroup)
-	wg.Add(config.SignerRoutines)
-	for i := 0; i < config.SignerRoutines; i++ {
-		go signerRoutine(wg, keys, keytags, config, radChan, errChan)
-	}
-
 	apex, e := z.Radix.Find(toRadixName(z.Origin))
 	if !e {
 		return ErrSoa
 	}
 	config.Minttl = apex.Value.(*ZoneData).RR[TypeSOA][0].(*SOA).Minttl
-	next := apex.Next()
-	radChan <- apex
+	if config.MultiSigner {
+		apex.Value.(*ZoneData).multiSigner(keys, keytags, config)
+	}
+	if config.NSEC3 != nil {
+		if err := z.nsec3Chain(config); err != nil {
+			return err
+		}
+	}
+
+	p := &signProgress{f: config.Progress}
+	if p.f != nil {
+		z.Radix.NextDo(func(interface{}) { p.total++ })
+	}
+	routines := config.SignerRoutines
+	if routines <= 0 {
+		routines = runtime.NumCPU() + 1
+	}
+	errChan := make(chan error, routines) // each routine sends at most one error
+	radChan := make(chan *radix.Radix, routines*2)
+
+	// Start the signer goroutines
+	wg := new(sync.WaitGroup)
+	wg.Add(routines)
+	for i := 0; i < routines; i++ {
+		go signerRoutine(ctx, z, wg, keys, keytags, config, p, radChan, errChan)
+	}
 
 	var err error
+	next := apex
 Sign:
-	for next.Value.(*ZoneData).Name != z.Origin {
+	for {
+		if zd := next.Value.(*ZoneData); z.glue(zd.Name) {
+			// Glue is not signed and has no NSEC
+			zd.Lock()
+			delete(zd.RR, TypeNSEC)
+			zd.Signatures = make(map[uint16][]*RRSIG)
+			zd.Unlock()
+			p.done()
+		} else {
+			select {
+			case err = <-errChan:
+				break Sign
+			case <-ctx.Done():
+				err = ctx.Err()
+				break Sign
+			case radChan <- next:
+			}
+		}
+		next = next.Next()
+		if strings.EqualFold(next.Value.(*ZoneData).Name, z.Origin) {
+			break Sign
+		}
+	}
+	close(radChan)
+	wg.Wait()
+	if err == nil {
 		select {
 		case err = <-errChan:
-			break Sign
 		default:
-			radChan <- next
-			next = next.Next()
+			// The routines skip the remaining nodes once ctx is done
+			err = ctx.Err()
 		}
 	}
-	close(radChan)
-	close(errChan)
-	if err != nil {
-		return err
+	if err == nil {
+		z.emit(ZoneEvent{Type: Signed})
 	}
-	wg.Wait()
-	return nil
+	return err
+}
+
+// signProgress counts the signed nodes for the Progress callback.
+type signProgress struct {
+	f      func(signed, total int)
+	signed int
+	total  int
+	sync.Mutex
+}
+
+func (p *signProgress) done() {
+	if p.f == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.signed++
+	p.f(p.signed, p.total)
 }
 
 // signerRoutine is a small helper routine to make the concurrent signing work.
-func signerRoutine(wg *sync.WaitGroup, keys map[*DNSKEY]PrivateKey, keytags map[*DNSKEY]uint16, config *SignatureConfig, in chan *radix.Radix, err chan error) {
+func signerRoutine(ctx context.Context, z *Zone, wg *sync.WaitGroup, keys map[*DNSKEY]PrivateKey, keytags map[*DNSKEY]uint16, config *SignatureConfig, p *signProgress, in chan *radix.Radix, err chan error) {
 	defer wg.Done()
-	for {
-		select {
-		case data, ok := <-in:
-			if !ok {
-				return
-			}
-			e := data.Value.(*ZoneData).Sign(data.Next().Value.(*ZoneData).Name, keys, keytags, config)
-			if e != nil {
-				err <- e
-				return
-			}
+	for data := range in {
+		if ctx.Err() != nil {
+			continue // drain
+		}
+		e := data.Value.(*ZoneData).Sign(z.next(data).Value.(*ZoneData).Name, keys, keytags, config)
+		if e != nil {
+			err <- e
+			return
+		}
+		p.done()
+	}
+}
+
+// next returns the first node after node that is not glue, see Zone.IsGlue.
+// The caller must hold z's lock.
+func (z *Zone) next(node *radix.Radix) *radix.Radix {
+	for node = node.Next(); z.glue(node.Value.(*ZoneData).Name); node = node.Next() {
+	}
+	return node
+}
+
+// TypeBitMap returns the sorted types that the NSEC or NSEC3 record for zd
+// must list, without the NSEC or NSEC3 type itself. At a delegation
+// (zd.NonAuth is true) only the NS and DS types are authoritative, the other
+// types there are occluded. RRSIG is included when one of the listed RRsets is
+// signed, that is when there is authoritative data other than an unsigned
+// NSset. For an empty non-terminal, or when zd is nil, the bitmap is empty. The
+// caller must hold zd's lock.
+func TypeBitMap(zd *ZoneData) []uint16 {
+	if zd == nil {
+		return nil
+	}
+	bitmap := make([]uint16, 0, len(zd.RR)+1)
+	signed := false
+	for t, rrs := range zd.RR {
+		if len(rrs) == 0 {
+			continue
+		}
+		switch t {
+		case TypeNSEC, TypeNSEC3, TypeRRSIG:
+			continue
+		}
+		if zd.NonAuth && t != TypeNS && t != TypeDS {
+			continue
+		}
+		if t != TypeNS || !zd.NonAuth {
+			signed = true
+		}
+		bitmap = append(bitmap, t)
+	}
+	if signed {
+		bitmap = append(bitmap, TypeRRSIG)
+	}
+	sort.Sort(uint16Slice(bitmap))
+	return bitmap
+}
+
+// uniqUint16 removes the duplicates from the sorted slice a.
+func uniqUint16(a []uint16) []uint16 {
+	if len(a) == 0 {
+		return a
+	}
+	j := 0
+	for i := 1; i < len(a); i++ {
+		if a[i] != a[j] {
+			j++
+			a[j] = a[i]
+		}
+	}
+	return a[:j+1]
+}
+
+func equalUint16(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, _ := range a {
+		if a[i] != b[i] {
+			return false
 		}
 	}
+	return true
 }
 
 // Sign signs a single ZoneData node. The zonedata itself is locked for writing,
@@ -500,50 +811,32 @@ func (node *ZoneData) Sign(next string, keys map[*DNSKEY]PrivateKey, keytags map
 	defer node.Unlock()
 
 	n, nsecok := node.RR[TypeNSEC]
-	bitmap := []uint16{TypeNSEC, TypeRRSIG}
-	bitmapEqual := true
-	for t, _ := range node.RR {
-		if nsecok {
-			// Check if the current (if available) nsec has these types too
-			// Grr O(n^2)
-			found := false
-			for _, v := range n[0].(*NSEC).TypeBitMap {
-				if v == t {
-					found = true
-					break
-				}
-				if v > t { // It is sorted, so by now we haven't found it
-					found = false
-					break
-				}
-			}
-			if !found {
-				bitmapEqual = false
-			}
-		}
-		if t == TypeNSEC || t == TypeRRSIG {
-			continue
-		}
-		bitmap = append(bitmap, t)
-
-	}
+	bitmap := append(TypeBitMap(node), TypeNSEC, TypeRRSIG)
 	sort.Sort(uint16Slice(bitmap))
-
+	bitmap = uniqUint16(bitmap)
+	bitmapEqual := false
 	if nsecok {
+		bitmapEqual = equalUint16(n[0].(*NSEC).TypeBitMap, bitmap)
+	}
+
+	if config.NSEC3 != nil {
+		// The NSEC3 chain is made by the zone, see Zone.Sign
+		delete(node.RR, TypeNSEC)
+		delete(node.Signatures, TypeNSEC)
+	} else if nsecok {
 		// There is an NSEC, check if it still points to the correct next node.
 		// Secondly the type bitmap may have changed.
-		// TODO(mg): actually checked the types in the map
 		if n[0].(*NSEC).NextDomain != next || !bitmapEqual {
 			n[0].(*NSEC).NextDomain = next
 			n[0].(*NSEC).TypeBitMap = bitmap
-			node.Signatures[TypeNSEC] = nil // drop all sigs
+			node.dropSignatures(TypeNSEC, keytags, config)
 		}
 	} else {
 		// No NSEC at all, create one
 		nsec := &NSEC{Hdr: RR_Header{node.Name, TypeNSEC, ClassINET, config.Minttl, 0}, NextDomain: next}
 		nsec.TypeBitMap = bitmap
 		node.RR[TypeNSEC] = []RR{nsec}
-		node.Signatures[TypeNSEC] = nil // drop all sigs (just in case)
+		node.dropSignatures(TypeNSEC, keytags, config) // just in case
 	}
 
 	// Walk all keys, and check the sigs
@@ -565,7 +858,7 @@ func (node *ZoneData) Sign(next string, keys map[*DNSKEY]PrivateKey, keytags map
 			}
 
 			j, q := signatures(node.Signatures[t], keytags[k])
-			if q == nil || now.Sub(uint32ToTime(q.Expiration)) < config.Refresh { // no there, are almost expired
+			if q == nil || NeedsResign(q, now, config) { // no there, are almost expired
 				s := new(RRSIG)
 				s.SignerName = k.Hdr.Name
 				s.Hdr.Ttl = k.Hdr.Ttl
@@ -587,18 +880,74 @@ func (node *ZoneData) Sign(next string, keys map[*DNSKEY]PrivateKey, keytags map
 		}
 	}
 	// All signatures have been made are refreshed. Now check the all signatures for expiraton
-	for i, s := range node.Signatures {
-		// s is another slice
-		for i1, s1 := range s {
-			if now.Sub(uint32ToTime(s1.Expiration)) < config.Refresh {
-				// can only happen if made with an unknown key, drop the sig
-				node.Signatures[i] = append(node.Signatures[i][:i1], node.Signatures[i][i1+1:]...)
+	for t, sigs := range node.Signatures {
+		keep := sigs[:0]
+		for _, s := range sigs {
+			// can only happen if made with an unknown key, drop the sig. In
+			// multi-signer mode the other provider refreshes it, so only drop it
+			// when it's expired.
+			if uint32ToTime(s.Expiration).Before(now) || (NeedsResign(s, now, config) && !config.MultiSigner) {
+				continue
 			}
+			keep = append(keep, s)
 		}
+		node.Signatures[t] = keep
 	}
 	return nil
 }
 
+// dropSignatures drops the signatures over the RRset of type t, because the
+// RRset changed. In multi-signer mode only the signatures made with keys are
+// dropped, the other providers must re-sign the RRset themselves.
+func (node *ZoneData) dropSignatures(t uint16, keys map[*DNSKEY]uint16, config *SignatureConfig) {
+	if !config.MultiSigner {
+		node.Signatures[t] = nil
+		return
+	}
+	keep := node.Signatures[t][:0]
+	for _, s := range node.Signatures[t] {
+		own := false
+		for _, tag := range keys {
+			own = own || s.KeyTag == tag
+		}
+		if !own {
+			keep = append(keep, s)
+		}
+	}
+	node.Signatures[t] = keep
+}
+
+// multiSigner adds the foreign keys to the DNSKEY RRset and the CDS and
+// CDNSKEY records of the SEP keys in keys to the apex node.
+func (node *ZoneData) multiSigner(keys map[*DNSKEY]PrivateKey, keytags map[*DNSKEY]uint16, config *SignatureConfig) {
+	node.Lock()
+	defer node.Unlock()
+	add := func(r RR) {
+		t := r.Header().Rrtype
+		for _, x := range node.RR[t] {
+			if sameRdata(x, r) {
+				return
+			}
+		}
+		node.RR[t] = append(node.RR[t], r)
+		node.dropSignatures(t, keytags, config)
+	}
+	for _, k := range config.ForeignKeys {
+		add(k.Copy())
+	}
+	for k, _ := range keys {
+		if k.Flags&SEP != SEP {
+			continue
+		}
+		ds := k.ToDS(SHA256)
+		if ds == nil {
+			continue
+		}
+		add(&CDS{RR_Header{node.Name, TypeCDS, ClassINET, k.Hdr.Ttl, 0}, ds.KeyTag, ds.Algorithm, ds.DigestType, ds.Digest})
+		add(&CDNSKEY{RR_Header{node.Name, TypeCDNSKEY, ClassINET, k.Hdr.Ttl, 0}, k.Flags, k.Protocol, k.Algorithm, k.PublicKey})
+	}
+}
+
 // Return the signature for the typecovered and make with the keytag. It
 // returns the index of the RRSIG and the RRSIG itself.
 func signatures(signatures []*RRSIG, keytag uint16) (int, *RRSIG) {
@@ -610,6 +959,17 @@ func signatures(signatures []*RRSIG, keytag uint16) (int, *RRSIG) {
 	return 0, nil
 }
 
+// NeedsResign returns true when the signature sig must be replaced at time
+// now: when it expires within config.Refresh, or when its inception is in the
+// future. Both checks allow for config.ClockSkew. Expired signatures always
+// need to be replaced.
+func NeedsResign(sig *RRSIG, now time.Time, config *SignatureConfig) bool {
+	if uint32ToTime(sig.Expiration).Sub(now) < config.Refresh+config.ClockSkew {
+		return true
+	}
+	return uint32ToTime(sig.Inception).Sub(now) > config.ClockSkew
+}
+
 // timeToUint32 translates a time.Time to a 32 bit value which                      
 // can be used as the RRSIG's inception or expiration times.
 func timeToUint32(t time.Time) uint32 {
@@ -627,8 +987,7 @@ func uint32ToTime(t uint32) time.Time {
 	if mod < 0 {
 		mod = 0
 	}
-	duration := time.Duration((mod * year68) * int64(t))
-	return time.Unix(0, 0).Add(duration)
+	return time.Unix(mod*year68+int64(t), 0)
 }
 
 // jitterTime returns a random +/- jitter
@@ -639,25 +998,3 @@ func jitterDuration(d time.Duration) time.Duration {
 	}
 	return -time.Duration(jitter)
 }
-
-// compareLabels behaves exactly as CompareLabels expect that l1 is already
-// a tokenize (in labels) version of the domain name. This saves memory and is faster.
-func compareLabelsSlice(l1 []string, s2 string) (n int) {
-	l2 := SplitLabels(s2)
-
-	x1 := len(l1) - 1
-	x2 := len(l2) - 1
-	for {
-		if x1 < 0 || x2 < 0 {
-			break
-		}
-		if l1[x1] == l2[x2] {
-			n++
-		} else {
-			break
-		}
-		x1--
-		x2--
-	}
-	return
-}
@@ -1,23 +1,74 @@
 package dns
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestRadixName(t *testing.T) {
 	tests := map[string]string{".": ".",
-		"www.miek.nl.": ".nl.miek.www",
-		"miek.nl.":     ".nl.miek",
-		"mi\\.ek.nl.":  ".nl.mi\\.ek",
-		`mi\\.ek.nl.`:  `.nl.ek.mi\\`,
+		"www.miek.nl.": ".nl\x00\x00miek\x00\x00www\x00\x00",
+		"miek.nl.":     ".nl\x00\x00miek\x00\x00",
+		"mi\\.ek.nl.":  ".nl\x00\x00mi.ek\x00\x00",
+		`mi\\.ek.nl.`:  ".nl\x00\x00ek\x00\x00mi\\\x00\x00",
+		`Mi\069K.nl`:   ".nl\x00\x00miek\x00\x00",
+		`a\000.nl.`:    ".nl\x00\x00a\x00\x01\x00\x00",
 		"":             "."}
 	for i, o := range tests {
-		t.Logf("%s %v\n", i, SplitLabels(i))
 		if x := toRadixName(i); x != o {
-			t.Logf("%s should convert to %s, not %s\n", i, o, x)
-			t.Fail()
+			t.Errorf("%s should convert to %q, not %q", i, o, x)
 		}
 	}
 }
 
+func TestRadixNameOrder(t *testing.T) {
+	// In canonical order, RFC 4034 section 6.1 (with a few more).
+	names := []string{"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.",
+		"zABC.a.EXAMPLE.", "a-b.example.", "z.example.", "\\001.z.example.",
+		"*.z.example.", "\\200.z.example."}
+	for i := 1; i < len(names); i++ {
+		if toRadixName(names[i-1]) >= toRadixName(names[i]) {
+			t.Errorf("%s should sort before %s", names[i-1], names[i])
+		}
+		if CanonicalCompare(names[i-1], names[i]) != -1 || CanonicalCompare(names[i], names[i-1]) != 1 {
+			t.Errorf("CanonicalCompare: %s should sort before %s", names[i-1], names[i])
+		}
+	}
+	if strings.HasPrefix(toRadixName("miek.nl."), toRadixName("mie.nl.")) {
+		t.Error("mie.nl. should not be a prefix of miek.nl.")
+	}
+}
+
+// FuzzRadixName checks that radix keys sort like CanonicalCompare and that
+// the key of a name is a prefix of the keys of the names below it.
+func FuzzRadixName(f *testing.F) {
+	f.Add("miek.nl.", "www.miek.nl.")
+	f.Add("a-b.example.", "b.a.example.")
+	f.Add(`a\000.nl.`, "b.a.nl.")
+	f.Add(`mi\.ek.nl.`, `MI\046ek.nl`)
+	f.Add(".", "a\\")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		ka, kb := toRadixName(a), toRadixName(b)
+		if c := strings.Compare(ka, kb); c != CanonicalCompare(a, b) {
+			t.Errorf("%q and %q: keys compare %d, CanonicalCompare %d", a, b, c, CanonicalCompare(a, b))
+		}
+		child := "x." + b
+		if b == "" || b == "." {
+			child = "x."
+		}
+		if kc := toRadixName(child); !strings.HasPrefix(kc, kb) || kc == kb {
+			t.Errorf("key of %q should start with the key of %q", child, b)
+		}
+	})
+}
+
 func TestInsert(t *testing.T) {
 	z := NewZone("miek.nl.")
 	mx, _ := NewRR("foo.miek.nl. MX 10 mx.miek.nl.")
@@ -43,3 +94,1152 @@ func TestRemove(t *testing.T) {
 		t.Errorf("zd(%s) exact(%s) still exists", zd, exact) // it should no longer be in the zone
 	}
 }
+
+func TestSnapshot(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "foo.miek.nl. MX 10 mx.miek.nl.", "www.miek.nl. A 127.0.0.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	rrs, err := z.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot failed: %s", err.Error())
+	}
+	if len(rrs) != 5 || rrs[0].Header().Rrtype != TypeSOA || rrs[4].Header().Rrtype != TypeSOA {
+		t.Fatalf("snapshot should have 5 RRs with SOA first and last, got %v", rrs)
+	}
+	// Changing the zone must not change the snapshot
+	z.Apex().RR[TypeSOA][0].(*SOA).Serial++
+	if rrs[0].(*SOA).Serial != 1 {
+		t.Error("snapshot changed when the zone changed")
+	}
+	if _, err := NewZone("example.org.").Snapshot(); err != ErrSoa {
+		t.Error("snapshot of zone without SOA should fail")
+	}
+
+	// The SOA of an apex in another case than the origin is only sent twice
+	z = NewZone("miek.nl.")
+	for _, s := range []string{"Miek.NL. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"Miek.NL. NS open.nlnetlabs.nl.", "www.miek.nl. A 127.0.0.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	rrs, _ = z.Snapshot()
+	soas := 0
+	for _, r := range rrs {
+		if r.Header().Rrtype == TypeSOA {
+			soas++
+		}
+	}
+	if len(rrs) != 4 || soas != 2 || rrs[3].Header().Rrtype != TypeSOA {
+		t.Errorf("snapshot should have 4 RRs with SOA first and last, got %v", rrs)
+	}
+}
+
+func TestTypeBitMap(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "miek.nl. MX 10 mx.miek.nl.",
+		"sub.miek.nl. NS ns.sub.miek.nl.", "sub.miek.nl. A 127.0.0.1",
+		"secure.miek.nl. NS ns.secure.miek.nl.", "secure.miek.nl. DS 12051 8 2 6f0fa6b0b1b5b4b39e0e2c3d1d1c8f50f3ec4f1d2b6a5c4f58ef03a6fa30d6b2"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	tests := map[string][]uint16{
+		"miek.nl.":        []uint16{TypeNS, TypeSOA, TypeMX, TypeRRSIG},
+		"sub.miek.nl.":    []uint16{TypeNS},
+		"secure.miek.nl.": []uint16{TypeNS, TypeDS, TypeRRSIG},
+	}
+	for name, want := range tests {
+		zd, _ := z.Find(name)
+		if b := TypeBitMap(zd); !equalUint16(b, want) {
+			t.Errorf("bitmap for %s should be %v, not %v", name, want, b)
+		}
+	}
+	if b := TypeBitMap(NewZoneData("ent.miek.nl.")); len(b) != 0 {
+		t.Errorf("bitmap for an empty non-terminal should be empty, not %v", b)
+	}
+}
+
+func TestZoneBuilder(t *testing.T) {
+	b := NewZoneBuilder("miek.nl")
+	b.SOA("open.nlnetlabs.nl.", "miekg.atoom.net.", 1).NS("@", "open.nlnetlabs.nl.")
+	b.Ttl(300).A("www", "127.0.0.1").MX("@", 10, "mx").SRV("_sip._udp", 0, 5, 5060, "www")
+	z, err := b.Zone()
+	if err != nil {
+		t.Fatalf("failed to build zone: %s", err.Error())
+	}
+	zd, exact := z.Find("www.miek.nl.")
+	if !exact || zd.RR[TypeA][0].Header().Ttl != 300 {
+		t.Fatalf("www.miek.nl. A with TTL 300 not found")
+	}
+	if mx := z.Apex().RR[TypeMX][0].(*MX); mx.Mx != "mx.miek.nl." || mx.Hdr.Ttl != 300 {
+		t.Errorf("bad MX record: %s", mx.String())
+	}
+	if _, err := b.CNAME("www", "mx").Zone(); err == nil {
+		t.Error("CNAME and other data should fail")
+	}
+	if _, err := NewZoneBuilder("miek.nl.").A("www", "::1").Zone(); err == nil {
+		t.Error("IPv6 address in A record should fail")
+	}
+	if _, err := NewZoneBuilder("miek.nl.").NS("@", "ns").Zone(); err != ErrSoa {
+		t.Error("zone without SOA should fail")
+	}
+}
+
+func TestEnsureApex(t *testing.T) {
+	z := NewZone("miek.nl.")
+	if err := z.EnsureApex(nil, nil); err == nil {
+		t.Error("apex without name servers should fail")
+	}
+	if err := z.EnsureApex(&SOA{Minttl: 300}, []string{"ns1", "ns2.example.net."}); err != nil {
+		t.Fatalf("failed to add the apex: %s", err.Error())
+	}
+	apex := z.Apex()
+	soa := apex.RR[TypeSOA][0].(*SOA)
+	if soa.Ns != "ns1.miek.nl." || soa.Mbox != "hostmaster.miek.nl." || soa.Minttl != 300 || soa.Refresh != 14400 {
+		t.Errorf("bad SOA: %s", soa.String())
+	}
+	if soa.Serial != SerialFromTime(time.Now()) || soa.Serial < 2013010100 {
+		t.Errorf("bad serial: %d", soa.Serial)
+	}
+	if len(apex.RR[TypeNS]) != 2 || apex.RR[TypeNS][1].(*NS).Ns != "ns2.example.net." {
+		t.Errorf("bad NS records: %v", apex.RR[TypeNS])
+	}
+	// Nothing is added a second time
+	if err := z.EnsureApex(nil, []string{"ns3"}); err != nil || len(apex.RR[TypeNS]) != 2 || len(apex.RR[TypeSOA]) != 1 {
+		t.Error("existing apex records changed")
+	}
+	if SerialFromTime(time.Date(2013, 6, 18, 12, 0, 0, 0, time.UTC)) != 2013061800 {
+		t.Error("bad serial from time")
+	}
+}
+
+func TestZONEMD(t *testing.T) {
+	// RFC 8976, Appendix A.1
+	const zone = `
+example.      86400  IN  SOA     ns1 admin 2018031900 (
+                                 1800 900 604800 86400 )
+              86400  IN  NS      ns1
+              86400  IN  NS      ns2
+              86400  IN  ZONEMD  2018031900 1 1 (
+                                 c68090d90a7aed71
+                                 6bc459f9340e3d7c
+                                 1370d4d24b7e2fc3
+                                 a1ddc0b9a87153b9
+                                 a9713b3c9ae5cc27
+                                 777f98b8e730044c )
+ns1           3600   IN  A       203.0.113.63
+ns2           3600   IN  AAAA    2001:db8::63
+`
+	z := NewZone("example.")
+	for x := range ParseZone(strings.NewReader(zone), "example.", "") {
+		if x.Error != nil {
+			t.Fatalf("failed to parse zone: %s", x.Error.Error())
+		}
+		z.Insert(x.RR)
+	}
+	if err := z.VerifyZONEMD(); err != nil {
+		t.Fatalf("failed to verify ZONEMD: %s", err.Error())
+	}
+	a, _ := NewRR("ns3.example. A 203.0.113.64")
+	z.Insert(a)
+	if z.VerifyZONEMD() == nil {
+		t.Fatal("ZONEMD should not match a changed zone")
+	}
+	md, err := z.ComputeZONEMD(ZoneMDSimple, ZoneMDSHA512)
+	if err != nil {
+		t.Fatalf("failed to compute ZONEMD: %s", err.Error())
+	}
+	z.RemoveRRset("example.", TypeZONEMD)
+	z.Insert(md)
+	if err := z.VerifyZONEMD(); err != nil {
+		t.Fatalf("failed to verify computed ZONEMD: %s", err.Error())
+	}
+}
+
+func TestSignMultiSigner(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "www.miek.nl. A 127.0.0.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	keys := make(map[*DNSKEY]PrivateKey)
+	for _, flags := range []uint16{257, 256} {
+		k := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: flags, Protocol: 3, Algorithm: ECDSAP256SHA256}
+		p, _ := k.Generate(256)
+		keys[k] = p
+		z.Insert(k)
+	}
+	foreign := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	foreign.Generate(256)
+	// A signature of the other provider
+	www, _ := z.Find("www.miek.nl.")
+	www.Signatures[TypeA] = []*RRSIG{{Hdr: RR_Header{"www.miek.nl.", TypeRRSIG, ClassINET, 3600, 0}, TypeCovered: TypeA,
+		Algorithm: ECDSAP256SHA256, KeyTag: foreign.KeyTag(), SignerName: "miek.nl.", Signature: "AAAA",
+		Inception: timeToUint32(time.Now()), Expiration: timeToUint32(time.Now().Add(24 * time.Hour))}}
+
+	config := newSignatureConfig()
+	config.MultiSigner = true
+	config.ForeignKeys = []*DNSKEY{foreign}
+	if err := z.Sign(keys, config); err != nil {
+		t.Fatalf("failed to sign zone: %s", err.Error())
+	}
+	apex := z.Apex()
+	if len(apex.RR[TypeDNSKEY]) != 3 {
+		t.Errorf("expected 3 DNSKEYs, got %d", len(apex.RR[TypeDNSKEY]))
+	}
+	if len(apex.RR[TypeCDS]) != 1 || len(apex.RR[TypeCDNSKEY]) != 1 {
+		t.Error("expected a CDS and a CDNSKEY for the KSK")
+	}
+	if len(apex.Signatures[TypeDNSKEY]) == 0 {
+		t.Error("DNSKEY RRset is not signed")
+	}
+	if len(www.Signatures[TypeA]) != 2 {
+		t.Errorf("expected the own and the foreign signature, got %d signatures", len(www.Signatures[TypeA]))
+	}
+}
+
+func TestUpdateSigned(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "a.miek.nl. A 127.0.0.1", "c.miek.nl. A 127.0.0.3"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	zsk := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	p, _ := zsk.Generate(256)
+	z.Insert(zsk)
+	keys := map[*DNSKEY]PrivateKey{zsk: p}
+	if err := z.Sign(keys, nil); err != nil {
+		t.Fatalf("failed to sign zone: %s", err.Error())
+	}
+	nextDomain := func(name string) string {
+		zd, exact := z.Find(name)
+		if !exact || len(zd.RR[TypeNSEC]) == 0 {
+			return ""
+		}
+		return zd.RR[TypeNSEC][0].(*NSEC).NextDomain
+	}
+	b, _ := NewRR("b.miek.nl. A 127.0.0.2")
+	u := new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.Insert([]RR{b})
+	if err := z.Update(u, keys, nil); err != nil {
+		t.Fatalf("failed to update zone: %s", err.Error())
+	}
+	if nextDomain("a.miek.nl.") != "b.miek.nl." || nextDomain("b.miek.nl.") != "c.miek.nl." {
+		t.Fatalf("NSEC chain not fixed after insert: a -> %s, b -> %s", nextDomain("a.miek.nl."), nextDomain("b.miek.nl."))
+	}
+	zd, _ := z.Find("b.miek.nl.")
+	if len(zd.Signatures[TypeA]) != 1 || zd.Signatures[TypeA][0].Verify(zsk, zd.RR[TypeA]) != nil {
+		t.Error("new A record is not signed")
+	}
+	if soa := z.Apex().RR[TypeSOA][0].(*SOA); soa.Serial != 2 || z.Apex().Signatures[TypeSOA][0].Verify(zsk, []RR{soa}) != nil {
+		t.Error("SOA serial not incremented and re-signed")
+	}
+
+	u = new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.RemoveName([]RR{b})
+	if err := z.Update(u, keys, nil); err != nil {
+		t.Fatalf("failed to update zone: %s", err.Error())
+	}
+	if _, exact := z.Find("b.miek.nl."); exact {
+		t.Error("b.miek.nl. still exists")
+	}
+	if nextDomain("a.miek.nl.") != "c.miek.nl." {
+		t.Errorf("NSEC chain not fixed after delete: a -> %s", nextDomain("a.miek.nl."))
+	}
+}
+
+func TestNeedsResign(t *testing.T) {
+	now := time.Unix(1400000000, 0)
+	config := &SignatureConfig{Refresh: 3 * 24 * time.Hour, ClockSkew: 5 * time.Minute}
+	tests := []struct {
+		inception, expiration time.Time
+		resign                bool
+	}{
+		{now.Add(-time.Hour), now.Add(28 * 24 * time.Hour), false},                         // fresh
+		{now.Add(-time.Hour), now.Add(3*24*time.Hour + 5*time.Minute), false},              // exactly at refresh + skew
+		{now.Add(-time.Hour), now.Add(3*24*time.Hour + 5*time.Minute - time.Second), true}, // just within refresh + skew
+		{now.Add(-time.Hour), now.Add(time.Hour), true},                                    // almost expired
+		{now.Add(-28 * 24 * time.Hour), now.Add(-time.Second), true},                       // expired
+		{now.Add(5 * time.Minute), now.Add(28 * 24 * time.Hour), false},                    // inception within skew
+		{now.Add(5*time.Minute + time.Second), now.Add(28 * 24 * time.Hour), true},         // inception too far in the future
+	}
+	for i, tc := range tests {
+		sig := &RRSIG{Inception: timeToUint32(tc.inception), Expiration: timeToUint32(tc.expiration)}
+		if r := NeedsResign(sig, now, config); r != tc.resign {
+			t.Errorf("test %d: expected NeedsResign to be %t, got %t", i, tc.resign, r)
+		}
+	}
+}
+
+func TestSignContext(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "a.miek.nl. A 127.0.0.1", "b.miek.nl. A 127.0.0.2", "c.miek.nl. A 127.0.0.3"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	zsk := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	p, _ := zsk.Generate(256)
+	keys := map[*DNSKEY]PrivateKey{zsk: p}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := z.SignContext(ctx, keys, nil); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	config := newSignatureConfig()
+	last, total := 0, 0
+	config.Progress = func(signed, n int) { last, total = signed, n }
+	if err := z.SignContext(context.Background(), keys, config); err != nil {
+		t.Fatalf("failed to sign zone: %s", err.Error())
+	}
+	if last != 4 || total != 4 {
+		t.Errorf("expected progress 4 of 4, got %d of %d", last, total)
+	}
+
+	// The apex in another case than the origin ends the walk too
+	z = NewZone("miek.nl.")
+	for _, s := range []string{"Miek.NL. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"Miek.NL. NS open.nlnetlabs.nl.", "a.miek.nl. A 127.0.0.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := z.SignContext(ctx, keys, nil); err != nil {
+		t.Errorf("failed to sign zone with a mixed case apex: %v", err)
+	}
+}
+
+func TestUpdateSignedNSEC3(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "a.miek.nl. A 127.0.0.1", "c.miek.nl. A 127.0.0.3"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	zsk := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	p, _ := zsk.Generate(256)
+	z.Insert(zsk)
+	keys := map[*DNSKEY]PrivateKey{zsk: p}
+	config := newSignatureConfig()
+	config.NSEC3 = &NSEC3PARAM{Hash: SHA1, Iterations: 0, Salt: "AABB"}
+	if err := z.Sign(keys, config); err != nil {
+		t.Fatalf("failed to sign zone: %s", err.Error())
+	}
+	// chain returns the hashed owner names of the NSEC3 records, after
+	// checking they are signed.
+	chain := func() map[string]bool {
+		hashes := make(map[string]bool)
+		z.Radix.NextDo(func(v interface{}) {
+			zd := v.(*ZoneData)
+			if _, ok := zd.RR[TypeNSEC]; ok {
+				t.Errorf("unexpected NSEC at %s", zd.Name)
+			}
+			for _, rr := range zd.RR[TypeNSEC3] {
+				hashes[strings.ToUpper(strings.SplitN(rr.Header().Name, ".", 2)[0])] = true
+				if len(zd.Signatures[TypeNSEC3]) != 1 || zd.Signatures[TypeNSEC3][0].Verify(zsk, zd.RR[TypeNSEC3]) != nil {
+					t.Errorf("NSEC3 at %s is not signed", zd.Name)
+				}
+			}
+		})
+		return hashes
+	}
+	b, _ := NewRR("b.miek.nl. A 127.0.0.2")
+	hash := HashName("b.miek.nl.", SHA1, 0, "AABB")
+	u := new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.Insert([]RR{b})
+	if err := z.Update(u, keys, config); err != nil {
+		t.Fatalf("failed to update zone: %s", err.Error())
+	}
+	if hashes := chain(); len(hashes) != 4 || !hashes[hash] {
+		t.Errorf("expected 4 NSEC3 records with one for b.miek.nl., got %v", hashes)
+	}
+
+	u = new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.RemoveName([]RR{b})
+	if err := z.Update(u, keys, config); err != nil {
+		t.Fatalf("failed to update zone: %s", err.Error())
+	}
+	if hashes := chain(); len(hashes) != 3 || hashes[hash] {
+		t.Errorf("expected 3 NSEC3 records without b.miek.nl., got %v", hashes)
+	}
+}
+
+func TestSignNSEC3(t *testing.T) {
+	for _, optOut := range []bool{true, false} {
+		z := NewZone("miek.nl.")
+		for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+			"miek.nl. NS open.nlnetlabs.nl.", "www.miek.nl. A 127.0.0.1",
+			"secure.miek.nl. NS ns.example.org.", "secure.miek.nl. DS 12345 8 2 8FE6E5C0D1EFB7F6D2A0D5E6E7B3F1A9A8B2C3D4E5F60718293A4B5C6D7E8F90",
+			"insecure.miek.nl. NS ns.insecure.miek.nl.", "ns.insecure.miek.nl. A 127.0.0.2",
+			"a.b.miek.nl. NS ns.example.org."} {
+			rr, err := NewRR(s)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %s", s, err.Error())
+			}
+			z.Insert(rr)
+		}
+		zsk := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+		p, _ := zsk.Generate(256)
+		config := newSignatureConfig()
+		config.NSEC3 = &NSEC3PARAM{Hash: SHA1, Iterations: 0, Salt: "AABB"}
+		config.OptOut = optOut
+		if err := z.Sign(map[*DNSKEY]PrivateKey{zsk: p}, config); err != nil {
+			t.Fatalf("failed to sign zone: %s", err.Error())
+		}
+		if len(z.Apex().RR[TypeNSEC3PARAM]) != 1 {
+			t.Error("expected an NSEC3PARAM at the apex")
+		}
+		chain := make(map[string]*NSEC3)
+		z.Radix.NextDo(func(v interface{}) {
+			zd := v.(*ZoneData)
+			if _, ok := zd.RR[TypeNSEC]; ok {
+				t.Errorf("unexpected NSEC at %s", zd.Name)
+			}
+			for _, rr := range zd.RR[TypeNSEC3] {
+				n := rr.(*NSEC3)
+				chain[n.NextDomain] = n
+				if (n.Flags == 1) != optOut {
+					t.Errorf("bad opt-out flag in %s", n)
+				}
+				if len(zd.Signatures[TypeNSEC3]) == 0 {
+					t.Errorf("NSEC3 at %s is not signed", zd.Name)
+				}
+			}
+		})
+		expected := 3 // the apex, www and secure
+		if !optOut {
+			expected = 6 // and insecure, a.b and the empty non-terminal b
+		}
+		if len(chain) != expected {
+			t.Errorf("expected %d NSEC3 records with opt-out %t, got %d", expected, optOut, len(chain))
+		}
+		for _, n := range chain {
+			if _, ok := chain[strings.ToUpper(strings.SplitN(n.Hdr.Name, ".", 2)[0])]; !ok {
+				t.Errorf("NSEC3 chain is not closed at %s", n.Hdr.Name)
+			}
+		}
+	}
+}
+
+func TestDelegations(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "www.miek.nl. A 127.0.0.1",
+		"secure.miek.nl. NS ns.example.org.", "secure.miek.nl. DS 12345 8 2 8FE6E5C0D1EFB7F6D2A0D5E6E7B3F1A9A8B2C3D4E5F60718293A4B5C6D7E8F90",
+		"insecure.miek.nl. NS ns.insecure.miek.nl.", "ns.insecure.miek.nl. A 127.0.0.2", "ns.insecure.miek.nl. AAAA ::1",
+		"deep.insecure.miek.nl. NS ns.example.org."} {
+		rr, err := NewRR(s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", s, err.Error())
+		}
+		z.Insert(rr)
+	}
+	d := z.Delegations()
+	if len(d) != 2 {
+		t.Fatalf("expected 2 delegations, got %d", len(d))
+	}
+	for _, d := range d {
+		switch d.Name {
+		case "secure.miek.nl.":
+			if !d.Secure() || len(d.NS) != 1 || len(d.Glue) != 0 {
+				t.Errorf("bad delegation %+v", d)
+			}
+		case "insecure.miek.nl.":
+			if d.Secure() || len(d.NS) != 1 || len(d.Glue) != 2 {
+				t.Errorf("bad delegation %+v", d)
+			}
+		default:
+			t.Errorf("unexpected delegation %s", d.Name)
+		}
+	}
+	for name, cut := range map[string]string{"www.miek.nl.": "", "miek.nl.": "", "example.org.": "",
+		"secure.miek.nl.": "secure.miek.nl.", "a.b.Deep.Insecure.miek.nl.": "insecure.miek.nl."} {
+		d := z.FindZoneCut(name)
+		if (d == nil && cut != "") || (d != nil && d.Name != cut) {
+			t.Errorf("bad zone cut for %s: %+v", name, d)
+		}
+	}
+}
+
+func TestGlue(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "www.miek.nl. A 127.0.0.1",
+		"insecure.miek.nl. NS ns.insecure.miek.nl.", "ns.insecure.miek.nl. A 127.0.0.2", "old.insecure.miek.nl. A 127.0.0.3",
+		"sibling.miek.nl. NS ns.insecure.miek.nl.", "broken.miek.nl. NS ns.broken.miek.nl."} {
+		rr, err := NewRR(s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", s, err.Error())
+		}
+		z.Insert(rr)
+	}
+	if !z.IsGlue("ns.insecure.miek.nl.") || z.IsGlue("insecure.miek.nl.") || z.IsGlue("www.miek.nl.") {
+		t.Error("bad glue detection")
+	}
+	if d := z.FindZoneCut("sibling.miek.nl."); d == nil || len(d.Glue) != 1 || len(d.Missing) != 0 {
+		t.Errorf("expected sibling glue, got %+v", d)
+	}
+	problems := z.Verify()
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	for _, p := range problems {
+		if s := p.Error(); s != "dns: broken.miek.nl.: missing glue for ns.broken.miek.nl." && s != "dns: old.insecure.miek.nl.: orphaned glue" {
+			t.Errorf("unexpected problem: %s", s)
+		}
+	}
+
+	zsk := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	p, _ := zsk.Generate(256)
+	if err := z.Sign(map[*DNSKEY]PrivateKey{zsk: p}, nil); err != nil {
+		t.Fatalf("failed to sign zone: %s", err.Error())
+	}
+	z.Radix.NextDo(func(v interface{}) {
+		zd := v.(*ZoneData)
+		_, nsec := zd.RR[TypeNSEC]
+		if z.glue(zd.Name) == (nsec || len(zd.Signatures) > 0) {
+			t.Errorf("bad NSEC or signatures at %s", zd.Name)
+		}
+		if nsec && z.glue(zd.RR[TypeNSEC][0].(*NSEC).NextDomain) {
+			t.Errorf("NSEC at %s points to glue", zd.Name)
+		}
+	})
+	d := z.FindZoneCut("insecure.miek.nl.")
+	if extra := z.Additional(d.NS, true); len(extra) != 1 || extra[0].Header().Rrtype != TypeA {
+		t.Errorf("expected unsigned glue, got %v", extra)
+	}
+}
+
+func TestZoneJSON(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "miek.nl. MX 10 mail.miek.nl.", "www.miek.nl. A 127.0.0.1",
+		"www.miek.nl. AAAA ::1", "www.miek.nl. TXT \"hello world\"", "miek.nl. CH TXT \"chaos\""} {
+		rr, err := NewRR(s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", s, err.Error())
+		}
+		z.Insert(rr)
+	}
+	zsk := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	p, _ := zsk.Generate(256)
+	z.Insert(zsk)
+	if err := z.Sign(map[*DNSKEY]PrivateKey{zsk: p}, nil); err != nil {
+		t.Fatalf("failed to sign zone: %s", err.Error())
+	}
+	b, err := json.Marshal(z)
+	if err != nil {
+		t.Fatalf("failed to export zone: %s", err.Error())
+	}
+	z1 := NewZone("miek.nl.")
+	if err := z1.ImportJSON(bytes.NewReader(b)); err != nil {
+		t.Fatalf("failed to import zone: %s", err.Error())
+	}
+	snapshot := func(z *Zone) []string {
+		rrs, _ := z.Snapshot()
+		s := make([]string, len(rrs))
+		for i, r := range rrs {
+			s[i] = r.String()
+		}
+		sort.Strings(s)
+		return s
+	}
+	if a, b := snapshot(z), snapshot(z1); strings.Join(a, "\n") != strings.Join(b, "\n") {
+		t.Errorf("zones differ after the round trip:\n%s\n\n%s", strings.Join(a, "\n"), strings.Join(b, "\n"))
+	}
+	www, _ := z.Find("www.miek.nl.")
+	if b, _ := json.Marshal(www); !strings.Contains(string(b), `"type":"A","ttl":3600,"rdata":{"A":"127.0.0.1"}`) {
+		t.Errorf("unexpected json for www.miek.nl.: %s", b)
+	}
+
+	for _, s := range []string{`{"origin": "example.org.", "rrs": []}`,
+		`{"rrs": [{"name": "a.miek.nl.", "type": "A", "ttl": 3600, "rdata": {"A": "127.0.0.1"}}, {"name": "b.miek.nl.", "type": "A", "ttl": 3600, "rdata": {"Mx": "127.0.0.1"}}]}`,
+		`{"rrs": [{"name": "a.miek.nl.", "type": "A", "ttl": 3600, "rdata": {"A": "127.0.0.1"}}, {"name": "b.example.org.", "type": "A", "ttl": 3600, "rdata": {"A": "127.0.0.1"}}]}`,
+		`{"rrs": [{"name": "a.miek.nl.", "type": "NOSUCH", "ttl": 3600, "rdata": {}}]}`} {
+		if err := z1.ImportJSON(strings.NewReader(s)); err == nil {
+			t.Errorf("expected an error importing %s", s)
+		}
+	}
+	if zd, _ := z1.Find("a.miek.nl."); zd != nil && zd.Name == "a.miek.nl." {
+		t.Error("records inserted from invalid json")
+	}
+}
+
+func TestZoneSet(t *testing.T) {
+	s := NewZoneSet()
+	for _, origin := range []string{"miek.nl.", "sub.miek.nl.", "example.org."} {
+		s.Add(NewZone(origin))
+	}
+	for name, origin := range map[string]string{"www.miek.nl.": "miek.nl.", "a.Sub.Miek.nl": "sub.miek.nl.",
+		"example.org.": "example.org.", "nl.": ""} {
+		z := s.Match(name)
+		if (z == nil && origin != "") || (z != nil && z.Origin != origin) {
+			t.Errorf("bad zone for %s: %v", name, z)
+		}
+	}
+	s.Remove("Sub.miek.nl")
+	if o := s.Origins(); len(o) != 2 || o[0] != "example.org." || s.Zone("SUB.miek.nl.") != nil {
+		t.Errorf("unexpected zones %v", o)
+	}
+}
+
+func TestZoneEvents(t *testing.T) {
+	z := NewZone("miek.nl.")
+	c := z.Subscribe()
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"miek.nl. NS open.nlnetlabs.nl.", "www.miek.nl. A 127.0.0.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	tmp, _ := NewRR("tmp.miek.nl. TXT \"expires\"")
+	z.InsertExpire(tmp, time.Now().Add(-time.Second))
+	z.Expire(time.Now())
+	z.RemoveRRset("www.miek.nl.", TypeA)
+	zsk := &DNSKEY{Hdr: RR_Header{"miek.nl.", TypeDNSKEY, ClassINET, 3600, 0}, Flags: 256, Protocol: 3, Algorithm: ECDSAP256SHA256}
+	p, _ := zsk.Generate(256)
+	z.Sign(map[*DNSKEY]PrivateKey{zsk: p}, nil)
+	z.Unsubscribe(c)
+
+	expected := []ZoneEventType{RRsetAdded, RRsetAdded, RRsetAdded, RRsetAdded, RRsetRemoved, SerialBumped, Expired, RRsetRemoved, Signed}
+	var got []ZoneEventType
+	for ev := range c {
+		got = append(got, ev.Type)
+		switch ev.Type {
+		case SerialBumped:
+			if ev.Serial != 2 {
+				t.Errorf("expected serial 2, got %d", ev.Serial)
+			}
+		case Expired:
+			if len(ev.RRs) != 1 || ev.RRs[0] != tmp {
+				t.Errorf("unexpected expired RRs %v", ev.RRs)
+			}
+		case RRsetRemoved:
+			if ev.Name != "tmp.miek.nl." && ev.Name != "www.miek.nl." {
+				t.Errorf("unexpected removal of %s", ev.Name)
+			}
+		}
+		if ev.Zone != z {
+			t.Error("event for another zone")
+		}
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("expected events %v, got %v", expected, got)
+		}
+	}
+}
+
+// xfrWriter records the sizes of the messages written.
+type xfrWriter struct {
+	testWriter
+	sizes []int
+	rrs   int
+}
+
+func (w *xfrWriter) WriteMsg(m *Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	w.sizes = append(w.sizes, len(buf))
+	w.rrs += len(m.Answer)
+	return nil
+}
+
+func TestTransferOutSize(t *testing.T) {
+	z := NewZone("miek.nl.")
+	soa, _ := NewRR("miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400")
+	z.Insert(soa)
+	txt := strings.Repeat("x", 250)
+	for i := 0; i < 200; i++ {
+		rr, _ := NewRR("t" + strconv.Itoa(i) + ".miek.nl. TXT " + strings.Repeat(`"`+txt+`" `, 8))
+		z.Insert(rr)
+	}
+	q := new(Msg)
+	q.SetAxfr("miek.nl.")
+	w := new(xfrWriter)
+	if err := z.TransferOut(w, q); err != nil {
+		t.Fatalf("transfer failed: %s", err.Error())
+	}
+	if w.rrs != 202 {
+		t.Errorf("expected 202 RRs, got %d", w.rrs)
+	}
+	if len(w.sizes) < 2 {
+		t.Errorf("expected the transfer to be split, got %d message(s)", len(w.sizes))
+	}
+	for _, l := range w.sizes {
+		if l > xfrMsgSize {
+			t.Errorf("message of %d bytes is larger than %d", l, xfrMsgSize)
+		}
+	}
+}
+
+func TestUpdateNoSOA(t *testing.T) {
+	z := NewZone("miek.nl.")
+	ns, _ := NewRR("miek.nl. NS open.nlnetlabs.nl.")
+	z.Insert(ns)
+	a, _ := NewRR("a.miek.nl. A 127.0.0.1")
+	u := new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.Insert([]RR{a})
+	if err := z.Update(u, nil, nil); err != ErrSoa {
+		t.Errorf("expected ErrSoa, got %v", err)
+	}
+	if _, exact := z.Find("a.miek.nl."); exact {
+		t.Error("a.miek.nl. was inserted in a zone without SOA")
+	}
+	if err := NewZone("miek.nl.").Update(u, nil, nil); err != ErrSoa {
+		t.Errorf("expected ErrSoa for an empty zone, got %v", err)
+	}
+}
+
+func TestUpdateNotZone(t *testing.T) {
+	z := NewZone("miek.nl.")
+	soa, _ := NewRR("miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400")
+	z.Insert(soa)
+	a, _ := NewRR("a.miek.nl. A 127.0.0.1")
+	evil, _ := NewRR("evil.example.org. A 127.0.0.2")
+	u := new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.Insert([]RR{a, evil})
+	err := z.Update(u, nil, nil)
+	if e, ok := err.(*Error); !ok || e.Rcode != RcodeNotZone {
+		t.Fatalf("expected a NOTZONE error, got %v", err)
+	}
+	if _, exact := z.Find("a.miek.nl."); exact {
+		t.Error("a.miek.nl. was inserted by a refused update")
+	}
+	if serial := z.Apex().RR[TypeSOA][0].(*SOA).Serial; serial != 1 {
+		t.Errorf("expected serial 1, got %d", serial)
+	}
+
+	// Changes later in the update see the earlier ones
+	u = new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.Insert([]RR{a})
+	if err := z.Update(u, nil, nil); err != nil {
+		t.Fatalf("failed to update zone: %s", err.Error())
+	}
+	del := &A{Hdr: RR_Header{Name: "a.miek.nl.", Rrtype: TypeA, Class: ClassANY}}
+	a1, _ := NewRR("a.miek.nl. A 127.0.0.1")
+	a2, _ := NewRR("a.miek.nl. A 127.0.0.1")
+	u = new(Msg)
+	u.SetUpdate("miek.nl.")
+	u.Ns = []RR{del, a1, a2}
+	if err := z.Update(u, nil, nil); err != nil {
+		t.Fatalf("failed to update zone: %s", err.Error())
+	}
+	if zd, exact := z.Find("a.miek.nl."); !exact || len(zd.RR[TypeA]) != 1 || zd.RR[TypeA][0] != a1 {
+		t.Errorf("expected a.miek.nl. to be replaced once, got %v", zd)
+	}
+}
+
+func TestUpdateLease(t *testing.T) {
+	z := NewZone("miek.nl.")
+	z.MinLease = 60
+	soa, _ := NewRR("miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400")
+	z.Insert(soa)
+	update := func(lease uint32, rr ...RR) {
+		u := new(Msg)
+		u.SetUpdate("miek.nl.")
+		u.Insert(rr)
+		u.SetEdns0(4096, false)
+		u.IsEdns0().SetOption(&EDNS0_UPDATE_LEASE{Code: EDNS0UPDATELEASE, Lease: lease})
+		if l := z.Lease(u); l < lease || l < z.MinLease {
+			t.Errorf("expected a lease of at least %d, got %d", lease, l)
+		}
+		if err := z.Update(u, nil, nil); err != nil {
+			t.Fatalf("failed to update zone: %s", err.Error())
+		}
+	}
+	a, _ := NewRR("a.miek.nl. A 127.0.0.1")
+	b, _ := NewRR("b.miek.nl. A 127.0.0.2")
+	update(10, a) // raised to MinLease
+	update(3600, b)
+	if rrs := z.Expire(time.Now().Add(30 * time.Second)); len(rrs) != 0 {
+		t.Errorf("expected no expired RRs, got %v", rrs)
+	}
+	if rrs := z.Expire(time.Now().Add(61 * time.Second)); len(rrs) != 1 || rrs[0] != a {
+		t.Errorf("expected a.miek.nl. to expire, got %v", rrs)
+	}
+	if _, exact := z.Find("a.miek.nl."); exact {
+		t.Error("a.miek.nl. still exists")
+	}
+	if serial := z.Apex().RR[TypeSOA][0].(*SOA).Serial; serial != 4 {
+		t.Errorf("expected serial 4, got %d", serial)
+	}
+
+	// Re-registering b refreshes its lease
+	b1, _ := NewRR("b.miek.nl. A 127.0.0.2")
+	update(7200, b1)
+	if rrs := z.Expire(time.Now().Add(3601 * time.Second)); len(rrs) != 0 {
+		t.Errorf("lease of b.miek.nl. not refreshed, expired %v", rrs)
+	}
+	if rrs := z.Expire(time.Now().Add(7201 * time.Second)); len(rrs) != 1 || rrs[0] != b {
+		t.Errorf("expected b.miek.nl. to expire, got %v", rrs)
+	}
+}
+
+func TestZoneJanitor(t *testing.T) {
+	z := NewZone("miek.nl.")
+	expired := make(chan RR, 1)
+	z.OnExpire = func(r RR) { expired <- r }
+	a, _ := NewRR("a.miek.nl. A 127.0.0.1")
+	b, _ := NewRR("b.miek.nl. A 127.0.0.2")
+	z.InsertExpire(a, time.Now().Add(50*time.Millisecond))
+	z.InsertExpire(b, time.Now().Add(50*time.Millisecond))
+	z.SetExpire(b, time.Time{})
+	stop := z.StartJanitor(10 * time.Millisecond)
+	defer func() { stop <- true }()
+	select {
+	case r := <-expired:
+		if r != a {
+			t.Errorf("expected a.miek.nl. to expire, got %s", r.String())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a.miek.nl. did not expire")
+	}
+	if _, exact := z.Find("a.miek.nl."); exact {
+		t.Error("a.miek.nl. still exists")
+	}
+	if _, exact := z.Find("b.miek.nl."); !exact {
+		t.Error("b.miek.nl. expired")
+	}
+}
+
+func TestZoneMeta(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"a.miek.nl. A 127.0.0.1", "a.miek.nl. A 127.0.0.2", "b.miek.nl. MX 10 a.miek.nl."} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	if err := z.SetMeta("a.miek.nl.", TypeA, &Meta{Tenant: "a", Comment: "web servers"}); err != nil {
+		t.Fatalf("failed to set metadata: %s", err.Error())
+	}
+	if err := z.SetMeta("a.miek.nl.", TypeAAAA, &Meta{Tenant: "a"}); err == nil {
+		t.Error("metadata set on a non-existing RRset")
+	}
+	if _, err := z.Snapshot(); err != nil {
+		t.Fatalf("failed to take snapshot: %s", err.Error())
+	}
+	var tenants []string
+	z.Walk(func(rrs []RR, sigs []*RRSIG, m *Meta) bool {
+		if m != nil {
+			tenants = append(tenants, rrs[0].Header().Name+"/"+m.Tenant)
+		}
+		return true
+	})
+	if len(tenants) != 1 || tenants[0] != "a.miek.nl./a" {
+		t.Errorf("unexpected metadata in walk: %v", tenants)
+	}
+
+	zd, _ := z.Find("a.miek.nl.")
+	z.Remove(zd.RR[TypeA][0])
+	if m := z.Meta("a.miek.nl.", TypeA); m == nil || m.Comment != "web servers" {
+		t.Error("metadata lost after removing an RR from the RRset")
+	}
+	z.RemoveRRset("a.miek.nl.", TypeA)
+	if m := z.Meta("a.miek.nl.", TypeA); m != nil {
+		t.Error("metadata kept after removing the RRset")
+	}
+	n := 0
+	z.Walk(func(rrs []RR, sigs []*RRSIG, m *Meta) bool { n++; return false })
+	if n != 1 {
+		t.Errorf("walk did not stop, called %d times", n)
+	}
+}
+
+func TestZoneAuthorize(t *testing.T) {
+	z := NewZone("customers.example.")
+	soa, _ := NewRR("customers.example. SOA ns.example. hostmaster.example. 1 14400 3600 604800 86400")
+	z.Insert(soa)
+	z.Authorize = AuthorizeSubdomains(map[string]string{"x-key.": "x.customers.example."})
+	a, _ := NewRR("www.x.customers.example. A 127.0.0.1")
+	b, _ := NewRR("www.y.customers.example. A 127.0.0.2")
+	if err := z.InsertAs("x-key.", a); err != nil {
+		t.Errorf("insert of own name refused: %s", err.Error())
+	}
+	if err := z.InsertAs("x-key.", b); err == nil {
+		t.Error("insert of other name allowed")
+	}
+	if err := z.InsertAs("y-key.", b); err == nil {
+		t.Error("insert by unknown tenant allowed")
+	}
+	if err := z.Insert(b); err != nil {
+		t.Errorf("insert by operator refused: %s", err.Error())
+	}
+
+	c, _ := NewRR("mail.x.customers.example. A 127.0.0.3")
+	u := new(Msg)
+	u.SetUpdate("customers.example.")
+	u.Insert([]RR{c})
+	u.RemoveRRset([]RR{b})
+	if err := z.UpdateAs("x-key.", u, nil, nil); err == nil {
+		t.Error("update touching other name allowed")
+	}
+	if _, exact := z.Find("mail.x.customers.example."); exact {
+		t.Error("refused update partially applied")
+	}
+	u = new(Msg)
+	u.SetUpdate("customers.example.")
+	u.Insert([]RR{c})
+	if err := z.Update(u, nil, nil); err == nil {
+		t.Error("unsigned update allowed")
+	}
+	if _, exact := z.Find("mail.x.customers.example."); exact {
+		t.Error("unsigned update applied")
+	}
+	u.SetTsig("x-key.", HmacMD5, 300, time.Now().Unix())
+	if err := z.Update(u, nil, nil); err != nil {
+		t.Errorf("update by TSIG key refused: %s", err.Error())
+	}
+	if err := z.RemoveAs("x-key.", b); err == nil {
+		t.Error("remove of other name allowed")
+	}
+}
+
+func TestAddAdditional(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. NS ns.miek.nl.", "miek.nl. NS ns.example.org.", "miek.nl. MX 10 ns.miek.nl.",
+		"ns.miek.nl. A 192.0.2.53", "ns.miek.nl. AAAA 2001:db8::53"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	zd, _ := z.Find("miek.nl.")
+	m := new(Msg)
+	m.SetQuestion("miek.nl.", TypeMX)
+	m.Answer = zd.RR[TypeMX]
+	m.Ns = zd.RR[TypeNS]
+	m.SetEdns0(4096, false)
+	z.AddAdditional(m)
+	if len(m.Extra) != 3 || m.Extra[0].Header().Rrtype != TypeA || m.Extra[1].Header().Rrtype != TypeAAAA {
+		t.Fatalf("expected A and AAAA before the OPT, got %v", m.Extra)
+	}
+	if m.Extra[2].Header().Rrtype != TypeOPT {
+		t.Error("OPT is not the last record")
+	}
+}
+
+func TestUpdateReverse(t *testing.T) {
+	z := NewZone("example.org.")
+	for _, s := range []string{"www.example.org. A 192.0.2.1", "web.example.org. A 192.0.2.1", "mail.example.org. A 192.0.2.2",
+		"mail.example.org. AAAA 2001:db8::25", "other.example.org. A 198.51.100.1"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	rev4, rev6 := NewZone("2.0.192.in-addr.arpa."), NewZone("8.b.d.0.1.0.0.2.ip6.arpa.")
+	old, _ := NewRR("2.2.0.192.in-addr.arpa. PTR old.example.org.")
+	rev4.Insert(old)
+
+	ptrs, err := UpdateReverse(z, []*Zone{rev4, rev6}, PTRKeep)
+	if err != nil {
+		t.Fatalf("failed to update reverse zones: %s", err.Error())
+	}
+	if len(ptrs) != 2 {
+		t.Errorf("expected 2 PTRs, got %v", ptrs)
+	}
+	if zd, _ := rev4.Find("2.2.0.192.in-addr.arpa."); len(zd.RR[TypePTR]) != 1 || zd.RR[TypePTR][0] != old {
+		t.Error("existing PTR not kept")
+	}
+	if zd, exact := rev6.Find("5.2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."); !exact || zd.RR[TypePTR][0].(*PTR).Ptr != "mail.example.org." {
+		t.Error("no PTR for the IPv6 address")
+	}
+
+	if _, err := UpdateReverse(z, []*Zone{rev4}, PTRReplace); err != nil {
+		t.Fatalf("failed to update reverse zones: %s", err.Error())
+	}
+	if zd, _ := rev4.Find("2.2.0.192.in-addr.arpa."); len(zd.RR[TypePTR]) != 1 || zd.RR[TypePTR][0].(*PTR).Ptr != "mail.example.org." {
+		t.Error("existing PTR not replaced")
+	}
+	if zd, _ := rev4.Find("1.2.0.192.in-addr.arpa."); len(zd.RR[TypePTR]) != 1 {
+		t.Errorf("expected one PTR for a shared address, got %d", len(zd.RR[TypePTR]))
+	}
+}
+
+func TestCompareZones(t *testing.T) {
+	server := func(rrs ...string) string {
+		z := NewZone("example.org.")
+		for _, s := range rrs {
+			rr, _ := NewRR(s)
+			z.Insert(rr)
+		}
+		l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to listen: %s", err.Error())
+		}
+		go (&Server{Handler: HandlerFunc(func(w ResponseWriter, req *Msg) { z.TransferOut(w, req) })}).serveTCP(l)
+		return l.Addr().String()
+	}
+	soa := "example.org. 3600 SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300"
+	a := server(soa, "example.org. 3600 NS ns.example.org.", "www.example.org. 300 A 192.0.2.1", "www.example.org. 300 A 192.0.2.2",
+		"ftp.example.org. 300 A 192.0.2.3", "mail.example.org. 300 MX 10 mx.example.org.")
+	b := server(soa, "example.org. 3600 NS NS.Example.ORG.", "WWW.example.org. 300 A 192.0.2.2", "www.example.org. 300 A 192.0.2.1",
+		"mail.example.org. 600 MX 10 mx.example.org.", "txt.example.org. 300 TXT \"new\"")
+
+	d, err := CompareZones("example.org", a, b)
+	if err != nil {
+		t.Fatalf("failed to compare zones: %s", err.Error())
+	}
+	if d.SerialA != 1 || d.SerialB != 1 || d.Equal() {
+		t.Fatalf("unexpected result: %+v", d)
+	}
+	if len(d.Missing) != 1 || d.Missing[0][0].Header().Name != "ftp.example.org." {
+		t.Errorf("expected ftp.example.org. to be missing, got %v", d.Missing)
+	}
+	if len(d.Extra) != 1 || d.Extra[0][0].Header().Rrtype != TypeTXT {
+		t.Errorf("expected an extra TXT record, got %v", d.Extra)
+	}
+	if len(d.Mismatched) != 1 || d.Mismatched[0].B[0].Header().Ttl != 600 {
+		t.Errorf("expected the MX TTL to mismatch, got %v", d.Mismatched)
+	}
+	if d, _ := CompareZones("example.org.", a, a); !d.Equal() {
+		t.Errorf("zone differs from itself: %+v", d)
+	}
+}
+
+func TestCanonicalRRsetsUnpackable(t *testing.T) {
+	// RRs that can not be packed are compared in presentation format
+	mx1 := &MX{Hdr: RR_Header{Name: "example.org.", Rrtype: TypeMX, Class: ClassINET, Ttl: 300}, Preference: 10, Mx: strings.Repeat("x", 64) + ".example.org."}
+	mx2 := &MX{Hdr: RR_Header{Name: "example.org.", Rrtype: TypeMX, Class: ClassINET, Ttl: 300}, Preference: 20, Mx: strings.Repeat("x", 64) + ".example.org."}
+	if _, err := PackRR(mx1, make([]byte, 512), 0, nil, false); err == nil {
+		t.Fatal("expected the MX record not to pack")
+	}
+	a := canonicalRRsets([]RR{mx1})
+	b := canonicalRRsets([]RR{mx1, mx2})
+	k := rrsetKey{"example.org.", ClassINET, TypeMX}
+	if a[k] == nil || b[k] == nil || len(b[k].rdata) != 2 {
+		t.Fatalf("unpackable RRs dropped: %v, %v", a, b)
+	}
+	if a[k].equal(b[k]) {
+		t.Error("RRsets with different unpackable RRs are equal")
+	}
+}
+
+func TestZoneCase(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"MIEK.NL. NS open.nlnetlabs.nl.", "WWW.Miek.NL. A 127.0.0.1", "www.miek.nl. TXT \"www\""} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	if z.Apex().NonAuth {
+		t.Error("apex NS with another case is not authoritative")
+	}
+	zd, _ := z.Find("www.miek.nl.")
+	if !strings.Contains(zd.String(), "WWW.Miek.NL.\t") || !strings.Contains(zd.String(), "www.miek.nl.\t") {
+		t.Errorf("case not preserved:\n%s", zd.String())
+	}
+
+	z.SetCase(CaseLower)
+	ftp, _ := NewRR("FTP.Miek.NL. A 127.0.0.2")
+	z.Insert(ftp)
+	if ftp.Header().Name != "ftp.miek.nl." {
+		t.Errorf("inserted name not lowercased: %s", ftp.Header().Name)
+	}
+	if zd.Name != "www.miek.nl." || zd.RR[TypeA][0].Header().Name != "www.miek.nl." {
+		t.Errorf("existing names not lowercased:\n%s", zd.String())
+	}
+	if !strings.Contains(z.Apex().String(), "miek.nl.\t") || strings.Contains(z.Apex().String(), "MIEK") {
+		t.Errorf("apex not lowercased:\n%s", z.Apex().String())
+	}
+}
+
+func TestInsertRRs(t *testing.T) {
+	z := NewZone("miek.nl.")
+	soa, _ := NewRR("miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400")
+	rrs := []RR{soa}
+	for i := 0; i < 1000; i++ {
+		rr, _ := NewRR("h" + strconv.Itoa(i) + ".miek.nl. A 127.0.0.1")
+		rrs = append(rrs, rr)
+	}
+	if err := z.InsertRRs(rrs); err != nil {
+		t.Fatalf("failed to insert: %s", err.Error())
+	}
+	if _, exact := z.Find("h999.miek.nl."); !exact {
+		t.Error("h999.miek.nl. not inserted")
+	}
+
+	a, _ := NewRR("a.miek.nl. A 127.0.0.1")
+	out, _ := NewRR("www.example.org. A 127.0.0.1")
+	if err := z.InsertRRs([]RR{a, out}); err == nil {
+		t.Error("expected an error for out of zone data")
+	}
+	if _, exact := z.Find("a.miek.nl."); exact {
+		t.Error("a.miek.nl. inserted from a failed batch")
+	}
+
+	txn := z.Begin()
+	txn.Remove(rrs[1])
+	txn.Insert(a)
+	txn.Rollback()
+	if _, exact := z.Find("h0.miek.nl."); !exact {
+		t.Error("h0.miek.nl. removed by a rolled back transaction")
+	}
+	txn = z.Begin()
+	txn.Remove(rrs[1])
+	txn.Insert(a)
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("failed to commit: %s", err.Error())
+	}
+	if _, exact := z.Find("h0.miek.nl."); exact {
+		t.Error("h0.miek.nl. not removed")
+	}
+	if _, exact := z.Find("a.miek.nl."); !exact {
+		t.Error("a.miek.nl. not inserted")
+	}
+	if err := txn.Commit(); err != ErrTxnDone {
+		t.Errorf("expected ErrTxnDone, got %v", err)
+	}
+}
+
+func TestHotNames(t *testing.T) {
+	z := NewZone("miek.nl.")
+	for _, s := range []string{"miek.nl. SOA open.nlnetlabs.nl. miekg.atoom.net. 1 14400 3600 604800 86400",
+		"www.miek.nl. A 127.0.0.1", "mx.miek.nl. A 127.0.0.2"} {
+		rr, _ := NewRR(s)
+		z.Insert(rr)
+	}
+	z.Find("www.miek.nl.")
+	if len(z.HotNames(0)) != 0 {
+		t.Fatal("lookups counted without HitSampling")
+	}
+	z.HitSampling = 1
+	for i := 0; i < 3; i++ {
+		z.Find("www.miek.nl.")
+	}
+	z.Find("nx1.miek.nl.")
+	z.Find("nx2.miek.nl.")
+	z.Find("mx.miek.nl.")
+	z.SetMeta("mx.miek.nl.", TypeA, &Meta{Source: "test"})
+	hot := z.HotNames(2)
+	if len(hot) != 2 || hot[0] != (HotName{"www.miek.nl.", 3, 0}) || hot[1] != (HotName{"miek.nl.", 0, 2}) {
+		t.Errorf("unexpected hot names %v", hot)
+	}
+	if hot := z.HotNames(0); len(hot) != 3 || hot[2] != (HotName{"mx.miek.nl.", 1, 0}) {
+		t.Errorf("unexpected hot names %v", hot)
+	}
+
+	z.ResetHits()
+	z.HitSampling = 4
+	for i := 0; i < 4000; i++ {
+		z.Find("www.miek.nl.")
+	}
+	if hot := z.HotNames(1); len(hot) != 1 || hot[0].Hits%4 != 0 || hot[0].Hits < 3000 || hot[0].Hits > 5000 {
+		t.Errorf("expected about 4000 sampled hits, got %v", hot)
+	}
+}
@@ -0,0 +1,68 @@
+package dns
+
+// Authorizing changes to a zone.
+
+import (
+	"strings"
+)
+
+// Operations passed to an AuthorizeFunc.
+const (
+	AuthInsert = iota // RRs are added
+	AuthRemove        // RRs are removed, for a whole name the type is TypeANY
+)
+
+// AuthorizeFunc is called before tenant changes the RRset of name and type t
+// in a zone with operation op, the change is refused when it returns false.
+// Insert, Remove, RemoveName and RemoveRRset make the changes as the empty
+// tenant, which is normally the operator of the platform; InsertAs and
+// RemoveAs name the tenant, Update uses the name of the TSIG key and refuses
+// updates without one. Changes made by the zone itself, such as expiring RRs,
+// are not authorized.
+type AuthorizeFunc func(tenant string, op int, name string, t uint16) bool
+
+// AuthorizeSubdomains returns an AuthorizeFunc that allows each tenant to
+// change the names at and below its domain in domains only. The empty tenant
+// may change all names. Basic use pattern:
+//
+//	z.Authorize = dns.AuthorizeSubdomains(map[string]string{
+//		"x-key.": "x.customers.example.",
+//		"y-key.": "y.customers.example.",
+//	})
+func AuthorizeSubdomains(domains map[string]string) AuthorizeFunc {
+	d := make(map[string]string, len(domains))
+	for tenant, domain := range domains {
+		d[strings.ToLower(tenant)] = Fqdn(strings.ToLower(domain))
+	}
+	return func(tenant string, op int, name string, t uint16) bool {
+		if tenant == "" {
+			return true
+		}
+		domain, ok := d[strings.ToLower(tenant)]
+		return ok && IsSubDomain(domain, Fqdn(name))
+	}
+}
+
+// authorize returns an error when tenant may not change the RRset.
+func (z *Zone) authorize(tenant string, op int, name string, t uint16) error {
+	if z.Authorize == nil || z.Authorize(tenant, op, name, t) {
+		return nil
+	}
+	return &Error{Err: "not authorized", Name: name}
+}
+
+// InsertAs works like Insert, but the RR is inserted by tenant.
+func (z *Zone) InsertAs(tenant string, r RR) error {
+	if err := z.authorize(tenant, AuthInsert, r.Header().Name, r.Header().Rrtype); err != nil {
+		return err
+	}
+	return z.insert(r)
+}
+
+// RemoveAs works like Remove, but the RR is removed by tenant.
+func (z *Zone) RemoveAs(tenant string, r RR) error {
+	if err := z.authorize(tenant, AuthRemove, r.Header().Name, r.Header().Rrtype); err != nil {
+		return err
+	}
+	return z.remove(r)
+}
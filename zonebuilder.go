@@ -0,0 +1,294 @@
+package dns
+
+// Building zones from code.
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// ZoneBuilder creates a Zone from code, for instance from an inventory
+// of hosts. Owner names and targets are relative to the origin, unless
+// they are fully qualified, "@" is the origin itself. Basic use pattern:
+//
+//	b := dns.NewZoneBuilder("example.org.")
+//	b.SOA("ns1", "hostmaster", 2013061801).NS("@", "ns1").A("ns1", "192.0.2.1")
+//	b.Ttl(300).A("www", "192.0.2.10").MX("@", 10, "mail").SRV("_sip._udp", 0, 5, 5060, "sip")
+//	z, err := b.Zone()
+//
+// The first error stops the building, it is returned by Zone and Err.
+type ZoneBuilder struct {
+	Origin string // Origin of the zone
+	ttl    uint32
+	rrs    []RR
+	soa    bool
+	err    error
+}
+
+// The TTL used by a ZoneBuilder when Ttl isn't called.
+const defaultBuilderTtl = 3600
+
+// NewZoneBuilder returns a ZoneBuilder for the zone origin. The TTL of
+// the records defaults to 3600.
+func NewZoneBuilder(origin string) *ZoneBuilder {
+	b := &ZoneBuilder{Origin: Fqdn(strings.ToLower(origin)), ttl: defaultBuilderTtl}
+	if _, _, ok := IsDomainName(b.Origin); !ok {
+		b.err = &Error{Err: "bad origin", Name: origin}
+	}
+	return b
+}
+
+// name returns the fully qualified name for s.
+func (b *ZoneBuilder) name(s string) string {
+	switch {
+	case s == "@" || s == "":
+		return b.Origin
+	case IsFqdn(s):
+		return s
+	case b.Origin == ".":
+		return s + "."
+	}
+	return s + "." + b.Origin
+}
+
+// hdr returns the header for a record with owner name s, it sets the
+// error when s is not a valid name.
+func (b *ZoneBuilder) hdr(s string, t uint16) RR_Header {
+	n := b.name(s)
+	if _, _, ok := IsDomainName(n); !ok && b.err == nil {
+		b.err = &Error{Err: "bad name", Name: s}
+	}
+	return RR_Header{Name: n, Rrtype: t, Class: ClassINET, Ttl: b.ttl}
+}
+
+// Ttl sets the TTL of the records that are added after it.
+func (b *ZoneBuilder) Ttl(ttl uint32) *ZoneBuilder {
+	b.ttl = ttl
+	return b
+}
+
+// RR adds rr to the zone as is.
+func (b *ZoneBuilder) RR(rr RR) *ZoneBuilder {
+	if b.err != nil {
+		return b
+	}
+	if !IsSubDomain(b.Origin, rr.Header().Name) {
+		b.err = &Error{Err: "out of zone data", Name: rr.Header().Name}
+		return b
+	}
+	if rr.Header().Rrtype == TypeSOA {
+		if b.soa {
+			b.err = &Error{Err: "more than one SOA", Name: b.Origin}
+			return b
+		}
+		b.soa = true
+	}
+	b.rrs = append(b.rrs, rr)
+	return b
+}
+
+// SOA adds the SOA record for the zone, with refresh, retry, expire and
+// minimum TTL set to 14400, 3600, 604800 and 3600.
+func (b *ZoneBuilder) SOA(ns, mbox string, serial uint32) *ZoneBuilder {
+	return b.RR(&SOA{b.hdr("@", TypeSOA), b.name(ns), b.name(mbox), serial, 14400, 3600, 604800, 3600})
+}
+
+// NS adds an NS record.
+func (b *ZoneBuilder) NS(name, ns string) *ZoneBuilder {
+	return b.RR(&NS{b.hdr(name, TypeNS), b.name(ns)})
+}
+
+// A adds an A record, ip must be an IPv4 address.
+func (b *ZoneBuilder) A(name, ip string) *ZoneBuilder {
+	a := net.ParseIP(ip).To4()
+	if a == nil {
+		if b.err == nil {
+			b.err = &Error{Err: "bad A address: " + ip, Name: name}
+		}
+		return b
+	}
+	return b.RR(&A{b.hdr(name, TypeA), a})
+}
+
+// AAAA adds an AAAA record, ip must be an IPv6 address.
+func (b *ZoneBuilder) AAAA(name, ip string) *ZoneBuilder {
+	a := net.ParseIP(ip)
+	if a == nil || a.To4() != nil {
+		if b.err == nil {
+			b.err = &Error{Err: "bad AAAA address: " + ip, Name: name}
+		}
+		return b
+	}
+	return b.RR(&AAAA{b.hdr(name, TypeAAAA), a})
+}
+
+// CNAME adds a CNAME record.
+func (b *ZoneBuilder) CNAME(name, target string) *ZoneBuilder {
+	return b.RR(&CNAME{b.hdr(name, TypeCNAME), b.name(target)})
+}
+
+// MX adds an MX record.
+func (b *ZoneBuilder) MX(name string, pref uint16, mx string) *ZoneBuilder {
+	return b.RR(&MX{b.hdr(name, TypeMX), pref, b.name(mx)})
+}
+
+// TXT adds a TXT record, with each string in txt as a character-string.
+func (b *ZoneBuilder) TXT(name string, txt ...string) *ZoneBuilder {
+	return b.RR(&TXT{b.hdr(name, TypeTXT), txt})
+}
+
+// SRV adds an SRV record.
+func (b *ZoneBuilder) SRV(name string, priority, weight, port uint16, target string) *ZoneBuilder {
+	return b.RR(&SRV{b.hdr(name, TypeSRV), priority, weight, port, b.name(target)})
+}
+
+// PTR adds a PTR record.
+func (b *ZoneBuilder) PTR(name, ptr string) *ZoneBuilder {
+	return b.RR(&PTR{b.hdr(name, TypePTR), b.name(ptr)})
+}
+
+// Err returns the first error that occurred while building.
+func (b *ZoneBuilder) Err() error {
+	return b.err
+}
+
+// Zone checks the records and returns the zone. The zone must
+// have a SOA and at least one NS record at the apex, and a name with a CNAME
+// can not have other data.
+func (b *ZoneBuilder) Zone() (*Zone, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if !b.soa {
+		return nil, ErrSoa
+	}
+	types := make(map[string][]uint16)
+	for _, rr := range b.rrs {
+		n := strings.ToLower(rr.Header().Name)
+		types[n] = append(types[n], rr.Header().Rrtype)
+	}
+	for n, ts := range types {
+		for _, t := range ts {
+			if t == TypeCNAME && len(ts) > 1 {
+				return nil, &Error{Err: "CNAME and other data", Name: n}
+			}
+		}
+	}
+	ns := false
+	for _, t := range types[b.Origin] {
+		ns = ns || t == TypeNS
+	}
+	if !ns {
+		return nil, &Error{Err: "no NS records at the apex", Name: b.Origin}
+	}
+	z := NewZone(b.Origin)
+	for _, rr := range b.rrs {
+		if err := z.Insert(rr); err != nil {
+			return nil, err
+		}
+	}
+	return z, nil
+}
+
+// SerialFromTime returns the SOA serial in the usual date format
+// YYYYMMDDnn for t (in UTC), with nn set to 00.
+func SerialFromTime(t time.Time) uint32 {
+	t = t.UTC()
+	return uint32(t.Year())*1000000 + uint32(t.Month())*10000 + uint32(t.Day())*100
+}
+
+// EnsureApex makes the zone servable by adding the SOA and NS records at the
+// apex, when they are missing. The zero fields of soa, which may be nil, get
+// defaults: the first name server of ns, the mailbox hostmaster in the zone,
+// a serial from SerialFromTime, the timers of ZoneBuilder.SOA (which makes
+// the negative TTL 3600) and a TTL of 3600. The names in ns are relative to
+// the origin, unless they are fully qualified; they are only used when the
+// apex has no NS records, one is required in that case. The zone can be
+// signed right away:
+//
+//	z := dns.NewZone("example.org.")
+//	err := z.EnsureApex(nil, []string{"ns1", "ns2.example.net."})
+func (z *Zone) EnsureApex(soa *SOA, ns []string) error {
+	name := func(s string) string {
+		switch {
+		case s == "@":
+			return z.Origin
+		case IsFqdn(s):
+			return s
+		case z.Origin == ".":
+			return s + "."
+		}
+		return s + "." + z.Origin
+	}
+	types := make(map[uint16]bool)
+	for _, t := range z.types(z.Origin) {
+		types[t] = true
+	}
+	if !types[TypeNS] && len(ns) == 0 {
+		return &Error{Err: "no NS records at the apex", Name: z.Origin}
+	}
+	if types[TypeSOA] {
+		soa = nil
+	} else {
+		if soa == nil {
+			soa = new(SOA)
+		}
+		soa = soa.Copy().(*SOA)
+		soa.Hdr.Name, soa.Hdr.Rrtype, soa.Hdr.Class = z.Origin, TypeSOA, ClassINET
+		if soa.Hdr.Ttl == 0 {
+			soa.Hdr.Ttl = defaultBuilderTtl
+		}
+		if soa.Ns == "" {
+			if len(ns) > 0 {
+				soa.Ns = name(ns[0])
+			} else {
+				zd, _ := z.lookup(z.Origin)
+				zd.RLock()
+				soa.Ns = zd.RR[TypeNS][0].(*NS).Ns
+				zd.RUnlock()
+			}
+		}
+		if soa.Mbox == "" {
+			soa.Mbox = name("hostmaster")
+		}
+		if soa.Serial == 0 {
+			soa.Serial = SerialFromTime(time.Now())
+		}
+		if soa.Refresh == 0 {
+			soa.Refresh = 14400
+		}
+		if soa.Retry == 0 {
+			soa.Retry = 3600
+		}
+		if soa.Expire == 0 {
+			soa.Expire = 604800
+		}
+		if soa.Minttl == 0 {
+			soa.Minttl = 3600
+		}
+	}
+	var rrs []RR
+	if soa != nil {
+		rrs = append(rrs, soa)
+	}
+	if !types[TypeNS] {
+		ttl := uint32(defaultBuilderTtl)
+		if soa != nil {
+			ttl = soa.Hdr.Ttl
+		}
+		for _, n := range ns {
+			n = name(n)
+			if _, _, ok := IsDomainName(n); !ok {
+				return &Error{Err: "bad name", Name: n}
+			}
+			rrs = append(rrs, &NS{RR_Header{z.Origin, TypeNS, ClassINET, ttl, 0}, n})
+		}
+	}
+	for _, r := range rrs {
+		if err := z.Insert(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package dns
+
+// The case of the owner names in a zone.
+
+import (
+	"strings"
+)
+
+// CasePolicy is the way a zone stores the case of owner names. Lookups in
+// a zone are always case insensitive, the policy determines the names in
+// the records that are returned, printed, transferred and signed.
+type CasePolicy int
+
+const (
+	CasePreserve CasePolicy = iota // Every RR keeps the owner name it is inserted with, the default
+	CaseLower                      // Owner names are lowercased, as in the canonical form of RFC 4034
+)
+
+// SetCase sets the case policy of z. With CaseLower the owner names of the
+// RRs and signatures already in z are lowercased too, so the zone is in one
+// case. The signatures stay valid, the owner name is lowercased in the
+// signed data anyway; sign the zone again to get NSEC records that point to
+// the lowercased names.
+func (z *Zone) SetCase(p CasePolicy) {
+	z.Lock()
+	defer z.Unlock()
+	z.Case = p
+	if p != CaseLower {
+		return
+	}
+	z.Radix.NextDo(func(v interface{}) {
+		zd := v.(*ZoneData)
+		zd.Lock()
+		defer zd.Unlock()
+		zd.Name = strings.ToLower(zd.Name)
+		for _, rrs := range zd.RR {
+			for _, r := range rrs {
+				lowerOwner(r)
+			}
+		}
+		for _, sigs := range zd.Signatures {
+			for _, r := range sigs {
+				lowerOwner(r)
+			}
+		}
+	})
+}
+
+// lowerOwner lowercases the owner name of r.
+func lowerOwner(r RR) {
+	r.Header().Name = strings.ToLower(r.Header().Name)
+}
@@ -0,0 +1,165 @@
+package dns
+
+// Zone cuts (delegations) in a zone.
+
+import (
+	"strings"
+)
+
+// A Delegation is a zone cut: the NS RRset of the child zone, the glue for
+// its name servers and the DS RRset. The records are copies.
+type Delegation struct {
+	Name    string // The owner name of the NS RRset
+	NS      []RR
+	Glue    []RR     // The A and AAAA records of the name servers below a cut in the zone
+	Missing []string // The name servers below the cut that have no glue
+	DS      []RR     // Nil for an unsigned (insecure) delegation
+}
+
+// Secure returns true when the delegation has a DS RRset.
+func (d *Delegation) Secure() bool {
+	return len(d.DS) > 0
+}
+
+// Delegations returns the delegations in z, in the order of the zone. Cuts
+// below another cut are occluded and are not returned.
+func (z *Zone) Delegations() []Delegation {
+	z.RLock()
+	defer z.RUnlock()
+	var cuts []*ZoneData
+	z.Radix.NextDo(func(v interface{}) {
+		if zd := v.(*ZoneData); zd.NonAuth {
+			cuts = append(cuts, zd)
+		}
+	})
+	var d []Delegation
+	for _, zd := range cuts {
+		if z.zoneCut(zd.Name) == zd {
+			d = append(d, *z.delegation(zd))
+		}
+	}
+	return d
+}
+
+// FindZoneCut returns the delegation that covers qname: the highest cut at
+// or above qname. It returns nil when qname is not delegated, or not in z.
+func (z *Zone) FindZoneCut(qname string) *Delegation {
+	if !z.isSubDomain(qname) {
+		return nil
+	}
+	z.RLock()
+	defer z.RUnlock()
+	zd := z.zoneCut(qname)
+	if zd == nil {
+		return nil
+	}
+	return z.delegation(zd)
+}
+
+// zoneCut returns the node of the highest cut at or above name, or nil. The
+// caller must hold z's read lock.
+func (z *Zone) zoneCut(name string) (cut *ZoneData) {
+	name = Fqdn(name)
+	off := 0
+	for i := LenLabels(name) - len(z.olabels); i > 0; i-- {
+		if n, exact := z.Radix.Find(toRadixName(name[off:])); exact {
+			if zd := n.Value.(*ZoneData); zd.NonAuth {
+				cut = zd
+			}
+		}
+		off, _ = NextLabelOffset(name, off)
+	}
+	return cut
+}
+
+// delegation returns the delegation at the cut zd. The caller must hold z's
+// read lock.
+func (z *Zone) delegation(zd *ZoneData) *Delegation {
+	zd.RLock()
+	d := &Delegation{Name: zd.Name}
+	for _, r := range zd.RR[TypeNS] {
+		d.NS = append(d.NS, r.Copy())
+	}
+	for _, r := range zd.RR[TypeDS] {
+		d.DS = append(d.DS, r.Copy())
+	}
+	zd.RUnlock()
+	for _, r := range d.NS {
+		target := r.(*NS).Ns
+		// The name servers of sibling delegations have glue too
+		if !z.isSubDomain(target) || !z.glue(target) {
+			continue
+		}
+		n := len(d.Glue)
+		if node, exact := z.Radix.Find(toRadixName(target)); exact {
+			g := node.Value.(*ZoneData)
+			g.RLock()
+			for _, t := range []uint16{TypeA, TypeAAAA} {
+				for _, a := range g.RR[t] {
+					d.Glue = append(d.Glue, a.Copy())
+				}
+			}
+			g.RUnlock()
+		}
+		if n == len(d.Glue) && IsSubDomain(d.Name, target) {
+			d.Missing = append(d.Missing, target)
+		}
+	}
+	return d
+}
+
+// IsGlue returns true when name is below a zone cut in z. The records of
+// such a name are glue (or occluded data): they are not authoritative, not
+// signed and not in the NSEC or NSEC3 chain.
+func (z *Zone) IsGlue(name string) bool {
+	if !z.isSubDomain(name) {
+		return false
+	}
+	z.RLock()
+	defer z.RUnlock()
+	return z.glue(name)
+}
+
+// glue is IsGlue for a name in z. The caller must hold z's read lock.
+func (z *Zone) glue(name string) bool {
+	cut := z.zoneCut(name)
+	return cut != nil && !strings.EqualFold(cut.Name, name)
+}
+
+// Verify checks the delegations in z and returns the problems found, or nil
+// when there are none. It reports name servers below a cut without glue,
+// and glue that no NS record in the zone points to (orphaned glue).
+func (z *Zone) Verify() []error {
+	var problems []error
+	targets := make(map[string]bool)
+	for _, d := range z.Delegations() {
+		for _, r := range d.NS {
+			targets[strings.ToLower(r.(*NS).Ns)] = true
+		}
+		for _, m := range d.Missing {
+			problems = append(problems, &Error{Err: "missing glue for " + m, Name: d.Name})
+		}
+	}
+	if apex, exact := z.lookup(z.Origin); exact {
+		apex.RLock()
+		for _, r := range apex.RR[TypeNS] {
+			targets[strings.ToLower(r.(*NS).Ns)] = true
+		}
+		apex.RUnlock()
+	}
+	z.RLock()
+	defer z.RUnlock()
+	z.Radix.NextDo(func(v interface{}) {
+		zd := v.(*ZoneData)
+		if targets[strings.ToLower(zd.Name)] || !z.glue(zd.Name) {
+			return
+		}
+		zd.RLock()
+		orphan := len(zd.RR[TypeA]) > 0 || len(zd.RR[TypeAAAA]) > 0
+		zd.RUnlock()
+		if orphan {
+			problems = append(problems, &Error{Err: "orphaned glue", Name: zd.Name})
+		}
+	})
+	return problems
+}
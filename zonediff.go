@@ -0,0 +1,183 @@
+package dns
+
+// Comparing zones on two servers.
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// ZoneDiffResult holds the differences between the copies of a zone on
+// two servers, as returned by CompareZones. The RRsets are in canonical
+// order.
+type ZoneDiffResult struct {
+	Origin     string
+	SerialA    uint32          // The serial of the zone on server A
+	SerialB    uint32          // The serial of the zone on server B
+	Missing    [][]RR          // RRsets only found on server A
+	Extra      [][]RR          // RRsets only found on server B
+	Mismatched []RRsetMismatch // RRsets found on both servers, with different data or TTL
+}
+
+// RRsetMismatch is an RRset that differs between two servers.
+type RRsetMismatch struct {
+	A []RR // The RRset on server A
+	B []RR // The RRset on server B
+}
+
+// Equal returns true when no differences were found.
+func (d *ZoneDiffResult) Equal() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Mismatched) == 0
+}
+
+// CompareZones transfers the zone origin from serverA and serverB using AXFR
+// and compares the RRsets, for instance to validate a migration. Names and
+// the domain names in the rdata are compared case insensitive, as in the
+// canonical RR form (RFC 4034, section 6.2), and the order of the RRs
+// within an RRset doesn't matter. Two RRsets also mismatch when their
+// TTLs differ. Note that the SOA record is compared as well, so different
+// serials show up as a mismatched SOA RRset. Signatures are compared as
+// RRsets of type RRSIG, per owner name.
+//
+// If given, tsig holds the name of the TSIG key, the base64 secret and
+// optionally the algorithm, these are used for both transfers.
+func CompareZones(origin, serverA, serverB string, tsig ...string) (diff ZoneDiffResult, err error) {
+	origin = Fqdn(origin)
+	diff.Origin = origin
+	ma, mb := &Master{Addr: serverA}, &Master{Addr: serverB}
+	if len(tsig) > 0 {
+		if len(tsig) < 2 {
+			return diff, ErrSecret
+		}
+		ma.TsigName, ma.TsigSecret = Fqdn(tsig[0]), tsig[1]
+		if len(tsig) > 2 {
+			ma.TsigAlgo = tsig[2]
+		}
+		*mb = *ma
+		mb.Addr = serverB
+	}
+	a, serial, err := ma.transferRRs(origin, nil)
+	if err != nil {
+		return diff, err
+	}
+	diff.SerialA = serial
+	b, serial, err := mb.transferRRs(origin, nil)
+	if err != nil {
+		return diff, err
+	}
+	diff.SerialB = serial
+
+	setsA, setsB := canonicalRRsets(a), canonicalRRsets(b)
+	for _, s := range setsA {
+		t := setsB[s.key]
+		if t == nil {
+			diff.Missing = append(diff.Missing, s.rrs)
+			continue
+		}
+		if !s.equal(t) {
+			diff.Mismatched = append(diff.Mismatched, RRsetMismatch{s.rrs, t.rrs})
+		}
+	}
+	for _, t := range setsB {
+		if setsA[t.key] == nil {
+			diff.Extra = append(diff.Extra, t.rrs)
+		}
+	}
+	sort.Sort(rrsets(diff.Missing))
+	sort.Sort(rrsets(diff.Extra))
+	sort.Sort(mismatches(diff.Mismatched))
+	return diff, nil
+}
+
+type rrsetKey struct {
+	name  string // lower cased
+	class uint16
+	t     uint16
+}
+
+// canonicalRRset is an RRset with the rdata of each RR in canonical
+// form, sorted.
+type canonicalRRset struct {
+	key   rrsetKey
+	rrs   []RR
+	rdata [][]byte
+	ttl   uint32
+	ttls  bool // true when the TTLs in the RRset differ
+}
+
+// canonicalRRsets groups rrs into RRsets. Duplicate RRs are removed.
+func canonicalRRsets(rrs []RR) map[rrsetKey]*canonicalRRset {
+	sets := make(map[rrsetKey]*canonicalRRset)
+	for _, r := range rrs {
+		h := r.Header()
+		rd := canonicalRdata(r)
+		k := rrsetKey{strings.ToLower(h.Name), h.Class, h.Rrtype}
+		s := sets[k]
+		if s == nil {
+			s = &canonicalRRset{key: k, ttl: h.Ttl}
+			sets[k] = s
+		}
+		i := sort.Search(len(s.rdata), func(i int) bool { return bytes.Compare(s.rdata[i], rd) >= 0 })
+		if i < len(s.rdata) && bytes.Equal(s.rdata[i], rd) {
+			continue // duplicate
+		}
+		s.rdata = append(s.rdata, nil)
+		copy(s.rdata[i+1:], s.rdata[i:])
+		s.rdata[i] = rd
+		s.rrs = append(s.rrs, r)
+		s.ttls = s.ttls || h.Ttl != s.ttl
+	}
+	return sets
+}
+
+// canonicalRdata returns the rdata of r in canonical wire format. When r can
+// not be packed the rdata in presentation format is returned, so the RR is
+// still compared.
+func canonicalRdata(r RR) []byte {
+	r1 := r.Copy()
+	lowerRdata(r1)
+	wire := make([]byte, r1.Len()*2)
+	off, err := PackRR(r1, wire, 0, nil, false)
+	if err != nil {
+		return []byte(strings.TrimPrefix(r1.String(), r1.Header().String()))
+	}
+	_, hoff, _ := UnpackDomainName(wire, 0)
+	return wire[hoff+10 : off]
+}
+
+func (s *canonicalRRset) equal(t *canonicalRRset) bool {
+	if s.ttl != t.ttl || s.ttls || t.ttls || len(s.rdata) != len(t.rdata) {
+		return false
+	}
+	for i := range s.rdata {
+		if !bytes.Equal(s.rdata[i], t.rdata[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lessRRset orders RRsets by owner name in canonical order and by type.
+func lessRRset(a, b []RR) bool {
+	ha, hb := a[0].Header(), b[0].Header()
+	if c := CanonicalCompare(ha.Name, hb.Name); c != 0 {
+		return c < 0
+	}
+	if ha.Rrtype != hb.Rrtype {
+		return ha.Rrtype < hb.Rrtype
+	}
+	return ha.Class < hb.Class
+}
+
+type rrsets [][]RR
+
+func (p rrsets) Len() int           { return len(p) }
+func (p rrsets) Less(i, j int) bool { return lessRRset(p[i], p[j]) }
+func (p rrsets) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+type mismatches []RRsetMismatch
+
+func (p mismatches) Len() int           { return len(p) }
+func (p mismatches) Less(i, j int) bool { return lessRRset(p[i].A, p[j].A) }
+func (p mismatches) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
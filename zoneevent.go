@@ -0,0 +1,95 @@
+package dns
+
+// Events for changes to a zone.
+
+import (
+	"sync"
+)
+
+// ZoneEventType is the type of a ZoneEvent.
+type ZoneEventType int
+
+// The types of zone events.
+const (
+	RRsetAdded   ZoneEventType = iota // An RR is added to the RRset Name/Rrtype
+	RRsetRemoved                      // RRs are removed from the RRset Name/Rrtype, Rrtype is TypeANY when the name is removed
+	Signed                            // The zone is (re)signed
+	SerialBumped                      // The serial of the SOA is incremented to Serial
+	Expired                           // The RRs in RRs expired and are removed, see Zone.Expire
+)
+
+// ZoneEvent is a change to a zone, see Zone.Subscribe.
+type ZoneEvent struct {
+	Type   ZoneEventType
+	Zone   *Zone
+	Name   string
+	Rrtype uint16
+	Serial uint32
+	RRs    []RR
+}
+
+// The size of the buffer of the channel of a subscriber.
+const zoneEventBuffer = 128
+
+// zoneEvents holds the subscribers of a zone.
+type zoneEvents struct {
+	subs []chan ZoneEvent
+	sync.Mutex
+}
+
+// Subscribe returns a channel that receives the changes to z, for instance
+// to send NOTIFYs or to drop cached answers. The channel has a buffer, when
+// it is full new events are dropped for that subscriber, so the receiver must
+// keep up. Changes to the ZoneData of z that are not made through the methods
+// of z are not seen. Call Unsubscribe to stop the events.
+func (z *Zone) Subscribe() <-chan ZoneEvent {
+	z.Lock()
+	if z.events == nil {
+		z.events = new(zoneEvents)
+	}
+	e := z.events
+	z.Unlock()
+	c := make(chan ZoneEvent, zoneEventBuffer)
+	e.Lock()
+	defer e.Unlock()
+	e.subs = append(e.subs, c)
+	return c
+}
+
+// Unsubscribe stops the events on c, returned by Subscribe, and closes it.
+func (z *Zone) Unsubscribe(c <-chan ZoneEvent) {
+	z.RLock()
+	e := z.events
+	z.RUnlock()
+	if e == nil {
+		return
+	}
+	e.Lock()
+	defer e.Unlock()
+	for i, s := range e.subs {
+		if s == c {
+			close(s)
+			e.subs = append(e.subs[:i], e.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// emit sends ev to the subscribers of z. It never blocks, and it may be
+// called with z's lock held. The events are created in NewZone, emit is
+// a no-op for zones that don't have them.
+func (z *Zone) emit(ev ZoneEvent) {
+	e := z.events
+	if e == nil {
+		return
+	}
+	ev.Zone = z
+	e.Lock()
+	defer e.Unlock()
+	for _, c := range e.subs {
+		select {
+		case c <- ev:
+		default:
+		}
+	}
+}
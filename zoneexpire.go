@@ -0,0 +1,119 @@
+package dns
+
+// Expiring RRs in a zone.
+
+import (
+	"time"
+)
+
+// InsertExpire inserts the RR r into the zone, it is removed by Expire at
+// time t.
+func (z *Zone) InsertExpire(r RR, t time.Time) error {
+	if err := z.Insert(r); err != nil {
+		return err
+	}
+	z.SetExpire(r, t)
+	return nil
+}
+
+// SetExpire sets the expiry time of the RR r, which must be stored in the
+// zone, to t. With a zero t, r does not expire. For an mDNS goodbye packet
+// (RFC 6762, section 10.1) the record is set to expire one second later:
+//
+//	z.SetExpire(r, time.Now().Add(time.Second))
+func (z *Zone) SetExpire(r RR, t time.Time) {
+	z.Lock()
+	defer z.Unlock()
+	if t.IsZero() {
+		delete(z.expire, r)
+		return
+	}
+	if z.expire == nil {
+		z.expire = make(map[RR]time.Time)
+	}
+	z.expire[r] = t
+}
+
+// Expire removes the RRs that expire at or before now from the zone and
+// returns them, OnExpire is called for each of them. When RRs are removed the
+// SOA serial is incremented. Expire does not re-sign the zone.
+func (z *Zone) Expire(now time.Time) []RR {
+	var rrs []RR
+	z.Lock()
+	for r, t := range z.expire {
+		if !now.Before(t) {
+			rrs = append(rrs, r)
+			delete(z.expire, r)
+		}
+	}
+	onExpire := z.OnExpire
+	z.Unlock()
+	removed := rrs[:0]
+	for _, r := range rrs {
+		// r may have been removed in some other way already
+		if z.contains(r) {
+			z.remove(r)
+			removed = append(removed, r)
+		}
+	}
+	if len(removed) > 0 {
+		if apex, exact := z.lookup(z.Origin); exact {
+			apex.Lock()
+			if soa, ok := apex.RR[TypeSOA]; ok {
+				soa[0].(*SOA).Serial++
+				z.emit(ZoneEvent{Type: SerialBumped, Name: z.Origin, Rrtype: TypeSOA, Serial: soa[0].(*SOA).Serial})
+			}
+			apex.Unlock()
+		}
+		z.emit(ZoneEvent{Type: Expired, RRs: append([]RR(nil), removed...)})
+	}
+	if onExpire != nil {
+		for _, r := range removed {
+			onExpire(r)
+		}
+	}
+	return removed
+}
+
+// StartJanitor starts a goroutine that calls Expire every interval. Send true
+// on the returned channel to stop it.
+func (z *Zone) StartJanitor(interval time.Duration) chan bool {
+	stop := make(chan bool)
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-t.C:
+				z.Expire(now)
+			}
+		}
+	}()
+	return stop
+}
+
+// contains returns true when r itself is stored in the zone.
+func (z *Zone) contains(r RR) bool {
+	zd, exact := z.lookup(r.Header().Name)
+	if !exact {
+		return false
+	}
+	zd.RLock()
+	defer zd.RUnlock()
+	if sig, ok := r.(*RRSIG); ok {
+		for _, x := range zd.Signatures[sig.TypeCovered] {
+			if x == sig {
+				return true
+			}
+		}
+		return false
+	}
+	for _, x := range zd.RR[r.Header().Rrtype] {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
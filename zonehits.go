@@ -0,0 +1,72 @@
+package dns
+
+// Access counts of the names in a zone.
+
+import (
+	"math/rand"
+	"sort"
+	"sync/atomic"
+)
+
+// HotName is a name in a zone with its (estimated) number of lookups.
+type HotName struct {
+	Name   string
+	Hits   uint64 // Lookups of the name itself
+	Misses uint64 // Lookups of names below it that don't exist, as in a random subdomain attack
+}
+
+// count counts a lookup that found zd, when it is sampled. Each sampled
+// lookup counts for z.HitSampling lookups. The sampling is random, so
+// lookups that aren't sampled don't touch memory shared between goroutines.
+func (z *Zone) count(zd *ZoneData, exact bool) {
+	if rand.Intn(z.HitSampling) != 0 {
+		return
+	}
+	n := uint64(z.HitSampling)
+	if exact {
+		atomic.AddUint64(&zd.hits, n)
+		return
+	}
+	atomic.AddUint64(&zd.misses, n)
+}
+
+// HotNames returns the n names with the most lookups by Find and FindFunc,
+// hits and misses together, most looked up first; with n <= 0 all names
+// that were looked up are returned. The counts are only kept when
+// z.HitSampling is set, they are estimates: a random 1 in HitSampling
+// lookups is counted. Use them to find the RRsets worth pre-packing, or the
+// domain under a random subdomain attack. Basic use pattern:
+//
+//	z.HitSampling = 16
+//	// ... serve for a while
+//	for _, h := range z.HotNames(10) {
+//		fmt.Printf("%s %d %d\n", h.Name, h.Hits, h.Misses)
+//	}
+func (z *Zone) HotNames(n int) []HotName {
+	var hot []HotName
+	z.RLock()
+	z.Radix.NextDo(func(i interface{}) {
+		zd := i.(*ZoneData)
+		h := HotName{Name: zd.Name, Hits: atomic.LoadUint64(&zd.hits), Misses: atomic.LoadUint64(&zd.misses)}
+		if h.Hits+h.Misses > 0 {
+			hot = append(hot, h)
+		}
+	})
+	z.RUnlock()
+	sort.SliceStable(hot, func(i, j int) bool { return hot[i].Hits+hot[i].Misses > hot[j].Hits+hot[j].Misses })
+	if n > 0 && len(hot) > n {
+		hot = hot[:n]
+	}
+	return hot
+}
+
+// ResetHits sets the counts of all names in the zone to zero.
+func (z *Zone) ResetHits() {
+	z.RLock()
+	defer z.RUnlock()
+	z.Radix.NextDo(func(i interface{}) {
+		zd := i.(*ZoneData)
+		atomic.StoreUint64(&zd.hits, 0)
+		atomic.StoreUint64(&zd.misses, 0)
+	})
+}
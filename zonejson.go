@@ -0,0 +1,192 @@
+package dns
+
+// JSON export and import of zones, for the REST APIs of management panels.
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ZoneRR is the structured form of an RR. The rdata fields are keyed on the
+// names of the fields of the RR's type, e.g. "Preference" and "Mx" for an MX
+// record. JSON is a subset of YAML 1.2, so the export can be read as YAML as
+// well.
+type ZoneRR struct {
+	Name  string                     `json:"name"`
+	Type  string                     `json:"type"`
+	Class string                     `json:"class,omitempty"` // Empty for IN
+	TTL   uint32                     `json:"ttl"`
+	Rdata map[string]json.RawMessage `json:"rdata"`
+}
+
+// NewZoneRR returns the structured form of r.
+func NewZoneRR(r RR) (*ZoneRR, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	h := r.Header()
+	zr := &ZoneRR{Name: h.Name, TTL: h.Ttl}
+	if err := json.Unmarshal(b, &zr.Rdata); err != nil {
+		return nil, err
+	}
+	delete(zr.Rdata, "Hdr")
+	var ok bool
+	if zr.Type, ok = TypeToString[h.Rrtype]; !ok {
+		zr.Type = "TYPE" + strconv.Itoa(int(h.Rrtype))
+	}
+	if h.Class != ClassINET {
+		if zr.Class, ok = ClassToString[h.Class]; !ok {
+			zr.Class = "CLASS" + strconv.Itoa(int(h.Class))
+		}
+	}
+	return zr, nil
+}
+
+// RR returns the RR zr describes. The type must be known, and the rdata
+// fields must match the fields of the type and pack to valid rdata.
+func (zr *ZoneRR) RR() (RR, error) {
+	t, err := ParseType(zr.Type)
+	if err != nil {
+		return nil, err
+	}
+	mk, ok := rr_mk[t]
+	if !ok {
+		return nil, &Error{Err: "unknown type " + zr.Type, Name: zr.Name}
+	}
+	class := uint16(ClassINET)
+	if zr.Class != "" {
+		if class, err = ParseClass(zr.Class); err != nil {
+			return nil, err
+		}
+	}
+	b, err := json.Marshal(zr.Rdata)
+	if err != nil {
+		return nil, err
+	}
+	r := mk()
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(r); err != nil {
+		return nil, &Error{Err: "bad rdata: " + err.Error(), Name: zr.Name}
+	}
+	*r.Header() = RR_Header{Name: Fqdn(zr.Name), Rrtype: t, Class: class, Ttl: zr.TTL}
+	if err := checkName(r.Header().Name); err != nil {
+		return nil, err
+	}
+	if _, err := PackRR(r, make([]byte, MaxMsgSize), 0, nil, false); err != nil {
+		return nil, &Error{Err: "bad rdata: " + err.Error(), Name: zr.Name}
+	}
+	return r, nil
+}
+
+// zoneJSON is the JSON form of a Zone and of a ZoneData.
+type zoneJSON struct {
+	Origin string    `json:"origin,omitempty"`
+	Name   string    `json:"name,omitempty"`
+	RRs    []*ZoneRR `json:"rrs"`
+}
+
+// MarshalJSON returns the RRs and signatures of zd in JSON:
+//
+//	{"name": "www.miek.nl.", "rrs": [{"name": "www.miek.nl.", "type": "A", "ttl": 3600, "rdata": {"A": "127.0.0.1"}}]}
+func (zd *ZoneData) MarshalJSON() ([]byte, error) {
+	zd.RLock()
+	defer zd.RUnlock()
+	j := &zoneJSON{Name: zd.Name}
+	if err := j.add(zd); err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// MarshalJSON returns the origin and all the RRs and signatures of z in
+// JSON, the apex records come first. See ZoneData.MarshalJSON.
+func (z *Zone) MarshalJSON() ([]byte, error) {
+	z.RLock()
+	defer z.RUnlock()
+	j := &zoneJSON{Origin: z.Origin}
+	var err error
+	z.Radix.NextDo(func(v interface{}) {
+		zd := v.(*ZoneData)
+		zd.RLock()
+		defer zd.RUnlock()
+		if err == nil {
+			err = j.add(zd)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// add adds the RRs and signatures of zd to j, the caller must hold zd's
+// lock.
+func (j *zoneJSON) add(zd *ZoneData) error {
+	add := func(r RR) error {
+		zr, err := NewZoneRR(r)
+		if err == nil {
+			j.RRs = append(j.RRs, zr)
+		}
+		return err
+	}
+	// SOA first, for readers that expect it
+	for _, r := range zd.RR[TypeSOA] {
+		if err := add(r); err != nil {
+			return err
+		}
+	}
+	for t, rrs := range zd.RR {
+		if t == TypeSOA {
+			continue
+		}
+		for _, r := range rrs {
+			if err := add(r); err != nil {
+				return err
+			}
+		}
+	}
+	for _, sigs := range zd.Signatures {
+		for _, r := range sigs {
+			if err := add(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ImportJSON reads a zone or a ZoneData in the JSON form of MarshalJSON
+// from r and inserts the RRs in z. All the RRs are checked first: when an
+// RR is not valid, not in the zone, or the origin doesn't match z, nothing
+// is inserted and the error is returned.
+func (z *Zone) ImportJSON(r io.Reader) error {
+	j := new(zoneJSON)
+	if err := json.NewDecoder(r).Decode(j); err != nil {
+		return &Error{Err: "bad zone json: " + err.Error(), Name: z.Origin}
+	}
+	if j.Origin != "" && !strings.EqualFold(Fqdn(j.Origin), z.Origin) {
+		return &Error{Err: "origin does not match " + z.Origin, Name: j.Origin}
+	}
+	rrs := make([]RR, len(j.RRs))
+	for i, zr := range j.RRs {
+		rr, err := zr.RR()
+		if err != nil {
+			return err
+		}
+		if !z.isSubDomain(rr.Header().Name) {
+			return &Error{Err: "out of zone data", Name: rr.Header().Name}
+		}
+		rrs[i] = rr
+	}
+	for _, rr := range rrs {
+		if err := z.Insert(rr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
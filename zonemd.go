@@ -0,0 +1,137 @@
+package dns
+
+// Message digests for zones (RFC 8976).
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// ZONEMD schemes and hash algorithms.
+const (
+	ZoneMDSimple = 1 // Scheme
+
+	ZoneMDSHA384 = 1 // Hash algorithms
+	ZoneMDSHA512 = 2
+)
+
+// ComputeZONEMD calculates the digest of the zone and returns it as a
+// ZONEMD record, for the apex of the zone with the serial of the SOA. Only
+// the simple scheme is supported. The ZONEMD records at the apex and their
+// signatures are not part of the digest, so the record can be inserted in
+// the zone, after which the apex ZONEMD RRset should be (re)signed.
+func (z *Zone) ComputeZONEMD(scheme, hash uint8) (*ZONEMD, error) {
+	if scheme != ZoneMDSimple {
+		return nil, &Error{Err: "unsupported ZONEMD scheme", Name: z.Origin}
+	}
+	h := zonemdHash(hash)
+	if h == nil {
+		return nil, ErrAlg
+	}
+	rrs, err := z.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	soa := rrs[0].(*SOA)
+	canonical := make(canonicalRRs, 0, len(rrs))
+	for _, r := range rrs[1:] { // the last SOA is the first SOA
+		if zonemdExclude(r, z.Origin) {
+			continue
+		}
+		r.Header().Name = strings.ToLower(r.Header().Name)
+		lowerRdata(r)
+		wire := make([]byte, r.Len()*2)
+		off, err := PackRR(r, wire, 0, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		_, hoff, _ := UnpackDomainName(wire, 0)
+		canonical = append(canonical, canonicalRR{r, wire[hoff+10 : off]})
+	}
+	sort.Sort(canonical)
+	for i, r := range canonical {
+		if i > 0 && !canonical.Less(i-1, i) {
+			continue // duplicate
+		}
+		wire := make([]byte, r.rr.Len()*2)
+		off, err := PackRR(r.rr, wire, 0, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(wire[:off])
+	}
+	md := new(ZONEMD)
+	md.Hdr = RR_Header{z.Origin, TypeZONEMD, ClassINET, soa.Hdr.Ttl, 0}
+	md.Serial = soa.Serial
+	md.Scheme = scheme
+	md.Hash = hash
+	md.Digest = hex.EncodeToString(h.Sum(nil))
+	return md, nil
+}
+
+// VerifyZONEMD checks the ZONEMD records at the apex of the zone. It returns
+// nil when one of the records with a supported scheme and hash algorithm
+// matches the zone. When the zone has no ZONEMD records, ErrRRset is returned;
+// when none of them can be checked, ErrAlg.
+func (z *Zone) VerifyZONEMD() error {
+	apex := z.Apex()
+	if apex == nil {
+		return ErrSoa
+	}
+	apex.RLock()
+	mds := make([]*ZONEMD, 0, len(apex.RR[TypeZONEMD]))
+	for _, r := range apex.RR[TypeZONEMD] {
+		mds = append(mds, r.(*ZONEMD))
+	}
+	apex.RUnlock()
+	if len(mds) == 0 {
+		return ErrRRset
+	}
+	var err error = ErrAlg
+	for _, md := range mds {
+		if md.Scheme != ZoneMDSimple || zonemdHash(md.Hash) == nil {
+			continue
+		}
+		computed, e := z.ComputeZONEMD(md.Scheme, md.Hash)
+		if e != nil {
+			return e
+		}
+		if computed.Serial != md.Serial {
+			err = &Error{Err: "ZONEMD serial does not match SOA serial", Name: z.Origin}
+			continue
+		}
+		if strings.EqualFold(computed.Digest, md.Digest) {
+			return nil
+		}
+		err = &Error{Err: "ZONEMD digest does not match", Name: z.Origin}
+	}
+	return err
+}
+
+func zonemdHash(h uint8) hash.Hash {
+	switch h {
+	case ZoneMDSHA384:
+		return sha512.New384()
+	case ZoneMDSHA512:
+		return sha512.New()
+	}
+	return nil
+}
+
+// zonemdExclude returns true for the apex ZONEMD records and their
+// signatures.
+func zonemdExclude(r RR, origin string) bool {
+	if !strings.EqualFold(r.Header().Name, origin) {
+		return false
+	}
+	switch x := r.(type) {
+	case *ZONEMD:
+		return true
+	case *RRSIG:
+		return x.TypeCovered == TypeZONEMD
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package dns
+
+// Metadata of the RRsets in a zone.
+
+import (
+	"sort"
+	"time"
+)
+
+// Meta is the metadata of an RRset in a zone, as needed by hosting platforms
+// that keep the zones of many customers. It is never sent to clients, and
+// transfers out (TransferOut, Snapshot) leave it alone. The metadata stays
+// with the RRset when RRs are added or removed, it is dropped when the RRset
+// is removed as a whole. Expiry times are kept per RR, see SetExpire and
+// ExpireTime.
+type Meta struct {
+	Source  string      // Where the RRset comes from, e.g. a file name or "update"
+	Tenant  string      // Account or tenant id of the owner of the RRset
+	Comment string      // Free form comment
+	Data    interface{} // Opaque data of the application
+}
+
+// SetMeta attaches the metadata m to the RRset of name s and type t,
+// replacing the metadata that was there. The RRset must exist, with a nil m
+// the metadata is removed.
+func (z *Zone) SetMeta(s string, t uint16, m *Meta) error {
+	zd, exact := z.lookup(s)
+	if !exact {
+		return &Error{Err: "no such RRset", Name: s}
+	}
+	zd.Lock()
+	defer zd.Unlock()
+	if len(zd.RR[t]) == 0 {
+		return &Error{Err: "no such RRset", Name: s}
+	}
+	if m == nil {
+		delete(zd.Meta, t)
+		return nil
+	}
+	zd.Meta[t] = m
+	return nil
+}
+
+// Meta returns the metadata of the RRset of name s and type t, or nil.
+func (z *Zone) Meta(s string, t uint16) *Meta {
+	zd, exact := z.lookup(s)
+	if !exact {
+		return nil
+	}
+	zd.RLock()
+	defer zd.RUnlock()
+	return zd.Meta[t]
+}
+
+// ExpireTime returns the time the RR r in the zone expires, or the zero time
+// when it does not expire.
+func (z *Zone) ExpireTime(r RR) time.Time {
+	z.RLock()
+	defer z.RUnlock()
+	return z.expire[r]
+}
+
+// Walk calls f for every RRset in the zone, in NSEC order, with its
+// signatures and metadata (which may be nil). When f returns false the walk
+// stops. The zone and the node are read locked while f runs, so f must not
+// modify the zone.
+func (z *Zone) Walk(f func(rrs []RR, sigs []*RRSIG, m *Meta) bool) {
+	z.RLock()
+	defer z.RUnlock()
+	stop := false
+	z.Radix.NextDo(func(i interface{}) {
+		if stop {
+			return
+		}
+		zd := i.(*ZoneData)
+		zd.RLock()
+		defer zd.RUnlock()
+		types := make([]uint16, 0, len(zd.RR))
+		for t, _ := range zd.RR {
+			types = append(types, t)
+		}
+		sort.Sort(uint16Slice(types))
+		for _, t := range types {
+			if !f(zd.RR[t], zd.Signatures[t], zd.Meta[t]) {
+				stop = true
+				return
+			}
+		}
+	})
+}
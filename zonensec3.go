@@ -0,0 +1,153 @@
+package dns
+
+// Denial of existence with NSEC3 (RFC 5155) for signed zones.
+
+import (
+	"sort"
+	"strings"
+)
+
+// nsec3Name holds a name that gets an NSEC3 record.
+type nsec3Name struct {
+	hash   string
+	bitmap []uint16
+}
+
+// nsec3Chain (re)builds the NSEC3 chain of z with the parameters in
+// config.NSEC3, the NSEC records are removed and the NSEC3PARAM record is
+// put at the apex. Names below a zone cut (glue) get no NSEC3 record. With
+// config.OptOut unsigned delegations get none either, nor do the empty
+// non-terminals that only lead to unsigned delegations. NSEC3 records that
+// don't change keep their signatures. The caller must hold z's write lock.
+func (z *Zone) nsec3Chain(config *SignatureConfig) error {
+	p := config.NSEC3
+	if HashName(z.Origin, p.Hash, p.Iterations, p.Salt) == "" {
+		return ErrAlg
+	}
+	apex, ok := z.Radix.Find(toRadixName(z.Origin))
+	if !ok {
+		return ErrSoa
+	}
+	param := &NSEC3PARAM{Hdr: RR_Header{z.Origin, TypeNSEC3PARAM, ClassINET, config.Minttl, 0},
+		Hash: p.Hash, Iterations: p.Iterations, SaltLength: uint8(len(p.Salt) / 2), Salt: p.Salt}
+	zd := apex.Value.(*ZoneData)
+	zd.Lock()
+	if old := zd.RR[TypeNSEC3PARAM]; len(old) != 1 || !sameRdata(old[0], param) {
+		zd.RR[TypeNSEC3PARAM] = []RR{param}
+		zd.Signatures[TypeNSEC3PARAM] = nil
+	}
+	zd.Unlock()
+
+	origin := strings.ToLower(z.Origin)
+	names := make(map[string]*ZoneData) // keyed on the lower cased name
+	chain := make(map[string]*ZoneData) // the nodes holding NSEC3 records, keyed on the owner
+	z.Radix.NextDo(func(v interface{}) {
+		zd := v.(*ZoneData)
+		zd.Lock()
+		defer zd.Unlock()
+		delete(zd.RR, TypeNSEC)
+		delete(zd.Signatures, TypeNSEC)
+		if _, ok := zd.RR[TypeNSEC3]; ok {
+			chain[strings.ToLower(zd.Name)] = zd
+			if len(zd.RR) == 1 {
+				return // only the NSEC3 record, not a name in the zone
+			}
+		}
+		names[strings.ToLower(zd.Name)] = zd
+	})
+
+	// above returns the names between name and the apex.
+	above := func(name string) (a []string) {
+		if name == origin {
+			return nil
+		}
+		for p := parentName(name); p != "" && p != origin; p = parentName(p) {
+			a = append(a, p)
+		}
+		return a
+	}
+	// below returns true when name is below a zone cut.
+	below := func(name string) bool {
+		for _, a := range above(name) {
+			if zd, ok := names[a]; ok && zd.NonAuth {
+				return true
+			}
+		}
+		return false
+	}
+	hashed := make(map[string]*nsec3Name) // keyed on the name
+	for name, zd := range names {
+		if below(name) {
+			continue
+		}
+		zd.RLock()
+		unsigned := zd.NonAuth && len(zd.RR[TypeDS]) == 0
+		bitmap := TypeBitMap(zd)
+		zd.RUnlock()
+		if unsigned && config.OptOut {
+			continue
+		}
+		hashed[name] = &nsec3Name{HashName(name, p.Hash, p.Iterations, p.Salt), bitmap}
+		// The empty non-terminals above name
+		for _, a := range above(name) {
+			if _, ok := names[a]; ok {
+				break
+			}
+			if _, ok := hashed[a]; !ok {
+				hashed[a] = &nsec3Name{HashName(a, p.Hash, p.Iterations, p.Salt), nil}
+			}
+		}
+	}
+
+	sorted := make([]*nsec3Name, 0, len(hashed))
+	for _, n := range hashed {
+		sorted = append(sorted, n)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].hash < sorted[j].hash })
+	flags := uint8(0)
+	if config.OptOut {
+		flags = 1
+	}
+	for i, n := range sorted {
+		owner := strings.ToLower(n.hash) + "." + z.Origin
+		nsec3 := &NSEC3{Hdr: RR_Header{owner, TypeNSEC3, ClassINET, config.Minttl, 0},
+			Hash: p.Hash, Flags: flags, Iterations: p.Iterations, SaltLength: uint8(len(p.Salt) / 2), Salt: p.Salt,
+			HashLength: 20, NextDomain: sorted[(i+1)%len(sorted)].hash, TypeBitMap: n.bitmap}
+		zd, ok := chain[owner]
+		delete(chain, owner)
+		if !ok {
+			zd = NewZoneData(owner)
+			zd.RR[TypeNSEC3] = []RR{nsec3}
+			z.Radix.Insert(toRadixName(owner), zd)
+			continue
+		}
+		zd.Lock()
+		if old := zd.RR[TypeNSEC3]; len(old) != 1 || !sameRdata(old[0], nsec3) {
+			zd.RR[TypeNSEC3] = []RR{nsec3}
+			zd.Signatures[TypeNSEC3] = nil
+		}
+		zd.Unlock()
+	}
+	// What is left of the old chain is stale
+	for owner, zd := range chain {
+		zd.Lock()
+		delete(zd.RR, TypeNSEC3)
+		delete(zd.Signatures, TypeNSEC3)
+		empty := len(zd.RR) == 0
+		zd.Unlock()
+		if empty {
+			z.Radix.Remove(toRadixName(owner))
+		}
+	}
+	return nil
+}
+
+// parentName returns the name with the first label of name removed, or ""
+// for the root.
+func parentName(name string) string {
+	i, end := NextLabelOffset(name, 0)
+	if end {
+		return ""
+	}
+	return name[i:]
+}
@@ -0,0 +1,68 @@
+package dns
+
+// A set of zones.
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ZoneSet holds the zones of a process, keyed on their origin. It's safe for
+// concurrent use by multiple goroutines.
+type ZoneSet struct {
+	zones map[string]*Zone // keyed on the lower cased origin
+	*sync.RWMutex
+}
+
+// NewZoneSet returns an empty ZoneSet.
+func NewZoneSet() *ZoneSet {
+	return &ZoneSet{zones: make(map[string]*Zone), RWMutex: new(sync.RWMutex)}
+}
+
+// Add adds z to s, it replaces the zone with the same origin.
+func (s *ZoneSet) Add(z *Zone) {
+	s.Lock()
+	defer s.Unlock()
+	s.zones[strings.ToLower(z.Origin)] = z
+}
+
+// Remove removes the zone with origin from s.
+func (s *ZoneSet) Remove(origin string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.zones, strings.ToLower(Fqdn(origin)))
+}
+
+// Zone returns the zone with origin, or nil.
+func (s *ZoneSet) Zone(origin string) *Zone {
+	s.RLock()
+	defer s.RUnlock()
+	return s.zones[strings.ToLower(Fqdn(origin))]
+}
+
+// Origins returns the sorted origins of the zones in s.
+func (s *ZoneSet) Origins() []string {
+	s.RLock()
+	defer s.RUnlock()
+	origins := make([]string, 0, len(s.zones))
+	for _, z := range s.zones {
+		origins = append(origins, z.Origin)
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// Match returns the zone closest to name: the zone with the longest origin
+// that name is in, or nil.
+func (s *ZoneSet) Match(name string) *Zone {
+	name = strings.ToLower(Fqdn(name))
+	s.RLock()
+	defer s.RUnlock()
+	for off, end := 0, false; !end; off, end = NextLabelOffset(name, off) {
+		if z, ok := s.zones[name[off:]]; ok {
+			return z
+		}
+	}
+	return s.zones["."]
+}
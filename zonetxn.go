@@ -0,0 +1,128 @@
+package dns
+
+// Bulk changes to a zone.
+
+import (
+	"time"
+)
+
+// InsertRRs inserts the RRs in rrs into z, like Insert, but all of them are
+// checked first: when one can't be inserted nothing is, and its error is
+// returned. The zone is locked once for the whole batch, which makes this
+// much faster than Insert for loading large zones.
+func (z *Zone) InsertRRs(rrs []RR) error {
+	t := z.Begin()
+	for _, r := range rrs {
+		if err := t.Insert(r); err != nil {
+			t.Rollback()
+			return err
+		}
+	}
+	return t.Commit()
+}
+
+// Txn is a transaction on a zone: a batch of inserts and removals that is
+// applied atomically by Commit, or discarded by Rollback. A Txn is not safe
+// for concurrent use by multiple goroutines.
+type Txn struct {
+	z      *Zone
+	ops    []txnOp
+	done   bool
+	noauth bool // the caller has authorized the changes, see UpdateAs
+}
+
+type txnOp struct {
+	r      RR
+	remove bool
+	name   string // when r is nil, the RRset of name and type t is removed
+	t      uint16
+}
+
+// Begin starts a transaction on z.
+func (z *Zone) Begin() *Txn {
+	return &Txn{z: z}
+}
+
+// Insert adds the insert of r to t. The checks of Zone.Insert are done now,
+// when r can't be inserted the error is returned and r is not added.
+func (t *Txn) Insert(r RR) error {
+	if t.done {
+		return ErrTxnDone
+	}
+	if err := t.authorize(AuthInsert, r.Header().Name, r.Header().Rrtype); err != nil {
+		return err
+	}
+	if err := t.z.checkInsert(r); err != nil {
+		return err
+	}
+	t.ops = append(t.ops, txnOp{r: r})
+	return nil
+}
+
+// Remove adds the removal of r, see Zone.Remove, to t.
+func (t *Txn) Remove(r RR) error {
+	if t.done {
+		return ErrTxnDone
+	}
+	if err := t.authorize(AuthRemove, r.Header().Name, r.Header().Rrtype); err != nil {
+		return err
+	}
+	t.ops = append(t.ops, txnOp{r: r, remove: true})
+	return nil
+}
+
+// RemoveRRset adds the removal of the RRset of s and type typ, see
+// Zone.RemoveRRset, to t.
+func (t *Txn) RemoveRRset(s string, typ uint16) error {
+	if t.done {
+		return ErrTxnDone
+	}
+	if err := t.authorize(AuthRemove, s, typ); err != nil {
+		return err
+	}
+	t.ops = append(t.ops, txnOp{name: s, t: typ})
+	return nil
+}
+
+// authorize authorizes a change as the empty tenant, unless the caller has
+// done so already.
+func (t *Txn) authorize(op int, name string, typ uint16) error {
+	if t.noauth {
+		return nil
+	}
+	return t.z.authorize("", op, name, typ)
+}
+
+// Commit applies the changes in t to the zone, in order, with the zone
+// locked for writing. Readers see the zone before or after all of them.
+func (t *Txn) Commit() error {
+	if t.done {
+		return ErrTxnDone
+	}
+	t.done = true
+	if len(t.ops) == 0 {
+		return nil
+	}
+	z := t.z
+	z.Lock()
+	defer z.Unlock()
+	z.ModTime = time.Now().UTC()
+	for _, op := range t.ops {
+		switch {
+		case op.r == nil:
+			z.delRRset(op.name, op.t)
+		case op.remove:
+			z.del(op.r)
+		default:
+			z.add(op.r)
+		}
+	}
+	t.ops = nil
+	return nil
+}
+
+// Rollback discards the changes in t.
+func (t *Txn) Rollback() {
+	t.done = true
+	t.ops = nil
+}
@@ -0,0 +1,393 @@
+package dns
+
+// Applying dynamic updates to a zone.
+
+import (
+	"github.com/miekg/radix"
+	"strings"
+	"time"
+)
+
+// Update applies the update section of the dynamic update u (RFC 2136,
+// section 3.4.2) to the zone; the prerequisites are not checked. Changes to
+// the SOA and NS records at the apex that would break the zone are ignored.
+// When the update does not change the SOA, its serial is incremented.
+//
+// If keys is not nil the zone is signed and only the changed RRsets are
+// (re)signed, the NSEC records of the changed names and of the names before
+// them are fixed. This is much cheaper than calling Sign for the whole zone
+// after every update. When config.NSEC3 is set the NSEC3 chain is made again,
+// which hashes all names. If config is nil, DefaultSignatureConfig is used.
+//
+// When u carries an update lease (EDNS0_UPDATE_LEASE), the inserted RRs are
+// removed by Expire when the lease, as returned by Lease, has lapsed. Adding
+// a leased RR that already exists refreshes its lease.
+//
+// The tenant of the update, for Authorize, is the name of the TSIG key u is
+// signed with, see UpdateAs. When Authorize is set an update that is not
+// signed is refused, it would otherwise be made as the empty tenant.
+func (z *Zone) Update(u *Msg, keys map[*DNSKEY]PrivateKey, config *SignatureConfig) error {
+	t := u.IsTsig()
+	if t == nil {
+		if z.Authorize != nil {
+			return &Error{Err: "not authorized", Name: z.Origin}
+		}
+		return z.update(u, keys, config)
+	}
+	return z.UpdateAs(strings.ToLower(t.Hdr.Name), u, keys, config)
+}
+
+// UpdateAs works like Update, but the update is made by tenant. When one of
+// the changes is not authorized, nothing is changed.
+func (z *Zone) UpdateAs(tenant string, u *Msg, keys map[*DNSKEY]PrivateKey, config *SignatureConfig) error {
+	for _, r := range u.Ns {
+		h := r.Header()
+		op := AuthInsert
+		if h.Class == ClassANY || h.Class == ClassNONE {
+			op = AuthRemove
+		}
+		if err := z.authorize(tenant, op, h.Name, h.Rrtype); err != nil {
+			return err
+		}
+	}
+	return z.update(u, keys, config)
+}
+
+func (z *Zone) update(u *Msg, keys map[*DNSKEY]PrivateKey, config *SignatureConfig) error {
+	serial, err := z.serial()
+	if err != nil {
+		return err
+	}
+	lease := time.Duration(z.Lease(u)) * time.Second
+	changed := make(map[string]map[uint16]bool) // names and the types that changed
+	change := func(name string, t uint16) {
+		name = strings.ToLower(name)
+		if changed[name] == nil {
+			changed[name] = make(map[uint16]bool)
+		}
+		changed[name][t] = true
+	}
+	// RFC 2136, section 3.4.1.3: all of the update is checked first
+	for _, r := range u.Ns {
+		if h := r.Header(); !z.isSubDomain(h.Name) {
+			return &Error{Err: "out of zone data", Name: h.Name, Rcode: RcodeNotZone}
+		}
+	}
+	t := &Txn{z: z, noauth: true}
+	var (
+		added   []RR                        // RRs inserted by this update
+		removed = make(map[RR]bool)         // RRs removed by this update
+		gone    = make(map[string][]uint16) // RRsets of the zone removed by this update
+		refresh []RR                        // leased RRs of the zone that are added again
+	)
+	// find works like z.find, but sees the changes made so far.
+	find := func(r RR) RR {
+		for _, x := range added {
+			if !removed[x] && sameRdata(x, r) {
+				return x
+			}
+		}
+		for _, typ := range gone[strings.ToLower(r.Header().Name)] {
+			if typ == r.Header().Rrtype {
+				return nil
+			}
+		}
+		if x := z.find(r); x != nil && !removed[x] {
+			return x
+		}
+		return nil
+	}
+	removeRRset := func(name string, typ uint16) error {
+		if err := t.RemoveRRset(name, typ); err != nil {
+			return err
+		}
+		for _, x := range added {
+			if x.Header().Rrtype == typ && strings.EqualFold(x.Header().Name, name) {
+				removed[x] = true
+			}
+		}
+		name = strings.ToLower(name)
+		gone[name] = append(gone[name], typ)
+		change(name, typ)
+		return nil
+	}
+	for _, r := range u.Ns {
+		h := r.Header()
+		apex := strings.EqualFold(h.Name, z.Origin)
+		switch h.Class {
+		case ClassANY:
+			if h.Rrtype == TypeANY {
+				types := z.types(h.Name)
+				for _, x := range added {
+					if !removed[x] && strings.EqualFold(x.Header().Name, h.Name) {
+						types = append(types, x.Header().Rrtype)
+					}
+				}
+				for _, typ := range types {
+					if apex && (typ == TypeSOA || typ == TypeNS) || typ == TypeNSEC {
+						continue
+					}
+					if err := removeRRset(h.Name, typ); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if apex && (h.Rrtype == TypeSOA || h.Rrtype == TypeNS) {
+				continue
+			}
+			if err := removeRRset(h.Name, h.Rrtype); err != nil {
+				return err
+			}
+		case ClassNONE:
+			if h.Rrtype == TypeSOA {
+				continue
+			}
+			r1 := r.Copy()
+			r1.Header().Class = ClassINET
+			if x := find(r1); x != nil {
+				if err := t.Remove(x); err != nil {
+					return err
+				}
+				removed[x] = true
+				change(h.Name, h.Rrtype)
+			}
+		default:
+			if h.Rrtype == TypeSOA {
+				if !apex || !SerialLess(serial, r.(*SOA).Serial) {
+					continue
+				}
+				if err := removeRRset(h.Name, TypeSOA); err != nil {
+					return err
+				}
+				serial = r.(*SOA).Serial
+			} else if x := find(r); x != nil {
+				if lease > 0 {
+					refresh = append(refresh, x)
+				}
+				continue
+			}
+			if err := t.Insert(r); err != nil {
+				return err
+			}
+			added = append(added, r)
+			change(h.Name, h.Rrtype)
+		}
+	}
+	if err := t.Commit(); err != nil {
+		return err
+	}
+	if lease > 0 {
+		for _, x := range refresh {
+			z.refreshLease(x, lease)
+		}
+		for _, x := range added {
+			if !removed[x] && x.Header().Rrtype != TypeSOA {
+				z.SetExpire(x, time.Now().Add(lease))
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if !changed[strings.ToLower(z.Origin)][TypeSOA] {
+		serial, err := z.bumpSerial()
+		if err != nil {
+			return err
+		}
+		z.emit(ZoneEvent{Type: SerialBumped, Name: z.Origin, Rrtype: TypeSOA, Serial: serial})
+		change(z.Origin, TypeSOA)
+	}
+	if keys == nil {
+		return nil
+	}
+	return z.signDelta(changed, keys, config)
+}
+
+// serial returns the serial of the SOA record of the zone, or ErrSoa when
+// the zone has none.
+func (z *Zone) serial() (uint32, error) {
+	apex, exact := z.lookup(z.Origin)
+	if !exact {
+		return 0, ErrSoa
+	}
+	apex.RLock()
+	defer apex.RUnlock()
+	if len(apex.RR[TypeSOA]) == 0 {
+		return 0, ErrSoa
+	}
+	return apex.RR[TypeSOA][0].(*SOA).Serial, nil
+}
+
+// bumpSerial increments the serial of the SOA record while the zone is
+// locked and returns the new serial, or ErrSoa when the zone has no SOA.
+func (z *Zone) bumpSerial() (uint32, error) {
+	z.Lock()
+	defer z.Unlock()
+	apex, e := z.Radix.Find(toRadixName(z.Origin))
+	if !e {
+		return 0, ErrSoa
+	}
+	zd := apex.Value.(*ZoneData)
+	zd.Lock()
+	defer zd.Unlock()
+	if len(zd.RR[TypeSOA]) == 0 {
+		return 0, ErrSoa
+	}
+	soa := zd.RR[TypeSOA][0].(*SOA)
+	soa.Serial++
+	return soa.Serial, nil
+}
+
+// Lease returns the lease in seconds granted to the dynamic update u, that is
+// the lease asked for in its EDNS0_UPDATE_LEASE option bounded by MinLease and
+// MaxLease, or 0 when u has no lease. The reply to u should tell the client
+// the granted lease:
+//
+//	if lease := z.Lease(req); lease > 0 {
+//		m.SetEdns0(4096, false)
+//		m.IsEdns0().SetOption(&dns.EDNS0_UPDATE_LEASE{Code: dns.EDNS0UPDATELEASE, Lease: lease})
+//	}
+func (z *Zone) Lease(u *Msg) uint32 {
+	opt := u.IsEdns0()
+	if opt == nil {
+		return 0
+	}
+	ul, ok := opt.GetOption(EDNS0UPDATELEASE).(*EDNS0_UPDATE_LEASE)
+	if !ok {
+		return 0
+	}
+	lease := ul.Lease
+	if lease < z.MinLease {
+		lease = z.MinLease
+	}
+	if z.MaxLease != 0 && lease > z.MaxLease {
+		lease = z.MaxLease
+	}
+	return lease
+}
+
+// refreshLease extends the lease of r to d from now, if r has a lease.
+func (z *Zone) refreshLease(r RR, d time.Duration) {
+	z.Lock()
+	defer z.Unlock()
+	if _, ok := z.expire[r]; ok {
+		z.expire[r] = time.Now().Add(d)
+	}
+}
+
+// types returns the types of the RRsets of name s.
+func (z *Zone) types(s string) []uint16 {
+	zd, exact := z.lookup(s)
+	if !exact {
+		return nil
+	}
+	zd.RLock()
+	defer zd.RUnlock()
+	types := make([]uint16, 0, len(zd.RR))
+	for t, _ := range zd.RR {
+		types = append(types, t)
+	}
+	return types
+}
+
+// find returns the RR in the zone with the same owner, type, class and rdata
+// as r, or nil if there is none.
+func (z *Zone) find(r RR) RR {
+	zd, exact := z.lookup(r.Header().Name)
+	if !exact {
+		return nil
+	}
+	zd.RLock()
+	defer zd.RUnlock()
+	for _, x := range zd.RR[r.Header().Rrtype] {
+		if sameRdata(x, r) {
+			return x
+		}
+	}
+	return nil
+}
+
+// signDelta re-signs the changed RRsets and fixes the NSEC chain around the
+// changed names. An NSEC3 chain is rebuilt, only its changed records are
+// signed.
+func (z *Zone) signDelta(changed map[string]map[uint16]bool, keys map[*DNSKEY]PrivateKey, config *SignatureConfig) error {
+	z.Lock()
+	z.ModTime = time.Now().UTC()
+	defer z.Unlock()
+	if config == nil {
+		config = DefaultSignatureConfig
+	}
+	keytags := make(map[*DNSKEY]uint16)
+	for k, _ := range keys {
+		keytags[k] = k.KeyTag()
+	}
+	apex, e := z.Radix.Find(toRadixName(z.Origin))
+	if !e {
+		return ErrSoa
+	}
+	config.Minttl = apex.Value.(*ZoneData).RR[TypeSOA][0].(*SOA).Minttl
+
+	resign := make(map[*ZoneData]*radix.Radix)
+	for name, types := range changed {
+		node, exact := z.Radix.Find(toRadixName(name))
+		if !exact {
+			continue
+		}
+		zd := node.Value.(*ZoneData)
+		zd.Lock()
+		empty := true
+		for t, _ := range zd.RR {
+			empty = empty && t == TypeNSEC
+		}
+		for t, _ := range types {
+			zd.dropSignatures(t, keytags, config)
+		}
+		// The types changed, so the NSEC is created again
+		delete(zd.RR, TypeNSEC)
+		zd.dropSignatures(TypeNSEC, keytags, config)
+		zd.Unlock()
+		prev := node.Prev()
+		for z.glue(prev.Value.(*ZoneData).Name) {
+			prev = prev.Prev()
+		}
+		resign[prev.Value.(*ZoneData)] = prev
+		if empty {
+			// The name no longer exists
+			z.Radix.Remove(toRadixName(name))
+			delete(resign, zd)
+			continue
+		}
+		if z.glue(zd.Name) {
+			continue
+		}
+		resign[zd] = node
+	}
+	if config.NSEC3 != nil {
+		if err := z.nsec3Chain(config); err != nil {
+			return err
+		}
+		// The NSEC3 records that are new or changed have no signatures
+		node := apex
+		for {
+			zd := node.Value.(*ZoneData)
+			zd.RLock()
+			for _, t := range []uint16{TypeNSEC3, TypeNSEC3PARAM} {
+				if _, ok := zd.RR[t]; ok && len(zd.Signatures[t]) == 0 {
+					resign[zd] = node
+				}
+			}
+			zd.RUnlock()
+			if node = node.Next(); strings.EqualFold(node.Value.(*ZoneData).Name, z.Origin) {
+				break
+			}
+		}
+	}
+	for zd, node := range resign {
+		if err := zd.Sign(z.next(node).Value.(*ZoneData).Name, keys, keytags, config); err != nil {
+			return err
+		}
+	}
+	z.emit(ZoneEvent{Type: Signed})
+	return nil
+}
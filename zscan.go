@@ -57,12 +57,14 @@ const (
 	_EXPECT_DIRGENERATE_BL // Space after directive $GENERATE
 )
 
-// ParseError is a parsing error. It contains the parse error and the location in the io.Reader
-// where the error occured.
+// ParseError is the error returned when a zone file or RR can not be parsed.
+// It holds the position of the token that could not be parsed and, when
+// there is one, the error that caused it.
 type ParseError struct {
-	file string
-	err  string
-	lex  lex
+	file  string
+	err   string
+	lex   lex
+	cause error
 }
 
 func (e *ParseError) Error() (s string) {
@@ -71,9 +73,47 @@ func (e *ParseError) Error() (s string) {
 	}
 	s += "dns: " + e.err + ": " + strconv.QuoteToASCII(e.lex.token) + " at line: " +
 		strconv.Itoa(e.lex.line) + ":" + strconv.Itoa(e.lex.column)
+	if e.cause != nil {
+		s += ": " + e.cause.Error()
+	}
 	return
 }
 
+// File returns the name of the file in which the error occurred.
+func (e *ParseError) File() string { return e.file }
+
+// Line returns the line on which the error occurred.
+func (e *ParseError) Line() int { return e.lex.line }
+
+// Column returns the column at which the error occurred.
+func (e *ParseError) Column() int { return e.lex.column }
+
+// Token returns the text of the token that could not be parsed.
+func (e *ParseError) Token() string { return e.lex.token }
+
+// Message returns the description of the error, without the position.
+func (e *ParseError) Message() string { return e.err }
+
+// Unwrap returns the error that caused the parse error, or nil.
+func (e *ParseError) Unwrap() error { return e.cause }
+
+// Is reports whether target is ErrParse.
+func (e *ParseError) Is(target error) bool { return target == ErrParse }
+
+// ParseErrors holds all the errors found in a zone file, see ParseZoneAll.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	s := make([]string, len(e))
+	for i, e1 := range e {
+		s[i] = e1.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Is reports whether target is ErrParse.
+func (e ParseErrors) Is(target error) bool { return target == ErrParse }
+
 type lex struct {
 	token  string // text of the token
 	err    bool   // when true, token text has lexer error 
@@ -99,10 +139,26 @@ func NewRR(s string) (RR, error) {
 	return ReadRR(strings.NewReader(s), "")
 }
 
+// NewRRWithOrigin works like NewRR, but relative names in s, including
+// "@", are completed with origin and the TTL defaults to defaultTTL.
+// This is handy for records in configuration files:
+//
+//	rr, err := dns.NewRRWithOrigin("www CNAME @", "example.org.", 300)
+func NewRRWithOrigin(s, origin string, defaultTTL uint32) (RR, error) {
+	if s == "" || s[len(s)-1] != '\n' {
+		s += "\n"
+	}
+	return readRR(strings.NewReader(s), origin, "", defaultTTL)
+}
+
 // ReadRR reads the RR contained in q. Only the first RR is returned.
 // The class defaults to IN and TTL defaults to 3600.
 func ReadRR(q io.Reader, filename string) (RR, error) {
-	r := <-parseZoneHelper(q, ".", filename, 1)
+	return readRR(q, ".", filename, defaultTtl)
+}
+
+func readRR(q io.Reader, origin, filename string, ttl uint32) (RR, error) {
+	r := <-parseZoneHelper(q, origin, filename, 1, false, ttl)
 	if r.Error != nil {
 		return nil, r.Error
 	}
@@ -126,17 +182,50 @@ func ReadRR(q io.Reader, filename string) (RR, error) {
 //		}
 //	}      
 func ParseZone(r io.Reader, origin, file string) chan Token {
-	return parseZoneHelper(r, origin, file, 10000)
+	return parseZoneHelper(r, origin, file, 10000, false, defaultTtl)
 }
 
-func parseZoneHelper(r io.Reader, origin, file string, chansize int) chan Token {
+// ParseZoneAll works like ParseZone, but it does not stop at the first error:
+// after an error the rest of the line is skipped and parsing continues on the
+// next line. It returns all the RRs that could be parsed and, if there were
+// errors, all of them as ParseErrors. Only errors of the lexer, such as an
+// unbalanced brace, stop the parsing.
+//
+//	rrs, err := dns.ParseZoneAll(f, "example.org.", "db.example.org")
+//	if errs, ok := err.(dns.ParseErrors); ok {
+//		for _, e := range errs {
+//			fmt.Printf("%s:%d:%d: %s\n", e.File(), e.Line(), e.Column(), e.Message())
+//		}
+//	}
+func ParseZoneAll(r io.Reader, origin, file string) ([]RR, error) {
+	var (
+		rrs  []RR
+		errs ParseErrors
+	)
+	for x := range parseZoneHelper(r, origin, file, 10000, true, defaultTtl) {
+		if x.Error != nil {
+			errs = append(errs, x.Error)
+			continue
+		}
+		rrs = append(rrs, x.RR)
+	}
+	if len(errs) > 0 {
+		return rrs, errs
+	}
+	return rrs, nil
+}
+
+func parseZoneHelper(r io.Reader, origin, file string, chansize int, cont bool, ttl uint32) chan Token {
 	t := make(chan Token, chansize)
-	go parseZone(r, origin, file, t, 0)
+	go parseZone(r, origin, file, t, 0, cont, ttl)
 	return t
 
 }
 
-func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
+// parseZone parses the zone in r and sends the RRs and errors on t. When
+// cont is true, parsing continues on the next line after an error. The TTL
+// defaults to ttl until a $TTL directive is seen.
+func parseZone(r io.Reader, origin, f string, t chan Token, include int, cont bool, ttl uint32) {
 	defer func() {
 		if include == 0 {
 			close(t)
@@ -160,19 +249,35 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 		origin = "."
 	}
 	if _, _, ok := IsDomainName(origin); !ok {
-		t <- Token{Error: &ParseError{f, "bad initial origin name", lex{}}}
+		t <- Token{Error: &ParseError{f, "bad initial origin name", lex{}, nil}}
 		return
 	}
 	origin = Fqdn(origin)
 
 	st := _EXPECT_OWNER_DIR // initial state
 	var h RR_Header
-	var defttl uint32 = defaultTtl
+	var defttl uint32 = ttl
 	var prevName string
+	// fail sends the error e, in continue mode it skips the rest of the line
+	// and returns true when parsing can go on.
+	fail := func(e *ParseError) bool {
+		t <- Token{Error: e}
+		if !cont {
+			return false
+		}
+		for l := e.lex; l.value != _NEWLINE && l.value != _EOF; {
+			var ok bool
+			if l, ok = <-c; !ok || l.err {
+				return false
+			}
+		}
+		st = _EXPECT_OWNER_DIR
+		return true
+	}
 	for l := range c {
 		// Lexer spotted an error already
 		if l.err == true {
-			t <- Token{Error: &ParseError{f, l.token, l}}
+			t <- Token{Error: &ParseError{f, l.token, l, nil}}
 			return
 
 		}
@@ -194,7 +299,10 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				}
 				_, ld, ok := IsDomainName(l.token)
 				if !ok {
-					t <- Token{Error: &ParseError{f, "bad owner name", l}}
+					_, _, err := ValidateName(l.token)
+					if fail(&ParseError{f, "bad owner name", l, err}) {
+						continue
+					}
 					return
 				}
 				if h.Name[ld-1] != '.' {
@@ -223,7 +331,9 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				// line except the RR type
 			case _STRING: // First thing on the is the ttl
 				if ttl, ok := stringToTtl(l.token); !ok {
-					t <- Token{Error: &ParseError{f, "not a TTL", l}}
+					if fail(&ParseError{f, "not a TTL", l, nil}) {
+						continue
+					}
 					return
 				} else {
 					h.Ttl = ttl
@@ -233,18 +343,24 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				st = _EXPECT_ANY_NOTTL_BL
 
 			default:
-				t <- Token{Error: &ParseError{f, "syntax error at beginning", l}}
+				if fail(&ParseError{f, "syntax error at beginning", l, nil}) {
+					continue
+				}
 				return
 			}
 		case _EXPECT_DIRINCLUDE_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after $INCLUDE-directive", l}}
+				if fail(&ParseError{f, "no blank after $INCLUDE-directive", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_DIRINCLUDE
 		case _EXPECT_DIRINCLUDE:
 			if l.value != _STRING {
-				t <- Token{Error: &ParseError{f, "expecting $INCLUDE value, not this...", l}}
+				if fail(&ParseError{f, "expecting $INCLUDE value, not this...", l, nil}) {
+					continue
+				}
 				return
 			}
 			neworigin := origin // There may be optionally a new origin set after the filename, if not use current one
@@ -254,7 +370,9 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				l := <-c
 				if l.value == _STRING {
 					if _, _, ok := IsDomainName(l.token); !ok {
-						t <- Token{Error: &ParseError{f, "bad origin name", l}}
+						if fail(&ParseError{f, "bad origin name", l, nil}) {
+							continue
+						}
 						return
 					}
 					// a new origin is specified.
@@ -271,30 +389,40 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 			case _NEWLINE, _EOF:
 				// Ok
 			default:
-				t <- Token{Error: &ParseError{f, "garbage after $INCLUDE", l}}
+				if fail(&ParseError{f, "garbage after $INCLUDE", l, nil}) {
+					continue
+				}
 				return
 			}
 			// Start with the new file
 			r1, e1 := os.Open(l.token)
 			if e1 != nil {
-				t <- Token{Error: &ParseError{f, "failed to open `" + l.token + "'", l}}
+				if fail(&ParseError{f, "failed to open `" + l.token + "'", l, e1}) {
+					continue
+				}
 				return
 			}
 			if include+1 > 7 {
-				t <- Token{Error: &ParseError{f, "too deeply nested $INCLUDE", l}}
+				if fail(&ParseError{f, "too deeply nested $INCLUDE", l, nil}) {
+					continue
+				}
 				return
 			}
-			parseZone(r1, l.token, neworigin, t, include+1)
+			parseZone(r1, neworigin, l.token, t, include+1, cont, defaultTtl)
 			st = _EXPECT_OWNER_DIR
 		case _EXPECT_DIRTTL_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after $TTL-directive", l}}
+				if fail(&ParseError{f, "no blank after $TTL-directive", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_DIRTTL
 		case _EXPECT_DIRTTL:
 			if l.value != _STRING {
-				t <- Token{Error: &ParseError{f, "expecting $TTL value, not this...", l}}
+				if fail(&ParseError{f, "expecting $TTL value, not this...", l, nil}) {
+					continue
+				}
 				return
 			}
 			if e := slurpRemainder(c, f); e != nil {
@@ -302,7 +430,9 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				return
 			}
 			if ttl, ok := stringToTtl(l.token); !ok {
-				t <- Token{Error: &ParseError{f, "expecting $TTL value, not this...", l}}
+				if fail(&ParseError{f, "expecting $TTL value, not this...", l, nil}) {
+					continue
+				}
 				return
 			} else {
 				defttl = ttl
@@ -310,20 +440,26 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 			st = _EXPECT_OWNER_DIR
 		case _EXPECT_DIRORIGIN_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after $ORIGIN-directive", l}}
+				if fail(&ParseError{f, "no blank after $ORIGIN-directive", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_DIRORIGIN
 		case _EXPECT_DIRORIGIN:
 			if l.value != _STRING {
-				t <- Token{Error: &ParseError{f, "expecting $ORIGIN value, not this...", l}}
+				if fail(&ParseError{f, "expecting $ORIGIN value, not this...", l, nil}) {
+					continue
+				}
 				return
 			}
 			if e := slurpRemainder(c, f); e != nil {
 				t <- Token{Error: e}
 			}
 			if _, _, ok := IsDomainName(l.token); !ok {
-				t <- Token{Error: &ParseError{f, "bad origin name", l}}
+				if fail(&ParseError{f, "bad origin name", l, nil}) {
+					continue
+				}
 				return
 			}
 			if !IsFqdn(l.token) {
@@ -338,23 +474,31 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 			st = _EXPECT_OWNER_DIR
 		case _EXPECT_DIRGENERATE_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after $GENERATE-directive", l}}
+				if fail(&ParseError{f, "no blank after $GENERATE-directive", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_DIRGENERATE
 		case _EXPECT_DIRGENERATE:
 			if l.value != _STRING {
-				t <- Token{Error: &ParseError{f, "expecting $GENERATE value, not this...", l}}
+				if fail(&ParseError{f, "expecting $GENERATE value, not this...", l, nil}) {
+					continue
+				}
 				return
 			}
 			if e := generate(l, c, t, origin); e != "" {
-				t <- Token{Error: &ParseError{f, e, l}}
+				if fail(&ParseError{f, e, l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_OWNER_DIR
 		case _EXPECT_OWNER_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank after owner", l}}
+				if fail(&ParseError{f, "no blank after owner", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_ANY
@@ -368,7 +512,9 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				st = _EXPECT_ANY_NOCLASS_BL
 			case _STRING: // TTL is this case
 				if ttl, ok := stringToTtl(l.token); !ok {
-					t <- Token{Error: &ParseError{f, "not a TTL", l}}
+					if fail(&ParseError{f, "not a TTL", l, nil}) {
+						continue
+					}
 					return
 				} else {
 					h.Ttl = ttl
@@ -376,18 +522,24 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				}
 				st = _EXPECT_ANY_NOTTL_BL
 			default:
-				t <- Token{Error: &ParseError{f, "expecting RR type, TTL or class, not this...", l}}
+				if fail(&ParseError{f, "expecting RR type, TTL or class, not this...", l, nil}) {
+					continue
+				}
 				return
 			}
 		case _EXPECT_ANY_NOCLASS_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank before class", l}}
+				if fail(&ParseError{f, "no blank before class", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_ANY_NOCLASS
 		case _EXPECT_ANY_NOTTL_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank before TTL", l}}
+				if fail(&ParseError{f, "no blank before TTL", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_ANY_NOTTL
@@ -400,14 +552,18 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				h.Rrtype = l.torc
 				st = _EXPECT_RDATA
 			default:
-				t <- Token{Error: &ParseError{f, "expecting RR type or class, not this...", l}}
+				if fail(&ParseError{f, "expecting RR type or class, not this...", l, nil}) {
+					continue
+				}
 				return
 			}
 		case _EXPECT_ANY_NOCLASS:
 			switch l.value {
 			case _STRING: // TTL
 				if ttl, ok := stringToTtl(l.token); !ok {
-					t <- Token{Error: &ParseError{f, "not a TTL", l}}
+					if fail(&ParseError{f, "not a TTL", l, nil}) {
+						continue
+					}
 					return
 				} else {
 					h.Ttl = ttl
@@ -418,18 +574,24 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				h.Rrtype = l.torc
 				st = _EXPECT_RDATA
 			default:
-				t <- Token{Error: &ParseError{f, "expecting RR type or TTL, not this...", l}}
+				if fail(&ParseError{f, "expecting RR type or TTL, not this...", l, nil}) {
+					continue
+				}
 				return
 			}
 		case _EXPECT_RRTYPE_BL:
 			if l.value != _BLANK {
-				t <- Token{Error: &ParseError{f, "no blank before RR type", l}}
+				if fail(&ParseError{f, "no blank before RR type", l, nil}) {
+					continue
+				}
 				return
 			}
 			st = _EXPECT_RRTYPE
 		case _EXPECT_RRTYPE:
 			if l.value != _RRTYPE {
-				t <- Token{Error: &ParseError{f, "unknown RR type", l}}
+				if fail(&ParseError{f, "unknown RR type", l, nil}) {
+					continue
+				}
 				return
 			}
 			h.Rrtype = l.torc
@@ -442,7 +604,9 @@ func parseZone(r io.Reader, origin, f string, t chan Token, include int) {
 				if e.lex.token == "" && e.lex.value == 0 {
 					e.lex = l // Uh, dirty
 				}
-				t <- Token{Error: e}
+				if fail(e) {
+					continue
+				}
 				return
 			}
 			t <- Token{RR: r}
@@ -873,7 +1037,7 @@ func slurpRemainder(c chan lex, f string) *ParseError {
 	case _BLANK:
 		l = <-c
 		if l.value != _NEWLINE && l.value != _EOF {
-			return &ParseError{f, "garbage after rdata", l}
+			return &ParseError{f, "garbage after rdata", l, nil}
 		}
 		// Ok
 	case _NEWLINE:
@@ -881,7 +1045,7 @@ func slurpRemainder(c chan lex, f string) *ParseError {
 	case _EOF:
 		// Ok
 	default:
-		return &ParseError{f, "garbage after rdata", l}
+		return &ParseError{f, "garbage after rdata", l, nil}
 	}
 	return nil
 }
@@ -890,16 +1054,16 @@ func slurpRemainder(c chan lex, f string) *ParseError {
 // Used for NID and L64 record.
 func stringToNodeID(l lex) (uint64, *ParseError) {
 	if len(l.token) < 19 {
-		return 0, &ParseError{l.token, "bad NID/L64 NodeID/Locator64", l}
+		return 0, &ParseError{l.token, "bad NID/L64 NodeID/Locator64", l, nil}
 	}
 	// There must be three colons at fixes postitions, if not its a parse error
 	if l.token[4] != ':' && l.token[9] != ':' && l.token[14] != ':' {
-		return 0, &ParseError{l.token, "bad NID/L64 NodeID/Locator64", l}
+		return 0, &ParseError{l.token, "bad NID/L64 NodeID/Locator64", l, nil}
 	}
 	s := l.token[0:4] + l.token[5:9] + l.token[10:14] + l.token[15:19]
 	u, e := strconv.ParseUint(s, 16, 64)
 	if e != nil {
-		return 0, &ParseError{l.token, "bad NID/L64 NodeID/Locator64", l}
+		return 0, &ParseError{l.token, "bad NID/L64 NodeID/Locator64", l, nil}
 	}
 	return u, nil
 }
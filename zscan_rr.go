@@ -118,6 +118,10 @@ func setRR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		return setDS(h, c, f)
 	case TypeCDS:
 		return setCDS(h, c, f)
+	case TypeCDNSKEY:
+		return setCDNSKEY(h, c, f)
+	case TypeZONEMD:
+		return setZONEMD(h, c, f)
 	case TypeDLV:
 		return setDLV(h, c, f)
 	case TypeTA:
@@ -134,6 +138,10 @@ func setRR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		return setSPF(h, c, f)
 	case TypeDHCID:
 		return setDHCID(h, c, f)
+	case TypeCERT:
+		return setCERT(h, c, o, f)
+	case TypeOPENPGPKEY:
+		return setOPENPGPKEY(h, c, f)
 	case TypeIPSECKEY:
 		return setIPSECKEY(h, c, o, f)
 	case TypeLOC:
@@ -164,7 +172,7 @@ func endingToString(c chan lex, errstr, f string) (string, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return "", &ParseError{f, "bad DHCID Digest", l}
+			return "", &ParseError{f, errstr, l, nil}
 		}
 		l = <-c
 	}
@@ -188,17 +196,17 @@ func endingToTxtSlice(c chan lex, errstr, f string) ([]string, *ParseError) {
 			case _BLANK:
 				if quote {
 					// _BLANK can only be seen in between txt parts.
-					return nil, &ParseError{f, errstr, l}
+					return nil, &ParseError{f, errstr, l, nil}
 				}
 			case _QUOTE:
 				quote = !quote
 			default:
-				return nil, &ParseError{f, errstr, l}
+				return nil, &ParseError{f, errstr, l, nil}
 			}
 			l = <-c
 		}
 		if quote {
-			return nil, &ParseError{f, errstr, l}
+			return nil, &ParseError{f, errstr, l, nil}
 		}
 	case false: // Unquoted text record
 		s = make([]string, 1)
@@ -217,7 +225,7 @@ func setA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l := <-c
 	rr.A = net.ParseIP(l.token)
 	if rr.A == nil {
-		return nil, &ParseError{f, "bad A A", l}
+		return nil, &ParseError{f, "bad A A", l, nil}
 	}
 	return rr, nil
 }
@@ -229,7 +237,7 @@ func setAAAA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l := <-c
 	rr.AAAA = net.ParseIP(l.token)
 	if rr.AAAA == nil {
-		return nil, &ParseError{f, "bad AAAA AAAA", l}
+		return nil, &ParseError{f, "bad AAAA AAAA", l, nil}
 	}
 	return rr, nil
 }
@@ -246,7 +254,7 @@ func setNS(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad NS Ns", l}
+		return nil, &ParseError{f, "bad NS Ns", l, nil}
 	}
 	if rr.Ns[ld-1] != '.' {
 		rr.Ns = appendOrigin(rr.Ns, o)
@@ -266,7 +274,7 @@ func setPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad PTR Ptr", l}
+		return nil, &ParseError{f, "bad PTR Ptr", l, nil}
 	}
 	if rr.Ptr[ld-1] != '.' {
 		rr.Ptr = appendOrigin(rr.Ptr, o)
@@ -285,7 +293,7 @@ func setRP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	} else {
 		_, ld, ok := IsDomainName(l.token)
 		if !ok {
-			return nil, &ParseError{f, "bad RP Mbox", l}
+			return nil, &ParseError{f, "bad RP Mbox", l, nil}
 		}
 		if rr.Mbox[ld-1] != '.' {
 			rr.Mbox = appendOrigin(rr.Mbox, o)
@@ -300,7 +308,7 @@ func setRP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad RP Txt", l}
+		return nil, &ParseError{f, "bad RP Txt", l, nil}
 	}
 	if rr.Txt[ld-1] != '.' {
 		rr.Txt = appendOrigin(rr.Txt, o)
@@ -321,7 +329,7 @@ func setMR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MR Mr", l}
+		return nil, &ParseError{f, "bad MR Mr", l, nil}
 	}
 	if rr.Mr[ld-1] != '.' {
 		rr.Mr = appendOrigin(rr.Mr, o)
@@ -341,7 +349,7 @@ func setMB(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MB Mb", l}
+		return nil, &ParseError{f, "bad MB Mb", l, nil}
 	}
 	if rr.Mb[ld-1] != '.' {
 		rr.Mb = appendOrigin(rr.Mb, o)
@@ -361,7 +369,7 @@ func setMG(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MG Mg", l}
+		return nil, &ParseError{f, "bad MG Mg", l, nil}
 	}
 	if rr.Mg[ld-1] != '.' {
 		rr.Mg = appendOrigin(rr.Mg, o)
@@ -393,7 +401,7 @@ func setMINFO(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	} else {
 		_, ld, ok := IsDomainName(l.token)
 		if !ok {
-			return nil, &ParseError{f, "bad MINFO Rmail", l}
+			return nil, &ParseError{f, "bad MINFO Rmail", l, nil}
 		}
 		if rr.Rmail[ld-1] != '.' {
 			rr.Rmail = appendOrigin(rr.Rmail, o)
@@ -407,7 +415,7 @@ func setMINFO(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MINFO Email", l}
+		return nil, &ParseError{f, "bad MINFO Email", l, nil}
 	}
 	if rr.Email[ld-1] != '.' {
 		rr.Email = appendOrigin(rr.Email, o)
@@ -427,7 +435,7 @@ func setMF(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MF Mf", l}
+		return nil, &ParseError{f, "bad MF Mf", l, nil}
 	}
 	if rr.Mf[ld-1] != '.' {
 		rr.Mf = appendOrigin(rr.Mf, o)
@@ -447,7 +455,7 @@ func setMD(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MD Md", l}
+		return nil, &ParseError{f, "bad MD Md", l, nil}
 	}
 	if rr.Md[ld-1] != '.' {
 		rr.Md = appendOrigin(rr.Md, o)
@@ -461,7 +469,7 @@ func setMX(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad MX Pref", l}
+		return nil, &ParseError{f, "bad MX Pref", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}
@@ -474,7 +482,7 @@ func setMX(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad MX Mx", l}
+		return nil, &ParseError{f, "bad MX Mx", l, nil}
 	}
 	if rr.Mx[ld-1] != '.' {
 		rr.Mx = appendOrigin(rr.Mx, o)
@@ -488,7 +496,7 @@ func setRT(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad RT Preference", l}
+		return nil, &ParseError{f, "bad RT Preference", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}
@@ -501,7 +509,7 @@ func setRT(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad RT Host", l}
+		return nil, &ParseError{f, "bad RT Host", l, nil}
 	}
 	if rr.Host[ld-1] != '.' {
 		rr.Host = appendOrigin(rr.Host, o)
@@ -515,7 +523,7 @@ func setAFSDB(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad AFSDB Subtype", l}
+		return nil, &ParseError{f, "bad AFSDB Subtype", l, nil}
 	} else {
 		rr.Subtype = uint16(i)
 	}
@@ -528,7 +536,7 @@ func setAFSDB(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad AFSDB Hostname", l}
+		return nil, &ParseError{f, "bad AFSDB Hostname", l, nil}
 	}
 	if rr.Hostname[ld-1] != '.' {
 		rr.Hostname = appendOrigin(rr.Hostname, o)
@@ -551,7 +559,7 @@ func setKX(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad KX Pref", l}
+		return nil, &ParseError{f, "bad KX Pref", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}
@@ -564,7 +572,7 @@ func setKX(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad KX Exchanger", l}
+		return nil, &ParseError{f, "bad KX Exchanger", l, nil}
 	}
 	if rr.Exchanger[ld-1] != '.' {
 		rr.Exchanger = appendOrigin(rr.Exchanger, o)
@@ -584,7 +592,7 @@ func setCNAME(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad CNAME Target", l}
+		return nil, &ParseError{f, "bad CNAME Target", l, nil}
 	}
 	if rr.Target[ld-1] != '.' {
 		rr.Target = appendOrigin(rr.Target, o)
@@ -604,7 +612,7 @@ func setDNAME(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad CNAME Target", l}
+		return nil, &ParseError{f, "bad CNAME Target", l, nil}
 	}
 	if rr.Target[ld-1] != '.' {
 		rr.Target = appendOrigin(rr.Target, o)
@@ -624,7 +632,7 @@ func setSOA(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	} else {
 		_, ld, ok := IsDomainName(l.token)
 		if !ok {
-			return nil, &ParseError{f, "bad SOA Ns", l}
+			return nil, &ParseError{f, "bad SOA Ns", l, nil}
 		}
 		if rr.Ns[ld-1] != '.' {
 			rr.Ns = appendOrigin(rr.Ns, o)
@@ -638,7 +646,7 @@ func setSOA(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	} else {
 		_, ld, ok := IsDomainName(l.token)
 		if !ok {
-			return nil, &ParseError{f, "bad SOA Mbox", l}
+			return nil, &ParseError{f, "bad SOA Mbox", l, nil}
 		}
 		if rr.Mbox[ld-1] != '.' {
 			rr.Mbox = appendOrigin(rr.Mbox, o)
@@ -655,10 +663,10 @@ func setSOA(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		if j, e := strconv.Atoi(l.token); e != nil {
 			if i == 0 {
 				// Serial should be a number
-				return nil, &ParseError{f, "bad SOA zone parameter", l}
+				return nil, &ParseError{f, "bad SOA zone parameter", l, nil}
 			}
 			if v, ok = stringToTtl(l.token); !ok {
-				return nil, &ParseError{f, "bad SOA zone parameter", l}
+				return nil, &ParseError{f, "bad SOA zone parameter", l, nil}
 
 			}
 		} else {
@@ -690,21 +698,21 @@ func setSRV(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SRV Priority", l}
+		return nil, &ParseError{f, "bad SRV Priority", l, nil}
 	} else {
 		rr.Priority = uint16(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SRV Weight", l}
+		return nil, &ParseError{f, "bad SRV Weight", l, nil}
 	} else {
 		rr.Weight = uint16(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SRV Port", l}
+		return nil, &ParseError{f, "bad SRV Port", l, nil}
 	} else {
 		rr.Port = uint16(i)
 	}
@@ -717,7 +725,7 @@ func setSRV(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad SRV Target", l}
+		return nil, &ParseError{f, "bad SRV Target", l, nil}
 	}
 	if rr.Target[ld-1] != '.' {
 		rr.Target = appendOrigin(rr.Target, o)
@@ -731,14 +739,14 @@ func setNAPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR Order", l}
+		return nil, &ParseError{f, "bad NAPTR Order", l, nil}
 	} else {
 		rr.Order = uint16(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR Preference", l}
+		return nil, &ParseError{f, "bad NAPTR Preference", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}
@@ -746,57 +754,57 @@ func setNAPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	<-c     // _BLANK
 	l = <-c // _QUOTE
 	if l.value != _QUOTE {
-		return nil, &ParseError{f, "bad NAPTR Flags", l}
+		return nil, &ParseError{f, "bad NAPTR Flags", l, nil}
 	}
 	l = <-c // Either String or Quote
 	if l.value == _STRING {
 		rr.Flags = l.token
 		l = <-c // _QUOTE
 		if l.value != _QUOTE {
-			return nil, &ParseError{f, "bad NAPTR Flags", l}
+			return nil, &ParseError{f, "bad NAPTR Flags", l, nil}
 		}
 	} else if l.value == _QUOTE {
 		rr.Flags = ""
 	} else {
-		return nil, &ParseError{f, "bad NAPTR Flags", l}
+		return nil, &ParseError{f, "bad NAPTR Flags", l, nil}
 	}
 
 	// Service
 	<-c     // _BLANK
 	l = <-c // _QUOTE
 	if l.value != _QUOTE {
-		return nil, &ParseError{f, "bad NAPTR Service", l}
+		return nil, &ParseError{f, "bad NAPTR Service", l, nil}
 	}
 	l = <-c // Either String or Quote
 	if l.value == _STRING {
 		rr.Service = l.token
 		l = <-c // _QUOTE
 		if l.value != _QUOTE {
-			return nil, &ParseError{f, "bad NAPTR Service", l}
+			return nil, &ParseError{f, "bad NAPTR Service", l, nil}
 		}
 	} else if l.value == _QUOTE {
 		rr.Service = ""
 	} else {
-		return nil, &ParseError{f, "bad NAPTR Service", l}
+		return nil, &ParseError{f, "bad NAPTR Service", l, nil}
 	}
 
 	// Regexp
 	<-c     // _BLANK
 	l = <-c // _QUOTE
 	if l.value != _QUOTE {
-		return nil, &ParseError{f, "bad NAPTR Regexp", l}
+		return nil, &ParseError{f, "bad NAPTR Regexp", l, nil}
 	}
 	l = <-c // Either String or Quote
 	if l.value == _STRING {
 		rr.Regexp = l.token
 		l = <-c // _QUOTE
 		if l.value != _QUOTE {
-			return nil, &ParseError{f, "bad NAPTR Regexp", l}
+			return nil, &ParseError{f, "bad NAPTR Regexp", l, nil}
 		}
 	} else if l.value == _QUOTE {
 		rr.Regexp = ""
 	} else {
-		return nil, &ParseError{f, "bad NAPTR Regexp", l}
+		return nil, &ParseError{f, "bad NAPTR Regexp", l, nil}
 	}
 	// After quote no space??
 	<-c     // _BLANK
@@ -808,7 +816,7 @@ func setNAPTR(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad NAPTR Replacement", l}
+		return nil, &ParseError{f, "bad NAPTR Replacement", l, nil}
 	}
 	if rr.Replacement[ld-1] != '.' {
 		rr.Replacement = appendOrigin(rr.Replacement, o)
@@ -827,7 +835,7 @@ func setTALINK(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	} else {
 		_, ld, ok := IsDomainName(l.token)
 		if !ok {
-			return nil, &ParseError{f, "bad TALINK PreviousName", l}
+			return nil, &ParseError{f, "bad TALINK PreviousName", l, nil}
 		}
 		if rr.PreviousName[ld-1] != '.' {
 			rr.PreviousName = appendOrigin(rr.PreviousName, o)
@@ -842,7 +850,7 @@ func setTALINK(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad TALINK NextName", l}
+		return nil, &ParseError{f, "bad TALINK NextName", l, nil}
 	}
 	if rr.NextName[ld-1] != '.' {
 		rr.NextName = appendOrigin(rr.NextName, o)
@@ -861,7 +869,7 @@ func setLOC(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	// North
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Latitude", l}
+		return nil, &ParseError{f, "bad LOC Latitude", l, nil}
 	} else {
 		rr.Latitude = 1000 * 60 * 60 * uint32(i)
 	}
@@ -872,14 +880,14 @@ func setLOC(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		goto East
 	}
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Latitude minutes", l}
+		return nil, &ParseError{f, "bad LOC Latitude minutes", l, nil}
 	} else {
 		rr.Latitude += 1000 * 60 * uint32(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.ParseFloat(l.token, 32); e != nil {
-		return nil, &ParseError{f, "bad LOC Latitude seconds", l}
+		return nil, &ParseError{f, "bad LOC Latitude seconds", l, nil}
 	} else {
 		rr.Latitude += uint32(1000 * i)
 	}
@@ -890,14 +898,14 @@ func setLOC(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		goto East
 	}
 	// If still alive, flag an error
-	return nil, &ParseError{f, "bad LOC Latitude North/South", l}
+	return nil, &ParseError{f, "bad LOC Latitude North/South", l, nil}
 
 East:
 	// East
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Longitude", l}
+		return nil, &ParseError{f, "bad LOC Longitude", l, nil}
 	} else {
 		rr.Longitude = 1000 * 60 * 60 * uint32(i)
 	}
@@ -908,14 +916,14 @@ East:
 		goto Altitude
 	}
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LOC Longitude minutes", l}
+		return nil, &ParseError{f, "bad LOC Longitude minutes", l, nil}
 	} else {
 		rr.Longitude += 1000 * 60 * uint32(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.ParseFloat(l.token, 32); e != nil {
-		return nil, &ParseError{f, "bad LOC Longitude seconds", l}
+		return nil, &ParseError{f, "bad LOC Longitude seconds", l, nil}
 	} else {
 		rr.Longitude += uint32(1000 * i)
 	}
@@ -926,7 +934,7 @@ East:
 		goto Altitude
 	}
 	// If still alive, flag an error
-	return nil, &ParseError{f, "bad LOC Longitude East/West", l}
+	return nil, &ParseError{f, "bad LOC Longitude East/West", l, nil}
 
 Altitude:
 	<-c // _BLANK
@@ -935,7 +943,7 @@ Altitude:
 		l.token = l.token[0 : len(l.token)-1]
 	}
 	if i, e := strconv.ParseFloat(l.token, 32); e != nil {
-		return nil, &ParseError{f, "bad LOC Altitude", l}
+		return nil, &ParseError{f, "bad LOC Altitude", l, nil}
 	} else {
 		rr.Altitude = uint32(i*100.0 + 10000000.0 + 0.5)
 	}
@@ -949,19 +957,19 @@ Altitude:
 			switch count {
 			case 0: // Size
 				if e, m, ok := stringToCm(l.token); !ok {
-					return nil, &ParseError{f, "bad LOC Size", l}
+					return nil, &ParseError{f, "bad LOC Size", l, nil}
 				} else {
 					rr.Size = (e & 0x0f) | (m << 4 & 0xf0)
 				}
 			case 1: // HorizPre
 				if e, m, ok := stringToCm(l.token); !ok {
-					return nil, &ParseError{f, "bad LOC HorizPre", l}
+					return nil, &ParseError{f, "bad LOC HorizPre", l, nil}
 				} else {
 					rr.HorizPre = (e & 0x0f) | (m << 4 & 0xf0)
 				}
 			case 2: // VertPre
 				if e, m, ok := stringToCm(l.token); !ok {
-					return nil, &ParseError{f, "bad LOC VertPre", l}
+					return nil, &ParseError{f, "bad LOC VertPre", l, nil}
 				} else {
 					rr.VertPre = (e & 0x0f) | (m << 4 & 0xf0)
 				}
@@ -970,7 +978,7 @@ Altitude:
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad LOC Size, HorizPre or VertPre", l}
+			return nil, &ParseError{f, "bad LOC Size, HorizPre or VertPre", l, nil}
 		}
 		l = <-c
 	}
@@ -984,7 +992,7 @@ func setHIP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	// HitLength is not represented
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad HIP PublicKeyAlgorithm", l}
+		return nil, &ParseError{f, "bad HIP PublicKeyAlgorithm", l, nil}
 	} else {
 		rr.PublicKeyAlgorithm = uint8(i)
 	}
@@ -1010,7 +1018,7 @@ func setHIP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 			}
 			_, ld, ok := IsDomainName(l.token)
 			if !ok {
-				return nil, &ParseError{f, "bad HIP RendezvousServers", l}
+				return nil, &ParseError{f, "bad HIP RendezvousServers", l, nil}
 			}
 			if l.token[ld-1] != '.' {
 				l.token = appendOrigin(l.token, o)
@@ -1019,7 +1027,7 @@ func setHIP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _BLANK:
 			// Ok
 		default:
-			return nil, &ParseError{f, "bad HIP RendezvousServers", l}
+			return nil, &ParseError{f, "bad HIP RendezvousServers", l, nil}
 		}
 		l = <-c
 	}
@@ -1032,26 +1040,28 @@ func setCERT(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	rr.Hdr = h
 
 	l := <-c
-	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad CERT Type", l}
+	if v, ok := StringToCertType[l.token]; ok {
+		rr.Type = v
+	} else if i, e := strconv.Atoi(l.token); e != nil {
+		return nil, &ParseError{f, "bad CERT Type", l, nil}
 	} else {
 		rr.Type = uint16(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR KeyTag", l}
+		return nil, &ParseError{f, "bad CERT KeyTag", l, nil}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NAPTR Algorithm", l}
+		return nil, &ParseError{f, "bad CERT Algorithm", l, nil}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
-	s, e := endingToString(c, "bad NAPTR Certificate", f)
+	s, e := endingToString(c, "bad CERT Certificate", f)
 	if e != nil {
 		return nil, e
 	}
@@ -1064,49 +1074,49 @@ func setRRSIG(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	rr.Hdr = h
 	l := <-c
 	if t, ok := StringToType[strings.ToUpper(l.token)]; !ok {
-		return nil, &ParseError{f, "bad RRSIG Typecovered", l}
+		return nil, &ParseError{f, "bad RRSIG Typecovered", l, nil}
 	} else {
 		rr.TypeCovered = t
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Algorithm", l}
+		return nil, &ParseError{f, "bad RRSIG Algorithm", l, nil}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Labels", l}
+		return nil, &ParseError{f, "bad RRSIG Labels", l, nil}
 	} else {
 		rr.Labels = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG OrigTtl", l}
+		return nil, &ParseError{f, "bad RRSIG OrigTtl", l, nil}
 	} else {
 		rr.OrigTtl = uint32(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, err := StringToTime(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Expiration", l}
+		return nil, &ParseError{f, "bad RRSIG Expiration", l, nil}
 	} else {
 		rr.Expiration = i
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, err := StringToTime(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG Inception", l}
+		return nil, &ParseError{f, "bad RRSIG Inception", l, nil}
 	} else {
 		rr.Inception = i
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, err := strconv.Atoi(l.token); err != nil {
-		return nil, &ParseError{f, "bad RRSIG KeyTag", l}
+		return nil, &ParseError{f, "bad RRSIG KeyTag", l, nil}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
@@ -1118,7 +1128,7 @@ func setRRSIG(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	} else {
 		_, ld, ok := IsDomainName(l.token)
 		if !ok {
-			return nil, &ParseError{f, "bad RRSIG SignerName", l}
+			return nil, &ParseError{f, "bad RRSIG SignerName", l, nil}
 		}
 		if rr.SignerName[ld-1] != '.' {
 			rr.SignerName = appendOrigin(rr.SignerName, o)
@@ -1143,7 +1153,7 @@ func setNSEC(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	} else {
 		_, ld, ok := IsDomainName(l.token)
 		if !ok {
-			return nil, &ParseError{f, "bad NSEC NextDomain", l}
+			return nil, &ParseError{f, "bad NSEC NextDomain", l, nil}
 		}
 		if rr.NextDomain[ld-1] != '.' {
 			rr.NextDomain = appendOrigin(rr.NextDomain, o)
@@ -1163,12 +1173,12 @@ func setNSEC(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _STRING:
 			if k, ok = StringToType[strings.ToUpper(l.token)]; !ok {
 				if k, ok = typeToInt(l.token); !ok {
-					return nil, &ParseError{f, "bad NSEC TypeBitMap", l}
+					return nil, &ParseError{f, "bad NSEC TypeBitMap", l, nil}
 				}
 			}
 			rr.TypeBitMap = append(rr.TypeBitMap, k)
 		default:
-			return nil, &ParseError{f, "bad NSEC TypeBitMap", l}
+			return nil, &ParseError{f, "bad NSEC TypeBitMap", l, nil}
 		}
 		l = <-c
 	}
@@ -1181,28 +1191,28 @@ func setNSEC3(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3 Hash", l}
+		return nil, &ParseError{f, "bad NSEC3 Hash", l, nil}
 	} else {
 		rr.Hash = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3 Flags", l}
+		return nil, &ParseError{f, "bad NSEC3 Flags", l, nil}
 	} else {
 		rr.Flags = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3 Iterations", l}
+		return nil, &ParseError{f, "bad NSEC3 Iterations", l, nil}
 	} else {
 		rr.Iterations = uint16(i)
 	}
 	<-c
 	l = <-c
 	if len(l.token) == 0 {
-		return nil, &ParseError{f, "bad NSEC3 Salt", l}
+		return nil, &ParseError{f, "bad NSEC3 Salt", l, nil}
 	}
 	rr.SaltLength = uint8(len(l.token)) / 2
 	rr.Salt = l.token
@@ -1225,12 +1235,12 @@ func setNSEC3(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 		case _STRING:
 			if k, ok = StringToType[strings.ToUpper(l.token)]; !ok {
 				if k, ok = typeToInt(l.token); !ok {
-					return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l}
+					return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l, nil}
 				}
 			}
 			rr.TypeBitMap = append(rr.TypeBitMap, k)
 		default:
-			return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l}
+			return nil, &ParseError{f, "bad NSEC3 TypeBitMap", l, nil}
 		}
 		l = <-c
 	}
@@ -1243,21 +1253,21 @@ func setNSEC3PARAM(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3PARAM Hash", l}
+		return nil, &ParseError{f, "bad NSEC3PARAM Hash", l, nil}
 	} else {
 		rr.Hash = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3PARAM Flags", l}
+		return nil, &ParseError{f, "bad NSEC3PARAM Flags", l, nil}
 	} else {
 		rr.Flags = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NSEC3PARAM Iterations", l}
+		return nil, &ParseError{f, "bad NSEC3PARAM Iterations", l, nil}
 	} else {
 		rr.Iterations = uint16(i)
 	}
@@ -1275,14 +1285,14 @@ func setWKS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l := <-c
 	rr.Address = net.ParseIP(l.token)
 	if rr.Address == nil {
-		return nil, &ParseError{f, "bad WKS Adress", l}
+		return nil, &ParseError{f, "bad WKS Adress", l, nil}
 	}
 
 	<-c // _BLANK
 	l = <-c
 	proto := "tcp"
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad WKS Protocol", l}
+		return nil, &ParseError{f, "bad WKS Protocol", l, nil}
 	} else {
 		rr.Protocol = uint8(i)
 		switch rr.Protocol {
@@ -1291,7 +1301,7 @@ func setWKS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		case 6:
 			proto = "tcp"
 		default:
-			return nil, &ParseError{f, "bad WKS Protocol", l}
+			return nil, &ParseError{f, "bad WKS Protocol", l, nil}
 		}
 	}
 
@@ -1311,12 +1321,12 @@ func setWKS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 				if i, e := strconv.Atoi(l.token); e != nil { // If a number use that
 					rr.BitMap = append(rr.BitMap, uint16(i))
 				} else {
-					return nil, &ParseError{f, "bad WKS BitMap", l}
+					return nil, &ParseError{f, "bad WKS BitMap", l, nil}
 				}
 			}
 			rr.BitMap = append(rr.BitMap, uint16(k))
 		default:
-			return nil, &ParseError{f, "bad WKS BitMap", l}
+			return nil, &ParseError{f, "bad WKS BitMap", l, nil}
 		}
 		l = <-c
 	}
@@ -1329,14 +1339,14 @@ func setSSHFP(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SSHFP Algorithm", l}
+		return nil, &ParseError{f, "bad SSHFP Algorithm", l, nil}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad SSHFP Type", l}
+		return nil, &ParseError{f, "bad SSHFP Type", l, nil}
 	} else {
 		rr.Type = uint8(i)
 	}
@@ -1352,21 +1362,21 @@ func setDNSKEY(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DNSKEY Flags", l}
+		return nil, &ParseError{f, "bad DNSKEY Flags", l, nil}
 	} else {
 		rr.Flags = uint16(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DNSKEY Protocol", l}
+		return nil, &ParseError{f, "bad DNSKEY Protocol", l, nil}
 	} else {
 		rr.Protocol = uint8(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DNSKEY Algorithm", l}
+		return nil, &ParseError{f, "bad DNSKEY Algorithm", l, nil}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
@@ -1378,27 +1388,59 @@ func setDNSKEY(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
+func setCDNSKEY(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+	rr := new(CDNSKEY)
+	rr.Hdr = h
+
+	l := <-c
+	if i, e := strconv.Atoi(l.token); e != nil {
+		return nil, &ParseError{f, "bad CDNSKEY Flags", l, nil}
+	} else {
+		rr.Flags = uint16(i)
+	}
+	<-c     // _BLANK
+	l = <-c // _STRING
+	if i, e := strconv.Atoi(l.token); e != nil {
+		return nil, &ParseError{f, "bad CDNSKEY Protocol", l, nil}
+	} else {
+		rr.Protocol = uint8(i)
+	}
+	<-c     // _BLANK
+	l = <-c // _STRING
+	if i, e := strconv.Atoi(l.token); e != nil {
+		return nil, &ParseError{f, "bad CDNSKEY Algorithm", l, nil}
+	} else {
+		rr.Algorithm = uint8(i)
+	}
+	s, e := endingToString(c, "bad CDNSKEY PublicKey", f)
+	if e != nil {
+		return nil, e
+	}
+	rr.PublicKey = s
+	return rr, nil
+}
+
 func setRKEY(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr := new(RKEY)
 	rr.Hdr = h
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad RKEY Flags", l}
+		return nil, &ParseError{f, "bad RKEY Flags", l, nil}
 	} else {
 		rr.Flags = uint16(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad RKEY Protocol", l}
+		return nil, &ParseError{f, "bad RKEY Protocol", l, nil}
 	} else {
 		rr.Protocol = uint8(i)
 	}
 	<-c     // _BLANK
 	l = <-c // _STRING
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad RKEY Algorithm", l}
+		return nil, &ParseError{f, "bad RKEY Algorithm", l, nil}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
@@ -1415,7 +1457,7 @@ func setDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr.Hdr = h
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DS KeyTag", l}
+		return nil, &ParseError{f, "bad DS KeyTag", l, nil}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
@@ -1423,7 +1465,7 @@ func setDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
 		if i, ok := StringToAlgorithm[strings.ToUpper(l.token)]; !ok {
-			return nil, &ParseError{f, "bad DS Algorithm", l}
+			return nil, &ParseError{f, "bad DS Algorithm", l, nil}
 		} else {
 			rr.Algorithm = i
 		}
@@ -1433,7 +1475,7 @@ func setDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DS DigestType", l}
+		return nil, &ParseError{f, "bad DS DigestType", l, nil}
 	} else {
 		rr.DigestType = uint8(i)
 	}
@@ -1445,12 +1487,43 @@ func setDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
+func setZONEMD(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+	rr := new(ZONEMD)
+	rr.Hdr = h
+	l := <-c
+	if i, e := strconv.ParseUint(l.token, 10, 32); e != nil {
+		return nil, &ParseError{f, "bad ZONEMD Serial", l, nil}
+	} else {
+		rr.Serial = uint32(i)
+	}
+	<-c // _BLANK
+	l = <-c
+	if i, e := strconv.Atoi(l.token); e != nil {
+		return nil, &ParseError{f, "bad ZONEMD Scheme", l, nil}
+	} else {
+		rr.Scheme = uint8(i)
+	}
+	<-c // _BLANK
+	l = <-c
+	if i, e := strconv.Atoi(l.token); e != nil {
+		return nil, &ParseError{f, "bad ZONEMD Hash", l, nil}
+	} else {
+		rr.Hash = uint8(i)
+	}
+	s, e := endingToString(c, "bad ZONEMD Digest", f)
+	if e != nil {
+		return nil, e
+	}
+	rr.Digest = s
+	return rr, nil
+}
+
 func setCDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr := new(CDS)
 	rr.Hdr = h
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad CDS KeyTag", l}
+		return nil, &ParseError{f, "bad CDS KeyTag", l, nil}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
@@ -1458,7 +1531,7 @@ func setCDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
 		if i, ok := StringToAlgorithm[strings.ToUpper(l.token)]; !ok {
-			return nil, &ParseError{f, "bad CDS Algorithm", l}
+			return nil, &ParseError{f, "bad CDS Algorithm", l, nil}
 		} else {
 			rr.Algorithm = i
 		}
@@ -1468,7 +1541,7 @@ func setCDS(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad CDS DigestType", l}
+		return nil, &ParseError{f, "bad CDS DigestType", l, nil}
 	} else {
 		rr.DigestType = uint8(i)
 	}
@@ -1485,7 +1558,7 @@ func setDLV(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr.Hdr = h
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DLV KeyTag", l}
+		return nil, &ParseError{f, "bad DLV KeyTag", l, nil}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
@@ -1493,7 +1566,7 @@ func setDLV(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
 		if i, ok := StringToAlgorithm[strings.ToUpper(l.token)]; !ok {
-			return nil, &ParseError{f, "bad DLV Algorithm", l}
+			return nil, &ParseError{f, "bad DLV Algorithm", l, nil}
 		} else {
 			rr.Algorithm = i
 		}
@@ -1503,7 +1576,7 @@ func setDLV(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad DLV DigestType", l}
+		return nil, &ParseError{f, "bad DLV DigestType", l, nil}
 	} else {
 		rr.DigestType = uint8(i)
 	}
@@ -1520,7 +1593,7 @@ func setTA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr.Hdr = h
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TA KeyTag", l}
+		return nil, &ParseError{f, "bad TA KeyTag", l, nil}
 	} else {
 		rr.KeyTag = uint16(i)
 	}
@@ -1528,7 +1601,7 @@ func setTA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
 		if i, ok := StringToAlgorithm[strings.ToUpper(l.token)]; !ok {
-			return nil, &ParseError{f, "bad TA Algorithm", l}
+			return nil, &ParseError{f, "bad TA Algorithm", l, nil}
 		} else {
 			rr.Algorithm = i
 		}
@@ -1538,7 +1611,7 @@ func setTA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TA DigestType", l}
+		return nil, &ParseError{f, "bad TA DigestType", l, nil}
 	} else {
 		rr.DigestType = uint8(i)
 	}
@@ -1555,21 +1628,21 @@ func setTLSA(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr.Hdr = h
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TLSA Usage", l}
+		return nil, &ParseError{f, "bad TLSA Usage", l, nil}
 	} else {
 		rr.Usage = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TLSA Selector", l}
+		return nil, &ParseError{f, "bad TLSA Selector", l, nil}
 	} else {
 		rr.Selector = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad TLSA MatchingType", l}
+		return nil, &ParseError{f, "bad TLSA MatchingType", l, nil}
 	} else {
 		rr.MatchingType = uint8(i)
 	}
@@ -1586,13 +1659,13 @@ func setRFC3597(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr.Hdr = h
 	l := <-c
 	if l.token != "\\#" {
-		return nil, &ParseError{f, "unkown RR type", l}
+		return nil, &ParseError{f, "unkown RR type", l, nil}
 	}
 	<-c // _BLANK
 	l = <-c
 	rdlength, e := strconv.Atoi(l.token)
 	if e != nil {
-		return nil, &ParseError{f, "bad RFC3597 Rdata", l}
+		return nil, &ParseError{f, "bad RFC3597 Rdata", l, nil}
 	}
 
 	s, e1 := endingToString(c, "bad RFC3597 Rdata", f)
@@ -1600,7 +1673,7 @@ func setRFC3597(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 		return nil, e1
 	}
 	if rdlength*2 != len(s) {
-		return nil, &ParseError{f, "bad RFC3597 Rdata", l}
+		return nil, &ParseError{f, "bad RFC3597 Rdata", l, nil}
 	}
 	rr.Rdata = s
 	return rr, nil
@@ -1649,14 +1722,14 @@ func setURI(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad URI Priority", l}
+		return nil, &ParseError{f, "bad URI Priority", l, nil}
 	} else {
 		rr.Priority = uint16(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad URI Weight", l}
+		return nil, &ParseError{f, "bad URI Weight", l, nil}
 	} else {
 		rr.Weight = uint16(i)
 	}
@@ -1675,20 +1748,20 @@ func setURI(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 			case _BLANK:
 				if quote {
 					// _BLANK can only be seen in between txt parts.
-					return nil, &ParseError{f, "bad URI Target", l}
+					return nil, &ParseError{f, "bad URI Target", l, nil}
 				}
 			case _QUOTE:
 				quote = !quote
 			default:
-				return nil, &ParseError{f, "bad URI Target", l}
+				return nil, &ParseError{f, "bad URI Target", l, nil}
 			}
 			l = <-c
 		}
 		if quote {
-			return nil, &ParseError{f, "bad URI Target", l}
+			return nil, &ParseError{f, "bad URI Target", l, nil}
 		}
 	case false: // Unquoted
-		return nil, &ParseError{f, "bad URI Target", l}
+		return nil, &ParseError{f, "bad URI Target", l, nil}
 	}
 	rr.Target = s
 	return rr, nil
@@ -1700,21 +1773,21 @@ func setIPSECKEY(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad IPSECKEY Precedence", l}
+		return nil, &ParseError{f, "bad IPSECKEY Precedence", l, nil}
 	} else {
 		rr.Precedence = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad IPSECKEY GatewayType", l}
+		return nil, &ParseError{f, "bad IPSECKEY GatewayType", l, nil}
 	} else {
 		rr.GatewayType = uint8(i)
 	}
 	<-c // _BLANK
 	l = <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad IPSECKEY Algorithm", l}
+		return nil, &ParseError{f, "bad IPSECKEY Algorithm", l, nil}
 	} else {
 		rr.Algorithm = uint8(i)
 	}
@@ -1742,13 +1815,25 @@ func setDHCID(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	return rr, nil
 }
 
+func setOPENPGPKEY(h RR_Header, c chan lex, f string) (RR, *ParseError) {
+	rr := new(OPENPGPKEY)
+	rr.Hdr = h
+
+	s, e := endingToString(c, "bad OPENPGPKEY PublicKey", f)
+	if e != nil {
+		return nil, e
+	}
+	rr.PublicKey = s
+	return rr, nil
+}
+
 func setNID(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	rr := new(NID)
 	rr.Hdr = h
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad NID Preference", l}
+		return nil, &ParseError{f, "bad NID Preference", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}
@@ -1768,7 +1853,7 @@ func setL32(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad L32 Preference", l}
+		return nil, &ParseError{f, "bad L32 Preference", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}
@@ -1776,7 +1861,7 @@ func setL32(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 	l = <-c // _STRING
 	rr.Locator32 = net.ParseIP(l.token)
 	if rr.Locator32 == nil {
-		return nil, &ParseError{f, "bad L32 Locator", l}
+		return nil, &ParseError{f, "bad L32 Locator", l, nil}
 	}
 	return rr, nil
 }
@@ -1787,7 +1872,7 @@ func setLP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad LP Preference", l}
+		return nil, &ParseError{f, "bad LP Preference", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}
@@ -1800,7 +1885,7 @@ func setLP(h RR_Header, c chan lex, o, f string) (RR, *ParseError) {
 	}
 	_, ld, ok := IsDomainName(l.token)
 	if !ok {
-		return nil, &ParseError{f, "bad LP Fqdn", l}
+		return nil, &ParseError{f, "bad LP Fqdn", l, nil}
 	}
 	if rr.Fqdn[ld-1] != '.' {
 		rr.Fqdn = appendOrigin(rr.Fqdn, o)
@@ -1814,7 +1899,7 @@ func setL64(h RR_Header, c chan lex, f string) (RR, *ParseError) {
 
 	l := <-c
 	if i, e := strconv.Atoi(l.token); e != nil {
-		return nil, &ParseError{f, "bad L64 Preference", l}
+		return nil, &ParseError{f, "bad L64 Preference", l, nil}
 	} else {
 		rr.Preference = uint16(i)
 	}